@@ -17,24 +17,31 @@ type AddrList struct {
 	peerByTime     []*peerAddr
 	peerByPriority *btree.BTree
 
-	maxItems   int
-	listenPort int
-	clientIP   *net.IP
-	blocklist  *blocklist.Blocklist
+	maxItems      int
+	dedupDuration time.Duration
+	listenPort    int
+	clientIP      *net.IP
+	blocklist     *blocklist.Blocklist
 
 	countBySource map[peersource.Source]int
+
+	// Addresses returned from Pop and the time they are popped.
+	popped map[string]time.Time
 }
 
 // New returns a new AddrList.
-func New(maxItems int, blocklist *blocklist.Blocklist, listenPort int, clientIP *net.IP) *AddrList {
+// Addresses pushed again within dedupDuration after they are popped are ignored.
+func New(maxItems int, dedupDuration time.Duration, blocklist *blocklist.Blocklist, listenPort int, clientIP *net.IP) *AddrList {
 	return &AddrList{
 		peerByPriority: btree.New(2),
 
 		maxItems:      maxItems,
+		dedupDuration: dedupDuration,
 		listenPort:    listenPort,
 		clientIP:      clientIP,
 		blocklist:     blocklist,
 		countBySource: make(map[peersource.Source]int),
+		popped:        make(map[string]time.Time),
 	}
 }
 
@@ -43,6 +50,7 @@ func (d *AddrList) Reset() {
 	d.peerByTime = nil
 	d.peerByPriority.Clear(false)
 	d.countBySource = make(map[peersource.Source]int)
+	d.popped = make(map[string]time.Time)
 }
 
 // Len returns the number of addresses in the list.
@@ -55,27 +63,42 @@ func (d *AddrList) LenSource(s peersource.Source) int {
 	return d.countBySource[s]
 }
 
-// Pop returns the next address. The returned address is removed from the list.
-func (d *AddrList) Pop() (*net.TCPAddr, peersource.Source) {
+// Pop returns the next address with its source and PEX flags. The returned address is removed from the list.
+func (d *AddrList) Pop() (*net.TCPAddr, peersource.Source, byte) {
 	item := d.peerByPriority.DeleteMax()
 	if item == nil {
-		return nil, 0
+		return nil, 0, 0
 	}
 	p := item.(*peerAddr)
 	d.peerByTime[p.index] = nil
 	d.countBySource[p.source]--
-	return p.addr, p.source
+	if d.dedupDuration > 0 {
+		d.popped[p.addr.String()] = time.Now()
+	}
+	return p.addr, p.source, p.flags
 }
 
 // Push adds a new address to the list. Does nothing if the address is already in the list.
+// Addresses that are popped recently are ignored.
 func (d *AddrList) Push(addrs []*net.TCPAddr, source peersource.Source) {
+	d.PushWithFlags(addrs, nil, source)
+}
+
+// PushWithFlags is like Push but also keeps the PEX flags of the addresses.
+// flags[i] belongs to addrs[i]. Addresses without a corresponding element in flags have no flags.
+func (d *AddrList) PushWithFlags(addrs []*net.TCPAddr, flags []byte, source peersource.Source) {
 	now := time.Now()
+	d.removeExpiredPopped(now)
 	var added int
-	for _, ad := range addrs {
+	for i, ad := range addrs {
 		// 0 port is invalid
 		if ad.Port == 0 {
 			continue
 		}
+		// Already dialed after received from another source
+		if _, ok := d.popped[ad.String()]; ok {
+			continue
+		}
 		// Discard own client
 		if ad.IP.IsLoopback() && ad.Port == d.listenPort {
 			continue
@@ -94,6 +117,9 @@ func (d *AddrList) Push(addrs []*net.TCPAddr, source peersource.Source) {
 			source:    source,
 			priority:  peerpriority.Calculate(ad, d.clientAddr()),
 		}
+		if i < len(flags) {
+			p.flags = flags[i]
+		}
 		item := d.peerByPriority.ReplaceOrInsert(p)
 		if item != nil {
 			prev := item.(*peerAddr)
@@ -121,6 +147,14 @@ func (d *AddrList) Push(addrs []*net.TCPAddr, source peersource.Source) {
 	}
 }
 
+func (d *AddrList) removeExpiredPopped(now time.Time) {
+	for addr, t := range d.popped {
+		if now.Sub(t) >= d.dedupDuration {
+			delete(d.popped, addr)
+		}
+	}
+}
+
 func (d *AddrList) filterNils() {
 	b := d.peerByTime[:0]
 	for _, x := range d.peerByTime {
@@ -3,6 +3,7 @@ package addrlist
 import (
 	"net"
 	"testing"
+	"time"
 
 	"github.com/cenkalti/rain/internal/peersource"
 	"github.com/stretchr/testify/assert"
@@ -10,7 +11,7 @@ import (
 
 func TestAddrList(t *testing.T) {
 	clientIP := net.IPv4(1, 2, 3, 4)
-	al := New(2, nil, 5000, &clientIP)
+	al := New(2, 0, nil, 5000, &clientIP)
 
 	// Push 1st addr
 	al.Push([]*net.TCPAddr{newAddr("1.1.1.1")}, peersource.Tracker)
@@ -53,6 +54,49 @@ func TestAddrList(t *testing.T) {
 	assert.Equal(t, al.peerByTime[1].index, 1)
 }
 
+func TestAddrListDedup(t *testing.T) {
+	clientIP := net.IPv4(1, 2, 3, 4)
+	al := New(10, 50*time.Millisecond, nil, 5000, &clientIP)
+
+	// Same address from two sources is dialed once.
+	al.Push([]*net.TCPAddr{newAddr("1.1.1.1")}, peersource.Tracker)
+	addr, _, _ := al.Pop()
+	assert.Equal(t, "1.1.1.1:1", addr.String())
+	al.Push([]*net.TCPAddr{newAddr("1.1.1.1")}, peersource.DHT)
+	assert.Equal(t, 0, al.Len())
+	assert.Equal(t, 0, al.LenSource(peersource.DHT))
+	addr, _, _ = al.Pop()
+	assert.Nil(t, addr)
+
+	// Address can be dialed again after dedup duration.
+	time.Sleep(60 * time.Millisecond)
+	al.Push([]*net.TCPAddr{newAddr("1.1.1.1")}, peersource.DHT)
+	assert.Equal(t, 1, al.Len())
+
+	// Reset clears the popped addresses.
+	al.Pop()
+	al.Reset()
+	al.Push([]*net.TCPAddr{newAddr("1.1.1.1")}, peersource.Tracker)
+	assert.Equal(t, 1, al.Len())
+}
+
+func TestAddrListFlags(t *testing.T) {
+	clientIP := net.IPv4(1, 2, 3, 4)
+	al := New(10, 0, nil, 5000, &clientIP)
+
+	al.PushWithFlags([]*net.TCPAddr{newAddr("1.1.1.1"), newAddr("2.2.2.2")}, []byte{0x03}, peersource.PEX)
+	flags := make(map[string]byte)
+	for {
+		addr, src, f := al.Pop()
+		if addr == nil {
+			break
+		}
+		assert.Equal(t, peersource.PEX, src)
+		flags[addr.IP.String()] = f
+	}
+	assert.Equal(t, map[string]byte{"1.1.1.1": 0x03, "2.2.2.2": 0}, flags)
+}
+
 func newAddr(ip string) *net.TCPAddr {
 	return &net.TCPAddr{IP: net.ParseIP(ip), Port: 1}
 }
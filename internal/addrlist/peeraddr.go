@@ -15,6 +15,8 @@ type peerAddr struct {
 	timestamp time.Time
 	source    peersource.Source
 	priority  peerpriority.Priority
+	// flags received in "added.f" field of PEX messages
+	flags byte
 
 	// index in AddrList.peerByTime slice
 	index int
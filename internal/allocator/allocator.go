@@ -1,6 +1,9 @@
 package allocator
 
 import (
+	"os"
+	"sync"
+
 	"github.com/cenkalti/rain/internal/metainfo"
 	"github.com/cenkalti/rain/internal/storage"
 )
@@ -42,7 +45,8 @@ func (a *Allocator) Close() {
 }
 
 // Run the Allocator.
-func (a *Allocator) Run(info *metainfo.Info, sto storage.Storage, progressC chan Progress, resultC chan *Allocator) {
+// Files that are marked in skipped are not created on disk until their data is read or written.
+func (a *Allocator) Run(info *metainfo.Info, sto storage.Storage, skipped []bool, progressC chan Progress, resultC chan *Allocator) {
 	defer close(a.doneC)
 
 	defer func() {
@@ -62,6 +66,27 @@ func (a *Allocator) Run(info *metainfo.Info, sto storage.Storage, progressC chan
 	var allocatedSize int64
 	a.Files = make([]File, len(info.Files))
 	for i, f := range info.Files {
+		if f.Padding || f.Symlink != "" {
+			// Padding files and symlinks have no data on disk.
+			// Their data is zeros for the purpose of piece hashing.
+			if f.Symlink != "" {
+				a.Error = sto.Symlink(f.Path, f.Symlink)
+				if a.Error != nil {
+					return
+				}
+			}
+			a.Files[i] = File{Storage: zeroFile{}, Name: f.Path}
+			allocatedSize += f.Length
+			a.sendProgress(progressC, allocatedSize)
+			continue
+		}
+		if i < len(skipped) && skipped[i] {
+			// Skipped file may still be written if it shares a piece with a wanted file.
+			a.Files[i] = File{Storage: &lazyFile{storage: sto, name: f.Path, size: f.Length, executable: f.Executable}, Name: f.Path}
+			allocatedSize += f.Length
+			a.sendProgress(progressC, allocatedSize)
+			continue
+		}
 		var sf storage.File
 		var exists bool
 		sf, exists, a.Error = sto.Open(f.Path, f.Length)
@@ -69,6 +94,12 @@ func (a *Allocator) Run(info *metainfo.Info, sto storage.Storage, progressC chan
 			return
 		}
 		a.Files[i] = File{Storage: sf, Name: f.Path}
+		if f.Executable {
+			a.Error = sto.SetExecutable(f.Path)
+			if a.Error != nil {
+				return
+			}
+		}
 		if exists {
 			a.HasExisting = true
 		} else {
@@ -79,6 +110,86 @@ func (a *Allocator) Run(info *metainfo.Info, sto storage.Storage, progressC chan
 	}
 }
 
+// zeroFile is used in place of padding files. Reads return zeros and writes are discarded.
+type zeroFile struct{}
+
+func (zeroFile) ReadAt(p []byte, off int64) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func (zeroFile) WriteAt(p []byte, off int64) (int, error) { return len(p), nil }
+func (zeroFile) Close() error                             { return nil }
+
+// lazyFile opens the file in storage on first read or write.
+// File is created only when it is written. Reading a file that does not exist returns storage.ErrNotCreated.
+type lazyFile struct {
+	storage    storage.Storage
+	name       string
+	size       int64
+	executable bool
+
+	m    sync.Mutex
+	file storage.File
+}
+
+func (f *lazyFile) open(create bool) (storage.File, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	if f.file != nil {
+		return f.file, nil
+	}
+	if !create {
+		_, err := f.storage.Stat(f.name)
+		if os.IsNotExist(err) {
+			return nil, storage.ErrNotCreated
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	sf, _, err := f.storage.Open(f.name, f.size)
+	if err != nil {
+		return nil, err
+	}
+	if f.executable {
+		err = f.storage.SetExecutable(f.name)
+		if err != nil {
+			sf.Close()
+			return nil, err
+		}
+	}
+	f.file = sf
+	return sf, nil
+}
+
+func (f *lazyFile) ReadAt(p []byte, off int64) (int, error) {
+	sf, err := f.open(false)
+	if err != nil {
+		return 0, err
+	}
+	return sf.ReadAt(p, off)
+}
+
+func (f *lazyFile) WriteAt(p []byte, off int64) (int, error) {
+	sf, err := f.open(true)
+	if err != nil {
+		return 0, err
+	}
+	return sf.WriteAt(p, off)
+}
+
+func (f *lazyFile) Close() error {
+	f.m.Lock()
+	defer f.m.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
+
 func (a *Allocator) sendProgress(progressC chan Progress, size int64) {
 	select {
 	case progressC <- Progress{AllocatedSize: size}:
@@ -0,0 +1,144 @@
+package allocator_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/allocator"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/storage"
+	"github.com/cenkalti/rain/internal/storage/filestorage"
+)
+
+func TestAllocateFileAttributes(t *testing.T) {
+	f, err := os.Open(filepath.Join("..", "metainfo", "testdata", "bep47.torrent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	mi, err := metainfo.New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "rain-allocator-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sto, err := filestorage.New(dir, nil, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := allocator.New()
+	resultC := make(chan *allocator.Allocator, 1)
+	go a.Run(&mi.Info, sto, nil, make(chan allocator.Progress, len(mi.Info.Files)), resultC)
+	a = <-resultC
+	if a.Error != nil {
+		t.Fatal(a.Error)
+	}
+	defer func() {
+		for _, f := range a.Files {
+			f.Storage.Close()
+		}
+	}()
+
+	fi, err := os.Stat(filepath.Join(dir, "bep47", "run.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&0100 == 0 {
+		t.Errorf("run.sh is not executable: %s", fi.Mode())
+	}
+	if _, err = os.Stat(filepath.Join(dir, "bep47", ".pad")); !os.IsNotExist(err) {
+		t.Errorf("padding file must not be created on disk: %v", err)
+	}
+	target, err := os.Readlink(filepath.Join(dir, "bep47", "link.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "data.txt" {
+		t.Errorf("invalid link target: %s", target)
+	}
+
+	// Padding must read as zeros for the hash of the first piece to match.
+	script := []byte("#!/bin/sh\necho hello\n")
+	if _, err = a.Files[0].Storage.WriteAt(script, 0); err != nil {
+		t.Fatal(err)
+	}
+	pieces := piece.NewPieces(&mi.Info, a.Files)
+	buf := make([]byte, pieces[0].Length)
+	if _, err = pieces[0].Data.ReadAt(buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha1.Sum(buf)
+	if !bytes.Equal(sum[:], pieces[0].Hash) {
+		t.Error("hash of the first piece does not match")
+	}
+}
+
+func TestAllocateSkippedFile(t *testing.T) {
+	f, err := os.Open(filepath.Join("..", "metainfo", "testdata", "bep47.torrent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	mi, err := metainfo.New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "rain-allocator-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sto, err := filestorage.New(dir, nil, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	skipped := make([]bool, len(mi.Info.Files))
+	skipped[0] = true
+	a := allocator.New()
+	resultC := make(chan *allocator.Allocator, 1)
+	go a.Run(&mi.Info, sto, skipped, make(chan allocator.Progress, len(mi.Info.Files)), resultC)
+	a = <-resultC
+	if a.Error != nil {
+		t.Fatal(a.Error)
+	}
+	defer func() {
+		for _, f := range a.Files {
+			f.Storage.Close()
+		}
+	}()
+
+	name := filepath.Join(dir, mi.Info.Files[0].Path)
+	if _, err = os.Stat(name); !os.IsNotExist(err) {
+		t.Fatalf("skipped file must not be created on disk: %v", err)
+	}
+	// Reading does not create the file.
+	if _, err = a.Files[0].Storage.ReadAt(make([]byte, 3), 0); err != storage.ErrNotCreated {
+		t.Fatalf("unexpected read error: %v", err)
+	}
+	if _, err = os.Stat(name); !os.IsNotExist(err) {
+		t.Fatalf("skipped file must not be created on read: %v", err)
+	}
+	// File is created when its data is written.
+	if _, err = a.Files[0].Storage.WriteAt([]byte("foo"), 0); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != mi.Info.Files[0].Length {
+		t.Errorf("invalid file size: %d", fi.Size())
+	}
+}
@@ -3,6 +3,7 @@ package announcer
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/cenkalti/rain/internal/tracker"
 )
@@ -37,3 +38,18 @@ func announce(
 	case <-ctx.Done():
 	}
 }
+
+// Result of an announce attempt. Passed to the result function of the announcers.
+// Result functions must not block because they are called from the announcer goroutines.
+type Result struct {
+	// URL of the tracker. Empty for DHT and LSD announces.
+	Tracker string
+	Event   tracker.Event
+	// Time until the next announce. Zero for stopped event.
+	Interval time.Duration
+	// Number of peers returned from the tracker.
+	// Peers found with DHT and LSD are received later, so it is always zero for them.
+	NumPeers int
+	// Set if the announce has failed.
+	Err *AnnounceError
+}
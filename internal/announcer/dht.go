@@ -4,13 +4,16 @@ import (
 	"time"
 
 	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/tracker"
 )
 
 // DHTAnnouncer runs a function periodically to announce the Torrent to DHT network.
+// It is also used for announcing to the local network with LSD.
 type DHTAnnouncer struct {
 	lastAnnounce   time.Time
 	needMorePeers  bool
 	needMorePeersC chan bool
+	triggerC       chan struct{}
 	closeC         chan struct{}
 	doneC          chan struct{}
 }
@@ -19,6 +22,7 @@ type DHTAnnouncer struct {
 func NewDHTAnnouncer() *DHTAnnouncer {
 	return &DHTAnnouncer{
 		needMorePeersC: make(chan bool),
+		triggerC:       make(chan struct{}, 1),
 		closeC:         make(chan struct{}),
 		doneC:          make(chan struct{}),
 		needMorePeers:  true,
@@ -39,25 +43,40 @@ func (a *DHTAnnouncer) NeedMorePeers(val bool) {
 	}
 }
 
+// Trigger an announce now. The announce is skipped if the last announce is done within the min interval.
+func (a *DHTAnnouncer) Trigger() {
+	select {
+	case a.triggerC <- struct{}{}:
+	default:
+	}
+}
+
 // Run the announcer. Invoke with go statement.
-func (a *DHTAnnouncer) Run(announceFunc func(), interval, minInterval time.Duration, l logger.Logger) {
+// onResult is called after each announce if it is not nil.
+func (a *DHTAnnouncer) Run(announceFunc func(), interval, minInterval time.Duration, onResult func(Result), l logger.Logger) {
 	defer close(a.doneC)
 
 	timer := time.NewTimer(minInterval)
 	defer timer.Stop()
 
-	resetTimer := func() {
+	nextInterval := func() time.Duration {
 		if a.needMorePeers {
-			timer.Reset(time.Until(a.lastAnnounce.Add(minInterval)))
-		} else {
-			timer.Reset(time.Until(a.lastAnnounce.Add(interval)))
+			return minInterval
 		}
+		return interval
+	}
+
+	resetTimer := func() {
+		timer.Reset(time.Until(a.lastAnnounce.Add(nextInterval())))
 	}
 
 	announce := func() {
 		announceFunc()
 		a.lastAnnounce = time.Now()
 		resetTimer()
+		if onResult != nil {
+			onResult(Result{Event: tracker.EventNone, Interval: nextInterval()})
+		}
 	}
 
 	announce()
@@ -67,6 +86,10 @@ func (a *DHTAnnouncer) Run(announceFunc func(), interval, minInterval time.Durat
 			announce()
 		case a.needMorePeers = <-a.needMorePeersC:
 			resetTimer()
+		case <-a.triggerC:
+			if time.Since(a.lastAnnounce) >= minInterval {
+				announce()
+			}
 		case <-a.closeC:
 			return
 		}
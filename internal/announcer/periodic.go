@@ -3,6 +3,7 @@ package announcer
 import (
 	"context"
 	"math"
+	"math/rand"
 	"net"
 	"net/url"
 	"reflect"
@@ -40,6 +41,12 @@ type PeriodicalAnnouncer struct {
 	numWant       int
 	interval      time.Duration
 	minInterval   time.Duration
+	startDelay    time.Duration
+	jitter        float64
+	starvation    float64
+	starvedC      chan struct{}
+	errorsC       chan TrackerError
+	onResult      func(Result)
 	seeders       int
 	leechers      int
 	warningMsg    string
@@ -50,8 +57,12 @@ type PeriodicalAnnouncer struct {
 	backoff       backoff.BackOff
 	getTorrent    func() tracker.Torrent
 	lastAnnounce  time.Time
+	lastSuccess   time.Time
 	nextAnnounce  time.Time
+	numSuccess    int
+	numFailure    int
 	HasAnnounced  bool
+	lastEvent     tracker.Event
 	responseC     chan *tracker.AnnounceResponse
 	errC          chan error
 	closeC        chan struct{}
@@ -60,21 +71,39 @@ type PeriodicalAnnouncer struct {
 	needMorePeers  bool
 	mNeedMorePeers sync.RWMutex
 	needMorePeersC chan struct{}
+
+	// Used instead of the interval returned from the tracker if not zero.
+	intervalOverride  time.Duration
+	mIntervalOverride sync.RWMutex
+
+	triggerC chan struct{}
 }
 
 // NewPeriodicalAnnouncer returns a new PeriodicalAnnouncer.
-func NewPeriodicalAnnouncer(trk tracker.Tracker, numWant int, minInterval time.Duration, getTorrent func() tracker.Torrent, completedC chan struct{}, newPeers chan []*net.TCPAddr, l logger.Logger) *PeriodicalAnnouncer {
+// First announce with "started" event is done after startDelay.
+// jitter is the fraction of the announce interval that the next announce time is randomized by in both directions.
+// If the tracker returns fewer peers than starvation*numWant, a value is sent to starvedC without blocking.
+// Failed announces are sent to errorsC if it is not nil.
+// onResult is called after each announce if it is not nil.
+func NewPeriodicalAnnouncer(trk tracker.Tracker, numWant int, minInterval, startDelay time.Duration, jitter, starvation float64, getTorrent func() tracker.Torrent, completedC chan struct{}, newPeers chan []*net.TCPAddr, starvedC chan struct{}, errorsC chan TrackerError, onResult func(Result), l logger.Logger) *PeriodicalAnnouncer {
 	return &PeriodicalAnnouncer{
 		Tracker:        trk,
 		status:         NotContactedYet,
 		statsCommandC:  make(chan statsRequest),
 		numWant:        numWant,
 		minInterval:    minInterval,
+		startDelay:     startDelay,
+		jitter:         jitter,
+		starvation:     starvation,
+		starvedC:       starvedC,
+		errorsC:        errorsC,
+		onResult:       onResult,
 		log:            l,
 		completedC:     completedC,
 		newPeers:       newPeers,
 		getTorrent:     getTorrent,
 		needMorePeersC: make(chan struct{}, 1),
+		triggerC:       make(chan struct{}, 1),
 		responseC:      make(chan *tracker.AnnounceResponse),
 		errC:           make(chan error),
 		closeC:         make(chan struct{}),
@@ -127,6 +156,30 @@ func (a *PeriodicalAnnouncer) NeedMorePeers(val bool) {
 	}
 }
 
+// SetIntervalOverride sets the interval used between announces instead of the interval returned from the tracker.
+// Min interval is still respected. Zero value removes the override.
+func (a *PeriodicalAnnouncer) SetIntervalOverride(d time.Duration) {
+	a.mIntervalOverride.Lock()
+	a.intervalOverride = d
+	a.mIntervalOverride.Unlock()
+	// Next announce time is calculated again.
+	select {
+	case a.needMorePeersC <- struct{}{}:
+	case <-a.doneC:
+	default:
+	}
+}
+
+// Trigger an announce without waiting for the next interval.
+// Announce is not done if the last one is done within the min interval, so multiple calls are coalesced into one announce.
+func (a *PeriodicalAnnouncer) Trigger() {
+	select {
+	case a.triggerC <- struct{}{}:
+	case <-a.doneC:
+	default:
+	}
+}
+
 // Run the announcer goroutine. Invoke with go statement.
 func (a *PeriodicalAnnouncer) Run() {
 	defer close(a.doneC)
@@ -153,18 +206,31 @@ func (a *PeriodicalAnnouncer) Run() {
 	default:
 	}
 
-	a.doAnnounce(ctx, tracker.EventStarted, a.numWant)
+	// "started" event is sent when the timer fires if the first announce is delayed.
+	pendingStarted := a.startDelay > 0
+	if pendingStarted {
+		resetTimer(a.startDelay)
+	} else {
+		a.doAnnounce(ctx, tracker.EventStarted, a.numWant)
+	}
 	for {
 		select {
 		case <-timer.C:
 			if a.status == Contacting {
 				break
 			}
+			if pendingStarted {
+				pendingStarted = false
+				a.doAnnounce(ctx, tracker.EventStarted, a.numWant)
+				break
+			}
 			a.doAnnounce(ctx, tracker.EventNone, a.numWant)
 		case resp := <-a.responseC:
 			a.status = Working
+			a.numSuccess++
 			a.seeders = int(resp.Seeders)
 			a.leechers = int(resp.Leechers)
+			a.lastSuccess = time.Now()
 			a.warningMsg = resp.WarningMessage
 			if a.warningMsg != "" {
 				a.log.Debugln("announce warning:", a.warningMsg)
@@ -173,21 +239,35 @@ func (a *PeriodicalAnnouncer) Run() {
 			if resp.MinInterval > 0 {
 				a.minInterval = resp.MinInterval
 			}
+			// Do not announce more frequently than allowed.
+			// This also prevents announcing in a loop if tracker does not send an interval.
+			if a.interval < a.minInterval {
+				a.interval = a.minInterval
+			}
 			a.HasAnnounced = true
 			a.lastError = nil
 			a.backoff.Reset()
 			interval := a.getNextInterval()
 			resetTimer(interval)
+			a.sendResult(Result{Interval: interval, NumPeers: len(resp.Peers)})
 			go func() {
 				select {
 				case a.newPeers <- resp.Peers:
 				case <-a.closeC:
 				}
 			}()
+			if a.isStarved(len(resp.Peers)) {
+				a.log.Debugf("tracker returned %d peers, %d requested", len(resp.Peers), a.numWant)
+				select {
+				case a.starvedC <- struct{}{}:
+				default:
+				}
+			}
 		case err := <-a.errC:
 			a.status = NotWorking
+			a.numFailure++
 			// Give more friendly error to the user
-			a.lastError = a.newAnnounceError(err)
+			a.lastError = newAnnounceError(a.Tracker, err)
 			if a.lastError.Unknown {
 				a.log.Errorln("announce error:", a.lastError.ErrorWithType())
 			} else {
@@ -195,13 +275,42 @@ func (a *PeriodicalAnnouncer) Run() {
 			}
 			interval := a.getNextIntervalFromError(a.lastError)
 			resetTimer(interval)
+			a.sendResult(Result{Interval: interval, Err: a.lastError})
+			if a.errorsC != nil {
+				terr := TrackerError{URL: a.Tracker.URL(), Err: a.lastError}
+				go func() {
+					select {
+					case a.errorsC <- terr:
+					case <-a.closeC:
+					}
+				}()
+			}
 		case <-a.needMorePeersC:
-			if a.status == Contacting || a.status == NotWorking {
+			if pendingStarted || a.status == Contacting || a.status == NotWorking {
 				break
 			}
 			interval := time.Until(a.lastAnnounce.Add(a.getNextInterval()))
 			resetTimer(interval)
+		case <-a.triggerC:
+			if pendingStarted {
+				pendingStarted = false
+				a.doAnnounce(ctx, tracker.EventStarted, a.numWant)
+				break
+			}
+			if a.status == Contacting {
+				break
+			}
+			if since := time.Since(a.lastAnnounce); since < a.minInterval {
+				a.log.Debugf("not announcing, last announce was %s ago", since.Truncate(time.Second))
+				break
+			}
+			a.doAnnounce(ctx, tracker.EventNone, a.numWant)
 		case <-a.completedC:
+			if pendingStarted {
+				// Torrent is already complete when "started" event is sent.
+				a.completedC = nil
+				break
+			}
 			if a.status == Contacting {
 				cancel()
 				ctx, cancel = context.WithCancel(context.Background())
@@ -217,14 +326,44 @@ func (a *PeriodicalAnnouncer) Run() {
 	}
 }
 
+func (a *PeriodicalAnnouncer) isStarved(numPeers int) bool {
+	if a.starvedC == nil || a.starvation <= 0 {
+		return false
+	}
+	return float64(numPeers) < a.starvation*float64(a.numWant)
+}
+
 func (a *PeriodicalAnnouncer) getNextInterval() time.Duration {
 	a.mNeedMorePeers.RLock()
 	need := a.needMorePeers
 	a.mNeedMorePeers.RUnlock()
 	if need {
-		return a.minInterval
+		return a.addJitter(a.minInterval)
 	}
-	return a.interval
+	a.mIntervalOverride.RLock()
+	override := a.intervalOverride
+	a.mIntervalOverride.RUnlock()
+	if override > 0 {
+		if override < a.minInterval {
+			return a.minInterval
+		}
+		return override
+	}
+	return a.addJitter(a.interval)
+}
+
+// addJitter randomizes the interval so announces of many torrents to the same tracker spread out over time.
+// Result is never less than the min interval.
+func (a *PeriodicalAnnouncer) addJitter(interval time.Duration) time.Duration {
+	if a.jitter <= 0 {
+		return interval
+	}
+	delta := (rand.Float64()*2 - 1) * a.jitter * float64(interval)
+	interval += time.Duration(delta)
+	if interval < a.minInterval {
+		interval = a.minInterval
+	}
+	return interval
 }
 
 func (a *PeriodicalAnnouncer) getNextIntervalFromError(err *AnnounceError) time.Duration {
@@ -238,6 +377,17 @@ func (a *PeriodicalAnnouncer) doAnnounce(ctx context.Context, event tracker.Even
 	go a.announce(ctx, event, numWant)
 	a.status = Contacting
 	a.lastAnnounce = time.Now()
+	a.lastEvent = event
+}
+
+// sendResult fills the fields of the last announce and passes the result to the result function.
+func (a *PeriodicalAnnouncer) sendResult(r Result) {
+	if a.onResult == nil {
+		return
+	}
+	r.Tracker = a.Tracker.URL()
+	r.Event = a.lastEvent
+	a.onResult(r)
 }
 
 func (a *PeriodicalAnnouncer) announce(ctx context.Context, event tracker.Event, numWant int) {
@@ -252,7 +402,12 @@ type Stats struct {
 	Seeders      int
 	Leechers     int
 	LastAnnounce time.Time
+	// Time of the last successful announce. Seeders and Leechers are received at this time.
+	LastSuccess  time.Time
 	NextAnnounce time.Time
+	// Number of successful and failed announces.
+	Successes int
+	Failures  int
 }
 
 func (a *PeriodicalAnnouncer) stats() Stats {
@@ -263,10 +418,19 @@ func (a *PeriodicalAnnouncer) stats() Stats {
 		Seeders:      a.seeders,
 		Leechers:     a.leechers,
 		LastAnnounce: a.lastAnnounce,
+		LastSuccess:  a.lastSuccess,
 		NextAnnounce: a.nextAnnounce,
+		Successes:    a.numSuccess,
+		Failures:     a.numFailure,
 	}
 }
 
+// TrackerError is sent from the PeriodicalAnnouncer when an announce fails.
+type TrackerError struct {
+	URL string
+	Err *AnnounceError
+}
+
 // AnnounceError the error that comes from the Tracker itself.
 type AnnounceError struct {
 	Err     error
@@ -274,18 +438,18 @@ type AnnounceError struct {
 	Unknown bool
 }
 
-func (a *PeriodicalAnnouncer) newAnnounceError(err error) (e *AnnounceError) {
+func newAnnounceError(trk tracker.Tracker, err error) (e *AnnounceError) {
 	e = &AnnounceError{Err: err}
 	switch err {
 	case resolver.ErrNotIPv4Address:
-		parsed, _ := url.Parse(a.Tracker.URL())
+		parsed, _ := url.Parse(trk.URL())
 		e.Message = "tracker has no IPv4 address: " + parsed.Hostname()
 		return
 	case resolver.ErrBlocked:
 		e.Message = "tracker IP is blocked"
 		return
 	case resolver.ErrInvalidPort:
-		parsed, _ := url.Parse(a.Tracker.URL())
+		parsed, _ := url.Parse(trk.URL())
 		e.Message = "invalid port number in tracker address: " + parsed.Host
 		return
 	case tracker.ErrDecode:
@@ -321,12 +485,12 @@ func (a *PeriodicalAnnouncer) newAnnounceError(err error) (e *AnnounceError) {
 			return
 		}
 		if strings.HasSuffix(s, "no such host") {
-			parsed, _ := url.Parse(a.Tracker.URL())
+			parsed, _ := url.Parse(trk.URL())
 			e.Message = "no such host: " + parsed.Hostname()
 			return
 		}
 		if strings.HasSuffix(s, "server misbehaving") {
-			parsed, _ := url.Parse(a.Tracker.URL())
+			parsed, _ := url.Parse(trk.URL())
 			e.Message = "server misbehaving: " + parsed.Hostname()
 			return
 		}
@@ -335,12 +499,12 @@ func (a *PeriodicalAnnouncer) newAnnounceError(err error) (e *AnnounceError) {
 			return
 		}
 		if strings.HasSuffix(s, "no route to host") {
-			parsed, _ := url.Parse(a.Tracker.URL())
+			parsed, _ := url.Parse(trk.URL())
 			e.Message = "no route to host: " + parsed.Hostname()
 			return
 		}
 		if strings.HasSuffix(s, resolver.ErrNotIPv4Address.Error()) {
-			parsed, _ := url.Parse(a.Tracker.URL())
+			parsed, _ := url.Parse(trk.URL())
 			e.Message = "tracker has no IPv4 address: " + parsed.Hostname()
 			return
 		}
@@ -361,7 +525,7 @@ func (a *PeriodicalAnnouncer) newAnnounceError(err error) (e *AnnounceError) {
 			return
 		}
 		if strings.Contains(s, "network is unreachable") {
-			parsed, _ := url.Parse(a.Tracker.URL())
+			parsed, _ := url.Parse(trk.URL())
 			e.Message = "network is unreachable: " + parsed.Hostname()
 			return
 		}
@@ -0,0 +1,234 @@
+package announcer
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/tracker"
+)
+
+type fakeTracker struct {
+	resp *tracker.AnnounceResponse
+}
+
+func (t *fakeTracker) Announce(ctx context.Context, req tracker.AnnounceRequest) (*tracker.AnnounceResponse, error) {
+	return t.resp, nil
+}
+
+func (t *fakeTracker) URL() string {
+	return "udp://tracker.example.com:1337/announce"
+}
+
+func (t *fakeTracker) Scrape(ctx context.Context, infoHashes [][20]byte) ([]tracker.ScrapeResult, error) {
+	return nil, tracker.ErrScrapeNotSupported
+}
+
+func runFakeAnnouncer(t *testing.T, resp *tracker.AnnounceResponse) Stats {
+	newPeers := make(chan []*net.TCPAddr, 1)
+	getTorrent := func() tracker.Torrent { return tracker.Torrent{} }
+	a := NewPeriodicalAnnouncer(&fakeTracker{resp: resp}, 50, time.Minute, 0, 0, 0, getTorrent, make(chan struct{}), newPeers, nil, nil, nil, logger.New("test"))
+	go a.Run()
+	defer a.Close()
+	select {
+	case <-newPeers:
+	case <-time.After(time.Second):
+		t.Fatal("no announce response")
+	}
+	return a.Stats()
+}
+
+func TestPeriodicalAnnouncerUsesTrackerInterval(t *testing.T) {
+	stats := runFakeAnnouncer(t, &tracker.AnnounceResponse{
+		Interval: 30 * time.Minute,
+		Leechers: 3,
+		Seeders:  7,
+	})
+	if stats.Status != Working {
+		t.Fatalf("invalid status: %d", stats.Status)
+	}
+	if stats.Leechers != 3 || stats.Seeders != 7 {
+		t.Errorf("invalid swarm stats: %d leechers, %d seeders", stats.Leechers, stats.Seeders)
+	}
+	if stats.LastSuccess.IsZero() {
+		t.Error("time of successful announce is not set")
+	}
+	next := stats.NextAnnounce.Sub(stats.LastAnnounce)
+	if next < 29*time.Minute || next > 31*time.Minute {
+		t.Errorf("invalid next announce: %s", next)
+	}
+}
+
+func TestPeriodicalAnnouncerHonorsMinInterval(t *testing.T) {
+	stats := runFakeAnnouncer(t, &tracker.AnnounceResponse{
+		Interval:    0,
+		MinInterval: 5 * time.Minute,
+	})
+	next := stats.NextAnnounce.Sub(stats.LastAnnounce)
+	if next < 4*time.Minute || next > 6*time.Minute {
+		t.Errorf("invalid next announce: %s", next)
+	}
+}
+
+func TestPeriodicalAnnouncerJitter(t *testing.T) {
+	a := NewPeriodicalAnnouncer(&fakeTracker{}, 50, 5*time.Minute, 0, 0.1, 0, nil, nil, nil, nil, nil, nil, logger.New("test"))
+	a.interval = 30 * time.Minute
+	seen := make(map[time.Duration]struct{})
+	for i := 0; i < 100; i++ {
+		next := a.getNextInterval()
+		if next < 27*time.Minute || next > 33*time.Minute {
+			t.Fatalf("next announce is out of bounds: %s", next)
+		}
+		seen[next] = struct{}{}
+	}
+	if len(seen) < 2 {
+		t.Fatal("next announce is not jittered")
+	}
+	a.interval = 5 * time.Minute
+	for i := 0; i < 100; i++ {
+		next := a.getNextInterval()
+		if next < 5*time.Minute || next > 5*time.Minute+30*time.Second {
+			t.Fatalf("next announce is out of bounds: %s", next)
+		}
+	}
+}
+
+func TestPeriodicalAnnouncerStarvation(t *testing.T) {
+	run := func(numPeers int) bool {
+		resp := &tracker.AnnounceResponse{Interval: 30 * time.Minute}
+		for i := 0; i < numPeers; i++ {
+			resp.Peers = append(resp.Peers, &net.TCPAddr{IP: net.IPv4(1, 2, 3, byte(i)), Port: 6881})
+		}
+		newPeers := make(chan []*net.TCPAddr, 1)
+		starvedC := make(chan struct{}, 1)
+		getTorrent := func() tracker.Torrent { return tracker.Torrent{} }
+		a := NewPeriodicalAnnouncer(&fakeTracker{resp: resp}, 50, time.Minute, 0, 0, 0.1, getTorrent, make(chan struct{}), newPeers, starvedC, nil, nil, logger.New("test"))
+		go a.Run()
+		defer a.Close()
+		select {
+		case <-newPeers:
+		case <-time.After(time.Second):
+			t.Fatal("no announce response")
+		}
+		a.Stats() // wait until response is processed
+		select {
+		case <-starvedC:
+			return true
+		default:
+			return false
+		}
+	}
+	if !run(0) {
+		t.Error("starvation is not signalled when tracker returns zero peers")
+	}
+	if run(5) {
+		t.Error("starvation is signalled when tracker returns enough peers")
+	}
+}
+
+type countingTracker struct {
+	fakeTracker
+	m     sync.Mutex
+	count int
+}
+
+// Announce returns a min interval of one minute after the first announce.
+func (t *countingTracker) Announce(ctx context.Context, req tracker.AnnounceRequest) (*tracker.AnnounceResponse, error) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.count++
+	resp := *t.resp
+	if t.count > 1 {
+		resp.MinInterval = time.Minute
+	}
+	return &resp, nil
+}
+
+func (t *countingTracker) announced() int {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.count
+}
+
+func TestPeriodicalAnnouncerTrigger(t *testing.T) {
+	trk := &countingTracker{fakeTracker: fakeTracker{resp: &tracker.AnnounceResponse{Interval: 30 * time.Minute}}}
+	newPeers := make(chan []*net.TCPAddr, 10)
+	getTorrent := func() tracker.Torrent { return tracker.Torrent{} }
+	a := NewPeriodicalAnnouncer(trk, 50, 0, 0, 0, 0, getTorrent, make(chan struct{}), newPeers, nil, nil, nil, logger.New("test"))
+	go a.Run()
+	defer a.Close()
+	waitAnnounce := func() {
+		select {
+		case <-newPeers:
+		case <-time.After(time.Second):
+			t.Fatal("no announce response")
+		}
+		a.Stats() // wait until response is processed
+	}
+	waitAnnounce()
+
+	// Announce is done without waiting for the interval.
+	a.Trigger()
+	waitAnnounce()
+	if n := trk.announced(); n != 2 {
+		t.Fatalf("announced %d times", n)
+	}
+
+	// Calls within the min interval returned from the tracker are coalesced.
+	a.Trigger()
+	a.Trigger()
+	a.Stats() // wait until triggers are processed
+	if n := trk.announced(); n != 2 {
+		t.Fatalf("announced %d times", n)
+	}
+}
+
+func TestPeriodicalAnnouncerIntervalOverride(t *testing.T) {
+	a := NewPeriodicalAnnouncer(&fakeTracker{}, 50, time.Minute, 0, 0.1, 0, nil, nil, nil, nil, nil, nil, logger.New("test"))
+	a.interval = 30 * time.Minute
+	a.SetIntervalOverride(2 * time.Minute)
+	if next := a.getNextInterval(); next != 2*time.Minute {
+		t.Fatalf("invalid next announce: %s", next)
+	}
+	a.SetIntervalOverride(time.Second)
+	if next := a.getNextInterval(); next != time.Minute {
+		t.Fatalf("min interval is not respected: %s", next)
+	}
+}
+
+func TestPeriodicalAnnouncerResult(t *testing.T) {
+	resp := &tracker.AnnounceResponse{
+		Interval: 30 * time.Minute,
+		Peers:    []*net.TCPAddr{{IP: net.IPv4(1, 2, 3, 4), Port: 5}},
+	}
+	results := make(chan Result, 1)
+	onResult := func(r Result) { results <- r }
+	getTorrent := func() tracker.Torrent { return tracker.Torrent{} }
+	a := NewPeriodicalAnnouncer(&fakeTracker{resp: resp}, 50, time.Minute, 0, 0, 0, getTorrent, make(chan struct{}), make(chan []*net.TCPAddr, 1), nil, nil, onResult, logger.New("test"))
+	go a.Run()
+	defer a.Close()
+	var r Result
+	select {
+	case r = <-results:
+	case <-time.After(time.Second):
+		t.Fatal("no announce result")
+	}
+	if r.Tracker != "udp://tracker.example.com:1337/announce" {
+		t.Fatalf("unexpected tracker: %s", r.Tracker)
+	}
+	if r.Event != tracker.EventStarted {
+		t.Fatalf("unexpected event: %s", r.Event)
+	}
+	if r.Interval != 30*time.Minute {
+		t.Fatalf("unexpected interval: %s", r.Interval)
+	}
+	if r.NumPeers != 1 {
+		t.Fatalf("unexpected number of peers: %d", r.NumPeers)
+	}
+	if r.Err != nil {
+		t.Fatal(r.Err.Err)
+	}
+}
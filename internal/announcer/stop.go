@@ -12,21 +12,31 @@ import (
 type StopAnnouncer struct {
 	log      logger.Logger
 	timeout  time.Duration
+	deadline time.Duration
+	retries  int
 	trackers []tracker.Tracker
 	torrent  tracker.Torrent
 	resultC  chan struct{}
+	onResult func(Result)
 	closeC   chan struct{}
 	doneC    chan struct{}
+	failed   bool
 }
 
 // NewStopAnnouncer returns a new StopAnnouncer.
-func NewStopAnnouncer(trackers []tracker.Tracker, tra tracker.Torrent, timeout time.Duration, resultC chan struct{}, l logger.Logger) *StopAnnouncer {
+// Each tracker is given `timeout` to accept the event and failed announces are retried `retries` times until then.
+// A result is sent after `deadline` even if some trackers have not returned yet. Zero deadline means no limit.
+// onResult is called after the event is announced to each tracker if it is not nil.
+func NewStopAnnouncer(trackers []tracker.Tracker, tra tracker.Torrent, timeout, deadline time.Duration, retries int, resultC chan struct{}, onResult func(Result), l logger.Logger) *StopAnnouncer {
 	return &StopAnnouncer{
 		log:      l,
 		timeout:  timeout,
+		deadline: deadline,
+		retries:  retries,
 		trackers: trackers,
 		torrent:  tra,
 		resultC:  resultC,
+		onResult: onResult,
 		closeC:   make(chan struct{}),
 		doneC:    make(chan struct{}),
 	}
@@ -38,35 +48,90 @@ func (a *StopAnnouncer) Close() {
 	<-a.doneC
 }
 
+// Succeeded returns true if all trackers have accepted the stopped event.
+// Must be called after a value is received from the result channel.
+func (a *StopAnnouncer) Succeeded() bool {
+	return !a.failed
+}
+
 // Run the announcer.
 func (a *StopAnnouncer) Run() {
 	defer close(a.doneC)
 
-	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(a.timeout))
-	go func() {
-		select {
-		case <-ctx.Done():
-		case <-a.closeC:
-			cancel()
-		}
-	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	doneC := make(chan struct{})
+	// Buffered so that trackers returning after the deadline do not block.
+	doneC := make(chan bool, len(a.trackers))
 	for _, trk := range a.trackers {
 		go func(trk tracker.Tracker) {
-			req := tracker.AnnounceRequest{
-				Torrent: a.torrent,
-				Event:   tracker.EventStopped,
-			}
-			_, _ = trk.Announce(ctx, req)
-			doneC <- struct{}{}
+			tctx, tcancel := context.WithTimeout(ctx, a.timeout)
+			defer tcancel()
+			doneC <- a.announce(tctx, trk)
 		}(trk)
 	}
-	for range a.trackers {
-		<-doneC
+
+	var deadlineC <-chan time.Time
+	if a.deadline > 0 {
+		t := time.NewTimer(a.deadline)
+		defer t.Stop()
+		deadlineC = t.C
+	}
+	remaining := len(a.trackers)
+loop:
+	for ; remaining > 0; remaining-- {
+		select {
+		case ok := <-doneC:
+			if !ok {
+				a.failed = true
+			}
+		case <-deadlineC:
+			a.log.Debugf("stopped event is not acknowledged by %d trackers in %s", remaining, a.deadline)
+			a.failed = true
+			break loop
+		case <-a.closeC:
+			a.failed = true
+			return
+		}
 	}
 	select {
 	case a.resultC <- struct{}{}:
 	case <-a.closeC:
 	}
 }
+
+func (a *StopAnnouncer) announce(ctx context.Context, trk tracker.Tracker) bool {
+	req := tracker.AnnounceRequest{
+		Torrent: a.torrent,
+		Event:   tracker.EventStopped,
+	}
+	for i := 0; ; i++ {
+		_, err := trk.Announce(ctx, req)
+		if err == nil {
+			a.sendResult(trk, nil)
+			return true
+		}
+		if i >= a.retries {
+			a.log.Debugf("cannot announce stopped event to tracker %s: %s", trk.URL(), err)
+			a.sendResult(trk, err)
+			return false
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			a.sendResult(trk, err)
+			return false
+		}
+	}
+}
+
+func (a *StopAnnouncer) sendResult(trk tracker.Tracker, err error) {
+	if a.onResult == nil {
+		return
+	}
+	r := Result{Tracker: trk.URL(), Event: tracker.EventStopped}
+	if err != nil {
+		r.Err = newAnnounceError(trk, err)
+	}
+	a.onResult(r)
+}
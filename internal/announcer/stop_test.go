@@ -0,0 +1,55 @@
+package announcer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/tracker"
+)
+
+// hangingTracker never returns from Announce, ignoring the context.
+type hangingTracker struct {
+	fakeTracker
+	releaseC chan struct{}
+}
+
+func (t *hangingTracker) Announce(ctx context.Context, req tracker.AnnounceRequest) (*tracker.AnnounceResponse, error) {
+	<-t.releaseC
+	return nil, context.Canceled
+}
+
+func TestStopAnnouncerDeadline(t *testing.T) {
+	ht := &hangingTracker{releaseC: make(chan struct{})}
+	defer close(ht.releaseC)
+	resultC := make(chan struct{})
+	trackers := []tracker.Tracker{&fakeTracker{}, ht}
+	a := NewStopAnnouncer(trackers, tracker.Torrent{}, time.Minute, 100*time.Millisecond, 0, resultC, nil, logger.New("test"))
+	go a.Run()
+	defer a.Close()
+	select {
+	case <-resultC:
+	case <-time.After(time.Second):
+		t.Fatal("stop announcer did not give up after deadline")
+	}
+	if a.Succeeded() {
+		t.Fatal("stopped event must not succeed when a tracker has not responded")
+	}
+}
+
+func TestStopAnnouncerSucceeded(t *testing.T) {
+	resultC := make(chan struct{})
+	trackers := []tracker.Tracker{&fakeTracker{}, &fakeTracker{}}
+	a := NewStopAnnouncer(trackers, tracker.Torrent{}, time.Minute, time.Minute, 0, resultC, nil, logger.New("test"))
+	go a.Run()
+	defer a.Close()
+	select {
+	case <-resultC:
+	case <-time.After(time.Second):
+		t.Fatal("no result")
+	}
+	if !a.Succeeded() {
+		t.Fatal("stopped event is not succeeded")
+	}
+}
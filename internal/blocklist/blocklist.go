@@ -7,12 +7,22 @@ import (
 	"errors"
 	"io"
 	"net"
+	"strconv"
 	"sync"
 
 	"github.com/cenkalti/rain/internal/blocklist/stree"
 )
 
-var errNotIPv4Address = errors.New("address is not ipv4")
+var (
+	errNotIPv4Address     = errors.New("address is not ipv4")
+	errInvalidRange       = errors.New("invalid ip range")
+	errInvalidAccessLevel = errors.New("invalid access level")
+	// errAllowedRange is returned for eMule rules that allow the range instead of blocking.
+	errAllowedRange = errors.New("ip range is allowed")
+)
+
+// eMule rules with access level above this value allow the range.
+const maxBlockedAccessLevel = 127
 
 // Blocklist holds a list of IP ranges in a Segment Tree structure for faster lookups.
 type Blocklist struct {
@@ -79,7 +89,10 @@ func load(r io.Reader, logger Logger) (*stree.Stree, int, error) {
 		if l[0] == '#' {
 			continue
 		}
-		r, err := parseCIDR(l)
+		r, err := parseLine(l)
+		if err == errAllowedRange {
+			continue
+		}
 		if err != nil {
 			hasError = true
 			if logger != nil {
@@ -106,6 +119,83 @@ type ipRange struct {
 	first, last uint32
 }
 
+// parseLine parses a rule in one of the following formats:
+//   - CIDR: 1.2.3.0/24
+//   - PeerGuardian text (.p2p): Some description:1.2.3.0-1.2.3.255
+//   - eMule (ipfilter.dat): 001.002.003.000 - 001.002.003.255 , 000 , Some description
+//   - Plain range or a single address: 1.2.3.0-1.2.3.255, 1.2.3.4
+//
+// Descriptions may contain any character, so the format is detected by the shape of the line.
+// eMule rules with an access level above 127 are not blocked and errAllowedRange is returned for them.
+func parseLine(b []byte) (r ipRange, err error) {
+	// eMule rules start with the range. Access level and description follow it, separated with commas.
+	if i := bytes.IndexByte(b, ','); i != -1 {
+		if r, err = parseRange(b[:i]); err == nil {
+			err = checkAccessLevel(b[i+1:])
+			return
+		}
+	}
+	// Range follows the description in PeerGuardian format. IPv4 addresses do not contain colons.
+	if i := bytes.LastIndexByte(b, ':'); i != -1 {
+		return parseRange(b[i+1:])
+	}
+	if bytes.IndexByte(b, '/') != -1 {
+		return parseCIDR(b)
+	}
+	return parseRange(b)
+}
+
+// parseRange parses a range of addresses separated with a dash or a single address.
+func parseRange(b []byte) (r ipRange, err error) {
+	first, last := b, b
+	if i := bytes.IndexByte(b, '-'); i != -1 {
+		first, last = b[:i], b[i+1:]
+	}
+	if r.first, err = parseIPv4(bytes.TrimSpace(first)); err != nil {
+		return
+	}
+	if r.last, err = parseIPv4(bytes.TrimSpace(last)); err != nil {
+		return
+	}
+	if r.first > r.last {
+		err = errInvalidRange
+	}
+	return
+}
+
+// checkAccessLevel parses the access level of an eMule rule from the fields following the range.
+func checkAccessLevel(b []byte) error {
+	if i := bytes.IndexByte(b, ','); i != -1 {
+		b = b[:i]
+	}
+	level, err := strconv.Atoi(string(bytes.TrimSpace(b)))
+	if err != nil {
+		return errInvalidAccessLevel
+	}
+	if level > maxBlockedAccessLevel {
+		return errAllowedRange
+	}
+	return nil
+}
+
+// parseIPv4 parses a dotted IPv4 address.
+// Unlike net.ParseIP, leading zeros are allowed as they are common in eMule format.
+func parseIPv4(b []byte) (uint32, error) {
+	parts := bytes.Split(b, []byte{'.'})
+	if len(parts) != 4 {
+		return 0, errNotIPv4Address
+	}
+	var ip uint32
+	for _, p := range parts {
+		n, err := strconv.ParseUint(string(p), 10, 8)
+		if err != nil {
+			return 0, errNotIPv4Address
+		}
+		ip = ip<<8 | uint32(n)
+	}
+	return ip, nil
+}
+
 func parseCIDR(b []byte) (r ipRange, err error) {
 	_, ipnet, err := net.ParseCIDR(string(b))
 	if err != nil {
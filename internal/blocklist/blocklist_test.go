@@ -20,6 +20,51 @@ func TestParseCIDR(t *testing.T) {
 	assert.Equal(t, uint32(511), r.last)
 }
 
+func TestParseLine(t *testing.T) {
+	cases := []struct {
+		line        string
+		first, last uint32
+	}{
+		{"0.0.1.1/24", 256, 511},
+		{"Bad guys:0.0.1.0-0.0.1.255", 256, 511},
+		{"Name with: colon:0.0.1.0-0.0.2.0", 256, 512},
+		{"Name, with comma:0.0.1.0-0.0.1.255", 256, 511},
+		{"Name/with slash:0.0.1.0-0.0.1.255", 256, 511},
+		{"000.000.001.000 - 000.000.001.255 , 000 , Bad guys", 256, 511},
+		{"000.000.001.000 - 000.000.001.255 , 127 , Bad guys: 1.2.3.4", 256, 511},
+		{"000.000.001.000 - 000.000.001.255 , 100 , Bad, guys:", 256, 511},
+		{"0.0.1.0-0.0.1.255", 256, 511},
+		{"0.0.1.1", 257, 257},
+	}
+	for _, c := range cases {
+		r, err := parseLine([]byte(c.line))
+		if err != nil {
+			t.Fatal(c.line, err)
+		}
+		assert.Equal(t, c.first, r.first, c.line)
+		assert.Equal(t, c.last, r.last, c.line)
+	}
+	for _, l := range []string{"0.0.1.255-0.0.1.0", "Bad guys:", "0.0.256.0", "::1-::2", "000.000.001.000 - 000.000.001.255 , x , Bad guys"} {
+		_, err := parseLine([]byte(l))
+		assert.Error(t, err, l)
+	}
+	_, err := parseLine([]byte("000.000.001.000 - 000.000.001.255 , 200 , Good guys: 1.2.3.4"))
+	assert.Equal(t, errAllowedRange, err)
+}
+
+func TestAllowedRange(t *testing.T) {
+	rules := "000.000.001.000 - 000.000.001.255 , 000 , Bad guys\n" +
+		"000.000.002.000 - 000.000.002.255 , 200 , Good guys\n"
+	b := New()
+	n, err := b.Reload(bytes.NewReader([]byte(rules)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, n)
+	assert.True(t, b.Blocked(net.ParseIP("0.0.1.1")))
+	assert.False(t, b.Blocked(net.ParseIP("0.0.2.1")))
+}
+
 func TestContains(t *testing.T) {
 	p := filepath.Join("testdata", "blocklist.cidr")
 	f, err := os.Open(p)
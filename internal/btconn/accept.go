@@ -12,6 +12,7 @@ import (
 
 // Accept BitTorrent handshake from the connection. Handles encryption.
 // Returns a new connection that is ready for sending/receiving BitTorrent protocol messages.
+// Returned errors are of type *StageError.
 func Accept(
 	conn net.Conn,
 	handshakeTimeout time.Duration,
@@ -26,9 +27,24 @@ func Accept(
 		panic("forceEncryption && getSKey == nil")
 	}
 
-	if err = conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+	stage := StageHandshake
+	defer func() {
+		if err != nil {
+			err = &StageError{Stage: stage, Err: err}
+		}
+	}()
+
+	// The deadline spans both the encryption negotiation and the BitTorrent handshake.
+	// Errors after the deadline are reported as timeouts, including the ones wrapped by the encryption layer.
+	deadline := time.Now().Add(handshakeTimeout)
+	if err = conn.SetDeadline(deadline); err != nil {
 		return
 	}
+	defer func() {
+		if err != nil && !time.Now().Before(deadline) {
+			err = &TimeoutError{Op: "handshake", Err: err}
+		}
+	}()
 
 	isEncrypted := false
 
@@ -42,6 +58,7 @@ func Accept(
 
 	peerExtensions, infoHash, err = readHandshake1(reader)
 	if err == errInvalidProtocol && getSKey != nil {
+		stage = StageEncryption
 		conn = &rwConn{readWriter{io.MultiReader(&buf, conn), conn}, conn}
 		mseConn := mse.WrapConn(conn)
 		err = mseConn.HandshakeIncoming(
@@ -61,6 +78,7 @@ func Accept(
 		}
 		log.Debugf("Encryption handshake is successful. Selected cipher: %s", cipher)
 		conn = mseConn
+		stage = StageHandshake
 		peerExtensions, infoHash, err = readHandshake1(conn)
 	}
 	if err != nil {
@@ -68,11 +86,13 @@ func Accept(
 	}
 
 	if forceEncryption && !isEncrypted {
+		stage = StageEncryption
 		err = errNotEncrypted
 		return
 	}
 
 	if !hasInfoHash(infoHash) {
+		stage = StageInfoHash
 		err = errInvalidInfoHash
 		return
 	}
@@ -85,6 +105,7 @@ func Accept(
 		return
 	}
 	if peerID == ourID {
+		stage = StagePeerID
 		err = errOwnConnection
 		return
 	}
@@ -1,6 +1,7 @@
 package btconn
 
 import (
+	"errors"
 	"net"
 	"testing"
 	"time"
@@ -28,7 +29,7 @@ func TestUnencrypted(t *testing.T) {
 	var gerr error
 	go func() {
 		defer close(done)
-		conn, cipher, ext, id, err2 := Dial(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}, 10*time.Second, 10*time.Second, false, false, ext1, infoHash, id1, nil)
+		conn, cipher, ext, id, err2 := Dial(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}, nil, 10*time.Second, 10*time.Second, false, false, ext1, infoHash, id1, nil)
 		if err2 != nil {
 			gerr = err2
 			return
@@ -83,7 +84,7 @@ func TestEncrypted(t *testing.T) {
 	var gerr error
 	go func() {
 		defer close(done)
-		conn, cipher, ext, id, err2 := Dial(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}, 10*time.Second, 10*time.Second, true, true, ext1, infoHash, id1, nil)
+		conn, cipher, ext, id, err2 := Dial(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}, nil, 10*time.Second, 10*time.Second, true, true, ext1, infoHash, id1, nil)
 		if err2 != nil {
 			gerr = err2
 			return
@@ -169,3 +170,57 @@ func TestEncrypted(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestHandshakeTimeout(t *testing.T) {
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(0, 0, 0, 0), Port: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err2 := l.Accept()
+		if err2 != nil {
+			return
+		}
+		defer conn.Close()
+		time.Sleep(time.Second)
+	}()
+	port := l.Addr().(*net.TCPAddr).Port
+	_, _, _, _, err = Dial(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}, nil, 10*time.Second, 100*time.Millisecond, false, false, ext1, infoHash, id1, nil)
+	var terr *TimeoutError
+	if !errors.As(err, &terr) {
+		t.Fatalf("expected timeout error, got: %v", err)
+	}
+	var serr *StageError
+	if !errors.As(err, &serr) || serr.Stage != StageHandshake {
+		t.Fatalf("expected handshake stage error, got: %v", err)
+	}
+}
+
+func TestInvalidInfoHash(t *testing.T) {
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(0, 0, 0, 0), Port: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, _, _, _, err2 := Dial(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}, nil, 10*time.Second, 10*time.Second, false, false, ext1, infoHash, id1, nil)
+		if err2 == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, _, _, err = Accept(conn, 10*time.Second, nil, false, func(ih [20]byte) bool { return false }, ext2, id2)
+	var serr *StageError
+	if !errors.As(err, &serr) || serr.Stage != StageInfoHash {
+		t.Fatalf("expected info hash stage error, got: %v", err)
+	}
+	conn.Close()
+	<-done
+}
@@ -10,11 +10,18 @@ import (
 	"github.com/cenkalti/rain/internal/mse"
 )
 
+// Dialer makes the underlying connection to the peer. net.Dialer is used if nil Dialer is passed to Dial.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
 // Dial new connection to the address. Does the BitTorrent protocol handshake.
 // Handles encryption. May try to connect again if encryption does not match with given setting.
 // Returns a net.Conn that is ready for sending/receiving BitTorrent peer protocol messages.
+// Returned errors are of type *StageError.
 func Dial(
 	addr net.Addr,
+	dialer Dialer,
 	dialTimeout, handshakeTimeout time.Duration,
 	enableEncryption,
 	forceEncryption bool,
@@ -36,10 +43,28 @@ func Dial(
 		}
 	}()
 
+	stage := StageDial
+	defer func() {
+		if err != nil {
+			err = &StageError{Stage: stage, Err: err}
+		}
+	}()
+
 	// First connection
 	log.Debug("Connecting to peer...")
-	dialer := net.Dialer{Timeout: dialTimeout}
-	conn, err = dialer.DialContext(ctx, addr.Network(), addr.String())
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	dial := func() (net.Conn, error) {
+		dctx, cancel := context.WithTimeout(ctx, dialTimeout)
+		defer cancel()
+		c, err := dialer.DialContext(dctx, dialNetwork(addr), addr.String())
+		if err != nil && isTimeout(err) {
+			err = &TimeoutError{Op: "dial", Err: err}
+		}
+		return c, err
+	}
+	conn, err = dial()
 	if err != nil {
 		return
 	}
@@ -57,6 +82,20 @@ func Dial(
 		}
 	}(conn)
 
+	// Errors after the handshake deadline are reported as timeouts,
+	// including the ones wrapped by the encryption layer.
+	var deadline time.Time
+	defer func() {
+		if _, ok := err.(*TimeoutError); ok {
+			return
+		}
+		if err != nil && !deadline.IsZero() && !time.Now().Before(deadline) {
+			err = &TimeoutError{Op: "handshake", Err: err}
+		}
+	}()
+
+	stage = StageHandshake
+
 	// Write first part of BitTorrent handshake to a buffer because we will use it in both encrypted and unencrypted handshake.
 	out := bytes.NewBuffer(make([]byte, 0, 68))
 	err = writeHandshake(out, ih, ourID, ourExtensions)
@@ -65,7 +104,9 @@ func Dial(
 	}
 
 	// Handshake must be completed in allowed duration.
-	if err = conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+	// The deadline spans both the encryption negotiation and the BitTorrent handshake.
+	deadline = time.Now().Add(handshakeTimeout)
+	if err = conn.SetDeadline(deadline); err != nil {
 		return
 	}
 
@@ -79,6 +120,7 @@ func Dial(
 		}
 
 		// Try encryption handshake
+		stage = StageEncryption
 		encConn := mse.WrapConn(conn)
 		cipher, err = encConn.HandshakeOutgoing(sKey, provide, out.Bytes())
 		if err != nil {
@@ -97,7 +139,8 @@ func Dial(
 			// Close current connection and try again without encryption
 			conn.Close()
 			log.Debug("Connecting again without encryption...")
-			conn, err = dialer.DialContext(ctx, addr.Network(), addr.String())
+			stage = StageDial
+			conn, err = dial()
 			if err != nil {
 				return
 			}
@@ -116,7 +159,9 @@ func Dial(
 			}(conn)
 
 			// Send BT handshake
-			if err = conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+			stage = StageHandshake
+			deadline = time.Now().Add(handshakeTimeout)
+			if err = conn.SetDeadline(deadline); err != nil {
 				return
 			}
 			if _, err = conn.Write(out.Bytes()); err != nil {
@@ -124,6 +169,7 @@ func Dial(
 			}
 		} else {
 			log.Debugf("Encryption handshake is successful. Selected cipher: %s", cipher)
+			stage = StageHandshake
 			conn = encConn
 		}
 	} else {
@@ -141,6 +187,7 @@ func Dial(
 		return
 	}
 	if ihRead != ih {
+		stage = StageInfoHash
 		err = errInvalidInfoHash
 		return
 	}
@@ -150,8 +197,21 @@ func Dial(
 		return
 	}
 	if peerID == ourID {
+		stage = StagePeerID
 		err = errOwnConnection
 		return
 	}
 	return
 }
+
+// dialNetwork returns the network that matches the address family of the peer address.
+func dialNetwork(addr net.Addr) string {
+	a, ok := addr.(*net.TCPAddr)
+	if !ok || a.IP == nil {
+		return addr.Network()
+	}
+	if a.IP.To4() != nil {
+		return "tcp4"
+	}
+	return "tcp6"
+}
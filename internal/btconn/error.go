@@ -1,5 +1,7 @@
 package btconn
 
+import "net"
+
 var (
 	errInvalidInfoHash = &HandshakeError{"invalid info hash"}
 	errOwnConnection   = &HandshakeError{"dropped own connection"}
@@ -7,6 +9,54 @@ var (
 	errInvalidProtocol = &HandshakeError{"invalid protocol"}
 )
 
+// Stage is a step of establishing a connection with a peer.
+type Stage int
+
+const (
+	// StageDial is opening the TCP connection.
+	StageDial Stage = iota
+	// StageEncryption is the MSE key exchange.
+	StageEncryption
+	// StageHandshake is the BitTorrent protocol handshake.
+	StageHandshake
+	// StageInfoHash is checking the info hash sent in the handshake.
+	StageInfoHash
+	// StagePeerID is checking the peer ID sent in the handshake.
+	StagePeerID
+)
+
+func (s Stage) String() string {
+	switch s {
+	case StageDial:
+		return "dial"
+	case StageEncryption:
+		return "encryption"
+	case StageHandshake:
+		return "handshake"
+	case StageInfoHash:
+		return "info hash"
+	case StagePeerID:
+		return "peer id"
+	default:
+		return "unknown"
+	}
+}
+
+// StageError is returned from Dial and Accept with the stage of the connection that has failed.
+type StageError struct {
+	Stage Stage
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return e.Stage.String() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
 // HandshakeError is an error while doing the protocol handshake.
 type HandshakeError struct {
 	message string
@@ -15,3 +65,36 @@ type HandshakeError struct {
 func (e *HandshakeError) Error() string {
 	return e.message
 }
+
+// TimeoutError is returned when the connection or the handshake is not completed in the allowed duration.
+type TimeoutError struct {
+	// Op is "dial" or "handshake".
+	Op  string
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return e.Op + " timeout: " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout is always true. Implements net.Error interface.
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// Temporary is always false. Implements net.Error interface.
+func (e *TimeoutError) Temporary() bool {
+	return false
+}
+
+var _ net.Error = (*TimeoutError)(nil)
+
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/cenkalti/rain/internal/jsonutil"
+	"github.com/cenkalti/rain/internal/magnet"
 	"github.com/cenkalti/rain/internal/rpctypes"
 	"github.com/cenkalti/rain/rainrpc"
 	"github.com/jroimartin/gocui"
@@ -406,8 +407,11 @@ func getRow(columns []string, t Torrent, index int) string {
 				row += fmt.Sprintf("%-11s", "")
 			} else {
 				status := stats.Status
-				if status == "Downloading Metadata" {
+				switch status {
+				case "Downloading Metadata":
 					status = "Downloading"
+				case "Seeding Complete":
+					status = "Complete"
 				}
 				row += fmt.Sprintf("%-11s", status)
 			}
@@ -799,7 +803,7 @@ func (c *Console) forceQuit(g *gocui.Gui, v *gocui.View) error {
 }
 
 func isURI(arg string) bool {
-	return strings.HasPrefix(arg, "magnet:") || strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://")
+	return strings.HasPrefix(arg, "magnet:") || strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") || magnet.IsInfoHash(arg)
 }
 
 func (c *Console) addTorrentHandleEnter(g *gocui.Gui, v *gocui.View) error {
@@ -1135,6 +1139,8 @@ func flags(p rpctypes.Peer) string {
 		sb.WriteString("H")
 	case "PEX":
 		sb.WriteString("X")
+	case "LSD":
+		sb.WriteString("L")
 	case "INCOMING":
 		sb.WriteString("I")
 	case "MANUAL":
@@ -1226,7 +1232,7 @@ func FormatStats(stats *rpctypes.Stats, v io.Writer) {
 // FormatSessionStats returns the human readable representation of session stats object.
 func FormatSessionStats(s *rpctypes.SessionStats, v io.Writer) {
 	fmt.Fprintf(v, "Torrents: %d, Peers: %d, Uptime: %s\n", s.Torrents, s.Peers, time.Duration(s.Uptime)*time.Second)
-	fmt.Fprintf(v, "BlocklistRules: %d, Updated: %s ago\n", s.BlockListRules, time.Duration(s.BlockListRecency)*time.Second)
+	fmt.Fprintf(v, "BlocklistRules: %d, Updated: %s ago, Rejected: %d\n", s.BlockListRules, time.Duration(s.BlockListRecency)*time.Second, s.BlockListRejected)
 	fmt.Fprintf(v, "Reads: %d/s, %dKB/s, Active: %d, Pending: %d\n", s.ReadsPerSecond, s.SpeedRead/1024, s.ReadsActive, s.ReadsPending)
 	fmt.Fprintf(v, "Writes: %d/s, %dKB/s, Active: %d, Pending: %d\n", s.WritesPerSecond, s.SpeedWrite/1024, s.WritesActive, s.WritesPending)
 	fmt.Fprintf(v, "ReadCache Objects: %d, Size: %dMB, Utilization: %d%%\n", s.ReadCacheObjects, s.ReadCacheSize/(1<<20), s.ReadCacheUtilization)
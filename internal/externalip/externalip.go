@@ -2,18 +2,29 @@ package externalip
 
 import (
 	"net"
+	"sync"
 
 	"github.com/cenkalti/log"
 )
 
-var ips []net.IP
+var (
+	mIPs sync.RWMutex
+	ips  []net.IP
+	ips6 []net.IP
+)
 
 func init() {
+	Refresh()
+}
+
+// Refresh reads the addresses of the network interfaces on the server again.
+func Refresh() {
 	addrs, err := net.InterfaceAddrs()
 	if err != nil {
 		log.Warningln("cannot get interface addresses:", err)
 		return
 	}
+	var found, found6 []net.IP
 	for _, addr := range addrs {
 		in, ok := addr.(*net.IPNet)
 		if !ok {
@@ -21,17 +32,24 @@ func init() {
 		}
 		i4 := in.IP.To4()
 		if i4 == nil {
+			if isPublicIPv6(in.IP) {
+				found6 = append(found6, in.IP)
+			}
 			continue
 		}
 		if !isPublicIP(i4) {
 			continue
 		}
-		ips = append(ips, i4)
+		found = append(found, i4)
 	}
+	mIPs.Lock()
+	ips = found
+	ips6 = found6
+	mIPs.Unlock()
 }
 
 func isPublicIP(ip4 net.IP) bool {
-	if ip4.IsLoopback() || ip4.IsLinkLocalMulticast() || ip4.IsLinkLocalUnicast() {
+	if ip4.IsUnspecified() || ip4.IsLoopback() || ip4.IsLinkLocalMulticast() || ip4.IsLinkLocalUnicast() {
 		return false
 	}
 	switch {
@@ -41,13 +59,29 @@ func isPublicIP(ip4 net.IP) bool {
 		return false
 	case ip4[0] == 192 && ip4[1] == 168:
 		return false
+	case ip4[0] == 100 && ip4[1]&0xc0 == 64:
+		// Shared address space for carrier-grade NAT (100.64.0.0/10).
+		return false
 	default:
 		return true
 	}
 }
 
+// IsPublic returns true if ip is an IPv4 address that is reachable from the internet.
+func IsPublic(ip net.IP) bool {
+	ip4 := ip.To4()
+	return ip4 != nil && isPublicIP(ip4)
+}
+
+// isPublicIPv6 returns false for unique local addresses (fc00::/7) in addition to non-global addresses.
+func isPublicIPv6(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && ip[0]&0xfe != 0xfc
+}
+
 // IsExternal returns true if the given IP matches one of the IP address of the external network interfaces on the server.
 func IsExternal(ip net.IP) bool {
+	mIPs.RLock()
+	defer mIPs.RUnlock()
 	for i := range ips {
 		if ip.Equal(ips[i]) {
 			return true
@@ -58,8 +92,20 @@ func IsExternal(ip net.IP) bool {
 
 // FirstExternalIP returns the first external IP of the network interfaces on the server.
 func FirstExternalIP() net.IP {
+	mIPs.RLock()
+	defer mIPs.RUnlock()
 	if len(ips) == 0 {
 		return nil
 	}
 	return ips[0]
 }
+
+// FirstExternalIPv6 returns the first global IPv6 address of the network interfaces on the server.
+func FirstExternalIPv6() net.IP {
+	mIPs.RLock()
+	defer mIPs.RUnlock()
+	if len(ips6) == 0 {
+		return nil
+	}
+	return ips6[0]
+}
@@ -35,6 +35,9 @@ func (p Piece) ReadAt(b []byte, off int64) (int, error) {
 			break
 		}
 	}
+	if i == len(p) {
+		return 0, io.EOF
+	}
 
 	// Add half section
 	advance := p[i].Length - (pos - off)
@@ -69,3 +72,37 @@ func (p Piece) Write(b []byte) (n int, err error) {
 	}
 	return
 }
+
+// WriteAt implements io.WriterAt interface.
+// It writes the bytes in b into files in s starting from given offset in the piece.
+// Used when saving downloaded blocks of an incomplete piece.
+func (p Piece) WriteAt(b []byte, off int64) (n int, err error) {
+	var pos int64
+	for _, sec := range p {
+		if len(b) == 0 {
+			return
+		}
+		if off >= pos+sec.Length {
+			pos += sec.Length
+			continue
+		}
+		advance := off - pos
+		l := sec.Length - advance
+		if l > int64(len(b)) {
+			l = int64(len(b))
+		}
+		var m int
+		m, err = sec.File.WriteAt(b[:l], sec.Offset+advance)
+		n += m
+		if err != nil {
+			return
+		}
+		b = b[m:]
+		off += int64(m)
+		pos += sec.Length
+	}
+	if len(b) > 0 {
+		err = io.ErrShortWrite
+	}
+	return
+}
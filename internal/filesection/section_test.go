@@ -72,6 +72,24 @@ func TestFiles(t *testing.T) {
 	if content(osFiles[3]) != "45erty" {
 		t.Fail()
 	}
+
+	// test write at offset
+	n, err = pf.WriteAt([]byte("xyz"), 1)
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 3 {
+		t.Errorf("n == %d", n)
+	}
+	if content(osFiles[0]) != "as1x" {
+		t.Fail()
+	}
+	if content(osFiles[1]) != "y" {
+		t.Fail()
+	}
+	if content(osFiles[3]) != "z5erty" {
+		t.Fail()
+	}
 }
 
 func content(f *os.File) string {
@@ -1,6 +1,7 @@
 package incominghandshaker
 
 import (
+	"errors"
 	"io"
 	"net"
 	"time"
@@ -14,6 +15,7 @@ import (
 type IncomingHandshaker struct {
 	Conn       net.Conn
 	PeerID     [20]byte
+	InfoHash   [20]byte
 	Extensions [8]byte
 	Cipher     mse.CryptoMethod
 	Error      error
@@ -50,27 +52,36 @@ func (h *IncomingHandshaker) Run(peerID [20]byte, getSKeyFunc func([20]byte) []b
 
 	log := logger.New("conn <- " + h.Conn.RemoteAddr().String())
 
-	conn, cipher, peerExtensions, peerID, _, err := btconn.Accept(
+	conn, cipher, peerExtensions, peerID, infoHash, err := btconn.Accept(
 		h.Conn, timeout, getSKeyFunc, forceIncomingEncryption, checkInfoHashFunc, ourExtensions, peerID)
 	if err != nil {
-		if err == io.EOF {
+		var (
+			terr *btconn.TimeoutError
+			nerr *net.OpError
+			herr *btconn.HandshakeError
+		)
+		if errors.Is(err, io.EOF) {
 			log.Debug("peer has closed the connection: EOF")
-		} else if err == io.ErrUnexpectedEOF {
+		} else if errors.Is(err, io.ErrUnexpectedEOF) {
 			log.Debug("peer has closed the connection: Unexpected EOF")
-		} else if _, ok := err.(*net.OpError); ok {
+		} else if errors.As(err, &terr) {
+			log.Debugln(err)
+		} else if errors.As(err, &nerr) {
 			log.Debugln("net operation error:", err)
-		} else if _, ok := err.(*btconn.HandshakeError); ok {
+		} else if errors.As(err, &herr) {
 			log.Debugln("protocol error:", err)
 		} else {
 			log.Debugln("cannot complete incoming handshake:", err)
 		}
 		h.Error = err
+		h.Cipher = cipher
 		return
 	}
 	log.Debugf("Connection accepted. (cipher=%s extensions=%x client=%q)", cipher, peerExtensions, peerID[:8])
 
 	h.Conn = conn
 	h.PeerID = peerID
+	h.InfoHash = infoHash
 	h.Extensions = peerExtensions
 	h.Cipher = cipher
 }
@@ -1,6 +1,7 @@
 package outgoinghandshaker
 
 import (
+	"errors"
 	"io"
 	"net"
 	"time"
@@ -42,24 +43,32 @@ func (h *OutgoingHandshaker) Close() {
 }
 
 // Run the handshaker.
-func (h *OutgoingHandshaker) Run(dialTimeout, handshakeTimeout time.Duration, peerID, infoHash [20]byte, resultC chan *OutgoingHandshaker, ourExtensions [8]byte, disableOutgoingEncryption, forceOutgoingEncryption bool) {
+func (h *OutgoingHandshaker) Run(dialer btconn.Dialer, dialTimeout, handshakeTimeout time.Duration, peerID, infoHash [20]byte, resultC chan *OutgoingHandshaker, ourExtensions [8]byte, disableOutgoingEncryption, forceOutgoingEncryption bool) {
 	defer close(h.doneC)
 	log := logger.New("peer -> " + h.Addr.String())
 
-	conn, cipher, peerExtensions, peerID, err := btconn.Dial(h.Addr, dialTimeout, handshakeTimeout, !disableOutgoingEncryption, forceOutgoingEncryption, ourExtensions, infoHash, peerID, h.closeC)
+	conn, cipher, peerExtensions, peerID, err := btconn.Dial(h.Addr, dialer, dialTimeout, handshakeTimeout, !disableOutgoingEncryption, forceOutgoingEncryption, ourExtensions, infoHash, peerID, h.closeC)
 	if err != nil {
-		if err == io.EOF {
+		var (
+			terr *btconn.TimeoutError
+			nerr *net.OpError
+			herr *btconn.HandshakeError
+		)
+		if errors.Is(err, io.EOF) {
 			log.Debug("peer has closed the connection: EOF")
-		} else if err == io.ErrUnexpectedEOF {
+		} else if errors.Is(err, io.ErrUnexpectedEOF) {
 			log.Debug("peer has closed the connection: Unexpected EOF")
-		} else if _, ok := err.(*net.OpError); ok {
+		} else if errors.As(err, &terr) {
+			log.Debugln(err)
+		} else if errors.As(err, &nerr) {
 			log.Debugln("net operation error:", err)
-		} else if _, ok := err.(*btconn.HandshakeError); ok {
+		} else if errors.As(err, &herr) {
 			log.Debugln("protocol error:", err)
 		} else {
 			log.Errorln("cannot complete outgoing handshake:", err)
 		}
 		h.Error = err
+		h.Cipher = cipher
 		select {
 		case resultC <- h:
 		case <-h.closeC:
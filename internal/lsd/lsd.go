@@ -0,0 +1,193 @@
+// Package lsd implements Local Service Discovery (BEP 14) for finding peers on the local network.
+package lsd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cenkalti/rain/internal/logger"
+)
+
+// MulticastAddr is the IPv4 multicast group and port that announcements are sent to.
+const MulticastAddr = "239.192.152.143:6771"
+
+// Announcements for many torrents are split into multiple messages to keep them in a single UDP packet.
+const maxInfoHashesPerMessage = 20
+
+var errInvalidMessage = errors.New("invalid LSD message")
+
+// Peer is a client on the local network that has announced a torrent.
+type Peer struct {
+	InfoHash [20]byte
+	Addr     *net.TCPAddr
+}
+
+// LSD sends announcements to the multicast group and receives announcements of other clients.
+// A single LSD is shared by all torrents in a session.
+type LSD struct {
+	conn     *net.UDPConn
+	sendConn *net.UDPConn
+	group    *net.UDPAddr
+	cookie   string
+	log      logger.Logger
+
+	// PeersC receives the peers found on the local network.
+	PeersC chan Peer
+
+	closeC chan struct{}
+	doneC  chan struct{}
+}
+
+// New joins the multicast group and returns a new LSD.
+func New(l logger.Logger) (*LSD, error) {
+	group, err := net.ResolveUDPAddr("udp4", MulticastAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return nil, err
+	}
+	// Multicast loopback is disabled on the listening socket.
+	// Messages are sent from another socket so that other clients on the same host receive them.
+	sendConn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// Random cookie is sent with announcements to ignore our own messages looped back by the multicast group.
+	b := make([]byte, 8)
+	_, err = rand.Read(b)
+	if err != nil {
+		conn.Close()
+		sendConn.Close()
+		return nil, err
+	}
+	return &LSD{
+		conn:     conn,
+		sendConn: sendConn,
+		group:    group,
+		cookie:   hex.EncodeToString(b),
+		log:      l,
+		PeersC:   make(chan Peer, 100),
+		closeC:   make(chan struct{}),
+		doneC:    make(chan struct{}),
+	}, nil
+}
+
+// Close leaves the multicast group and stops the receiving goroutine.
+func (d *LSD) Close() {
+	close(d.closeC)
+	d.conn.Close()
+	d.sendConn.Close()
+	<-d.doneC
+}
+
+// Announce the torrents listening on port to the local network.
+func (d *LSD) Announce(port int, infoHashes [][20]byte) error {
+	for len(infoHashes) > 0 {
+		n := len(infoHashes)
+		if n > maxInfoHashesPerMessage {
+			n = maxInfoHashesPerMessage
+		}
+		_, err := d.sendConn.WriteToUDP(newMessage(port, infoHashes[:n], d.cookie), d.group)
+		if err != nil {
+			return err
+		}
+		infoHashes = infoHashes[n:]
+	}
+	return nil
+}
+
+// Run receives announcements from other clients and sends the peers to PeersC.
+// Peers are dropped if PeersC is full. Invoke with go statement.
+func (d *LSD) Run() {
+	defer close(d.doneC)
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := d.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-d.closeC:
+			default:
+				d.log.Errorln("cannot read LSD message:", err)
+			}
+			return
+		}
+		port, infoHashes, cookie, err := parseMessage(buf[:n])
+		if err != nil {
+			d.log.Debugf("cannot parse LSD message from %s: %s", addr, err)
+			continue
+		}
+		if cookie == d.cookie {
+			continue
+		}
+		for _, ih := range infoHashes {
+			p := Peer{
+				InfoHash: ih,
+				Addr:     &net.TCPAddr{IP: addr.IP, Port: port},
+			}
+			select {
+			case d.PeersC <- p:
+			default:
+			}
+		}
+	}
+}
+
+func newMessage(port int, infoHashes [][20]byte, cookie string) []byte {
+	var sb strings.Builder
+	sb.WriteString("BT-SEARCH * HTTP/1.1\r\n")
+	sb.WriteString("Host: " + MulticastAddr + "\r\n")
+	sb.WriteString("Port: " + strconv.Itoa(port) + "\r\n")
+	for _, ih := range infoHashes {
+		sb.WriteString("Infohash: " + hex.EncodeToString(ih[:]) + "\r\n")
+	}
+	sb.WriteString("cookie: " + cookie + "\r\n")
+	sb.WriteString("\r\n\r\n")
+	return []byte(sb.String())
+}
+
+func parseMessage(b []byte) (port int, infoHashes [][20]byte, cookie string, err error) {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(b)))
+	if err != nil {
+		return
+	}
+	if req.Method != "BT-SEARCH" {
+		err = errInvalidMessage
+		return
+	}
+	port, err = strconv.Atoi(req.Header.Get("Port"))
+	if err != nil {
+		return
+	}
+	if port <= 0 || port > 65535 {
+		err = errInvalidMessage
+		return
+	}
+	for _, s := range req.Header.Values("Infohash") {
+		var ih [20]byte
+		if len(s) != hex.EncodedLen(len(ih)) {
+			err = errInvalidMessage
+			return
+		}
+		_, err = hex.Decode(ih[:], []byte(s))
+		if err != nil {
+			return
+		}
+		infoHashes = append(infoHashes, ih)
+	}
+	if len(infoHashes) == 0 {
+		err = errInvalidMessage
+		return
+	}
+	cookie = req.Header.Get("Cookie")
+	return
+}
@@ -0,0 +1,53 @@
+package lsd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage(t *testing.T) {
+	ih1 := [20]byte{1}
+	ih2 := [20]byte{2}
+	port, infoHashes, cookie, err := parseMessage(newMessage(6881, [][20]byte{ih1, ih2}, "abc"))
+	assert.NoError(t, err)
+	assert.Equal(t, 6881, port)
+	assert.Equal(t, [][20]byte{ih1, ih2}, infoHashes)
+	assert.Equal(t, "abc", cookie)
+
+	_, _, _, err = parseMessage([]byte("BT-SEARCH * HTTP/1.1\r\nHost: " + MulticastAddr + "\r\nPort: 6881\r\n\r\n\r\n"))
+	assert.Error(t, err)
+}
+
+func TestAnnounce(t *testing.T) {
+	d1, err := New(logger.New("lsd1"))
+	if err != nil {
+		t.Skip("cannot join multicast group:", err)
+	}
+	defer d1.Close()
+	d2, err := New(logger.New("lsd2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d2.Close()
+	go d1.Run()
+	go d2.Run()
+
+	ih := [20]byte{1, 2, 3}
+	assert.NoError(t, d1.Announce(6881, [][20]byte{ih}))
+	select {
+	case p := <-d2.PeersC:
+		assert.Equal(t, ih, p.InfoHash)
+		assert.Equal(t, 6881, p.Addr.Port)
+	case <-time.After(5 * time.Second):
+		t.Skip("announcement is not received, multicast may not be supported on this network")
+	}
+	// Own announcements are ignored.
+	select {
+	case <-d1.PeersC:
+		t.Fatal("received own announcement")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -2,6 +2,8 @@
 package magnet
 
 import (
+	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/base32"
 	"encoding/hex"
 	"errors"
@@ -9,16 +11,37 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/multiformats/go-multihash"
 )
 
+// Display names longer than this are truncated.
+const maxNameLength = 255
+
+var errV2Only = errors.New("v2-only magnet links are not supported: magnet link must contain a v1 info hash (urn:btih)")
+
 // Magnet link contains the information to download torrent metadata from network.
 type Magnet struct {
 	InfoHash [20]byte
-	Name     string
+	// SHA-256 info hash of a hybrid torrent (BEP 52). Nil if the link does not contain one.
+	InfoHashV2 *[32]byte
+	Name       string
+	// Tracker tiers. A tracker is listed only once, in its first tier.
 	Trackers [][]string
-	Peers    []string
+	// Peer addresses given with "x.pe" params.
+	Peers []string
+	// Webseed URLs given with "ws" params (BEP 19).
+	Webseeds []string
+}
+
+// IsInfoHash returns true if s is a hex encoded v1 info hash.
+func IsInfoHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
 }
 
 // New parses the string and returns new Magnet.
@@ -41,17 +64,31 @@ func New(s string) (*Magnet, error) {
 	if len(xts) == 0 {
 		return nil, errors.New("empty xt param")
 	}
-	xt := xts[0]
 
+	// Magnet links of hybrid torrents contain both v1 and v2 info hashes.
 	var magnet Magnet
-	magnet.InfoHash, err = infoHashString(xt)
-	if err != nil {
-		return nil, err
+	var hasV1 bool
+	for _, xt := range xts {
+		b, err := infoHashString(xt)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) == sha256.Size {
+			var h [32]byte
+			copy(h[:], b)
+			magnet.InfoHashV2 = &h
+			continue
+		}
+		copy(magnet.InfoHash[:], b)
+		hasV1 = true
+	}
+	if !hasV1 {
+		return nil, errV2Only
 	}
 
 	names := params["dn"]
 	if len(names) != 0 {
-		magnet.Name = names[0]
+		magnet.Name = cleanName(names[0])
 	}
 
 	var tiers []trackerTier
@@ -70,13 +107,36 @@ func New(s string) (*Magnet, error) {
 
 	sort.Slice(tiers, func(i, j int) bool { return tiers[i].index < tiers[j].index })
 
-	magnet.Trackers = make([][]string, len(tiers))
-	for i, ti := range tiers {
-		magnet.Trackers[i] = ti.trackers
+	seen := make(map[string]struct{})
+	magnet.Trackers = make([][]string, 0, len(tiers))
+	for _, ti := range tiers {
+		var trackers []string
+		for _, tr := range ti.trackers {
+			tr = strings.TrimSpace(tr)
+			if tr == "" {
+				continue
+			}
+			if _, ok := seen[tr]; ok {
+				continue
+			}
+			seen[tr] = struct{}{}
+			trackers = append(trackers, tr)
+		}
+		if len(trackers) > 0 {
+			magnet.Trackers = append(magnet.Trackers, trackers)
+		}
 	}
 
 	magnet.Peers = params["x.pe"]
 
+	for _, ws := range params["ws"] {
+		wu, err := url.Parse(ws)
+		if err != nil || (wu.Scheme != "http" && wu.Scheme != "https") {
+			continue
+		}
+		magnet.Webseeds = append(magnet.Webseeds, ws)
+	}
+
 	return &magnet, nil
 }
 
@@ -85,6 +145,10 @@ func (m *Magnet) String() string {
 	b.Grow(2048)
 	b.WriteString("magnet:?xt=urn:btih:")
 	b.WriteString(hex.EncodeToString(m.InfoHash[:]))
+	if m.InfoHashV2 != nil {
+		b.WriteString("&xt=urn:btmh:1220")
+		b.WriteString(hex.EncodeToString(m.InfoHashV2[:]))
+	}
 	if m.Name != "" {
 		b.WriteString("&dn=")
 		b.WriteString(url.QueryEscape(m.Name))
@@ -106,46 +170,73 @@ func (m *Magnet) String() string {
 		b.WriteString("&x.pe=")
 		b.WriteString(p)
 	}
+	for _, ws := range m.Webseeds {
+		b.WriteString("&ws=")
+		b.WriteString(url.QueryEscape(ws))
+	}
 	return b.String()
 }
 
+// cleanName converts the display name into a string that is safe to use as a path component.
+func cleanName(s string) string {
+	s = strings.ToValidUTF8(s, "")
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\':
+			return '_'
+		case !unicode.IsPrint(r):
+			return -1
+		}
+		return r
+	}, s)
+	s = strings.TrimSpace(s)
+	if s == "." || s == ".." {
+		return ""
+	}
+	if len(s) > maxNameLength {
+		s = strings.ToValidUTF8(s[:maxNameLength], "")
+	}
+	return s
+}
+
 type trackerTier struct {
 	trackers []string
 	index    int
 }
 
-// infoHashString returns a new info hash value from a string.
-// s must be 40 (hex encoded) or 32 (base32 encoded) characters, otherwise it returns error.
-func infoHashString(xt string) ([20]byte, error) {
-	var ih [20]byte
-	var b []byte
-	var err error
+// infoHashString returns the info hash in xt param.
+// Returned value is 20 bytes for v1 info hashes and 32 bytes for v2 info hashes.
+// v1 info hash must be 40 (hex encoded) or 32 (base32 encoded) characters.
+// v2 info hash must be a hex encoded SHA-256 multihash.
+func infoHashString(xt string) ([]byte, error) {
 	switch {
 	case strings.HasPrefix(xt, "urn:btih:"):
 		xt = xt[9:]
 		switch len(xt) {
 		case 40:
-			b, err = hex.DecodeString(xt)
+			return hex.DecodeString(xt)
 		case 32:
-			b, err = base32.StdEncoding.DecodeString(xt)
+			return base32.StdEncoding.DecodeString(xt)
 		default:
-			return ih, errors.New("info hash must be 32 or 40 characters")
+			return nil, errors.New("info hash must be 32 or 40 characters")
 		}
+	case strings.HasPrefix(xt, "urn:btmh:"):
+		mh, err := multihash.FromHexString(xt[9:])
 		if err != nil {
-			return ih, err
+			return nil, err
 		}
-	case strings.HasPrefix(xt, "urn:btmh:"):
-		xt = xt[9:]
-		b, err = multihash.FromHexString(xt)
+		dmh, err := multihash.Decode(mh)
 		if err != nil {
-			return ih, err
+			return nil, err
 		}
-		if len(b) != 20 {
-			return ih, errors.New("invalid multihash (len != 20)")
+		switch {
+		case dmh.Code == multihash.SHA1 && len(dmh.Digest) == sha1.Size:
+		case dmh.Code == multihash.SHA2_256 && len(dmh.Digest) == sha256.Size:
+		default:
+			return nil, errors.New("invalid multihash: must be sha1 or sha2-256")
 		}
+		return dmh.Digest, nil
 	default:
-		return ih, errors.New("invalid xt param: must start with \"urn:btih:\" or \"urn:btmh\"")
+		return nil, errors.New("invalid xt param: must start with \"urn:btih:\" or \"urn:btmh:\"")
 	}
-	copy(ih[:], b)
-	return ih, nil
 }
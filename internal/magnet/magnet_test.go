@@ -31,3 +31,106 @@ func TestParse(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestParseDisplayName(t *testing.T) {
+	cases := map[string]string{
+		"sample%20torrent":      "sample torrent",
+		"..%2F..%2Fetc":         ".._.._etc",
+		"..":                    "",
+		"%20name%09with%0Atab ": "namewithtab",
+	}
+	for dn, expected := range cases {
+		m, err := New("magnet:?xt=urn:btih:F60CC95E3566AF84C1AB223FD4CE80FA88E6438A&dn=" + dn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Name != expected {
+			t.Errorf("invalid name for %q: %q", dn, m.Name)
+		}
+	}
+}
+
+func TestParseHybrid(t *testing.T) {
+	v1 := "f60cc95e3566af84c1ab223fd4ce80fa88e6438a"
+	v2 := "caf1e1c30e81cb361b9ee167c4aa64228a7fa4fa9f6105232b28ad099f3a302e"
+	u := "magnet:?xt=urn:btih:" + v1 + "&xt=urn:btmh:1220" + v2
+	m, err := New(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(m.InfoHash[:]) != v1 {
+		t.Fatal("invalid info hash")
+	}
+	if m.InfoHashV2 == nil || hex.EncodeToString(m.InfoHashV2[:]) != v2 {
+		t.Fatal("invalid v2 info hash")
+	}
+	if m.String() != u {
+		t.Fatal("invalid string:", m.String())
+	}
+	_, err = New("magnet:?xt=urn:btmh:1220" + v2)
+	if err != errV2Only {
+		t.Fatal("expected v2-only error, got:", err)
+	}
+}
+
+func TestIsInfoHash(t *testing.T) {
+	cases := map[string]bool{
+		"f60cc95e3566af84c1ab223fd4ce80fa88e6438a":                     true,
+		"F60CC95E3566AF84C1AB223FD4CE80FA88E6438A":                     true,
+		"f60cc95e3566af84c1ab223fd4ce80fa88e6438":                      false,
+		"z60cc95e3566af84c1ab223fd4ce80fa88e6438a":                     false,
+		"magnet:?xt=urn:btih:f60cc95e3566af84c1ab223fd4ce80fa88e6438a": false,
+	}
+	for s, expected := range cases {
+		if IsInfoHash(s) != expected {
+			t.Errorf("IsInfoHash(%q) must return %v", s, expected)
+		}
+	}
+}
+
+func TestParseMultipleTrackers(t *testing.T) {
+	u := "magnet:?xt=urn:btih:f60cc95e3566af84c1ab223fd4ce80fa88e6438a" +
+		"&dn=Sample+Torrent" +
+		"&tr=udp%3A%2F%2Ftracker.example.org%3A1337%2Fannounce" +
+		"&tr=https%3A%2F%2Ftracker.example.com%2Fannounce%3Fpasskey%3Dabc%26uid%3D1" +
+		"&tr=udp%3A%2F%2Ftracker.example.org%3A1337%2Fannounce" +
+		"&tr.5=udp%3A%2F%2Ftracker.example.org%3A1337%2Fannounce" +
+		"&tr.5=http%3A%2F%2Ftracker.example.net%2Fannounce" +
+		"&x.pe=10.0.0.1%3A6881&x.pe=%5B2001%3Adb8%3A%3A1%5D%3A6881" +
+		"&ws=http%3A%2F%2Fmirror.example.org%2Ffiles%2F&ws=ftp%3A%2F%2Fmirror.example.org%2F"
+	m, err := New(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "Sample Torrent" {
+		t.Errorf("invalid name: %q", m.Name)
+	}
+	expected := [][]string{
+		{"udp://tracker.example.org:1337/announce"},
+		{"https://tracker.example.com/announce?passkey=abc&uid=1"},
+		{"http://tracker.example.net/announce"},
+	}
+	if len(m.Trackers) != len(expected) {
+		t.Fatalf("invalid trackers: %v", m.Trackers)
+	}
+	for i := range expected {
+		if strings.Join(m.Trackers[i], " ") != strings.Join(expected[i], " ") {
+			t.Errorf("invalid tier #%d: %v", i, m.Trackers[i])
+		}
+	}
+	if len(m.Peers) != 2 || m.Peers[0] != "10.0.0.1:6881" || m.Peers[1] != "[2001:db8::1]:6881" {
+		t.Errorf("invalid peers: %v", m.Peers)
+	}
+	if len(m.Webseeds) != 1 || m.Webseeds[0] != "http://mirror.example.org/files/" {
+		t.Errorf("invalid webseeds: %v", m.Webseeds)
+	}
+
+	// Parsing the string form returns the same trackers.
+	m2, err := New(m.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m2.Trackers) != len(expected) || m2.Trackers[1][0] != expected[1][0] || len(m2.Webseeds) != 1 {
+		t.Errorf("invalid magnet after formatting: %s", m.String())
+	}
+}
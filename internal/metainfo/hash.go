@@ -0,0 +1,163 @@
+package metainfo
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
+)
+
+// Version of the metainfo format.
+type Version int
+
+const (
+	// V1 is the original metainfo format defined in BEP 3.
+	V1 Version = 1
+	// V2 is the metainfo format defined in BEP 52.
+	V2 Version = 2
+	// Hybrid metainfo contains both v1 and v2 information about the same files.
+	Hybrid Version = 3
+)
+
+// merkleBlockSize is the size of the data that is hashed for each leaf of the merkle tree of a v2 file.
+const merkleBlockSize = 16 << 10
+
+// HashAlgorithm is the hash function that is used for verifying piece data.
+type HashAlgorithm interface {
+	// Name of the algorithm.
+	Name() string
+	// New returns a new hash.Hash for calculating piece hashes.
+	New() hash.Hash
+}
+
+type sha1Algorithm struct{}
+
+func (sha1Algorithm) Name() string   { return "sha1" }
+func (sha1Algorithm) New() hash.Hash { return sha1.New() }
+
+// SHA1 is used for verifying pieces of v1 and hybrid torrents.
+var SHA1 HashAlgorithm = sha1Algorithm{}
+
+// merkleAlgorithm calculates the root of the SHA-256 merkle tree of a v2 piece as described in BEP 52.
+type merkleAlgorithm struct {
+	// Number of bytes in piece that belong to the file. Remaining bytes are padding and not hashed.
+	length uint32
+	// Number of leaves in the tree. Leaves after the end of the file are zero.
+	leaves uint32
+}
+
+func (merkleAlgorithm) Name() string { return "sha256" }
+
+func (a merkleAlgorithm) New() hash.Hash {
+	return &merkleHash{
+		length: a.length,
+		leaves: a.leaves,
+		block:  sha256.New(),
+	}
+}
+
+// PieceHashAlgorithm returns the algorithm for verifying the piece at index.
+// Hybrid torrents are verified with the v1 piece hashes because they cover the padded v1 file layout.
+func (i *Info) PieceHashAlgorithm(index uint32) HashAlgorithm {
+	if i.Version != V2 {
+		return SHA1
+	}
+	return i.v2Pieces[index]
+}
+
+type merkleHash struct {
+	length  uint32
+	leaves  uint32
+	written uint32
+	block   hash.Hash
+	blockN  int
+	hashes  [][sha256.Size]byte
+}
+
+func (h *merkleHash) Write(p []byte) (int, error) {
+	n := len(p)
+	if left := h.length - h.written; uint32(len(p)) > left {
+		p = p[:left]
+	}
+	for len(p) > 0 {
+		m := merkleBlockSize - h.blockN
+		if m > len(p) {
+			m = len(p)
+		}
+		_, _ = h.block.Write(p[:m])
+		h.blockN += m
+		h.written += uint32(m)
+		p = p[m:]
+		if h.blockN == merkleBlockSize {
+			h.hashes = append(h.hashes, sumBlock(h.block))
+			h.block.Reset()
+			h.blockN = 0
+		}
+	}
+	return n, nil
+}
+
+func (h *merkleHash) Sum(b []byte) []byte {
+	hashes := make([][sha256.Size]byte, len(h.hashes), h.leaves)
+	copy(hashes, h.hashes)
+	if h.blockN > 0 {
+		hashes = append(hashes, sumBlock(h.block))
+	}
+	root := merkleRoot(hashes, h.leaves, [sha256.Size]byte{})
+	return append(b, root[:]...)
+}
+
+func (h *merkleHash) Reset() {
+	h.written = 0
+	h.block.Reset()
+	h.blockN = 0
+	h.hashes = h.hashes[:0]
+}
+
+func (h *merkleHash) Size() int { return sha256.Size }
+
+func (h *merkleHash) BlockSize() int { return merkleBlockSize }
+
+func sumBlock(h hash.Hash) (sum [sha256.Size]byte) {
+	h.Sum(sum[:0])
+	return
+}
+
+// merkleRoot returns the root of the tree with given leaves.
+// The tree is padded to n leaves with pad, n must be a power of two.
+func merkleRoot(leaves [][sha256.Size]byte, n uint32, pad [sha256.Size]byte) [sha256.Size]byte {
+	layer := make([][sha256.Size]byte, n)
+	copy(layer, leaves)
+	for i := len(leaves); i < len(layer); i++ {
+		layer[i] = pad
+	}
+	var buf [2 * sha256.Size]byte
+	for len(layer) > 1 {
+		for i := 0; i < len(layer)/2; i++ {
+			copy(buf[:sha256.Size], layer[2*i][:])
+			copy(buf[sha256.Size:], layer[2*i+1][:])
+			layer[i] = sha256.Sum256(buf[:])
+		}
+		layer = layer[:len(layer)/2]
+	}
+	return layer[0]
+}
+
+// padHash returns the root of a tree with n zero leaves.
+func padHash(n uint32) (h [sha256.Size]byte) {
+	var buf [2 * sha256.Size]byte
+	for ; n > 1; n /= 2 {
+		copy(buf[:sha256.Size], h[:])
+		copy(buf[sha256.Size:], h[:])
+		h = sha256.Sum256(buf[:])
+	}
+	return
+}
+
+// nextPowerOfTwo returns the smallest power of two that is not less than n.
+func nextPowerOfTwo(n uint32) uint32 {
+	p := uint32(1)
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
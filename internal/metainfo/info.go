@@ -2,6 +2,7 @@ package metainfo
 
 import (
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -27,39 +28,65 @@ var (
 type Info struct {
 	PieceLength uint32
 	Name        string
-	Hash        [20]byte
-	Length      int64
-	NumPieces   uint32
-	Bytes       []byte
-	Private     bool
-	Files       []File
+	// SHA-1 hash of info dictionary. For v2-only torrents, it is the truncated SHA-256 hash as described in BEP 52.
+	Hash [20]byte
+	// SHA-256 hash of info dictionary. Only set for v2 and hybrid torrents.
+	HashV2    [32]byte
+	Length    int64
+	NumPieces uint32
+	Bytes     []byte
+	Private   bool
+	Files     []File
+	Version   Version
+	// Bencoded "piece layers" dictionary of the torrent file. Only set for v2-only torrents.
+	PieceLayers []byte
 	pieces      []byte
+	v2Pieces    []merkleAlgorithm
 }
 
 // File represents a file inside a Torrent.
 type File struct {
 	Length int64
 	Path   string
+	// Padding files are not saved to disk. Their contents are all zeros.
+	Padding bool
+	// Executable files have their executable bit set after they are created.
+	Executable bool
+	// Symlink is the path of the link target relative to the storage root. Empty for regular files.
+	Symlink string
 }
 
 type file struct {
-	Length int64    `bencode:"length"`
-	Path   []string `bencode:"path"`
+	Length      int64    `bencode:"length"`
+	Path        []string `bencode:"path"`
+	Attr        string   `bencode:"attr,omitempty"`         // BEP 47
+	SymlinkPath []string `bencode:"symlink path,omitempty"` // BEP 47
 }
 
 // NewInfo returns info from bencoded bytes in b.
-func NewInfo(b []byte) (*Info, error) {
+// pieceLayers is the bencoded "piece layers" dictionary from the torrent file, it is only used for v2-only torrents.
+func NewInfo(b, pieceLayers []byte) (*Info, error) {
 	var ib struct {
 		PieceLength uint32             `bencode:"piece length"`
 		Pieces      []byte             `bencode:"pieces"`
 		Name        string             `bencode:"name"`
 		Private     bencode.RawMessage `bencode:"private"`
-		Length      int64              `bencode:"length"` // Single File Mode
-		Files       []file             `bencode:"files"`  // Multiple File mode
+		Length      int64              `bencode:"length"`       // Single File Mode
+		Attr        string             `bencode:"attr"`         // Single File Mode
+		Files       []file             `bencode:"files"`        // Multiple File mode
+		MetaVersion int                `bencode:"meta version"` // BEP 52
+		FileTree    bencode.RawMessage `bencode:"file tree"`    // BEP 52
 	}
 	if err := bencode.DecodeBytes(b, &ib); err != nil {
 		return nil, err
 	}
+	version := V1
+	if ib.MetaVersion == 2 {
+		if len(ib.Pieces) == 0 {
+			return newInfoV2(b, ib.Name, ib.PieceLength, parsePrivateField(ib.Private), ib.FileTree, pieceLayers)
+		}
+		version = Hybrid
+	}
 	if ib.PieceLength == 0 {
 		return nil, errZeroPieceLength
 	}
@@ -77,6 +104,11 @@ func NewInfo(b []byte) (*Info, error) {
 				return nil, fmt.Errorf("invalid file name: %q", filepath.Join(file.Path...))
 			}
 		}
+		for _, path := range file.SymlinkPath {
+			if strings.TrimSpace(path) == ".." {
+				return nil, fmt.Errorf("invalid symlink path: %q", filepath.Join(file.SymlinkPath...))
+			}
+		}
 	}
 	i := Info{
 		PieceLength: ib.PieceLength,
@@ -84,6 +116,7 @@ func NewInfo(b []byte) (*Info, error) {
 		pieces:      ib.Pieces,
 		Name:        ib.Name,
 		Private:     parsePrivateField(ib.Private),
+		Version:     version,
 	}
 	multiFile := len(ib.Files) > 0
 	if multiFile {
@@ -104,6 +137,9 @@ func NewInfo(b []byte) (*Info, error) {
 	hash := sha1.New()
 	_, _ = hash.Write(b)
 	copy(i.Hash[:], hash.Sum(nil))
+	if version == Hybrid {
+		i.HashV2 = sha256.Sum256(b)
+	}
 
 	// name field is optional
 	if ib.Name != "" {
@@ -122,12 +158,26 @@ func NewInfo(b []byte) (*Info, error) {
 				parts = append(parts, cleanName(p))
 			}
 			i.Files[j] = File{
-				Path:   filepath.Join(parts...),
-				Length: f.Length,
+				Path:       filepath.Join(parts...),
+				Length:     f.Length,
+				Padding:    strings.ContainsRune(f.Attr, 'p'),
+				Executable: strings.ContainsRune(f.Attr, 'x'),
+			}
+			if strings.ContainsRune(f.Attr, 'l') && len(f.SymlinkPath) > 0 {
+				target := make([]string, 0, len(f.SymlinkPath)+1)
+				target = append(target, cleanName(i.Name))
+				for _, p := range f.SymlinkPath {
+					target = append(target, cleanName(p))
+				}
+				i.Files[j].Symlink = filepath.Join(target...)
 			}
 		}
 	} else {
-		i.Files = []File{{Path: cleanName(i.Name), Length: i.Length}}
+		i.Files = []File{{
+			Path:       cleanName(i.Name),
+			Length:     i.Length,
+			Executable: strings.ContainsRune(ib.Attr, 'x'),
+		}}
 	}
 	return &i, nil
 }
@@ -295,8 +345,12 @@ func NewInfoBytes(root string, paths []string, private bool, pieceLength uint32,
 
 // PieceHash returns the hash of a piece at index.
 func (i *Info) PieceHash(index uint32) []byte {
-	begin := index * sha1.Size
-	end := begin + sha1.Size
+	size := uint32(sha1.Size)
+	if i.Version == V2 {
+		size = sha256.Size
+	}
+	begin := index * size
+	end := begin + size
 	return i.pieces[begin:end]
 }
 
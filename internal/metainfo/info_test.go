@@ -1,9 +1,14 @@
 package metainfo
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/zeebo/bencode"
 )
 
 func TestCalculatePieceLength(t *testing.T) {
@@ -47,3 +52,141 @@ func TestCleanName(t *testing.T) {
 		assert.Equal(t, c.cleaned, cleanNameN(c.name, c.max))
 	}
 }
+
+func TestHashAlgorithm(t *testing.T) {
+	newInfo := func(metaVersion int, withPieces bool) ([]byte, error) {
+		ib := map[string]interface{}{
+			"name":         "foo",
+			"piece length": 16 << 10,
+			"length":       100,
+		}
+		if metaVersion != 0 {
+			ib["meta version"] = metaVersion
+			root := sha256.Sum256(make([]byte, 100))
+			ib["file tree"] = map[string]interface{}{
+				"foo": map[string]interface{}{
+					"": map[string]interface{}{
+						"length":      100,
+						"pieces root": root[:],
+					},
+				},
+			}
+		}
+		if withPieces {
+			ib["pieces"] = make([]byte, 20)
+		}
+		return bencode.EncodeBytes(ib)
+	}
+
+	b, err := newInfo(0, true)
+	assert.NoError(t, err)
+	info, err := NewInfo(b, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, V1, info.Version)
+	assert.Equal(t, "sha1", info.PieceHashAlgorithm(0).Name())
+
+	b, err = newInfo(2, true)
+	assert.NoError(t, err)
+	info, err = NewInfo(b, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Hybrid, info.Version)
+	assert.Equal(t, "sha1", info.PieceHashAlgorithm(0).Name())
+	assert.Equal(t, sha256.Sum256(b), info.HashV2)
+
+	b, err = newInfo(2, false)
+	assert.NoError(t, err)
+	info, err = NewInfo(b, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, V2, info.Version)
+	assert.Equal(t, "sha256", info.PieceHashAlgorithm(0).Name())
+	assert.Equal(t, sha256.Sum256(b), info.HashV2)
+	assert.Equal(t, info.HashV2[:20], info.Hash[:])
+}
+
+func TestInfoV2(t *testing.T) {
+	const pieceLength = 32 << 10
+	data := make([]byte, 40000)
+	_, _ = rand.Read(data)
+	small := make([]byte, 100)
+	_, _ = rand.Read(small)
+
+	// Leaves of the merkle tree are the hashes of 16K blocks. Blocks after the end of the file are zero.
+	hashPair := func(a, b [32]byte) [32]byte {
+		return sha256.Sum256(append(a[:], b[:]...))
+	}
+	l0 := sha256.Sum256(data[:16<<10])
+	l1 := sha256.Sum256(data[16<<10 : 32<<10])
+	l2 := sha256.Sum256(data[32<<10:])
+	p0 := hashPair(l0, l1)
+	p1 := hashPair(l2, [32]byte{})
+	root := hashPair(p0, p1)
+	smallRoot := sha256.Sum256(small)
+
+	newTorrent := func(layer []byte) ([]byte, error) {
+		info := map[string]interface{}{
+			"name":         "test",
+			"piece length": pieceLength,
+			"meta version": 2,
+			"file tree": map[string]interface{}{
+				"a": map[string]interface{}{
+					"": map[string]interface{}{
+						"length":      len(data),
+						"pieces root": root[:],
+					},
+				},
+				"b": map[string]interface{}{
+					"": map[string]interface{}{
+						"length":      len(small),
+						"pieces root": smallRoot[:],
+					},
+				},
+			},
+		}
+		mi := map[string]interface{}{
+			"info": info,
+		}
+		if layer != nil {
+			mi["piece layers"] = map[string]interface{}{
+				string(root[:]): layer,
+			}
+		}
+		return bencode.EncodeBytes(mi)
+	}
+
+	b, err := newTorrent(append(p0[:], p1[:]...))
+	assert.NoError(t, err)
+	mi, err := New(bytes.NewReader(b))
+	assert.NoError(t, err)
+	info := mi.Info
+	assert.Equal(t, V2, info.Version)
+	assert.Equal(t, uint32(3), info.NumPieces)
+	assert.Equal(t, int64(2*pieceLength+len(small)), info.Length)
+	assert.Equal(t, []File{
+		{Path: filepath.Join("test", "a"), Length: int64(len(data))},
+		{Path: filepath.Join("test", ".pad", "25536"), Length: 2*pieceLength - int64(len(data)), Padding: true},
+		{Path: filepath.Join("test", "b"), Length: int64(len(small))},
+	}, info.Files)
+
+	// Pieces at the end of files contain padding that is not hashed.
+	padded := append(data, make([]byte, 2*pieceLength-len(data))...)
+	pieces := [][]byte{padded[:pieceLength], padded[pieceLength:], small}
+	for i, p := range pieces {
+		h := info.PieceHashAlgorithm(uint32(i)).New()
+		_, _ = h.Write(p)
+		assert.Equal(t, info.PieceHash(uint32(i)), h.Sum(nil), "piece %d", i)
+	}
+	h := info.PieceHashAlgorithm(1).New()
+	_, _ = h.Write(append(data[pieceLength:], make([]byte, 2*pieceLength-len(data)-1)...))
+	_, _ = h.Write([]byte{1})
+	assert.Equal(t, info.PieceHash(1), h.Sum(nil), "padding must not be hashed")
+
+	b, err = newTorrent(nil)
+	assert.NoError(t, err)
+	_, err = New(bytes.NewReader(b))
+	assert.Equal(t, errMissingPieceLayer, err)
+
+	b, err = newTorrent(append(p1[:], p0[:]...))
+	assert.NoError(t, err)
+	_, err = New(bytes.NewReader(b))
+	assert.Equal(t, errInvalidPieceLayer, err)
+}
@@ -18,6 +18,11 @@ type MetaInfo struct {
 	Info         Info
 	AnnounceList [][]string
 	URLList      []string
+	HTTPSeeds    []string
+	CreationDate time.Time
+	Comment      string
+	CreatedBy    string
+	Encoding     string
 }
 
 // New returns a torrent from bencoded stream.
@@ -28,6 +33,12 @@ func New(r io.Reader) (*MetaInfo, error) {
 		Announce     bencode.RawMessage `bencode:"announce"`
 		AnnounceList bencode.RawMessage `bencode:"announce-list"`
 		URLList      bencode.RawMessage `bencode:"url-list"`
+		HTTPSeeds    bencode.RawMessage `bencode:"httpseeds"`
+		CreationDate bencode.RawMessage `bencode:"creation date"`
+		Comment      bencode.RawMessage `bencode:"comment"`
+		CreatedBy    bencode.RawMessage `bencode:"created by"`
+		Encoding     bencode.RawMessage `bencode:"encoding"`
+		PieceLayers  bencode.RawMessage `bencode:"piece layers"` // BEP 52
 	}
 	err := bencode.NewDecoder(r).Decode(&t)
 	if err != nil {
@@ -36,7 +47,7 @@ func New(r io.Reader) (*MetaInfo, error) {
 	if len(t.Info) == 0 {
 		return nil, errors.New("no info dict in torrent file")
 	}
-	info, err := NewInfo(t.Info)
+	info, err := NewInfo(t.Info, t.PieceLayers)
 	if err != nil {
 		return nil, err
 	}
@@ -64,26 +75,72 @@ func New(r io.Reader) (*MetaInfo, error) {
 			ret.AnnounceList = append(ret.AnnounceList, []string{s})
 		}
 	}
-	if len(t.URLList) > 0 {
-		if t.URLList[0] == 'l' {
-			var l []string
-			err = bencode.DecodeBytes(t.URLList, &l)
-			if err == nil {
-				for _, s := range l {
-					if isWebseedSupported(s) {
-						ret.URLList = append(ret.URLList, s)
-					}
+	ret.URLList = decodeWebseeds(t.URLList)
+	// URLs present in both lists are used as GetRight style (BEP 19) sources.
+	for _, s := range decodeWebseeds(t.HTTPSeeds) {
+		if !containsString(ret.URLList, s) {
+			ret.HTTPSeeds = append(ret.HTTPSeeds, s)
+		}
+	}
+	if len(t.CreationDate) > 0 {
+		var i int64
+		err = bencode.DecodeBytes(t.CreationDate, &i)
+		if err == nil && i > 0 {
+			ret.CreationDate = time.Unix(i, 0).UTC()
+		}
+	}
+	ret.Comment = decodeOptionalString(t.Comment)
+	ret.CreatedBy = decodeOptionalString(t.CreatedBy)
+	ret.Encoding = decodeOptionalString(t.Encoding)
+	return &ret, nil
+}
+
+// decodeWebseeds returns the supported URLs in a "url-list" or "httpseeds" field, which may be a single string or a list of strings.
+func decodeWebseeds(b bencode.RawMessage) []string {
+	if len(b) == 0 {
+		return nil
+	}
+	var ret []string
+	if b[0] == 'l' {
+		var l []string
+		err := bencode.DecodeBytes(b, &l)
+		if err == nil {
+			for _, s := range l {
+				if isWebseedSupported(s) {
+					ret = append(ret, s)
 				}
 			}
-		} else {
-			var s string
-			err = bencode.DecodeBytes(t.URLList, &s)
-			if err == nil && isWebseedSupported(s) {
-				ret.URLList = append(ret.URLList, s)
-			}
+		}
+	} else {
+		var s string
+		err := bencode.DecodeBytes(b, &s)
+		if err == nil && isWebseedSupported(s) {
+			ret = append(ret, s)
 		}
 	}
-	return &ret, nil
+	return ret
+}
+
+func containsString(l []string, s string) bool {
+	for _, x := range l {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeOptionalString returns empty string if the field is missing or is not a valid string.
+func decodeOptionalString(b bencode.RawMessage) string {
+	if len(b) == 0 {
+		return ""
+	}
+	var s string
+	err := bencode.DecodeBytes(b, &s)
+	if err != nil {
+		return ""
+	}
+	return s
 }
 
 func isTrackerSupported(s string) bool {
@@ -96,8 +153,14 @@ func isWebseedSupported(s string) bool {
 
 // NewBytes creates a new torrent metadata file from given information.
 func NewBytes(info []byte, trackers [][]string, webseeds []string, comment string) ([]byte, error) {
+	return NewBytesWithPieceLayers(info, nil, trackers, webseeds, comment)
+}
+
+// NewBytesWithPieceLayers is like NewBytes but also includes the piece layers of a v2 torrent.
+func NewBytesWithPieceLayers(info, pieceLayers []byte, trackers [][]string, webseeds []string, comment string) ([]byte, error) {
 	mi := struct {
 		Info         bencode.RawMessage `bencode:"info"`
+		PieceLayers  bencode.RawMessage `bencode:"piece layers,omitempty"`
 		Announce     string             `bencode:"announce,omitempty"`
 		AnnounceList [][]string         `bencode:"announce-list,omitempty"`
 		URLList      bencode.RawMessage `bencode:"url-list,omitempty"`
@@ -106,6 +169,7 @@ func NewBytes(info []byte, trackers [][]string, webseeds []string, comment strin
 		CreatedBy    string             `bencode:"created by,omitempty"`
 	}{
 		Info:         info,
+		PieceLayers:  pieceLayers,
 		Comment:      comment,
 		CreationDate: time.Now().UTC().Unix(),
 		CreatedBy:    Creator,
@@ -1,11 +1,15 @@
 package metainfo
 
 import (
+	"bytes"
 	"encoding/hex"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/zeebo/bencode"
 )
 
 func TestTorrent(t *testing.T) {
@@ -26,4 +30,55 @@ func TestTorrent(t *testing.T) {
 		{"http://torrent.ubuntu.com:6969/announce"},
 		{"http://ipv6.torrent.ubuntu.com:6969/announce"},
 	}, tor.AnnounceList)
+	assert.Equal(t, "Ubuntu CD releases.ubuntu.com", tor.Comment)
+	assert.Equal(t, int64(1406245742), tor.CreationDate.Unix())
+	assert.Equal(t, "", tor.CreatedBy)
+	assert.Equal(t, "", tor.Encoding)
+}
+
+func TestFileAttributes(t *testing.T) {
+	f, err := os.Open("testdata/bep47.torrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tor, err := New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, int64(32768), tor.Info.Length)
+	assert.Equal(t, []File{
+		{Path: filepath.Join("bep47", "run.sh"), Length: 21, Executable: true},
+		{Path: filepath.Join("bep47", ".pad", "16363"), Length: 16363, Padding: true},
+		{Path: filepath.Join("bep47", "data.txt"), Length: 16384},
+		{Path: filepath.Join("bep47", "link.txt"), Symlink: filepath.Join("bep47", "data.txt")},
+	}, tor.Info.Files)
+}
+
+func TestHTTPSeeds(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/bep47.torrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]bencode.RawMessage
+	err = bencode.DecodeBytes(b, &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m["url-list"], _ = bencode.EncodeBytes("http://example.com/webseed/")
+	m["httpseeds"], _ = bencode.EncodeBytes([]string{"http://example.com/seed.php", "http://example.com/webseed/", "ftp://example.com/seed"})
+	b, err = bencode.EncodeBytes(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tor, err := New(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"http://example.com/webseed/"}, tor.URLList)
+	assert.Equal(t, []string{"http://example.com/seed.php"}, tor.HTTPSeeds)
 }
@@ -0,0 +1,201 @@
+package metainfo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zeebo/bencode"
+)
+
+var (
+	errPieceLengthV2     = errors.New("piece length of v2 torrent must be a power of two and at least 16K")
+	errInvalidFileTree   = errors.New("invalid file tree")
+	errInvalidPiecesRoot = errors.New("invalid pieces root")
+	errMissingPieceLayer = errors.New("piece layer of file is missing")
+	errInvalidPieceLayer = errors.New("piece layer does not match pieces root")
+)
+
+// fileV2 is a file in the "file tree" of a v2 info dictionary.
+type fileV2 struct {
+	Length     int64  `bencode:"length"`
+	PiecesRoot []byte `bencode:"pieces root"`
+	Attr       string `bencode:"attr"`
+	path       []string
+}
+
+// newInfoV2 returns the info of a v2-only torrent (BEP 52).
+// Files in v2 torrents are aligned to piece boundaries.
+// Padding files are inserted between files, so the pieces are laid out the same way as the v1 part of hybrid torrents.
+func newInfoV2(b []byte, name string, pieceLength uint32, private bool, fileTree bencode.RawMessage, pieceLayers []byte) (*Info, error) {
+	if pieceLength < merkleBlockSize || pieceLength&(pieceLength-1) != 0 {
+		return nil, errPieceLengthV2
+	}
+	var files []fileV2
+	err := walkFileTree(fileTree, nil, &files)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errInvalidFileTree
+	}
+	var layers map[string][]byte
+	if len(pieceLayers) > 0 {
+		err = bencode.DecodeBytes(pieceLayers, &layers)
+		if err != nil {
+			return nil, err
+		}
+	}
+	i := Info{
+		PieceLength: pieceLength,
+		Private:     private,
+		Version:     V2,
+		Bytes:       b,
+		PieceLayers: pieceLayers,
+	}
+	i.HashV2 = sha256.Sum256(b)
+	copy(i.Hash[:], i.HashV2[:])
+	if name != "" {
+		i.Name = name
+	} else {
+		i.Name = hex.EncodeToString(i.Hash[:])
+	}
+	singleFile := len(files) == 1 && len(files[0].path) == 1
+	for _, f := range files {
+		if f.Length == 0 {
+			i.Files = append(i.Files, i.newFileV2(f, singleFile))
+			continue
+		}
+		if rem := i.Length % int64(pieceLength); rem != 0 {
+			pad := int64(pieceLength) - rem
+			i.Files = append(i.Files, File{
+				Path:    filepath.Join(cleanName(i.Name), ".pad", strconv.FormatInt(pad, 10)),
+				Length:  pad,
+				Padding: true,
+			})
+			i.Length += pad
+		}
+		err = i.addPiecesV2(f, layers)
+		if err != nil {
+			return nil, err
+		}
+		i.Files = append(i.Files, i.newFileV2(f, singleFile))
+		i.Length += f.Length
+	}
+	if len(i.v2Pieces) == 0 {
+		return nil, errZeroPieces
+	}
+	if uint64(len(i.v2Pieces)) > uint64(^uint32(0)/sha256.Size) {
+		return nil, errInvalidPieceData
+	}
+	i.NumPieces = uint32(len(i.v2Pieces))
+	return &i, nil
+}
+
+func (i *Info) newFileV2(f fileV2, singleFile bool) File {
+	parts := make([]string, 0, len(f.path)+1)
+	if !singleFile {
+		parts = append(parts, cleanName(i.Name))
+	}
+	for _, p := range f.path {
+		parts = append(parts, cleanName(p))
+	}
+	return File{
+		Path:       filepath.Join(parts...),
+		Length:     f.Length,
+		Executable: strings.ContainsRune(f.Attr, 'x'),
+	}
+}
+
+// addPiecesV2 appends the piece hashes of the file.
+// Files that are not larger than the piece length have a single piece whose hash is the pieces root.
+// Hashes of larger files are taken from the piece layers and checked against the pieces root.
+func (i *Info) addPiecesV2(f fileV2, layers map[string][]byte) error {
+	if len(f.PiecesRoot) != sha256.Size {
+		return errInvalidPiecesRoot
+	}
+	pieceLength := int64(i.PieceLength)
+	numPieces := (f.Length + pieceLength - 1) / pieceLength
+	leaves := i.PieceLength / merkleBlockSize
+	hashes := f.PiecesRoot
+	if numPieces == 1 {
+		leaves = nextPowerOfTwo(uint32((f.Length + merkleBlockSize - 1) / merkleBlockSize))
+	} else {
+		var ok bool
+		hashes, ok = layers[string(f.PiecesRoot)]
+		if !ok {
+			return errMissingPieceLayer
+		}
+		if int64(len(hashes)) != numPieces*sha256.Size {
+			return errInvalidPieceLayer
+		}
+		layer := make([][sha256.Size]byte, numPieces)
+		for j := range layer {
+			copy(layer[j][:], hashes[j*sha256.Size:])
+		}
+		root := merkleRoot(layer, nextPowerOfTwo(uint32(numPieces)), padHash(leaves))
+		if !bytes.Equal(root[:], f.PiecesRoot) {
+			return errInvalidPieceLayer
+		}
+	}
+	for j := int64(0); j < numPieces; j++ {
+		length := pieceLength
+		if j == numPieces-1 {
+			length = f.Length - j*pieceLength
+		}
+		i.v2Pieces = append(i.v2Pieces, merkleAlgorithm{length: uint32(length), leaves: leaves})
+	}
+	i.pieces = append(i.pieces, hashes...)
+	return nil
+}
+
+// walkFileTree appends the files in the tree to files in the order of their paths.
+// A file is a dictionary with an empty key that contains the length and pieces root of the file.
+func walkFileTree(b bencode.RawMessage, path []string, files *[]fileV2) error {
+	var tree map[string]bencode.RawMessage
+	err := bencode.DecodeBytes(b, &tree)
+	if err != nil {
+		return err
+	}
+	if len(path) > 0 {
+		if fb, ok := tree[""]; ok {
+			if len(tree) != 1 {
+				return errInvalidFileTree
+			}
+			var f fileV2
+			err = bencode.DecodeBytes(fb, &f)
+			if err != nil {
+				return err
+			}
+			if f.Length < 0 {
+				return errInvalidFileTree
+			}
+			f.path = path
+			*files = append(*files, f)
+			return nil
+		}
+	}
+	keys := make([]string, 0, len(tree))
+	for k := range tree {
+		if k == "" || strings.TrimSpace(k) == ".." {
+			return fmt.Errorf("invalid file name: %q", filepath.Join(append(path, k)...))
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := make([]string, len(path), len(path)+1)
+		copy(p, path)
+		err = walkFileTree(tree[k], append(p, k), files)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+// Package mover moves the files of a torrent to another directory.
+package mover
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const copyBufferSize = 1 << 20
+
+var errClosed = errors.New("mover is closed")
+
+// Mover moves files from a directory to another.
+// Files are renamed if both directories are on the same file system.
+// Otherwise, they are copied to the destination and removed from the source after all of them are copied.
+// If moving fails or is cancelled, files that are moved so far are put back, so the source stays intact.
+type Mover struct {
+	Error error
+
+	closeC chan struct{}
+	doneC  chan struct{}
+}
+
+// File to be moved. Path is relative to the source and destination directories.
+type File struct {
+	Path   string
+	Length int64
+}
+
+// Progress about the move.
+type Progress struct {
+	MovedSize int64
+}
+
+// New returns a new Mover.
+func New() *Mover {
+	return &Mover{
+		closeC: make(chan struct{}),
+		doneC:  make(chan struct{}),
+	}
+}
+
+// Close the Mover. If files are being moved, the move is cancelled and files are put back to the source.
+func (m *Mover) Close() {
+	close(m.closeC)
+	<-m.doneC
+}
+
+// Run the Mover. Files that do not exist in the source directory are skipped.
+func (m *Mover) Run(src, dest string, files []File, progressC chan Progress, resultC chan *Mover) {
+	defer close(m.doneC)
+
+	m.Error = m.move(src, dest, files, progressC)
+	if m.Error == errClosed {
+		return
+	}
+	select {
+	case resultC <- m:
+	case <-m.closeC:
+	}
+}
+
+func (m *Mover) move(src, dest string, files []File, progressC chan Progress) error {
+	existing := make([]File, 0, len(files))
+	for _, f := range files {
+		_, err := os.Lstat(filepath.Join(src, f.Path))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// Do not overwrite files that belong to something else.
+		_, err = os.Lstat(filepath.Join(dest, f.Path))
+		if err == nil {
+			return fmt.Errorf("file already exists at destination: %s", f.Path)
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		existing = append(existing, f)
+	}
+	if len(existing) == 0 {
+		return nil
+	}
+	err := m.rename(src, dest, existing, progressC)
+	if err == errCrossDevice {
+		err = m.copy(src, dest, existing, progressC)
+	}
+	if err != nil {
+		return err
+	}
+	removeEmptyDirs(src, existing)
+	return nil
+}
+
+// removeEmptyDirs removes the directories of files in src that are left empty after moving. src is not removed.
+func removeEmptyDirs(src string, files []File) {
+	for _, f := range files {
+		for dir := filepath.Dir(f.Path); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+			if os.Remove(filepath.Join(src, dir)) != nil {
+				break
+			}
+		}
+	}
+}
+
+var errCrossDevice = errors.New("cannot rename across file systems")
+
+// rename moves files by renaming them. Returns errCrossDevice if the first file cannot be renamed,
+// in that case the files must be copied.
+func (m *Mover) rename(src, dest string, files []File, progressC chan Progress) (err error) {
+	var renamed []File
+	defer func() {
+		if err == nil || err == errCrossDevice {
+			return
+		}
+		for _, f := range renamed {
+			_ = os.Rename(filepath.Join(dest, f.Path), filepath.Join(src, f.Path))
+		}
+	}()
+	var moved int64
+	for i, f := range files {
+		select {
+		case <-m.closeC:
+			return errClosed
+		default:
+		}
+		target := filepath.Join(dest, f.Path)
+		err = os.MkdirAll(filepath.Dir(target), os.ModeDir|0750)
+		if err != nil {
+			return err
+		}
+		err = os.Rename(filepath.Join(src, f.Path), target)
+		if err != nil {
+			if i == 0 {
+				// Likely on different file systems. Copying may still work.
+				return errCrossDevice
+			}
+			return err
+		}
+		renamed = append(renamed, f)
+		moved += f.Length
+		if !m.sendProgress(progressC, moved) {
+			return errClosed
+		}
+	}
+	return nil
+}
+
+// copy copies all files to dest, then removes them from src.
+func (m *Mover) copy(src, dest string, files []File, progressC chan Progress) (err error) {
+	var copied []File
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, f := range copied {
+			_ = os.Remove(filepath.Join(dest, f.Path))
+		}
+	}()
+	buf := make([]byte, copyBufferSize)
+	var moved int64
+	for _, f := range files {
+		// Added before copying, so a partially written file is removed too.
+		copied = append(copied, f)
+		err = m.copyFile(filepath.Join(src, f.Path), filepath.Join(dest, f.Path), buf, &moved, progressC)
+		if err != nil {
+			return err
+		}
+	}
+	for _, f := range files {
+		_ = os.Remove(filepath.Join(src, f.Path))
+	}
+	return nil
+}
+
+func (m *Mover) copyFile(src, dest string, buf []byte, moved *int64, progressC chan Progress) error {
+	sf, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sf.Close()
+	fi, err := sf.Stat()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(dest), os.ModeDir|0750)
+	if err != nil {
+		return err
+	}
+	df, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer df.Close()
+	for {
+		select {
+		case <-m.closeC:
+			return errClosed
+		default:
+		}
+		n, rerr := sf.Read(buf)
+		if n > 0 {
+			_, err = df.Write(buf[:n])
+			if err != nil {
+				// Fails with ENOSPC if the destination does not have enough space.
+				return err
+			}
+			*moved += int64(n)
+			if !m.sendProgress(progressC, *moved) {
+				return errClosed
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+	err = df.Sync()
+	if err != nil {
+		return err
+	}
+	err = df.Close()
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(dest, fi.ModTime(), fi.ModTime())
+}
+
+func (m *Mover) sendProgress(progressC chan Progress, size int64) bool {
+	select {
+	case progressC <- Progress{MovedSize: size}:
+		return true
+	case <-m.closeC:
+		return false
+	}
+}
@@ -0,0 +1,95 @@
+package mover
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, data string) {
+	err := os.MkdirAll(filepath.Dir(name), os.ModeDir|0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(name, []byte(data), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func run(src, dest string, files []File) error {
+	m := New()
+	progressC := make(chan Progress)
+	resultC := make(chan *Mover)
+	go m.Run(src, dest, files, progressC, resultC)
+	for {
+		select {
+		case <-progressC:
+		case res := <-resultC:
+			return res.Error
+		}
+	}
+}
+
+func TestMove(t *testing.T) {
+	where, err := ioutil.TempDir("", "rain-mover-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(where)
+	src := filepath.Join(where, "src")
+	dest := filepath.Join(where, "dest")
+	writeFile(t, filepath.Join(src, "torrent", "a"), "foo")
+	writeFile(t, filepath.Join(src, "torrent", "dir", "b"), "bar")
+
+	files := []File{
+		{Path: filepath.Join("torrent", "a"), Length: 3},
+		{Path: filepath.Join("torrent", "dir", "b"), Length: 3},
+		{Path: filepath.Join("torrent", "missing"), Length: 3},
+	}
+	err = run(src, dest, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dest, "torrent", "dir", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "bar" {
+		t.Fatalf("invalid content: %q", b)
+	}
+	if _, err = os.Stat(filepath.Join(src, "torrent")); !os.IsNotExist(err) {
+		t.Fatalf("empty directory is not removed: %v", err)
+	}
+	if _, err = os.Stat(src); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMoveDestinationExists(t *testing.T) {
+	where, err := ioutil.TempDir("", "rain-mover-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(where)
+	src := filepath.Join(where, "src")
+	dest := filepath.Join(where, "dest")
+	writeFile(t, filepath.Join(src, "a"), "foo")
+	writeFile(t, filepath.Join(src, "b"), "bar")
+	writeFile(t, filepath.Join(dest, "b"), "baz")
+
+	files := []File{{Path: "a", Length: 3}, {Path: "b", Length: 3}}
+	err = run(src, dest, files)
+	if err == nil {
+		t.Fatal("existing file is overwritten")
+	}
+	for _, f := range files {
+		if _, err = os.Stat(filepath.Join(src, f.Path)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = os.Stat(filepath.Join(dest, "a")); !os.IsNotExist(err) {
+		t.Fatalf("file is moved: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+// Package onion contains helpers for working with Tor hidden service (.onion) addresses.
+package onion
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+)
+
+// IsOnion returns true if host is a Tor hidden service address.
+func IsOnion(host string) bool {
+	return strings.HasSuffix(strings.ToLower(strings.TrimSuffix(host, ".")), ".onion")
+}
+
+// prefix is the IPv6 range used by OnionCat for mapping onion addresses.
+// Addresses in this range are not routable on the Internet.
+var prefix = net.IP{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
+
+// IsMapped returns true if the IP is in the range that is used for mapping onion addresses.
+func IsMapped(ip net.IP) bool {
+	ip = ip.To16()
+	return ip != nil && ip.To4() == nil && ip[:len(prefix)].Equal(prefix)
+}
+
+// Map assigns fake IPv6 addresses to onion host names,
+// so onion peers can be kept in address lists that work with IP addresses.
+// It is also used for other host names that must be resolved by Tor instead of the local resolver.
+// Tor does the same thing with its "AutomapHostsOnResolve" option.
+type Map struct {
+	m     sync.Mutex
+	ips   map[string]net.IP
+	hosts map[string]string
+	last  uint64
+}
+
+// NewMap returns a new empty Map.
+func NewMap() *Map {
+	return &Map{
+		ips:   make(map[string]net.IP),
+		hosts: make(map[string]string),
+	}
+}
+
+// IP returns the mapped IP address of the onion host. A new address is assigned if the host is not mapped before.
+func (m *Map) IP(host string) net.IP {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	m.m.Lock()
+	defer m.m.Unlock()
+	if ip, ok := m.ips[host]; ok {
+		return ip
+	}
+	m.last++
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, prefix)
+	binary.BigEndian.PutUint64(ip[8:], m.last)
+	m.ips[host] = ip
+	m.hosts[ip.String()] = host
+	return ip
+}
+
+// Host returns the onion host name of the mapped IP address.
+func (m *Map) Host(ip net.IP) (string, bool) {
+	m.m.Lock()
+	defer m.m.Unlock()
+	host, ok := m.hosts[ip.String()]
+	return host, ok
+}
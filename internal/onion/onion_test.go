@@ -0,0 +1,28 @@
+package onion
+
+import (
+	"net"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	if !IsOnion("abcdef.onion") || !IsOnion("ABCDEF.ONION.") || IsOnion("example.com") {
+		t.Fatal("invalid IsOnion result")
+	}
+	m := NewMap()
+	ip1 := m.IP("aaaa.onion")
+	ip2 := m.IP("bbbb.onion")
+	if ip1.Equal(ip2) {
+		t.Fatal("hosts must be mapped to different addresses")
+	}
+	if !m.IP("AAAA.onion").Equal(ip1) {
+		t.Fatal("same host must be mapped to same address")
+	}
+	if !IsMapped(ip1) || IsMapped(net.ParseIP("2001:db8::1")) || IsMapped(net.IPv4(127, 0, 0, 1)) {
+		t.Fatal("invalid IsMapped result")
+	}
+	host, ok := m.Host(ip2)
+	if !ok || host != "bbbb.onion" {
+		t.Fatalf("invalid host: %q", host)
+	}
+}
@@ -17,8 +17,8 @@ import (
 	"github.com/cenkalti/rain/internal/pexlist"
 	"github.com/cenkalti/rain/internal/piece"
 	"github.com/cenkalti/rain/internal/pieceset"
+	"github.com/cenkalti/rain/internal/speedlimit"
 	"github.com/cenkalti/rain/internal/stringutil"
-	"github.com/juju/ratelimit"
 	"github.com/rcrowley/go-metrics"
 )
 
@@ -52,6 +52,9 @@ type Peer struct {
 
 	Downloading bool
 
+	// Moving average of the durations between sending a request and receiving the block.
+	RequestLatency time.Duration
+
 	downloadSpeed metrics.Meter
 	uploadSpeed   metrics.Meter
 
@@ -61,6 +64,8 @@ type Peer struct {
 	ExtensionHandshake *peerprotocol.ExtensionHandshakeMessage
 
 	PEX *pex
+	// Number of PEX messages received from the peer. Only the first message may exceed the limit of addresses.
+	PEXReceived int
 
 	snubTimeout time.Duration
 	snubTimer   *time.Timer
@@ -82,7 +87,7 @@ type PieceMessage struct {
 }
 
 // New wraps the net.Conn and returns a new Peer.
-func New(conn net.Conn, source peersource.Source, id [20]byte, extensions [8]byte, cipher mse.CryptoMethod, pieceReadTimeout, snubTimeout time.Duration, maxRequestsIn int, br, bw *ratelimit.Bucket) *Peer {
+func New(conn net.Conn, source peersource.Source, id [20]byte, extensions [8]byte, cipher mse.CryptoMethod, pieceReadTimeout, snubTimeout time.Duration, maxRequestsIn int, br, bw *speedlimit.Limiter, trace bool) *Peer {
 	bf, _ := bitfield.NewBytes(extensions[:], 64)
 	fastEnabled := bf.Test(61)
 	extensionsEnabled := bf.Test(43)
@@ -91,7 +96,7 @@ func New(conn net.Conn, source peersource.Source, id [20]byte, extensions [8]byt
 	t := time.NewTimer(math.MaxInt64)
 	t.Stop()
 	return &Peer{
-		Conn:              peerconn.New(conn, newPeerLogger(source, conn), pieceReadTimeout, maxRequestsIn, fastEnabled, br, bw),
+		Conn:              peerconn.New(conn, newPeerLogger(source, conn), pieceReadTimeout, maxRequestsIn, fastEnabled, br, bw, trace),
 		Source:            source,
 		ConnectedAt:       time.Now(),
 		ID:                id,
@@ -187,6 +192,18 @@ func (p *Peer) StartPEX(initialPeers map[*Peer]struct{}, recentlySeen *pexlist.R
 	}
 }
 
+// PEXFlags returns the flags of the Peer that are sent to other peers in "added.f" field of PEX messages.
+func (p *Peer) PEXFlags() byte {
+	var flags byte
+	if p.EncryptionCipher == mse.RC4 {
+		flags |= peerprotocol.PEXFlagPreferEncryption
+	}
+	if p.Bitfield != nil && p.Bitfield.All() {
+		flags |= peerprotocol.PEXFlagSeedOnly
+	}
+	return flags
+}
+
 // ResetSnubTimer is called when some data received from the Peer.
 func (p *Peer) ResetSnubTimer() {
 	p.snubTimer.Reset(p.snubTimeout)
@@ -197,6 +214,15 @@ func (p *Peer) StopSnubTimer() {
 	p.snubTimer.Stop()
 }
 
+// UpdateRequestLatency adds a new sample to the moving average of request latencies.
+func (p *Peer) UpdateRequestLatency(d time.Duration) {
+	if p.RequestLatency == 0 {
+		p.RequestLatency = d
+		return
+	}
+	p.RequestLatency = (7*p.RequestLatency + d) / 8
+}
+
 // DownloadSpeed of the Peer in bytes per second.
 func (p *Peer) DownloadSpeed() int {
 	return int(p.downloadSpeed.Rate1())
@@ -207,6 +233,26 @@ func (p *Peer) UploadSpeed() int {
 	return int(p.uploadSpeed.Rate1())
 }
 
+// BytesDownloaded returns the total number of bytes in piece messages received from the Peer.
+func (p *Peer) BytesDownloaded() int64 {
+	return p.downloadSpeed.Count()
+}
+
+// BytesUploaded returns the total number of bytes in piece messages sent to the Peer.
+func (p *Peer) BytesUploaded() int64 {
+	return p.uploadSpeed.Count()
+}
+
+// ReciprocationRatio returns the ratio of downloaded bytes from the Peer to uploaded bytes to the Peer.
+// Returns zero if nothing is uploaded to the Peer.
+func (p *Peer) ReciprocationRatio() float64 {
+	uploaded := p.BytesUploaded()
+	if uploaded == 0 {
+		return 0
+	}
+	return float64(p.BytesDownloaded()) / float64(uploaded)
+}
+
 // Choke the connected Peer by sending a "choke" protocol message.
 func (p *Peer) Choke() {
 	p.ClientChoking = true
@@ -229,6 +275,11 @@ func (p *Peer) Interested() bool {
 	return p.PeerInterested
 }
 
+// Snubbing returns true if the Peer has stopped sending the pieces we have requested.
+func (p *Peer) Snubbing() bool {
+	return p.Snubbed
+}
+
 // Optimistic returns true if we are unchoking the Peer optimistically.
 func (p *Peer) Optimistic() bool {
 	return p.OptimisticUnchoked
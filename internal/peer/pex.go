@@ -16,25 +16,30 @@ type pex struct {
 	// Contains added and dropped peers.
 	pexList *pexlist.PEXList
 
-	pexAddPeerC  chan *net.TCPAddr
+	pexAddPeerC  chan pexAddr
 	pexDropPeerC chan *net.TCPAddr
 
 	closeC chan struct{}
 	doneC  chan struct{}
 }
 
+type pexAddr struct {
+	addr  *net.TCPAddr
+	flags byte
+}
+
 func newPEX(conn *peerconn.Conn, extID uint8, initialPeers map[*Peer]struct{}, recentlySeen *pexlist.RecentlySeen) *pex {
 	pl := pexlist.NewWithRecentlySeen(recentlySeen.Peers())
 	for pe := range initialPeers {
 		if pe.Addr().String() != conn.Addr().String() {
-			pl.Add(pe.Addr())
+			pl.Add(pe.Addr(), pe.PEXFlags())
 		}
 	}
 	return &pex{
 		conn:         conn,
 		extID:        extID,
 		pexList:      pl,
-		pexAddPeerC:  make(chan *net.TCPAddr),
+		pexAddPeerC:  make(chan pexAddr),
 		pexDropPeerC: make(chan *net.TCPAddr),
 		closeC:       make(chan struct{}),
 		doneC:        make(chan struct{}),
@@ -56,8 +61,8 @@ func (p *pex) run() {
 
 	for {
 		select {
-		case addr := <-p.pexAddPeerC:
-			p.pexList.Add(addr)
+		case pa := <-p.pexAddPeerC:
+			p.pexList.Add(pa.addr, pa.flags)
 		case addr := <-p.pexDropPeerC:
 			p.pexList.Drop(addr)
 		case <-ticker.C:
@@ -68,9 +73,10 @@ func (p *pex) run() {
 	}
 }
 
-func (p *pex) Add(addr *net.TCPAddr) {
+// Add the address to the next PEX message with flags that are sent in "added.f" field.
+func (p *pex) Add(addr *net.TCPAddr, flags byte) {
 	select {
-	case p.pexAddPeerC <- addr:
+	case p.pexAddPeerC <- pexAddr{addr: addr, flags: flags}:
 	case <-p.doneC:
 	}
 }
@@ -83,13 +89,14 @@ func (p *pex) Drop(addr *net.TCPAddr) {
 }
 
 func (p *pex) pexFlushPeers() {
-	added, dropped := p.pexList.Flush()
+	added, addedFlags, dropped := p.pexList.Flush()
 	if len(added) == 0 && len(dropped) == 0 {
 		return
 	}
 	extPEXMsg := peerprotocol.ExtensionPEXMessage{
-		Added:   added,
-		Dropped: dropped,
+		Added:      added,
+		AddedFlags: addedFlags,
+		Dropped:    dropped,
 	}
 	msg := peerprotocol.ExtensionMessage{
 		ExtendedMessageID: p.extID,
@@ -9,7 +9,7 @@ import (
 	"github.com/cenkalti/rain/internal/peerconn/peerreader"
 	"github.com/cenkalti/rain/internal/peerconn/peerwriter"
 	"github.com/cenkalti/rain/internal/peerprotocol"
-	"github.com/juju/ratelimit"
+	"github.com/cenkalti/rain/internal/speedlimit"
 )
 
 // Conn is a peer connection that provides a channel for receiving messages and methods for sending messages.
@@ -24,11 +24,12 @@ type Conn struct {
 }
 
 // New returns a new PeerConn by wrapping a net.Conn.
-func New(conn net.Conn, l logger.Logger, pieceTimeout time.Duration, maxRequestsIn int, fastEnabled bool, br, bw *ratelimit.Bucket) *Conn {
+// If trace is true, all sent and received messages are logged at debug level.
+func New(conn net.Conn, l logger.Logger, pieceTimeout time.Duration, maxRequestsIn int, fastEnabled bool, br, bw *speedlimit.Limiter, trace bool) *Conn {
 	return &Conn{
 		conn:     conn,
-		reader:   peerreader.New(conn, l, pieceTimeout, br),
-		writer:   peerwriter.New(conn, l, maxRequestsIn, fastEnabled, bw),
+		reader:   peerreader.New(conn, l, pieceTimeout, br, trace),
+		writer:   peerwriter.New(conn, l, maxRequestsIn, fastEnabled, bw, trace),
 		messages: make(chan interface{}),
 		log:      l,
 		closeC:   make(chan struct{}),
@@ -14,7 +14,7 @@ import (
 	"github.com/cenkalti/rain/internal/logger"
 	"github.com/cenkalti/rain/internal/peerprotocol"
 	"github.com/cenkalti/rain/internal/piece"
-	"github.com/juju/ratelimit"
+	"github.com/cenkalti/rain/internal/speedlimit"
 )
 
 const (
@@ -34,20 +34,23 @@ type PeerReader struct {
 	r            io.Reader
 	log          logger.Logger
 	pieceTimeout time.Duration
-	bucket       *ratelimit.Bucket
+	bucket       *speedlimit.Limiter
+	trace        bool
 	messages     chan interface{}
 	stopC        chan struct{}
 	doneC        chan struct{}
 }
 
 // New returns a new PeerReader by wrapping a net.Conn.
-func New(conn net.Conn, l logger.Logger, pieceTimeout time.Duration, b *ratelimit.Bucket) *PeerReader {
+// If trace is true, every received message is logged at debug level.
+func New(conn net.Conn, l logger.Logger, pieceTimeout time.Duration, b *speedlimit.Limiter, trace bool) *PeerReader {
 	return &PeerReader{
 		conn:         conn,
 		r:            bufio.NewReaderSize(conn, readBufferSize),
 		log:          l,
 		pieceTimeout: pieceTimeout,
 		bucket:       b,
+		trace:        trace,
 		messages:     make(chan interface{}),
 		stopC:        make(chan struct{}),
 		doneC:        make(chan struct{}),
@@ -250,6 +253,9 @@ func (p *PeerReader) Run() {
 		if msg == nil {
 			panic("msg unset")
 		}
+		if p.trace {
+			p.traceMessage(id, length, msg)
+		}
 		select {
 		case p.messages <- msg:
 		case <-p.stopC:
@@ -258,6 +264,25 @@ func (p *PeerReader) Run() {
 	}
 }
 
+func (p *PeerReader) traceMessage(id peerprotocol.MessageID, length uint32, msg interface{}) {
+	switch m := msg.(type) {
+	case Piece:
+		p.log.Debugf("wire: received %s length=%d index=%d begin=%d", id, length, m.Index, m.Begin)
+	case peerprotocol.RequestMessage:
+		p.log.Debugf("wire: received %s length=%d index=%d begin=%d block=%d", id, length, m.Index, m.Begin, m.Length)
+	case peerprotocol.RejectMessage:
+		p.log.Debugf("wire: received %s length=%d index=%d begin=%d block=%d", id, length, m.Index, m.Begin, m.Length)
+	case peerprotocol.CancelMessage:
+		p.log.Debugf("wire: received %s length=%d index=%d begin=%d block=%d", id, length, m.Index, m.Begin, m.Length)
+	case peerprotocol.HaveMessage:
+		p.log.Debugf("wire: received %s length=%d index=%d", id, length, m.Index)
+	case peerprotocol.AllowedFastMessage:
+		p.log.Debugf("wire: received %s length=%d index=%d", id, length, m.Index)
+	default:
+		p.log.Debugf("wire: received %s length=%d", id, length)
+	}
+}
+
 func (p *PeerReader) readPiece(length uint32) (buf bufferpool.Buffer, err error) {
 	buf = blockPool.Get(int(length))
 	defer func() {
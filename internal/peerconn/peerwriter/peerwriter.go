@@ -11,42 +11,50 @@ import (
 	"github.com/cenkalti/rain/internal/logger"
 	"github.com/cenkalti/rain/internal/peerconn/peerreader"
 	"github.com/cenkalti/rain/internal/peerprotocol"
-	"github.com/juju/ratelimit"
+	"github.com/cenkalti/rain/internal/speedlimit"
 )
 
 const keepAlivePeriod = 2 * time.Minute
 
 // PeerWriter is responsible for writing BitTorrent protocol messages to the peer connection.
+// Control messages are written before queued piece messages so that the remote peer
+// gets choke, have and cancel messages quickly even if the upload to the peer is slow.
 type PeerWriter struct {
 	conn                  net.Conn
 	queueC                chan peerprotocol.Message
 	cancelC               chan peerprotocol.CancelMessage
-	writeQueue            *list.List
+	controlQueue          *list.List
+	pieceQueue            *list.List
 	maxQueuedRequests     int
 	fastEnabled           bool
 	currentQueuedRequests int
+	excessRequests        int
 	writeC                chan peerprotocol.Message
 	messages              chan interface{}
 	servedRequests        map[peerprotocol.RequestMessage]struct{}
-	bucket                *ratelimit.Bucket
+	bucket                *speedlimit.Limiter
+	trace                 bool
 	log                   logger.Logger
 	stopC                 chan struct{}
 	doneC                 chan struct{}
 }
 
 // New returns a new PeerWriter by wrapping a net.Conn.
-func New(conn net.Conn, l logger.Logger, maxQueuedRequests int, fastEnabled bool, b *ratelimit.Bucket) *PeerWriter {
+// If trace is true, every sent message is logged at debug level.
+func New(conn net.Conn, l logger.Logger, maxQueuedRequests int, fastEnabled bool, b *speedlimit.Limiter, trace bool) *PeerWriter {
 	return &PeerWriter{
 		conn:              conn,
 		queueC:            make(chan peerprotocol.Message),
 		cancelC:           make(chan peerprotocol.CancelMessage),
-		writeQueue:        list.New(),
+		controlQueue:      list.New(),
+		pieceQueue:        list.New(),
 		maxQueuedRequests: maxQueuedRequests,
 		fastEnabled:       fastEnabled,
 		writeC:            make(chan peerprotocol.Message),
 		messages:          make(chan interface{}),
 		servedRequests:    make(map[peerprotocol.RequestMessage]struct{}),
 		bucket:            b,
+		trace:             trace,
 		log:               l,
 		stopC:             make(chan struct{}),
 		doneC:             make(chan struct{}),
@@ -103,12 +111,19 @@ func (p *PeerWriter) Run() {
 
 	for {
 		var (
+			q      *list.List
 			e      *list.Element
 			msg    peerprotocol.Message
 			writeC chan peerprotocol.Message
 		)
-		if p.writeQueue.Len() > 0 {
-			e = p.writeQueue.Front()
+		switch {
+		case p.controlQueue.Len() > 0:
+			q = p.controlQueue
+		case p.pieceQueue.Len() > 0:
+			q = p.pieceQueue
+		}
+		if q != nil {
+			e = q.Front()
 			msg = e.Value.(peerprotocol.Message)
 			writeC = p.writeC
 		}
@@ -116,9 +131,9 @@ func (p *PeerWriter) Run() {
 		case msg = <-p.queueC:
 			p.queueMessage(msg)
 		case writeC <- msg:
-			p.writeQueue.Remove(e)
+			q.Remove(e)
 			if _, ok := msg.(Piece); ok {
-				p.currentQueuedRequests--
+				p.dequeuePieces(1)
 			}
 		case cm := <-p.cancelC:
 			p.cancelRequest(cm)
@@ -135,6 +150,16 @@ func (p *PeerWriter) queueMessage(msg peerprotocol.Message) {
 	case Piece:
 		// Reject request if peer queued to many requests
 		if p.currentQueuedRequests >= p.maxQueuedRequests {
+			p.excessRequests++
+			if p.excessRequests == p.maxQueuedRequests+1 {
+				// A well-behaving peer does not request more than the limit we have sent in extension handshake.
+				// Closing the connection makes the reader fail and the peer is disconnected.
+				p.log.Debugln("closing connection, peer is flooding requests")
+				_ = p.conn.Close()
+			}
+			if p.excessRequests > p.maxQueuedRequests {
+				return
+			}
 			if p.fastEnabled {
 				msg = peerprotocol.RejectMessage{RequestMessage: msg2.RequestMessage}
 				break
@@ -144,26 +169,34 @@ func (p *PeerWriter) queueMessage(msg peerprotocol.Message) {
 			}
 		}
 		p.currentQueuedRequests++
+		p.pieceQueue.PushBack(msg)
+		return
 	}
-	p.writeQueue.PushBack(msg)
+	p.controlQueue.PushBack(msg)
 }
 
+// cancelQueuedPieceMessages drops all piece messages that are not written yet.
+// Pieces are not sent after a choke message so there is no need to waste upload for them.
 func (p *PeerWriter) cancelQueuedPieceMessages() {
-	var next *list.Element
-	for e := p.writeQueue.Front(); e != nil; e = next {
-		next = e.Next()
-		if _, ok := e.Value.(Piece); ok {
-			p.writeQueue.Remove(e)
-			p.currentQueuedRequests--
-		}
+	p.dequeuePieces(p.pieceQueue.Len())
+	p.pieceQueue.Init()
+}
+
+// dequeuePieces decrements the queued request count.
+// Excess requests are forgiven once the queue drops under the limit,
+// so only a peer that keeps the queue full while sending more requests gets disconnected.
+func (p *PeerWriter) dequeuePieces(n int) {
+	p.currentQueuedRequests -= n
+	if p.currentQueuedRequests < p.maxQueuedRequests {
+		p.excessRequests = 0
 	}
 }
 
 func (p *PeerWriter) cancelRequest(cm peerprotocol.CancelMessage) {
-	for e := p.writeQueue.Front(); e != nil; e = e.Next() {
-		if pi, ok := e.Value.(Piece); ok && pi.Index == cm.Index && pi.Begin == cm.Begin && pi.Length == cm.Length {
-			p.writeQueue.Remove(e)
-			p.currentQueuedRequests--
+	for e := p.pieceQueue.Front(); e != nil; e = e.Next() {
+		if pi := e.Value.(Piece); pi.Index == cm.Index && pi.Begin == cm.Begin && pi.Length == cm.Length {
+			p.pieceQueue.Remove(e)
+			p.dequeuePieces(1)
 			break
 		}
 	}
@@ -254,6 +287,9 @@ func (p *PeerWriter) messageWriter() {
 				p.log.Errorf("cannot write message [%v]: %s", msg.ID(), err.Error())
 				return
 			}
+			if p.trace {
+				p.traceMessage(msg, uint32(m))
+			}
 		case <-keepAliveTicker.C:
 			_, err := p.conn.Write([]byte{0, 0, 0, 0})
 			if _, ok := err.(*net.OpError); ok {
@@ -270,6 +306,25 @@ func (p *PeerWriter) messageWriter() {
 	}
 }
 
+func (p *PeerWriter) traceMessage(msg peerprotocol.Message, length uint32) {
+	switch m := msg.(type) {
+	case Piece:
+		p.log.Debugf("wire: sent %s length=%d index=%d begin=%d", msg.ID(), length, m.Index, m.Begin)
+	case peerprotocol.RequestMessage:
+		p.log.Debugf("wire: sent %s length=%d index=%d begin=%d block=%d", msg.ID(), length, m.Index, m.Begin, m.Length)
+	case peerprotocol.RejectMessage:
+		p.log.Debugf("wire: sent %s length=%d index=%d begin=%d block=%d", msg.ID(), length, m.Index, m.Begin, m.Length)
+	case peerprotocol.CancelMessage:
+		p.log.Debugf("wire: sent %s length=%d index=%d begin=%d block=%d", msg.ID(), length, m.Index, m.Begin, m.Length)
+	case peerprotocol.HaveMessage:
+		p.log.Debugf("wire: sent %s length=%d index=%d", msg.ID(), length, m.Index)
+	case peerprotocol.AllowedFastMessage:
+		p.log.Debugf("wire: sent %s length=%d index=%d", msg.ID(), length, m.Index)
+	default:
+		p.log.Debugf("wire: sent %s length=%d", msg.ID(), length)
+	}
+}
+
 func (p *PeerWriter) countUploadBytes(n int) {
 	n -= 13 // message + piece header
 	if n < 0 {
@@ -0,0 +1,130 @@
+package peerwriter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+)
+
+func TestControlMessageJumpsAheadOfPieces(t *testing.T) {
+	conn1, conn2 := net.Pipe()
+	defer conn2.Close()
+
+	w := New(conn1, logger.New("test"), 10, true, nil, false)
+	go w.Run()
+	defer func() {
+		w.Stop()
+		<-w.Done()
+	}()
+	go func() {
+		for range w.Messages() {
+		}
+	}()
+
+	data := bytes.NewReader(make([]byte, 16))
+	for i := uint32(0); i < 3; i++ {
+		w.SendPiece(peerprotocol.RequestMessage{Index: i, Length: 16}, data)
+	}
+	w.SendMessage(peerprotocol.CancelMessage{RequestMessage: peerprotocol.RequestMessage{Index: 5, Length: 16}})
+
+	// net.Pipe is synchronous, so at most one piece message can be in flight before the cancel is queued.
+	var ids []peerprotocol.MessageID
+	for len(ids) < 4 {
+		var length uint32
+		if err := binary.Read(conn2, binary.BigEndian, &length); err != nil {
+			t.Fatal(err)
+		}
+		if length == 0 {
+			continue // keep-alive
+		}
+		b := make([]byte, length)
+		if _, err := io.ReadFull(conn2, b); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, peerprotocol.MessageID(b[0]))
+	}
+	if ids[0] != peerprotocol.Cancel && ids[1] != peerprotocol.Cancel {
+		t.Fatalf("cancel message is not sent before queued pieces: %v", ids)
+	}
+}
+
+func TestRequestFlood(t *testing.T) {
+	conn1, conn2 := net.Pipe()
+	defer conn2.Close()
+
+	const maxQueued = 2
+	w := New(conn1, logger.New("test"), maxQueued, true, nil, false)
+	go w.Run()
+	defer func() {
+		w.Stop()
+		<-w.Done()
+	}()
+	go func() {
+		for range w.Messages() {
+		}
+	}()
+
+	// Nothing is read from the connection, so at most one piece is being written and the rest are queued or rejected.
+	data := bytes.NewReader(make([]byte, 16))
+	for i := uint32(0); i < 4*maxQueued; i++ {
+		w.SendPiece(peerprotocol.RequestMessage{Index: i, Length: 16}, data)
+	}
+
+	errC := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.Discard, conn2)
+		errC <- err
+	}()
+	select {
+	case <-errC:
+	case <-time.After(time.Second):
+		t.Fatal("connection is not closed")
+	}
+}
+
+func TestExcessRequestsReset(t *testing.T) {
+	conn1, conn2 := net.Pipe()
+	defer conn2.Close()
+
+	const maxQueued = 2
+	w := New(conn1, logger.New("test"), maxQueued, true, nil, false)
+	go w.Run()
+	defer func() {
+		w.Stop()
+		<-w.Done()
+	}()
+	go func() {
+		for range w.Messages() {
+		}
+	}()
+
+	// Each round exceeds the limit by less than the flood threshold and then drains the queue.
+	// Total of excess requests is over the threshold, but the connection must stay open.
+	data := bytes.NewReader(make([]byte, 16))
+	const perRound = maxQueued + 3
+	for round := 0; round < 3; round++ {
+		for i := uint32(0); i < perRound; i++ {
+			w.SendPiece(peerprotocol.RequestMessage{Index: i, Length: 16}, data)
+		}
+		// Every request is answered with either a piece or a reject message.
+		for n := 0; n < perRound; {
+			var length uint32
+			if err := binary.Read(conn2, binary.BigEndian, &length); err != nil {
+				t.Fatal(err)
+			}
+			if length == 0 {
+				continue // keep-alive
+			}
+			if _, err := io.CopyN(io.Discard, conn2, int64(length)); err != nil {
+				t.Fatal(err)
+			}
+			n++
+		}
+	}
+}
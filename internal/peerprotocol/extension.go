@@ -3,6 +3,7 @@ package peerprotocol
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -17,6 +18,8 @@ const (
 	ExtensionIDMetadata
 	// ExtensionIDPEX is ID for PEX extension messages.
 	ExtensionIDPEX
+	// ExtensionIDHolepunch is ID for holepunch extension messages.
+	ExtensionIDHolepunch
 )
 
 const (
@@ -24,6 +27,8 @@ const (
 	ExtensionKeyMetadata = "ut_metadata"
 	// ExtensionKeyPEX is the key for the PEX extension.
 	ExtensionKeyPEX = "ut_pex"
+	// ExtensionKeyHolepunch is the key for the holepunch extension.
+	ExtensionKeyHolepunch = "ut_holepunch"
 )
 
 const (
@@ -35,6 +40,26 @@ const (
 	ExtensionMetadataMessageTypeReject
 )
 
+const (
+	// ExtensionHolepunchMessageTypeRendezvous is sent to a relay peer to ask for connecting to the target peer.
+	ExtensionHolepunchMessageTypeRendezvous = iota
+	// ExtensionHolepunchMessageTypeConnect is sent by the relay to both peers to make them connect to each other.
+	ExtensionHolepunchMessageTypeConnect
+	// ExtensionHolepunchMessageTypeError is sent by the relay when it cannot relay the rendezvous message.
+	ExtensionHolepunchMessageTypeError
+)
+
+const (
+	// HolepunchErrorNoSuchPeer is sent when the target endpoint is invalid.
+	HolepunchErrorNoSuchPeer = 1 + iota
+	// HolepunchErrorNotConnected is sent when the relay is not connected to the target peer.
+	HolepunchErrorNotConnected
+	// HolepunchErrorNoSupport is sent when the target peer does not support the holepunch extension.
+	HolepunchErrorNoSupport
+	// HolepunchErrorNoSelf is sent when the target endpoint is the relay itself.
+	HolepunchErrorNoSelf
+)
+
 // ExtensionMessage is extension to BitTorrent protocol.
 type ExtensionMessage struct {
 	ExtendedMessageID uint8
@@ -51,6 +76,14 @@ func (m ExtensionMessage) Read([]byte) (int, error) {
 
 // WriteTo writes the bytes into io.Writer.
 func (m ExtensionMessage) WriteTo(w io.Writer) (n int64, err error) {
+	if hm, ok := m.Payload.(ExtensionHolepunchMessage); ok {
+		var b []byte
+		b, err = hm.MarshalBinary()
+		if err != nil {
+			return
+		}
+		return m.writeBinary(w, b)
+	}
 	nn, err := w.Write([]byte{m.ExtendedMessageID})
 	n += int64(nn)
 	if err != nil {
@@ -69,6 +102,17 @@ func (m ExtensionMessage) WriteTo(w io.Writer) (n int64, err error) {
 	return
 }
 
+func (m ExtensionMessage) writeBinary(w io.Writer, b []byte) (n int64, err error) {
+	nn, err := w.Write([]byte{m.ExtendedMessageID})
+	n += int64(nn)
+	if err != nil {
+		return
+	}
+	nn, err = w.Write(b)
+	n += int64(nn)
+	return
+}
+
 // UnmarshalBinary parses extension message.
 func (m *ExtensionMessage) UnmarshalBinary(data []byte) error {
 	var extID uint8
@@ -100,6 +144,10 @@ func (m *ExtensionMessage) UnmarshalBinary(data []byte) error {
 		var extMsg ExtensionPEXMessage
 		err = dec.Decode(&extMsg)
 		m.Payload = extMsg
+	case ExtensionIDHolepunch:
+		var extMsg ExtensionHolepunchMessage
+		err = extMsg.UnmarshalBinary(payload)
+		m.Payload = extMsg
 	default:
 		return fmt.Errorf("peer sent invalid extension message id: %d", m.ExtendedMessageID)
 	}
@@ -139,8 +187,76 @@ type ExtensionMetadataMessage struct {
 
 // ExtensionPEXMessage is the message for the PEX extension.
 type ExtensionPEXMessage struct {
-	Added   string `bencode:"added"`
-	Dropped string `bencode:"dropped"`
+	Added       string `bencode:"added"`
+	AddedFlags  string `bencode:"added.f,omitempty"`
+	Dropped     string `bencode:"dropped"`
+	Added6      string `bencode:"added6,omitempty"`
+	Added6Flags string `bencode:"added6.f,omitempty"`
+	Dropped6    string `bencode:"dropped6,omitempty"`
+}
+
+const (
+	// PEXFlagPreferEncryption is set in "added.f" and "added6.f" for peers that prefer encrypted connections.
+	PEXFlagPreferEncryption = 0x01
+	// PEXFlagSeedOnly is set in "added.f" and "added6.f" for peers that are seeding.
+	PEXFlagSeedOnly = 0x02
+)
+
+// ExtensionHolepunchMessage is the message for the holepunch extension (BEP 55).
+// Unlike other extension messages, it is not bencoded.
+type ExtensionHolepunchMessage struct {
+	Type  uint8
+	Addr  *net.TCPAddr
+	Error uint32
+}
+
+// MarshalBinary encodes the message as: type, address type, address, port, error code.
+func (m ExtensionHolepunchMessage) MarshalBinary() ([]byte, error) {
+	if m.Addr == nil {
+		return nil, errors.New("holepunch message has no address")
+	}
+	ip := truncateIP(m.Addr.IP)
+	var addrType uint8
+	switch len(ip) {
+	case net.IPv4len:
+	case net.IPv6len:
+		addrType = 1
+	default:
+		return nil, fmt.Errorf("invalid ip in holepunch message: %s", m.Addr.IP)
+	}
+	b := make([]byte, 0, 2+len(ip)+2+4)
+	b = append(b, m.Type, addrType)
+	b = append(b, ip...)
+	b = append(b, byte(m.Addr.Port>>8), byte(m.Addr.Port))
+	b = append(b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[len(b)-4:], m.Error)
+	return b, nil
+}
+
+// UnmarshalBinary parses the holepunch message.
+func (m *ExtensionHolepunchMessage) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return errors.New("holepunch message too short")
+	}
+	var ipLen int
+	switch data[1] {
+	case 0:
+		ipLen = net.IPv4len
+	case 1:
+		ipLen = net.IPv6len
+	default:
+		return fmt.Errorf("invalid address type in holepunch message: %d", data[1])
+	}
+	if len(data) != 2+ipLen+2+4 {
+		return fmt.Errorf("invalid holepunch message length: %d", len(data))
+	}
+	m.Type = data[0]
+	ip := make(net.IP, ipLen)
+	copy(ip, data[2:2+ipLen])
+	port := binary.BigEndian.Uint16(data[2+ipLen : 4+ipLen])
+	m.Addr = &net.TCPAddr{IP: ip, Port: int(port)}
+	m.Error = binary.BigEndian.Uint32(data[4+ipLen:])
+	return nil
 }
 
 func truncateIP(ip net.IP) net.IP {
@@ -14,6 +14,10 @@ const (
 	Manual
 	// Incoming indicates that the peer found us. We did not found the peer.
 	Incoming
+	// Resume indicates that the peer is loaded from resume data.
+	Resume
+	// LSD indicates that the peer is found on the local network with Local Service Discovery.
+	LSD
 )
 
 func (s Source) String() string {
@@ -28,6 +32,10 @@ func (s Source) String() string {
 		return "manual"
 	case Incoming:
 		return "incoming"
+	case Resume:
+		return "resume"
+	case LSD:
+		return "lsd"
 	default:
 		panic("unhandled source")
 	}
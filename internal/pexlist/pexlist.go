@@ -8,24 +8,26 @@ import (
 )
 
 const (
+	// MaxPeers is the limit of addresses in a PEX message.
 	// BEP 11: Except for the initial PEX message the combined amount of added v4/v6 contacts should not exceed 50 entries.
 	// The same applies to dropped entries.
-	maxPeers = 50
+	MaxPeers = 50
 )
 
 // PEXList contains the list of peer address for sending them to a peer at certain interval.
 // List contains 2 separate lists for added and dropped addresses.
+// Added addresses are kept with their flags that are sent in "added.f" field.
 type PEXList struct {
-	added   map[tracker.CompactPeer]struct{}
-	dropped map[tracker.CompactPeer]struct{}
+	added   map[tracker.CompactPeer]byte
+	dropped map[tracker.CompactPeer]byte
 	flushed bool
 }
 
 // New returns a new empty PEXList.
 func New() *PEXList {
 	return &PEXList{
-		added:   make(map[tracker.CompactPeer]struct{}),
-		dropped: make(map[tracker.CompactPeer]struct{}),
+		added:   make(map[tracker.CompactPeer]byte),
+		dropped: make(map[tracker.CompactPeer]byte),
 	}
 }
 
@@ -33,42 +35,45 @@ func New() *PEXList {
 func NewWithRecentlySeen(rs []tracker.CompactPeer) *PEXList {
 	l := New()
 	for _, cp := range rs {
-		l.dropped[cp] = struct{}{}
+		l.dropped[cp] = 0
 	}
 	return l
 }
 
 // Add adds the address to the added part and removes from dropped part.
-func (l *PEXList) Add(addr *net.TCPAddr) {
+// If the address is already in the added part, its flags are updated.
+func (l *PEXList) Add(addr *net.TCPAddr, flags byte) {
 	p := tracker.NewCompactPeer(addr)
-	l.added[p] = struct{}{}
+	l.added[p] = flags
 	delete(l.dropped, p)
 }
 
 // Drop adds the address to the dropped part and removes from added part.
 func (l *PEXList) Drop(addr *net.TCPAddr) {
 	peer := tracker.NewCompactPeer(addr)
-	l.dropped[peer] = struct{}{}
+	l.dropped[peer] = 0
 	delete(l.added, peer)
 }
 
 // Flush returns added and dropped parts and empty the list.
-func (l *PEXList) Flush() (added, dropped string) {
-	added = l.flush(l.added, l.flushed)
-	dropped = l.flush(l.dropped, l.flushed)
+// addedFlags contains a flag byte for each address in added.
+func (l *PEXList) Flush() (added, addedFlags, dropped string) {
+	added, addedFlags = l.flush(l.added, l.flushed)
+	dropped, _ = l.flush(l.dropped, l.flushed)
 	l.flushed = true
 	return
 }
 
-func (l *PEXList) flush(m map[tracker.CompactPeer]struct{}, limit bool) string {
+func (l *PEXList) flush(m map[tracker.CompactPeer]byte, limit bool) (peers, flags string) {
 	count := len(m)
-	if limit && count > maxPeers {
-		count = maxPeers
+	if limit && count > MaxPeers {
+		count = MaxPeers
 	}
 
-	var s strings.Builder
+	var s, f strings.Builder
 	s.Grow(count * 6)
-	for p := range m {
+	f.Grow(count)
+	for p, flag := range m {
 		if count == 0 {
 			break
 		}
@@ -79,7 +84,8 @@ func (l *PEXList) flush(m map[tracker.CompactPeer]struct{}, limit bool) string {
 			panic(err)
 		}
 		s.Write(b)
+		f.WriteByte(flag)
 		delete(m, p)
 	}
-	return s.String()
+	return s.String(), f.String()
 }
@@ -0,0 +1,29 @@
+package pexlist
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPEXListFlush(t *testing.T) {
+	l := New()
+	for i := 0; i < 60; i++ {
+		l.Add(newAddr("2.2.2."+strconv.Itoa(i)), 0)
+	}
+	// First message is not limited.
+	added, addedFlags, dropped := l.Flush()
+	assert.Equal(t, 60*6, len(added))
+	assert.Equal(t, 60, len(addedFlags))
+	assert.Equal(t, 0, len(dropped))
+
+	for i := 0; i < 60; i++ {
+		l.Drop(newAddr("2.2.2." + strconv.Itoa(i)))
+	}
+	l.Add(newAddr("1.1.1.1"), 0x02)
+	added, addedFlags, dropped = l.Flush()
+	assert.Equal(t, "\x01\x01\x01\x01\x00\x01", added)
+	assert.Equal(t, "\x02", addedFlags)
+	assert.Equal(t, MaxPeers*6, len(dropped))
+}
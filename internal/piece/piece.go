@@ -14,12 +14,13 @@ const BlockSize = 16 * 1024
 
 // Piece of a torrent.
 type Piece struct {
-	Index   uint32            // index in torrent
-	Length  uint32            // always equal to Info.PieceLength except last piece
-	Data    filesection.Piece // the place to write downloaded bytes
-	Hash    []byte
-	Writing bool
-	Done    bool
+	Index         uint32            // index in torrent
+	Length        uint32            // always equal to Info.PieceLength except last piece
+	Data          filesection.Piece // the place to write downloaded bytes
+	Hash          []byte
+	HashAlgorithm metainfo.HashAlgorithm // algorithm for checking Hash, SHA-1 is used if nil
+	Writing       bool
+	Done          bool
 }
 
 // Block is part of a Piece that is specified in peerprotocol.Request messages.
@@ -56,8 +57,9 @@ func NewPieces(info *metainfo.Info, files []allocator.File) []Piece {
 	pieces := make([]Piece, info.NumPieces)
 	for i := uint32(0); i < info.NumPieces; i++ {
 		p := Piece{
-			Index: i,
-			Hash:  info.PieceHash(i),
+			Index:         i,
+			Hash:          info.PieceHash(i),
+			HashAlgorithm: info.PieceHashAlgorithm(i),
 		}
 
 		var sections filesection.Piece
@@ -146,6 +148,14 @@ func (p *Piece) FindBlock(begin, length uint32) (b Block, ok bool) {
 	return b, true
 }
 
+// NewHash returns a new hash.Hash for calculating the hash of piece data.
+func (p *Piece) NewHash() hash.Hash {
+	if p.HashAlgorithm == nil {
+		return metainfo.SHA1.New()
+	}
+	return p.HashAlgorithm.New()
+}
+
 // VerifyHash returns true if hash of piece data in buffer `buf` matches the hash of Piece.
 func (p *Piece) VerifyHash(buf []byte, h hash.Hash) bool {
 	if uint32(len(buf)) != p.Length {
@@ -1,8 +1,17 @@
 package piece
 
 import (
+	"crypto/rand"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/cenkalti/rain/internal/allocator"
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/metainfo"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -79,3 +88,87 @@ func TestFindBlock(t *testing.T) {
 	assert.True(t, ok)
 	assert.Equal(t, Block{Index: 2, Begin: 2 * BlockSize, Length: 42}, b)
 }
+
+type memFile struct {
+	data []byte
+}
+
+func (f *memFile) ReadAt(b []byte, off int64) (int, error) {
+	return copy(b, f.data[off:]), nil
+}
+
+func (f *memFile) WriteAt(b []byte, off int64) (int, error) {
+	return copy(f.data[off:], b), nil
+}
+
+func (f *memFile) Close() error { return nil }
+
+func TestPieceSpanningManyFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rain-piece-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Files are smaller than the piece length so a single piece contains many of them.
+	// The last piece is shorter than the piece length.
+	lengths := []int{3000, 7000, 1, 0, 5000, 9000, 2500, 12000, 4096, 1000}
+	var content []byte
+	for i, l := range lengths {
+		b := make([]byte, l)
+		_, _ = rand.Read(b)
+		content = append(content, b...)
+		err = ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("f%02d", i)), b, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ib, err := metainfo.NewInfoBytes("", []string{dir}, false, BlockSize, "test", logger.New("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := metainfo.NewInfo(ib, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make([]allocator.File, len(info.Files))
+	mems := make([]*memFile, len(info.Files))
+	for i, f := range info.Files {
+		mems[i] = &memFile{data: make([]byte, f.Length)}
+		files[i] = allocator.File{Storage: mems[i], Name: f.Path}
+	}
+	pieces := NewPieces(info, files)
+	assert.Equal(t, int(info.NumPieces), len(pieces))
+	assert.Greater(t, len(pieces[0].Data), 3)
+
+	// Write pieces as they are downloaded.
+	for i := range pieces {
+		p := &pieces[i]
+		begin := int(p.Index) * int(info.PieceLength)
+		data := content[begin : begin+int(p.Length)]
+		assert.True(t, p.VerifyHash(data, sha1.New()))
+		n, err := p.Data.Write(data)
+		assert.NoError(t, err)
+		assert.Equal(t, len(data), n)
+	}
+	for i, l := range lengths {
+		begin := 0
+		for _, x := range lengths[:i] {
+			begin += x
+		}
+		assert.Equal(t, content[begin:begin+l], mems[i].data)
+	}
+
+	// Read blocks of pieces as they are uploaded.
+	for i := range pieces {
+		p := &pieces[i]
+		begin := int(p.Index) * int(info.PieceLength)
+		for off := 0; off < int(p.Length); off += 1000 {
+			b := make([]byte, minInt64(1000, int64(int(p.Length)-off)))
+			n, err := p.Data.ReadAt(b, int64(off))
+			assert.NoError(t, err)
+			assert.Equal(t, len(b), n)
+			assert.Equal(t, content[begin+off:begin+off+len(b)], b)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package piecedownloader
 
 import (
 	"errors"
+	"sort"
+	"time"
 
 	"github.com/cenkalti/rain/internal/bufferpool"
 	"github.com/cenkalti/rain/internal/piece"
@@ -12,6 +14,9 @@ var (
 	ErrBlockDuplicate = errors.New("received duplicate block")
 	// ErrBlockNotRequested is returned from PieceDownloader.GotBlock method when the received block is not requested yet.
 	ErrBlockNotRequested = errors.New("received not requested block")
+	// ErrBlockCancelled is returned from PieceDownloader.GotBlock method when the request of the block is cancelled
+	// because it is received from another peer before.
+	ErrBlockCancelled = errors.New("received cancelled block")
 )
 
 // PieceDownloader downloads all blocks of a piece from a peer.
@@ -22,8 +27,12 @@ type PieceDownloader struct {
 	Buffer      bufferpool.Buffer
 
 	remaining []int
-	pending   map[int]struct{} // in-flight requests
-	done      map[int]struct{} // downloaded requests
+	pending   map[int]time.Time // in-flight requests with the time they are sent
+	done      map[int]struct{}  // downloaded requests
+	cancelled map[int]struct{}  // requests cancelled because the block is received from another peer
+
+	lastLatency time.Duration
+	mixed       bool
 }
 
 // Peer of a Torrent.
@@ -45,11 +54,45 @@ func New(pi *piece.Piece, pe Peer, allowedFast bool, buf bufferpool.Buffer) *Pie
 		AllowedFast: allowedFast,
 		Buffer:      buf,
 		remaining:   remaining,
-		pending:     make(map[int]struct{}),
+		pending:     make(map[int]time.Time),
 		done:        make(map[int]struct{}),
+		cancelled:   make(map[int]struct{}),
 	}
 }
 
+// Resume marks the blocks as downloaded. Data of the blocks must be already present in the buffer.
+// Used when continuing a piece that was partially downloaded before the torrent is stopped.
+func (d *PieceDownloader) Resume(blocks []int) {
+	for _, i := range blocks {
+		if i < 0 || i >= d.Piece.NumBlocks() {
+			continue
+		}
+		d.done[i] = struct{}{}
+	}
+	remaining := d.remaining[:0]
+	for _, i := range d.remaining {
+		if _, ok := d.done[i]; !ok {
+			remaining = append(remaining, i)
+		}
+	}
+	d.remaining = remaining
+}
+
+// DoneBlocks returns the sorted indexes of blocks that has been downloaded.
+func (d *PieceDownloader) DoneBlocks() []int {
+	blocks := make([]int, 0, len(d.done))
+	for i := range d.done {
+		blocks = append(blocks, i)
+	}
+	sort.Ints(blocks)
+	return blocks
+}
+
+// Progress returns the number of downloaded blocks and the total number of blocks in the piece.
+func (d *PieceDownloader) Progress() (done, total int) {
+	return len(d.done), d.Piece.NumBlocks()
+}
+
 // Choked must be called when the peer has choked us. This will cancel pending reuqests.
 func (d *PieceDownloader) Choked() {
 	if d.AllowedFast {
@@ -68,10 +111,16 @@ func (d *PieceDownloader) Choked() {
 // GotBlock must be called when a block is received from the piece.
 func (d *PieceDownloader) GotBlock(block piece.Block, data []byte) error {
 	var err error
-	if _, ok := d.done[block.Index]; ok {
+	if _, ok := d.cancelled[block.Index]; ok {
+		// Peer has sent the block before receiving our cancel message.
+		delete(d.cancelled, block.Index)
+		return ErrBlockCancelled
+	} else if _, ok := d.done[block.Index]; ok {
 		return ErrBlockDuplicate
-	} else if _, ok := d.pending[block.Index]; !ok {
+	} else if requestedAt, ok := d.pending[block.Index]; !ok {
 		err = ErrBlockNotRequested
+	} else {
+		d.lastLatency = time.Since(requestedAt)
 	}
 	copy(d.Buffer.Data[block.Begin:block.Begin+block.Length], data)
 	delete(d.pending, block.Index)
@@ -79,8 +128,44 @@ func (d *PieceDownloader) GotBlock(block piece.Block, data []byte) error {
 	return err
 }
 
+// GotBlockFromOther must be called when a block of the piece is received by another downloader of the same piece.
+// Data of the block is copied into the buffer and the request of the block is cancelled if it is pending.
+func (d *PieceDownloader) GotBlockFromOther(block piece.Block, data []byte) {
+	if _, ok := d.done[block.Index]; ok {
+		return
+	}
+	if _, ok := d.pending[block.Index]; ok {
+		d.Peer.CancelPiece(d.Piece.Index, block.Begin, block.Length)
+		delete(d.pending, block.Index)
+		d.cancelled[block.Index] = struct{}{}
+	} else {
+		for i, j := range d.remaining {
+			if j == block.Index {
+				d.remaining = append(d.remaining[:i], d.remaining[i+1:]...)
+				break
+			}
+		}
+	}
+	copy(d.Buffer.Data[block.Begin:block.Begin+block.Length], data)
+	d.done[block.Index] = struct{}{}
+	d.mixed = true
+}
+
+// Mixed returns true if some of the blocks are received by other downloaders of the piece.
+func (d *PieceDownloader) Mixed() bool {
+	return d.mixed
+}
+
 // Rejected must be called when the peer has rejected a piece request.
 func (d *PieceDownloader) Rejected(block piece.Block) {
+	if _, ok := d.cancelled[block.Index]; ok {
+		// Peer rejects the request after receiving our cancel message.
+		delete(d.cancelled, block.Index)
+		return
+	}
+	if _, ok := d.done[block.Index]; ok {
+		return
+	}
 	delete(d.pending, block.Index)
 	d.remaining = append(d.remaining, block.Index)
 }
@@ -112,10 +197,20 @@ func (d *PieceDownloader) RequestBlocks(queueLength int) {
 			d.Peer.RequestPiece(d.Piece.Index, b.Begin, b.Length)
 		}
 		d.remaining = d.remaining[1:]
-		d.pending[i] = struct{}{}
+		d.pending[i] = time.Now()
 	}
 }
 
+// Pending returns the number of in-flight requests.
+func (d *PieceDownloader) Pending() int {
+	return len(d.pending)
+}
+
+// LastLatency returns the duration between sending the request and receiving the last block.
+func (d *PieceDownloader) LastLatency() time.Duration {
+	return d.lastLatency
+}
+
 // Done returns true if all blocks of the piece has been downloaded.
 func (d *PieceDownloader) Done() bool {
 	return len(d.done) == d.Piece.NumBlocks()
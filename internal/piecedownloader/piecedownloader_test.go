@@ -2,6 +2,7 @@ package piecedownloader
 
 import (
 	"testing"
+	"time"
 
 	"github.com/cenkalti/rain/internal/bufferpool"
 	"github.com/cenkalti/rain/internal/peerprotocol"
@@ -145,3 +146,82 @@ func TestPieceDownloader(t *testing.T) {
 	assert.Equal(t, 11, len(d.done))
 	assert.True(t, d.Done())
 }
+
+func TestPieceDownloaderLatency(t *testing.T) {
+	bp := bufferpool.New(2 * blockSize)
+	buf := bp.Get(2 * blockSize)
+	pi := &piece.Piece{
+		Index:  1,
+		Length: 2 * blockSize,
+	}
+	d := New(pi, &TestPeer{}, false, buf)
+	d.RequestBlocks(2)
+	assert.Equal(t, 2, d.Pending())
+
+	time.Sleep(10 * time.Millisecond)
+	err := d.GotBlock(piece.Block{Index: 0, Begin: 0, Length: blockSize}, make([]byte, blockSize))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, d.Pending())
+	assert.True(t, d.LastLatency() >= 10*time.Millisecond)
+}
+
+func TestPieceDownloaderResume(t *testing.T) {
+	bp := bufferpool.New(4 * blockSize)
+	buf := bp.Get(4 * blockSize)
+	pi := &piece.Piece{
+		Index:  1,
+		Length: 4 * blockSize,
+	}
+	pe := &TestPeer{}
+	d := New(pi, pe, false, buf)
+	d.Resume([]int{0, 2, 99})
+	assert.Equal(t, []int{0, 2}, d.DoneBlocks())
+	assert.Equal(t, []int{1, 3}, d.remaining)
+
+	d.RequestBlocks(4)
+	assert.Equal(t, 2, len(pe.requested))
+	assert.Nil(t, d.GotBlock(piece.Block{Index: 1, Begin: blockSize, Length: blockSize}, make([]byte, blockSize)))
+	assert.Nil(t, d.GotBlock(piece.Block{Index: 3, Begin: 3 * blockSize, Length: blockSize}, make([]byte, blockSize)))
+	assert.True(t, d.Done())
+}
+
+func TestPieceDownloaderGotBlockFromOther(t *testing.T) {
+	bp := bufferpool.New(3 * blockSize)
+	pi := &piece.Piece{
+		Index:  1,
+		Length: 3 * blockSize,
+	}
+	pe1, pe2 := &TestPeer{}, &TestPeer{}
+	d1 := New(pi, pe1, false, bp.Get(3*blockSize))
+	d2 := New(pi, pe2, false, bp.Get(3*blockSize))
+	d1.RequestBlocks(2)
+	d2.RequestBlocks(2)
+
+	// Block is received from the first peer. Request on the second peer is cancelled.
+	block0 := piece.Block{Index: 0, Begin: 0, Length: blockSize}
+	data := make([]byte, blockSize)
+	data[0] = 42
+	assert.Nil(t, d1.GotBlock(block0, data))
+	d2.GotBlockFromOther(block0, data)
+	assert.Equal(t, []Message{{Index: 1, Begin: 0, Length: blockSize}}, pe2.canceled)
+	assert.Equal(t, byte(42), d2.Buffer.Data[0])
+	assert.Equal(t, 1, d2.Pending())
+	assert.True(t, d2.Mixed())
+	assert.False(t, d1.Mixed())
+
+	// Second peer sends the block before receiving the cancel, or rejects the request.
+	assert.Equal(t, ErrBlockCancelled, d2.GotBlock(block0, data))
+	d2.Rejected(block0)
+	assert.Equal(t, 1, d2.Pending())
+
+	// Block that is not requested yet is not requested from the second peer.
+	block2 := piece.Block{Index: 2, Begin: 2 * blockSize, Length: blockSize}
+	d2.GotBlockFromOther(block2, data)
+	assert.Equal(t, 1, len(pe2.canceled))
+	d2.RequestBlocks(2)
+	assert.Equal(t, 2, len(pe2.requested))
+
+	block1 := piece.Block{Index: 1, Begin: blockSize, Length: blockSize}
+	assert.Nil(t, d2.GotBlock(block1, data))
+	assert.True(t, d2.Done())
+}
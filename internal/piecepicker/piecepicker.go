@@ -3,6 +3,7 @@ package piecepicker
 import (
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/cenkalti/rain/internal/peer"
 	"github.com/cenkalti/rain/internal/peerset"
@@ -16,6 +17,9 @@ import (
 These are the things to consider when selecting a piece for downloading:
 
   * Piece is done (hash checked and written to disk)
+  * Piece is skipped (all of its files are not wanted)
+  * Piece has high priority (one of its files has high priority)
+  * Piece has a deadline (must be downloaded before a certain time, e.g. for streaming)
   * Piece is writing
   * Peer has the piece
   * Peer is choking us
@@ -36,9 +40,13 @@ type PiecePicker struct {
 	pieces               []myPiece
 	piecesByAvailability []*myPiece
 	piecesByStalled      []*myPiece
+	piecesByDeadline     []*myPiece
 	maxDuplicateDownload int
+	endgameThreshold     int
 	available            uint32
 	endgame              bool
+	sequential           bool
+	sequentialWindow     uint32
 }
 
 type myPiece struct {
@@ -50,6 +58,15 @@ type myPiece struct {
 
 	// Downloading from webseed source or marked to be downloaded later.
 	RequestedWebseed *webseedsource.WebseedSource
+
+	// Not downloaded because all files in the piece are skipped.
+	Skipped bool
+
+	// Picked before other pieces because one of the files in the piece has high priority.
+	High bool
+
+	// Picked before all other pieces, nearest deadline first. Zero if the piece has no deadline.
+	Deadline time.Time
 }
 
 // RunningDownloads returns the number of pieces that are being downloaded actively.
@@ -66,7 +83,7 @@ func (p *myPiece) StalledDownloads() int {
 // AvailableForWebseed returns true if the piece can be downloaded from a webseed source.
 // If the piece is already requested from a peer, it does not become eligible for downloading from webseed until entering the endgame mode.
 func (p *myPiece) AvailableForWebseed(duplicate bool) bool {
-	if p.Done || p.Writing || p.RequestedWebseed != nil {
+	if p.Done || p.Writing || p.Skipped || p.RequestedWebseed != nil {
 		return false
 	}
 	if !duplicate {
@@ -76,7 +93,9 @@ func (p *myPiece) AvailableForWebseed(duplicate bool) bool {
 }
 
 // New returns a new PiecePicker.
-func New(pieces []piece.Piece, maxDuplicateDownload int, webseedSources []*webseedsource.WebseedSource) *PiecePicker {
+// Endgame mode is activated when all missing pieces are requested or
+// the number of missing pieces is not more than endgameThreshold.
+func New(pieces []piece.Piece, maxDuplicateDownload, endgameThreshold int, webseedSources []*webseedsource.WebseedSource) *PiecePicker {
 	ps := make([]myPiece, len(pieces))
 	for i := range pieces {
 		ps[i] = myPiece{Piece: &pieces[i]}
@@ -92,6 +111,7 @@ func New(pieces []piece.Piece, maxDuplicateDownload int, webseedSources []*webse
 		piecesByAvailability: sps,
 		piecesByStalled:      sps2,
 		maxDuplicateDownload: maxDuplicateDownload,
+		endgameThreshold:     endgameThreshold,
 		webseedSources:       webseedSources,
 	}
 }
@@ -131,6 +151,47 @@ func (p *PiecePicker) WebseedStopAt(src *webseedsource.WebseedSource, i uint32)
 	return false
 }
 
+// SetSkipped sets whether the piece at index i is excluded from downloading.
+func (p *PiecePicker) SetSkipped(i uint32, value bool) {
+	p.pieces[i].Skipped = value
+}
+
+// SetSequential sets whether the pieces are downloaded in order, e.g. for streaming.
+// When enabled, the first `window` needed pieces are picked in order of their indexes and
+// the pieces after the window are picked rarest first. If window is 0, all pieces are picked in order.
+func (p *PiecePicker) SetSequential(value bool, window uint32) {
+	p.sequential = value
+	p.sequentialWindow = window
+}
+
+// SetHigh sets whether the piece at index i is picked before the pieces with normal priority.
+func (p *PiecePicker) SetHigh(i uint32, value bool) {
+	p.pieces[i].High = value
+}
+
+// SetDeadline sets the time that the piece at index i must be downloaded before.
+// Pieces with a deadline are picked before the others, in order of their deadlines. Zero value clears the deadline.
+func (p *PiecePicker) SetDeadline(i uint32, deadline time.Time) {
+	mp := &p.pieces[i]
+	if mp.Deadline.Equal(deadline) {
+		return
+	}
+	if mp.Deadline.IsZero() {
+		p.piecesByDeadline = append(p.piecesByDeadline, mp)
+	} else if deadline.IsZero() {
+		for j, mp2 := range p.piecesByDeadline {
+			if mp2 == mp {
+				p.piecesByDeadline = append(p.piecesByDeadline[:j], p.piecesByDeadline[j+1:]...)
+				break
+			}
+		}
+	}
+	mp.Deadline = deadline
+	sort.SliceStable(p.piecesByDeadline, func(i, j int) bool {
+		return p.piecesByDeadline[i].Deadline.Before(p.piecesByDeadline[j].Deadline)
+	})
+}
+
 // Available returns the number of available pieces among the swarm.
 func (p *PiecePicker) Available() uint32 {
 	return p.available
@@ -182,6 +243,14 @@ func (p *PiecePicker) HandleCancelDownload(pe *peer.Peer, i uint32) {
 	p.pieces[i].Snubbed.Remove(pe)
 }
 
+// HandleReject must be called when the peer has rejected all requests for the piece while unchoking us.
+// The piece is not picked for the peer again until it sends a Have message for the piece.
+func (p *PiecePicker) HandleReject(pe *peer.Peer, i uint32) {
+	p.HandleCancelDownload(pe, i)
+	p.removeHavingPeer(int(i), pe)
+	pe.ReceivedAllowedFast.Remove(p.pieces[i].Piece)
+}
+
 // HandleDisconnect must be called to remove the peer from internal indexes.
 func (p *PiecePicker) HandleDisconnect(pe *peer.Peer) {
 	for i := range p.pieces {
@@ -243,10 +312,25 @@ func (p *PiecePicker) findPiece(pe *peer.Peer) (mp *myPiece, allowedFast bool) {
 	if pe.PeerChoking {
 		return nil, false
 	}
+	// Pick the piece with the nearest deadline
+	pi = p.pickDeadline(pe)
+	if pi != nil {
+		return pi, false
+	}
+	if !p.endgame && p.endgameThreshold > 0 && p.numMissing() <= p.endgameThreshold {
+		p.endgame = true
+	}
 	// Short path for endgame mode.
 	if p.endgame {
 		return p.pickEndgame(pe), false
 	}
+	// Pick next piece in order
+	if p.sequential {
+		pi = p.pickSequential(pe)
+		if pi != nil {
+			return pi, false
+		}
+	}
 	// Pieck rarest piece
 	pi = p.pickRarest(pe)
 	if pi != nil {
@@ -263,9 +347,44 @@ func (p *PiecePicker) findPiece(pe *peer.Peer) (mp *myPiece, allowedFast bool) {
 func (p *PiecePicker) pickAllowedFast(pe *peer.Peer) *myPiece {
 	for _, pi := range pe.ReceivedAllowedFast.Pieces {
 		mp := &p.pieces[pi.Index]
+		if mp.Done || mp.Writing || mp.Skipped {
+			continue
+		}
+		if mp.Requested.Len() == 0 && mp.Having.Has(pe) {
+			return mp
+		}
+	}
+	return nil
+}
+
+func (p *PiecePicker) pickDeadline(pe *peer.Peer) *myPiece {
+	for _, mp := range p.piecesByDeadline {
 		if mp.Done || mp.Writing {
 			continue
 		}
+		if !mp.Having.Has(pe) {
+			continue
+		}
+		// Request again from another peer if the running downloads are stalled, so the deadline is not missed.
+		if mp.Requested.Len() == 0 || (mp.RunningDownloads() == 0 && mp.Requested.Len() < p.maxDuplicateDownload) {
+			return mp
+		}
+	}
+	return nil
+}
+
+func (p *PiecePicker) pickSequential(pe *peer.Peer) *myPiece {
+	// Number of needed pieces seen, starting from the first one.
+	var n uint32
+	for i := range p.pieces {
+		mp := &p.pieces[i]
+		if mp.Done || mp.Writing || mp.Skipped {
+			continue
+		}
+		if p.sequentialWindow > 0 && n >= p.sequentialWindow {
+			break
+		}
+		n++
 		if mp.Requested.Len() == 0 && mp.Having.Has(pe) {
 			return mp
 		}
@@ -274,15 +393,19 @@ func (p *PiecePicker) pickAllowedFast(pe *peer.Peer) *myPiece {
 }
 
 func (p *PiecePicker) pickRarest(pe *peer.Peer) *myPiece {
-	// Sort by rarity
+	// Sort by priority, then by rarity
 	sort.Slice(p.piecesByAvailability, func(i, j int) bool {
-		return len(p.piecesByAvailability[i].Having.Peers) < len(p.piecesByAvailability[j].Having.Peers)
+		a, b := p.piecesByAvailability[i], p.piecesByAvailability[j]
+		if a.High != b.High {
+			return a.High
+		}
+		return len(a.Having.Peers) < len(b.Having.Peers)
 	})
 	var picked *myPiece
 	var hasUnrequested bool
 	// Select unrequested piece
 	for _, mp := range p.piecesByAvailability {
-		if mp.Done || mp.Writing {
+		if mp.Done || mp.Writing || mp.Skipped {
 			continue
 		}
 		if mp.Requested.Len() == 0 && mp.Having.Has(pe) {
@@ -299,6 +422,18 @@ func (p *PiecePicker) pickRarest(pe *peer.Peer) *myPiece {
 	return picked
 }
 
+// numMissing returns the number of pieces that are not downloaded yet, excluding skipped pieces.
+func (p *PiecePicker) numMissing() int {
+	var n int
+	for i := range p.pieces {
+		mp := &p.pieces[i]
+		if !mp.Done && !mp.Skipped {
+			n++
+		}
+	}
+	return n
+}
+
 func (p *PiecePicker) pickEndgame(pe *peer.Peer) *myPiece {
 	// Sort by request count
 	sort.Slice(p.piecesByAvailability, func(i, j int) bool {
@@ -306,7 +441,7 @@ func (p *PiecePicker) pickEndgame(pe *peer.Peer) *myPiece {
 	})
 	// Select unrequested piece
 	for _, mp := range p.piecesByAvailability {
-		if mp.Done || mp.Writing {
+		if mp.Done || mp.Writing || mp.Skipped {
 			continue
 		}
 		if mp.Requested.Len() < p.maxDuplicateDownload && mp.Having.Has(pe) {
@@ -323,7 +458,7 @@ func (p *PiecePicker) pickStalled(pe *peer.Peer) *myPiece {
 	})
 	// Select unrequested piece
 	for _, mp := range p.piecesByStalled {
-		if mp.Done || mp.Writing {
+		if mp.Done || mp.Writing || mp.Skipped {
 			continue
 		}
 		if mp.RunningDownloads() > 0 {
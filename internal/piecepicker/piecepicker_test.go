@@ -2,6 +2,7 @@ package piecepicker
 
 import (
 	"testing"
+	"time"
 
 	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/peer"
@@ -26,7 +27,7 @@ func TestPiecePicker(t *testing.T) {
 	pieces[0].Done = true
 	pieces[2].Done = true
 	pieces[3].Done = true
-	pp := New(pieces, 2, nil)
+	pp := New(pieces, 2, 0, nil)
 	pp.HandleHave(peers[0], 1)
 	pp.HandleHave(peers[0], 3)
 	pp.HandleHave(peers[0], 4)
@@ -84,3 +85,106 @@ func (p *PiecePicker) pickFor(pe *peer.Peer) *piece.Piece {
 	pi, _ := p.PickFor(pe)
 	return pi
 }
+
+func TestPiecePickerSequential(t *testing.T) {
+	pieces := make([]piece.Piece, numPieces)
+	for i := range pieces {
+		pieces[i] = newPiece(i)
+	}
+	pieces[0].Done = true
+	pp := New(pieces, 2, 0, nil)
+	pp.SetSequential(true, 3)
+	pe := newPeer(0)
+	for i := 1; i < numPieces; i++ {
+		pp.HandleHave(pe, uint32(i))
+	}
+	// Piece 6 is the rarest.
+	pe2 := newPeer(1)
+	for i := 1; i < numPieces-1; i++ {
+		pp.HandleHave(pe2, uint32(i))
+	}
+	for _, i := range []int{1, 2, 3} {
+		assert.Equal(t, &pieces[i], pp.pickFor(pe))
+	}
+	// Pieces after the window are picked rarest first.
+	assert.Equal(t, &pieces[6], pp.pickFor(pe))
+}
+
+func TestPiecePickerPriority(t *testing.T) {
+	pieces := make([]piece.Piece, numPieces)
+	for i := range pieces {
+		pieces[i] = newPiece(i)
+	}
+	pp := New(pieces, 1, 0, nil)
+	pp.SetSkipped(0, true)
+	pp.SetHigh(5, true)
+	pe := newPeer(0)
+	for i := 0; i < numPieces; i++ {
+		pp.HandleHave(pe, uint32(i))
+	}
+	// Piece 1 is the rarest.
+	pe2 := newPeer(1)
+	for i := 2; i < numPieces; i++ {
+		pp.HandleHave(pe2, uint32(i))
+	}
+	assert.Equal(t, &pieces[5], pp.pickFor(pe))
+	assert.Equal(t, &pieces[1], pp.pickFor(pe))
+	for i := 2; i < numPieces-1; i++ {
+		assert.NotNil(t, pp.pickFor(pe))
+	}
+	// Skipped piece is never requested.
+	assert.Nil(t, pp.pickFor(pe))
+}
+
+func TestPiecePickerEndgameThreshold(t *testing.T) {
+	pieces := make([]piece.Piece, numPieces)
+	for i := range pieces {
+		pieces[i] = newPiece(i)
+	}
+	pp := New(pieces, 2, 3, nil)
+	pe := newPeer(0)
+	for i := 0; i < numPieces; i++ {
+		pp.HandleHave(pe, uint32(i))
+	}
+	pieces[0].Done = true
+	pieces[1].Done = true
+	pp.SetSkipped(2, true)
+	assert.NotNil(t, pp.pickFor(pe))
+	assert.False(t, pp.endgame)
+
+	pieces[3].Done = true
+	assert.NotNil(t, pp.pickFor(pe))
+	assert.True(t, pp.endgame)
+}
+
+func TestPiecePickerDeadline(t *testing.T) {
+	pieces := make([]piece.Piece, numPieces)
+	for i := range pieces {
+		pieces[i] = newPiece(i)
+	}
+	pp := New(pieces, 2, 0, nil)
+	pp.SetSequential(true, 0)
+	pp.SetHigh(1, true)
+	pe := newPeer(0)
+	for i := 0; i < numPieces; i++ {
+		pp.HandleHave(pe, uint32(i))
+	}
+	now := time.Now()
+	pp.SetDeadline(4, now.Add(2*time.Second))
+	pp.SetDeadline(6, now.Add(time.Second))
+	pp.SetDeadline(5, now.Add(3*time.Second))
+	pp.SetDeadline(5, time.Time{})
+	// Deadlines outrank sequential and high priority pieces.
+	assert.Equal(t, &pieces[6], pp.pickFor(pe))
+	assert.Equal(t, &pieces[4], pp.pickFor(pe))
+	assert.Equal(t, &pieces[0], pp.pickFor(pe))
+
+	// Stalled download of a deadline piece is requested from another peer.
+	pp.SetDeadline(3, now.Add(time.Second))
+	assert.Equal(t, &pieces[3], pp.pickFor(pe))
+	pe2 := newPeer(1)
+	pp.HandleHave(pe2, 3)
+	assert.Nil(t, pp.pickFor(pe2))
+	pp.HandleSnubbed(pe, 3)
+	assert.Equal(t, &pieces[3], pp.pickFor(pe2))
+}
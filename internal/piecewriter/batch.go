@@ -0,0 +1,77 @@
+package piecewriter
+
+import (
+	"sort"
+
+	"github.com/cenkalti/rain/internal/filesection"
+	"github.com/cenkalti/rain/internal/semaphore"
+	"github.com/rcrowley/go-metrics"
+)
+
+// Batch writes the data of hash checked pieces to disk together.
+// Pieces are written in the order of their indexes so that files are written sequentially.
+type Batch struct {
+	Writers []*PieceWriter
+	doneC   chan struct{}
+}
+
+// NewBatch returns a new Batch for writing the pieces of given writers.
+func NewBatch(writers []*PieceWriter) *Batch {
+	return &Batch{
+		Writers: writers,
+		doneC:   make(chan struct{}),
+	}
+}
+
+// Done returns a channel that is closed after the pieces are written by Run.
+func (b *Batch) Done() <-chan struct{} {
+	return b.doneC
+}
+
+// Run writes the pieces in the batch, then sends the batch to resultC.
+func (b *Batch) Run(resultC chan *Batch, closeC chan struct{}, writesPerSecond, writeBytesPerSecond metrics.Meter, sem *semaphore.Semaphore) {
+	b.Write(writesPerSecond, writeBytesPerSecond, sem)
+	close(b.doneC)
+	select {
+	case resultC <- b:
+	case <-closeC:
+	}
+}
+
+// Write the pieces in the batch to disk and sync the files.
+// The result of each piece is set to the Error field of its writer.
+func (b *Batch) Write(writesPerSecond, writeBytesPerSecond metrics.Meter, sem *semaphore.Semaphore) {
+	sort.Slice(b.Writers, func(i, j int) bool { return b.Writers[i].Piece.Index < b.Writers[j].Piece.Index })
+	sem.Wait()
+	defer sem.Signal()
+	files := make(map[filesection.ReadWriterAt][]*PieceWriter)
+	for _, w := range b.Writers {
+		writesPerSecond.Mark(1)
+		writeBytesPerSecond.Mark(int64(len(w.Buffer.Data)))
+		_, w.Error = w.Piece.Data.Write(w.Buffer.Data)
+		if w.Error != nil {
+			continue
+		}
+		for _, sec := range w.Piece.Data {
+			files[sec.File] = append(files[sec.File], w)
+		}
+	}
+	// A piece must not be reported as written while its data is only in the OS cache.
+	for f, writers := range files {
+		s, ok := f.(syncer)
+		if !ok {
+			continue
+		}
+		if err := s.Sync(); err != nil {
+			for _, w := range writers {
+				if w.Error == nil {
+					w.Error = err
+				}
+			}
+		}
+	}
+}
+
+type syncer interface {
+	Sync() error
+}
@@ -1,8 +1,6 @@
 package piecewriter
 
 import (
-	"crypto/sha1"
-
 	"github.com/cenkalti/rain/internal/bufferpool"
 	"github.com/cenkalti/rain/internal/piece"
 	"github.com/cenkalti/rain/internal/semaphore"
@@ -14,24 +12,29 @@ type PieceWriter struct {
 	Piece  *piece.Piece
 	Source interface{}
 	Buffer bufferpool.Buffer
+	Verify bool
+	// If true, only the hash is checked. Data is written later in a Batch.
+	Defer bool
 
 	HashOK bool
 	Error  error
 }
 
 // New returns new PieceWriter for a given piece.
-func New(p *piece.Piece, source interface{}, buf bufferpool.Buffer) *PieceWriter {
+// If verify is false, the data is written without checking the hash.
+func New(p *piece.Piece, source interface{}, buf bufferpool.Buffer, verify bool) *PieceWriter {
 	return &PieceWriter{
 		Piece:  p,
 		Source: source,
 		Buffer: buf,
+		Verify: verify,
 	}
 }
 
 // Run checks the hash, then writes the data in the buffer to the disk.
 func (w *PieceWriter) Run(resultC chan *PieceWriter, closeC chan struct{}, writesPerSecond, writeBytesPerSecond metrics.Meter, sem *semaphore.Semaphore) {
-	w.HashOK = w.Piece.VerifyHash(w.Buffer.Data, sha1.New())
-	if w.HashOK {
+	w.HashOK = !w.Verify || w.Piece.VerifyHash(w.Buffer.Data, w.Piece.NewHash())
+	if w.HashOK && !w.Defer {
 		writesPerSecond.Mark(1)
 		writeBytesPerSecond.Mark(int64(len(w.Buffer.Data)))
 		sem.Wait()
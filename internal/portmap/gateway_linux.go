@@ -0,0 +1,51 @@
+// +build linux
+
+package portmap
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// defaultGateway returns the gateway of the default IPv4 route by reading the kernel routing table.
+func defaultGateway() (net.IP, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseRoutes(f)
+}
+
+func parseRoutes(r io.Reader) (net.IP, error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		// Fields: Iface Destination Gateway Flags ...
+		fields := strings.Fields(s.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[2], 16, 32)
+		if err != nil {
+			continue
+		}
+		// Kernel prints the address in network byte order as a number in host byte order.
+		// Storing the number in memory restores the original bytes regardless of the endianness of the host.
+		ip := make(net.IP, 4)
+		*(*uint32)(unsafe.Pointer(&ip[0])) = uint32(v)
+		if ip.IsUnspecified() {
+			continue
+		}
+		return ip, nil
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("default gateway not found")
+}
@@ -0,0 +1,28 @@
+// +build linux
+
+package portmap
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestParseRoutes(t *testing.T) {
+	// Gateway is 192.168.1.1, printed as a number in host byte order.
+	gateway := "0101A8C0"
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 0 {
+		gateway = "C0A80101"
+	}
+	routes := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+		"eth0\t0000A8C0\t00000000\t0001\t0\t0\t0\t00FFFFFF\t0\t0\t0\n" +
+		"eth0\t00000000\t" + gateway + "\t0003\t0\t0\t0\t00000000\t0\t0\t0\n"
+	ip, err := parseRoutes(strings.NewReader(routes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip.String() != "192.168.1.1" {
+		t.Fatalf("unexpected gateway: %s", ip)
+	}
+}
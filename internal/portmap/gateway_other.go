@@ -0,0 +1,13 @@
+// +build !linux
+
+package portmap
+
+import (
+	"errors"
+	"net"
+)
+
+// defaultGateway is only implemented on Linux. UPnP can still be used on other platforms.
+func defaultGateway() (net.IP, error) {
+	return nil, errors.New("finding default gateway is not supported on this platform")
+}
@@ -0,0 +1,130 @@
+package portmap
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	natpmpPort         = 5351
+	natpmpOpExternalIP = 0
+	natpmpOpMapTCP     = 2
+	// Requests are retried this many times, doubling the wait time after each try (RFC 6886 section 3.1).
+	natpmpTries        = 4
+	natpmpInitialDelay = 250 * time.Millisecond
+)
+
+// natpmp maps ports with NAT Port Mapping Protocol (RFC 6886).
+type natpmp struct {
+	gateway *net.UDPAddr
+}
+
+var _ Protocol = (*natpmp)(nil)
+
+func (n *natpmp) String() string {
+	return "NAT-PMP"
+}
+
+// discoverNATPMP returns a client for the default gateway if it responds to NAT-PMP requests.
+func discoverNATPMP(ctx context.Context) (*natpmp, error) {
+	gw, err := defaultGateway()
+	if err != nil {
+		return nil, err
+	}
+	n := &natpmp{gateway: &net.UDPAddr{IP: gw, Port: natpmpPort}}
+	_, err = n.ExternalIP(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *natpmp) ExternalIP(ctx context.Context) (net.IP, error) {
+	resp, err := n.request(ctx, []byte{0, natpmpOpExternalIP}, 12)
+	if err != nil {
+		return nil, err
+	}
+	return net.IP(resp[8:12]), nil
+}
+
+func (n *natpmp) AddPortMapping(ctx context.Context, port int, lease time.Duration) error {
+	return n.mapPort(ctx, port, uint32(lease/time.Second))
+}
+
+func (n *natpmp) DeletePortMapping(ctx context.Context, port int) error {
+	return n.mapPort(ctx, port, 0)
+}
+
+func (n *natpmp) mapPort(ctx context.Context, port int, lifetime uint32) error {
+	req := make([]byte, 12)
+	req[1] = natpmpOpMapTCP
+	binary.BigEndian.PutUint16(req[4:6], uint16(port))
+	if lifetime > 0 {
+		binary.BigEndian.PutUint16(req[6:8], uint16(port))
+	}
+	binary.BigEndian.PutUint32(req[8:12], lifetime)
+	resp, err := n.request(ctx, req, 16)
+	if err != nil {
+		return err
+	}
+	if lifetime > 0 {
+		if mapped := binary.BigEndian.Uint16(resp[10:12]); int(mapped) != port {
+			// Gateway assigned a different external port. Peers would not be able to reach us with the announced port.
+			_ = n.mapPort(ctx, port, 0)
+			return fmt.Errorf("gateway mapped port %d to different external port %d", port, mapped)
+		}
+	}
+	return nil
+}
+
+// request sends a request to the gateway and returns the response after checking the result code.
+func (n *natpmp) request(ctx context.Context, req []byte, size int) ([]byte, error) {
+	conn, err := net.DialUDP("udp4", nil, n.gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	buf := make([]byte, 16)
+	delay := natpmpInitialDelay
+	for i := 0; i < natpmpTries; i++ {
+		_, err = conn.Write(req)
+		if err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(delay)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		err = conn.SetReadDeadline(deadline)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			var m int
+			m, err = conn.Read(buf)
+			if err != nil {
+				break
+			}
+			// Ignore unrelated packets.
+			if m < size || buf[0] != 0 || buf[1] != req[1]|0x80 {
+				continue
+			}
+			if code := binary.BigEndian.Uint16(buf[2:4]); code != 0 {
+				return nil, fmt.Errorf("NAT-PMP request failed with result code %d", code)
+			}
+			return buf[:size], nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() {
+			return nil, err
+		}
+		delay *= 2
+	}
+	return nil, errors.New("NAT-PMP gateway did not respond")
+}
@@ -0,0 +1,237 @@
+// Package portmap forwards ports on the router to the local host with UPnP IGD or NAT-PMP.
+package portmap
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/rain/internal/externalip"
+	"github.com/cenkalti/rain/internal/logger"
+)
+
+// mappingDescription is shown in the port mapping list of the router.
+const mappingDescription = "rain"
+
+// Protocol is implemented by the port mapping protocols supported by the router.
+type Protocol interface {
+	AddPortMapping(ctx context.Context, port int, lease time.Duration) error
+	DeletePortMapping(ctx context.Context, port int) error
+	ExternalIP(ctx context.Context) (net.IP, error)
+	String() string
+}
+
+// Mapper keeps the TCP ports added to it forwarded on the router.
+// The router is discovered with UPnP first, then with NAT-PMP.
+// Mappings are refreshed before their lease expires and deleted when the Mapper is closed.
+// Refresh can be called to find the router again without waiting for the next refresh.
+type Mapper struct {
+	// Discover finds the router. It can be replaced before calling Run, e.g. in tests.
+	Discover func(ctx context.Context) (Protocol, error)
+
+	lease   time.Duration
+	timeout time.Duration
+	log     logger.Logger
+
+	m          sync.Mutex
+	ports      map[int]struct{}
+	externalIP net.IP
+
+	updateC  chan struct{}
+	refreshC chan struct{}
+	closeC   chan struct{}
+	doneC    chan struct{}
+}
+
+// New returns a new Mapper. Mappings are requested for lease duration and refreshed at half of it.
+// Discovery waits for responses from routers and other requests to the router are cancelled after timeout.
+func New(lease, timeout time.Duration, l logger.Logger) *Mapper {
+	m := &Mapper{
+		lease:    lease,
+		timeout:  timeout,
+		log:      l,
+		ports:    make(map[int]struct{}),
+		updateC:  make(chan struct{}, 1),
+		refreshC: make(chan struct{}, 1),
+		closeC:   make(chan struct{}),
+		doneC:    make(chan struct{}),
+	}
+	m.Discover = m.discoverRouter
+	return m
+}
+
+// Add a port to be forwarded.
+func (m *Mapper) Add(port int) {
+	m.m.Lock()
+	m.ports[port] = struct{}{}
+	m.m.Unlock()
+	m.notify()
+}
+
+// Remove the forwarding of the port.
+func (m *Mapper) Remove(port int) {
+	m.m.Lock()
+	delete(m.ports, port)
+	m.m.Unlock()
+	m.notify()
+}
+
+// ExternalIP returns the external IP address reported by the router.
+// Returns nil if it is not known yet or it is not a public address.
+func (m *Mapper) ExternalIP() net.IP {
+	m.m.Lock()
+	defer m.m.Unlock()
+	return m.externalIP
+}
+
+// Refresh discovers the router again and adds all ports to it.
+// It should be called when the network changes, e.g. the external IP changes after the router is replaced or restarted.
+func (m *Mapper) Refresh() {
+	select {
+	case m.refreshC <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Mapper) notify() {
+	select {
+	case m.updateC <- struct{}{}:
+	default:
+	}
+}
+
+// Close the Mapper and delete the port mappings on the router.
+func (m *Mapper) Close() {
+	close(m.closeC)
+	<-m.doneC
+}
+
+// Run the Mapper. Errors are logged and retried at the next refresh.
+func (m *Mapper) Run() {
+	defer close(m.doneC)
+
+	var proto Protocol
+	var discoveryFailed bool
+	mapped := make(map[int]struct{})
+
+	ticker := time.NewTicker(m.lease / 2)
+	defer ticker.Stop()
+
+	// Requests are cancelled when the Mapper is closed.
+	runCtx, cancelRun := context.WithCancel(context.Background())
+	defer cancelRun()
+	go func() {
+		select {
+		case <-m.closeC:
+			cancelRun()
+		case <-runCtx.Done():
+		}
+	}()
+
+	// update maps the added ports and deletes the mappings of removed ports.
+	// On refresh, existing mappings are renewed and discovery is retried if it has failed before.
+	update := func(refresh bool) {
+		if proto == nil {
+			if discoveryFailed && !refresh {
+				return
+			}
+			var err error
+			proto, err = m.Discover(runCtx)
+			if err != nil {
+				discoveryFailed = true
+				m.log.Warningln("cannot find router for port forwarding:", err)
+				return
+			}
+			m.log.Infof("found router for port forwarding with %s", proto)
+		}
+		ctx, cancel := context.WithTimeout(runCtx, m.timeout)
+		defer cancel()
+		ip, err := proto.ExternalIP(ctx)
+		if err != nil {
+			// Router may have been replaced or restarted. Find it again at next refresh.
+			m.log.Warningln("cannot get external IP from router:", err)
+			proto = nil
+			discoveryFailed = true
+			mapped = make(map[int]struct{})
+			return
+		}
+		if !externalip.IsPublic(ip) {
+			// Router is behind another NAT, so the address is not reachable from the internet.
+			m.log.Debugln("external IP of the router is not public:", ip)
+			ip = nil
+		}
+		m.m.Lock()
+		m.externalIP = ip
+		m.m.Unlock()
+		m.m.Lock()
+		wanted := make(map[int]struct{}, len(m.ports))
+		for port := range m.ports {
+			wanted[port] = struct{}{}
+		}
+		m.m.Unlock()
+		for port := range mapped {
+			if _, ok := wanted[port]; ok {
+				continue
+			}
+			err = proto.DeletePortMapping(ctx, port)
+			if err != nil {
+				m.log.Warningf("cannot delete mapping of port %d: %s", port, err)
+			}
+			delete(mapped, port)
+		}
+		for port := range wanted {
+			if _, ok := mapped[port]; ok && !refresh {
+				continue
+			}
+			err = proto.AddPortMapping(ctx, port, m.lease)
+			if err != nil {
+				m.log.Warningf("cannot forward port %d: %s", port, err)
+				delete(mapped, port)
+				continue
+			}
+			m.log.Debugf("port %d is forwarded with %s", port, proto)
+			mapped[port] = struct{}{}
+		}
+	}
+
+	for {
+		select {
+		case <-m.updateC:
+			update(false)
+		case <-ticker.C:
+			update(true)
+		case <-m.refreshC:
+			// Mappings on the previous router are not deleted because it may not be reachable anymore.
+			proto = nil
+			mapped = make(map[int]struct{})
+			update(true)
+		case <-m.closeC:
+			if proto == nil {
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+			for port := range mapped {
+				err := proto.DeletePortMapping(ctx, port)
+				if err != nil {
+					m.log.Warningf("cannot delete mapping of port %d: %s", port, err)
+				}
+			}
+			cancel()
+			return
+		}
+	}
+}
+
+func (m *Mapper) discoverRouter(ctx context.Context) (Protocol, error) {
+	u, err := discoverUPnP(ctx, m.timeout)
+	if err == nil {
+		return u, nil
+	}
+	m.log.Debugln("UPnP discovery failed:", err)
+	n, err := discoverNATPMP(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
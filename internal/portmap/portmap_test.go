@@ -0,0 +1,222 @@
+package portmap
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNATPMP(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	lifetimes := make(chan uint32, 2)
+	go func() {
+		buf := make([]byte, 12)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			switch {
+			case n == 2 && buf[1] == natpmpOpExternalIP:
+				resp := make([]byte, 12)
+				resp[1] = 128
+				copy(resp[8:12], net.IPv4(1, 2, 3, 4).To4())
+				_, _ = conn.WriteTo(resp, addr)
+			case n == 12 && buf[1] == natpmpOpMapTCP:
+				lifetimes <- binary.BigEndian.Uint32(buf[8:12])
+				resp := make([]byte, 16)
+				resp[1] = 130
+				copy(resp[8:12], buf[4:8])
+				copy(resp[12:16], buf[8:12])
+				_, _ = conn.WriteTo(resp, addr)
+			}
+		}
+	}()
+
+	n := &natpmp{gateway: conn.LocalAddr().(*net.UDPAddr)}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ip, err := n.ExternalIP(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3.4", ip.String())
+
+	assert.NoError(t, n.AddPortMapping(ctx, 6881, time.Hour))
+	assert.Equal(t, uint32(3600), <-lifetimes)
+
+	assert.NoError(t, n.DeletePortMapping(ctx, 6881))
+	assert.Equal(t, uint32(0), <-lifetimes)
+}
+
+const testDeviceDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service>
+                <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+                <controlURL>/ctl/IPConn</controlURL>
+              </service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+func TestUPnP(t *testing.T) {
+	var m sync.Mutex
+	var actions []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rootDesc.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testDeviceDescription)
+	})
+	mux.HandleFunc("/ctl/IPConn", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		action := r.Header.Get("SOAPAction")
+		m.Lock()
+		actions = append(actions, action)
+		m.Unlock()
+		switch action {
+		case `"urn:schemas-upnp-org:service:WANIPConnection:1#GetExternalIPAddress"`:
+			fmt.Fprint(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>`+
+				`<u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">`+
+				`<NewExternalIPAddress>1.2.3.4</NewExternalIPAddress></u:GetExternalIPAddressResponse></s:Body></s:Envelope>`)
+		case `"urn:schemas-upnp-org:service:WANIPConnection:1#AddPortMapping"`:
+			if !strings.Contains(string(body), "<NewExternalPort>6881</NewExternalPort>") ||
+				!strings.Contains(string(body), "<NewInternalClient>127.0.0.1</NewInternalClient>") {
+				http.Error(w, "invalid arguments", http.StatusInternalServerError)
+			}
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	u, err := newUPnP(ctx, srv.URL+"/rootDesc.xml", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, srv.URL+"/ctl/IPConn", u.controlURL)
+
+	ip, err := u.ExternalIP(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3.4", ip.String())
+	assert.NoError(t, u.AddPortMapping(ctx, 6881, time.Hour))
+	assert.NoError(t, u.DeletePortMapping(ctx, 6881))
+
+	m.Lock()
+	defer m.Unlock()
+	assert.Equal(t, 3, len(actions))
+}
+
+type fakeProtocol struct {
+	m       sync.Mutex
+	ports   map[int]bool
+	changeC chan struct{}
+}
+
+func (p *fakeProtocol) AddPortMapping(ctx context.Context, port int, lease time.Duration) error {
+	p.m.Lock()
+	p.ports[port] = true
+	p.m.Unlock()
+	p.changeC <- struct{}{}
+	return nil
+}
+
+func (p *fakeProtocol) DeletePortMapping(ctx context.Context, port int) error {
+	p.m.Lock()
+	delete(p.ports, port)
+	p.m.Unlock()
+	p.changeC <- struct{}{}
+	return nil
+}
+
+func (p *fakeProtocol) ExternalIP(ctx context.Context) (net.IP, error) {
+	return net.IPv4(1, 2, 3, 4), nil
+}
+
+func (p *fakeProtocol) String() string { return "fake" }
+
+func (p *fakeProtocol) mapped(port int) bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.ports[port]
+}
+
+func TestMapper(t *testing.T) {
+	p := &fakeProtocol{ports: make(map[int]bool), changeC: make(chan struct{}, 10)}
+	m := New(time.Hour, time.Second, logger.New("portmap"))
+	m.Discover = func(ctx context.Context) (Protocol, error) { return p, nil }
+	go m.Run()
+
+	m.Add(6881)
+	<-p.changeC
+	assert.True(t, p.mapped(6881))
+	assert.Equal(t, "1.2.3.4", m.ExternalIP().String())
+
+	m.Add(6882)
+	<-p.changeC
+	m.Remove(6881)
+	<-p.changeC
+	assert.False(t, p.mapped(6881))
+	assert.True(t, p.mapped(6882))
+
+	// Remaining mappings are deleted on close.
+	m.Close()
+	assert.False(t, p.mapped(6882))
+}
+
+func TestMapperRefresh(t *testing.T) {
+	p := &fakeProtocol{ports: make(map[int]bool), changeC: make(chan struct{}, 10)}
+	m := New(time.Hour, time.Second, logger.New("portmap"))
+	discovered := make(chan struct{}, 10)
+	m.Discover = func(ctx context.Context) (Protocol, error) {
+		discovered <- struct{}{}
+		return p, nil
+	}
+	go m.Run()
+	defer m.Close()
+
+	m.Add(6881)
+	<-discovered
+	<-p.changeC
+	assert.True(t, p.mapped(6881))
+
+	// Router is found again and the port is added without waiting for the lease to expire.
+	p.m.Lock()
+	delete(p.ports, 6881)
+	p.m.Unlock()
+	m.Refresh()
+	select {
+	case <-discovered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("router is not discovered again")
+	}
+	<-p.changeC
+	assert.True(t, p.mapped(6881))
+}
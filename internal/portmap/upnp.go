@@ -0,0 +1,292 @@
+package portmap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ssdpAddr = "239.255.255.250:1900"
+
+var igdSearchTargets = []string{
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:1",
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:2",
+}
+
+var wanConnectionServices = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+var errNoIGD = errors.New("no UPnP internet gateway device found")
+
+// upnp maps ports with the WANIPConnection or WANPPPConnection service of an Internet Gateway Device.
+type upnp struct {
+	client      *http.Client
+	controlURL  string
+	serviceType string
+	localIP     net.IP
+}
+
+var _ Protocol = (*upnp)(nil)
+
+func (u *upnp) String() string {
+	return "UPnP"
+}
+
+// discoverUPnP sends SSDP search requests and returns the first gateway device that has a WAN connection service.
+func discoverUPnP(ctx context.Context, timeout time.Duration) (*upnp, error) {
+	locations, err := searchSSDP(ctx, timeout)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error = errNoIGD
+	for _, loc := range locations {
+		u, err := newUPnP(ctx, loc, timeout)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return u, nil
+	}
+	return nil, lastErr
+}
+
+// searchSSDP returns the description locations of the devices that respond to the search request in timeout.
+func searchSSDP(ctx context.Context, timeout time.Duration) ([]string, error) {
+	raddr, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range igdSearchTargets {
+		msg := "M-SEARCH * HTTP/1.1\r\n" +
+			"HOST: " + ssdpAddr + "\r\n" +
+			"ST: " + st + "\r\n" +
+			"MAN: \"ssdp:discover\"\r\n" +
+			"MX: 2\r\n\r\n"
+		_, err = conn.WriteTo([]byte(msg), raddr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var locations []string
+	seen := make(map[string]struct{})
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+				break
+			}
+			return nil, err
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		loc := resp.Header.Get("Location")
+		if loc == "" {
+			continue
+		}
+		if _, ok := seen[loc]; ok {
+			continue
+		}
+		seen[loc] = struct{}{}
+		locations = append(locations, loc)
+	}
+	if len(locations) == 0 {
+		return nil, errNoIGD
+	}
+	return locations, nil
+}
+
+type upnpDevice struct {
+	Services []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []upnpDevice `xml:"deviceList>device"`
+}
+
+type upnpRoot struct {
+	URLBase string     `xml:"URLBase"`
+	Device  upnpDevice `xml:"device"`
+}
+
+// newUPnP reads the device description at location and finds the control URL of the WAN connection service.
+func newUPnP(ctx context.Context, location string, timeout time.Duration) (*upnp, error) {
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: timeout}
+	req, err := http.NewRequest(http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot get device description: %s", resp.Status)
+	}
+	var root upnpRoot
+	err = xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&root)
+	if err != nil {
+		return nil, err
+	}
+	if root.URLBase != "" {
+		base, err = url.Parse(root.URLBase)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, st := range wanConnectionServices {
+		controlURL := findControlURL(&root.Device, st)
+		if controlURL == "" {
+			continue
+		}
+		ref, err := url.Parse(controlURL)
+		if err != nil {
+			return nil, err
+		}
+		localIP, err := localIPFor(base.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		return &upnp{
+			client:      client,
+			controlURL:  base.ResolveReference(ref).String(),
+			serviceType: st,
+			localIP:     localIP,
+		}, nil
+	}
+	return nil, errNoIGD
+}
+
+func findControlURL(d *upnpDevice, serviceType string) string {
+	for _, s := range d.Services {
+		if s.ServiceType == serviceType {
+			return s.ControlURL
+		}
+	}
+	for i := range d.Devices {
+		if u := findControlURL(&d.Devices[i], serviceType); u != "" {
+			return u
+		}
+	}
+	return ""
+}
+
+// localIPFor returns the address of the local interface that is used to reach host.
+func localIPFor(host string) (net.IP, error) {
+	conn, err := net.Dial("udp4", net.JoinHostPort(host, "1"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+func (u *upnp) AddPortMapping(ctx context.Context, port int, lease time.Duration) error {
+	_, err := u.call(ctx, "AddPortMapping", [][2]string{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(port)},
+		{"NewProtocol", "TCP"},
+		{"NewInternalPort", strconv.Itoa(port)},
+		{"NewInternalClient", u.localIP.String()},
+		{"NewEnabled", "1"},
+		{"NewPortMappingDescription", mappingDescription},
+		{"NewLeaseDuration", strconv.Itoa(int(lease / time.Second))},
+	})
+	return err
+}
+
+func (u *upnp) DeletePortMapping(ctx context.Context, port int) error {
+	_, err := u.call(ctx, "DeletePortMapping", [][2]string{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(port)},
+		{"NewProtocol", "TCP"},
+	})
+	return err
+}
+
+func (u *upnp) ExternalIP(ctx context.Context) (net.IP, error) {
+	body, err := u.call(ctx, "GetExternalIPAddress", nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		IP string `xml:"Body>GetExternalIPAddressResponse>NewExternalIPAddress"`
+	}
+	err = xml.Unmarshal(body, &resp)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(resp.IP)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid external IP address: %q", resp.IP)
+	}
+	return ip, nil
+}
+
+// call sends a SOAP request to the control URL of the service and returns the response body.
+func (u *upnp) call(ctx context.Context, action string, args [][2]string) ([]byte, error) {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0"?>`)
+	sb.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">`)
+	sb.WriteString(`<s:Body><u:` + action + ` xmlns:u="` + u.serviceType + `">`)
+	for _, arg := range args {
+		sb.WriteString("<" + arg[0] + ">")
+		_ = xml.EscapeText(&sb, []byte(arg[1]))
+		sb.WriteString("</" + arg[0] + ">")
+	}
+	sb.WriteString(`</u:` + action + `></s:Body></s:Envelope>`)
+
+	req, err := http.NewRequest(http.MethodPost, u.controlURL, strings.NewReader(sb.String()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+u.serviceType+"#"+action+`"`)
+	resp, err := u.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s failed: %s", action, resp.Status)
+	}
+	return body, nil
+}
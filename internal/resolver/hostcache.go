@@ -0,0 +1,68 @@
+package resolver
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// HostCache resolves host names to IPv4 addresses and keeps the results for a short duration.
+type HostCache struct {
+	ttl    time.Duration
+	lookup func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+	m       sync.Mutex
+	entries map[string]hostCacheEntry
+}
+
+type hostCacheEntry struct {
+	ips       []net.IP
+	expiresAt time.Time
+}
+
+// NewHostCache returns a new HostCache that keeps the resolved addresses for ttl duration.
+func NewHostCache(ttl time.Duration) *HostCache {
+	return &HostCache{
+		ttl:     ttl,
+		lookup:  net.DefaultResolver.LookupIPAddr,
+		entries: make(map[string]hostCacheEntry),
+	}
+}
+
+// LookupIP returns all IPv4 addresses of the host in the order returned from the resolver.
+func (c *HostCache) LookupIP(ctx context.Context, timeout time.Duration, host string) ([]net.IP, error) {
+	now := time.Now()
+	c.m.Lock()
+	e, ok := c.entries[host]
+	c.m.Unlock()
+	if ok && now.Before(e.expiresAt) {
+		return e.ips, nil
+	}
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, timeout)
+	defer cancel()
+	addrs, err := c.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, ia := range addrs {
+		i4 := ia.IP.To4()
+		if i4 != nil {
+			ips = append(ips, i4)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, ErrNotIPv4Address
+	}
+	c.m.Lock()
+	for h, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, h)
+		}
+	}
+	c.entries[host] = hostCacheEntry{ips: ips, expiresAt: now.Add(c.ttl)}
+	c.m.Unlock()
+	return ips, nil
+}
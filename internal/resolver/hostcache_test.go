@@ -0,0 +1,33 @@
+package resolver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHostCache(t *testing.T) {
+	var lookups int
+	c := NewHostCache(time.Minute)
+	c.lookup = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		lookups++
+		return []net.IPAddr{
+			{IP: net.ParseIP("1.2.3.4")},
+			{IP: net.ParseIP("::1")},
+			{IP: net.ParseIP("5.6.7.8")},
+		}, nil
+	}
+	for i := 0; i < 2; i++ {
+		ips, err := c.LookupIP(context.Background(), time.Second, "peer.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ips) != 2 || !ips[0].Equal(net.ParseIP("1.2.3.4")) || !ips[1].Equal(net.ParseIP("5.6.7.8")) {
+			t.Fatalf("unexpected addresses: %v", ips)
+		}
+	}
+	if lookups != 1 {
+		t.Fatalf("result must be cached, lookups: %d", lookups)
+	}
+}
@@ -8,44 +8,79 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/cenkalti/rain/internal/resumer"
 	"go.etcd.io/bbolt"
 )
 
 // Keys for the persisten storage.
 var Keys = struct {
-	InfoHash        []byte
-	Port            []byte
-	Name            []byte
-	Trackers        []byte
-	URLList         []byte
-	FixedPeers      []byte
-	Dest            []byte
-	Info            []byte
-	Bitfield        []byte
-	AddedAt         []byte
-	BytesDownloaded []byte
-	BytesUploaded   []byte
-	BytesWasted     []byte
-	SeededFor       []byte
-	Started         []byte
-	CompleteCmdRun  []byte
+	InfoHash          []byte
+	InfoHashV2        []byte
+	Port              []byte
+	Name              []byte
+	Trackers          []byte
+	URLList           []byte
+	HTTPSeeds         []byte
+	FixedPeers        []byte
+	Dest              []byte
+	Info              []byte
+	PieceLayers       []byte
+	Bitfield          []byte
+	UnverifiedPieces  []byte
+	AddedAt           []byte
+	BytesDownloaded   []byte
+	BytesUploaded     []byte
+	BytesWasted       []byte
+	SeededFor         []byte
+	Started           []byte
+	StopAfterDownload []byte
+	DisablePEX        []byte
+	CompleteCmdRun    []byte
+	SeedLimitReached  []byte
+	CreationDate      []byte
+	Comment           []byte
+	CreatedBy         []byte
+	Encoding          []byte
+	Peers             []byte
+	PeerFailures      []byte
+	PartialPieces     []byte
+	FilePriorities    []byte
+	FileStats         []byte
+	Location          []byte
 }{
-	InfoHash:        []byte("info_hash"),
-	Port:            []byte("port"),
-	Name:            []byte("name"),
-	Trackers:        []byte("trackers"),
-	URLList:         []byte("url_list"),
-	FixedPeers:      []byte("fixed_peers"),
-	Dest:            []byte("dest"),
-	Info:            []byte("info"),
-	Bitfield:        []byte("bitfield"),
-	AddedAt:         []byte("added_at"),
-	BytesDownloaded: []byte("bytes_downloaded"),
-	BytesUploaded:   []byte("bytes_uploaded"),
-	BytesWasted:     []byte("bytes_wasted"),
-	SeededFor:       []byte("seeded_for"),
-	Started:         []byte("started"),
-	CompleteCmdRun:  []byte("complete_cmd_run"),
+	InfoHash:          []byte("info_hash"),
+	InfoHashV2:        []byte("info_hash_v2"),
+	Port:              []byte("port"),
+	Name:              []byte("name"),
+	Trackers:          []byte("trackers"),
+	URLList:           []byte("url_list"),
+	HTTPSeeds:         []byte("http_seeds"),
+	FixedPeers:        []byte("fixed_peers"),
+	Dest:              []byte("dest"),
+	Info:              []byte("info"),
+	PieceLayers:       []byte("piece_layers"),
+	Bitfield:          []byte("bitfield"),
+	UnverifiedPieces:  []byte("unverified_pieces"),
+	AddedAt:           []byte("added_at"),
+	BytesDownloaded:   []byte("bytes_downloaded"),
+	BytesUploaded:     []byte("bytes_uploaded"),
+	BytesWasted:       []byte("bytes_wasted"),
+	SeededFor:         []byte("seeded_for"),
+	Started:           []byte("started"),
+	StopAfterDownload: []byte("stop_after_download"),
+	DisablePEX:        []byte("disable_pex"),
+	CompleteCmdRun:    []byte("complete_cmd_run"),
+	SeedLimitReached:  []byte("seed_limit_reached"),
+	CreationDate:      []byte("creation_date"),
+	Comment:           []byte("comment"),
+	CreatedBy:         []byte("created_by"),
+	Encoding:          []byte("encoding"),
+	Peers:             []byte("peers"),
+	PeerFailures:      []byte("peer_failures"),
+	PartialPieces:     []byte("partial_pieces"),
+	FilePriorities:    []byte("file_priorities"),
+	FileStats:         []byte("file_stats"),
+	Location:          []byte("location"),
 }
 
 // Resumer contains methods for saving/loading resume information of a torrent to a BoltDB database.
@@ -80,30 +115,71 @@ func (r *Resumer) Write(torrentID string, spec *Spec) error {
 	if err != nil {
 		return err
 	}
+	httpSeeds, err := json.Marshal(spec.HTTPSeeds)
+	if err != nil {
+		return err
+	}
 	fixedPeers, err := json.Marshal(spec.FixedPeers)
 	if err != nil {
 		return err
 	}
+	peers, err := json.Marshal(spec.Peers)
+	if err != nil {
+		return err
+	}
+	peerFailures, err := json.Marshal(spec.PeerFailures)
+	if err != nil {
+		return err
+	}
+	partialPieces, err := json.Marshal(spec.PartialPieces)
+	if err != nil {
+		return err
+	}
+	filePriorities, err := json.Marshal(spec.FilePriorities)
+	if err != nil {
+		return err
+	}
+	fileStats, err := json.Marshal(spec.FileStats)
+	if err != nil {
+		return err
+	}
 	return r.db.Update(func(tx *bbolt.Tx) error {
 		b, err := tx.Bucket(r.bucket).CreateBucketIfNotExists([]byte(torrentID))
 		if err != nil {
 			return err
 		}
 		_ = b.Put(Keys.InfoHash, spec.InfoHash)
+		_ = b.Put(Keys.InfoHashV2, spec.InfoHashV2)
 		_ = b.Put(Keys.Port, []byte(port))
 		_ = b.Put(Keys.Name, []byte(spec.Name))
 		_ = b.Put(Keys.Trackers, trackers)
 		_ = b.Put(Keys.URLList, urlList)
+		_ = b.Put(Keys.HTTPSeeds, httpSeeds)
 		_ = b.Put(Keys.FixedPeers, fixedPeers)
 		_ = b.Put(Keys.Info, spec.Info)
+		_ = b.Put(Keys.PieceLayers, spec.PieceLayers)
 		_ = b.Put(Keys.Bitfield, spec.Bitfield)
+		_ = b.Put(Keys.UnverifiedPieces, spec.UnverifiedPieces)
 		_ = b.Put(Keys.AddedAt, []byte(spec.AddedAt.Format(time.RFC3339)))
 		_ = b.Put(Keys.BytesDownloaded, []byte(strconv.FormatInt(spec.BytesDownloaded, 10)))
 		_ = b.Put(Keys.BytesUploaded, []byte(strconv.FormatInt(spec.BytesUploaded, 10)))
 		_ = b.Put(Keys.BytesWasted, []byte(strconv.FormatInt(spec.BytesWasted, 10)))
 		_ = b.Put(Keys.SeededFor, []byte(spec.SeededFor.String()))
 		_ = b.Put(Keys.Started, []byte(strconv.FormatBool(spec.Started)))
+		_ = b.Put(Keys.StopAfterDownload, []byte(strconv.FormatBool(spec.StopAfterDownload)))
+		_ = b.Put(Keys.DisablePEX, []byte(strconv.FormatBool(spec.DisablePEX)))
 		_ = b.Put(Keys.CompleteCmdRun, []byte(strconv.FormatBool(spec.CompleteCmdRun)))
+		_ = b.Put(Keys.SeedLimitReached, []byte(strconv.FormatBool(spec.SeedLimitReached)))
+		_ = b.Put(Keys.CreationDate, []byte(spec.CreationDate.Format(time.RFC3339)))
+		_ = b.Put(Keys.Comment, []byte(spec.Comment))
+		_ = b.Put(Keys.CreatedBy, []byte(spec.CreatedBy))
+		_ = b.Put(Keys.Encoding, []byte(spec.Encoding))
+		_ = b.Put(Keys.Peers, peers)
+		_ = b.Put(Keys.PeerFailures, peerFailures)
+		_ = b.Put(Keys.PartialPieces, partialPieces)
+		_ = b.Put(Keys.FilePriorities, filePriorities)
+		_ = b.Put(Keys.FileStats, fileStats)
+		_ = b.Put(Keys.Location, []byte(spec.Location))
 		return nil
 	})
 }
@@ -119,14 +195,162 @@ func (r *Resumer) WriteInfo(torrentID string, value []byte) error {
 	})
 }
 
-// WriteBitfield writes only bitfield of a torrent.
-func (r *Resumer) WriteBitfield(torrentID string, value []byte) error {
+// WriteBitfield writes the bitfield of a torrent and the stats of its files at the time the bitfield is created.
+// Saved file stats are not changed if fileStats is nil.
+// Transfer stats are written in the same transaction, so they are consistent with the bitfield.
+func (r *Resumer) WriteBitfield(torrentID string, value []byte, fileStats []FileStat, stats resumer.Stats) error {
+	var fs []byte
+	if fileStats != nil {
+		var err error
+		fs, err = json.Marshal(fileStats)
+		if err != nil {
+			return err
+		}
+	}
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		err := b.Put(Keys.Bitfield, value)
+		if err != nil {
+			return err
+		}
+		if fs != nil {
+			err = b.Put(Keys.FileStats, fs)
+			if err != nil {
+				return err
+			}
+		}
+		return putStats(b, stats)
+	})
+}
+
+// DeleteBitfield deletes the bitfield of a torrent, so its files are verified when it is started.
+func (r *Resumer) DeleteBitfield(torrentID string) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		err := b.Delete(Keys.UnverifiedPieces)
+		if err != nil {
+			return err
+		}
+		return b.Delete(Keys.Bitfield)
+	})
+}
+
+// WriteUnverifiedPieces writes the pieces of a torrent that are in the bitfield but not verified yet.
+// Saved value is deleted if value is empty.
+func (r *Resumer) WriteUnverifiedPieces(torrentID string, value []byte) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		if len(value) == 0 {
+			return b.Delete(Keys.UnverifiedPieces)
+		}
+		return b.Put(Keys.UnverifiedPieces, value)
+	})
+}
+
+// WriteStats writes the transfer stats of a torrent.
+func (r *Resumer) WriteStats(torrentID string, stats resumer.Stats) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		return putStats(b, stats)
+	})
+}
+
+func putStats(b *bbolt.Bucket, stats resumer.Stats) error {
+	_ = b.Put(Keys.BytesDownloaded, []byte(strconv.FormatInt(stats.BytesDownloaded, 10)))
+	_ = b.Put(Keys.BytesUploaded, []byte(strconv.FormatInt(stats.BytesUploaded, 10)))
+	_ = b.Put(Keys.BytesWasted, []byte(strconv.FormatInt(stats.BytesWasted, 10)))
+	return b.Put(Keys.SeededFor, []byte(time.Duration(stats.SeededFor).String()))
+}
+
+// WriteTrackers writes the tracker tiers of a torrent.
+func (r *Resumer) WriteTrackers(torrentID string, value [][]string) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Put(Keys.Trackers, b)
+	})
+}
+
+// WritePeers writes the addresses of peers that are going to be connected when the torrent is resumed
+// and the number of consecutive failed connections to them.
+func (r *Resumer) WritePeers(torrentID string, value []string, failures map[string]int) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	f, err := json.Marshal(failures)
+	if err != nil {
+		return err
+	}
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if bucket == nil {
+			return nil
+		}
+		err := bucket.Put(Keys.Peers, b)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(Keys.PeerFailures, f)
+	})
+}
+
+// WritePartialPieces writes the indexes of downloaded blocks of incomplete pieces, keyed by piece index.
+func (r *Resumer) WritePartialPieces(torrentID string, value map[uint32][]int) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Put(Keys.PartialPieces, b)
+	})
+}
+
+// WriteFilePriorities writes the priorities of files in a torrent.
+func (r *Resumer) WriteFilePriorities(torrentID string, value []int) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Put(Keys.FilePriorities, b)
+	})
+}
+
+// WriteLocation writes the directory that the files of a torrent are moved to.
+func (r *Resumer) WriteLocation(torrentID string, value string) error {
 	return r.db.Update(func(tx *bbolt.Tx) error {
 		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
 		if b == nil {
 			return nil
 		}
-		return b.Put(Keys.Bitfield, value)
+		return b.Put(Keys.Location, []byte(value))
 	})
 }
 
@@ -152,6 +376,41 @@ func (r *Resumer) WriteCompleteCmdRun(torrentID string) error {
 	})
 }
 
+// WriteSeedLimitReached writes whether a torrent is stopped after reaching its seed limits.
+func (r *Resumer) WriteSeedLimitReached(torrentID string, value bool) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		return b.Put(Keys.SeedLimitReached, []byte(strconv.FormatBool(value)))
+	})
+}
+
+// Delete the resume data of a torrent.
+func (r *Resumer) Delete(torrentID string) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		err := tx.Bucket(r.bucket).DeleteBucket([]byte(torrentID))
+		if err == bbolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+// List returns the IDs of torrents that have resume data.
+func (r *Resumer) List() ([]string, error) {
+	var ids []string
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(r.bucket).ForEach(func(k, _ []byte) error {
+			ids = append(ids, string(k))
+			return nil
+		})
+	})
+	return ids, err
+}
+
+// Read the resume data of a torrent.
 func (r *Resumer) Read(torrentID string) (spec *Spec, err error) {
 	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
 	defer func() {
@@ -174,6 +433,12 @@ func (r *Resumer) Read(torrentID string) (spec *Spec, err error) {
 		spec.InfoHash = make([]byte, len(value))
 		copy(spec.InfoHash, value)
 
+		value = b.Get(Keys.InfoHashV2)
+		if len(value) > 0 {
+			spec.InfoHashV2 = make([]byte, len(value))
+			copy(spec.InfoHashV2, value)
+		}
+
 		var err error
 		value = b.Get(Keys.Port)
 		spec.Port, err = strconv.Atoi(string(value))
@@ -221,6 +486,14 @@ func (r *Resumer) Read(torrentID string) (spec *Spec, err error) {
 			}
 		}
 
+		value = b.Get(Keys.HTTPSeeds)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.HTTPSeeds)
+			if err != nil {
+				return err
+			}
+		}
+
 		value = b.Get(Keys.FixedPeers)
 		if value != nil {
 			err = json.Unmarshal(value, &spec.FixedPeers)
@@ -235,12 +508,24 @@ func (r *Resumer) Read(torrentID string) (spec *Spec, err error) {
 			copy(spec.Info, value)
 		}
 
+		value = b.Get(Keys.PieceLayers)
+		if value != nil {
+			spec.PieceLayers = make([]byte, len(value))
+			copy(spec.PieceLayers, value)
+		}
+
 		value = b.Get(Keys.Bitfield)
 		if value != nil {
 			spec.Bitfield = make([]byte, len(value))
 			copy(spec.Bitfield, value)
 		}
 
+		value = b.Get(Keys.UnverifiedPieces)
+		if len(value) > 0 {
+			spec.UnverifiedPieces = make([]byte, len(value))
+			copy(spec.UnverifiedPieces, value)
+		}
+
 		value = b.Get(Keys.AddedAt)
 		if value != nil {
 			spec.AddedAt, err = time.Parse(time.RFC3339, string(value))
@@ -289,6 +574,22 @@ func (r *Resumer) Read(torrentID string) (spec *Spec, err error) {
 			}
 		}
 
+		value = b.Get(Keys.StopAfterDownload)
+		if value != nil {
+			spec.StopAfterDownload, err = strconv.ParseBool(string(value))
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.DisablePEX)
+		if value != nil {
+			spec.DisablePEX, err = strconv.ParseBool(string(value))
+			if err != nil {
+				return err
+			}
+		}
+
 		value = b.Get(Keys.CompleteCmdRun)
 		if value != nil {
 			spec.CompleteCmdRun, err = strconv.ParseBool(string(value))
@@ -297,6 +598,82 @@ func (r *Resumer) Read(torrentID string) (spec *Spec, err error) {
 			}
 		}
 
+		value = b.Get(Keys.SeedLimitReached)
+		if value != nil {
+			spec.SeedLimitReached, err = strconv.ParseBool(string(value))
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.CreationDate)
+		if value != nil {
+			spec.CreationDate, err = time.Parse(time.RFC3339, string(value))
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.Comment)
+		if value != nil {
+			spec.Comment = string(value)
+		}
+
+		value = b.Get(Keys.CreatedBy)
+		if value != nil {
+			spec.CreatedBy = string(value)
+		}
+
+		value = b.Get(Keys.Encoding)
+		if value != nil {
+			spec.Encoding = string(value)
+		}
+
+		value = b.Get(Keys.Peers)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.Peers)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.PeerFailures)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.PeerFailures)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.PartialPieces)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.PartialPieces)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.FilePriorities)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.FilePriorities)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.FileStats)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.FileStats)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.Location)
+		if value != nil {
+			spec.Location = string(value)
+		}
+
 		return nil
 	})
 	return
@@ -9,13 +9,17 @@ import (
 // Spec contains fields for resuming an existing torrent.
 type Spec struct {
 	InfoHash          []byte
+	InfoHashV2        []byte
 	Port              int
 	Name              string
 	Trackers          [][]string
 	URLList           []string
+	HTTPSeeds         []string
 	FixedPeers        []string
 	Info              []byte
+	PieceLayers       []byte
 	Bitfield          []byte
+	UnverifiedPieces  []byte
 	AddedAt           time.Time
 	BytesDownloaded   int64
 	BytesUploaded     int64
@@ -23,7 +27,26 @@ type Spec struct {
 	SeededFor         time.Duration
 	Started           bool
 	StopAfterDownload bool
+	DisablePEX        bool
 	CompleteCmdRun    bool
+	SeedLimitReached  bool
+	CreationDate      time.Time
+	Comment           string
+	CreatedBy         string
+	Encoding          string
+	Peers             []string
+	PeerFailures      map[string]int
+	PartialPieces     map[uint32][]int
+	FilePriorities    []int
+	FileStats         []FileStat
+	// Set if the files are moved out of the data directory. Not in JSON, moved data is saved to the data directory.
+	Location string
+}
+
+// FileStat contains the size and modification time of a file in the torrent.
+type FileStat struct {
+	Size    int64
+	ModTime time.Time
 }
 
 type jsonSpec struct {
@@ -31,6 +54,7 @@ type jsonSpec struct {
 	Name              string
 	Trackers          [][]string
 	URLList           []string
+	HTTPSeeds         []string
 	FixedPeers        []string
 	AddedAt           time.Time
 	BytesDownloaded   int64
@@ -38,13 +62,27 @@ type jsonSpec struct {
 	BytesWasted       int64
 	Started           bool
 	StopAfterDownload bool
+	DisablePEX        bool
 	CompleteCmdRun    bool
+	SeedLimitReached  bool
+	CreationDate      time.Time
+	Comment           string
+	CreatedBy         string
+	Encoding          string
+	Peers             []string
+	PeerFailures      map[string]int
+	PartialPieces     map[uint32][]int
+	FilePriorities    []int
+	FileStats         []FileStat
 
 	// JSON unsafe types
-	InfoHash  string
-	Info      string
-	Bitfield  string
-	SeededFor int64
+	InfoHash         string
+	InfoHashV2       string
+	Info             string
+	PieceLayers      string
+	Bitfield         string
+	UnverifiedPieces string
+	SeededFor        int64
 }
 
 // MarshalJSON converts the Spec to a JSON string.
@@ -54,6 +92,7 @@ func (s Spec) MarshalJSON() ([]byte, error) {
 		Name:              s.Name,
 		Trackers:          s.Trackers,
 		URLList:           s.URLList,
+		HTTPSeeds:         s.HTTPSeeds,
 		FixedPeers:        s.FixedPeers,
 		AddedAt:           s.AddedAt,
 		BytesDownloaded:   s.BytesDownloaded,
@@ -61,12 +100,26 @@ func (s Spec) MarshalJSON() ([]byte, error) {
 		BytesWasted:       s.BytesWasted,
 		Started:           s.Started,
 		StopAfterDownload: s.StopAfterDownload,
+		DisablePEX:        s.DisablePEX,
 		CompleteCmdRun:    s.CompleteCmdRun,
+		SeedLimitReached:  s.SeedLimitReached,
+		CreationDate:      s.CreationDate,
+		Comment:           s.Comment,
+		CreatedBy:         s.CreatedBy,
+		Encoding:          s.Encoding,
+		Peers:             s.Peers,
+		PeerFailures:      s.PeerFailures,
+		PartialPieces:     s.PartialPieces,
+		FilePriorities:    s.FilePriorities,
+		FileStats:         s.FileStats,
 
-		InfoHash:  base64.StdEncoding.EncodeToString(s.InfoHash),
-		Info:      base64.StdEncoding.EncodeToString(s.Info),
-		Bitfield:  base64.StdEncoding.EncodeToString(s.Bitfield),
-		SeededFor: int64(s.SeededFor),
+		InfoHash:         base64.StdEncoding.EncodeToString(s.InfoHash),
+		InfoHashV2:       base64.StdEncoding.EncodeToString(s.InfoHashV2),
+		Info:             base64.StdEncoding.EncodeToString(s.Info),
+		PieceLayers:      base64.StdEncoding.EncodeToString(s.PieceLayers),
+		Bitfield:         base64.StdEncoding.EncodeToString(s.Bitfield),
+		UnverifiedPieces: base64.StdEncoding.EncodeToString(s.UnverifiedPieces),
+		SeededFor:        int64(s.SeededFor),
 	}
 	return json.Marshal(j)
 }
@@ -82,19 +135,38 @@ func (s *Spec) UnmarshalJSON(b []byte) error {
 	if err != nil {
 		return err
 	}
+	if j.InfoHashV2 != "" {
+		s.InfoHashV2, err = base64.StdEncoding.DecodeString(j.InfoHashV2)
+		if err != nil {
+			return err
+		}
+	}
 	s.Info, err = base64.StdEncoding.DecodeString(j.Info)
 	if err != nil {
 		return err
 	}
+	if j.PieceLayers != "" {
+		s.PieceLayers, err = base64.StdEncoding.DecodeString(j.PieceLayers)
+		if err != nil {
+			return err
+		}
+	}
 	s.Bitfield, err = base64.StdEncoding.DecodeString(j.Bitfield)
 	if err != nil {
 		return err
 	}
+	if j.UnverifiedPieces != "" {
+		s.UnverifiedPieces, err = base64.StdEncoding.DecodeString(j.UnverifiedPieces)
+		if err != nil {
+			return err
+		}
+	}
 	s.SeededFor = time.Duration(j.SeededFor)
 	s.Port = j.Port
 	s.Name = j.Name
 	s.Trackers = j.Trackers
 	s.URLList = j.URLList
+	s.HTTPSeeds = j.HTTPSeeds
 	s.FixedPeers = j.FixedPeers
 	s.AddedAt = j.AddedAt
 	s.BytesDownloaded = j.BytesDownloaded
@@ -102,6 +174,17 @@ func (s *Spec) UnmarshalJSON(b []byte) error {
 	s.BytesWasted = j.BytesWasted
 	s.Started = j.Started
 	s.StopAfterDownload = j.StopAfterDownload
+	s.DisablePEX = j.DisablePEX
 	s.CompleteCmdRun = j.CompleteCmdRun
+	s.SeedLimitReached = j.SeedLimitReached
+	s.CreationDate = j.CreationDate
+	s.Comment = j.Comment
+	s.CreatedBy = j.CreatedBy
+	s.Encoding = j.Encoding
+	s.Peers = j.Peers
+	s.PeerFailures = j.PeerFailures
+	s.PartialPieces = j.PartialPieces
+	s.FilePriorities = j.FilePriorities
+	s.FileStats = j.FileStats
 	return nil
 }
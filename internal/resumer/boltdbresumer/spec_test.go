@@ -7,8 +7,10 @@ import (
 
 func TestMarshalUnmarshalSpec(t *testing.T) {
 	s := Spec{
-		Info: []byte{1, 2, 3},
-		Name: "foo",
+		Info:         []byte{1, 2, 3},
+		Name:         "foo",
+		Peers:        []string{"1.1.1.1:1"},
+		PeerFailures: map[string]int{"1.1.1.1:1": 2},
 	}
 	b, err := s.MarshalJSON()
 	if err != nil {
@@ -25,4 +27,7 @@ func TestMarshalUnmarshalSpec(t *testing.T) {
 	if s.Name != s2.Name {
 		t.FailNow()
 	}
+	if len(s2.Peers) != 1 || s2.PeerFailures[s2.Peers[0]] != 2 {
+		t.FailNow()
+	}
 }
@@ -8,3 +8,16 @@ type Stats struct {
 	BytesWasted     int64
 	SeededFor       int64 // time.Duration
 }
+
+// Store keeps the resume data of torrents. Resume data of a torrent is saved as a single value.
+// Methods may be called from multiple goroutines.
+type Store interface {
+	// Save replaces the resume data of the torrent with id.
+	Save(id string, data []byte) error
+	// Load returns the resume data of the torrent with id. Returns nil data if it does not exist.
+	Load(id string) ([]byte, error)
+	// Delete removes the resume data of the torrent with id.
+	Delete(id string) error
+	// List returns the IDs of the torrents that have resume data.
+	List() ([]string, error)
+}
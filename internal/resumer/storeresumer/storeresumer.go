@@ -0,0 +1,214 @@
+// Package storeresumer provides a Resumer implementation that saves the resume data of torrents to a resumer.Store.
+package storeresumer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/rain/internal/resumer"
+	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
+)
+
+// Resumer contains methods for saving/loading resume information of a torrent to a resumer.Store.
+// Each write loads the resume data of the torrent, changes it and saves it back to the store.
+type Resumer struct {
+	store resumer.Store
+	m     sync.Mutex
+}
+
+// record is the value that is saved to the store for each torrent.
+type record struct {
+	Spec *boltdbresumer.Spec
+	// Location is not included in the JSON of Spec.
+	Location string
+}
+
+// New returns a new Resumer.
+func New(store resumer.Store) *Resumer {
+	return &Resumer{store: store}
+}
+
+// Write the torrent spec for torrent with `torrentID`.
+func (r *Resumer) Write(torrentID string, spec *boltdbresumer.Spec) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.save(torrentID, spec)
+}
+
+// Read the resume data of a torrent.
+func (r *Resumer) Read(torrentID string) (*boltdbresumer.Spec, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	spec, err := r.load(torrentID)
+	if err != nil {
+		return nil, err
+	}
+	if spec == nil {
+		return nil, fmt.Errorf("resume data not found: %q", torrentID)
+	}
+	return spec, nil
+}
+
+// Delete the resume data of a torrent.
+func (r *Resumer) Delete(torrentID string) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.store.Delete(torrentID)
+}
+
+// List returns the IDs of torrents that have resume data.
+func (r *Resumer) List() ([]string, error) {
+	return r.store.List()
+}
+
+// WriteInfo writes only the info dict of a torrent.
+func (r *Resumer) WriteInfo(torrentID string, value []byte) error {
+	return r.update(torrentID, func(spec *boltdbresumer.Spec) {
+		spec.Info = value
+	})
+}
+
+// WriteBitfield writes the bitfield of a torrent and the stats of its files at the time the bitfield is created.
+// Saved file stats are not changed if fileStats is nil.
+// Transfer stats are written at the same time, so they are consistent with the bitfield.
+func (r *Resumer) WriteBitfield(torrentID string, value []byte, fileStats []boltdbresumer.FileStat, stats resumer.Stats) error {
+	return r.update(torrentID, func(spec *boltdbresumer.Spec) {
+		spec.Bitfield = value
+		if fileStats != nil {
+			spec.FileStats = fileStats
+		}
+		setStats(spec, stats)
+	})
+}
+
+// DeleteBitfield deletes the bitfield of a torrent, so its files are verified when it is started.
+func (r *Resumer) DeleteBitfield(torrentID string) error {
+	return r.update(torrentID, func(spec *boltdbresumer.Spec) {
+		spec.Bitfield = nil
+		spec.UnverifiedPieces = nil
+	})
+}
+
+// WriteUnverifiedPieces writes the pieces of a torrent that are in the bitfield but not verified yet.
+func (r *Resumer) WriteUnverifiedPieces(torrentID string, value []byte) error {
+	return r.update(torrentID, func(spec *boltdbresumer.Spec) {
+		spec.UnverifiedPieces = value
+	})
+}
+
+// WriteStats writes the transfer stats of a torrent.
+func (r *Resumer) WriteStats(torrentID string, stats resumer.Stats) error {
+	return r.update(torrentID, func(spec *boltdbresumer.Spec) {
+		setStats(spec, stats)
+	})
+}
+
+// WriteTrackers writes the tracker tiers of a torrent.
+func (r *Resumer) WriteTrackers(torrentID string, value [][]string) error {
+	return r.update(torrentID, func(spec *boltdbresumer.Spec) {
+		spec.Trackers = value
+	})
+}
+
+// WritePeers writes the addresses of peers that are going to be connected when the torrent is resumed
+// and the number of consecutive failed connections to them.
+func (r *Resumer) WritePeers(torrentID string, value []string, failures map[string]int) error {
+	return r.update(torrentID, func(spec *boltdbresumer.Spec) {
+		spec.Peers = value
+		spec.PeerFailures = failures
+	})
+}
+
+// WritePartialPieces writes the indexes of downloaded blocks of incomplete pieces, keyed by piece index.
+func (r *Resumer) WritePartialPieces(torrentID string, value map[uint32][]int) error {
+	return r.update(torrentID, func(spec *boltdbresumer.Spec) {
+		spec.PartialPieces = value
+	})
+}
+
+// WriteFilePriorities writes the priorities of files in a torrent.
+func (r *Resumer) WriteFilePriorities(torrentID string, value []int) error {
+	return r.update(torrentID, func(spec *boltdbresumer.Spec) {
+		spec.FilePriorities = value
+	})
+}
+
+// WriteLocation writes the directory that the files of a torrent are moved to.
+func (r *Resumer) WriteLocation(torrentID string, value string) error {
+	return r.update(torrentID, func(spec *boltdbresumer.Spec) {
+		spec.Location = value
+	})
+}
+
+// WriteStarted writes the start status of a torrent.
+func (r *Resumer) WriteStarted(torrentID string, value bool) error {
+	return r.update(torrentID, func(spec *boltdbresumer.Spec) {
+		spec.Started = value
+	})
+}
+
+// WriteCompleteCmdRun writes that the completion command of a torrent has been run.
+func (r *Resumer) WriteCompleteCmdRun(torrentID string) error {
+	return r.update(torrentID, func(spec *boltdbresumer.Spec) {
+		spec.CompleteCmdRun = true
+	})
+}
+
+// WriteSeedLimitReached writes whether a torrent is stopped after reaching its seed limits.
+func (r *Resumer) WriteSeedLimitReached(torrentID string, value bool) error {
+	return r.update(torrentID, func(spec *boltdbresumer.Spec) {
+		spec.SeedLimitReached = value
+	})
+}
+
+func setStats(spec *boltdbresumer.Spec, stats resumer.Stats) {
+	spec.BytesDownloaded = stats.BytesDownloaded
+	spec.BytesUploaded = stats.BytesUploaded
+	spec.BytesWasted = stats.BytesWasted
+	spec.SeededFor = time.Duration(stats.SeededFor)
+}
+
+// update changes the resume data of a torrent with f. Does nothing if the torrent has no resume data.
+func (r *Resumer) update(torrentID string, f func(spec *boltdbresumer.Spec)) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	spec, err := r.load(torrentID)
+	if err != nil {
+		return err
+	}
+	if spec == nil {
+		return nil
+	}
+	f(spec)
+	return r.save(torrentID, spec)
+}
+
+func (r *Resumer) load(torrentID string) (*boltdbresumer.Spec, error) {
+	b, err := r.store.Load(torrentID)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, nil
+	}
+	var rec record
+	err = json.Unmarshal(b, &rec)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Spec == nil {
+		return nil, fmt.Errorf("invalid resume data: %q", torrentID)
+	}
+	rec.Spec.Location = rec.Location
+	return rec.Spec, nil
+}
+
+func (r *Resumer) save(torrentID string, spec *boltdbresumer.Spec) error {
+	b, err := json.Marshal(record{Spec: spec, Location: spec.Location})
+	if err != nil {
+		return err
+	}
+	return r.store.Save(torrentID, b)
+}
@@ -0,0 +1,101 @@
+package storeresumer
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/resumer"
+	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
+)
+
+type memoryStore struct {
+	m    sync.Mutex
+	data map[string][]byte
+}
+
+func (s *memoryStore) Save(id string, data []byte) error {
+	s.m.Lock()
+	s.data[id] = data
+	s.m.Unlock()
+	return nil
+}
+
+func (s *memoryStore) Load(id string) ([]byte, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.data[id], nil
+}
+
+func (s *memoryStore) Delete(id string) error {
+	s.m.Lock()
+	delete(s.data, id)
+	s.m.Unlock()
+	return nil
+}
+
+func (s *memoryStore) List() ([]string, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	ids := make([]string, 0, len(s.data))
+	for id := range s.data {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func TestResumer(t *testing.T) {
+	r := New(&memoryStore{data: make(map[string][]byte)})
+	spec := &boltdbresumer.Spec{
+		InfoHash: []byte("01234567890123456789"),
+		Port:     6881,
+		Name:     "foo",
+		Location: "/tmp/foo",
+	}
+	if err := r.Write("id", spec); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WriteBitfield("id", []byte{0xff}, []boltdbresumer.FileStat{{Size: 1}}, resumer.Stats{BytesDownloaded: 10, SeededFor: int64(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WriteStarted("id", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WritePeers("id", []string{"1.1.1.1:1"}, map[string]int{"1.1.1.1:1": 2}); err != nil {
+		t.Fatal(err)
+	}
+	// Writes to unknown torrents are ignored.
+	if err := r.WriteStarted("unknown", true); err != nil {
+		t.Fatal(err)
+	}
+	ids, err := r.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != "id" {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+	spec2, err := r.Read("id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(spec2.InfoHash, spec.InfoHash) || spec2.Port != 6881 || spec2.Name != "foo" || spec2.Location != "/tmp/foo" {
+		t.Fatalf("spec is not saved: %+v", spec2)
+	}
+	if !bytes.Equal(spec2.Bitfield, []byte{0xff}) || len(spec2.FileStats) != 1 || spec2.BytesDownloaded != 10 || spec2.SeededFor != time.Minute {
+		t.Fatalf("bitfield and stats are not saved: %+v", spec2)
+	}
+	if !spec2.Started {
+		t.Fatal("started is not saved")
+	}
+	if len(spec2.Peers) != 1 || spec2.PeerFailures["1.1.1.1:1"] != 2 {
+		t.Fatalf("peers are not saved: %v %v", spec2.Peers, spec2.PeerFailures)
+	}
+	if err = r.Delete("id"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.Read("id"); err == nil {
+		t.Fatal("deleted torrent must not be read")
+	}
+}
@@ -9,33 +9,72 @@ type Torrent struct {
 	AddedAt  Time
 }
 
+// Metainfo contains the optional fields of a .torrent file.
+type Metainfo struct {
+	CreationDate Time
+	Comment      string
+	CreatedBy    string
+	Encoding     string
+}
+
 // Peer of a Torrent.
 type Peer struct {
-	ID                 string
-	Client             string
-	Addr               string
-	Source             string
-	ConnectedAt        Time
-	Downloading        bool
-	ClientInterested   bool
-	ClientChoking      bool
-	PeerInterested     bool
-	PeerChoking        bool
-	OptimisticUnchoked bool
-	Snubbed            bool
-	EncryptedHandshake bool
-	EncryptedStream    bool
-	DownloadSpeed      int
-	UploadSpeed        int
+	ID                   string
+	Client               string
+	Addr                 string
+	Source               string
+	ConnectedAt          Time
+	Downloading          bool
+	ClientInterested     bool
+	ClientChoking        bool
+	PeerInterested       bool
+	PeerChoking          bool
+	OptimisticUnchoked   bool
+	Snubbed              bool
+	EncryptedHandshake   bool
+	EncryptedStream      bool
+	DownloadSpeed        int
+	UploadSpeed          int
+	RequestsOut          int
+	MaxRequestsOut       int
+	PeerRequestQueue     int
+	RequestLatencyMillis int
+	ReciprocationRatio   float64
 }
 
 // Webseed source of a Torrent.
 type Webseed struct {
 	URL           string
+	Trusted       bool
 	Error         string
 	DownloadSpeed int
 }
 
+// File in a Torrent.
+type File struct {
+	Path    string
+	Length  int64
+	Padding bool
+	// -1 for skipped files, 0 for normal priority, 1 for high priority.
+	Priority int
+}
+
+// PieceProgress contains the number of received blocks of a piece that is being downloaded.
+type PieceProgress struct {
+	BlocksDone  int
+	BlocksTotal int
+}
+
+// SwarmStats contains the estimated number of seeders and leechers of a Torrent.
+type SwarmStats struct {
+	Seeders           int
+	Leechers          int
+	TrackerSeeders    int
+	TrackerLeechers   int
+	ConnectedSeeders  int
+	ConnectedLeechers int
+}
+
 // Tracker of a Torrent.
 type Tracker struct {
 	URL           string
@@ -47,6 +86,7 @@ type Tracker struct {
 	ErrorUnknown  bool
 	ErrorInternal string
 	LastAnnounce  Time
+	LastSuccess   Time
 	NextAnnounce  Time
 }
 
@@ -57,8 +97,10 @@ type SessionStats struct {
 	Peers          int
 	PortsAvailable int
 
-	BlockListRules   int
-	BlockListRecency int
+	BlockListRules    int
+	BlockListRecency  int
+	BlockListRejected int
+	IncomingRejected  int
 
 	ReadCacheObjects     int
 	ReadCacheSize        int64
@@ -89,11 +131,12 @@ type Stats struct {
 	Status   string
 	Error    string
 	Pieces   struct {
-		Checked   uint32
-		Have      uint32
-		Missing   uint32
-		Available uint32
-		Total     uint32
+		Checked      uint32
+		Have         uint32
+		Missing      uint32
+		Available    uint32
+		Total        uint32
+		Availability float64
 	}
 	Bytes struct {
 		Total      int64
@@ -105,9 +148,13 @@ type Stats struct {
 		Wasted     int64
 	}
 	Peers struct {
-		Total    int
-		Incoming int
-		Outgoing int
+		Total      int
+		Incoming   int
+		Outgoing   int
+		DialTarget int
+		Seeking    bool
+		Choked     int
+		Unchoked   int
 	}
 	Handshakes struct {
 		Total    int
@@ -119,6 +166,15 @@ type Stats struct {
 		Tracker int
 		DHT     int
 		PEX     int
+		LSD     int
+		Resume  int
+		Saved   int
+	}
+	Swarm struct {
+		Seeders    int
+		Leechers   int
+		Trackers   int
+		LastUpdate Time
 	}
 	Downloads struct {
 		Total   int
@@ -135,6 +191,7 @@ type Stats struct {
 	Private     bool
 	PieceLength uint32
 	SeededFor   uint
+	SeedRatio   float64
 	Speed       struct {
 		Download int
 		Upload   int
@@ -152,6 +209,16 @@ type GetMagnetResponse struct {
 	Magnet string
 }
 
+// GetTorrentMetainfoRequest contains request arguments for Session.GetTorrentMetainfo method.
+type GetTorrentMetainfoRequest struct {
+	ID string
+}
+
+// GetTorrentMetainfoResponse contains response arguments for Session.GetTorrentMetainfo method.
+type GetTorrentMetainfoResponse struct {
+	Metainfo Metainfo
+}
+
 // GetTorrentRequest contains request arguments for Session.GetTorrent method.
 type GetTorrentRequest struct {
 	ID string
@@ -209,6 +276,19 @@ type RemoveTorrentRequest struct {
 type RemoveTorrentResponse struct {
 }
 
+// RemoveTorrentWaitRequest contains request arguments for Session.RemoveTorrentWait method.
+type RemoveTorrentWaitRequest struct {
+	ID string
+	// Timeout in seconds. Zero means no limit.
+	Timeout int
+}
+
+// RemoveTorrentWaitResponse contains response arguments for Session.RemoveTorrentWait method.
+type RemoveTorrentWaitResponse struct {
+	// True if the stopped event is announced to all trackers of the torrent before removal.
+	StoppedEventAnnounced bool
+}
+
 // CleanDatabaseRequest contains request arguments for Session.CleanDatabase method.
 type CleanDatabaseRequest struct {
 }
@@ -266,6 +346,48 @@ type GetTorrentWebseedsResponse struct {
 	Webseeds []Webseed
 }
 
+// GetTorrentFilesRequest contains request arguments for Session.GetTorrentFiles method.
+type GetTorrentFilesRequest struct {
+	ID string
+}
+
+// GetTorrentFilesResponse contains response arguments for Session.GetTorrentFiles method.
+type GetTorrentFilesResponse struct {
+	Files []File
+}
+
+// SetFilePriorityRequest contains request arguments for Session.SetFilePriority method.
+type SetFilePriorityRequest struct {
+	ID       string
+	Index    int
+	Priority int
+}
+
+// SetFilePriorityResponse contains response arguments for Session.SetFilePriority method.
+type SetFilePriorityResponse struct {
+}
+
+// GetTorrentSwarmStatsRequest contains request arguments for Session.GetTorrentSwarmStats method.
+type GetTorrentSwarmStatsRequest struct {
+	ID string
+}
+
+// GetTorrentSwarmStatsResponse contains response arguments for Session.GetTorrentSwarmStats method.
+type GetTorrentSwarmStatsResponse struct {
+	SwarmStats SwarmStats
+}
+
+// GetTorrentPieceProgressRequest contains request arguments for Session.GetTorrentPieceProgress method.
+type GetTorrentPieceProgressRequest struct {
+	ID    string
+	Index uint32
+}
+
+// GetTorrentPieceProgressResponse contains response arguments for Session.GetTorrentPieceProgress method.
+type GetTorrentPieceProgressResponse struct {
+	PieceProgress PieceProgress
+}
+
 // StartTorrentRequest contains request arguments for Session.StartTorrent method.
 type StartTorrentRequest struct {
 	ID string
@@ -284,6 +406,24 @@ type StopTorrentRequest struct {
 type StopTorrentResponse struct {
 }
 
+// PauseTorrentRequest contains request arguments for Session.PauseTorrent method.
+type PauseTorrentRequest struct {
+	ID string
+}
+
+// PauseTorrentResponse contains response arguments for Session.PauseTorrent method.
+type PauseTorrentResponse struct {
+}
+
+// ResumeTorrentRequest contains request arguments for Session.ResumeTorrent method.
+type ResumeTorrentRequest struct {
+	ID string
+}
+
+// ResumeTorrentResponse contains response arguments for Session.ResumeTorrent method.
+type ResumeTorrentResponse struct {
+}
+
 // AnnounceTorrentRequest contains request arguments for Session.AnnounceTorrent method.
 type AnnounceTorrentRequest struct {
 	ID string
@@ -36,6 +36,20 @@ func (s *Semaphore) Wait() {
 	atomic.AddInt32(&s.active, 1)
 }
 
+// WaitOrClose waits for the semaphore like Wait.
+// Returns false without acquiring the semaphore if closeC is closed before the resource is available.
+func (s *Semaphore) WaitOrClose(closeC <-chan struct{}) bool {
+	atomic.AddInt32(&s.waiting, 1)
+	defer atomic.AddInt32(&s.waiting, -1)
+	select {
+	case s.c <- token{}:
+		atomic.AddInt32(&s.active, 1)
+		return true
+	case <-closeC:
+		return false
+	}
+}
+
 // Signal the semaphore. A random waiting goroutine will be waken up.
 func (s *Semaphore) Signal() {
 	<-s.c
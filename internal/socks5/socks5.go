@@ -0,0 +1,212 @@
+// Package socks5 implements the client side of SOCKS5 protocol for making TCP connections and sending UDP packets through a proxy.
+package socks5
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	version5 = 5
+
+	methodNoAuth       = 0
+	methodNoAcceptable = 0xff
+
+	cmdConnect      = 1
+	cmdUDPAssociate = 3
+
+	atypIPv4   = 1
+	atypDomain = 3
+	atypIPv6   = 4
+)
+
+var replyMessages = map[byte]string{
+	1: "general SOCKS server failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// Dialer makes TCP connections and UDP associations through a SOCKS5 proxy.
+type Dialer struct {
+	// Address of the proxy server in host:port form.
+	ProxyAddr string
+}
+
+// New returns a new Dialer for the proxy at addr.
+func New(addr string) *Dialer {
+	return &Dialer{ProxyAddr: addr}
+}
+
+// DialContext connects to the address via the proxy.
+// Host names are sent to the proxy as is, without being resolved locally.
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("socks5: unsupported network: %s", network)
+	}
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("socks5: invalid port: %s", portStr)
+	}
+	conn, _, err := d.request(ctx, cmdConnect, host, uint16(port))
+	return conn, err
+}
+
+// request connects to the proxy and sends the command.
+// Returns the connection to the proxy and the address bound by the proxy for the command.
+func (d *Dialer) request(ctx context.Context, cmd byte, host string, port uint16) (net.Conn, *net.UDPAddr, error) {
+	var nd net.Dialer
+	conn, err := nd.DialContext(ctx, "tcp", d.ProxyAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			conn.Close()
+		}
+	}()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetDeadline(deadline); err != nil {
+			return nil, nil, err
+		}
+	}
+	// Close the connection if context is cancelled during the proxy handshake.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	bound, err := handshake(conn, cmd, host, port)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, nil, err
+	}
+	if err = conn.SetDeadline(time.Time{}); err != nil {
+		return nil, nil, err
+	}
+	return conn, bound, nil
+}
+
+// handshake negotiates the authentication method and sends the command.
+// Bound address is nil if the proxy replies with a host name.
+func handshake(conn net.Conn, cmd byte, host string, port uint16) (*net.UDPAddr, error) {
+	// Negotiate authentication method.
+	_, err := conn.Write([]byte{version5, 1, methodNoAuth})
+	if err != nil {
+		return nil, err
+	}
+	var resp [2]byte
+	_, err = io.ReadFull(conn, resp[:])
+	if err != nil {
+		return nil, err
+	}
+	if resp[0] != version5 {
+		return nil, fmt.Errorf("socks5: invalid version: %d", resp[0])
+	}
+	if resp[1] == methodNoAcceptable {
+		return nil, errors.New("socks5: no acceptable authentication method")
+	}
+	if resp[1] != methodNoAuth {
+		return nil, fmt.Errorf("socks5: unsupported authentication method: %d", resp[1])
+	}
+
+	// Send command request.
+	req, err := appendAddr([]byte{version5, cmd, 0}, host, port)
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.Write(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read reply.
+	var hdr [3]byte
+	_, err = io.ReadFull(conn, hdr[:])
+	if err != nil {
+		return nil, err
+	}
+	if hdr[0] != version5 {
+		return nil, fmt.Errorf("socks5: invalid version: %d", hdr[0])
+	}
+	if hdr[1] != 0 {
+		if msg, ok := replyMessages[hdr[1]]; ok {
+			return nil, errors.New("socks5: " + msg)
+		}
+		return nil, fmt.Errorf("socks5: unknown reply code: %d", hdr[1])
+	}
+	return readAddr(conn)
+}
+
+// appendAddr appends the address in SOCKS5 format to b.
+func appendAddr(b []byte, host string, port uint16) ([]byte, error) {
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return nil, errors.New("socks5: host name too long")
+		}
+		b = append(b, atypDomain, byte(len(host)))
+		b = append(b, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		b = append(b, atypIPv4)
+		b = append(b, ip4...)
+	} else {
+		b = append(b, atypIPv6)
+		b = append(b, ip.To16()...)
+	}
+	return append(b, byte(port>>8), byte(port)), nil
+}
+
+// readAddr reads an address in SOCKS5 format. Returns nil address if it is a host name.
+func readAddr(r io.Reader) (*net.UDPAddr, error) {
+	var atyp [1]byte
+	_, err := io.ReadFull(r, atyp[:])
+	if err != nil {
+		return nil, err
+	}
+	var addrLen int
+	switch atyp[0] {
+	case atypIPv4:
+		addrLen = net.IPv4len
+	case atypIPv6:
+		addrLen = net.IPv6len
+	case atypDomain:
+		var l [1]byte
+		_, err = io.ReadFull(r, l[:])
+		if err != nil {
+			return nil, err
+		}
+		addrLen = int(l[0])
+	default:
+		return nil, fmt.Errorf("socks5: unknown address type: %d", atyp[0])
+	}
+	b := make([]byte, addrLen+2)
+	_, err = io.ReadFull(r, b)
+	if err != nil {
+		return nil, err
+	}
+	if atyp[0] == atypDomain {
+		return nil, nil
+	}
+	return &net.UDPAddr{IP: net.IP(b[:addrLen]), Port: int(b[addrLen])<<8 | int(b[addrLen+1])}, nil
+}
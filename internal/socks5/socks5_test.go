@@ -0,0 +1,212 @@
+package socks5
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// serveOne accepts a single SOCKS5 connection and sends the requested host name back to the client.
+func serveOne(t *testing.T, l net.Listener) {
+	conn, err := l.Accept()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+	buf := make([]byte, 3)
+	if _, err = io.ReadFull(conn, buf); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = conn.Write([]byte{version5, methodNoAuth}); err != nil {
+		t.Error(err)
+		return
+	}
+	hdr := make([]byte, 5)
+	if _, err = io.ReadFull(conn, hdr); err != nil {
+		t.Error(err)
+		return
+	}
+	if hdr[1] != cmdConnect || hdr[3] != atypDomain {
+		t.Errorf("unexpected request: %v", hdr)
+		return
+	}
+	host := make([]byte, int(hdr[4])+2)
+	if _, err = io.ReadFull(conn, host); err != nil {
+		t.Error(err)
+		return
+	}
+	port := int(host[len(host)-2])<<8 | int(host[len(host)-1])
+	reply := []byte{version5, 0, 0, atypIPv4, 127, 0, 0, 1, 0, 0}
+	if _, err = conn.Write(reply); err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = conn.Write([]byte(net.JoinHostPort(string(host[:len(host)-2]), strconv.Itoa(port))))
+}
+
+func TestDial(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go serveOne(t, l)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d := New(l.Addr().String())
+	conn, err := d.DialContext(ctx, "tcp", "example.onion:6881")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	b, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "example.onion:6881" {
+		t.Fatalf("proxy received invalid address: %q", b)
+	}
+}
+
+func TestDialRefused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 3)
+		_, _ = io.ReadFull(conn, buf)
+		_, _ = conn.Write([]byte{version5, methodNoAuth})
+		_, _ = io.ReadFull(conn, make([]byte, 10))
+		_, _ = conn.Write([]byte{version5, 5, 0, atypIPv4, 0, 0, 0, 0, 0, 0})
+	}()
+
+	d := New(l.Addr().String())
+	_, err = d.DialContext(context.Background(), "tcp", "127.0.0.1:1")
+	if err == nil || err.Error() != "socks5: connection refused" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// serveUDP accepts a single UDP ASSOCIATE request and sends the packets back to the client with the same header.
+func serveUDP(t *testing.T, l net.Listener) {
+	conn, err := l.Accept()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+	buf := make([]byte, 3)
+	if _, err = io.ReadFull(conn, buf); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = conn.Write([]byte{version5, methodNoAuth}); err != nil {
+		t.Error(err)
+		return
+	}
+	req := make([]byte, 10)
+	if _, err = io.ReadFull(conn, req); err != nil {
+		t.Error(err)
+		return
+	}
+	if req[1] != cmdUDPAssociate {
+		t.Errorf("unexpected request: %v", req)
+		return
+	}
+	relay, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer relay.Close()
+	// Reply with unspecified address. Client must send packets to the proxy host.
+	port := relay.LocalAddr().(*net.UDPAddr).Port
+	reply := []byte{version5, 0, 0, atypIPv4, 0, 0, 0, 0, byte(port >> 8), byte(port)}
+	if _, err = conn.Write(reply); err != nil {
+		t.Error(err)
+		return
+	}
+	pkt := make([]byte, 1500)
+	n, from, err := relay.ReadFrom(pkt)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = relay.WriteTo(pkt[:n], from); err != nil {
+		t.Error(err)
+	}
+	// Keep the association until the client closes it.
+	_, _ = io.Copy(io.Discard, conn)
+}
+
+func TestListenPacket(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go serveUDP(t, l)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d := New(l.Addr().String())
+	conn, err := d.ListenPacket(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	dest := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 6969}
+	if _, err = conn.WriteTo([]byte("hello"), dest); err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 100)
+	n, from, err := conn.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b[:n]) != "hello" || from.String() != dest.String() {
+		t.Fatalf("invalid packet from %s: %q", from, b[:n])
+	}
+}
+
+func TestListenPacketNotSupported(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 3)
+		_, _ = io.ReadFull(conn, buf)
+		_, _ = conn.Write([]byte{version5, methodNoAuth})
+		_, _ = io.ReadFull(conn, make([]byte, 10))
+		_, _ = conn.Write([]byte{version5, 7, 0, atypIPv4, 0, 0, 0, 0, 0, 0})
+	}()
+
+	d := New(l.Addr().String())
+	_, err = d.ListenPacket(context.Background())
+	if err == nil || err.Error() != "socks5: command not supported" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package socks5
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+)
+
+var errFragmented = errors.New("socks5: fragmented UDP packet")
+
+// PacketConn sends and receives UDP packets through a SOCKS5 proxy.
+// The association is valid while the TCP connection to the proxy is open.
+type PacketConn struct {
+	ctrl  net.Conn
+	conn  *net.UDPConn
+	relay *net.UDPAddr
+}
+
+var _ net.PacketConn = (*PacketConn)(nil)
+
+// ListenPacket asks the proxy to relay UDP packets with UDP ASSOCIATE command.
+// Returns an error if the proxy does not support UDP.
+func (d *Dialer) ListenPacket(ctx context.Context) (*PacketConn, error) {
+	ctrl, relay, err := d.request(ctx, cmdUDPAssociate, "0.0.0.0", 0)
+	if err != nil {
+		return nil, err
+	}
+	if relay == nil {
+		ctrl.Close()
+		return nil, errors.New("socks5: proxy replied with a host name for UDP relay")
+	}
+	// Proxy may reply with an unspecified address, meaning that packets must be sent to the proxy host.
+	if relay.IP.IsUnspecified() {
+		relay.IP = ctrl.RemoteAddr().(*net.TCPAddr).IP
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+	go func() {
+		// Proxy terminates the association when the TCP connection is closed.
+		_, _ = io.Copy(ioutil.Discard, ctrl)
+		conn.Close()
+	}()
+	return &PacketConn{ctrl: ctrl, conn: conn, relay: relay}, nil
+}
+
+// ReadFrom reads a packet relayed by the proxy. Returned address is the original sender of the packet.
+// Fragmented packets are dropped.
+func (c *PacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	buf := make([]byte, len(b)+262)
+	for {
+		n, _, err := c.conn.ReadFrom(buf)
+		if err != nil {
+			return 0, nil, err
+		}
+		addr, data, err := parseUDPHeader(buf[:n])
+		if err != nil {
+			continue
+		}
+		return copy(b, data), addr, nil
+	}
+}
+
+// WriteTo sends the packet to addr via the proxy. addr must be a *net.UDPAddr.
+func (c *PacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	uaddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return 0, &net.OpError{Op: "write", Net: "udp", Addr: addr, Err: errors.New("socks5: address must be UDP")}
+	}
+	pkt, err := appendAddr([]byte{0, 0, 0}, uaddr.IP.String(), uint16(uaddr.Port))
+	if err != nil {
+		return 0, err
+	}
+	pkt = append(pkt, b...)
+	_, err = c.conn.WriteTo(pkt, c.relay)
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close the association.
+func (c *PacketConn) Close() error {
+	c.ctrl.Close()
+	return c.conn.Close()
+}
+
+// LocalAddr returns the local address of the UDP socket that sends packets to the proxy.
+func (c *PacketConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// SetDeadline sets the read and write deadlines.
+func (c *PacketConn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline.
+func (c *PacketConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline.
+func (c *PacketConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// parseUDPHeader returns the sender address and the data in a packet relayed by the proxy.
+func parseUDPHeader(pkt []byte) (*net.UDPAddr, []byte, error) {
+	if len(pkt) < 4 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	if pkt[2] != 0 {
+		return nil, nil, errFragmented
+	}
+	r := bytes.NewReader(pkt[3:])
+	addr, err := readAddr(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if addr == nil {
+		return nil, nil, errors.New("socks5: sender of UDP packet is a host name")
+	}
+	return addr, pkt[len(pkt)-r.Len():], nil
+}
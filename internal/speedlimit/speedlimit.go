@@ -0,0 +1,69 @@
+package speedlimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/juju/ratelimit"
+)
+
+// Limiter is a token bucket whose rate can be changed while it is in use.
+// Limiters can be chained so that the total rate of all children stays under the rate of their parent.
+type Limiter struct {
+	parent *Limiter
+
+	m      sync.RWMutex
+	rate   int64
+	bucket *ratelimit.Bucket
+}
+
+// New returns a new Limiter that allows rate bytes per second. A rate of 0 means unlimited.
+// If parent is not nil, bytes taken from the Limiter are also taken from the parent.
+func New(rate int64, parent *Limiter) *Limiter {
+	l := &Limiter{parent: parent}
+	l.SetRate(rate)
+	return l
+}
+
+// Rate returns the current rate in bytes per second. 0 means unlimited.
+func (l *Limiter) Rate() int64 {
+	l.m.RLock()
+	defer l.m.RUnlock()
+	return l.rate
+}
+
+// SetRate changes the rate of the Limiter. A rate of 0 means unlimited.
+func (l *Limiter) SetRate(rate int64) {
+	if rate < 0 {
+		rate = 0
+	}
+	l.m.Lock()
+	defer l.m.Unlock()
+	if rate == l.rate && (rate == 0 || l.bucket != nil) {
+		return
+	}
+	l.rate = rate
+	if rate == 0 {
+		l.bucket = nil
+		return
+	}
+	l.bucket = ratelimit.NewBucketWithRate(float64(rate), rate)
+}
+
+// Take removes n bytes from the Limiter and its parents.
+// Returns the time to wait before the bytes can be transferred.
+func (l *Limiter) Take(n int64) time.Duration {
+	if l == nil {
+		return 0
+	}
+	var d time.Duration
+	l.m.RLock()
+	if l.bucket != nil {
+		d = l.bucket.Take(n)
+	}
+	l.m.RUnlock()
+	if pd := l.parent.Take(n); pd > d {
+		d = pd
+	}
+	return d
+}
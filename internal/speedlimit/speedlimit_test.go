@@ -0,0 +1,28 @@
+package speedlimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	parent := New(0, nil)
+	l := New(1000, parent)
+
+	// Bucket is full at start.
+	assert.Equal(t, time.Duration(0), l.Take(1000))
+	assert.True(t, l.Take(1000) > 900*time.Millisecond)
+
+	// Removing the limit takes effect immediately.
+	l.SetRate(0)
+	assert.Equal(t, time.Duration(0), l.Take(1000))
+
+	// Limit of parent is applied to children.
+	parent.SetRate(1000)
+	assert.Equal(t, time.Duration(0), l.Take(1000))
+	assert.True(t, l.Take(1000) > 900*time.Millisecond)
+	assert.Equal(t, int64(1000), parent.Rate())
+	assert.Equal(t, int64(0), l.Rate())
+}
@@ -0,0 +1,139 @@
+package filestorage
+
+import (
+	"container/list"
+	"os"
+	"sync"
+)
+
+// FileCache limits the number of open files shared by multiple FileStorages.
+// When the limit is reached, least recently used files are closed.
+// Closed files are opened again when they are read or written.
+type FileCache struct {
+	max int
+
+	m   sync.Mutex
+	lru *list.List // front is the most recently used file
+}
+
+// NewFileCache returns a new FileCache that keeps at most max files open.
+func NewFileCache(max int) *FileCache {
+	return &FileCache{
+		max: max,
+		lru: list.New(),
+	}
+}
+
+// Len returns the number of open files in the cache.
+func (c *FileCache) Len() int {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.lru.Len()
+}
+
+func (c *FileCache) add(of *os.File, name string) *cachedFile {
+	f := &cachedFile{cache: c, name: name}
+	c.m.Lock()
+	c.evict()
+	f.of = of
+	f.elem = c.lru.PushFront(f)
+	c.m.Unlock()
+	return f
+}
+
+// evict closes least recently used files that are not being read or written at the moment.
+// Must be called with the lock held before opening a new file.
+func (c *FileCache) evict() {
+	for e := c.lru.Back(); e != nil && c.lru.Len() >= c.max; {
+		prev := e.Prev()
+		f := e.Value.(*cachedFile)
+		if f.inUse == 0 {
+			f.closeFile()
+		}
+		e = prev
+	}
+}
+
+func (c *FileCache) acquire(f *cachedFile) (*os.File, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if f.closed {
+		return nil, os.ErrClosed
+	}
+	if f.of != nil {
+		c.lru.MoveToFront(f.elem)
+	} else {
+		c.evict()
+		of, err := os.OpenFile(f.name, applyNoAtimeFlag(os.O_RDWR|os.O_SYNC), fileMode)
+		if err != nil {
+			return nil, err
+		}
+		err = disableReadAhead(of)
+		if err != nil {
+			_ = of.Close()
+			return nil, err
+		}
+		f.of = of
+		f.elem = c.lru.PushFront(f)
+	}
+	f.inUse++
+	return f.of, nil
+}
+
+func (c *FileCache) release(f *cachedFile) {
+	c.m.Lock()
+	f.inUse--
+	c.m.Unlock()
+}
+
+// cachedFile implements storage.File. Underlying OS file may be closed by the cache at any time it is not in use.
+type cachedFile struct {
+	cache *FileCache
+	name  string
+
+	// Fields below are guarded by cache.m
+	of     *os.File
+	elem   *list.Element
+	inUse  int
+	closed bool
+}
+
+func (f *cachedFile) ReadAt(p []byte, off int64) (int, error) {
+	of, err := f.cache.acquire(f)
+	if err != nil {
+		return 0, err
+	}
+	defer f.cache.release(f)
+	return of.ReadAt(p, off)
+}
+
+func (f *cachedFile) WriteAt(p []byte, off int64) (int, error) {
+	of, err := f.cache.acquire(f)
+	if err != nil {
+		return 0, err
+	}
+	defer f.cache.release(f)
+	return of.WriteAt(p, off)
+}
+
+func (f *cachedFile) Close() error {
+	f.cache.m.Lock()
+	defer f.cache.m.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	f.closed = true
+	return f.closeFile()
+}
+
+// closeFile closes the OS file and removes it from the cache. Must be called with the lock held.
+func (f *cachedFile) closeFile() error {
+	if f.of == nil {
+		return nil
+	}
+	err := f.of.Close()
+	f.of = nil
+	f.cache.lru.Remove(f.elem)
+	f.elem = nil
+	return err
+}
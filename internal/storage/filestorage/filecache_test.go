@@ -0,0 +1,83 @@
+package filestorage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestFileCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rain-filestorage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const maxOpen = 3
+	const numFiles = 10
+	cache := NewFileCache(maxOpen)
+	sto, err := New(dir, cache, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fdsBefore := countFDs()
+
+	data := func(i int) []byte { return bytes.Repeat([]byte{byte(i)}, 100) }
+	files := make([]*cachedFile, numFiles)
+	for i := range files {
+		f, exists, err := sto.Open("file"+strconv.Itoa(i), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists {
+			t.Fatal("file must not exist")
+		}
+		files[i] = f.(*cachedFile)
+		if _, err = f.WriteAt(data(i), 0); err != nil {
+			t.Fatal(err)
+		}
+		if n := cache.Len(); n > maxOpen {
+			t.Fatalf("%d files are open", n)
+		}
+	}
+	// Read in reverse order so closed files are opened again.
+	for i := numFiles - 1; i >= 0; i-- {
+		b := make([]byte, 100)
+		if _, err = files[i].ReadAt(b, 0); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, data(i)) {
+			t.Fatalf("invalid data in file %d", i)
+		}
+		if n := cache.Len(); n > maxOpen {
+			t.Fatalf("%d files are open", n)
+		}
+	}
+	for _, f := range files {
+		if err = f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := cache.Len(); n != 0 {
+		t.Fatalf("%d files are still open", n)
+	}
+	if _, err = files[0].ReadAt(make([]byte, 1), 0); err != os.ErrClosed {
+		t.Fatalf("must not read closed file: %v", err)
+	}
+	if fdsBefore >= 0 {
+		if fdsAfter := countFDs(); fdsAfter != fdsBefore {
+			t.Fatalf("file descriptors leaked: %d before, %d after", fdsBefore, fdsAfter)
+		}
+	}
+}
+
+// countFDs returns the number of open file descriptors of the process or -1 if it cannot be determined.
+func countFDs() int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(fds)
+}
@@ -10,21 +10,30 @@ import (
 
 // FileStorage implements Storage interface for saving files on disk.
 type FileStorage struct {
-	dest string
+	dest        string
+	cache       *FileCache
+	mmap        bool
+	preallocate bool
 }
 
 // New returns a new FileStorage at the destination.
-func New(dest string) (*FileStorage, error) {
+// If cache is not nil, number of open files is limited by the cache.
+// If mmap is true, files are memory-mapped. Files that cannot be mapped are accessed with regular I/O.
+// If preallocate is true, disk space is reserved for the full size of files when they are created.
+// Otherwise, files are created as sparse files and disk space is used as the data is written.
+func New(dest string, cache *FileCache, mmap, preallocate bool) (*FileStorage, error) {
 	var err error
 	dest, err = filepath.Abs(dest)
 	if err != nil {
 		return nil, err
 	}
-	return &FileStorage{dest: dest}, nil
+	return &FileStorage{dest: dest, cache: cache, mmap: mmap, preallocate: preallocate}, nil
 }
 
 var _ storage.Storage = (*FileStorage)(nil)
 
+const fileMode = 0o640
+
 // Open a file.
 func (s *FileStorage) Open(name string, size int64) (f storage.File, exists bool, err error) {
 	name = filepath.Clean(name)
@@ -44,25 +53,42 @@ func (s *FileStorage) Open(name string, size int64) (f storage.File, exists bool
 		if err == nil && of != nil {
 			err = disableReadAhead(of)
 		}
-		if err != nil && of != nil {
-			_ = of.Close()
+		if err != nil {
+			if of != nil {
+				_ = of.Close()
+			}
+			return
+		}
+		if s.mmap && size > 0 && size <= mmapMaxSize {
+			// Fall back to regular I/O if the file cannot be mapped.
+			if mf, merr := newMmapFile(of, size); merr == nil {
+				err = of.Close()
+				if err != nil {
+					_ = mf.Close()
+					return
+				}
+				f = mf
+				return
+			}
+		}
+		if s.cache != nil {
+			f = s.cache.add(of, name)
 		} else {
 			f = of
 		}
 	}()
 
 	// Open OS file.
-	const mode = 0o640
 	openFlags := os.O_RDWR | os.O_SYNC
 	openFlags = applyNoAtimeFlag(openFlags)
-	of, err = os.OpenFile(name, openFlags, mode)
+	of, err = os.OpenFile(name, openFlags, fileMode)
 	if os.IsNotExist(err) {
 		openFlags |= os.O_CREATE
-		of, err = os.OpenFile(name, openFlags, mode)
+		of, err = os.OpenFile(name, openFlags, fileMode)
 		if err != nil {
 			return
 		}
-		err = of.Truncate(size)
+		err = s.resize(of, 0, size)
 		return
 	}
 	if err != nil {
@@ -74,11 +100,78 @@ func (s *FileStorage) Open(name string, size int64) (f storage.File, exists bool
 		return
 	}
 	if fi.Size() != size {
-		err = of.Truncate(size)
+		err = s.resize(of, fi.Size(), size)
 	}
 	return
 }
 
+// resize changes the size of the file from current to size.
+func (s *FileStorage) resize(f *os.File, current, size int64) error {
+	if s.preallocate && size > current {
+		return preallocate(f, size)
+	}
+	return f.Truncate(size)
+}
+
+// Stat returns the FileInfo of the file at name.
+func (s *FileStorage) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(filepath.Join(s.dest, filepath.Clean(name)))
+}
+
 func (s *FileStorage) RootDir() string {
 	return s.dest
 }
+
+// Allocated returns the total size of the regular files under the storage root.
+func (s *FileStorage) Allocated() (int64, error) {
+	var n int64
+	err := filepath.Walk(s.dest, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			n += info.Size()
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return n, err
+}
+
+// Symlink creates a symbolic link at name pointing to target.
+// Both paths are relative to the storage root. Link is created with a relative path to the target.
+func (s *FileStorage) Symlink(name, target string) error {
+	name = filepath.Join(s.dest, filepath.Clean(name))
+	target = filepath.Join(s.dest, filepath.Clean(target))
+	rel, err := filepath.Rel(filepath.Dir(name), target)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(name), os.ModeDir|0750)
+	if err != nil {
+		return err
+	}
+	existing, err := os.Readlink(name)
+	if err == nil {
+		if existing == rel {
+			return nil
+		}
+		err = os.Remove(name)
+		if err != nil {
+			return err
+		}
+	}
+	return os.Symlink(rel, name)
+}
+
+// SetExecutable sets the executable bits of the file at name.
+func (s *FileStorage) SetExecutable(name string) error {
+	name = filepath.Join(s.dest, filepath.Clean(name))
+	fi, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(name, fi.Mode()|0110)
+}
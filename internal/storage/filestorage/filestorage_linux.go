@@ -14,3 +14,18 @@ func disableReadAhead(f *os.File) error {
 func applyNoAtimeFlag(f int) int {
 	return f | syscall.O_NOATIME
 }
+
+// preallocate reserves disk blocks for the file up to size.
+// Falls back to truncating if the file system does not support it.
+func preallocate(f *os.File, size int64) error {
+	err := reserve(f, size)
+	if err == unix.EOPNOTSUPP || err == unix.ENOSYS {
+		return f.Truncate(size)
+	}
+	return err
+}
+
+// reserve allocates disk blocks for the file up to size. Data in the file is not changed.
+func reserve(f *os.File, size int64) error {
+	return unix.Fallocate(int(f.Fd()), 0, 0, size)
+}
@@ -2,7 +2,12 @@
 
 package filestorage
 
-import "os"
+import (
+	"errors"
+	"os"
+)
+
+var errReserveNotSupported = errors.New("reserving disk space is not supported")
 
 func disableReadAhead(f *os.File) error {
 	return nil
@@ -11,3 +16,12 @@ func disableReadAhead(f *os.File) error {
 func applyNoAtimeFlag(f int) int {
 	return f
 }
+
+func preallocate(f *os.File, size int64) error {
+	return f.Truncate(size)
+}
+
+// reserve is not supported, so files are not memory-mapped on this platform.
+func reserve(f *os.File, size int64) error {
+	return errReserveNotSupported
+}
@@ -0,0 +1,107 @@
+package filestorage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"runtime/debug"
+	"sync"
+)
+
+// mmapMaxSize is the size of the largest file that is memory-mapped.
+// Larger files are accessed with regular I/O, so mappings do not exhaust the address space on 32-bit platforms.
+const mmapMaxSize = 1 << 30
+
+// ErrMmapFault is returned when the mapped memory cannot be accessed, for example the file is truncated by another process.
+var ErrMmapFault = errors.New("cannot access memory-mapped file")
+
+// mmapFile implements storage.File by reading and writing the memory-mapped contents of a file.
+// The OS file is closed after mapping, so mapped files do not count against Config.MaxOpenDataFiles.
+type mmapFile struct {
+	m      sync.RWMutex
+	data   []byte
+	closed bool
+}
+
+// newMmapFile maps the file of given size to memory. The file is not closed.
+// Disk space is reserved for the whole file before mapping. Otherwise, writing to a page of a sparse file
+// raises SIGBUS instead of returning an error when the disk is full.
+func newMmapFile(of *os.File, size int64) (*mmapFile, error) {
+	err := reserve(of, size)
+	if err != nil {
+		return nil, err
+	}
+	data, err := mmap(of, size)
+	if err != nil {
+		return nil, err
+	}
+	return &mmapFile{data: data}, nil
+}
+
+func (f *mmapFile) ReadAt(p []byte, off int64) (n int, err error) {
+	f.m.RLock()
+	defer f.m.RUnlock()
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer recoverFault(&err)
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n = copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt copies p to the mapped memory and writes the modified pages to disk before returning,
+// same as the files that are opened with O_SYNC flag.
+func (f *mmapFile) WriteAt(p []byte, off int64) (n int, err error) {
+	f.m.RLock()
+	defer f.m.RUnlock()
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer recoverFault(&err)
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.ErrShortWrite
+	}
+	n = copy(f.data[off:], p)
+	err = msync(f.data, off, n)
+	if err != nil {
+		return n, err
+	}
+	if n < len(p) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
+
+// Close unmaps the file from memory.
+func (f *mmapFile) Close() error {
+	f.m.Lock()
+	defer f.m.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	f.closed = true
+	err := munmap(f.data)
+	f.data = nil
+	return err
+}
+
+// recoverFault converts the fault raised while accessing the mapped memory to an error.
+// Must be deferred after enabling debug.SetPanicOnFault and before the memory is accessed.
+func recoverFault(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if _, ok := r.(interface{ Addr() uintptr }); !ok {
+		panic(r)
+	}
+	*err = ErrMmapFault
+}
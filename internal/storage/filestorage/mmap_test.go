@@ -0,0 +1,82 @@
+// +build linux
+
+package filestorage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestMmap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rain-filestorage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sto, err := New(dir, NewFileCache(1), true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, exists, err := sto.Open("file", 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("file must not exist")
+	}
+	if _, ok := f.(*mmapFile); !ok {
+		t.Fatalf("file is not mapped: %T", f)
+	}
+	// Disk space is reserved, so writing to the mapped pages does not fail when the disk is full.
+	fi, err := os.Stat(filepath.Join(dir, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocks := fi.Sys().(*syscall.Stat_t).Blocks; blocks*512 < 10000 {
+		t.Fatalf("disk space is not reserved, blocks: %d", blocks)
+	}
+	data := bytes.Repeat([]byte{1}, 5000)
+	if _, err = f.WriteAt(data, 4999); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteAt(data, 5001); err == nil {
+		t.Fatal("write past the end of file must fail")
+	}
+	b := make([]byte, 5000)
+	if _, err = f.ReadAt(b, 4999); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Fatal("invalid data")
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.ReadAt(b, 0); err != os.ErrClosed {
+		t.Fatalf("unexpected error after close: %v", err)
+	}
+
+	// Written data is on disk after the file is unmapped.
+	b, err = ioutil.ReadFile(filepath.Join(dir, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b[4999:9999], data) {
+		t.Fatal("data is not written to file")
+	}
+
+	// Empty files cannot be mapped.
+	f, _, err = sto.Open("empty", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, ok := f.(*cachedFile); !ok {
+		t.Fatalf("empty file must use regular I/O: %T", f)
+	}
+}
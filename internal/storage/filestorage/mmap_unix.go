@@ -0,0 +1,28 @@
+// +build !windows
+
+package filestorage
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+var pageSize = int64(os.Getpagesize())
+
+func mmap(f *os.File, size int64) ([]byte, error) {
+	return unix.Mmap(int(f.Fd()), 0, int(size), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+}
+
+// msync writes the pages containing n bytes at offset off to disk.
+func msync(data []byte, off int64, n int) error {
+	if n == 0 {
+		return nil
+	}
+	begin := off - off%pageSize
+	return unix.Msync(data[begin:off+int64(n)], unix.MS_SYNC)
+}
+
+func munmap(data []byte) error {
+	return unix.Munmap(data)
+}
@@ -0,0 +1,20 @@
+package filestorage
+
+import (
+	"errors"
+	"os"
+)
+
+var errMmapNotSupported = errors.New("mmap is not supported on windows")
+
+func mmap(f *os.File, size int64) ([]byte, error) {
+	return nil, errMmapNotSupported
+}
+
+func msync(data []byte, off int64, n int) error {
+	return errMmapNotSupported
+}
+
+func munmap(data []byte) error {
+	return errMmapNotSupported
+}
@@ -0,0 +1,174 @@
+// Package memorystorage implements Storage interface that keeps files in memory.
+package memorystorage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/rain/internal/storage"
+)
+
+// MemoryStorage implements Storage interface for keeping files in memory.
+// Contents are lost when the process exits. Useful for tests and short-lived downloads.
+type MemoryStorage struct {
+	mu    sync.Mutex
+	files map[string]*file
+	links map[string]string
+}
+
+// New returns a new empty MemoryStorage.
+func New() *MemoryStorage {
+	return &MemoryStorage{
+		files: make(map[string]*file),
+		links: make(map[string]string),
+	}
+}
+
+var _ storage.Storage = (*MemoryStorage)(nil)
+
+// Open a file. File is created with the given size if it does not exist.
+func (s *MemoryStorage) Open(name string, size int64) (f storage.File, exists bool, err error) {
+	name = filepath.Clean(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mf, exists := s.files[name]
+	if !exists {
+		mf = &file{name: name}
+		s.files[name] = mf
+	}
+	mf.truncate(size)
+	return mf, exists, nil
+}
+
+// Stat returns the FileInfo of the file at name.
+func (s *MemoryStorage) Stat(name string) (os.FileInfo, error) {
+	name = filepath.Clean(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if target, ok := s.links[name]; ok {
+		name = target
+	}
+	mf, ok := s.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return mf.stat(), nil
+}
+
+// Symlink creates a link at name pointing to target. Stat on the link returns the stats of the target.
+func (s *MemoryStorage) Symlink(name, target string) error {
+	s.mu.Lock()
+	s.links[filepath.Clean(name)] = filepath.Clean(target)
+	s.mu.Unlock()
+	return nil
+}
+
+// SetExecutable sets the executable bits of the file at name.
+func (s *MemoryStorage) SetExecutable(name string) error {
+	name = filepath.Clean(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mf, ok := s.files[name]
+	if !ok {
+		return &os.PathError{Op: "chmod", Path: name, Err: os.ErrNotExist}
+	}
+	mf.mu.Lock()
+	mf.executable = true
+	mf.mu.Unlock()
+	return nil
+}
+
+// RootDir returns an empty string because files are not saved on disk.
+func (s *MemoryStorage) RootDir() string {
+	return ""
+}
+
+// Allocated returns the total size of the files in memory.
+func (s *MemoryStorage) Allocated() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for _, mf := range s.files {
+		mf.mu.RLock()
+		n += int64(len(mf.data))
+		mf.mu.RUnlock()
+	}
+	return n, nil
+}
+
+type file struct {
+	name       string
+	mu         sync.RWMutex
+	data       []byte
+	modTime    time.Time
+	executable bool
+}
+
+func (f *file) truncate(size int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if int64(len(f.data)) == size {
+		return
+	}
+	data := make([]byte, size)
+	copy(data, f.data)
+	f.data = data
+	f.modTime = time.Now()
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if end := off + int64(len(p)); end > int64(len(f.data)) {
+		data := make([]byte, end)
+		copy(data, f.data)
+		f.data = data
+	}
+	copy(f.data[off:], p)
+	f.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *file) Close() error {
+	return nil
+}
+
+func (f *file) stat() os.FileInfo {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	mode := os.FileMode(0640)
+	if f.executable {
+		mode |= 0110
+	}
+	return fileInfo{name: filepath.Base(f.name), size: int64(len(f.data)), mode: mode, modTime: f.modTime}
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi fileInfo) IsDir() bool        { return false }
+func (fi fileInfo) Sys() interface{}   { return nil }
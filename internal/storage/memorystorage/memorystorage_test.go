@@ -0,0 +1,60 @@
+package memorystorage
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	sto := New()
+	f, exists, err := sto.Open("dir/file", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("file must not exist")
+	}
+	if _, err = f.WriteAt([]byte("world"), 5); err != nil {
+		t.Fatal(err)
+	}
+
+	f, exists, err = sto.Open("dir/file", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatal("file must exist")
+	}
+	b := make([]byte, 8)
+	n, err := f.ReadAt(b, 5)
+	if err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if !bytes.Equal(b[:n], []byte("world")) {
+		t.Fatalf("unexpected data: %q", b[:n])
+	}
+
+	if err = sto.Symlink("link", "dir/file"); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := sto.Stat("link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != 10 {
+		t.Fatalf("unexpected size: %d", fi.Size())
+	}
+	if _, err = sto.Stat("missing"); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist error, got %v", err)
+	}
+
+	allocated, err := sto.Allocated()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allocated != 10 {
+		t.Fatalf("unexpected allocated bytes: %d", allocated)
+	}
+}
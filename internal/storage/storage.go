@@ -1,12 +1,26 @@
 // Package storage contains an interface for reading and writing files in a torrent.
 package storage
 
-import "io"
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrNotCreated is returned when reading a file that is not created on storage yet.
+// Such file has no downloaded data.
+var ErrNotCreated = errors.New("file is not created yet")
 
 // Storage is an interface for reading/writing torrent files.
 type Storage interface {
 	Open(name string, size int64) (f File, exists bool, err error)
+	// Stat returns the size and modification time of a file.
+	Stat(name string) (os.FileInfo, error)
+	Symlink(name, target string) error
+	SetExecutable(name string) error
 	RootDir() string
+	// Allocated returns the total number of bytes allocated for the files in the storage.
+	Allocated() (int64, error)
 }
 
 // File interface for reading/writing torrent data.
@@ -0,0 +1,67 @@
+package tracker
+
+import (
+	"context"
+	"sync"
+)
+
+// AnnounceList implements the Tracker interface and contains the tiers of trackers in a torrent as described in BEP 12.
+// Tiers are tried in order and the next tier is used only if all trackers in the previous tiers fail.
+type AnnounceList struct {
+	Tiers []*Tier
+	index int
+	m     sync.RWMutex
+}
+
+var _ Tracker = (*AnnounceList)(nil)
+
+// NewAnnounceList returns a new AnnounceList.
+func NewAnnounceList(tiers []*Tier) *AnnounceList {
+	return &AnnounceList{
+		Tiers: tiers,
+	}
+}
+
+// Announce a torrent to the tiers in order until one of them responds.
+func (a *AnnounceList) Announce(ctx context.Context, req AnnounceRequest) (*AnnounceResponse, error) {
+	var err error
+	for i, tier := range a.Tiers {
+		a.m.Lock()
+		a.index = i
+		a.m.Unlock()
+		var resp *AnnounceResponse
+		resp, err = tier.Announce(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+func (a *AnnounceList) currentTier() *Tier {
+	a.m.RLock()
+	defer a.m.RUnlock()
+	return a.Tiers[a.index]
+}
+
+// Scrape the torrents from the current Tier.
+func (a *AnnounceList) Scrape(ctx context.Context, infoHashes [][20]byte) ([]ScrapeResult, error) {
+	return a.currentTier().Scrape(ctx, infoHashes)
+}
+
+// URL returns the URL of the current Tracker in the current Tier.
+func (a *AnnounceList) URL() string {
+	return a.currentTier().URL()
+}
+
+// URLs returns the URLs of the Trackers in each Tier.
+func (a *AnnounceList) URLs() [][]string {
+	urls := make([][]string, len(a.Tiers))
+	for i, tier := range a.Tiers {
+		urls[i] = tier.URLs()
+	}
+	return urls
+}
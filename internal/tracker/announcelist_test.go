@@ -0,0 +1,80 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type testTracker struct {
+	url       string
+	fail      bool
+	announces int
+}
+
+func (t *testTracker) Announce(ctx context.Context, req AnnounceRequest) (*AnnounceResponse, error) {
+	t.announces++
+	if t.fail {
+		return nil, errors.New("tracker is down")
+	}
+	return &AnnounceResponse{}, nil
+}
+
+func (t *testTracker) Scrape(ctx context.Context, infoHashes [][20]byte) ([]ScrapeResult, error) {
+	return nil, nil
+}
+
+func (t *testTracker) URL() string {
+	return t.url
+}
+
+func TestTierPromotesWorkingTracker(t *testing.T) {
+	primary := &testTracker{url: "primary", fail: true}
+	backup := &testTracker{url: "backup"}
+	tier := &Tier{Trackers: []Tracker{primary, backup}}
+	_, err := tier.Announce(context.Background(), AnnounceRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tier.URL() != "backup" || tier.URLs()[0] != "backup" {
+		t.Fatalf("working tracker is not moved to front: %v", tier.URLs())
+	}
+	_, err = tier.Announce(context.Background(), AnnounceRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primary.announces != 1 || backup.announces != 2 {
+		t.Fatalf("unexpected announces, primary: %d, backup: %d", primary.announces, backup.announces)
+	}
+}
+
+func TestAnnounceListFailover(t *testing.T) {
+	primary := &testTracker{url: "primary", fail: true}
+	backup := &testTracker{url: "backup"}
+	unused := &testTracker{url: "unused"}
+	al := NewAnnounceList([]*Tier{
+		{Trackers: []Tracker{primary}},
+		{Trackers: []Tracker{backup}},
+		{Trackers: []Tracker{unused}},
+	})
+	_, err := al.Announce(context.Background(), AnnounceRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if al.URL() != "backup" {
+		t.Fatalf("unexpected current tracker: %s", al.URL())
+	}
+	if unused.announces != 0 {
+		t.Fatal("next tier is used while a previous tier is working")
+	}
+
+	backup.fail = true
+	unused.fail = true
+	_, err = al.Announce(context.Background(), AnnounceRequest{})
+	if err == nil {
+		t.Fatal("expected error when all tiers fail")
+	}
+	if primary.announces != 2 || backup.announces != 2 || unused.announces != 1 {
+		t.Fatalf("unexpected announces, primary: %d, backup: %d, unused: %d", primary.announces, backup.announces, unused.announces)
+	}
+}
@@ -58,3 +58,20 @@ func DecodePeersCompact(b []byte) ([]*net.TCPAddr, error) {
 	}
 	return addrs, nil
 }
+
+// DecodePeersCompact6 parses and returns addresses for list of IPv6 peers.
+// Each peer is 18 bytes: a 16-bytes IP address and a 2-bytes port value.
+func DecodePeersCompact6(b []byte) ([]*net.TCPAddr, error) {
+	const size = net.IPv6len + 2
+	if len(b)%size != 0 {
+		return nil, errors.New("invalid peer list length")
+	}
+	addrs := make([]*net.TCPAddr, 0, len(b)/size)
+	for i := 0; i < len(b); i += size {
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, b[i:i+net.IPv6len])
+		port := binary.BigEndian.Uint16(b[i+net.IPv6len : i+size])
+		addrs = append(addrs, &net.TCPAddr{IP: ip, Port: int(port)})
+	}
+	return addrs, nil
+}
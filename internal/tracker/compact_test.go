@@ -22,3 +22,18 @@ func TestCompactPeer(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestDecodePeersCompact6(t *testing.T) {
+	b := []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0x1a, 0xe1}
+	addrs, err := DecodePeersCompact6(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0].String() != "[2001:db8::1]:6881" {
+		t.Fatalf("unexpected addresses: %v", addrs)
+	}
+	_, err = DecodePeersCompact6(b[:17])
+	if err == nil {
+		t.Fatal("invalid length must be rejected")
+	}
+}
@@ -1,8 +1,8 @@
 package httptracker
 
 import (
-	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -78,6 +78,14 @@ func (t *HTTPTracker) Announce(ctx context.Context, req tracker.AnnounceRequest)
 	sb.WriteString("&no_peer_id=1")
 	sb.WriteString("&numwant=")
 	sb.WriteString(strconv.Itoa(req.NumWant))
+	if req.Torrent.IP != nil {
+		sb.WriteString("&ip=")
+		sb.WriteString(url.QueryEscape(req.Torrent.IP.String()))
+	}
+	if req.Torrent.IPv6 != nil {
+		sb.WriteString("&ipv6=")
+		sb.WriteString(url.QueryEscape(req.Torrent.IPv6.String()))
+	}
 
 	if req.Event != tracker.EventNone {
 		sb.WriteString("&event=")
@@ -87,42 +95,15 @@ func (t *HTTPTracker) Announce(ctx context.Context, req tracker.AnnounceRequest)
 		sb.WriteString("&trackerid=")
 		sb.WriteString(t.trackerID)
 	}
+	var key [4]byte
+	binary.BigEndian.PutUint32(key[:], req.Torrent.Key)
 	sb.WriteString("&key=")
-	sb.WriteString(hex.EncodeToString(req.Torrent.PeerID[16:20]))
-
-	t.log.Debugf("making request to: %q", sb.String())
-
-	httpReq, err := http.NewRequest(http.MethodGet, sb.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	httpReq = httpReq.WithContext(ctx)
-
-	httpReq.Header.Set("User-Agent", t.userAgent)
-
-	doReq := func() (int, http.Header, []byte, error) {
-		resp, err := t.http.Do(httpReq)
-		if err != nil {
-			return 0, nil, nil, err
-		}
-		t.log.Debugf("tracker responded %d with %d bytes body", resp.StatusCode, resp.ContentLength)
-		defer resp.Body.Close()
-		if resp.ContentLength > t.maxResponseLength {
-			return 0, resp.Header, nil, fmt.Errorf("tracker respsonse too large: %d", resp.ContentLength)
-		}
-		r := io.LimitReader(resp.Body, t.maxResponseLength)
-		data, err := ioutil.ReadAll(r)
-		return resp.StatusCode, resp.Header, data, err
-	}
+	sb.WriteString(hex.EncodeToString(key[:]))
 
-	code, header, body, err := doReq()
-	if uerr, ok := err.(*url.Error); ok && uerr.Err == context.Canceled {
-		return nil, context.Canceled
-	}
+	code, header, body, err := t.get(ctx, sb.String())
 	if err != nil {
 		return nil, err
 	}
-	t.log.Debugf("read %d bytes from body", len(body))
 
 	var response announceResponse
 	err = bencode.DecodeBytes(body, &response)
@@ -166,14 +147,25 @@ func (t *HTTPTracker) Announce(ctx context.Context, req tracker.AnnounceRequest)
 	if err != nil {
 		return nil, err
 	}
+	// IPv6 peers are always in binary model (BEP 7).
+	if len(response.Peers6) > 0 {
+		peers6, err := tracker.DecodePeersCompact6(response.Peers6)
+		if err != nil {
+			return nil, err
+		}
+		t.log.Debugf("got %d IPv4 and %d IPv6 peers", len(peers), len(peers6))
+		peers = append(peers, peers6...)
+	}
+	peers = uniquePeers(peers)
 	t.log.Debugf("got %d peers", len(peers))
 
 	// Filter external IP
 	if len(response.ExternalIP) != 0 {
+		externalIP := net.IP(response.ExternalIP)
 		var filtered int
-		for i, p := range peers {
-			if !bytes.Equal(p.IP[:], response.ExternalIP) {
-				peers[i] = p
+		for _, p := range peers {
+			if !p.IP.Equal(externalIP) {
+				peers[filtered] = p
 				filtered++
 			}
 		}
@@ -190,6 +182,44 @@ func (t *HTTPTracker) Announce(ctx context.Context, req tracker.AnnounceRequest)
 	}, nil
 }
 
+// get makes a GET request to the tracker and returns the status code, headers and body of the response.
+func (t *HTTPTracker) get(ctx context.Context, u string) (int, http.Header, []byte, error) {
+	t.log.Debugf("making request to: %q", u)
+
+	httpReq, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+
+	httpReq.Header.Set("User-Agent", t.userAgent)
+
+	doReq := func() (int, http.Header, []byte, error) {
+		resp, err := t.http.Do(httpReq)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		t.log.Debugf("tracker responded %d with %d bytes body", resp.StatusCode, resp.ContentLength)
+		defer resp.Body.Close()
+		if resp.ContentLength > t.maxResponseLength {
+			return 0, resp.Header, nil, fmt.Errorf("tracker respsonse too large: %d", resp.ContentLength)
+		}
+		r := io.LimitReader(resp.Body, t.maxResponseLength)
+		data, err := ioutil.ReadAll(r)
+		return resp.StatusCode, resp.Header, data, err
+	}
+
+	code, header, body, err := doReq()
+	if uerr, ok := err.(*url.Error); ok && uerr.Err == context.Canceled {
+		return 0, nil, nil, context.Canceled
+	}
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	t.log.Debugf("read %d bytes from body", len(body))
+	return code, header, body, nil
+}
+
 // percentEscape puts `%` before every byte.
 // Some trackers don't like the output of url.QueryEscape function because it may skip encoding safe characters.
 // This function escapes every byte explicitly.
@@ -222,3 +252,22 @@ func parsePeersDictionary(b bencode.RawMessage) ([]*net.TCPAddr, error) {
 	}
 	return addrs, err
 }
+
+// uniquePeers removes the addresses that are seen before in the list.
+// IPv4-mapped addresses in "peers6" field are same with the addresses in "peers" field.
+func uniquePeers(addrs []*net.TCPAddr) []*net.TCPAddr {
+	seen := make(map[string]struct{}, len(addrs))
+	b := addrs[:0]
+	for _, addr := range addrs {
+		if ip4 := addr.IP.To4(); ip4 != nil {
+			addr = &net.TCPAddr{IP: ip4, Port: addr.Port}
+		}
+		key := addr.String()
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		b = append(b, addr)
+	}
+	return b
+}
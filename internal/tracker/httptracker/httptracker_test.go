@@ -2,7 +2,9 @@ package httptracker_test
 
 import (
 	"context"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 	"time"
@@ -99,3 +101,92 @@ func TestHTTPTracker(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestHTTPTrackerScrape(t *testing.T) {
+	ih1 := [20]byte{1}
+	ih2 := [20]byte{2}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/scrape" || r.URL.Query().Get("passkey") != "foo" {
+			http.NotFound(w, r)
+			return
+		}
+		hashes := r.URL.Query()["info_hash"]
+		if len(hashes) != 2 || hashes[0] != string(ih1[:]) || hashes[1] != string(ih2[:]) {
+			t.Errorf("invalid info hashes: %q", hashes)
+		}
+		// Tracker does not know about the second torrent.
+		_, _ = w.Write([]byte("d5:filesd20:" + string(ih1[:]) + "d8:completei5e10:downloadedi10e10:incompletei3eeee"))
+	}))
+	defer srv.Close()
+
+	rawURL := srv.URL + "/announce?passkey=foo"
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trk := httptracker.New(rawURL, u, timeout, new(http.Transport), "Mozilla/5.0", 2*1024*1024)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	results, err := trk.Scrape(ctx, [][20]byte{ih1, ih2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := tracker.ScrapeResult{InfoHash: ih1, Seeders: 5, Leechers: 3, Completed: 10}
+	if len(results) != 1 || results[0] != expected {
+		t.Fatalf("invalid results: %#v", results)
+	}
+
+	// Scrape is not supported if the announce URL does not end with "announce".
+	rawURL = srv.URL + "/a"
+	u, err = url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trk = httptracker.New(rawURL, u, timeout, new(http.Transport), "Mozilla/5.0", 2*1024*1024)
+	_, err = trk.Scrape(ctx, [][20]byte{ih1})
+	if err != tracker.ErrScrapeNotSupported {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestHTTPTrackerPeers6(t *testing.T) {
+	peers := string([]byte{1, 2, 3, 4, 0x04, 0x57})
+	peers6 := string([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 1, 2, 3, 4, 0x04, 0x57}) +
+		string([]byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0x08, 0xae})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := r.URL.Query().Get("ipv6"); ip != "2001:db8::2" {
+			t.Errorf("invalid ipv6 param: %q", ip)
+		}
+		_, _ = w.Write([]byte("d8:intervali60e5:peers6:" + peers + "6:peers636:" + peers6 + "e"))
+	}))
+	defer srv.Close()
+
+	rawURL := srv.URL + "/announce"
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trk := httptracker.New(rawURL, u, timeout, new(http.Transport), "Mozilla/5.0", 2*1024*1024)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req := tracker.AnnounceRequest{
+		Torrent: tracker.Torrent{
+			InfoHash: [20]byte{6},
+			PeerID:   [20]byte{1},
+			Port:     1111,
+			IPv6:     net.ParseIP("2001:db8::2"),
+		},
+	}
+	resp, err := trk.Announce(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// IPv4-mapped address in peers6 is the same peer in peers.
+	if len(resp.Peers) != 2 || resp.Peers[0].String() != "1.2.3.4:1111" || resp.Peers[1].String() != "[2001:db8::1]:2222" {
+		t.Fatalf("invalid peers: %v", resp.Peers)
+	}
+}
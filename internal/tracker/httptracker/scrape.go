@@ -0,0 +1,87 @@
+package httptracker
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/rain/internal/tracker"
+	"github.com/zeebo/bencode"
+)
+
+// Scrape the torrents by doing a GET request to the scrape URL of the tracker.
+// Multiple info hashes are requested in a single request (BEP 48).
+func (t *HTTPTracker) Scrape(ctx context.Context, infoHashes [][20]byte) ([]tracker.ScrapeResult, error) {
+	u, ok := scrapeURL(t.rawURL)
+	if !ok {
+		return nil, tracker.ErrScrapeNotSupported
+	}
+	var sb strings.Builder
+	sb.WriteString(u)
+	sep := "?"
+	if strings.ContainsRune(u, '?') {
+		sep = "&"
+	}
+	for _, ih := range infoHashes {
+		sb.WriteString(sep)
+		sb.WriteString("info_hash=")
+		sb.WriteString(percentEscape(ih))
+		sep = "&"
+	}
+
+	code, header, body, err := t.get(ctx, sb.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var response scrapeResponse
+	err = bencode.DecodeBytes(body, &response)
+	if err != nil {
+		if code != 200 {
+			return nil, &StatusError{
+				Code:   code,
+				Header: header,
+				Body:   string(body),
+			}
+		}
+		return nil, tracker.ErrDecode
+	}
+
+	if response.FailureReason != "" {
+		retryIn, _ := strconv.Atoi(response.RetryIn)
+		return nil, &tracker.Error{
+			FailureReason: response.FailureReason,
+			RetryIn:       time.Duration(retryIn) * time.Minute,
+		}
+	}
+
+	results := make([]tracker.ScrapeResult, 0, len(infoHashes))
+	for _, ih := range infoHashes {
+		f, ok := response.Files[string(ih[:])]
+		if !ok {
+			continue
+		}
+		results = append(results, tracker.ScrapeResult{
+			InfoHash:  ih,
+			Seeders:   f.Complete,
+			Leechers:  f.Incomplete,
+			Completed: f.Downloaded,
+		})
+	}
+	return results, nil
+}
+
+// scrapeURL returns the scrape URL of the tracker by replacing "announce" with "scrape" in the last path segment of the announce URL.
+// Returns false if the last path segment does not start with "announce", which means that the tracker does not support scrape.
+func scrapeURL(announceURL string) (string, bool) {
+	base, query := announceURL, ""
+	if i := strings.IndexByte(announceURL, '?'); i >= 0 {
+		base, query = announceURL[:i], announceURL[i:]
+	}
+	i := strings.LastIndexByte(base, '/')
+	if i < 0 || !strings.HasPrefix(base[i+1:], "announce") {
+		return "", false
+	}
+	return base[:i+1] + "scrape" + strings.TrimPrefix(base[i+1:], "announce") + query, true
+}
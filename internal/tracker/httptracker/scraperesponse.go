@@ -0,0 +1,13 @@
+package httptracker
+
+type scrapeResponse struct {
+	FailureReason string                `bencode:"failure reason"`
+	RetryIn       string                `bencode:"retry in"`
+	Files         map[string]scrapeFile `bencode:"files"`
+}
+
+type scrapeFile struct {
+	Complete   int32 `bencode:"complete"`
+	Incomplete int32 `bencode:"incomplete"`
+	Downloaded int32 `bencode:"downloaded"`
+}
@@ -3,12 +3,14 @@ package tracker
 import (
 	"context"
 	"math/rand"
+	"sync"
 )
 
 // Tier implements the Tracker interface and contains multiple Trackers which tries to announce to the working Tracker.
 type Tier struct {
 	Trackers []Tracker
 	index    int
+	m        sync.RWMutex
 }
 
 var _ Tracker = (*Tier)(nil)
@@ -21,17 +23,65 @@ func NewTier(trackers []Tracker) *Tier {
 	}
 }
 
-// Announce a torrent to the tracker.
-// If annouce fails, the next announce will be made to the next Tracker in the tier.
+// Announce a torrent to the trackers in the tier in order until one of them responds.
+// The Tracker that responds is moved to the front of the tier as described in BEP 12.
 func (t *Tier) Announce(ctx context.Context, req AnnounceRequest) (*AnnounceResponse, error) {
-	resp, err := t.Trackers[t.index].Announce(ctx, req)
-	if err != nil {
-		t.index = (t.index + 1) % len(t.Trackers)
+	t.m.RLock()
+	trackers := append([]Tracker(nil), t.Trackers...)
+	t.m.RUnlock()
+	var err error
+	for i, tr := range trackers {
+		t.setIndex(i)
+		var resp *AnnounceResponse
+		resp, err = tr.Announce(ctx, req)
+		if err == nil {
+			t.promote(i)
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
 	}
-	return resp, err
+	return nil, err
+}
+
+func (t *Tier) setIndex(i int) {
+	t.m.Lock()
+	t.index = i
+	t.m.Unlock()
+}
+
+func (t *Tier) promote(i int) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	tr := t.Trackers[i]
+	copy(t.Trackers[1:i+1], t.Trackers[:i])
+	t.Trackers[0] = tr
+	t.index = 0
+}
+
+// Scrape the torrents from the current Tracker in the Tier.
+func (t *Tier) Scrape(ctx context.Context, infoHashes [][20]byte) ([]ScrapeResult, error) {
+	t.m.RLock()
+	tr := t.Trackers[t.index]
+	t.m.RUnlock()
+	return tr.Scrape(ctx, infoHashes)
 }
 
 // URL returns the current Tracker in the Tier.
 func (t *Tier) URL() string {
+	t.m.RLock()
+	defer t.m.RUnlock()
 	return t.Trackers[t.index].URL()
 }
+
+// URLs returns the URLs of the Trackers in the Tier in the order they are tried.
+func (t *Tier) URLs() []string {
+	t.m.RLock()
+	defer t.m.RUnlock()
+	urls := make([]string, len(t.Trackers))
+	for i, tr := range t.Trackers {
+		urls[i] = tr.URL()
+	}
+	return urls
+}
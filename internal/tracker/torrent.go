@@ -1,5 +1,7 @@
 package tracker
 
+import "net"
+
 // Torrent contains fields that are sent in an announce request.
 type Torrent struct {
 	BytesUploaded   int64
@@ -8,4 +10,11 @@ type Torrent struct {
 	InfoHash        [20]byte
 	PeerID          [20]byte
 	Port            int
+	// Random key that identifies the client to trackers if its IP address changes.
+	// Must be same across announces.
+	Key uint32
+	// External IP address of the client. Not sent if nil.
+	IP net.IP
+	// External IPv6 address of the client, sent in "ipv6" parameter to HTTP trackers (BEP 7). Not sent if nil.
+	IPv6 net.IP
 }
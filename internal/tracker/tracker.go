@@ -15,6 +15,10 @@ type Tracker interface {
 	// Announce should also be called on specific events.
 	Announce(ctx context.Context, req AnnounceRequest) (*AnnounceResponse, error)
 
+	// Scrape returns the swarm statistics of the torrents with given info hashes without announcing.
+	// Returns ErrScrapeNotSupported if the tracker does not support scraping.
+	Scrape(ctx context.Context, infoHashes [][20]byte) ([]ScrapeResult, error)
+
 	// URL of the tracker.
 	URL() string
 }
@@ -36,9 +40,20 @@ type AnnounceResponse struct {
 	Peers          []*net.TCPAddr
 }
 
+// ScrapeResult contains the swarm statistics of a torrent returned in a scrape response.
+type ScrapeResult struct {
+	InfoHash  [20]byte
+	Seeders   int32
+	Leechers  int32
+	Completed int32
+}
+
 // ErrDecode is returned from Tracker.Announce method when there is problem with the encoding of response.
 var ErrDecode = errors.New("cannot decode response")
 
+// ErrScrapeNotSupported is returned from Tracker.Scrape method when the tracker does not support scrape requests.
+var ErrScrapeNotSupported = errors.New("tracker does not support scrape")
+
 // Error is the string that is sent by the tracker from announce or scrape.
 type Error struct {
 	FailureReason string
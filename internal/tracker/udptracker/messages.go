@@ -2,6 +2,7 @@ package udptracker
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"io"
 
@@ -108,3 +109,26 @@ func (r *transferAnnounceRequest) WriteTo(w io.Writer) (int64, error) {
 
 	return int64(buf.Buffered()), buf.Flush()
 }
+
+type scrapeRequest struct {
+	udpRequestHeader
+	InfoHashes [][20]byte
+}
+
+func (r *scrapeRequest) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	err := binary.Write(&buf, binary.BigEndian, r.udpRequestHeader)
+	if err != nil {
+		return 0, err
+	}
+	for _, ih := range r.InfoHashes {
+		buf.Write(ih[:])
+	}
+	return buf.WriteTo(w)
+}
+
+type scrapeResponseItem struct {
+	Seeders   int32
+	Completed int32
+	Leechers  int32
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/cenkalti/rain/internal/blocklist"
 	"github.com/cenkalti/rain/internal/logger"
 	"github.com/cenkalti/rain/internal/resolver"
+	"github.com/cenkalti/rain/internal/socks5"
 	"github.com/cenkalti/rain/internal/tracker"
 	"github.com/zeebo/bencode"
 )
@@ -29,9 +30,10 @@ const (
 // Transport for UDP tracker implementation.
 type Transport struct {
 	blocklist  *blocklist.Blocklist
-	conn       *net.UDPConn
+	conn       net.PacketConn
 	log        logger.Logger
 	dnsTimeout time.Duration
+	proxy      *socks5.Dialer
 
 	connections  map[string]*connection
 	transactions map[int32]*transaction
@@ -47,11 +49,14 @@ type connection struct {
 }
 
 // NewTransport returns a new UDP tracker transport.
-func NewTransport(bl *blocklist.Blocklist, dnsTimeout time.Duration) *Transport {
+// If proxy is not nil, packets are sent through the SOCKS5 proxy with UDP ASSOCIATE command.
+// Requests fail if the proxy does not support UDP.
+func NewTransport(bl *blocklist.Blocklist, dnsTimeout time.Duration, proxy *socks5.Dialer) *Transport {
 	return &Transport{
 		blocklist:    bl,
 		log:          logger.New("udp tracker transport"),
 		dnsTimeout:   dnsTimeout,
+		proxy:        proxy,
 		connections:  make(map[string]*connection),
 		transactions: make(map[int32]*transaction),
 		closeC:       make(chan struct{}),
@@ -69,7 +74,7 @@ func (t *Transport) getConnection(addr string) *connection {
 	return conn
 }
 
-func (t *Transport) listen() error {
+func (t *Transport) listen(ctx context.Context) error {
 	t.m.Lock()
 	defer t.m.Unlock()
 
@@ -77,20 +82,26 @@ func (t *Transport) listen() error {
 		return nil
 	}
 
-	var laddr net.UDPAddr
-	conn, err := net.ListenUDP("udp4", &laddr)
+	var conn net.PacketConn
+	var err error
+	if t.proxy != nil {
+		conn, err = t.proxy.ListenPacket(ctx)
+	} else {
+		var laddr net.UDPAddr
+		conn, err = net.ListenUDP("udp4", &laddr)
+	}
 	if err != nil {
 		return err
 	}
 
 	t.conn = conn
-	go t.readLoop()
+	go t.readLoop(conn)
 	return nil
 }
 
 // Do sends the transaction to the tracker. Retries on failure.
 func (t *Transport) Do(ctx context.Context, trx *transaction) ([]byte, error) {
-	err := t.listen()
+	err := t.listen(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -135,17 +146,24 @@ func (t *Transport) Close() error {
 
 // readLoop reads datagrams from connection, finds the transaction and
 // sends the bytes to the transaction's response channel.
-func (t *Transport) readLoop() {
+func (t *Transport) readLoop(conn net.PacketConn) {
 	// Read buffer must be big enough to hold a UDP packet of maximum expected size.
 	const maxNumWant = 1000
 	bigBuf := make([]byte, 20+6*maxNumWant)
 	for {
-		n, err := t.conn.Read(bigBuf)
+		n, _, err := conn.ReadFrom(bigBuf)
 		if err != nil {
 			select {
 			case <-t.closeC:
 			default:
 				t.log.Error(err)
+				// Listen again on next request. Proxy may have terminated the association.
+				t.m.Lock()
+				if t.conn == conn {
+					conn.Close()
+					t.conn = nil
+				}
+				t.m.Unlock()
 			}
 			return
 		}
@@ -171,21 +189,7 @@ func (t *Transport) readLoop() {
 		// Tracker has sent and error.
 		if header.Action == actionError {
 			// The part after the header is the error message.
-			rest := buf[binary.Size(header):]
-			var terr struct {
-				FailureReason string `bencode:"failure reason"`
-				RetryIn       string `bencode:"retry in"`
-			}
-			err = bencode.DecodeBytes(rest, &terr)
-			if err != nil {
-				trx.err = tracker.ErrDecode
-			} else {
-				retryIn, _ := strconv.Atoi(terr.RetryIn)
-				trx.err = &tracker.Error{
-					FailureReason: terr.FailureReason,
-					RetryIn:       time.Duration(retryIn) * time.Minute,
-				}
-			}
+			trx.err = parseError(buf[binary.Size(header):])
 			trx.Done()
 			continue
 		}
@@ -197,6 +201,27 @@ func (t *Transport) readLoop() {
 	}
 }
 
+// parseError returns the error in the message sent by the tracker.
+// BEP 15 defines the message as a plain string but some trackers send
+// a bencoded dictionary as in HTTP trackers, including a "retry in" field.
+func parseError(b []byte) error {
+	if len(b) > 0 && b[0] == 'd' {
+		var terr struct {
+			FailureReason string `bencode:"failure reason"`
+			RetryIn       string `bencode:"retry in"`
+		}
+		err := bencode.DecodeBytes(b, &terr)
+		if err == nil {
+			retryIn, _ := strconv.Atoi(terr.RetryIn)
+			return &tracker.Error{
+				FailureReason: terr.FailureReason,
+				RetryIn:       time.Duration(retryIn) * time.Minute,
+			}
+		}
+	}
+	return &tracker.Error{FailureReason: string(b)}
+}
+
 func (t *Transport) writeTrx(trx *transaction) {
 	t.log.Debugln("Writing transaction. ID:", trx.ID())
 	var buf bytes.Buffer
@@ -205,7 +230,14 @@ func (t *Transport) writeTrx(trx *transaction) {
 		t.log.Error(err)
 		return
 	}
-	_, err = t.conn.WriteTo(buf.Bytes(), trx.addr)
+	t.m.Lock()
+	conn := t.conn
+	t.m.Unlock()
+	if conn == nil {
+		t.log.Debugln("connection is closed, cannot write transaction:", trx.ID())
+		return
+	}
+	_, err = conn.WriteTo(buf.Bytes(), trx.addr)
 	if err != nil {
 		t.log.Error(err)
 	}
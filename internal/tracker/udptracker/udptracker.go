@@ -54,8 +54,11 @@ func (t *UDPTracker) Announce(ctx context.Context, req tracker.AnnounceRequest)
 		Event:      req.Event,
 		NumWant:    int32(req.NumWant),
 		Port:       uint16(req.Torrent.Port),
+		Key:        req.Torrent.Key,
+	}
+	if ip4 := req.Torrent.IP.To4(); ip4 != nil {
+		request.IP = binary.BigEndian.Uint32(ip4)
 	}
-	binary.BigEndian.PutUint32(request.PeerID[16:20], request.Key)
 	request.SetAction(actionAnnounce)
 
 	request2 := &transferAnnounceRequest{
@@ -83,6 +86,61 @@ func (t *UDPTracker) Announce(ctx context.Context, req tracker.AnnounceRequest)
 	}, nil
 }
 
+// maxScrapeInfoHashes is the number of info hashes that can be sent in a single scrape request (BEP 15).
+const maxScrapeInfoHashes = 74
+
+// Scrape the torrents from UDP tracker.
+// Info hashes are sent in batches, as many as possible in each request.
+func (t *UDPTracker) Scrape(ctx context.Context, infoHashes [][20]byte) ([]tracker.ScrapeResult, error) {
+	results := make([]tracker.ScrapeResult, 0, len(infoHashes))
+	for len(infoHashes) > 0 {
+		batch := infoHashes
+		if len(batch) > maxScrapeInfoHashes {
+			batch = batch[:maxScrapeInfoHashes]
+		}
+		infoHashes = infoHashes[len(batch):]
+
+		request := &scrapeRequest{InfoHashes: batch}
+		request.SetAction(actionScrape)
+		trx := newTransaction(request, t.dest)
+		reply, err := t.transport.Do(ctx, trx)
+		if err != nil {
+			return nil, err
+		}
+		items, err := t.parseScrapeResponse(reply, len(batch))
+		if err != nil {
+			return nil, tracker.ErrDecode
+		}
+		for i, item := range items {
+			results = append(results, tracker.ScrapeResult{
+				InfoHash:  batch[i],
+				Seeders:   item.Seeders,
+				Leechers:  item.Leechers,
+				Completed: item.Completed,
+			})
+		}
+	}
+	return results, nil
+}
+
+func (t *UDPTracker) parseScrapeResponse(data []byte, count int) ([]scrapeResponseItem, error) {
+	r := bytes.NewReader(data)
+	var header udpMessageHeader
+	err := binary.Read(r, binary.BigEndian, &header)
+	if err != nil {
+		return nil, err
+	}
+	if header.Action != actionScrape {
+		return nil, errors.New("invalid action")
+	}
+	items := make([]scrapeResponseItem, count)
+	err = binary.Read(r, binary.BigEndian, items)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 func (t *UDPTracker) parseAnnounceResponse(data []byte) (*udpAnnounceResponse, []*net.TCPAddr, error) {
 	var response udpAnnounceResponse
 	err := binary.Read(bytes.NewReader(data), binary.BigEndian, &response)
@@ -2,8 +2,11 @@ package udptracker_test
 
 import (
 	"context"
+	"encoding/binary"
+	"net"
 	"net/url"
 	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -55,7 +58,7 @@ func TestUDPTracker(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	tr := udptracker.NewTransport(nil, 5*time.Second)
+	tr := udptracker.NewTransport(nil, 5*time.Second, nil)
 	trk := udptracker.New(rawURL, u, tr)
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
@@ -94,3 +97,156 @@ func TestUDPTracker(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+// mockUDPTracker accepts all connect requests and replies announce and scrape requests with the result of reply function.
+func mockUDPTracker(t *testing.T, reply func(transactionID []byte, request []byte) []byte) (addr string, closeFunc func()) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, raddr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 16 {
+				continue
+			}
+			action := binary.BigEndian.Uint32(buf[8:12])
+			trxID := buf[12:16]
+			var b []byte
+			switch action {
+			case 0: // connect
+				b = make([]byte, 16)
+				copy(b[4:8], trxID)
+				binary.BigEndian.PutUint64(b[8:16], 42)
+			case 1, 2: // announce, scrape
+				b = reply(trxID, buf[:n])
+			default:
+				continue
+			}
+			_, _ = conn.WriteTo(b, raddr)
+		}
+	}()
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+func TestUDPTrackerAnnounceResponse(t *testing.T) {
+	addr, closeTracker := mockUDPTracker(t, func(trxID, _ []byte) []byte {
+		b := make([]byte, 20+6)
+		binary.BigEndian.PutUint32(b[0:4], 1)
+		copy(b[4:8], trxID)
+		binary.BigEndian.PutUint32(b[8:12], 1800) // interval
+		binary.BigEndian.PutUint32(b[12:16], 3)   // leechers
+		binary.BigEndian.PutUint32(b[16:20], 7)   // seeders
+		copy(b[20:24], net.IPv4(1, 2, 3, 4).To4())
+		binary.BigEndian.PutUint16(b[24:26], 5555)
+		return b
+	})
+	defer closeTracker()
+
+	rawURL := "udp://" + addr + "/announce"
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trk := udptracker.New(rawURL, u, udptracker.NewTransport(nil, 5*time.Second, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := trk.Announce(ctx, tracker.AnnounceRequest{Torrent: tracker.Torrent{Port: 1111}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Interval != 30*time.Minute {
+		t.Errorf("invalid interval: %s", resp.Interval)
+	}
+	if resp.Leechers != 3 || resp.Seeders != 7 {
+		t.Errorf("invalid swarm stats: %d leechers, %d seeders", resp.Leechers, resp.Seeders)
+	}
+	if len(resp.Peers) != 1 || resp.Peers[0].String() != "1.2.3.4:5555" {
+		t.Errorf("invalid peers: %v", resp.Peers)
+	}
+}
+
+func TestUDPTrackerError(t *testing.T) {
+	addr, closeTracker := mockUDPTracker(t, func(trxID, _ []byte) []byte {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint32(b[0:4], 3)
+		copy(b[4:8], trxID)
+		return append(b, "torrent not registered"...)
+	})
+	defer closeTracker()
+
+	rawURL := "udp://" + addr + "/announce"
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trk := udptracker.New(rawURL, u, udptracker.NewTransport(nil, 5*time.Second, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err = trk.Announce(ctx, tracker.AnnounceRequest{Torrent: tracker.Torrent{Port: 1111}})
+	terr, ok := err.(*tracker.Error)
+	if !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if terr.FailureReason != "torrent not registered" {
+		t.Errorf("invalid failure reason: %q", terr.FailureReason)
+	}
+}
+
+func TestUDPTrackerScrape(t *testing.T) {
+	var requests int32
+	addr, closeTracker := mockUDPTracker(t, func(trxID, req []byte) []byte {
+		atomic.AddInt32(&requests, 1)
+		hashes := req[16:]
+		b := make([]byte, 8, 8+len(hashes)/20*12)
+		binary.BigEndian.PutUint32(b[0:4], 2)
+		copy(b[4:8], trxID)
+		for i := 0; i < len(hashes); i += 20 {
+			item := make([]byte, 12)
+			binary.BigEndian.PutUint32(item[0:4], uint32(hashes[i]))    // seeders
+			binary.BigEndian.PutUint32(item[4:8], 10)                   // completed
+			binary.BigEndian.PutUint32(item[8:12], uint32(hashes[i])*2) // leechers
+			b = append(b, item...)
+		}
+		return b
+	})
+	defer closeTracker()
+
+	rawURL := "udp://" + addr + "/announce"
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trk := udptracker.New(rawURL, u, udptracker.NewTransport(nil, 5*time.Second, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	infoHashes := make([][20]byte, 100)
+	for i := range infoHashes {
+		infoHashes[i][0] = byte(i)
+	}
+	results, err := trk.Scrape(ctx, infoHashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("info hashes must be sent in 2 requests, sent %d", n)
+	}
+	if len(results) != len(infoHashes) {
+		t.Fatalf("invalid number of results: %d", len(results))
+	}
+	for i, res := range results {
+		if res.InfoHash != infoHashes[i] || res.Seeders != int32(i) || res.Leechers != int32(i)*2 || res.Completed != 10 {
+			t.Errorf("invalid result at %d: %#v", i, res)
+		}
+	}
+}
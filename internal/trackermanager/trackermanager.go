@@ -3,6 +3,7 @@ package trackermanager
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -10,26 +11,63 @@ import (
 	"time"
 
 	"github.com/cenkalti/rain/internal/blocklist"
+	"github.com/cenkalti/rain/internal/onion"
 	"github.com/cenkalti/rain/internal/resolver"
+	"github.com/cenkalti/rain/internal/socks5"
 	"github.com/cenkalti/rain/internal/tracker"
 	"github.com/cenkalti/rain/internal/tracker/httptracker"
 	"github.com/cenkalti/rain/internal/tracker/udptracker"
 )
 
+var (
+	errUDPOverTor      = errors.New("UDP trackers cannot be used over Tor")
+	errOnionWithoutTor = errors.New("onion trackers can only be reached over Tor")
+)
+
 // TrackerManager is a manager for using the same transport for same domains/IPs.
 // Manages both HTTP and UDP trackers.
 type TrackerManager struct {
 	httpTransport *http.Transport
 	udpTransport  *udptracker.Transport
+	torProxy      *socks5.Dialer
 }
 
 // New returns a new TrackerManager.
-func New(bl *blocklist.Blocklist, dnsTimeout time.Duration, tlsSkipVerify bool) *TrackerManager {
+// If torProxy is not nil, HTTP trackers are contacted through Tor and UDP trackers are disabled.
+// Otherwise, if proxy is not nil, HTTP and UDP trackers are contacted through the SOCKS5 proxy.
+// Addresses of trackers are checked against bl before connecting, also when they are contacted through the proxy.
+// tlsConfig is used for HTTPS trackers regardless of the proxy settings.
+func New(bl *blocklist.Blocklist, dnsTimeout time.Duration, tlsConfig *tls.Config, torProxy, proxy *socks5.Dialer) *TrackerManager {
+	if torProxy != nil {
+		proxy = nil
+	}
 	m := &TrackerManager{
 		httpTransport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: tlsSkipVerify}, // nolint: gosec
+			TLSClientConfig: tlsConfig,
 		},
-		udpTransport: udptracker.NewTransport(bl, dnsTimeout),
+		udpTransport: udptracker.NewTransport(bl, dnsTimeout, proxy),
+		torProxy:     torProxy,
+	}
+	if torProxy != nil {
+		// Host names are resolved by Tor to prevent DNS leaks.
+		m.httpTransport.DialContext = torProxy.DialContext
+		return m
+	}
+	if proxy != nil {
+		m.httpTransport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if bl == nil || bl.Len() == 0 {
+				// Host names are resolved by the proxy to prevent DNS leaks.
+				return proxy.DialContext(ctx, network, addr)
+			}
+			// Address must be checked against the blocklist before connecting, so the host name is resolved locally.
+			ip, port, err := resolver.Resolve(ctx, addr, dnsTimeout, bl)
+			if err != nil {
+				return nil, err
+			}
+			taddr := &net.TCPAddr{IP: ip, Port: port}
+			return proxy.DialContext(ctx, network, taddr.String())
+		}
+		return m
 	}
 	m.httpTransport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
 		ip, port, err := resolver.Resolve(ctx, addr, dnsTimeout, bl)
@@ -49,11 +87,17 @@ func (m *TrackerManager) Get(s string, httpTimeout time.Duration, httpUserAgent
 	if err != nil {
 		return nil, err
 	}
+	if m.torProxy == nil && onion.IsOnion(u.Hostname()) {
+		return nil, errOnionWithoutTor
+	}
 	switch u.Scheme {
 	case "http", "https":
 		tr := httptracker.New(s, u, httpTimeout, m.httpTransport, httpUserAgent, httpMaxResponseLength)
 		return tr, nil
 	case "udp":
+		if m.torProxy != nil {
+			return nil, errUDPOverTor
+		}
 		tr := udptracker.New(s, u, m.udpTransport)
 		return tr, nil
 	default:
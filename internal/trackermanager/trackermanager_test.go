@@ -0,0 +1,50 @@
+package trackermanager
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/blocklist"
+	"github.com/cenkalti/rain/internal/socks5"
+	"github.com/cenkalti/rain/internal/tracker"
+)
+
+func TestProxyBlocklist(t *testing.T) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	connectedC := make(chan struct{}, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		connectedC <- struct{}{}
+		conn.Close()
+	}()
+
+	bl := blocklist.New()
+	if _, err = bl.Reload(strings.NewReader("10.0.0.0/8\n")); err != nil {
+		t.Fatal(err)
+	}
+	m := New(bl, time.Second, nil, nil, socks5.New(l.Addr().String()))
+	trk, err := m.Get("http://10.1.2.3:8080/announce", time.Second, "test", 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err = trk.Announce(ctx, tracker.AnnounceRequest{}); err == nil {
+		t.Fatal("announce to blocked tracker must fail")
+	}
+	select {
+	case <-connectedC:
+		t.Fatal("blocked tracker is contacted through the proxy")
+	default:
+	}
+}
@@ -10,6 +10,12 @@ type Unchoker struct {
 	numUnchoked           int
 	numOptimisticUnchoked int
 
+	// Peers that download from us but do not upload back are choked preferentially while downloading.
+	leechMinUpload int64
+	leechMinRatio  float64
+	// Regular unchoke slots are given only to peers that upload to us while downloading.
+	preferReciprocating bool
+
 	// Every 3rd round an optimistic unchoke logic is applied.
 	round uint8
 
@@ -34,15 +40,30 @@ type Peer interface {
 	// OptimisticUnchoked returns the value previously set by SetOptimistic
 	Optimistic() bool
 
+	// Snubbing returns true if remote peer has stopped sending the pieces we have requested.
+	Snubbing() bool
+
 	DownloadSpeed() int
 	UploadSpeed() int
+
+	// Total bytes received from and sent to remote peer
+	BytesDownloaded() int64
+	BytesUploaded() int64
 }
 
 // New returns a new Unchoker.
-func New(numUnchoked, numOptimisticUnchoked int) *Unchoker {
+// A peer is considered as a leech after uploading leechMinUpload bytes to it,
+// if the ratio of bytes downloaded from the peer to bytes uploaded to the peer is below leechMinRatio.
+// Leech detection is disabled if leechMinRatio is zero.
+// If preferReciprocating is true, while downloading, peers that are not uploading to us
+// can only be unchoked optimistically.
+func New(numUnchoked, numOptimisticUnchoked int, leechMinUpload int64, leechMinRatio float64, preferReciprocating bool) *Unchoker {
 	return &Unchoker{
 		numUnchoked:             numUnchoked,
 		numOptimisticUnchoked:   numOptimisticUnchoked,
+		leechMinUpload:          leechMinUpload,
+		leechMinRatio:           leechMinRatio,
+		preferReciprocating:     preferReciprocating,
 		peersUnchoked:           make(map[Peer]struct{}, numUnchoked),
 		peersUnchokedOptimistic: make(map[Peer]struct{}, numUnchoked),
 	}
@@ -64,23 +85,65 @@ func (u *Unchoker) candidatesUnchoke(allPeers []Peer) []Peer {
 	return peers
 }
 
-func (u *Unchoker) sortPeers(peers []Peer, completed bool) {
+// isLeech returns true if the peer does not reciprocate the data we upload to it.
+func (u *Unchoker) isLeech(pe Peer) bool {
+	if u.leechMinRatio <= 0 {
+		return false
+	}
+	uploaded := pe.BytesUploaded()
+	if uploaded == 0 || uploaded < u.leechMinUpload {
+		return false
+	}
+	return float64(pe.BytesDownloaded())/float64(uploaded) < u.leechMinRatio
+}
+
+// isReciprocating returns true if the peer has recently uploaded to us.
+func isReciprocating(pe Peer) bool {
+	return pe.DownloadSpeed() > 0
+}
+
+// sortPeers sorts the peers by the order of preference for unchoking.
+// While downloading, leeches and peers that are snubbing us are placed after other peers in the slice (anti-snubbing).
+// Returns the number of peers that are not leeches or snubbing.
+func (u *Unchoker) sortPeers(peers []Peer, completed bool) int {
 	byUploadSpeed := func(i, j int) bool { return peers[i].UploadSpeed() > peers[j].UploadSpeed() }
-	byDownloadSpeed := func(i, j int) bool { return peers[i].DownloadSpeed() > peers[j].DownloadSpeed() }
 	if completed {
+		// Peers cannot upload to us when we are seeding.
 		sort.Slice(peers, byUploadSpeed)
-	} else {
-		sort.Slice(peers, byDownloadSpeed)
+		return len(peers)
+	}
+	leech := make(map[Peer]bool, len(peers))
+	var numLeeches int
+	for _, pe := range peers {
+		if u.isLeech(pe) || pe.Snubbing() {
+			leech[pe] = true
+			numLeeches++
+		}
 	}
+	sort.Slice(peers, func(i, j int) bool {
+		if leech[peers[i]] != leech[peers[j]] {
+			return !leech[peers[i]]
+		}
+		return peers[i].DownloadSpeed() > peers[j].DownloadSpeed()
+	})
+	return len(peers) - numLeeches
 }
 
 // TickUnchoke must be called at every 10 seconds.
 func (u *Unchoker) TickUnchoke(allPeers []Peer, torrentCompleted bool) {
 	optimistic := u.round == 0
 	peers := u.candidatesUnchoke(allPeers)
-	u.sortPeers(peers, torrentCompleted)
+	numNonLeeches := u.sortPeers(peers, torrentCompleted)
+	numRegular := len(peers)
+	if u.preferReciprocating && !torrentCompleted {
+		// Non-leeches are sorted by download speed so reciprocating peers are at the front.
+		numRegular = 0
+		for numRegular < numNonLeeches && isReciprocating(peers[numRegular]) {
+			numRegular++
+		}
+	}
 	var i, unchoked int
-	for ; i < len(peers) && unchoked < u.numUnchoked; i++ {
+	for ; i < numRegular && unchoked < u.numUnchoked; i++ {
 		if !optimistic && peers[i].Optimistic() {
 			continue
 		}
@@ -88,11 +151,25 @@ func (u *Unchoker) TickUnchoke(allPeers []Peer, torrentCompleted bool) {
 		unchoked++
 	}
 	peers = peers[i:]
+	numNonLeeches -= i
+	if numNonLeeches < 0 {
+		numNonLeeches = 0
+	}
 	if optimistic {
 		for i = 0; i < u.numOptimisticUnchoked && len(peers) > 0; i++ {
+			// Leeches are unchoked only if there are no other candidates.
 			n := rand.Intn(len(peers)) // nolint: gosec
+			if numNonLeeches > 0 {
+				n = rand.Intn(numNonLeeches) // nolint: gosec
+			}
 			pe := peers[n]
 			u.optimisticUnchokePeer(pe)
+			if n < numNonLeeches {
+				// Keep non-leeches at the front of the slice.
+				peers[n], peers[numNonLeeches-1] = peers[numNonLeeches-1], peers[n]
+				n = numNonLeeches - 1
+				numNonLeeches--
+			}
 			peers[n], peers = peers[len(peers)-1], peers[:len(peers)-1]
 		}
 	}
@@ -142,11 +219,22 @@ func (u *Unchoker) optimisticUnchokePeer(pe Peer) {
 	pe.SetOptimistic(true)
 }
 
+// ChokeAll chokes all unchoked peers. Must be called when uploading is paused.
+func (u *Unchoker) ChokeAll() {
+	for pe := range u.peersUnchoked {
+		u.chokePeer(pe)
+	}
+	for pe := range u.peersUnchokedOptimistic {
+		u.chokePeer(pe)
+	}
+}
+
 // FastUnchoke must be called when remote peer is interested.
 // Remote peer is unchoked immediately if there are not enough unchoked peers.
 // Without this function, remote peer would have to wait for next unchoke period.
-func (u *Unchoker) FastUnchoke(pe Peer) {
-	if pe.Choking() && pe.Interested() && len(u.peersUnchoked) < u.numUnchoked {
+func (u *Unchoker) FastUnchoke(pe Peer, torrentCompleted bool) {
+	regular := !u.preferReciprocating || torrentCompleted || isReciprocating(pe)
+	if regular && pe.Choking() && pe.Interested() && len(u.peersUnchoked) < u.numUnchoked {
 		u.unchokePeer(pe)
 	}
 	if pe.Choking() && pe.Interested() && len(u.peersUnchokedOptimistic) < u.numOptimisticUnchoked {
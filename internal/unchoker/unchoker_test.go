@@ -33,7 +33,7 @@ func TestTickUnchoke(t *testing.T) {
 		}
 		return peers
 	}
-	u := New(2, 1)
+	u := New(2, 1, 0, 0, false)
 
 	// Must unchoke fastest downloading 2 peers
 	u.round = 1
@@ -143,12 +143,106 @@ func TestTickUnchoke(t *testing.T) {
 	}, testPeers)
 }
 
+func TestTickUnchokeLeech(t *testing.T) {
+	uploader := &TestPeer{interested: true, choking: true, downloadSpeed: 4, downloaded: 100, uploaded: 100}
+	// Downloads from us but never uploads back.
+	leech := &TestPeer{interested: true, downloaded: 0, uploaded: 100}
+	newcomer := &TestPeer{interested: true, choking: true}
+	peers := func() []Peer { return []Peer{leech, newcomer, uploader} }
+	u := New(1, 1, 50, 0.5, false)
+	u.peersUnchoked[leech] = struct{}{}
+
+	for i := 0; i < 10; i++ {
+		u.round = 0
+		u.TickUnchoke(peers(), false)
+		assert.False(t, uploader.choking)
+		assert.False(t, newcomer.choking)
+		assert.True(t, newcomer.optimistic)
+		assert.True(t, leech.choking)
+	}
+
+	// Leech is unchoked when there are no other candidates.
+	newcomer.interested = false
+	u.round = 0
+	u.TickUnchoke(peers(), false)
+	assert.False(t, leech.choking)
+	assert.True(t, leech.optimistic)
+}
+
+func TestTickUnchokeRanking(t *testing.T) {
+	a := &TestPeer{interested: true, choking: true, downloadSpeed: 10, uploadSpeed: 1}
+	b := &TestPeer{interested: true, choking: true, downloadSpeed: 30, uploadSpeed: 2}
+	c := &TestPeer{interested: true, choking: true, downloadSpeed: 20, uploadSpeed: 3}
+	d := &TestPeer{interested: true, choking: true, downloadSpeed: 40, uploadSpeed: 4, snubbing: true}
+	peers := func() []Peer { return []Peer{a, b, c, d} }
+	u := New(2, 1, 0, 0, false)
+
+	// Fastest downloading peers are unchoked. Snubbing peer is ranked last even though it was the fastest.
+	u.round = 1
+	u.TickUnchoke(peers(), false)
+	assert.Equal(t, []bool{true, false, false, true}, []bool{a.choking, b.choking, c.choking, d.choking})
+
+	// Snubbing peer is unchoked when it starts sending pieces again.
+	d.snubbing = false
+	u.round = 1
+	u.TickUnchoke(peers(), false)
+	assert.Equal(t, []bool{true, false, true, false}, []bool{a.choking, b.choking, c.choking, d.choking})
+
+	// Fastest uploading peers are unchoked when seeding. Snubbing does not matter.
+	d.snubbing = true
+	u.round = 1
+	u.TickUnchoke(peers(), true)
+	assert.Equal(t, []bool{true, true, false, false}, []bool{a.choking, b.choking, c.choking, d.choking})
+}
+
+func TestTickUnchokeReciprocating(t *testing.T) {
+	fast := &TestPeer{interested: true, choking: true, downloadSpeed: 20, uploadSpeed: 1, downloaded: 200, uploaded: 100}
+	slow := &TestPeer{interested: true, choking: true, downloadSpeed: 10, uploadSpeed: 2, downloaded: 100, uploaded: 100}
+	// Uploaded to us before but not sending anything recently.
+	idle := &TestPeer{interested: true, choking: true, uploadSpeed: 3, downloaded: 100, uploaded: 100}
+	leech := &TestPeer{interested: true, choking: true, uploaded: 100}
+	peers := func() []Peer { return []Peer{leech, idle, slow, fast} }
+	u := New(3, 1, 50, 0.5, true)
+
+	// Only reciprocating peers get regular slots even if there are free slots.
+	u.round = 1
+	u.TickUnchoke(peers(), false)
+	assert.Equal(t, []bool{false, false, true, true}, []bool{fast.choking, slow.choking, idle.choking, leech.choking})
+
+	// Non-reciprocating peers are unchoked optimistically. Leech is not selected while there are other candidates.
+	for i := 0; i < 10; i++ {
+		u.round = 0
+		u.TickUnchoke(peers(), false)
+		assert.Equal(t, []bool{false, false, false, true}, []bool{fast.choking, slow.choking, idle.choking, leech.choking})
+		assert.True(t, idle.optimistic)
+	}
+
+	// Optimistic slot is the only way for a non-reciprocating peer to be unchoked quickly.
+	newcomer := &TestPeer{interested: true, choking: true}
+	u.FastUnchoke(newcomer, false)
+	assert.True(t, newcomer.choking)
+	u.HandleDisconnect(idle)
+	u.FastUnchoke(newcomer, false)
+	assert.False(t, newcomer.choking)
+	assert.True(t, newcomer.optimistic)
+
+	// All peers are ranked by upload speed when seeding.
+	idle.choking = true
+	idle.optimistic = false
+	u.round = 1
+	u.TickUnchoke(peers(), true)
+	assert.Equal(t, []bool{false, false, false, true}, []bool{fast.choking, slow.choking, idle.choking, leech.choking})
+}
+
 type TestPeer struct {
 	interested    bool
 	choking       bool
 	optimistic    bool
+	snubbing      bool
 	downloadSpeed int
 	uploadSpeed   int
+	downloaded    int64
+	uploaded      int64
 }
 
 func (p *TestPeer) Choke()                   { p.choking = true }
@@ -157,5 +251,8 @@ func (p *TestPeer) Choking() bool            { return p.choking }
 func (p *TestPeer) Interested() bool         { return p.interested }
 func (p *TestPeer) Optimistic() bool         { return p.optimistic }
 func (p *TestPeer) SetOptimistic(value bool) { p.optimistic = value }
+func (p *TestPeer) Snubbing() bool           { return p.snubbing }
 func (p *TestPeer) DownloadSpeed() int       { return p.downloadSpeed }
 func (p *TestPeer) UploadSpeed() int         { return p.uploadSpeed }
+func (p *TestPeer) BytesDownloaded() int64   { return p.downloaded }
+func (p *TestPeer) BytesUploaded() int64     { return p.uploaded }
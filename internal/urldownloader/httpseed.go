@@ -0,0 +1,118 @@
+package urldownloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/rain/internal/bufferpool"
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/speedlimit"
+)
+
+// NewHTTPSeed returns a new URLDownloader for a Hoffman-style HTTP seed (BEP 17) that is listed in "httpseeds" key of the torrent.
+// Unlike GetRight-style web seeds, pieces are requested one by one with the info hash and the piece index in the query string.
+func NewHTTPSeed(source string, infoHash [20]byte, begin, end uint32) *URLDownloader {
+	d := New(source, begin, end)
+	d.HTTPSeed = true
+	d.infoHash = infoHash
+	return d
+}
+
+func (d *URLDownloader) runHTTPSeed(ctx context.Context, cancel context.CancelFunc, client *http.Client, pieces []piece.Piece, resultC chan interface{}, pool *bufferpool.Pool, readTimeout time.Duration, limiter *speedlimit.Limiter) {
+	if d.Begin >= d.readEnd() {
+		return
+	}
+	for {
+		index := d.ReadCurrent()
+		buf := pool.Get(int(pieces[index].Length))
+		err := d.downloadHTTPSeedPiece(ctx, cancel, client, index, buf, readTimeout, limiter)
+		if err != nil {
+			buf.Release()
+			d.sendResult(resultC, &PieceResult{Downloader: d, Error: err})
+			return
+		}
+		done := index >= d.readEnd()-1
+		d.sendResult(resultC, &PieceResult{Downloader: d, Buffer: buf, Index: index, Done: done})
+		if done {
+			return
+		}
+		d.incrCurrent()
+	}
+}
+
+func (d *URLDownloader) downloadHTTPSeedPiece(ctx context.Context, cancel context.CancelFunc, client *http.Client, index uint32, buf bufferpool.Buffer, readTimeout time.Duration, limiter *speedlimit.Limiter) error {
+	req, err := http.NewRequest(http.MethodGet, d.getHTTPSeedURL(index), nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return checkHTTPSeedStatus(resp)
+	}
+	timer := time.AfterFunc(readTimeout, cancel)
+	defer timer.Stop()
+	var n int // position in piece
+	for n < len(buf.Data) {
+		readSize := len(buf.Data) - n
+		if limiter != nil && readSize > limitedReadSize {
+			readSize = limitedReadSize
+		}
+		o, err := readFull(resp.Body, buf.Data[n:n+readSize], timer, readTimeout)
+		if err != nil {
+			return err
+		}
+		if wait := limiter.Take(int64(o)); wait > 0 {
+			timer.Stop()
+			select {
+			case <-time.After(wait):
+			case <-d.closeC:
+				return context.Canceled
+			}
+			timer.Reset(readTimeout)
+		}
+		n += o
+	}
+	return nil
+}
+
+// getHTTPSeedURL returns the URL for requesting the piece at index from the HTTP seed.
+// Source URL may already contain a query string, e.g. "http://example.com/seed.php?id=1".
+func (d *URLDownloader) getHTTPSeedURL(index uint32) string {
+	src := d.URL
+	switch {
+	case strings.HasSuffix(src, "?") || strings.HasSuffix(src, "&"):
+	case strings.Contains(src, "?"):
+		src += "&"
+	default:
+		src += "?"
+	}
+	return src + "info_hash=" + url.QueryEscape(string(d.infoHash[:])) + "&piece=" + strconv.FormatUint(uint64(index), 10)
+}
+
+// checkHTTPSeedStatus returns the error for a response with a status other than 200.
+// A busy HTTP seed responds with 503 and the number of seconds to wait before retrying in the body.
+func checkHTTPSeedStatus(resp *http.Response) error {
+	err := checkStatus(resp)
+	if err == nil {
+		// Partial content is not expected since ranges are not requested.
+		return &StatusError{Code: resp.StatusCode}
+	}
+	var serr *StatusError
+	if errors.As(err, &serr) && serr.Code == http.StatusServiceUnavailable && serr.RetryAfter == 0 {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 16))
+		serr.RetryAfter = parseRetryAfter(string(b), time.Now())
+	}
+	return err
+}
@@ -2,22 +2,30 @@ package urldownloader
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/cenkalti/rain/internal/bufferpool"
 	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/speedlimit"
 )
 
 // URLDownloader downloads files from a HTTP source.
 type URLDownloader struct {
 	URL                 string
 	Begin, End, current uint32
-	closeC, doneC       chan struct{}
+	// HTTPSeed is true if the source speaks the BEP 17 protocol instead of serving the files of the torrent.
+	HTTPSeed      bool
+	infoHash      [20]byte
+	closeC, doneC chan struct{}
 }
 
 // PieceResult wraps the downloaded piece data.
@@ -70,8 +78,13 @@ func (d *URLDownloader) ReadCurrent() uint32 {
 	return atomic.LoadUint32(&d.current)
 }
 
+// limitedReadSize is the max number of bytes read from response at once when a limiter is given.
+const limitedReadSize = 16 * 1024
+
 // Run the URLDownloader and download pieces.
-func (d *URLDownloader) Run(client *http.Client, pieces []piece.Piece, multifile bool, resultC chan interface{}, pool *bufferpool.Pool, readTimeout time.Duration) {
+// multifile is not used for HTTP seeds since they are requested by piece index.
+// If limiter is not nil, reading from response bodies is slowed down to stay under the rate of the limiter.
+func (d *URLDownloader) Run(client *http.Client, pieces []piece.Piece, multifile bool, resultC chan interface{}, pool *bufferpool.Pool, readTimeout time.Duration, limiter *speedlimit.Limiter) {
 	defer close(d.doneC)
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
@@ -82,6 +95,11 @@ func (d *URLDownloader) Run(client *http.Client, pieces []piece.Piece, multifile
 		cancel()
 	}()
 
+	if d.HTTPSeed {
+		d.runHTTPSeed(ctx, cancel, client, pieces, resultC, pool, readTimeout, limiter)
+		return
+	}
+
 	jobs := createJobs(pieces, d.Begin, d.readEnd())
 
 	var n int // position in piece
@@ -103,6 +121,9 @@ func (d *URLDownloader) Run(client *http.Client, pieces []piece.Piece, multifile
 		}
 		defer resp.Body.Close()
 		err = checkStatus(resp)
+		if err == nil {
+			err = checkRange(resp, job)
+		}
 		if err != nil {
 			d.sendResult(resultC, &PieceResult{Downloader: d, Error: err})
 			return false
@@ -112,11 +133,23 @@ func (d *URLDownloader) Run(client *http.Client, pieces []piece.Piece, multifile
 		var m int64 // position in response
 		for m < job.Length {
 			readSize := calcReadSize(buf, n, job, m)
+			if limiter != nil && readSize > limitedReadSize {
+				readSize = limitedReadSize
+			}
 			o, err := readFull(resp.Body, buf.Data[n:int64(n)+readSize], timer, readTimeout)
 			if err != nil {
 				d.sendResult(resultC, &PieceResult{Downloader: d, Error: err})
 				return false
 			}
+			if wait := limiter.Take(int64(o)); wait > 0 {
+				timer.Stop()
+				select {
+				case <-time.After(wait):
+				case <-d.closeC:
+					return false
+				}
+				timer.Reset(readTimeout)
+			}
 			n += o
 			m += int64(o)
 			if n == len(buf.Data) { // piece completed
@@ -170,14 +203,23 @@ func (d *URLDownloader) getURL(filename string, multifile bool) string {
 	src := d.URL
 	if !multifile {
 		if src[len(src)-1] == '/' {
-			src += url.PathEscape(filename)
+			src += escapePath(filename)
 		}
 		return src
 	}
 	if src[len(src)-1] != '/' {
 		src += "/"
 	}
-	return src + url.PathEscape(filename)
+	return src + escapePath(filename)
+}
+
+// escapePath escapes the elements of a file path in the torrent separately, so directory separators are kept in the URL.
+func escapePath(name string) string {
+	parts := strings.Split(filepath.ToSlash(name), "/")
+	for i := range parts {
+		parts[i] = url.PathEscape(parts[i])
+	}
+	return strings.Join(parts, "/")
 }
 
 func (d *URLDownloader) sendResult(resultC chan interface{}, res *PieceResult) {
@@ -192,11 +234,86 @@ func (d *URLDownloader) sendResult(resultC chan interface{}, res *PieceResult) {
 	}
 }
 
+// StatusError is returned when the server responds with an unexpected status code.
+type StatusError struct {
+	Code int
+	// Delay requested by the server in Retry-After header of 429 and 503 responses. Zero if not present.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.Code)
+}
+
 func checkStatus(resp *http.Response) error {
 	switch resp.StatusCode {
 	case 200, 206:
 		return nil
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return &StatusError{
+			Code:       resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	default:
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return &StatusError{Code: resp.StatusCode}
+	}
+}
+
+// checkRange checks that the response body starts at the beginning of the requested range and is long enough.
+// Otherwise, bytes from wrong offsets would be written into pieces, e.g. when the server ignores the Range header.
+func checkRange(resp *http.Response, job downloadJob) error {
+	if resp.StatusCode == http.StatusOK {
+		// Server sent the whole file. It is usable only if the range starts at the beginning of the file.
+		if job.RangeBegin != 0 {
+			return errors.New("server does not support range requests")
+		}
+		return nil
+	}
+	first, last, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return err
+	}
+	if first != job.RangeBegin || last < job.RangeBegin+job.Length-1 {
+		return fmt.Errorf("unexpected content range: %d-%d, requested: %d-%d", first, last, job.RangeBegin, job.RangeBegin+job.Length-1)
+	}
+	return nil
+}
+
+// parseContentRange returns the first and last byte positions in the Content-Range header value, e.g. "bytes 0-99/100".
+func parseContentRange(value string) (first, last int64, err error) {
+	err = fmt.Errorf("invalid content range: %q", value)
+	if !strings.HasPrefix(value, "bytes ") {
+		return
+	}
+	value = strings.TrimPrefix(value, "bytes ")
+	if i := strings.IndexByte(value, '/'); i >= 0 {
+		value = value[:i]
+	}
+	parts := strings.SplitN(value, "-", 2)
+	if len(parts) != 2 {
+		return
+	}
+	first, err1 := strconv.ParseInt(parts[0], 10, 64)
+	last, err2 := strconv.ParseInt(parts[1], 10, 64)
+	if err1 != nil || err2 != nil || first > last {
+		return 0, 0, err
+	}
+	return first, last, nil
+}
+
+// parseRetryAfter parses the value of Retry-After header which can be in seconds or HTTP-date form.
+// Returns zero if the value is invalid or in the past.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	t, err := http.ParseTime(value)
+	if err != nil || !t.After(now) {
+		return 0
 	}
+	return t.Sub(now)
 }
@@ -0,0 +1,184 @@
+package urldownloader
+
+import (
+	"bytes"
+	"crypto/sha1" // nolint: gosec
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/bufferpool"
+	"github.com/cenkalti/rain/internal/filesection"
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
+
+func TestDownloadLastPiece(t *testing.T) {
+	const pieceLength = 4 * piece.BlockSize
+	// Last piece has a full block and a short block.
+	data := make([]byte, 2*pieceLength+piece.BlockSize+100)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	var pieces []piece.Piece
+	for i := uint32(0); int64(i)*pieceLength < int64(len(data)); i++ {
+		begin := int64(i) * pieceLength
+		end := begin + pieceLength
+		if end > int64(len(data)) {
+			end = int64(len(data))
+		}
+		sum := sha1.Sum(data[begin:end]) // nolint: gosec
+		pieces = append(pieces, piece.Piece{
+			Index:  i,
+			Length: uint32(end - begin),
+			Data:   filesection.Piece{{Name: "file", Offset: begin, Length: end - begin}},
+			Hash:   sum[:],
+		})
+	}
+
+	// Accessed from the server goroutines.
+	var m sync.Mutex
+	var ranges []string
+	ignoreRange := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		if ignoreRange {
+			r.Header.Del("Range")
+		}
+		m.Unlock()
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	download := func() *PieceResult {
+		d := New(srv.URL+"/file", 2, 3)
+		resultC := make(chan interface{}, 1)
+		go d.Run(srv.Client(), pieces, false, resultC, bufferpool.New(pieceLength), time.Second, nil)
+		defer d.Close()
+		select {
+		case res := <-resultC:
+			return res.(*PieceResult)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+			return nil
+		}
+	}
+
+	res := download()
+	assert.NoError(t, res.Error)
+	assert.Equal(t, uint32(2), res.Index)
+	assert.True(t, res.Done)
+	assert.Equal(t, data[2*pieceLength:], res.Buffer.Data)
+	assert.True(t, pieces[2].VerifyHash(res.Buffer.Data, sha1.New())) // nolint: gosec
+	m.Lock()
+	assert.Equal(t, []string{fmt.Sprintf("bytes=%d-%d", 2*pieceLength, len(data)-1)}, ranges)
+	m.Unlock()
+	res.Buffer.Release()
+
+	// Body of a response that ignores the Range header must not be written into the piece.
+	m.Lock()
+	ignoreRange = true
+	m.Unlock()
+	res = download()
+	assert.EqualError(t, res.Error, "server does not support range requests")
+}
+
+func TestGetURL(t *testing.T) {
+	d := New("http://example.com/files", 0, 1)
+	assert.Equal(t, "http://example.com/files/dir/a%20b/c%23d%3F.txt", d.getURL(filepath.Join("dir", "a b", "c#d?.txt"), true))
+	assert.Equal(t, "http://example.com/files", d.getURL("file.txt", false))
+	d = New("http://example.com/files/", 0, 1)
+	assert.Equal(t, "http://example.com/files/100%25.txt", d.getURL("100%.txt", false))
+}
+
+func TestGetHTTPSeedURL(t *testing.T) {
+	var infoHash [20]byte
+	copy(infoHash[:], "\x01 &=?abcdefghijklmno")
+	d := NewHTTPSeed("http://example.com/seed.php", infoHash, 0, 1)
+	assert.Equal(t, "http://example.com/seed.php?info_hash=%01+%26%3D%3Fabcdefghijklmno&piece=7", d.getHTTPSeedURL(7))
+	d = NewHTTPSeed("http://example.com/seed.php?id=1", infoHash, 0, 1)
+	assert.Equal(t, "http://example.com/seed.php?id=1&info_hash=%01+%26%3D%3Fabcdefghijklmno&piece=7", d.getHTTPSeedURL(7))
+}
+
+func TestDownloadHTTPSeed(t *testing.T) {
+	const pieceLength = 2 * piece.BlockSize
+	data := make([]byte, 2*pieceLength+100)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	// Pieces span multiple files but HTTP seeds are requested by piece index.
+	pieces := []piece.Piece{
+		{Index: 0, Length: pieceLength, Data: filesection.Piece{{Name: "a", Offset: 0, Length: 100}, {Name: "b", Offset: 0, Length: pieceLength - 100}}},
+		{Index: 1, Length: pieceLength, Data: filesection.Piece{{Name: "b", Offset: pieceLength - 100, Length: pieceLength}}},
+		{Index: 2, Length: 100, Data: filesection.Piece{{Name: "b", Offset: 2*pieceLength - 100, Length: 100}}},
+	}
+	var infoHash [20]byte
+	copy(infoHash[:], "01234567890123456789")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("info_hash") != string(infoHash[:]) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		index, err := strconv.Atoi(r.URL.Query().Get("piece"))
+		if err != nil || index >= len(pieces) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		begin := index * pieceLength
+		_, _ = w.Write(data[begin : begin+int(pieces[index].Length)])
+	}))
+	defer srv.Close()
+
+	d := NewHTTPSeed(srv.URL+"/seed", infoHash, 1, 3)
+	resultC := make(chan interface{}, 2)
+	go d.Run(srv.Client(), pieces, true, resultC, bufferpool.New(pieceLength), time.Second, nil)
+	for i := uint32(1); i < 3; i++ {
+		select {
+		case res := <-resultC:
+			pr := res.(*PieceResult)
+			assert.NoError(t, pr.Error)
+			assert.Equal(t, i, pr.Index)
+			assert.Equal(t, i == 2, pr.Done)
+			assert.Equal(t, data[i*pieceLength:i*pieceLength+pieces[i].Length], pr.Buffer.Data)
+			pr.Buffer.Release()
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+	d.Close()
+
+	// Busy seed tells how long to wait in response body.
+	busySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("30"))
+	}))
+	defer busySrv.Close()
+	d = NewHTTPSeed(busySrv.URL+"/seed", infoHash, 0, 1)
+	go d.Run(busySrv.Client(), pieces, true, resultC, bufferpool.New(pieceLength), time.Second, nil)
+	defer d.Close()
+	select {
+	case res := <-resultC:
+		var serr *StatusError
+		assert.True(t, errors.As(res.(*PieceResult).Error, &serr))
+		assert.Equal(t, 30*time.Second, serr.RetryAfter)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+}
@@ -0,0 +1,63 @@
+package verifier
+
+import (
+	"os"
+	"time"
+
+	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/storage"
+)
+
+// FileStat contains the size and modification time of a file in the torrent.
+// Zero value means that the file does not exist or has no data on disk.
+type FileStat struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// Resume contains the result of a previous verification and the stats of the files at that time.
+// If the files are not changed since then, pieces are not read again.
+type Resume struct {
+	Bitfield  *bitfield.Bitfield
+	FileStats []FileStat
+	Storage   storage.Storage
+	Files     []metainfo.File
+}
+
+// FileStats returns the stats of the files in the same order.
+// Padding files and symlinks have zero stats because their data is not read from disk.
+func FileStats(sto storage.Storage, files []metainfo.File) ([]FileStat, error) {
+	stats := make([]FileStat, len(files))
+	for i, f := range files {
+		if f.Padding || f.Symlink != "" {
+			continue
+		}
+		fi, err := sto.Stat(f.Path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		stats[i] = FileStat{Size: fi.Size(), ModTime: fi.ModTime()}
+	}
+	return stats, nil
+}
+
+// unchanged returns true if the files have the same stats as the time the bitfield is saved.
+func (r *Resume) unchanged() bool {
+	if r.Bitfield == nil || len(r.FileStats) != len(r.Files) {
+		return false
+	}
+	stats, err := FileStats(r.Storage, r.Files)
+	if err != nil {
+		return false
+	}
+	for i := range stats {
+		if stats[i].Size != r.FileStats[i].Size || !stats[i].ModTime.Equal(r.FileStats[i].ModTime) {
+			return false
+		}
+	}
+	return true
+}
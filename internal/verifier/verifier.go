@@ -1,19 +1,25 @@
 package verifier
 
 import (
-	"crypto/sha1"
+	"errors"
+	"sync"
 
 	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/semaphore"
+	"github.com/cenkalti/rain/internal/storage"
 )
 
 // Verifier verifies the pieces on disk.
 type Verifier struct {
 	Bitfield *bitfield.Bitfield
 	Error    error
+	// True if the bitfield is taken from resume data without reading the pieces.
+	Resumed bool
 
-	closeC chan struct{}
-	doneC  chan struct{}
+	concurrency int
+	closeC      chan struct{}
+	doneC       chan struct{}
 }
 
 // Progress information about the verification.
@@ -21,11 +27,15 @@ type Progress struct {
 	Checked uint32
 }
 
-// New returns a new Verifier.
-func New() *Verifier {
+// New returns a new Verifier that hashes up to concurrency pieces in parallel.
+func New(concurrency int) *Verifier {
+	if concurrency < 1 {
+		concurrency = 1
+	}
 	return &Verifier{
-		closeC: make(chan struct{}),
-		doneC:  make(chan struct{}),
+		concurrency: concurrency,
+		closeC:      make(chan struct{}),
+		doneC:       make(chan struct{}),
 	}
 }
 
@@ -36,7 +46,11 @@ func (v *Verifier) Close() {
 }
 
 // Run and verify all pieces of the torrent.
-func (v *Verifier) Run(pieces []piece.Piece, progressC chan Progress, resultC chan *Verifier) {
+// Pieces in files that are not created yet are reported as missing.
+// If skipHash is true, files are not read and all pieces are assumed to be complete.
+// If resume is not nil and the files are not changed since it is saved, its bitfield is used without reading the pieces.
+// Reading files starts after acquiring sem, so the number of verifiers reading from disk at the same time is limited.
+func (v *Verifier) Run(pieces []piece.Piece, skipHash bool, resume *Resume, sem *semaphore.Semaphore, progressC chan Progress, resultC chan *Verifier) {
 	defer close(v.doneC)
 
 	defer func() {
@@ -47,25 +61,109 @@ func (v *Verifier) Run(pieces []piece.Piece, progressC chan Progress, resultC ch
 	}()
 
 	v.Bitfield = bitfield.New(uint32(len(pieces)))
-	buf := make([]byte, pieces[0].Length)
-	hash := sha1.New()
-	var numOK uint32
-	for _, p := range pieces {
-		buf = buf[:p.Length]
-		_, v.Error = p.Data.ReadAt(buf, 0)
-		if v.Error != nil {
+	if skipHash {
+		for i := range pieces {
+			v.Bitfield.Set(uint32(i))
+		}
+		return
+	}
+	if resume != nil && resume.unchanged() {
+		v.Bitfield = resume.Bitfield.Copy()
+		v.Resumed = true
+		return
+	}
+	if !sem.WaitOrClose(v.closeC) {
+		return
+	}
+	defer sem.Signal()
+	v.verifyPieces(pieces, progressC)
+}
+
+type pieceResult struct {
+	// Position of the piece in the slice passed to Run.
+	pos   int
+	ok    bool
+	error error
+}
+
+// verifyPieces hashes pieces in worker goroutines.
+// Pieces are handed to workers in order and Progress is reported with the number of consecutive pieces checked from the start,
+// so Checked never decreases even if workers finish out of order.
+func (v *Verifier) verifyPieces(pieces []piece.Piece, progressC chan Progress) {
+	jobC := make(chan int)
+	resultC := make(chan pieceResult)
+	stopC := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(v.concurrency)
+	for i := 0; i < v.concurrency; i++ {
+		go v.worker(pieces, jobC, resultC, stopC, &wg)
+	}
+	defer wg.Wait()
+	defer close(stopC)
+
+	done := make([]bool, len(pieces))
+	var sent, checked int
+	for {
+		if checked < len(pieces) && done[checked] {
+			for checked < len(pieces) && done[checked] {
+				checked++
+			}
+			select {
+			case progressC <- Progress{Checked: uint32(checked)}:
+			case <-v.closeC:
+				return
+			}
+		}
+		if checked == len(pieces) {
 			return
 		}
-		ok := p.VerifyHash(buf, hash)
-		if ok {
-			v.Bitfield.Set(p.Index)
-			numOK++
+		var sendC chan int
+		if sent < len(pieces) {
+			sendC = jobC
 		}
 		select {
-		case progressC <- Progress{Checked: p.Index + 1}:
+		case sendC <- sent:
+			sent++
+		case res := <-resultC:
+			if res.error != nil {
+				v.Error = res.error
+				return
+			}
+			if res.ok {
+				v.Bitfield.Set(pieces[res.pos].Index)
+			}
+			done[res.pos] = true
 		case <-v.closeC:
 			return
 		}
-		hash.Reset()
+	}
+}
+
+func (v *Verifier) worker(pieces []piece.Piece, jobC chan int, resultC chan pieceResult, stopC chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	buf := make([]byte, pieces[0].Length)
+	for {
+		select {
+		case pos := <-jobC:
+			p := &pieces[pos]
+			res := pieceResult{pos: pos}
+			buf = buf[:p.Length]
+			_, res.error = p.Data.ReadAt(buf, 0)
+			switch {
+			case errors.Is(res.error, storage.ErrNotCreated):
+				// Piece is in a file that has not been written yet, so it is missing.
+				res.error = nil
+			case res.error == nil:
+				// Hash is created for each piece because v2 pieces at the end of files are hashed differently.
+				res.ok = p.VerifyHash(buf, p.NewHash())
+			}
+			select {
+			case resultC <- res:
+			case <-stopC:
+				return
+			}
+		case <-stopC:
+			return
+		}
 	}
 }
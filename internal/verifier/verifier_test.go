@@ -0,0 +1,258 @@
+package verifier
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/filesection"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/semaphore"
+	"github.com/cenkalti/rain/internal/storage"
+	"github.com/cenkalti/rain/internal/storage/filestorage"
+)
+
+type failingReader struct {
+	t *testing.T
+}
+
+func (r failingReader) ReadAt(b []byte, off int64) (int, error) {
+	r.t.Fatal("data must not be read")
+	return 0, nil
+}
+
+func (r failingReader) WriteAt(b []byte, off int64) (int, error) {
+	return len(b), nil
+}
+
+func TestSkipHash(t *testing.T) {
+	pieces := make([]piece.Piece, 3)
+	for i := range pieces {
+		pieces[i] = piece.Piece{
+			Index:  uint32(i),
+			Length: 4,
+			Data:   filesection.Piece{{File: failingReader{t}, Length: 4}},
+			Hash:   make([]byte, 20),
+		}
+	}
+	v := New(1)
+	resultC := make(chan *Verifier, 1)
+	v.Run(pieces, true, nil, semaphore.New(1), make(chan Progress), resultC)
+	<-resultC
+	if v.Error != nil {
+		t.Fatal(v.Error)
+	}
+	if !v.Bitfield.All() {
+		t.Fatal("all pieces must be marked as complete")
+	}
+}
+
+type memFile []byte
+
+func (f memFile) ReadAt(b []byte, off int64) (int, error) {
+	return copy(b, f[off:]), nil
+}
+
+func (f memFile) WriteAt(b []byte, off int64) (int, error) {
+	return copy(f[off:], b), nil
+}
+
+type sha256Algorithm struct{}
+
+func (sha256Algorithm) Name() string   { return "sha256" }
+func (sha256Algorithm) New() hash.Hash { return sha256.New() }
+
+func TestHashAlgorithm(t *testing.T) {
+	data := []byte("data")
+	sum1 := sha1.Sum(data)
+	sum256 := sha256.Sum256(data)
+	pieces := []piece.Piece{{
+		Index:  0,
+		Length: 4,
+		Data:   filesection.Piece{{File: memFile(data), Length: 4}},
+		Hash:   sum1[:],
+	}, {
+		Index:         1,
+		Length:        4,
+		Data:          filesection.Piece{{File: memFile(data), Length: 4}},
+		Hash:          sum256[:],
+		HashAlgorithm: sha256Algorithm{},
+	}}
+	v := New(1)
+	resultC := make(chan *Verifier, 1)
+	v.Run(pieces, false, nil, semaphore.New(1), make(chan Progress, 2), resultC)
+	<-resultC
+	if v.Error != nil {
+		t.Fatal(v.Error)
+	}
+	if !v.Bitfield.All() {
+		t.Fatal("pieces must be verified with their own hash algorithm")
+	}
+}
+
+func TestResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rain-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sto, err := filestorage.New(dir, nil, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, _, err := sto.Open("file", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	files := []metainfo.File{{Length: 4, Path: "file"}}
+	stats, err := FileStats(sto, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bf := bitfield.New(1)
+	bf.Set(0)
+	resume := &Resume{Bitfield: bf, FileStats: stats, Storage: sto, Files: files}
+
+	// Files are not changed, so pieces are not read.
+	pieces := []piece.Piece{{
+		Length: 4,
+		Data:   filesection.Piece{{File: failingReader{t}, Length: 4}},
+		Hash:   make([]byte, 20),
+	}}
+	v := New(1)
+	resultC := make(chan *Verifier, 1)
+	v.Run(pieces, false, resume, semaphore.New(1), make(chan Progress, 1), resultC)
+	<-resultC
+	if !v.Resumed || !v.Bitfield.Test(0) {
+		t.Fatal("bitfield must be taken from resume data")
+	}
+
+	// File is modified, so pieces are verified again.
+	err = os.Chtimes(filepath.Join(dir, "file"), time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pieces[0].Data = filesection.Piece{{File: memFile("data"), Length: 4}}
+	v = New(1)
+	v.Run(pieces, false, resume, semaphore.New(1), make(chan Progress, 1), resultC)
+	<-resultC
+	if v.Resumed || v.Bitfield.Test(0) {
+		t.Fatal("pieces must be verified after file is changed")
+	}
+}
+
+func newMemPieces(count int, length uint32) []piece.Piece {
+	pieces := make([]piece.Piece, count)
+	for i := range pieces {
+		data := make([]byte, length)
+		data[0] = byte(i)
+		sum := sha1.Sum(data)
+		pieces[i] = piece.Piece{
+			Index:  uint32(i),
+			Length: length,
+			Data:   filesection.Piece{{File: memFile(data), Length: int64(length)}},
+			Hash:   sum[:],
+		}
+	}
+	return pieces
+}
+
+func TestConcurrency(t *testing.T) {
+	pieces := newMemPieces(100, 16)
+	// Corrupt some pieces.
+	for i := 0; i < len(pieces); i += 7 {
+		pieces[i].Hash = make([]byte, 20)
+	}
+	v := New(4)
+	progressC := make(chan Progress)
+	resultC := make(chan *Verifier, 1)
+	go v.Run(pieces, false, nil, semaphore.New(1), progressC, resultC)
+	var last uint32
+	for {
+		select {
+		case p := <-progressC:
+			if p.Checked <= last {
+				t.Fatalf("progress is not increasing: %d after %d", p.Checked, last)
+			}
+			last = p.Checked
+			continue
+		case <-resultC:
+		}
+		break
+	}
+	if v.Error != nil {
+		t.Fatal(v.Error)
+	}
+	if last != uint32(len(pieces)) {
+		t.Fatalf("last progress: %d", last)
+	}
+	for i := range pieces {
+		expected := i%7 != 0
+		if v.Bitfield.Test(uint32(i)) != expected {
+			t.Fatalf("piece #%d: expected %v", i, expected)
+		}
+	}
+}
+
+func benchmarkVerify(b *testing.B, concurrency int) {
+	pieces := newMemPieces(256, 256<<10)
+	b.SetBytes(int64(len(pieces)) * 256 << 10)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v := New(concurrency)
+		progressC := make(chan Progress, len(pieces))
+		resultC := make(chan *Verifier, 1)
+		v.Run(pieces, false, nil, semaphore.New(1), progressC, resultC)
+		<-resultC
+		if !v.Bitfield.All() {
+			b.Fatal("all pieces must be verified")
+		}
+	}
+}
+
+func BenchmarkVerify1(b *testing.B) { benchmarkVerify(b, 1) }
+func BenchmarkVerify4(b *testing.B) { benchmarkVerify(b, 4) }
+func BenchmarkVerify8(b *testing.B) { benchmarkVerify(b, 8) }
+
+// notCreatedFile behaves like a file that is skipped and not written yet.
+type notCreatedFile struct{}
+
+func (notCreatedFile) ReadAt(b []byte, off int64) (int, error)  { return 0, storage.ErrNotCreated }
+func (notCreatedFile) WriteAt(b []byte, off int64) (int, error) { return 0, storage.ErrNotCreated }
+
+func TestFileNotCreated(t *testing.T) {
+	data := []byte("data")
+	sum := sha1.Sum(data)
+	pieces := []piece.Piece{
+		{
+			Index:  0,
+			Length: 4,
+			Data:   filesection.Piece{{File: memFile(data), Length: 4}},
+			Hash:   sum[:],
+		},
+		{
+			Index:  1,
+			Length: 4,
+			Data:   filesection.Piece{{File: memFile(data), Length: 2}, {File: notCreatedFile{}, Length: 2}},
+			Hash:   sum[:],
+		},
+	}
+	v := New(1)
+	resultC := make(chan *Verifier, 1)
+	v.Run(pieces, false, nil, semaphore.New(1), make(chan Progress, len(pieces)), resultC)
+	<-resultC
+	if v.Error != nil {
+		t.Fatal(v.Error)
+	}
+	if !v.Bitfield.Test(0) || v.Bitfield.Test(1) {
+		t.Fatal("piece in file that is not created must be missing")
+	}
+}
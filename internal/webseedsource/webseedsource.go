@@ -9,11 +9,16 @@ import (
 
 // WebseedSource is a URL for downloading torrent data from web sources.
 type WebseedSource struct {
-	URL           string
-	Disabled      bool
-	Downloader    *urldownloader.URLDownloader
-	LastError     error
-	DisabledAt    time.Time
+	URL     string
+	Trusted bool
+	// HTTPSeed is true if the URL is listed in "httpseeds" key of the torrent (BEP 17).
+	HTTPSeed   bool
+	Disabled   bool
+	Downloader *urldownloader.URLDownloader
+	LastError  error
+	DisabledAt time.Time
+	// Number of consecutive errors. Reset after a piece is downloaded successfully.
+	Failures      int
 	DownloadSpeed metrics.Meter
 }
 
@@ -29,6 +34,15 @@ func NewList(sources []string) []*WebseedSource {
 	return l
 }
 
+// NewHTTPSeedList returns a new WebseedSource list for the HTTP seeds (BEP 17) of a torrent.
+func NewHTTPSeedList(sources []string) []*WebseedSource {
+	l := NewList(sources)
+	for _, src := range l {
+		src.HTTPSeed = true
+	}
+	return l
+}
+
 // Downloading returns true if data is being downloaded from this source.
 func (s *WebseedSource) Downloading() bool {
 	return s.Downloader != nil
@@ -24,10 +24,13 @@ import (
 	"github.com/cenkalti/rain/internal/logger"
 	"github.com/cenkalti/rain/internal/magnet"
 	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/rainprometheus"
 	"github.com/cenkalti/rain/rainrpc"
 	"github.com/cenkalti/rain/torrent"
 	"github.com/hokaccha/go-prettyjson"
 	"github.com/mitchellh/go-homedir"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/urfave/cli"
 	"github.com/zeebo/bencode"
 	"gopkg.in/yaml.v2"
@@ -112,6 +115,10 @@ func main() {
 					Usage: "read config from `FILE`",
 					Value: "~/rain/config.yaml",
 				},
+				cli.StringFlag{
+					Name:  "metrics",
+					Usage: "serve Prometheus metrics at `ADDR`",
+				},
 			},
 			Action: handleServer,
 		},
@@ -129,6 +136,10 @@ func main() {
 					Usage: "request timeout",
 					Value: 10 * time.Second,
 				},
+				cli.StringFlag{
+					Name:  "token",
+					Usage: "bearer token for RPC server",
+				},
 			},
 			Before: handleBeforeClient,
 			Subcommands: []cli.Command{
@@ -235,6 +246,58 @@ func main() {
 						},
 					},
 				},
+				{
+					Name:     "files",
+					Usage:    "get files of torrent with their priorities",
+					Category: "Getters",
+					Action:   handleFiles,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Required: true,
+						},
+					},
+				},
+				{
+					Name:     "swarm-stats",
+					Usage:    "get estimated number of seeders and leechers of torrent",
+					Category: "Getters",
+					Action:   handleSwarmStats,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Required: true,
+						},
+					},
+				},
+				{
+					Name:     "piece-progress",
+					Usage:    "get number of received blocks of a piece that is being downloaded",
+					Category: "Getters",
+					Action:   handlePieceProgress,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Required: true,
+						},
+						cli.UintFlag{
+							Name:     "index",
+							Required: true,
+						},
+					},
+				},
+				{
+					Name:     "metainfo",
+					Usage:    "get creation date, comment, created by and encoding of torrent",
+					Category: "Getters",
+					Action:   handleMetainfo,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Required: true,
+						},
+					},
+				},
 				{
 					Name:     "peers",
 					Usage:    "get peers of torrent",
@@ -281,6 +344,28 @@ func main() {
 						},
 					},
 				},
+				{
+					Name:     "set-file-priority",
+					Usage:    "set download priority of file in torrent",
+					Category: "Actions",
+					Action:   handleSetFilePriority,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Required: true,
+						},
+						cli.IntFlag{
+							Name:     "index",
+							Required: true,
+							Usage:    "index of file in the file list",
+						},
+						cli.IntFlag{
+							Name:     "priority",
+							Required: true,
+							Usage:    "-1 to skip, 0 for normal, 1 for high priority",
+						},
+					},
+				},
 				{
 					Name:     "announce",
 					Usage:    "announce to tracker",
@@ -329,6 +414,30 @@ func main() {
 						},
 					},
 				},
+				{
+					Name:     "pause",
+					Usage:    "pause torrent without disconnecting peers",
+					Category: "Actions",
+					Action:   handlePause,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Required: true,
+						},
+					},
+				},
+				{
+					Name:     "resume",
+					Usage:    "resume paused torrent",
+					Category: "Actions",
+					Action:   handleResume,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Required: true,
+						},
+					},
+				},
 				{
 					Name:     "start-all",
 					Usage:    "start all torrents",
@@ -631,6 +740,19 @@ func handleServer(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	metricsAddr := c.String("metrics")
+	if metricsAddr != "" {
+		reg := prometheus.NewRegistry()
+		err = rainprometheus.Register(reg, ses)
+		if err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+		go func() {
+			log.Notice(http.ListenAndServe(metricsAddr, mux))
+		}()
+	}
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
 	s := <-ch
@@ -650,7 +772,11 @@ func handleDownload(c *cli.Context) error {
 	cfg.DataDir = "."
 	cfg.DataDirIncludesTorrentID = false
 	var ih torrent.InfoHash
-	if isURI(arg) {
+	if magnet.IsInfoHash(arg) {
+		b, _ := hex.DecodeString(arg)
+		copy(ih[:], b)
+		cfg.Database = arg + ".resume"
+	} else if isURI(arg) {
 		magnet, err := magnet.New(arg)
 		if err != nil {
 			return err
@@ -735,6 +861,9 @@ func handleDownload(c *cli.Context) error {
 func handleBeforeClient(c *cli.Context) error {
 	clt = rainrpc.NewClient(c.String("url"))
 	clt.SetTimeout(c.Duration("timeout"))
+	if token := c.String("token"); token != "" {
+		clt.SetAuthToken(token)
+	}
 	return nil
 }
 
@@ -763,7 +892,7 @@ func handleList(c *cli.Context) error {
 }
 
 func isURI(arg string) bool {
-	return strings.HasPrefix(arg, "magnet:") || strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://")
+	return strings.HasPrefix(arg, "magnet:") || strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") || magnet.IsInfoHash(arg)
 }
 
 func handleAdd(c *cli.Context) error {
@@ -872,6 +1001,62 @@ func handleWebseeds(c *cli.Context) error {
 	return nil
 }
 
+func handleFiles(c *cli.Context) error {
+	resp, err := clt.GetTorrentFiles(c.String("id"))
+	if err != nil {
+		return err
+	}
+	b, err := prettyjson.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, _ = os.Stdout.Write(b)
+	_, _ = os.Stdout.WriteString("\n")
+	return nil
+}
+
+func handlePieceProgress(c *cli.Context) error {
+	resp, err := clt.GetTorrentPieceProgress(c.String("id"), uint32(c.Uint("index")))
+	if err != nil {
+		return err
+	}
+	b, err := prettyjson.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, _ = os.Stdout.Write(b)
+	_, _ = os.Stdout.WriteString("\n")
+	return nil
+}
+
+func handleSwarmStats(c *cli.Context) error {
+	resp, err := clt.GetTorrentSwarmStats(c.String("id"))
+	if err != nil {
+		return err
+	}
+	b, err := prettyjson.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, _ = os.Stdout.Write(b)
+	_, _ = os.Stdout.WriteString("\n")
+	return nil
+}
+
+func handleMetainfo(c *cli.Context) error {
+	resp, err := clt.GetTorrentMetainfo(c.String("id"))
+	if err != nil {
+		return err
+	}
+	b, err := prettyjson.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, _ = os.Stdout.Write(b)
+	_, _ = os.Stdout.WriteString("\n")
+	return nil
+}
+
 func handlePeers(c *cli.Context) error {
 	resp, err := clt.GetTorrentPeers(c.String("id"))
 	if err != nil {
@@ -894,6 +1079,10 @@ func handleAddTracker(c *cli.Context) error {
 	return clt.AddTracker(c.String("id"), c.String("tracker"))
 }
 
+func handleSetFilePriority(c *cli.Context) error {
+	return clt.SetFilePriority(c.String("id"), c.Int("index"), c.Int("priority"))
+}
+
 func handleAnnounce(c *cli.Context) error {
 	return clt.AnnounceTorrent(c.String("id"))
 }
@@ -910,6 +1099,14 @@ func handleStop(c *cli.Context) error {
 	return clt.StopTorrent(c.String("id"))
 }
 
+func handlePause(c *cli.Context) error {
+	return clt.PauseTorrent(c.String("id"))
+}
+
+func handleResume(c *cli.Context) error {
+	return clt.ResumeTorrent(c.String("id"))
+}
+
 func handleStartAll(c *cli.Context) error {
 	return clt.StartAllTorrents()
 }
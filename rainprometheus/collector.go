@@ -0,0 +1,142 @@
+// Package rainprometheus exports statistics of a Rain session as Prometheus metrics.
+package rainprometheus
+
+import (
+	"github.com/cenkalti/rain/torrent"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "rain"
+
+var torrentLabels = []string{"id", "name"}
+
+// Collector implements prometheus.Collector for a Session.
+// Values are read on each scrape through the Stats methods of Session and Torrent,
+// so they are consistent with the state of each torrent's event loop.
+type Collector struct {
+	session *torrent.Session
+
+	torrents          *prometheus.Desc
+	peers             *prometheus.Desc
+	speedDownload     *prometheus.Desc
+	speedUpload       *prometheus.Desc
+	blocklistRejected *prometheus.Desc
+	incomingRejected  *prometheus.Desc
+
+	torrentSpeedDownload *prometheus.Desc
+	torrentSpeedUpload   *prometheus.Desc
+	torrentDownloaded    *prometheus.Desc
+	torrentUploaded      *prometheus.Desc
+	torrentPeers         *prometheus.Desc
+	torrentPeersChoked   *prometheus.Desc
+	torrentPeersUnchoked *prometheus.Desc
+	torrentPiecesHave    *prometheus.Desc
+	torrentPiecesTotal   *prometheus.Desc
+	torrentAnnounces     *prometheus.Desc
+}
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+// NewCollector returns a new Collector that reads metrics from s.
+func NewCollector(s *torrent.Session) *Collector {
+	return &Collector{
+		session: s,
+
+		torrents:          prometheus.NewDesc(namespace+"_torrents", "Number of torrents in session.", nil, nil),
+		peers:             prometheus.NewDesc(namespace+"_peers", "Number of connected peers in all torrents.", nil, nil),
+		speedDownload:     prometheus.NewDesc(namespace+"_download_speed_bytes", "Download speed of all torrents in bytes/s.", nil, nil),
+		speedUpload:       prometheus.NewDesc(namespace+"_upload_speed_bytes", "Upload speed of all torrents in bytes/s.", nil, nil),
+		blocklistRejected: prometheus.NewDesc(namespace+"_blocklist_rejected_total", "Number of connections rejected because the peer IP is in blocklist.", nil, nil),
+		incomingRejected:  prometheus.NewDesc(namespace+"_incoming_rejected_total", "Number of incoming connections rejected because of connection limits.", nil, nil),
+
+		torrentSpeedDownload: prometheus.NewDesc(namespace+"_torrent_download_speed_bytes", "Download speed of torrent in bytes/s.", torrentLabels, nil),
+		torrentSpeedUpload:   prometheus.NewDesc(namespace+"_torrent_upload_speed_bytes", "Upload speed of torrent in bytes/s.", torrentLabels, nil),
+		torrentDownloaded:    prometheus.NewDesc(namespace+"_torrent_downloaded_bytes_total", "Number of bytes downloaded from swarm.", torrentLabels, nil),
+		torrentUploaded:      prometheus.NewDesc(namespace+"_torrent_uploaded_bytes_total", "Number of bytes uploaded to swarm.", torrentLabels, nil),
+		torrentPeers:         prometheus.NewDesc(namespace+"_torrent_peers", "Number of connected peers.", torrentLabels, nil),
+		torrentPeersChoked:   prometheus.NewDesc(namespace+"_torrent_peers_choked", "Number of peers that we are choking.", torrentLabels, nil),
+		torrentPeersUnchoked: prometheus.NewDesc(namespace+"_torrent_peers_unchoked", "Number of peers that we are uploading to.", torrentLabels, nil),
+		torrentPiecesHave:    prometheus.NewDesc(namespace+"_torrent_pieces_have", "Number of pieces downloaded and verified.", torrentLabels, nil),
+		torrentPiecesTotal:   prometheus.NewDesc(namespace+"_torrent_pieces_total", "Number of pieces in torrent.", torrentLabels, nil),
+		torrentAnnounces:     prometheus.NewDesc(namespace+"_torrent_announces_total", "Number of tracker announces by result.", append(torrentLabels, "tracker", "result"), nil),
+	}
+}
+
+// Register creates a new Collector for s and registers it to reg.
+func Register(reg prometheus.Registerer, s *torrent.Session) error {
+	return reg.Register(NewCollector(s))
+}
+
+// Describe implements prometheus.Collector interface.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.torrents
+	ch <- c.peers
+	ch <- c.speedDownload
+	ch <- c.speedUpload
+	ch <- c.blocklistRejected
+	ch <- c.incomingRejected
+	ch <- c.torrentSpeedDownload
+	ch <- c.torrentSpeedUpload
+	ch <- c.torrentDownloaded
+	ch <- c.torrentUploaded
+	ch <- c.torrentPeers
+	ch <- c.torrentPeersChoked
+	ch <- c.torrentPeersUnchoked
+	ch <- c.torrentPiecesHave
+	ch <- c.torrentPiecesTotal
+	ch <- c.torrentAnnounces
+}
+
+// Collect implements prometheus.Collector interface.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ss := c.session.Stats()
+	ch <- prometheus.MustNewConstMetric(c.torrents, prometheus.GaugeValue, float64(ss.Torrents))
+	ch <- prometheus.MustNewConstMetric(c.peers, prometheus.GaugeValue, float64(ss.Peers))
+	ch <- prometheus.MustNewConstMetric(c.speedDownload, prometheus.GaugeValue, float64(ss.SpeedDownload))
+	ch <- prometheus.MustNewConstMetric(c.speedUpload, prometheus.GaugeValue, float64(ss.SpeedUpload))
+	ch <- prometheus.MustNewConstMetric(c.blocklistRejected, prometheus.CounterValue, float64(ss.BlockListRejected))
+	ch <- prometheus.MustNewConstMetric(c.incomingRejected, prometheus.CounterValue, float64(ss.IncomingRejected))
+
+	for _, t := range c.session.ListTorrents() {
+		c.collectTorrent(ch, t)
+	}
+}
+
+func (c *Collector) collectTorrent(ch chan<- prometheus.Metric, t *torrent.Torrent) {
+	s := t.Stats()
+	labels := []string{t.ID(), s.Name}
+	gauge := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+	}
+	counter := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value, labels...)
+	}
+	gauge(c.torrentSpeedDownload, float64(s.Speed.Download))
+	gauge(c.torrentSpeedUpload, float64(s.Speed.Upload))
+	counter(c.torrentDownloaded, float64(s.Bytes.Downloaded))
+	counter(c.torrentUploaded, float64(s.Bytes.Uploaded))
+	gauge(c.torrentPeers, float64(s.Peers.Total))
+	gauge(c.torrentPeersChoked, float64(s.Peers.Choked))
+	gauge(c.torrentPeersUnchoked, float64(s.Peers.Unchoked))
+	gauge(c.torrentPiecesHave, float64(s.Pieces.Have))
+	gauge(c.torrentPiecesTotal, float64(s.Pieces.Total))
+
+	// Same URL may be added more than once. Counts are summed because a series must be unique in a scrape.
+	var urls []string
+	announces := make(map[string]*torrent.Tracker)
+	for _, tr := range t.Trackers() {
+		if sum, ok := announces[tr.URL]; ok {
+			sum.Successes += tr.Successes
+			sum.Failures += tr.Failures
+			continue
+		}
+		tr := tr
+		announces[tr.URL] = &tr
+		urls = append(urls, tr.URL)
+	}
+	for _, u := range urls {
+		tr := announces[u]
+		ch <- prometheus.MustNewConstMetric(c.torrentAnnounces, prometheus.CounterValue, float64(tr.Successes), t.ID(), s.Name, u, "success")
+		ch <- prometheus.MustNewConstMetric(c.torrentAnnounces, prometheus.CounterValue, float64(tr.Failures), t.ID(), s.Name, u, "failure")
+	}
+}
@@ -0,0 +1,76 @@
+package rainprometheus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/torrent"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectorDuplicateTrackers(t *testing.T) {
+	tr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("d8:intervali3600e5:peers0:e"))
+	}))
+	defer tr.Close()
+	trackerURL := tr.URL + "/announce"
+
+	where, err := ioutil.TempDir("", "rain-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(where)
+	cfg := torrent.DefaultConfig
+	cfg.Database = filepath.Join(where, "session.db")
+	cfg.DataDir = where
+	cfg.DHTEnabled = false
+	cfg.PEXEnabled = false
+	cfg.RPCEnabled = false
+	s, err := torrent.NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	tor, err := s.AddURI("magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567&dn=test", &torrent.AddTorrentOptions{ID: "test", Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err = tor.AddTracker(trackerURL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = tor.Start(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for successes := 0; successes < 2; {
+		if time.Now().After(deadline) {
+			t.Fatal("trackers are not announced")
+		}
+		time.Sleep(10 * time.Millisecond)
+		successes = 0
+		for _, st := range tor.Trackers() {
+			successes += st.Successes
+		}
+	}
+
+	expected := fmt.Sprintf(`
+# HELP rain_torrent_announces_total Number of tracker announces by result.
+# TYPE rain_torrent_announces_total counter
+rain_torrent_announces_total{id="test",name="test",result="failure",tracker="%[1]s"} 0
+rain_torrent_announces_total{id="test",name="test",result="success",tracker="%[1]s"} 2
+`, trackerURL)
+	err = testutil.CollectAndCompare(NewCollector(s), strings.NewReader(expected), "rain_torrent_announces_total")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
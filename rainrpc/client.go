@@ -35,6 +35,23 @@ func (c *Client) SetTimeout(d time.Duration) {
 	c.httpClient.Timeout = d
 }
 
+// SetAuthToken sets the bearer token that is sent in Authorization header of requests.
+// Must be set if Config.RPCAuthToken is set in remote Session.
+func (c *Client) SetAuthToken(token string) {
+	c.httpClient.Transport = &authTransport{token: token, base: http.DefaultTransport}
+}
+
+type authTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(req)
+}
+
 // Addr returns the address of remote Session.
 func (c *Client) Addr() string {
 	return c.addr
@@ -99,6 +116,16 @@ func (c *Client) RemoveTorrent(id string) error {
 	return c.client.Call("Session.RemoveTorrent", args, &reply)
 }
 
+// RemoveTorrentWait removes a torrent from remote Session and waits until the stopped event is announced
+// and its data is deleted. Zero timeout means no limit.
+// Returns true if the stopped event is announced to all trackers of the torrent.
+func (c *Client) RemoveTorrentWait(id string, timeout time.Duration) (bool, error) {
+	args := rpctypes.RemoveTorrentWaitRequest{ID: id, Timeout: int(timeout / time.Second)}
+	var reply rpctypes.RemoveTorrentWaitResponse
+	err := c.client.Call("Session.RemoveTorrentWait", args, &reply)
+	return reply.StoppedEventAnnounced, err
+}
+
 // CleanDatabase removes invalid records in session database.
 func (c *Client) CleanDatabase() error {
 	var args rpctypes.CleanDatabaseRequest
@@ -128,6 +155,13 @@ func (c *Client) GetMagnet(id string) (string, error) {
 	return reply.Magnet, err
 }
 
+// GetTorrentMetainfo returns the optional fields of the .torrent file.
+func (c *Client) GetTorrentMetainfo(id string) (*rpctypes.Metainfo, error) {
+	args := rpctypes.GetTorrentMetainfoRequest{ID: id}
+	var reply rpctypes.GetTorrentMetainfoResponse
+	return &reply.Metainfo, c.client.Call("Session.GetTorrentMetainfo", args, &reply)
+}
+
 // GetTorrent returns the bytes of a .torrent file.
 func (c *Client) GetTorrent(id string) ([]byte, error) {
 	args := rpctypes.GetTorrentRequest{ID: id}
@@ -153,6 +187,20 @@ func (c *Client) GetTorrentPeers(id string) ([]rpctypes.Peer, error) {
 	return reply.Peers, c.client.Call("Session.GetTorrentPeers", args, &reply)
 }
 
+// GetTorrentSwarmStats returns the estimated number of seeders and leechers of a torrent.
+func (c *Client) GetTorrentSwarmStats(id string) (*rpctypes.SwarmStats, error) {
+	args := rpctypes.GetTorrentSwarmStatsRequest{ID: id}
+	var reply rpctypes.GetTorrentSwarmStatsResponse
+	return &reply.SwarmStats, c.client.Call("Session.GetTorrentSwarmStats", args, &reply)
+}
+
+// GetTorrentPieceProgress returns the number of received blocks of a piece that is being downloaded.
+func (c *Client) GetTorrentPieceProgress(id string, index uint32) (*rpctypes.PieceProgress, error) {
+	args := rpctypes.GetTorrentPieceProgressRequest{ID: id, Index: index}
+	var reply rpctypes.GetTorrentPieceProgressResponse
+	return &reply.PieceProgress, c.client.Call("Session.GetTorrentPieceProgress", args, &reply)
+}
+
 // GetTorrentWebseeds returns the WebSeed sources of a torrent.
 func (c *Client) GetTorrentWebseeds(id string) ([]rpctypes.Webseed, error) {
 	args := rpctypes.GetTorrentWebseedsRequest{ID: id}
@@ -160,6 +208,20 @@ func (c *Client) GetTorrentWebseeds(id string) ([]rpctypes.Webseed, error) {
 	return reply.Webseeds, c.client.Call("Session.GetTorrentWebseeds", args, &reply)
 }
 
+// GetTorrentFiles returns the files of a torrent with their priorities.
+func (c *Client) GetTorrentFiles(id string) ([]rpctypes.File, error) {
+	args := rpctypes.GetTorrentFilesRequest{ID: id}
+	var reply rpctypes.GetTorrentFilesResponse
+	return reply.Files, c.client.Call("Session.GetTorrentFiles", args, &reply)
+}
+
+// SetFilePriority sets the download priority of the file at index in the file list of the torrent.
+func (c *Client) SetFilePriority(id string, index int, priority int) error {
+	args := rpctypes.SetFilePriorityRequest{ID: id, Index: index, Priority: priority}
+	var reply rpctypes.SetFilePriorityResponse
+	return c.client.Call("Session.SetFilePriority", args, &reply)
+}
+
 // StartTorrent starts the torrent.
 func (c *Client) StartTorrent(id string) error {
 	args := rpctypes.StartTorrentRequest{ID: id}
@@ -174,6 +236,20 @@ func (c *Client) StopTorrent(id string) error {
 	return c.client.Call("Session.StopTorrent", args, &reply)
 }
 
+// PauseTorrent stops downloading and uploading pieces while keeping the peers connected.
+func (c *Client) PauseTorrent(id string) error {
+	args := rpctypes.PauseTorrentRequest{ID: id}
+	var reply rpctypes.PauseTorrentResponse
+	return c.client.Call("Session.PauseTorrent", args, &reply)
+}
+
+// ResumeTorrent continues downloading and uploading of a paused torrent.
+func (c *Client) ResumeTorrent(id string) error {
+	args := rpctypes.ResumeTorrentRequest{ID: id}
+	var reply rpctypes.ResumeTorrentResponse
+	return c.client.Call("Session.ResumeTorrent", args, &reply)
+}
+
 // AnnounceTorrent forces the torrent to re-announce to trackers and DHT.
 func (c *Client) AnnounceTorrent(id string) error {
 	args := rpctypes.AnnounceTorrentRequest{ID: id}
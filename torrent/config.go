@@ -1,6 +1,7 @@
 package torrent
 
 import (
+	"crypto/tls"
 	"time"
 
 	"github.com/cenkalti/rain/internal/metainfo"
@@ -20,26 +21,62 @@ func init() {
 type Config struct {
 	// Database file to save resume data.
 	Database string
+	// If set, resume data of torrents is saved to the store instead of Database.
+	// Torrents in the store are restored when the session is opened. Database is still used for session data, e.g. the blocklist.
+	ResumeStore ResumeStore `yaml:"-"`
 	// DataDir is where files are downloaded.
 	DataDir string
 	// If true, torrent files are saved into <data_dir>/<torrent_id>/<torrent_name>.
 	// Useful if downloading the same torrent from multiple sources.
 	DataDirIncludesTorrentID bool
+	// If set, called to create the storage of each torrent instead of saving files under DataDir.
+	// The same storage must be returned for the same torrent ID after the session is restarted,
+	// otherwise torrents are verified and downloaded again.
+	StorageProvider func(torrentID string) (Storage, error) `yaml:"-"`
 	// New torrents will be listened at selected port in this range.
 	PortBegin, PortEnd uint16
 	// At start, client will set max open files limit to this number. (like "ulimit -n" command)
 	MaxOpenFiles uint64
+	// Max number of torrent data files kept open at the same time, shared by all torrents in the session.
+	// Least recently used files are closed when the limit is reached and opened again when needed.
+	// Set to zero to keep all files open.
+	MaxOpenDataFiles int
+	// Memory-map data files instead of reading and writing them with system calls.
+	// Mapped files are not counted in MaxOpenDataFiles. Files that cannot be mapped are accessed with regular I/O.
+	// Only supported on Linux, because disk space must be reserved before mapping a file. Files larger than 1 GiB are not mapped.
+	// Reading or writing a mapped file that is truncated by another process returns an error.
+	UseMmap bool
+	// Reserve disk space for the full size of files when a torrent is started.
+	// By default, files are created as sparse files and disk space is used as pieces are written.
+	// Preallocating prevents fragmentation and running out of space in the middle of a download.
+	// Falls back to sparse files if not supported by the OS or the file system.
+	// Files that are skipped with FilePrioritySkip are not created in either case.
+	PreallocateFiles bool
 	// Enable peer exchange protocol.
 	PEXEnabled bool
+	// Enable holepunch extension (BEP 55) for connecting to peers behind NAT.
+	// When we cannot connect to an address received with PEX, the peer that sent the address is asked to
+	// make both sides connect to each other at the same time. Requires PEX to be enabled.
+	HolepunchEnabled bool
 	// Resume data (bitfield & stats) are saved to disk at interval to keep IO lower.
 	ResumeWriteInterval time.Duration
+	// If set, peer id of all torrents is prefixed with this string, including private torrents.
+	// Must be 8 bytes in Azureus-style format, e.g. "-RN0001-". See BEP 20.
+	// Remaining 12 bytes of peer id are randomized once for the session and shared by all torrents.
+	// If not set, each torrent has its own random peer id.
+	PeerIDPrefix string
+	// If set, client version of all torrents is this string, including private torrents.
+	// It is sent in BEP 10 handshake message and as the user agent to HTTP trackers.
+	ClientVersion string
 	// Peer id is prefixed with this string. See BEP 20. Remaining bytes of peer id will be randomized.
-	// Only applies to private torrents.
+	// Only applies to private torrents. Ignored if PeerIDPrefix is set. Cannot be longer than 20 bytes.
 	PrivatePeerIDPrefix string
 	// Client version that is sent in BEP 10 handshake message.
-	// Only applies to private torrents.
+	// Only applies to private torrents. Ignored if ClientVersion is set.
 	PrivateExtensionHandshakeClientVersion string
-	// URL to the blocklist file in CIDR format.
+	// URL to the blocklist file. Can be a local file path or a file:// URL.
+	// Rules may be in CIDR, PeerGuardian (.p2p) or eMule (ipfilter.dat) format.
+	// eMule rules with access level above 127 allow the range, so they are not blocked.
 	BlocklistURL string
 	// When to refresh blocklist
 	BlocklistUpdateInterval time.Duration
@@ -63,12 +100,41 @@ type Config struct {
 	MaxPieces uint32
 	// Time to wait when resolving host names for trackers and peers.
 	DNSResolveTimeout time.Duration
-	// Global download speed limit in KB/s.
+	// Duration to keep the resolved addresses of peers that are given as host names.
+	PeerHostCacheTTL time.Duration
+	// Global download speed limit in bytes/s. Applies to peers and webseeds. 0 means unlimited.
+	// Can be changed while the session is running with Session.SetSpeedLimitDownload.
 	SpeedLimitDownload int64
-	// Global upload speed limit in KB/s.
+	// Global upload speed limit in bytes/s. 0 means unlimited.
+	// Can be changed while the session is running with Session.SetSpeedLimitUpload.
 	SpeedLimitUpload int64
+	// Upload speed limit for a single peer in bytes/s, so a peer cannot use all of the upload bandwidth. 0 means unlimited.
+	SpeedLimitUploadPerPeer int64
 	// Start torrent automatically if it was running when previous session was closed.
 	ResumeOnStartup bool
+	// Recoverable errors (file allocation, verification and write errors) are retried during this period
+	// before the torrent is stopped with an error. Set to 0 to stop the torrent at first error.
+	// The torrent is in Retrying status while waiting to be restarted.
+	// Errors that cannot be fixed by retrying (e.g. invalid file names) stop the torrent immediately.
+	ErrorGracePeriod time.Duration
+	// Time to wait before restarting the torrent after a recoverable error.
+	ErrorRetryInterval time.Duration
+	// Interval for checking whether the external IP address of the client has changed.
+	// The address is detected from network interfaces and "yourip" field reported by peers.
+	// On change, all torrents are re-announced to trackers and DHT. Set to 0 to disable.
+	// The detected address is sent to trackers in announce requests if it is a public address.
+	ExternalIPCheckInterval time.Duration
+	// Minimum number of peers that must report the same "yourip" before it is accepted as the external IP.
+	// Each peer IP has one vote and the weight of votes is halved at every ExternalIPCheckInterval.
+	ExternalIPMinVotes int
+	// Forward the listen ports of torrents on the router with UPnP, or NAT-PMP if UPnP is not available.
+	// The external IP address reported by the router is sent to trackers in announce requests.
+	// Errors are logged and do not prevent torrents from starting.
+	PortForwarding bool
+	// Duration of the port mappings requested from the router. Mappings are refreshed at half of this duration.
+	PortForwardingLease time.Duration
+	// Time to wait for responses from the router.
+	PortForwardingTimeout time.Duration
 
 	// Enable RPC server
 	RPCEnabled bool
@@ -78,6 +144,8 @@ type Config struct {
 	RPCPort int
 	// Time to wait for ongoing requests before shutting down RPC HTTP server.
 	RPCShutdownTimeout time.Duration
+	// If not empty, RPC clients must send this token in "Authorization: Bearer <token>" header.
+	RPCAuthToken string
 
 	// Enable DHT node.
 	DHTEnabled bool
@@ -92,56 +160,218 @@ type Config struct {
 	// Known routers to bootstrap local DHT node.
 	DHTBootstrapNodes []string
 
+	// Enable Local Service Discovery (BEP 14) for finding peers on the local network with UDP multicast.
+	// Private torrents are not announced. Disabled by default.
+	LSDEnabled bool
+	// Interval of announcing torrents to the local network.
+	LSDAnnounceInterval time.Duration
+	// Minimum announce interval when more peers are needed.
+	LSDMinAnnounceInterval time.Duration
+
+	// Address of the SOCKS5 port of a Tor client (e.g. "127.0.0.1:9050").
+	// When set, outgoing peer connections, HTTP tracker requests and webseed requests are made through Tor,
+	// and .onion peer and tracker addresses can be used. Host names are resolved by Tor, not locally.
+	// Because Tor carries only TCP, DHT and LSD are disabled and UDP trackers are skipped (HTTP trackers in the same tier are used instead).
+	// Limitations: incoming peer connections are still accepted on the listen port without Tor,
+	// and the listen port is sent to trackers and peers. Do not rely on this setting alone for strong anonymity.
+	TorProxy string
+	// Address of a SOCKS5 proxy (e.g. "127.0.0.1:1080") for outgoing peer connections.
+	// Encryption handshake is done after the connection through the proxy is established.
+	// Incoming peer connections are not affected. PeerSourceAddresses is not used when set. Ignored if TorProxy is set.
+	PeerProxy string
+	// Address of a SOCKS5 proxy for tracker requests. Host names of HTTP trackers are resolved by the proxy,
+	// unless a blocklist is loaded and BlocklistEnabledForTrackers is set. Then, they are resolved locally to check the blocklist.
+	// UDP trackers are contacted with UDP ASSOCIATE command. Their host names are resolved locally.
+	// If the proxy does not support UDP, announces to UDP trackers fail with an error and HTTP trackers in the same tier are used instead.
+	// Ignored if TorProxy is set.
+	TrackerProxy string
+
 	// Number of peer addresses to request in announce request.
 	TrackerNumWant int
-	// Time to wait for announcing stopped event.
+	// Announce to all tiers in the announce list of a torrent at the same time.
+	// By default, tiers are tried in order and the next tier is used only if all trackers in the previous tiers fail (BEP 12).
+	TrackerAnnounceToAllTiers bool
+	// Time to wait for announcing stopped event to each tracker.
 	// Stopped event is sent to the tracker when torrent is stopped.
 	TrackerStopTimeout time.Duration
+	// Max time the torrent stays in "Stopping" state.
+	// After this duration, the torrent is stopped even if some trackers have not responded to the stopped event.
+	// Trackers are announced in parallel, so it has no effect unless it is shorter than TrackerStopTimeout.
+	// Zero value means no limit; the torrent waits until every tracker returns or times out.
+	StoppedEventTimeout time.Duration
+	// Number of times to retry announcing stopped event if the tracker returns an error.
+	// Retries are bounded by TrackerStopTimeout.
+	TrackerStopRetries int
+	// Wait for the stopped event to be announced before removing the torrent from the session or closing the session.
+	// Some private trackers do not accept a started event for a torrent that has not sent a stopped event before.
+	// If false, stopped event is cancelled when the torrent is removed.
+	TrackerWaitStopped bool
 	// When the client needs new peer addresses to connect, it ask to the tracker.
 	// To prevent spamming the tracker an interval is set to wait before the next announce.
 	TrackerMinAnnounceInterval time.Duration
+	// Fraction of the announce interval to randomize the next announce time in both directions.
+	// For example, 0.1 means the next announce happens within ±10% of the interval returned from the tracker.
+	// Spreads announces of many torrents over time. Next announce is never earlier than the min interval.
+	TrackerAnnounceJitter float64
+	// Announce earlier than the interval returned from the tracker when there are no connected peers while downloading.
+	// Announce to trackers, DHT and LSD is done after TrackerAnnounceOnNoPeersDelay if no peer connects in the meantime.
+	// TrackerMinAnnounceInterval and DHTMinAnnounceInterval are still respected.
+	TrackerAnnounceOnNoPeers bool
+	// Time to wait after the last peer has disconnected before announcing.
+	TrackerAnnounceOnNoPeersDelay time.Duration
+	// When a tracker returns fewer peers than this fraction of TrackerNumWant and the torrent still needs peers,
+	// other trackers, DHT and LSD are announced without waiting their intervals.
+	// TrackerMinAnnounceInterval and DHTMinAnnounceInterval are still respected. Set to zero to disable.
+	TrackerStarvationRatio float64
+	// When torrents are resumed on startup, the first announce of each torrent is delayed
+	// by a random duration up to this value, so many torrents do not announce at the same time.
+	// Set to zero to announce immediately.
+	StartupAnnounceStagger time.Duration
 	// Total time to wait for response to be read.
 	// This includes ConnectTimeout and TLSHandshakeTimeout.
 	TrackerHTTPTimeout time.Duration
 	// User agent sent when communicating with HTTP trackers.
-	// Only applies to private torrents.
+	// Only applies to private torrents. Ignored if ClientVersion is set.
 	TrackerHTTPPrivateUserAgent string
 	// Max number of bytes in a tracker response.
 	TrackerHTTPMaxResponseSize uint
 	// Check and validate TLS ceritificates.
 	TrackerHTTPVerifyTLS bool
+	// TLS config used when connecting to HTTPS trackers, e.g. for setting custom root CAs.
+	// If set, TrackerHTTPVerifyTLS is ignored and InsecureSkipVerify field of the config is used instead.
+	TrackerHTTPTLSConfig *tls.Config `yaml:"-"`
+	// If set, called with the result of each announce to trackers, DHT and LSD.
+	// Calls are made from a single goroutine in the order of announces.
+	// Results are dropped if the function cannot keep up, so announces are never blocked by it.
+	OnAnnounce func(AnnounceResult) `yaml:"-"`
 
 	// Number of unchoked peers.
 	UnchokedPeers int
 	// Number of optimistic unchoked peers.
 	OptimisticUnchokedPeers int
-	// Max number of blocks allowed to be queued without dropping any.
+	// While downloading, peers that do not upload back to us are choked preferentially.
+	// A peer is considered as a leech if the ratio of bytes downloaded from the peer to bytes uploaded to the peer
+	// is below AntiLeechMinRatio after uploading AntiLeechMinUpload bytes to it. 0 disables it, which is the default.
+	AntiLeechMinRatio float64
+	// Number of bytes to upload to a peer before checking its reciprocation ratio.
+	AntiLeechMinUpload int64
+	// While downloading, regular unchoke slots are given only to peers that are currently uploading to us.
+	// Other peers, including the ones that have just connected, can only be unchoked optimistically.
+	PreferReciprocatingPeers bool
+	// Max number of blocks requested by a peer that are queued for uploading.
+	// Excess requests are rejected if the peer supports fast extension, otherwise they are dropped.
+	// The peer is disconnected after sending this many more requests while its queue is full.
 	MaxRequestsIn int
 	// Max number of blocks requested from a peer but not received yet.
 	// `rreq` value from extended handshake cannot exceed this limit.
 	MaxRequestsOut int
 	// Number of bloks requested from peer if it does not send `rreq` value in extended handshake.
 	DefaultRequestsOut int
+	// Size the outgoing request queue of each peer to hold AdaptiveRequestsQueueTime worth of blocks at its download rate.
+	// The queue is kept between AdaptiveRequestsOutMin and AdaptiveRequestsOutMax, and cannot exceed `rreq` and MaxRequestsOut.
+	// DefaultRequestsOut is used until the download rate of the peer is measured.
+	AdaptiveRequestsOut bool
+	// Duration of data to keep requested from a peer when AdaptiveRequestsOut is enabled.
+	// It must be longer than the round-trip time to the peer, otherwise the link is idle while waiting for the next blocks.
+	AdaptiveRequestsQueueTime time.Duration
+	// Lower bound for the request queue of a peer when AdaptiveRequestsOut is enabled.
+	AdaptiveRequestsOutMin int
+	// Upper bound for the request queue of a peer when AdaptiveRequestsOut is enabled.
+	AdaptiveRequestsOutMax int
 	// Time to wait for a requested block to be received before marking peer as snubbed
 	RequestTimeout time.Duration
 	// Max number of running downloads on piece in endgame mode, snubbed and choed peers don't count
 	EndgameMaxDuplicateDownloads int
+	// Endgame mode is activated when the number of missing pieces is not more than this value.
+	// Endgame mode is always activated when all missing pieces are requested, even if this value is 0.
+	// In endgame mode, the same piece is requested from multiple peers and
+	// the requests for a block are cancelled on other peers as soon as the block is received.
+	EndgameThreshold int
+	// Time to wait after the first peer is connected before picking pieces to download.
+	// Waiting lets the torrent collect Have messages from more peers so rarest-first selection works better.
+	// Set to zero to start downloading immediately.
+	PickerWarmupDelay time.Duration
+	// Number of missing pieces, starting from the first one, that are downloaded in order when sequential download is enabled
+	// with Torrent.SetSequential. Pieces after the window are downloaded rarest first. Set to zero to download all pieces in order.
+	SequentialWindow int
+	// Priority of files in new torrents. Applied when the file list becomes known,
+	// either when a torrent file is added or when the metadata of a magnet link is downloaded.
+	// Set to FilePrioritySkip to download nothing until files are selected.
+	DefaultFilePriority FilePriority
+	// Seeding is stopped when the ratio of uploaded bytes to downloaded bytes reaches this value.
+	// Downloaded bytes include the bytes from previous runs of the torrent. Set to zero to disable.
+	SeedRatioLimit float64
+	// Seeding is stopped when the torrent is seeded for this duration in total. Set to zero to disable.
+	SeedTimeLimit time.Duration
 	// Max number of outgoing connections to dial
 	MaxPeerDial int
+	// While downloading, if fewer peers than this are connected, trackers and DHT are asked for new peers
+	// at their min intervals and the dial target is raised to this value until the minimum is met.
+	// MaxPeerDial is never exceeded. Set to zero to disable.
+	MinConnectedPeers int
 	// Max number of incoming connections to accept
 	MaxPeerAccept int
+	// Max number of connections of a torrent, including the ones in handshake state. Set to zero to disable.
+	MaxPeerConnectionsPerTorrent int
+	// Max number of connections in all torrents, including the ones in handshake state. Set to zero to disable.
+	// When the limit is reached, torrents having less than their fair share of connections can still connect to new peers.
+	// In that case, a connection of the torrent having the most peers is dropped.
+	MaxPeerConnectionsGlobal int
+	// Max number of incoming connections that are in handshake state at the same time.
+	// New connections are rejected when the limit is reached.
+	MaxPendingIncomingHandshakes int
+	// Adjust the number of outgoing connections of each torrent by looking at the swarm health.
+	// When enabled, MaxPeerDial is used as the initial target and
+	// the target is kept between AdaptivePeerLimitMin and AdaptivePeerLimitMax.
+	AdaptivePeerLimit bool
+	// Lower bound for outgoing connections when AdaptivePeerLimit is enabled.
+	AdaptivePeerLimitMin int
+	// Upper bound for outgoing connections when AdaptivePeerLimit is enabled.
+	AdaptivePeerLimitMax int
 	// Running metadata downloads, snubbed peers don't count
 	ParallelMetadataDownloads int
+	// Magnet links are stopped with an error if the metadata cannot be downloaded from peers in this duration.
+	// The timer restarts each time the torrent is started. Set to zero to wait forever.
+	MetadataTimeout time.Duration
+	// Listen on IPv6 for incoming peer connections, on the same port with IPv4,
+	// and connect to IPv6 peers found from trackers, DHT and other sources.
+	// If false, IPv6 peers are still connected if the host has a public IPv6 address.
+	ListenIPv6 bool
+	// Local IP addresses to dial peers from. On a host with multiple network interfaces,
+	// the address on the interface that can route to the peer is chosen. If it is unclear, the default route is used.
+	// Incoming connections are accepted on all addresses and replied from the address they are received on.
+	// If empty, source address is selected by the OS.
+	PeerSourceAddresses []string
 	// Time to wait for TCP connection to open.
 	PeerConnectTimeout time.Duration
-	// Time to wait for BitTorrent handshake to complete.
+	// Time to wait for BitTorrent handshake to complete, including the encryption negotiation.
 	PeerHandshakeTimeout time.Duration
+	// Number of recent connection attempts of a torrent returned from Torrent.RecentConnections. Set to zero to disable.
+	MaxRecentConnections int
 	// When peer has started to send piece block, if it does not send any bytes in PieceReadTimeout, the connection is closed.
 	PieceReadTimeout time.Duration
 	// Max number of peer addresses to keep in connect queue.
 	MaxPeerAddresses int
+	// Number of corrupt pieces a peer may send before it is disconnected and banned for the lifetime of the torrent.
+	// Pieces with blocks received from multiple peers are not counted. Set to 0 to ban on the first corrupt piece.
+	MaxBadPiecesBeforeBan int
+	// Addresses received again from any source within this duration after being dialed are not added to the connect queue.
+	// Prevents connecting to the same peer more than once when it is found by multiple trackers, DHT and PEX.
+	PeerAddressDedupDuration time.Duration
+	// Number of addresses of peers that we have downloaded from to save in resume data.
+	// Saved peers are dialed immediately when the torrent is started again. Set to 0 to disable.
+	MaxResumePeers int
+	// Saved peer is removed from resume data after this many consecutive failed connections.
+	// A connection that downloads from the peer resets the count.
+	MaxResumePeerFailures int
 	// Number of allowed-fast messages to send after handshake.
 	AllowedFastSet int
+	// Log every message sent to and received from peers at debug level.
+	// Useful for debugging protocol issues. Do not enable in production.
+	WireTrace bool
+	// Log messages of peers only with these addresses. Addresses may be in "ip" or "ip:port" format.
+	// Has no effect if WireTrace is enabled.
+	WireTracePeers []string
 
 	// Number of bytes to read when a piece is requested by a peer.
 	ReadCacheBlockSize int64
@@ -153,12 +383,32 @@ type Config struct {
 	ParallelReads uint
 	// Number of write operations to do in parallel.
 	ParallelWrites uint
+	// Max number of torrents verifying their files at the same time.
+	// Other torrents wait in queue in Verifying status until one of the running verifications is finished.
+	// Must be greater than zero.
+	MaxConcurrentVerifications int
+	// Number of pieces hashed in parallel while a torrent is verifying its files.
+	// Higher values speed up verification on fast disks with many CPU cores.
+	// Keep it low on spinning disks, reading many pieces at once causes seeks.
+	VerifierConcurrency int
 	// Number of bytes allocated in memory for downloading piece data.
 	WriteCacheSize int64
+	// Number of bytes of hash checked pieces kept in memory to be written to disk together.
+	// Buffered pieces are written in order of their indexes, so files are written sequentially
+	// instead of seeking for every piece. Useful for spinning disks.
+	// A piece is not counted as downloaded until it is written. Buffer is written fully when the torrent is stopped.
+	// Zero value disables buffering and each piece is written as soon as it is downloaded.
+	WriteBufferSize int64
+	// Buffered pieces are written after this duration even if WriteBufferSize is not reached.
+	WriteBufferFlushInterval time.Duration
 
+	// Encryption policy of incoming and outgoing peer connections.
+	// Fields below are used only if it is EncryptionPreferred.
+	Encryption EncryptionMode
 	// When the client want to connect a peer, first it tries to do encrypted handshake.
 	// If it does not work, it connects to same peer again and does unencrypted handshake.
 	// This behavior can be changed via this variable.
+	// Peers that are flagged to prefer encryption in PEX messages are still dialed with encrypted handshake first.
 	DisableOutgoingEncryption bool
 	// Dial only encrypted connections.
 	ForceOutgoingEncryption bool
@@ -173,14 +423,30 @@ type Config struct {
 	WebseedResponseHeaderTimeout time.Duration
 	// HTTP body read timeout for Webseed sources
 	WebseedResponseBodyReadTimeout time.Duration
-	// Retry interval for restarting failed downloads
+	// Retry interval for restarting failed downloads.
+	// Interval is doubled after each consecutive error of the same source, up to WebseedMaxRetryInterval.
 	WebseedRetryInterval time.Duration
+	// Maximum retry interval for restarting failed downloads.
+	WebseedMaxRetryInterval time.Duration
+	// WebSeed source is not retried anymore after this many consecutive errors. 0 means no limit.
+	WebseedMaxRetries int
 	// Verify TLS certificate for WebSeed URLs
 	WebseedVerifyTLS bool
 	// Limit the number of WebSeed sources in torrent.
 	WebseedMaxSources int
 	// Number of maximum simulateous downloads from WebSeed sources.
 	WebseedMaxDownloads int
+	// URL prefixes of WebSeed sources that are trusted.
+	// Scheme, host and port must be the same and the path of the source must be under the path of the prefix.
+	WebseedTrustedSources []string
+	// Skip SHA-1 verification of pieces downloaded from trusted WebSeed sources.
+	// Only HTTPS sources are trusted and only when WebseedVerifyTLS is enabled.
+	// Pieces downloaded from peers and untrusted sources are always verified.
+	WebseedSkipVerifyTrusted bool
+
+	// UNSAFE: Do not check SHA-1 hashes of pieces. Existing files are assumed to be complete and
+	// downloaded pieces are written to disk without checking. Only for development and testing with known-good data.
+	DisableVerification bool
 
 	// Shell command to execute on torrent completion.
 	OnCompleteCmd []string
@@ -195,7 +461,11 @@ var DefaultConfig = Config{
 	PortBegin:                              50000,
 	PortEnd:                                60000,
 	MaxOpenFiles:                           10240,
+	MaxOpenDataFiles:                       4096,
+	UseMmap:                                false,
+	PreallocateFiles:                       false,
 	PEXEnabled:                             true,
+	HolepunchEnabled:                       false,
 	ResumeWriteInterval:                    30 * time.Second,
 	PrivatePeerIDPrefix:                    "-RN" + Version + "-",
 	PrivateExtensionHandshakeClientVersion: "Rain " + Version,
@@ -210,7 +480,15 @@ var DefaultConfig = Config{
 	MaxTorrentSize:                         10 << 20,
 	MaxPieces:                              64 << 10,
 	DNSResolveTimeout:                      5 * time.Second,
+	PeerHostCacheTTL:                       time.Minute,
 	ResumeOnStartup:                        true,
+	ErrorGracePeriod:                       time.Minute,
+	ErrorRetryInterval:                     10 * time.Second,
+	ExternalIPCheckInterval:                5 * time.Minute,
+	ExternalIPMinVotes:                     3,
+	PortForwarding:                         false,
+	PortForwardingLease:                    time.Hour,
+	PortForwardingTimeout:                  3 * time.Second,
 
 	// RPC Server
 	RPCEnabled:         true,
@@ -219,13 +497,22 @@ var DefaultConfig = Config{
 	RPCShutdownTimeout: 5 * time.Second,
 
 	// Tracker
-	TrackerNumWant:              200,
-	TrackerStopTimeout:          5 * time.Second,
-	TrackerMinAnnounceInterval:  time.Minute,
-	TrackerHTTPTimeout:          10 * time.Second,
-	TrackerHTTPPrivateUserAgent: "Rain/" + Version,
-	TrackerHTTPMaxResponseSize:  2 << 20,
-	TrackerHTTPVerifyTLS:        true,
+	TrackerNumWant:                200,
+	TrackerAnnounceToAllTiers:     false,
+	TrackerStopTimeout:            5 * time.Second,
+	TrackerStopRetries:            2,
+	StoppedEventTimeout:           3 * time.Second,
+	TrackerWaitStopped:            true,
+	TrackerMinAnnounceInterval:    time.Minute,
+	TrackerAnnounceJitter:         0.1,
+	TrackerAnnounceOnNoPeers:      true,
+	TrackerAnnounceOnNoPeersDelay: 10 * time.Second,
+	TrackerStarvationRatio:        0.1,
+	StartupAnnounceStagger:        0,
+	TrackerHTTPTimeout:            10 * time.Second,
+	TrackerHTTPPrivateUserAgent:   "Rain/" + Version,
+	TrackerHTTPMaxResponseSize:    2 << 20,
+	TrackerHTTPVerifyTLS:          true,
 
 	// DHT node
 	DHTEnabled:             true,
@@ -241,30 +528,64 @@ var DefaultConfig = Config{
 		"dht.aelitis.com:6881",
 	},
 
+	// Local Service Discovery
+	LSDEnabled:             false,
+	LSDAnnounceInterval:    5 * time.Minute,
+	LSDMinAnnounceInterval: time.Minute,
+
 	// Peer
 	UnchokedPeers:                3,
 	OptimisticUnchokedPeers:      1,
+	AntiLeechMinRatio:            0,
+	AntiLeechMinUpload:           16 << 20,
+	PreferReciprocatingPeers:     false,
 	MaxRequestsIn:                250,
 	MaxRequestsOut:               250,
 	DefaultRequestsOut:           50,
+	AdaptiveRequestsOutMin:       4,
+	AdaptiveRequestsOutMax:       250,
+	AdaptiveRequestsQueueTime:    3 * time.Second,
 	RequestTimeout:               20 * time.Second,
 	EndgameMaxDuplicateDownloads: 20,
+	EndgameThreshold:             0,
+	PickerWarmupDelay:            0,
+	SequentialWindow:             20,
+	DefaultFilePriority:          FilePriorityNormal,
+	SeedRatioLimit:               0,
+	SeedTimeLimit:                0,
 	MaxPeerDial:                  80,
+	MinConnectedPeers:            0,
+	ListenIPv6:                   false,
 	MaxPeerAccept:                20,
+	MaxPeerConnectionsPerTorrent: 0,
+	MaxPeerConnectionsGlobal:     0,
+	MaxPendingIncomingHandshakes: 10,
+	AdaptivePeerLimitMin:         20,
+	AdaptivePeerLimitMax:         200,
 	ParallelMetadataDownloads:    2,
+	MetadataTimeout:              0,
 	PeerConnectTimeout:           5 * time.Second,
 	PeerHandshakeTimeout:         10 * time.Second,
+	MaxRecentConnections:         50,
 	PieceReadTimeout:             30 * time.Second,
 	MaxPeerAddresses:             2000,
+	MaxBadPiecesBeforeBan:        1,
+	PeerAddressDedupDuration:     time.Minute,
 	AllowedFastSet:               10,
+	MaxResumePeers:               20,
+	MaxResumePeerFailures:        3,
 
 	// IO
-	ReadCacheBlockSize: 128 << 10,
-	ReadCacheSize:      256 << 20,
-	ReadCacheTTL:       1 * time.Minute,
-	ParallelReads:      1,
-	ParallelWrites:     1,
-	WriteCacheSize:     1 << 30,
+	ReadCacheBlockSize:         128 << 10,
+	ReadCacheSize:              256 << 20,
+	ReadCacheTTL:               1 * time.Minute,
+	ParallelReads:              1,
+	ParallelWrites:             1,
+	MaxConcurrentVerifications: 2,
+	VerifierConcurrency:        1,
+	WriteCacheSize:             1 << 30,
+	WriteBufferSize:            0,
+	WriteBufferFlushInterval:   5 * time.Second,
 
 	// Webseed settings
 	WebseedDialTimeout:             10 * time.Second,
@@ -272,6 +593,7 @@ var DefaultConfig = Config{
 	WebseedResponseHeaderTimeout:   10 * time.Second,
 	WebseedResponseBodyReadTimeout: 10 * time.Second,
 	WebseedRetryInterval:           time.Minute,
+	WebseedMaxRetryInterval:        30 * time.Minute,
 	WebseedVerifyTLS:               true,
 	WebseedMaxSources:              10,
 	WebseedMaxDownloads:            4,
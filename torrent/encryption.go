@@ -0,0 +1,44 @@
+package torrent
+
+// EncryptionMode is the policy for Message Stream Encryption (MSE) of peer connections.
+// It is applied to both incoming and outgoing connections.
+type EncryptionMode int
+
+const (
+	// EncryptionPreferred tries encrypted handshake first for outgoing connections
+	// and falls back to plaintext if the peer does not support encryption.
+	// Both encrypted and plaintext incoming connections are accepted.
+	// In this mode, Config.DisableOutgoingEncryption, Config.ForceOutgoingEncryption and Config.ForceIncomingEncryption are respected.
+	// This is the default.
+	EncryptionPreferred EncryptionMode = iota
+	// EncryptionDisabled dials plaintext connections only and rejects encrypted incoming handshakes.
+	EncryptionDisabled
+	// EncryptionRequired dials encrypted connections only and rejects plaintext incoming handshakes.
+	// RC4 is used for the data stream, header-only encryption is not accepted.
+	EncryptionRequired
+)
+
+func (m EncryptionMode) String() string {
+	switch m {
+	case EncryptionPreferred:
+		return "preferred"
+	case EncryptionDisabled:
+		return "disabled"
+	case EncryptionRequired:
+		return "required"
+	default:
+		return "unknown"
+	}
+}
+
+// encryptionPolicy returns how the handshakes of peer connections are done according to Config.Encryption.
+func (c *Config) encryptionPolicy() (enableOutgoing, forceOutgoing, enableIncoming, forceIncoming bool) {
+	switch c.Encryption {
+	case EncryptionDisabled:
+		return false, false, false, false
+	case EncryptionRequired:
+		return true, true, true, true
+	default:
+		return !c.DisableOutgoingEncryption, c.ForceOutgoingEncryption, true, c.ForceIncomingEncryption
+	}
+}
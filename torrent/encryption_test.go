@@ -0,0 +1,46 @@
+package torrent
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/cenkalti/rain/internal/mse"
+	"github.com/fortytw2/leaktest"
+)
+
+func TestEncryptionRequired(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	s.config.Encryption = EncryptionRequired
+
+	tor := addTorrentFile(t, s, nil)
+	var port int
+	select {
+	case port = <-tor.torrent.NotifyListen():
+	case <-time.After(timeout):
+		t.Fatal("torrent is not listening")
+	}
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	var peerID [20]byte
+	copy(peerID[:], "-XX0000-000000000000")
+
+	// Plaintext handshake is refused.
+	conn, _, _, _, err := btconn.Dial(addr, nil, time.Second, time.Second, false, false, [8]byte{}, tor.torrent.infoHash, peerID, make(chan struct{}))
+	if err == nil {
+		conn.Close()
+		t.Fatal("plaintext connection must be refused")
+	}
+
+	// Encrypted handshake is accepted.
+	conn, cipher, _, _, err := btconn.Dial(addr, nil, time.Second, time.Second, true, true, [8]byte{}, tor.torrent.infoHash, peerID, make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if cipher != mse.RC4 {
+		t.Fatalf("unexpected cipher: %s", cipher)
+	}
+}
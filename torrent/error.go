@@ -1,9 +1,42 @@
 package torrent
 
 import (
+	"errors"
+	"os"
+	"syscall"
+
 	"github.com/cenkalti/rain/internal/announcer"
+	"github.com/cenkalti/rain/internal/storage/filestorage"
 )
 
+// ErrStorageReadOnly is the error that the torrent is paused with when downloaded data cannot be written
+// because the files or the download directory is read-only.
+// Call Resume after fixing the permissions. Pieces that could not be written are downloaded again.
+var ErrStorageReadOnly = errors.New("storage is read-only")
+
+// isReadOnlyError returns true if the error is caused by a read-only file system or missing write permission.
+func isReadOnlyError(err error) bool {
+	return errors.Is(err, syscall.EROFS) || errors.Is(err, os.ErrPermission)
+}
+
+// ErrDiskFull is the error that the torrent is paused with when downloaded data cannot be written
+// because there is no space left on the disk or the disk quota is exceeded.
+// Call Resume after freeing space. Pieces that could not be written are downloaded again.
+var ErrDiskFull = errors.New("no space left on disk")
+
+// isDiskFullError returns true if the error is caused by running out of disk space.
+// Writing to a memory-mapped file faults instead of returning ENOSPC if the file system cannot allocate the page,
+// for example on copy-on-write file systems where reserved space is not guaranteed.
+func isDiskFullError(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) || isDiskFullErrno(err) || errors.Is(err, filestorage.ErrMmapFault)
+}
+
+// isPermanentError returns true if the error cannot be fixed by retrying the operation later,
+// so the torrent must be stopped without waiting for Config.ErrorGracePeriod.
+func isPermanentError(err error) bool {
+	return isReadOnlyError(err) || errors.Is(err, syscall.ENAMETOOLONG) || errors.Is(err, syscall.EISDIR) || errors.Is(err, syscall.ENOTDIR)
+}
+
 // InputError is returned from Session.AddTorrent and Session.AddURI methods when there is problem with the input.
 type InputError struct {
 	err error
@@ -0,0 +1,9 @@
+// +build !windows
+
+package torrent
+
+// isDiskFullErrno returns true for the Windows error codes that are returned instead of ENOSPC.
+// Other platforms return ENOSPC, so it always returns false.
+func isDiskFullErrno(err error) bool {
+	return false
+}
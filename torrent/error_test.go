@@ -0,0 +1,26 @@
+package torrent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/storage/filestorage"
+)
+
+func TestIsDiskFullError(t *testing.T) {
+	for _, err := range []error{
+		&os.PathError{Op: "write", Path: "foo", Err: syscall.ENOSPC},
+		fmt.Errorf("cannot write piece: %w", syscall.EDQUOT),
+		fmt.Errorf("cannot write piece: %w", filestorage.ErrMmapFault),
+	} {
+		if !isDiskFullError(err) {
+			t.Errorf("not detected as disk full error: %v", err)
+		}
+	}
+	if isDiskFullError(errors.New("foo")) {
+		t.Error("unrelated error is detected as disk full error")
+	}
+}
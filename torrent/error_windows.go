@@ -0,0 +1,14 @@
+// +build windows
+
+package torrent
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// isDiskFullErrno returns true for the Windows error codes that are returned instead of ENOSPC.
+func isDiskFullErrno(err error) bool {
+	return errors.Is(err, windows.ERROR_DISK_FULL) || errors.Is(err, windows.ERROR_HANDLE_DISK_FULL)
+}
@@ -11,19 +11,28 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/blocklist"
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/cenkalti/rain/internal/externalip"
 	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/lsd"
+	"github.com/cenkalti/rain/internal/onion"
 	"github.com/cenkalti/rain/internal/piececache"
+	"github.com/cenkalti/rain/internal/portmap"
 	"github.com/cenkalti/rain/internal/resolver"
 	"github.com/cenkalti/rain/internal/resourcemanager"
 	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
+	"github.com/cenkalti/rain/internal/resumer/storeresumer"
 	"github.com/cenkalti/rain/internal/semaphore"
+	"github.com/cenkalti/rain/internal/socks5"
+	"github.com/cenkalti/rain/internal/speedlimit"
+	"github.com/cenkalti/rain/internal/storage/filestorage"
 	"github.com/cenkalti/rain/internal/tracker"
 	"github.com/cenkalti/rain/internal/trackermanager"
-	"github.com/juju/ratelimit"
 	"github.com/mitchellh/go-homedir"
 	"github.com/nictuku/dht"
 	"go.etcd.io/bbolt"
@@ -41,29 +50,49 @@ var (
 type Session struct {
 	config         Config
 	db             *bbolt.DB
-	resumer        *boltdbresumer.Resumer
+	resumer        sessionResumer
 	log            logger.Logger
 	extensions     [8]byte
+	peerIDSuffix   [20]byte
 	dht            *dht.DHT
 	rpc            *rpcServer
 	trackerManager *trackermanager.TrackerManager
 	ram            *resourcemanager.ResourceManager
 	pieceCache     *piececache.Cache
 	webseedClient  http.Client
+	peerHostCache  *resolver.HostCache
 	createdAt      time.Time
 	semWrite       *semaphore.Semaphore
+	semVerify      *semaphore.Semaphore
+	torProxy       *socks5.Dialer
+	peerDialer     btconn.Dialer
+	onions         *onion.Map
+	portMapper     *portmap.Mapper
+	lsd            *lsd.LSD
+	fileCache      *filestorage.FileCache
 	metrics        *sessionMetrics
-	bucketDownload *ratelimit.Bucket
-	bucketUpload   *ratelimit.Bucket
+	limitDownload  *speedlimit.Limiter
+	limitUpload    *speedlimit.Limiter
 	closeC         chan struct{}
 
+	// Results of announces waiting to be passed to Config.OnAnnounce.
+	announceResultC chan AnnounceResult
+
 	mPeerRequests   sync.Mutex
 	dhtPeerRequests map[*torrent]struct{}
+	lsdPeerRequests map[*torrent]struct{}
+
+	// Counts the removed torrents that are being closed in background.
+	removeWG sync.WaitGroup
 
 	mTorrents          sync.RWMutex
 	torrents           map[string]*Torrent
 	torrentsByInfoHash map[dht.InfoHash][]*Torrent
 	invalidTorrentIDs  []string
+	// Length of torrents map. Can be read without holding mTorrents.
+	numTorrents int32
+	// Number of connections in handshake state in all torrents. Counted in Config.MaxPeerConnectionsGlobal.
+	numHandshakers int32
 
 	mPorts         sync.RWMutex
 	availablePorts map[int]struct{}
@@ -71,6 +100,14 @@ type Session struct {
 	mBlocklist         sync.RWMutex
 	blocklist          *blocklist.Blocklist
 	blocklistTimestamp time.Time
+
+	mExternalIP       sync.Mutex
+	externalIP        net.IP
+	externalIPVotes   map[string]externalIPVote
+	externalIPChangeC chan net.IP
+
+	// False if DHT is disabled in config or it cannot be used because TorProxy is set.
+	dhtEnabled bool
 }
 
 // NewSession creates a new Session for downloading and seeding torrents.
@@ -79,6 +116,23 @@ func NewSession(cfg Config) (*Session, error) {
 	if cfg.PortBegin >= cfg.PortEnd {
 		return nil, errors.New("invalid port range")
 	}
+	if cfg.PeerIDPrefix != "" {
+		if err := validatePeerIDPrefix(cfg.PeerIDPrefix); err != nil {
+			return nil, err
+		}
+	}
+	if strings.ContainsAny(cfg.ClientVersion, "\r\n") {
+		return nil, errors.New("client version cannot contain line breaks")
+	}
+	if len(cfg.PrivatePeerIDPrefix) > 20 {
+		return nil, errors.New("private peer id prefix cannot be longer than 20 bytes")
+	}
+	if cfg.MaxConcurrentVerifications <= 0 {
+		return nil, errors.New("max concurrent verifications must be greater than zero")
+	}
+	if cfg.MaxRecentConnections < 0 {
+		return nil, errors.New("max recent connections cannot be negative")
+	}
 	if cfg.MaxOpenFiles > 0 {
 		err := setNoFile(cfg.MaxOpenFiles)
 		if err != nil {
@@ -99,6 +153,9 @@ func NewSession(cfg Config) (*Session, error) {
 		return nil, err
 	}
 	l := logger.New("session")
+	if cfg.DisableVerification {
+		l.Warning("PIECE VERIFICATION IS DISABLED! Corrupt data will not be detected. Do not use this setting in production.")
+	}
 	db, err := bbolt.Open(cfg.Database, 0640, &bbolt.Options{Timeout: time.Second})
 	if err == bbolt.ErrTimeout {
 		return nil, errors.New("resume database is locked by another process")
@@ -110,30 +167,50 @@ func NewSession(cfg Config) (*Session, error) {
 			db.Close()
 		}
 	}()
-	var ids []string
 	err = db.Update(func(tx *bbolt.Tx) error {
 		_, err2 := tx.CreateBucketIfNotExists(sessionBucket)
-		if err2 != nil {
-			return err2
-		}
-		b, err2 := tx.CreateBucketIfNotExists(torrentsBucket)
-		if err2 != nil {
-			return err2
-		}
-		return b.ForEach(func(k, _ []byte) error {
-			ids = append(ids, string(k))
-			return nil
-		})
+		return err2
 	})
 	if err != nil {
 		return nil, err
 	}
-	res, err := boltdbresumer.New(db, torrentsBucket)
+	var res sessionResumer
+	if cfg.ResumeStore != nil {
+		res = storeresumer.New(cfg.ResumeStore)
+	} else {
+		res, err = boltdbresumer.New(db, torrentsBucket)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ids, err := res.List()
 	if err != nil {
 		return nil, err
 	}
+	var sourceDialer *sourceAddrDialer
+	if len(cfg.PeerSourceAddresses) > 0 {
+		sourceDialer, err = newSourceAddrDialer(cfg.PeerSourceAddresses)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var torProxy *socks5.Dialer
+	if cfg.TorProxy != "" {
+		torProxy = socks5.New(cfg.TorProxy)
+	}
+	// DHT and LSD use UDP which cannot be sent over Tor.
+	dhtEnabled := cfg.DHTEnabled && torProxy == nil
+	lsdEnabled := cfg.LSDEnabled && torProxy == nil
+	var trackerProxy *socks5.Dialer
+	if cfg.TrackerProxy != "" {
+		trackerProxy = socks5.New(cfg.TrackerProxy)
+	}
+	trackerTLSConfig := &tls.Config{InsecureSkipVerify: !cfg.TrackerHTTPVerifyTLS} // nolint: gosec
+	if cfg.TrackerHTTPTLSConfig != nil {
+		trackerTLSConfig = cfg.TrackerHTTPTLSConfig.Clone()
+	}
 	var dhtNode *dht.DHT
-	if cfg.DHTEnabled {
+	if dhtEnabled {
 		dhtConfig := dht.NewConfig()
 		dhtConfig.Address = cfg.DHTHost
 		dhtConfig.Port = int(cfg.DHTPort)
@@ -164,17 +241,27 @@ func NewSession(cfg Config) (*Session, error) {
 		db:                 db,
 		resumer:            res,
 		blocklist:          bl,
-		trackerManager:     trackermanager.New(blTracker, cfg.DNSResolveTimeout, !cfg.TrackerHTTPVerifyTLS),
+		trackerManager:     trackermanager.New(blTracker, cfg.DNSResolveTimeout, trackerTLSConfig, torProxy, trackerProxy),
 		log:                l,
 		torrents:           make(map[string]*Torrent),
 		torrentsByInfoHash: make(map[dht.InfoHash][]*Torrent),
 		availablePorts:     ports,
 		dht:                dhtNode,
+		dhtEnabled:         dhtEnabled,
 		pieceCache:         piececache.New(cfg.ReadCacheSize, cfg.ReadCacheTTL, cfg.ParallelReads),
 		ram:                resourcemanager.New(cfg.WriteCacheSize),
 		createdAt:          time.Now(),
 		semWrite:           semaphore.New(int(cfg.ParallelWrites)),
+		semVerify:          semaphore.New(cfg.MaxConcurrentVerifications),
+		limitDownload:      speedlimit.New(cfg.SpeedLimitDownload, nil),
+		limitUpload:        speedlimit.New(cfg.SpeedLimitUpload, nil),
+		torProxy:           torProxy,
+		onions:             onion.NewMap(),
 		closeC:             make(chan struct{}),
+		peerHostCache:      resolver.NewHostCache(cfg.PeerHostCacheTTL),
+		externalIP:         externalip.FirstExternalIP(),
+		externalIPVotes:    make(map[string]externalIPVote),
+		externalIPChangeC:  make(chan net.IP, 1),
 		webseedClient: http.Client{
 			Transport: &http.Transport{
 				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
@@ -194,11 +281,22 @@ func NewSession(cfg Config) (*Session, error) {
 			},
 		},
 	}
-	if cfg.SpeedLimitDownload > 0 {
-		c.bucketDownload = ratelimit.NewBucketWithRate(float64(cfg.SpeedLimitDownload), cfg.SpeedLimitDownload)
+	if sourceDialer != nil {
+		c.peerDialer = sourceDialer
+	}
+	if cfg.PeerProxy != "" {
+		c.peerDialer = socks5.New(cfg.PeerProxy)
 	}
-	if cfg.SpeedLimitUpload > 0 {
-		c.bucketUpload = ratelimit.NewBucketWithRate(float64(cfg.SpeedLimitUpload), cfg.SpeedLimitUpload)
+	if torProxy != nil {
+		c.peerDialer = &torPeerDialer{proxy: torProxy, onions: c.onions}
+		c.webseedClient.Transport.(*http.Transport).DialContext = torProxy.DialContext
+	}
+	if cfg.MaxOpenDataFiles > 0 {
+		c.fileCache = filestorage.NewFileCache(cfg.MaxOpenDataFiles)
+	}
+	c.peerIDSuffix, err = newPeerIDSuffix()
+	if err != nil {
+		return nil, err
 	}
 	err = c.startBlocklistReloader()
 	if err != nil {
@@ -210,11 +308,30 @@ func NewSession(cfg Config) (*Session, error) {
 	}
 	ext.Set(61) // Fast Extension (BEP 6)
 	ext.Set(43) // Extension Protocol (BEP 10)
-	if cfg.DHTEnabled {
+	if dhtEnabled {
 		ext.Set(63) // DHT Protocol (BEP 5)
 		c.dhtPeerRequests = make(map[*torrent]struct{})
 	}
 	c.initMetrics()
+	if cfg.PortForwarding {
+		c.portMapper = portmap.New(cfg.PortForwardingLease, cfg.PortForwardingTimeout, logger.New("portmap"))
+		go c.portMapper.Run()
+	}
+	if lsdEnabled {
+		c.lsd, err = lsd.New(logger.New("lsd"))
+		if err != nil {
+			// Multicast may not be available on the network. Session can work without finding local peers.
+			l.Warningln("cannot start local service discovery:", err)
+		} else {
+			c.lsdPeerRequests = make(map[*torrent]struct{})
+			go c.lsd.Run()
+			go c.processLSD()
+		}
+	}
+	if cfg.OnAnnounce != nil {
+		c.announceResultC = make(chan AnnounceResult, announceResultQueueSize)
+		go c.processAnnounceResults()
+	}
 	c.loadExistingTorrents(ids)
 	if c.config.RPCEnabled {
 		c.rpc = newRPCServer(c)
@@ -223,15 +340,19 @@ func NewSession(cfg Config) (*Session, error) {
 			return nil, err
 		}
 	}
-	if cfg.DHTEnabled {
+	if dhtEnabled {
 		go c.processDHTResults()
 	}
 	go c.updateStatsLoop()
+	if cfg.ExternalIPCheckInterval > 0 {
+		go c.checkExternalIPLoop()
+	}
 	return c, nil
 }
 
 func (s *Session) parseTrackers(tiers [][]string, private bool) []tracker.Tracker {
 	ret := make([]tracker.Tracker, 0, len(tiers))
+	var announceList []*tracker.Tier
 	for _, tier := range tiers {
 		trackers := make([]tracker.Tracker, 0, len(tier))
 		for _, tr := range tier {
@@ -244,26 +365,73 @@ func (s *Session) parseTrackers(tiers [][]string, private bool) []tracker.Tracke
 		if len(trackers) > 0 {
 			tra := tracker.NewTier(trackers)
 			ret = append(ret, tra)
+			announceList = append(announceList, tra)
 		}
 	}
+	if !s.config.TrackerAnnounceToAllTiers && len(announceList) > 1 {
+		return []tracker.Tracker{tracker.NewAnnounceList(announceList)}
+	}
 	return ret
 }
 
 func (s *Session) getTrackerUserAgent(private bool) string {
+	if s.config.ClientVersion != "" {
+		return s.config.ClientVersion
+	}
 	if private {
 		return s.config.TrackerHTTPPrivateUserAgent
 	}
 	return trackerHTTPPublicUserAgent
 }
 
+// findTorrentByInfoHash returns a torrent in the session with the info hash.
+// Returns nil if there is no such torrent, e.g. it has been removed.
+func (s *Session) findTorrentByInfoHash(infoHash [20]byte) *torrent {
+	s.mTorrents.RLock()
+	defer s.mTorrents.RUnlock()
+	a := s.torrentsByInfoHash[dht.InfoHash(infoHash[:])]
+	if len(a) == 0 {
+		return nil
+	}
+	return a[0].torrent
+}
+
+// getSKey returns the info hash of the torrent matching the hash of SKey in MSE handshake.
+func (s *Session) getSKey(sKeyHash [20]byte) []byte {
+	s.mTorrents.RLock()
+	defer s.mTorrents.RUnlock()
+	for _, t := range s.torrents {
+		if t.torrent.sKeyHash == sKeyHash {
+			return t.torrent.InfoHash()
+		}
+	}
+	return nil
+}
+
+func (s *Session) isWireTraceEnabled(addr *net.TCPAddr) bool {
+	if s.config.WireTrace {
+		return true
+	}
+	for _, a := range s.config.WireTracePeers {
+		if a == addr.String() || a == addr.IP.String() {
+			return true
+		}
+	}
+	return false
+}
+
 // Close stops all torrents and release the resources.
 func (s *Session) Close() error {
 	close(s.closeC)
 
-	if s.config.DHTEnabled {
+	if s.dht != nil {
 		s.dht.Stop()
 	}
 
+	if s.lsd != nil {
+		s.lsd.Close()
+	}
+
 	s.updateStats()
 
 	var wg sync.WaitGroup
@@ -279,6 +447,13 @@ func (s *Session) Close() error {
 	s.torrents = nil
 	s.mTorrents.Unlock()
 
+	// Wait for removed torrents to announce stopped event and delete their files.
+	s.removeWG.Wait()
+
+	if s.portMapper != nil {
+		s.portMapper.Close()
+	}
+
 	if s.rpc != nil {
 		err := s.rpc.Stop(s.config.RPCShutdownTimeout)
 		if err != nil {
@@ -328,14 +503,48 @@ func (s *Session) GetTorrent(id string) *Torrent {
 }
 
 // RemoveTorrent removes the torrent from the session and delete its files.
+// The torrent is closed and its files are deleted in background.
+// If Config.TrackerWaitStopped is set, files are deleted after the stopped event is announced.
 func (s *Session) RemoveTorrent(id string) error {
-	t, err := s.removeTorrentFromClient(id)
-	if t != nil {
-		go func() { _ = s.stopAndRemoveData(t) }()
-	}
+	_, _, err := s.removeTorrent(id)
 	return err
 }
 
+// RemoveTorrentContext removes the torrent from the session like RemoveTorrent,
+// then waits until the stopped event is announced and files are deleted, or ctx is done.
+// Removal continues in background if ctx is done before.
+// Returns true if the stopped event is announced to all trackers of the torrent.
+// Result is always false if Config.TrackerWaitStopped is not set.
+func (s *Session) RemoveTorrentContext(ctx context.Context, id string) (bool, error) {
+	t, doneC, err := s.removeTorrent(id)
+	if t == nil || err != nil {
+		return false, err
+	}
+	select {
+	case <-doneC:
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+	return s.config.TrackerWaitStopped && !t.torrent.stoppedEventFailed, nil
+}
+
+// removeTorrent closes the removed torrent and deletes its files in a new goroutine.
+// Returned channel is closed when the goroutine is done. Session.Close waits for these goroutines before returning.
+func (s *Session) removeTorrent(id string) (*Torrent, chan struct{}, error) {
+	t, err := s.removeTorrentFromClient(id)
+	if t == nil {
+		return nil, nil, err
+	}
+	doneC := make(chan struct{})
+	s.removeWG.Add(1)
+	go func() {
+		defer s.removeWG.Done()
+		defer close(doneC)
+		_ = s.stopAndRemoveData(t)
+	}()
+	return t, doneC, err
+}
+
 func (s *Session) removeTorrentFromClient(id string) (*Torrent, error) {
 	s.mTorrents.Lock()
 	defer s.mTorrents.Unlock()
@@ -345,6 +554,7 @@ func (s *Session) removeTorrentFromClient(id string) (*Torrent, error) {
 	}
 	t.torrent.log.Info("removing torrent")
 	delete(s.torrents, id)
+	atomic.AddInt32(&s.numTorrents, -1)
 
 	// Delete from the list of torrents with same info hash
 	ih := dht.InfoHash(t.torrent.InfoHash())
@@ -357,20 +567,27 @@ func (s *Session) removeTorrentFromClient(id string) (*Torrent, error) {
 		}
 	}
 
-	if s.config.DHTEnabled && len(s.torrentsByInfoHash[ih]) == 0 {
+	if s.dhtEnabled && len(s.torrentsByInfoHash[ih]) == 0 {
 		s.dht.RemoveInfoHash(string(ih))
 	}
-	return t, s.db.Update(func(tx *bbolt.Tx) error {
-		return tx.Bucket(torrentsBucket).DeleteBucket([]byte(id))
-	})
+	return t, s.resumer.Delete(id)
 }
 
 func (s *Session) stopAndRemoveData(t *Torrent) error {
 	t.torrent.Close()
+	return s.removeData(t)
+}
+
+func (s *Session) removeData(t *Torrent) error {
 	s.releasePort(t.torrent.port)
 	var err error
 	var dest string
-	if s.config.DataDirIncludesTorrentID {
+	if t.torrent.location != "" {
+		// Directory is chosen by the user and may contain other files.
+		if t.torrent.info != nil {
+			dest = filepath.Join(t.torrent.location, t.torrent.info.Name)
+		}
+	} else if s.config.DataDirIncludesTorrentID {
 		dest = filepath.Join(s.config.DataDir, t.torrent.id)
 	} else if t.torrent.info != nil {
 		dest = t.torrent.info.Name
@@ -386,18 +603,13 @@ func (s *Session) stopAndRemoveData(t *Torrent) error {
 
 // StartAll starts all torrents in session.
 func (s *Session) StartAll() error {
-	err := s.db.Update(func(tx *bbolt.Tx) error {
-		tb := tx.Bucket(torrentsBucket)
-		s.mTorrents.RLock()
-		for _, t := range s.torrents {
-			b := tb.Bucket([]byte(t.torrent.id))
-			_ = b.Put([]byte("started"), []byte("true"))
+	s.mTorrents.RLock()
+	defer s.mTorrents.RUnlock()
+	for _, t := range s.torrents {
+		err := s.resumer.WriteStarted(t.torrent.id, true)
+		if err != nil {
+			return err
 		}
-		defer s.mTorrents.RUnlock()
-		return nil
-	})
-	if err != nil {
-		return err
 	}
 	for _, t := range s.torrents {
 		t.torrent.Start()
@@ -407,21 +619,26 @@ func (s *Session) StartAll() error {
 
 // StopAll stops all torrents in session.
 func (s *Session) StopAll() error {
-	err := s.db.Update(func(tx *bbolt.Tx) error {
-		tb := tx.Bucket(torrentsBucket)
-		s.mTorrents.RLock()
-		for _, t := range s.torrents {
-			b := tb.Bucket([]byte(t.torrent.id))
-			_ = b.Put([]byte("started"), []byte("false"))
+	s.mTorrents.RLock()
+	defer s.mTorrents.RUnlock()
+	for _, t := range s.torrents {
+		err := s.resumer.WriteStarted(t.torrent.id, false)
+		if err != nil {
+			return err
 		}
-		defer s.mTorrents.RUnlock()
-		return nil
-	})
-	if err != nil {
-		return err
 	}
 	for _, t := range s.torrents {
 		t.torrent.Stop()
 	}
 	return nil
 }
+
+// SetSpeedLimitDownload changes the global download speed limit to rate bytes per second. 0 means unlimited.
+func (s *Session) SetSpeedLimitDownload(rate int64) {
+	s.limitDownload.SetRate(rate)
+}
+
+// SetSpeedLimitUpload changes the global upload speed limit to rate bytes per second. 0 means unlimited.
+func (s *Session) SetSpeedLimitUpload(rate int64) {
+	s.limitUpload.SetRate(rate)
+}
@@ -2,20 +2,22 @@ package torrent
 
 import (
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/magnet"
 	"github.com/cenkalti/rain/internal/metainfo"
 	"github.com/cenkalti/rain/internal/resumer"
 	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
-	"github.com/cenkalti/rain/internal/storage/filestorage"
+	"github.com/cenkalti/rain/internal/storage"
 	"github.com/cenkalti/rain/internal/webseedsource"
 	"github.com/gofrs/uuid"
 	"github.com/nictuku/dht"
@@ -30,6 +32,14 @@ type AddTorrentOptions struct {
 	Stopped bool
 	// Stop torrent after all pieces are downloaded.
 	StopAfterDownload bool
+	// Do not exchange peer addresses with PEX messages. PEX is always disabled for private torrents.
+	DisablePEX bool
+	// Assume that the files already exist and are complete, and start seeding without checking the pieces.
+	// Useful for cross-seeding data downloaded by another client.
+	// A piece is verified when it is requested by a peer for the first time and downloaded again if the check fails.
+	// If some of the files are missing, all pieces are verified as usual.
+	// Only applies to AddTorrent.
+	SkipHashCheck bool
 }
 
 // AddTorrent adds a new torrent to the session by reading .torrent metainfo from reader.
@@ -74,6 +84,13 @@ func (s *Session) addTorrentStopped(r io.Reader, opt *AddTorrentOptions) (*Torre
 			s.releasePort(port)
 		}
 	}()
+	var bf *bitfield.Bitfield
+	if opt.SkipHashCheck {
+		bf = bitfield.New(mi.Info.NumPieces)
+		for i := uint32(0); i < bf.Len(); i++ {
+			bf.Set(i)
+		}
+	}
 	t, err := newTorrent2(
 		s,
 		id,
@@ -85,15 +102,26 @@ func (s *Session) addTorrentStopped(r io.Reader, opt *AddTorrentOptions) (*Torre
 		s.parseTrackers(mi.AnnounceList, mi.Info.Private),
 		nil, // fixedPeers
 		&mi.Info,
-		nil, // bitfield
+		bf,
 		resumer.Stats{},
-		webseedsource.NewList(mi.URLList),
+		append(webseedsource.NewList(mi.URLList), webseedsource.NewHTTPSeedList(mi.HTTPSeeds)...),
 		opt.StopAfterDownload,
+		opt.DisablePEX,
 		false, // completeCmdRun
+		Metainfo{
+			CreationDate: mi.CreationDate,
+			Comment:      mi.Comment,
+			CreatedBy:    mi.CreatedBy,
+			Encoding:     mi.Encoding,
+		},
 	)
 	if err != nil {
 		return nil, err
 	}
+	t.filePriorities = s.defaultFilePriorities(&mi.Info)
+	if bf != nil {
+		t.unverifiedPieces = bf.Copy()
+	}
 	go s.checkTorrent(t)
 	defer func() {
 		if err != nil {
@@ -106,9 +134,20 @@ func (s *Session) addTorrentStopped(r io.Reader, opt *AddTorrentOptions) (*Torre
 		Name:              mi.Info.Name,
 		Trackers:          mi.AnnounceList,
 		URLList:           mi.URLList,
+		HTTPSeeds:         mi.HTTPSeeds,
 		Info:              mi.Info.Bytes,
+		PieceLayers:       mi.Info.PieceLayers,
 		AddedAt:           t.addedAt,
 		StopAfterDownload: opt.StopAfterDownload,
+		DisablePEX:        opt.DisablePEX,
+		CreationDate:      mi.CreationDate,
+		Comment:           mi.Comment,
+		CreatedBy:         mi.CreatedBy,
+		Encoding:          mi.Encoding,
+		FilePriorities:    filePrioritiesToInts(t.filePriorities),
+	}
+	if bf != nil {
+		rspec.UnverifiedPieces = bf.Bytes()
 	}
 	err = s.resumer.Write(id, rspec)
 	if err != nil {
@@ -121,12 +160,17 @@ func (s *Session) addTorrentStopped(r io.Reader, opt *AddTorrentOptions) (*Torre
 // AddURI adds a new torrent to the session from a URI.
 // URI may be a magnet link or a HTTP URL.
 // In case of a HTTP address, a torrent is tried to be downloaded from that URL.
+// A hex encoded info hash is also accepted and added as a magnet link without trackers.
+// Peers of such torrent are found via DHT and LSD, so DHT must be enabled.
 // Nil value can be passed as opt for default options.
 func (s *Session) AddURI(uri string, opt *AddTorrentOptions) (*Torrent, error) {
 	uri = filterOutControlChars(uri)
 	if opt == nil {
 		opt = &AddTorrentOptions{}
 	}
+	if magnet.IsInfoHash(uri) {
+		return s.addInfoHash(uri, opt)
+	}
 	u, err := url.Parse(uri)
 	if err != nil {
 		return nil, newInputError(err)
@@ -141,6 +185,15 @@ func (s *Session) AddURI(uri string, opt *AddTorrentOptions) (*Torrent, error) {
 	}
 }
 
+var errInfoHashWithoutDHT = errors.New("DHT must be enabled to add a torrent by info hash")
+
+func (s *Session) addInfoHash(ih string, opt *AddTorrentOptions) (*Torrent, error) {
+	if !s.dhtEnabled {
+		return nil, newInputError(errInfoHashWithoutDHT)
+	}
+	return s.addMagnet("magnet:?xt=urn:btih:"+ih, opt)
+}
+
 func filterOutControlChars(s string) string {
 	var sb strings.Builder
 	sb.Grow(len(s))
@@ -185,26 +238,36 @@ func (s *Session) addMagnet(link string, opt *AddTorrentOptions) (*Torrent, erro
 			s.releasePort(port)
 		}
 	}()
+	// Use info hash as the provisional name until the metadata is downloaded.
+	name := ma.Name
+	if name == "" {
+		name = hex.EncodeToString(ma.InfoHash[:])
+	}
 	t, err := newTorrent2(
 		s,
 		id,
 		time.Now(),
 		ma.InfoHash[:],
 		sto,
-		ma.Name,
+		name,
 		port,
 		s.parseTrackers(ma.Trackers, false),
 		ma.Peers,
 		nil, // info
 		nil, // bitfield
 		resumer.Stats{},
-		nil, // webseedSources
+		webseedsource.NewList(ma.Webseeds),
 		opt.StopAfterDownload,
+		opt.DisablePEX,
 		false, // completeCmdRun
+		Metainfo{},
 	)
 	if err != nil {
 		return nil, err
 	}
+	if ma.InfoHashV2 != nil {
+		t.setInfoHashV2(*ma.InfoHashV2)
+	}
 	go s.checkTorrent(t)
 	defer func() {
 		if err != nil {
@@ -214,11 +277,16 @@ func (s *Session) addMagnet(link string, opt *AddTorrentOptions) (*Torrent, erro
 	rspec := &boltdbresumer.Spec{
 		InfoHash:          ma.InfoHash[:],
 		Port:              port,
-		Name:              ma.Name,
+		Name:              name,
 		Trackers:          ma.Trackers,
+		URLList:           ma.Webseeds,
 		FixedPeers:        ma.Peers,
 		AddedAt:           t.addedAt,
 		StopAfterDownload: opt.StopAfterDownload,
+		DisablePEX:        opt.DisablePEX,
+	}
+	if ma.InfoHashV2 != nil {
+		rspec.InfoHashV2 = ma.InfoHashV2[:]
 	}
 	err = s.resumer.Write(id, rspec)
 	if err != nil {
@@ -231,7 +299,7 @@ func (s *Session) addMagnet(link string, opt *AddTorrentOptions) (*Torrent, erro
 	return t2, err
 }
 
-func (s *Session) add(opt *AddTorrentOptions) (id string, port int, sto *filestorage.FileStorage, err error) {
+func (s *Session) add(opt *AddTorrentOptions) (id string, port int, sto storage.Storage, err error) {
 	port, err = s.getPort()
 	if err != nil {
 		return
@@ -261,16 +329,7 @@ func (s *Session) add(opt *AddTorrentOptions) (id string, port int, sto *filesto
 		}
 		id = base64.RawURLEncoding.EncodeToString(u1[:])
 	}
-	var dest string
-	if s.config.DataDirIncludesTorrentID {
-		dest = filepath.Join(s.config.DataDir, id)
-	} else {
-		dest = s.config.DataDir
-	}
-	sto, err = filestorage.New(dest)
-	if err != nil {
-		return
-	}
+	sto, err = s.newStorage(id, "")
 	return
 }
 
@@ -282,6 +341,7 @@ func (s *Session) insertTorrent(t *torrent) *Torrent {
 	s.mTorrents.Lock()
 	defer s.mTorrents.Unlock()
 	s.torrents[t.id] = t2
+	atomic.AddInt32(&s.numTorrents, 1)
 	ih := dht.InfoHash(t.InfoHash())
 	s.torrentsByInfoHash[ih] = append(s.torrentsByInfoHash[ih], t2)
 	return t2
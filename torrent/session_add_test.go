@@ -0,0 +1,94 @@
+package torrent
+
+import (
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+)
+
+func TestMagnetInfoHashV2(t *testing.T) {
+	defer leaktest.Check(t)()
+	tmp, closeTmp := tempdir(t)
+	defer closeTmp()
+	cfg := DefaultConfig
+	cfg.Database = filepath.Join(tmp, "session.db")
+	cfg.DataDir = tmp
+	cfg.DHTEnabled = false
+	cfg.PEXEnabled = false
+	cfg.RPCEnabled = false
+
+	v2 := "caf1e1c30e81cb361b9ee167c4aa64228a7fa4fa9f6105232b28ad099f3a302e"
+	link := torrentMagnetLink + "&xt=urn:btmh:1220" + v2
+	var truncated [20]byte
+	b, err := hex.DecodeString(v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(truncated[:], b)
+	check := func(tor *Torrent) {
+		if !tor.torrent.matchesInfoHash(truncated) {
+			t.Fatal("v2 info hash is not accepted in handshake")
+		}
+		m, err := tor.Magnet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(m, "xt=urn:btmh:1220"+v2) {
+			t.Fatalf("v2 info hash is not in magnet link: %s", m)
+		}
+	}
+
+	s, err := NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor, err := s.AddURI(link, &AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(tor)
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// V2 info hash is loaded from resume data before the metadata is downloaded.
+	s, err = NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	torrents := s.ListTorrents()
+	if len(torrents) != 1 {
+		t.Fatalf("loaded %d torrents", len(torrents))
+	}
+	check(torrents[0])
+}
+
+func TestAddInfoHash(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	_, err := s.AddURI(torrentInfoHashString, nil)
+	if !errors.Is(err, errInfoHashWithoutDHT) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// DHT node is not needed for adding the torrent.
+	s.dhtEnabled = true
+	defer func() { s.dhtEnabled = false }()
+	tor, err := s.AddURI(torrentInfoHashString, &AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tor.InfoHash().String() != torrentInfoHashString {
+		t.Fatalf("unexpected info hash: %s", tor.InfoHash())
+	}
+	if tor.Name() != torrentInfoHashString {
+		t.Fatalf("unexpected name: %s", tor.Name())
+	}
+}
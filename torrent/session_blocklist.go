@@ -6,9 +6,12 @@ import (
 	"context"
 	"crypto/sha1"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"os"
 	"time"
 
 	"github.com/cenkalti/backoff/v3"
@@ -101,12 +104,70 @@ func (s *Session) retryReloadBlocklist() {
 	}
 }
 
+// ReloadBlocklist downloads the blocklist from Config.BlocklistURL and replaces the rules in use.
+// Blocklist is also reloaded periodically in every Config.BlocklistUpdateInterval.
+func (s *Session) ReloadBlocklist() error {
+	if s.config.BlocklistURL == "" {
+		return errors.New("blocklist url is not set")
+	}
+	return s.reloadBlocklist()
+}
+
 func (s *Session) reloadBlocklist() error {
-	req, err := http.NewRequest(http.MethodGet, s.config.BlocklistURL, nil)
+	buf, err := s.fetchBlocklist()
+	if err != nil {
+		return err
+	}
+
+	err = s.loadBlocklistReader(bytes.NewReader(buf))
 	if err != nil {
 		return err
 	}
 
+	now := time.Now()
+
+	s.mBlocklist.Lock()
+	s.blocklistTimestamp = now
+	s.mBlocklist.Unlock()
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(sessionBucket)
+		err2 := b.Put(blocklistKey, buf)
+		if err2 != nil {
+			return err2
+		}
+		sum := sha1.Sum([]byte(s.config.BlocklistURL))
+		err2 = b.Put(blocklistURLHashKey, sum[:])
+		if err2 != nil {
+			return err2
+		}
+		return b.Put(blocklistTimestampKey, []byte(now.Format(time.RFC3339)))
+	})
+}
+
+func (s *Session) fetchBlocklist() ([]byte, error) {
+	u, err := url.Parse(s.config.BlocklistURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return s.downloadBlocklist()
+	case "file":
+		return s.readBlocklistFile(u.Path)
+	case "":
+		return s.readBlocklistFile(s.config.BlocklistURL)
+	default:
+		return nil, fmt.Errorf("unsupported blocklist url scheme: %s", u.Scheme)
+	}
+}
+
+func (s *Session) downloadBlocklist() ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, s.config.BlocklistURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go func() {
@@ -124,7 +185,7 @@ func (s *Session) reloadBlocklist() error {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -133,58 +194,54 @@ func (s *Session) reloadBlocklist() error {
 	s.log.Infoln("Blocklist response content type:", resp.Header.Get("content-type"))
 
 	if resp.StatusCode != 200 {
-		return errors.New("invalid blocklist status code")
+		return nil, errors.New("invalid blocklist status code")
 	}
 	if resp.ContentLength == -1 {
-		return errors.New("unknown content length")
+		return nil, errors.New("unknown content length")
 	}
 	if resp.ContentLength > s.config.BlocklistMaxResponseSize {
-		return errors.New("response too big")
+		return nil, errors.New("response too big")
 	}
 
 	buf := make([]byte, resp.ContentLength)
 	_, err = io.ReadFull(resp.Body, buf)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	resp.Body.Close()
 
 	if resp.Header.Get("content-type") == "application/x-gzip" {
-		gr, gerr := gzip.NewReader(bytes.NewReader(buf))
-		if gerr != nil {
-			return gerr
-		}
-		defer gr.Close()
-		buf, err = ioutil.ReadAll(gr)
-		if err != nil {
-			return err
-		}
+		return gunzip(buf)
 	}
+	return buf, nil
+}
 
-	err = s.loadBlocklistReader(bytes.NewReader(buf))
+func (s *Session) readBlocklistFile(name string) ([]byte, error) {
+	fi, err := os.Stat(name)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	if fi.Size() > s.config.BlocklistMaxResponseSize {
+		return nil, errors.New("blocklist file too big")
+	}
+	buf, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	// Files do not have a content type. Check the magic number of gzip format.
+	if len(buf) >= 2 && buf[0] == 0x1f && buf[1] == 0x8b {
+		return gunzip(buf)
+	}
+	return buf, nil
+}
 
-	now := time.Now()
-
-	s.mBlocklist.Lock()
-	s.blocklistTimestamp = now
-	s.mBlocklist.Unlock()
-
-	return s.db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(sessionBucket)
-		err2 := b.Put(blocklistKey, buf)
-		if err2 != nil {
-			return err2
-		}
-		sum := sha1.Sum([]byte(s.config.BlocklistURL))
-		err2 = b.Put(blocklistURLHashKey, sum[:])
-		if err2 != nil {
-			return err2
-		}
-		return b.Put(blocklistTimestampKey, []byte(now.Format(time.RFC3339)))
-	})
+func gunzip(buf []byte) ([]byte, error) {
+	gr, err := gzip.NewReader(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+	return ioutil.ReadAll(gr)
 }
 
 func (s *Session) loadBlocklistFromDB() error {
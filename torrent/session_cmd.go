@@ -24,7 +24,7 @@ func (s *Session) runOnCompleteCmd(torrent *torrent) {
 		"RAIN_TORRENT_DIR="+torrent.storage.RootDir(),
 		"RAIN_TORRENT_HASH="+hex.EncodeToString(torrent.infoHash[:]),
 		"RAIN_TORRENT_ID="+torrent.id,
-		"RAIN_TORRENT_NAME="+torrent.name)
+		"RAIN_TORRENT_NAME="+torrent.Name())
 
 	s.log.Debugf("executing completion hook for torrent %s: %s", torrent.id, cmd.String())
 
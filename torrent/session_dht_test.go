@@ -0,0 +1,47 @@
+package torrent
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/tracker"
+	"github.com/fortytw2/leaktest"
+)
+
+func TestPrivateTorrentDHT(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	// DHT node is not needed for checking whether the torrent is announced to DHT.
+	s.dhtEnabled = true
+	s.dhtPeerRequests = make(map[*torrent]struct{})
+	defer func() { s.dhtEnabled = false }()
+
+	for _, private := range []bool{false, true} {
+		info, err := metainfo.NewInfoBytes("", []string{filepath.Join(torrentDataDir, torrentName)}, private, 0, "", logger.New("test"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := metainfo.NewBytes(info, nil, nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tor, err := s.AddTorrent(bytes.NewReader(b), &AddTorrentOptions{Stopped: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		trk := &countingTracker{}
+		tor.torrent.trackers = []tracker.Tracker{trk}
+		tor.Start()
+		waitFor(t, "torrent is not announced to tracker", func() bool { return trk.Announces() != 0 })
+		// Announcers are started in the run loop before Stats is handled.
+		tor.Stats()
+		if created := tor.torrent.dhtAnnouncer != nil; created == private {
+			t.Fatalf("private: %v, DHT announcer created: %v", private, created)
+		}
+		tor.Stop()
+	}
+}
@@ -0,0 +1,127 @@
+package torrent
+
+import (
+	"net"
+	"time"
+
+	"github.com/cenkalti/rain/internal/externalip"
+)
+
+// NotifyExternalIPChange returns a channel that receives the new external IP address of the client when it changes.
+// Values are dropped if the receiver is not ready.
+func (s *Session) NotifyExternalIPChange() <-chan net.IP {
+	return s.externalIPChangeC
+}
+
+// ExternalIP returns the last known external IP address of the client.
+// Returns nil if the address is not known yet.
+func (s *Session) ExternalIP() net.IP {
+	s.mExternalIP.Lock()
+	defer s.mExternalIP.Unlock()
+	return s.externalIP
+}
+
+// externalIPVote is the last address reported by a peer IP.
+type externalIPVote struct {
+	IP string
+	// Weight of the vote is halved at every check so old votes count less and are removed eventually.
+	Weight float64
+}
+
+// Votes lighter than this are removed.
+const externalIPMinVoteWeight = 1.0 / 16
+
+// voteExternalIP records the address reported by a peer in "yourip" field of extension handshake.
+// Each peer IP has a single vote. Voting again replaces the previous vote of the peer.
+func (s *Session) voteExternalIP(voter string, ip net.IP) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return
+	}
+	s.mExternalIP.Lock()
+	s.externalIPVotes[voter] = externalIPVote{IP: ip4.String(), Weight: 1}
+	s.mExternalIP.Unlock()
+}
+
+func (s *Session) checkExternalIPLoop() {
+	ticker := time.NewTicker(s.config.ExternalIPCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.checkExternalIP()
+		case <-s.closeC:
+			return
+		}
+	}
+}
+
+func (s *Session) checkExternalIP() {
+	externalip.Refresh()
+	s.mExternalIP.Lock()
+	ip := s.electExternalIP()
+	s.decayExternalIPVotes()
+	if ip == nil || ip.Equal(s.externalIP) {
+		s.mExternalIP.Unlock()
+		return
+	}
+	old := s.externalIP
+	s.externalIP = ip
+	s.mExternalIP.Unlock()
+
+	if old == nil {
+		s.log.Infof("external IP is detected as %s", ip)
+		return
+	}
+	s.log.Infof("external IP has changed from %s to %s, announcing torrents again", old, ip)
+	select {
+	case s.externalIPChangeC <- ip:
+	default:
+	}
+	// Router may have been replaced or restarted, so the ports may not be forwarded anymore.
+	if s.portMapper != nil {
+		s.portMapper.Refresh()
+	}
+	for _, t := range s.ListTorrents() {
+		go t.torrent.Announce()
+	}
+}
+
+// decayExternalIPVotes halves the weight of all votes and removes the ones that are too old.
+func (s *Session) decayExternalIPVotes() {
+	for voter, v := range s.externalIPVotes {
+		v.Weight /= 2
+		if v.Weight < externalIPMinVoteWeight {
+			delete(s.externalIPVotes, voter)
+			continue
+		}
+		s.externalIPVotes[voter] = v
+	}
+}
+
+// electExternalIP returns the public address with the highest total vote weight from peers.
+// Falls back to the address reported by the router or the address of the network interfaces if there are not enough votes.
+func (s *Session) electExternalIP() net.IP {
+	tally := make(map[string]float64)
+	for _, v := range s.externalIPVotes {
+		tally[v.IP] += v.Weight
+	}
+	var best string
+	var bestVotes float64
+	for ip, votes := range tally {
+		if votes > bestVotes || (votes == bestVotes && ip < best) {
+			best, bestVotes = ip, votes
+		}
+	}
+	if bestVotes >= float64(s.config.ExternalIPMinVotes) && bestVotes > 0 {
+		if ip := net.ParseIP(best).To4(); externalip.IsPublic(ip) {
+			return ip
+		}
+	}
+	if s.portMapper != nil {
+		if ip := s.portMapper.ExternalIP(); ip != nil {
+			return ip
+		}
+	}
+	return externalip.FirstExternalIP()
+}
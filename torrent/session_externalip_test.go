@@ -0,0 +1,109 @@
+package torrent
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/portmap"
+)
+
+func TestExternalIPVotes(t *testing.T) {
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	ip := net.IPv4(203, 0, 113, 5).To4()
+	elect := func() net.IP {
+		s.mExternalIP.Lock()
+		defer s.mExternalIP.Unlock()
+		return s.electExternalIP()
+	}
+
+	// Same peer cannot vote multiple times.
+	for i := 0; i < s.config.ExternalIPMinVotes; i++ {
+		s.voteExternalIP("1.1.1.1", ip)
+	}
+	if elect().Equal(ip) {
+		t.Fatal("address must not be elected with votes from a single peer")
+	}
+	s.voteExternalIP("1.1.1.2", ip)
+	s.voteExternalIP("1.1.1.3", ip)
+	if !elect().Equal(ip) {
+		t.Fatal("address must be elected")
+	}
+
+	// Old votes lose weight.
+	s.mExternalIP.Lock()
+	s.decayExternalIPVotes()
+	s.mExternalIP.Unlock()
+	if elect().Equal(ip) {
+		t.Fatal("address must not be elected with old votes")
+	}
+
+	// Private addresses are never elected.
+	private := net.IPv4(192, 168, 1, 2).To4()
+	for _, voter := range []string{"1.1.1.1", "1.1.1.2", "1.1.1.3"} {
+		s.voteExternalIP(voter, private)
+	}
+	if elect().Equal(private) {
+		t.Fatal("private address must not be elected")
+	}
+}
+
+type fakeRouter struct {
+	discoverC chan struct{}
+}
+
+func (r *fakeRouter) AddPortMapping(ctx context.Context, port int, lease time.Duration) error {
+	return nil
+}
+
+func (r *fakeRouter) DeletePortMapping(ctx context.Context, port int) error {
+	return nil
+}
+
+func (r *fakeRouter) ExternalIP(ctx context.Context) (net.IP, error) {
+	return nil, nil
+}
+
+func (r *fakeRouter) String() string { return "fake" }
+
+func TestExternalIPChangeRefreshesPortMappings(t *testing.T) {
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	router := &fakeRouter{discoverC: make(chan struct{}, 10)}
+	s.portMapper = portmap.New(time.Hour, time.Second, logger.New("portmap"))
+	s.portMapper.Discover = func(ctx context.Context) (portmap.Protocol, error) {
+		router.discoverC <- struct{}{}
+		return router, nil
+	}
+	go s.portMapper.Run()
+	s.portMapper.Add(6881)
+	<-router.discoverC
+
+	ip := net.IPv4(203, 0, 113, 5).To4()
+	s.mExternalIP.Lock()
+	s.externalIP = ip
+	s.mExternalIP.Unlock()
+	voters := []string{"1.1.1.1", "1.1.1.2", "1.1.1.3"}
+	vote := func(ip net.IP) {
+		for _, voter := range voters {
+			s.voteExternalIP(voter, ip)
+		}
+		s.checkExternalIP()
+	}
+	vote(ip)
+	select {
+	case <-router.discoverC:
+		t.Fatal("router must not be discovered again if the external IP is not changed")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	vote(net.IPv4(203, 0, 113, 6))
+	select {
+	case <-router.discoverC:
+	case <-time.After(timeout):
+		t.Fatal("router is not discovered again after the external IP has changed")
+	}
+}
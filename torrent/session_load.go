@@ -2,13 +2,13 @@ package torrent
 
 import (
 	"errors"
-	"path/filepath"
+	"math/rand"
+	"time"
 
 	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/metainfo"
 	"github.com/cenkalti/rain/internal/resumer"
 	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
-	"github.com/cenkalti/rain/internal/storage/filestorage"
 	"github.com/cenkalti/rain/internal/webseedsource"
 	"go.etcd.io/bbolt"
 )
@@ -33,14 +33,24 @@ func (s *Session) loadExistingTorrents(ids []string) {
 	}
 	s.log.Infof("loaded %d existing torrents", loaded)
 	if s.config.ResumeOnStartup {
-		for _, t := range started {
-			t.torrent.Start()
+		s.resumeTorrents(started)
+	}
+}
+
+// resumeTorrents starts torrents that were running when previous session was closed.
+// First announces are spread over StartupAnnounceStagger to avoid a burst of announces on startup.
+func (s *Session) resumeTorrents(torrents []*Torrent) {
+	for _, t := range torrents {
+		var delay time.Duration
+		if s.config.StartupAnnounceStagger > 0 {
+			delay = time.Duration(rand.Int63n(int64(s.config.StartupAnnounceStagger)))
 		}
+		t.torrent.startWithAnnounceDelay(delay)
 	}
 }
 
-func (s *Session) parseInfo(b []byte) (*metainfo.Info, error) {
-	i, err := metainfo.NewInfo(b)
+func (s *Session) parseInfo(b, pieceLayers []byte) (*metainfo.Info, error) {
+	i, err := metainfo.NewInfo(b, pieceLayers)
 	if err != nil {
 		return nil, err
 	}
@@ -55,12 +65,13 @@ func (s *Session) loadExistingTorrent(id string) (tt *Torrent, hasStarted bool,
 	if err != nil {
 		return
 	}
-	hasStarted = spec.Started
+	// Torrents that are stopped by the seed limits stay in SeedingComplete until they are started again.
+	hasStarted = spec.Started && !spec.SeedLimitReached
 	var info *metainfo.Info
-	var bf *bitfield.Bitfield
+	var bf, unverified *bitfield.Bitfield
 	var private bool
 	if len(spec.Info) > 0 {
-		info2, err2 := s.parseInfo(spec.Info)
+		info2, err2 := s.parseInfo(spec.Info, spec.PieceLayers)
 		if err2 != nil {
 			return nil, spec.Started, err2
 		}
@@ -72,15 +83,19 @@ func (s *Session) loadExistingTorrent(id string) (tt *Torrent, hasStarted bool,
 				return nil, spec.Started, err3
 			}
 			bf = bf3
+			if len(spec.UnverifiedPieces) > 0 {
+				unverified, err = bitfield.NewBytes(spec.UnverifiedPieces, info.NumPieces)
+				if err != nil {
+					return nil, spec.Started, err
+				}
+			}
 		}
 	}
-	var dest string
-	if s.config.DataDirIncludesTorrentID {
-		dest = filepath.Join(s.config.DataDir, id)
-	} else {
-		dest = s.config.DataDir
+	name := spec.Name
+	if info != nil {
+		name = info.Name
 	}
-	sto, err := filestorage.New(dest)
+	sto, err := s.newStorage(id, spec.Location)
 	if err != nil {
 		return
 	}
@@ -90,7 +105,7 @@ func (s *Session) loadExistingTorrent(id string) (tt *Torrent, hasStarted bool,
 		spec.AddedAt,
 		spec.InfoHash,
 		sto,
-		spec.Name,
+		name,
 		spec.Port,
 		s.parseTrackers(spec.Trackers, private),
 		spec.FixedPeers,
@@ -102,15 +117,38 @@ func (s *Session) loadExistingTorrent(id string) (tt *Torrent, hasStarted bool,
 			BytesWasted:     spec.BytesWasted,
 			SeededFor:       int64(spec.SeededFor),
 		},
-		webseedsource.NewList(spec.URLList),
+		append(webseedsource.NewList(spec.URLList), webseedsource.NewHTTPSeedList(spec.HTTPSeeds)...),
 		spec.StopAfterDownload,
+		spec.DisablePEX,
 		spec.CompleteCmdRun,
+		Metainfo{
+			CreationDate: spec.CreationDate,
+			Comment:      spec.Comment,
+			CreatedBy:    spec.CreatedBy,
+			Encoding:     spec.Encoding,
+		},
 	)
 	if err != nil {
 		return
 	}
+	if info == nil && len(spec.InfoHashV2) == 32 {
+		var h [32]byte
+		copy(h[:], spec.InfoHashV2)
+		t.setInfoHashV2(h)
+	}
 	t.rawTrackers = spec.Trackers
+	t.resumePeers = spec.Peers
+	for addr, n := range spec.PeerFailures {
+		t.resumePeerFailures[addr] = n
+	}
+	t.partialPieces = spec.PartialPieces
+	t.filePriorities = filePrioritiesFromInts(spec.FilePriorities)
+	t.fileStats = fileStatsFromSpec(spec.FileStats)
 	t.rawWebseedSources = spec.URLList
+	t.rawHTTPSeeds = spec.HTTPSeeds
+	t.location = spec.Location
+	t.seedLimitReached = spec.SeedLimitReached
+	t.unverifiedPieces = unverified
 	go s.checkTorrent(t)
 	delete(s.availablePorts, spec.Port)
 
@@ -121,18 +159,11 @@ func (s *Session) loadExistingTorrent(id string) (tt *Torrent, hasStarted bool,
 // CleanDatabase removes invalid records in the database.
 // Normally you don't need to call this.
 func (s *Session) CleanDatabase() error {
-	err := s.db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(torrentsBucket)
-		for _, id := range s.invalidTorrentIDs {
-			err := b.DeleteBucket([]byte(id))
-			if err != nil {
-				return err
-			}
+	for _, id := range s.invalidTorrentIDs {
+		err := s.resumer.Delete(id)
+		if err != nil {
+			return err
 		}
-		return nil
-	})
-	if err != nil {
-		return err
 	}
 	s.invalidTorrentIDs = nil
 	return nil
@@ -157,19 +188,15 @@ func (s *Session) CompactDatabase(output string) error {
 	if err != nil {
 		return err
 	}
-	for _, t := range s.torrents {
-		spec := &boltdbresumer.Spec{
-			InfoHash:          t.torrent.InfoHash(),
-			Port:              t.torrent.port,
-			Name:              t.torrent.name,
-			Trackers:          t.torrent.rawTrackers,
-			URLList:           t.torrent.rawWebseedSources,
-			FixedPeers:        t.torrent.fixedPeers,
-			Info:              t.torrent.info.Bytes,
-			AddedAt:           t.torrent.addedAt,
-			StopAfterDownload: t.torrent.stopAfterDownload,
+	s.mTorrents.RLock()
+	defer s.mTorrents.RUnlock()
+	for id := range s.torrents {
+		// Resume data is written as the torrent changes, so the existing record has all fields up to date.
+		spec, err := s.resumer.Read(id)
+		if err != nil {
+			return err
 		}
-		err = res.Write(t.torrent.id, spec)
+		err = res.Write(id, spec)
 		if err != nil {
 			return err
 		}
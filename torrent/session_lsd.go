@@ -0,0 +1,48 @@
+package torrent
+
+import (
+	"net"
+	"time"
+
+	"github.com/nictuku/dht"
+)
+
+func (s *Session) processLSD() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.handleLSDTick()
+		case p := <-s.lsd.PeersC:
+			s.mTorrents.RLock()
+			for _, t := range s.torrentsByInfoHash[dht.InfoHash(p.InfoHash[:])] {
+				select {
+				case t.torrent.lsdPeersC <- []*net.TCPAddr{p.Addr}:
+				case <-t.torrent.closeC:
+				default:
+				}
+			}
+			s.mTorrents.RUnlock()
+		case <-s.closeC:
+			return
+		}
+	}
+}
+
+// handleLSDTick sends the pending announce requests. Torrents listening on the same port are announced in the same message.
+func (s *Session) handleLSDTick() {
+	s.mPeerRequests.Lock()
+	infoHashes := make(map[int][][20]byte)
+	for t := range s.lsdPeerRequests {
+		infoHashes[t.port] = append(infoHashes[t.port], t.infoHash)
+		delete(s.lsdPeerRequests, t)
+	}
+	s.mPeerRequests.Unlock()
+	for port, ihs := range infoHashes {
+		err := s.lsd.Announce(port, ihs)
+		if err != nil {
+			s.log.Debugln("cannot announce to local network:", err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package torrent
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/lsd"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/fortytw2/leaktest"
+)
+
+// enableLSD starts local service discovery in a session created with newTestSession.
+func enableLSD(t *testing.T, s *Session) {
+	var err error
+	s.lsd, err = lsd.New(logger.New("lsd"))
+	if err != nil {
+		t.Skip("cannot join multicast group:", err)
+	}
+	s.lsdPeerRequests = make(map[*torrent]struct{})
+	go s.lsd.Run()
+	go s.processLSD()
+}
+
+func TestLSDPrivateTorrent(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	enableLSD(t, s)
+	d, err := lsd.New(logger.New("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	go d.Run()
+
+	ports := make(map[[20]byte]int)
+	for _, private := range []bool{false, true} {
+		info, err := metainfo.NewInfoBytes("", []string{filepath.Join(torrentDataDir, torrentName)}, private, 0, "", logger.New("test"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := metainfo.NewBytes(info, nil, nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tor, err := s.AddTorrent(bytes.NewReader(b), &AddTorrentOptions{Stopped: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tor.torrent.trackers = nil
+		tor.Start()
+		if !private {
+			ports[tor.torrent.infoHash] = tor.Port()
+		}
+	}
+	// Both torrents are requested to be announced in the same tick, so the private torrent would be received shortly after the public one.
+	var received int
+	deadline := time.After(timeout)
+	for {
+		select {
+		case p := <-d.PeersC:
+			port, ok := ports[p.InfoHash]
+			if !ok {
+				t.Fatalf("unexpected torrent is announced: %x", p.InfoHash)
+			}
+			if p.Addr.Port != port {
+				t.Fatalf("announced port: %d, expected: %d", p.Addr.Port, port)
+			}
+			received++
+			deadline = time.After(2 * time.Second)
+		case <-deadline:
+			if received == 0 {
+				t.Fatal("torrent is not announced to local network")
+			}
+			return
+		}
+	}
+}
@@ -16,6 +16,8 @@ type sessionMetrics struct {
 	Uptime                metrics.Gauge
 	BlockListRules        metrics.Gauge
 	BlockListRecency      metrics.Gauge
+	BlockListRejected     metrics.Counter
+	IncomingRejected      metrics.Counter
 	ReadCacheObjects      metrics.Gauge
 	ReadCacheSize         metrics.Gauge
 	ReadCacheUtilization  metrics.Gauge
@@ -62,6 +64,8 @@ func (s *Session) initMetrics() {
 			}
 			return int64(time.Since(s.blocklistTimestamp) / time.Second)
 		}),
+		BlockListRejected: metrics.NewRegisteredCounter("blocklist_rejected", r),
+		IncomingRejected:  metrics.NewRegisteredCounter("incoming_rejected", r),
 
 		ReadCacheObjects:     metrics.NewRegisteredFunctionalGauge("read_cache_objects", r, func() int64 { return int64(s.pieceCache.Len()) }),
 		ReadCacheSize:        metrics.NewRegisteredFunctionalGauge("read_cache_size", r, func() int64 { return s.pieceCache.Size() }),
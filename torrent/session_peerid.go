@@ -0,0 +1,65 @@
+package torrent
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// validatePeerIDPrefix checks that the prefix is in Azureus-style format described in BEP 20:
+// '-', two characters for the client, four characters for the version and '-'.
+func validatePeerIDPrefix(prefix string) error {
+	if len(prefix) != 8 {
+		return fmt.Errorf("peer id prefix must be 8 bytes: %q", prefix)
+	}
+	if prefix[0] != '-' || prefix[7] != '-' {
+		return fmt.Errorf("peer id prefix must start and end with '-': %q", prefix)
+	}
+	for _, c := range []byte(prefix[1:7]) {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return fmt.Errorf("peer id prefix must contain only letters and digits between dashes: %q", prefix)
+		}
+	}
+	return nil
+}
+
+// newPeerIDSuffix returns the random bytes of a peer id.
+func newPeerIDSuffix() (suffix [20]byte, err error) {
+	_, err = rand.Read(suffix[:])
+	return
+}
+
+// peerID returns the peer id that starts with prefix. Remaining bytes are random and same for all torrents in the session.
+func (s *Session) peerID(prefix string) [20]byte {
+	id := s.peerIDSuffix
+	copy(id[:], prefix)
+	return id
+}
+
+// torrentPeerID returns the peer id of a new torrent that starts with prefix.
+// The session-wide peer id is shared only if Config.PeerIDPrefix is set, so the client is identified consistently.
+// Otherwise, each torrent gets a random peer id, so trackers and peers cannot link the torrents of the session.
+func (s *Session) torrentPeerID(prefix string) ([20]byte, error) {
+	if s.config.PeerIDPrefix != "" {
+		return s.peerID(prefix), nil
+	}
+	id, err := newPeerIDSuffix()
+	if err != nil {
+		return id, err
+	}
+	copy(id[:], prefix)
+	return id, nil
+}
+
+func (s *Session) peerIDPrefix() string {
+	if s.config.PeerIDPrefix != "" {
+		return s.config.PeerIDPrefix
+	}
+	return publicPeerIDPrefix
+}
+
+// PeerID returns the peer id of the session.
+// It is sent to peers and trackers by all torrents only if Config.PeerIDPrefix is set.
+// Otherwise, each torrent uses a random peer id.
+func (s *Session) PeerID() [20]byte {
+	return s.peerID(s.peerIDPrefix())
+}
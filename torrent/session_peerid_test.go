@@ -0,0 +1,65 @@
+package torrent
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPeerIDPrefix(t *testing.T) {
+	tmp, closeTmp := tempdir(t)
+	defer closeTmp()
+	cfg := DefaultConfig
+	cfg.Database = filepath.Join(tmp, "session.db")
+	cfg.DataDir = tmp
+	cfg.DHTEnabled = false
+	cfg.LSDEnabled = false
+	cfg.RPCEnabled = false
+
+	for _, prefix := range []string{"-RN0001", "-RN00001-", "RN0001--", "-RN 001-", "-RN0001-" + strings.Repeat("x", 13)} {
+		cfg.PeerIDPrefix = prefix
+		if _, err := NewSession(cfg); err == nil {
+			t.Fatalf("session must not be created with peer id prefix %q", prefix)
+		}
+	}
+
+	cfg.PeerIDPrefix = "-XX0001-"
+	cfg.ClientVersion = "XX 0.0.1"
+	s, err := NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	id := s.PeerID()
+	if !bytes.HasPrefix(id[:], []byte("-XX0001-")) {
+		t.Fatalf("invalid peer id: %q", id[:])
+	}
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	if tor.torrent.peerID != id {
+		t.Fatalf("torrent must use the peer id of session: %q", tor.torrent.peerID[:])
+	}
+	if v := tor.torrent.getClientVersion(); v != cfg.ClientVersion {
+		t.Fatalf("invalid client version: %q", v)
+	}
+	if ua := s.getTrackerUserAgent(true); ua != cfg.ClientVersion {
+		t.Fatalf("invalid user agent: %q", ua)
+	}
+}
+
+func TestRandomPeerIDPerTorrent(t *testing.T) {
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	var ids [][20]byte
+	for i := 0; i < 2; i++ {
+		tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+		if !bytes.HasPrefix(tor.torrent.peerID[:], []byte(publicPeerIDPrefix)) {
+			t.Fatalf("invalid peer id: %q", tor.torrent.peerID[:])
+		}
+		ids = append(ids, tor.torrent.peerID)
+	}
+	if ids[0] == ids[1] {
+		t.Fatal("torrents must have different peer ids if peer id prefix is not set")
+	}
+}
@@ -0,0 +1,42 @@
+package torrent
+
+import (
+	"github.com/cenkalti/rain/internal/resumer"
+	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
+)
+
+// ResumeStore keeps the resume data of torrents, so they are restored when the session is opened again.
+// Custom backends can be plugged in with Config.ResumeStore.
+type ResumeStore = resumer.Store
+
+// sessionResumer saves and loads the resume data of torrents.
+// Implemented by boltdbresumer for the session database and by storeresumer for Config.ResumeStore.
+type sessionResumer interface {
+	Write(torrentID string, spec *boltdbresumer.Spec) error
+	Read(torrentID string) (*boltdbresumer.Spec, error)
+	Delete(torrentID string) error
+	List() ([]string, error)
+	WriteInfo(torrentID string, value []byte) error
+	WriteBitfield(torrentID string, value []byte, fileStats []boltdbresumer.FileStat, stats resumer.Stats) error
+	DeleteBitfield(torrentID string) error
+	WriteUnverifiedPieces(torrentID string, value []byte) error
+	WriteStats(torrentID string, stats resumer.Stats) error
+	WriteTrackers(torrentID string, value [][]string) error
+	WritePeers(torrentID string, value []string, failures map[string]int) error
+	WritePartialPieces(torrentID string, value map[uint32][]int) error
+	WriteFilePriorities(torrentID string, value []int) error
+	WriteLocation(torrentID string, value string) error
+	WriteStarted(torrentID string, value bool) error
+	WriteCompleteCmdRun(torrentID string) error
+	WriteSeedLimitReached(torrentID string, value bool) error
+}
+
+// resumeStats returns the transfer stats of the torrent that are saved in resume data.
+func (t *torrent) resumeStats() resumer.Stats {
+	return resumer.Stats{
+		BytesDownloaded: t.bytesDownloaded.Count(),
+		BytesUploaded:   t.bytesUploaded.Count(),
+		BytesWasted:     t.bytesWasted.Count(),
+		SeededFor:       t.seededFor.Count(),
+	}
+}
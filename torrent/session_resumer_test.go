@@ -0,0 +1,104 @@
+package torrent
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+)
+
+type memoryResumeStore struct {
+	m    sync.Mutex
+	data map[string][]byte
+}
+
+func (s *memoryResumeStore) Save(id string, data []byte) error {
+	s.m.Lock()
+	s.data[id] = data
+	s.m.Unlock()
+	return nil
+}
+
+func (s *memoryResumeStore) Load(id string) ([]byte, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.data[id], nil
+}
+
+func (s *memoryResumeStore) Delete(id string) error {
+	s.m.Lock()
+	delete(s.data, id)
+	s.m.Unlock()
+	return nil
+}
+
+func (s *memoryResumeStore) List() ([]string, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	ids := make([]string, 0, len(s.data))
+	for id := range s.data {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func TestResumeStore(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	tmp, closeTmp := tempdir(t)
+	defer closeTmp()
+	cfg := DefaultConfig
+	cfg.Database = filepath.Join(tmp, "session.db")
+	cfg.DataDir = tmp
+	cfg.DHTEnabled = false
+	cfg.PEXEnabled = false
+	cfg.RPCEnabled = false
+	cfg.ResumeStore = &memoryResumeStore{data: make(map[string][]byte)}
+
+	s, err := NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	tor.torrent.trackers = nil
+	tor.Start()
+	if err = tor.AddPeer(addr); err != nil {
+		t.Fatal(err)
+	}
+	assertCompleted(t, tor)
+	downloaded := tor.Stats().Bytes.Downloaded
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Torrent is restored from the store with its bitfield and stats.
+	cfg.ResumeOnStartup = false
+	s, err = NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	torrents := s.ListTorrents()
+	if len(torrents) != 1 {
+		t.Fatalf("loaded %d torrents", len(torrents))
+	}
+	tor = torrents[0]
+	tor.torrent.trackers = nil
+	tor.Start()
+	waitStatus(t, tor, Seeding)
+	st := tor.Stats()
+	if st.Pieces.Have != st.Pieces.Total {
+		t.Fatalf("restored %d of %d pieces", st.Pieces.Have, st.Pieces.Total)
+	}
+	if st.Bytes.Downloaded != downloaded {
+		t.Fatalf("restored downloaded bytes: %d, expected: %d", st.Bytes.Downloaded, downloaded)
+	}
+	if err = s.RemoveTorrent(tor.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ := cfg.ResumeStore.List(); len(ids) != 0 {
+		t.Fatal("resume data is not deleted")
+	}
+}
@@ -2,6 +2,7 @@ package torrent
 
 import (
 	"archive/tar"
+	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -88,6 +89,18 @@ func (h *rpcHandler) RemoveTorrent(args *rpctypes.RemoveTorrentRequest, reply *r
 	return h.session.RemoveTorrent(args.ID)
 }
 
+func (h *rpcHandler) RemoveTorrentWait(args *rpctypes.RemoveTorrentWaitRequest, reply *rpctypes.RemoveTorrentWaitResponse) error {
+	ctx := context.Background()
+	if args.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(args.Timeout)*time.Second)
+		defer cancel()
+	}
+	var err error
+	reply.StoppedEventAnnounced, err = h.session.RemoveTorrentContext(ctx, args.ID)
+	return err
+}
+
 func (h *rpcHandler) GetMagnet(args *rpctypes.GetMagnetRequest, reply *rpctypes.GetMagnetResponse) error {
 	t := h.session.GetTorrent(args.ID)
 	if t == nil {
@@ -98,6 +111,21 @@ func (h *rpcHandler) GetMagnet(args *rpctypes.GetMagnetRequest, reply *rpctypes.
 	return err
 }
 
+func (h *rpcHandler) GetTorrentMetainfo(args *rpctypes.GetTorrentMetainfoRequest, reply *rpctypes.GetTorrentMetainfoResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	mi := t.Metainfo()
+	reply.Metainfo = rpctypes.Metainfo{
+		CreationDate: rpctypes.Time{Time: mi.CreationDate},
+		Comment:      mi.Comment,
+		CreatedBy:    mi.CreatedBy,
+		Encoding:     mi.Encoding,
+	}
+	return nil
+}
+
 func (h *rpcHandler) GetTorrent(args *rpctypes.GetTorrentRequest, reply *rpctypes.GetTorrentResponse) error {
 	t := h.session.GetTorrent(args.ID)
 	if t == nil {
@@ -123,8 +151,10 @@ func (h *rpcHandler) GetSessionStats(args *rpctypes.GetSessionStatsRequest, repl
 		Peers:          s.Peers,
 		PortsAvailable: s.PortsAvailable,
 
-		BlockListRules:   s.BlockListRules,
-		BlockListRecency: int(s.BlockListRecency / time.Second),
+		BlockListRules:    s.BlockListRules,
+		BlockListRecency:  int(s.BlockListRecency / time.Second),
+		BlockListRejected: s.BlockListRejected,
+		IncomingRejected:  s.IncomingRejected,
 
 		ReadCacheObjects:     s.ReadCacheObjects,
 		ReadCacheSize:        s.ReadCacheSize,
@@ -161,17 +191,19 @@ func (h *rpcHandler) GetTorrentStats(args *rpctypes.GetTorrentStatsRequest, repl
 		Port:     s.Port,
 		Status:   s.Status.String(),
 		Pieces: struct {
-			Checked   uint32
-			Have      uint32
-			Missing   uint32
-			Available uint32
-			Total     uint32
+			Checked      uint32
+			Have         uint32
+			Missing      uint32
+			Available    uint32
+			Total        uint32
+			Availability float64
 		}{
-			Checked:   s.Pieces.Checked,
-			Have:      s.Pieces.Have,
-			Missing:   s.Pieces.Missing,
-			Available: s.Pieces.Available,
-			Total:     s.Pieces.Total,
+			Checked:      s.Pieces.Checked,
+			Have:         s.Pieces.Have,
+			Missing:      s.Pieces.Missing,
+			Available:    s.Pieces.Available,
+			Total:        s.Pieces.Total,
+			Availability: s.Pieces.Availability,
 		},
 		Bytes: struct {
 			Total      int64
@@ -191,13 +223,21 @@ func (h *rpcHandler) GetTorrentStats(args *rpctypes.GetTorrentStatsRequest, repl
 			Wasted:     s.Bytes.Wasted,
 		},
 		Peers: struct {
-			Total    int
-			Incoming int
-			Outgoing int
+			Total      int
+			Incoming   int
+			Outgoing   int
+			DialTarget int
+			Seeking    bool
+			Choked     int
+			Unchoked   int
 		}{
-			Total:    s.Peers.Total,
-			Incoming: s.Peers.Incoming,
-			Outgoing: s.Peers.Outgoing,
+			Total:      s.Peers.Total,
+			Incoming:   s.Peers.Incoming,
+			Outgoing:   s.Peers.Outgoing,
+			DialTarget: s.Peers.DialTarget,
+			Seeking:    s.Peers.Seeking,
+			Choked:     s.Peers.Choked,
+			Unchoked:   s.Peers.Unchoked,
 		},
 		Handshakes: struct {
 			Total    int
@@ -213,11 +253,27 @@ func (h *rpcHandler) GetTorrentStats(args *rpctypes.GetTorrentStatsRequest, repl
 			Tracker int
 			DHT     int
 			PEX     int
+			LSD     int
+			Resume  int
+			Saved   int
 		}{
 			Total:   s.Addresses.Total,
 			Tracker: s.Addresses.Tracker,
 			DHT:     s.Addresses.DHT,
 			PEX:     s.Addresses.PEX,
+			LSD:     s.Addresses.LSD,
+			Resume:  s.Addresses.Resume,
+			Saved:   s.Addresses.Saved,
+		},
+		Swarm: struct {
+			Seeders    int
+			Leechers   int
+			Trackers   int
+			LastUpdate rpctypes.Time
+		}{
+			Seeders:  s.Swarm.Seeders,
+			Leechers: s.Swarm.Leechers,
+			Trackers: s.Swarm.Trackers,
 		},
 		Downloads: struct {
 			Total   int
@@ -243,6 +299,7 @@ func (h *rpcHandler) GetTorrentStats(args *rpctypes.GetTorrentStatsRequest, repl
 		Private:     s.Private,
 		PieceLength: s.PieceLength,
 		SeededFor:   uint(s.SeededFor / time.Second),
+		SeedRatio:   s.SeedRatio,
 		Speed: struct {
 			Download int
 			Upload   int
@@ -254,6 +311,9 @@ func (h *rpcHandler) GetTorrentStats(args *rpctypes.GetTorrentStatsRequest, repl
 	if s.Error != nil {
 		reply.Stats.Error = s.Error.Error()
 	}
+	if !s.Swarm.LastUpdate.IsZero() {
+		reply.Stats.Swarm.LastUpdate = rpctypes.Time{Time: s.Swarm.LastUpdate}
+	}
 	if s.ETA != nil {
 		reply.Stats.ETA = int(*s.ETA / time.Second)
 	} else {
@@ -285,6 +345,9 @@ func (h *rpcHandler) GetTorrentTrackers(args *rpctypes.GetTorrentTrackersRequest
 		if !t.LastAnnounce.IsZero() {
 			reply.Trackers[i].LastAnnounce = rpctypes.Time{Time: t.LastAnnounce}
 		}
+		if !t.LastSuccess.IsZero() {
+			reply.Trackers[i].LastSuccess = rpctypes.Time{Time: t.LastSuccess}
+		}
 		if !t.NextAnnounce.IsZero() {
 			reply.Trackers[i].NextAnnounce = rpctypes.Time{Time: t.NextAnnounce}
 		}
@@ -312,26 +375,35 @@ func (h *rpcHandler) GetTorrentPeers(args *rpctypes.GetTorrentPeersRequest, repl
 			source = "INCOMING"
 		case SourceManual:
 			source = "MANUAL"
+		case SourceResume:
+			source = "RESUME"
+		case SourceLSD:
+			source = "LSD"
 		default:
 			panic("unhandled peer source")
 		}
 		reply.Peers[i] = rpctypes.Peer{
-			ID:                 hex.EncodeToString(p.ID[:]),
-			Client:             p.Client,
-			Addr:               p.Addr.String(),
-			Source:             source,
-			ConnectedAt:        rpctypes.Time{Time: p.ConnectedAt},
-			Downloading:        p.Downloading,
-			ClientInterested:   p.ClientInterested,
-			ClientChoking:      p.ClientChoking,
-			PeerInterested:     p.PeerInterested,
-			PeerChoking:        p.PeerChoking,
-			OptimisticUnchoked: p.OptimisticUnchoked,
-			Snubbed:            p.Snubbed,
-			EncryptedHandshake: p.EncryptedHandshake,
-			EncryptedStream:    p.EncryptedStream,
-			DownloadSpeed:      p.DownloadSpeed,
-			UploadSpeed:        p.UploadSpeed,
+			ID:                   hex.EncodeToString(p.ID[:]),
+			Client:               p.Client,
+			Addr:                 p.Addr.String(),
+			Source:               source,
+			ConnectedAt:          rpctypes.Time{Time: p.ConnectedAt},
+			Downloading:          p.Downloading,
+			ClientInterested:     p.ClientInterested,
+			ClientChoking:        p.ClientChoking,
+			PeerInterested:       p.PeerInterested,
+			PeerChoking:          p.PeerChoking,
+			OptimisticUnchoked:   p.OptimisticUnchoked,
+			Snubbed:              p.Snubbed,
+			EncryptedHandshake:   p.EncryptedHandshake,
+			EncryptedStream:      p.EncryptedStream,
+			DownloadSpeed:        p.DownloadSpeed,
+			UploadSpeed:          p.UploadSpeed,
+			RequestsOut:          p.RequestsOut,
+			MaxRequestsOut:       p.MaxRequestsOut,
+			PeerRequestQueue:     p.PeerRequestQueue,
+			RequestLatencyMillis: int(p.RequestLatency / time.Millisecond),
+			ReciprocationRatio:   p.ReciprocationRatio,
 		}
 	}
 	return nil
@@ -347,6 +419,7 @@ func (h *rpcHandler) GetTorrentWebseeds(args *rpctypes.GetTorrentWebseedsRequest
 	for i, p := range webseeds {
 		reply.Webseeds[i] = rpctypes.Webseed{
 			URL:           p.URL,
+			Trusted:       p.Trusted,
 			DownloadSpeed: p.DownloadSpeed,
 		}
 		if p.Error != nil {
@@ -356,6 +429,62 @@ func (h *rpcHandler) GetTorrentWebseeds(args *rpctypes.GetTorrentWebseedsRequest
 	return nil
 }
 
+func (h *rpcHandler) GetTorrentFiles(args *rpctypes.GetTorrentFilesRequest, reply *rpctypes.GetTorrentFilesResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	files := t.Files()
+	reply.Files = make([]rpctypes.File, len(files))
+	for i, f := range files {
+		reply.Files[i] = rpctypes.File{
+			Path:     f.Path,
+			Length:   f.Length,
+			Padding:  f.Padding,
+			Priority: int(f.Priority),
+		}
+	}
+	return nil
+}
+
+func (h *rpcHandler) SetFilePriority(args *rpctypes.SetFilePriorityRequest, reply *rpctypes.SetFilePriorityResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	return t.SetFilePriority(args.Index, FilePriority(args.Priority))
+}
+
+func (h *rpcHandler) GetTorrentSwarmStats(args *rpctypes.GetTorrentSwarmStatsRequest, reply *rpctypes.GetTorrentSwarmStatsResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	s := t.SwarmStats()
+	reply.SwarmStats = rpctypes.SwarmStats{
+		Seeders:           s.Seeders,
+		Leechers:          s.Leechers,
+		TrackerSeeders:    s.TrackerSeeders,
+		TrackerLeechers:   s.TrackerLeechers,
+		ConnectedSeeders:  s.ConnectedSeeders,
+		ConnectedLeechers: s.ConnectedLeechers,
+	}
+	return nil
+}
+
+func (h *rpcHandler) GetTorrentPieceProgress(args *rpctypes.GetTorrentPieceProgressRequest, reply *rpctypes.GetTorrentPieceProgressResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	done, total := t.PieceProgress(args.Index)
+	reply.PieceProgress = rpctypes.PieceProgress{
+		BlocksDone:  done,
+		BlocksTotal: total,
+	}
+	return nil
+}
+
 func (h *rpcHandler) StartTorrent(args *rpctypes.StartTorrentRequest, reply *rpctypes.StartTorrentResponse) error {
 	t := h.session.GetTorrent(args.ID)
 	if t == nil {
@@ -372,6 +501,24 @@ func (h *rpcHandler) StopTorrent(args *rpctypes.StopTorrentRequest, reply *rpcty
 	return t.Stop()
 }
 
+func (h *rpcHandler) PauseTorrent(args *rpctypes.PauseTorrentRequest, reply *rpctypes.PauseTorrentResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	t.Pause()
+	return nil
+}
+
+func (h *rpcHandler) ResumeTorrent(args *rpctypes.ResumeTorrentRequest, reply *rpctypes.ResumeTorrentResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	t.Resume()
+	return nil
+}
+
 func (h *rpcHandler) AnnounceTorrent(args *rpctypes.AnnounceTorrentRequest, reply *rpctypes.AnnounceTorrentResponse) error {
 	t := h.session.GetTorrent(args.ID)
 	if t == nil {
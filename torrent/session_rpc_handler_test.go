@@ -0,0 +1,87 @@
+package torrent
+
+import (
+	"io"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/cenkalti/rain/rainrpc"
+)
+
+func TestRPCAddRemoveTorrent(t *testing.T) {
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	s.config.RPCAuthToken = "secret"
+	srv := httptest.NewServer(newRPCServer(s).httpServer.Handler)
+	defer srv.Close()
+
+	clt := rainrpc.NewClient(srv.URL)
+	defer clt.Close()
+	_, err := clt.ListTorrents()
+	if err == nil {
+		t.Fatal("request without token must fail")
+	}
+	clt.SetAuthToken("secret")
+
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tor, err := clt.AddTorrent(f, &rainrpc.AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tor.Name != torrentName {
+		t.Fatalf("invalid name: %q", tor.Name)
+	}
+	files, err := clt.GetTorrentFiles(tor.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no files")
+	}
+	err = clt.SetFilePriority(tor.ID, 0, int(FilePrioritySkip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := s.GetTorrent(tor.ID).Files()[0].Priority; p != FilePrioritySkip {
+		t.Fatalf("invalid priority: %d", p)
+	}
+	torrents, err := clt.ListTorrents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(torrents) != 1 || torrents[0].ID != tor.ID {
+		t.Fatalf("invalid torrent list: %v", torrents)
+	}
+	err = clt.RemoveTorrent(tor.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.GetTorrent(tor.ID) != nil {
+		t.Fatal("torrent is not removed")
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor, err = clt.AddTorrent(f, &rainrpc.AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Torrent has never announced, so there is no tracker to send the stopped event.
+	announced, err := clt.RemoveTorrentWait(tor.ID, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !announced {
+		t.Fatal("stopped event must be reported as announced")
+	}
+	if s.GetTorrent(tor.ID) != nil {
+		t.Fatal("torrent is not removed")
+	}
+}
@@ -2,6 +2,7 @@ package torrent
 
 import (
 	"context"
+	"crypto/subtle"
 	"expvar"
 	"net"
 	"net/http"
@@ -29,10 +30,15 @@ func newRPCServer(ses *Session) *rpcServer {
 	mux.HandleFunc("/move-torrent", h.handleMoveTorrent)
 	mux.Handle("/", jsonrpc2.HTTPHandler(srv))
 
+	var handler http.Handler = mux
+	if ses.config.RPCAuthToken != "" {
+		handler = authHandler(ses.config.RPCAuthToken, mux)
+	}
+
 	return &rpcServer{
 		rpcServer: srv,
 		httpServer: http.Server{
-			Handler: mux,
+			Handler: handler,
 		},
 		log: logger.New("rpc server"),
 	}
@@ -63,3 +69,16 @@ func (s *rpcServer) Stop(timeout time.Duration) error {
 	defer cancel()
 	return s.httpServer.Shutdown(ctx)
 }
+
+// authHandler rejects the requests that do not have the bearer token in Authorization header.
+func authHandler(token string, h http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,113 @@
+package torrent
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Addresses of network interfaces are listed again after this duration, so they are not listed on every dial.
+const interfaceAddrsTTL = time.Minute
+
+// sourceAddrDialer connects to peers from one of the configured local addresses.
+// On a host with multiple network interfaces, the address that can route to the peer is chosen,
+// so that replies come back on the same interface. Default route of the OS is used when it is unclear.
+type sourceAddrDialer struct {
+	addrs []net.IP
+	// route returns the local address that the destination is reachable from.
+	route func(dst net.IP) net.IP
+
+	mInterfaceAddrs        sync.Mutex
+	interfaceAddrs         []net.Addr
+	interfaceAddrsListedAt time.Time
+}
+
+func newSourceAddrDialer(addrs []string) (*sourceAddrDialer, error) {
+	d := &sourceAddrDialer{}
+	d.route = d.routeSource
+	for _, s := range addrs {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid peer source address: %q", s)
+		}
+		d.addrs = append(d.addrs, ip)
+	}
+	return d, nil
+}
+
+func (d *sourceAddrDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var nd net.Dialer
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if src := d.sourceFor(net.ParseIP(host)); src != nil {
+		nd.LocalAddr = &net.TCPAddr{IP: src}
+	}
+	return nd.DialContext(ctx, network, addr)
+}
+
+// sourceFor returns the local address to dial the destination from. Returns nil if the default route should be used.
+func (d *sourceAddrDialer) sourceFor(dst net.IP) net.IP {
+	if dst == nil {
+		return nil
+	}
+	var candidates []net.IP
+	for _, ip := range d.addrs {
+		if (ip.To4() == nil) == (dst.To4() == nil) {
+			candidates = append(candidates, ip)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return nil
+	case 1:
+		return candidates[0]
+	}
+	routed := d.route(dst)
+	if routed == nil {
+		return nil
+	}
+	for _, ip := range candidates {
+		if ip.Equal(routed) {
+			return ip
+		}
+	}
+	return nil
+}
+
+// routeSource returns the local address of the interface whose network contains the destination.
+// If the destination is not on a directly connected network, the source address that the OS picks for it is returned.
+func (d *sourceAddrDialer) routeSource(dst net.IP) net.IP {
+	for _, a := range d.getInterfaceAddrs() {
+		if n, ok := a.(*net.IPNet); ok && !n.IP.IsLoopback() && n.Contains(dst) {
+			return n.IP
+		}
+	}
+	// No packets are sent when connecting a UDP socket. It only selects the route.
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: dst, Port: 9})
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP
+}
+
+// getInterfaceAddrs returns the cached addresses of network interfaces. The cache is refreshed after interfaceAddrsTTL.
+func (d *sourceAddrDialer) getInterfaceAddrs() []net.Addr {
+	d.mInterfaceAddrs.Lock()
+	defer d.mInterfaceAddrs.Unlock()
+	if d.interfaceAddrs != nil && time.Since(d.interfaceAddrsListedAt) < interfaceAddrsTTL {
+		return d.interfaceAddrs
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		// Keep using the previous list until the interfaces can be listed again.
+		return d.interfaceAddrs
+	}
+	d.interfaceAddrs = addrs
+	d.interfaceAddrsListedAt = time.Now()
+	return addrs
+}
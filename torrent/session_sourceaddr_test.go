@@ -0,0 +1,63 @@
+package torrent
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestPeerSourceAddress(t *testing.T) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	d, err := newSourceAddrDialer([]string{"127.0.0.2", "127.0.0.3", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var routed net.IP
+	d.route = func(dst net.IP) net.IP {
+		if !dst.Equal(net.IPv4(127, 0, 0, 1)) {
+			t.Fatalf("unexpected destination: %s", dst)
+		}
+		return routed
+	}
+	dialFrom := func() net.IP {
+		conn, err := d.DialContext(context.Background(), "tcp4", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn2, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn2.Close()
+		return conn2.RemoteAddr().(*net.TCPAddr).IP
+	}
+
+	routed = net.ParseIP("127.0.0.3")
+	if ip := dialFrom(); !ip.Equal(routed) {
+		t.Fatalf("connection must be made from the routed address, got %s", ip)
+	}
+	routed = net.ParseIP("127.0.0.2")
+	if ip := dialFrom(); !ip.Equal(routed) {
+		t.Fatalf("connection must be made from the routed address, got %s", ip)
+	}
+	// Route is not one of the configured addresses, default route is used.
+	routed = net.ParseIP("10.0.0.1")
+	if ip := dialFrom(); !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Fatalf("connection must be made from the default address, got %s", ip)
+	}
+	routed = nil
+	if ip := dialFrom(); !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Fatalf("connection must be made from the default address, got %s", ip)
+	}
+
+	_, err = newSourceAddrDialer([]string{"foo"})
+	if err == nil {
+		t.Fatal("invalid address must be rejected")
+	}
+}
@@ -1,12 +1,6 @@
 package torrent
 
-import (
-	"strconv"
-	"time"
-
-	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
-	"go.etcd.io/bbolt"
-)
+import "time"
 
 // SessionStats contains statistics about Session.
 type SessionStats struct {
@@ -23,6 +17,10 @@ type SessionStats struct {
 	BlockListRules int
 	// Time elapsed after the last successful update of blocklist.
 	BlockListRecency time.Duration
+	// Number of incoming and outgoing connections rejected because the peer IP is in blocklist.
+	BlockListRejected int
+	// Number of incoming connections rejected because of MaxPeerAccept, MaxPendingIncomingHandshakes or connection limits.
+	IncomingRejected int
 
 	// Number of objects in piece read cache.
 	// Each object is a block whose size is defined in Config.ReadCacheBlockSize.
@@ -74,8 +72,10 @@ func (s *Session) Stats() SessionStats {
 		Peers:          int(s.metrics.Peers.Count()),
 		PortsAvailable: int(s.metrics.PortsAvailable.Value()),
 
-		BlockListRules:   int(s.metrics.BlockListRules.Value()),
-		BlockListRecency: time.Duration(s.metrics.BlockListRecency.Value()) * time.Second,
+		BlockListRules:    int(s.metrics.BlockListRules.Value()),
+		BlockListRecency:  time.Duration(s.metrics.BlockListRecency.Value()) * time.Second,
+		BlockListRejected: int(s.metrics.BlockListRejected.Count()),
+		IncomingRejected:  int(s.metrics.IncomingRejected.Count()),
 
 		ReadCacheObjects:     int(s.metrics.ReadCacheObjects.Value()),
 		ReadCacheSize:        s.metrics.ReadCacheSize.Value(),
@@ -116,26 +116,23 @@ func (s *Session) updateStatsLoop() {
 func (s *Session) updateStats() {
 	s.mTorrents.RLock()
 	defer s.mTorrents.RUnlock()
-	err := s.db.Update(func(tx *bbolt.Tx) error {
-		mb := tx.Bucket(torrentsBucket)
-		for _, t := range s.torrents {
-			b := mb.Bucket([]byte(t.torrent.id))
-			_ = b.Put(boltdbresumer.Keys.BytesDownloaded, []byte(strconv.FormatInt(t.torrent.bytesDownloaded.Count(), 10)))
-			_ = b.Put(boltdbresumer.Keys.BytesUploaded, []byte(strconv.FormatInt(t.torrent.bytesUploaded.Count(), 10)))
-			_ = b.Put(boltdbresumer.Keys.BytesWasted, []byte(strconv.FormatInt(t.torrent.bytesWasted.Count(), 10)))
-			_ = b.Put(boltdbresumer.Keys.SeededFor, []byte(time.Duration(t.torrent.seededFor.Count()).String()))
-
-			t.torrent.mBitfield.RLock()
-			if t.torrent.bitfield != nil {
-				_ = b.Put(boltdbresumer.Keys.Bitfield, t.torrent.bitfield.Bytes())
+	for _, t := range s.torrents {
+		stats := t.torrent.resumeStats()
+		// Bitfield is saved only if pieces are downloaded since the last save.
+		// Stats are saved together with it, so they match the saved pieces.
+		var err error
+		t.torrent.mBitfield.Lock()
+		if t.torrent.bitfield != nil && t.torrent.bitfieldDirty {
+			err = s.resumer.WriteBitfield(t.torrent.id, t.torrent.bitfield.Bytes(), nil, stats)
+			if err == nil {
+				t.torrent.bitfieldDirty = false
 			}
+		} else {
+			err = s.resumer.WriteStats(t.torrent.id, stats)
+		}
+		t.torrent.mBitfield.Unlock()
+		if err != nil {
+			s.log.Errorln("cannot update stats:", err.Error())
 		}
-		return nil
-	})
-	for _, t := range s.torrents {
-		t.torrent.mBitfield.RUnlock()
-	}
-	if err != nil {
-		s.log.Errorln("cannot update stats:", err.Error())
 	}
 }
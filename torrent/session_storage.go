@@ -0,0 +1,45 @@
+package torrent
+
+import (
+	"path/filepath"
+
+	"github.com/cenkalti/rain/internal/storage"
+	"github.com/cenkalti/rain/internal/storage/filestorage"
+	"github.com/cenkalti/rain/internal/storage/memorystorage"
+)
+
+// Storage is the interface for reading and writing the files of a torrent.
+// Custom backends can be plugged in with Config.StorageProvider.
+type Storage = storage.Storage
+
+// StorageFile is a file opened by Storage.
+type StorageFile = storage.File
+
+// NewMemoryStorage returns a Storage that keeps files in memory.
+// Contents are lost when the process exits.
+func NewMemoryStorage() Storage {
+	return memorystorage.New()
+}
+
+// newStorage returns the storage of the torrent with id.
+// Files are saved on disk under Config.DataDir if Config.StorageProvider is not set.
+// If location is not empty, files are saved under location instead.
+func (s *Session) newStorage(id, location string) (storage.Storage, error) {
+	if s.config.StorageProvider != nil {
+		return s.config.StorageProvider(id)
+	}
+	if location != "" {
+		return s.newFileStorage(location)
+	}
+	var dest string
+	if s.config.DataDirIncludesTorrentID {
+		dest = filepath.Join(s.config.DataDir, id)
+	} else {
+		dest = s.config.DataDir
+	}
+	return s.newFileStorage(dest)
+}
+
+func (s *Session) newFileStorage(dest string) (*filestorage.FileStorage, error) {
+	return filestorage.New(dest, s.fileCache, s.config.UseMmap, s.config.PreallocateFiles)
+}
@@ -0,0 +1,66 @@
+package torrent
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	sto := NewMemoryStorage()
+	s.config.StorageProvider = func(string) (Storage, error) { return sto, nil }
+
+	tor := addTorrentFile(t, s, nil)
+	tor.AddPeer(addr)
+	select {
+	case <-tor.torrent.NotifyComplete():
+	case err := <-tor.torrent.NotifyError():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		t.Fatal("download did not finish")
+	}
+	for _, mf := range tor.torrent.info.Files {
+		expected, err := ioutil.ReadFile(filepath.Join(torrentDataDir, mf.Path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sf, _, err := sto.Open(mf.Path, mf.Length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, mf.Length)
+		if _, err = sf.ReadAt(b, 0); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, expected) {
+			t.Fatalf("contents of %s do not match", mf.Path)
+		}
+	}
+	allocated, err := sto.Allocated()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total := tor.Stats().Bytes.Total; allocated != total {
+		t.Fatalf("allocated %d bytes, torrent size is %d", allocated, total)
+	}
+
+	tor.Stop()
+	waitStatus(t, tor, Stopped)
+	if err = tor.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	// Torrent is stopped again after manual verification.
+	waitStatus(t, tor, Stopped)
+	if st := tor.Stats(); st.Pieces.Have != st.Pieces.Total {
+		t.Fatalf("verified %d of %d pieces", st.Pieces.Have, st.Pieces.Total)
+	}
+}
@@ -0,0 +1,58 @@
+package torrent
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+)
+
+func TestDownloadPeerProxy(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	// Address is not reachable without the proxy.
+	const peerIP = "192.0.2.1"
+	proxyAddr, closeProxy := socksForwarder(t, peerIP, addr)
+	defer closeProxy()
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmp, closeTmp := tempdir(t)
+	defer closeTmp()
+	cfg := DefaultConfig
+	cfg.Database = filepath.Join(tmp, "session.db")
+	cfg.DataDir = tmp
+	cfg.DHTEnabled = false
+	cfg.LSDEnabled = false
+	cfg.PEXEnabled = false
+	cfg.RPCEnabled = false
+	cfg.PeerProxy = proxyAddr
+	cfg.ForceOutgoingEncryption = true
+	s, err := NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	tor := addTorrentFile(t, s, nil)
+	tor.torrent.trackers = nil
+	if err = tor.AddPeer(net.JoinHostPort(peerIP, port)); err != nil {
+		t.Fatal(err)
+	}
+	assertCompleted(t, tor)
+}
+
+func TestNegativeMaxRecentConnections(t *testing.T) {
+	tmp, closeTmp := tempdir(t)
+	defer closeTmp()
+	cfg := DefaultConfig
+	cfg.Database = filepath.Join(tmp, "session.db")
+	cfg.DataDir = tmp
+	cfg.MaxRecentConnections = -1
+	if _, err := NewSession(cfg); err == nil {
+		t.Fatal("session must not be created with negative max recent connections")
+	}
+}
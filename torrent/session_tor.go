@@ -0,0 +1,32 @@
+package torrent
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/cenkalti/rain/internal/onion"
+	"github.com/cenkalti/rain/internal/socks5"
+)
+
+var errOnionWithoutTor = errors.New("onion peers can only be reached over Tor, set TorProxy in config")
+
+// torPeerDialer connects to peers through the SOCKS5 port of Tor.
+// Mapped IP addresses of onion peers and peers added with host names are converted back to their host names before dialing.
+type torPeerDialer struct {
+	proxy  *socks5.Dialer
+	onions *onion.Map
+}
+
+func (d *torPeerDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip != nil && onion.IsMapped(ip) {
+		if name, ok := d.onions.Host(ip); ok {
+			addr = net.JoinHostPort(name, port)
+		}
+	}
+	return d.proxy.DialContext(ctx, network, addr)
+}
@@ -0,0 +1,91 @@
+package torrent
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+)
+
+func TestDownloadOnionPeer(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	const onionHost = "rainseeder.onion"
+	proxyAddr, closeProxy := socksForwarder(t, onionHost, addr)
+	defer closeProxy()
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Onion peers cannot be added without Tor.
+	s1, closeSession1 := newTestSession(t)
+	defer closeSession1()
+	tor := addTorrentFile(t, s1, nil)
+	if err = tor.AddPeer(net.JoinHostPort(onionHost, port)); err != errOnionWithoutTor {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tmp, closeTmp := tempdir(t)
+	defer closeTmp()
+	cfg := DefaultConfig
+	cfg.Database = filepath.Join(tmp, "session.db")
+	cfg.DataDir = tmp
+	cfg.PEXEnabled = false
+	cfg.LSDEnabled = true
+	cfg.RPCEnabled = false
+	cfg.TorProxy = proxyAddr
+	s2, err := NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s2.Close()
+	if s2.dht != nil || s2.lsd != nil {
+		t.Fatal("DHT and LSD must be disabled when Tor is used")
+	}
+	if !s2.config.DHTEnabled || !s2.config.LSDEnabled {
+		t.Fatal("config must not be modified")
+	}
+	tor = addTorrentFile(t, s2, nil)
+	tor.torrent.trackers = nil
+	if err = tor.AddPeer(net.JoinHostPort(onionHost, port)); err != nil {
+		t.Fatal(err)
+	}
+	assertCompleted(t, tor)
+}
+
+func TestDownloadHostPeerOverTor(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	// Host name cannot be resolved locally, so the download fails if it is not resolved by Tor.
+	const peerHost = "rainseeder.invalid"
+	proxyAddr, closeProxy := socksForwarder(t, peerHost, addr)
+	defer closeProxy()
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmp, closeTmp := tempdir(t)
+	defer closeTmp()
+	cfg := DefaultConfig
+	cfg.Database = filepath.Join(tmp, "session.db")
+	cfg.DataDir = tmp
+	cfg.PEXEnabled = false
+	cfg.RPCEnabled = false
+	cfg.TorProxy = proxyAddr
+	s, err := NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	tor := addTorrentFile(t, s, nil)
+	tor.torrent.trackers = nil
+	if err = tor.AddPeer(net.JoinHostPort(peerHost, port)); err != nil {
+		t.Fatal(err)
+	}
+	assertCompleted(t, tor)
+}
@@ -2,6 +2,7 @@ package torrent
 
 import (
 	"archive/tar"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -14,7 +15,6 @@ import (
 
 	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
 	"github.com/cenkalti/rain/internal/tracker"
-	"go.etcd.io/bbolt"
 )
 
 // Torrent is created from a torrent file or a magnet link.
@@ -36,8 +36,8 @@ func (t *Torrent) ID() string {
 }
 
 // Name of the torrent.
-// For magnet downloads name can change after metadata is downloaded but this method still returns the initial name.
-// Use Stats() method to get name in info dictionary.
+// For magnet downloads, the display name in the link is returned until the metadata is downloaded.
+// If the link does not contain a display name, hex encoded info hash is returned.
 func (t *Torrent) Name() string {
 	return t.torrent.Name()
 }
@@ -55,11 +55,32 @@ func (t *Torrent) AddedAt() time.Time {
 	return t.torrent.addedAt
 }
 
+// Metainfo contains the optional fields of a .torrent file that are outside of the info dictionary.
+type Metainfo struct {
+	// Zero value if the field is not present in the torrent file.
+	CreationDate time.Time
+	Comment      string
+	CreatedBy    string
+	Encoding     string
+}
+
+// Metainfo returns the optional fields read from the .torrent file.
+// All fields are empty if the torrent is added from a magnet link.
+func (t *Torrent) Metainfo() Metainfo {
+	return t.torrent.meta
+}
+
 // Stats returns statistics about the torrent.
 func (t *Torrent) Stats() Stats {
 	return t.torrent.Stats()
 }
 
+// Availability returns the number of complete copies of the torrent in the connected swarm.
+// See Stats.Pieces.Availability for details.
+func (t *Torrent) Availability() float64 {
+	return t.torrent.Stats().Pieces.Availability
+}
+
 // Magnet returns the magnet link.
 // Returns error if torrent is private.
 func (t *Torrent) Magnet() (string, error) {
@@ -82,6 +103,30 @@ func (t *Torrent) Peers() []Peer {
 	return t.torrent.Peers()
 }
 
+// RecentConnections returns the outcomes of the recent incoming and outgoing connections, oldest first.
+// Failed connections include the stage of the handshake that has failed.
+// Number of returned connections is limited by Config.MaxRecentConnections.
+func (t *Torrent) RecentConnections() []ConnectionAttempt {
+	return t.torrent.RecentConnections()
+}
+
+// SwarmStats returns the estimated size of the swarm by combining the numbers from trackers and connected peers.
+func (t *Torrent) SwarmStats() SwarmStats {
+	return t.torrent.SwarmStats()
+}
+
+// PieceProgress returns the number of received blocks and the total number of blocks of a piece that is being downloaded.
+// Both values are zero if the piece is not currently being downloaded.
+func (t *Torrent) PieceProgress(index uint32) (blocksDone, blocksTotal int) {
+	return t.torrent.PieceProgress(index)
+}
+
+// PieceMap returns a snapshot of the downloaded pieces and the pieces that are being downloaded, for drawing a piece map.
+// The returned value is a copy and can be used without synchronization.
+func (t *Torrent) PieceMap() PieceMap {
+	return t.torrent.PieceMap()
+}
+
 // Webseeds returns the list of WebSeed sources in the torrent.
 func (t *Torrent) Webseeds() []Webseed {
 	return t.torrent.Webseeds()
@@ -106,6 +151,15 @@ func (t *Torrent) NotifyComplete() <-chan struct{} {
 	return t.torrent.NotifyComplete()
 }
 
+// Events returns a channel for receiving lifecycle events of the torrent in the order they happen.
+// The same channel is returned on every call, so each event is received by only one receiver.
+// Events are not dropped. They are queued in memory until they are received, so the channel should be drained.
+// Events that are not received yet are discarded when the channel is closed.
+// The channel is closed when the torrent is removed from the session or the session is closed.
+func (t *Torrent) Events() <-chan Event {
+	return t.torrent.eventsC
+}
+
 // AddPeer adds a new peer to the torrent. Does nothing if torrent is stopped.
 func (t *Torrent) AddPeer(addr string) error {
 	return t.torrent.addPeerString(addr)
@@ -121,21 +175,11 @@ func (t *Torrent) AddTracker(uri string) error {
 	if err != nil {
 		return err
 	}
-	err = t.torrent.session.db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(torrentsBucket).Bucket([]byte(t.torrent.id))
-		value := b.Get(boltdbresumer.Keys.Trackers)
-		var trackers [][]string
-		err = json.Unmarshal(value, &trackers)
-		if err != nil {
-			return err
-		}
-		trackers = append(trackers, []string{uri})
-		value, err = json.Marshal(trackers)
-		if err != nil {
-			return err
-		}
-		return b.Put(boltdbresumer.Keys.Trackers, value)
-	})
+	spec, err := t.torrent.session.resumer.Read(t.torrent.id)
+	if err != nil {
+		return err
+	}
+	err = t.torrent.session.resumer.WriteTrackers(t.torrent.id, append(spec.Trackers, []string{uri}))
 	if err != nil {
 		return err
 	}
@@ -165,19 +209,80 @@ func (t *Torrent) Stop() error {
 	return nil
 }
 
-// Announce the torrent to all trackers and DHT. It does not overrides the minimum interval value sent by the trackers or set in Config.
+// Pause stops requesting and uploading pieces without disconnecting peers or announcing to trackers.
+// Only a torrent in Downloading or Seeding status can be paused. Paused status is not saved,
+// the torrent is started normally after the session is restarted.
+func (t *Torrent) Pause() {
+	t.torrent.Pause()
+}
+
+// Resume continues downloading and uploading of a paused torrent.
+// If the torrent is in DiskError status, pieces that could not be written are downloaded again.
+func (t *Torrent) Resume() {
+	t.torrent.Resume()
+}
+
+// Announce the torrent to all trackers, DHT and LSD now.
+// Sources that are announced within the minimum interval sent by the tracker or set in Config are not announced again,
+// so calling Announce multiple times in a short period results in a single announce.
 func (t *Torrent) Announce() {
 	t.torrent.Announce()
 }
 
+// SetAnnounceInterval overrides the interval between announces returned from trackers. Useful for testing.
+// The minimum interval sent by the tracker or set in Config is still respected. Zero value removes the override.
+// The override is not saved and is lost when the session is closed.
+func (t *Torrent) SetAnnounceInterval(d time.Duration) {
+	t.torrent.SetAnnounceInterval(d)
+}
+
+// Files returns the list of files in the torrent with their priorities.
+// Returns nil if the metadata of a magnet link is not downloaded yet.
+func (t *Torrent) Files() []File {
+	return t.torrent.Files()
+}
+
+// SetFilePriority sets the priority of the file at index in the file list of the torrent.
+// Pieces of skipped files are not downloaded, unless they contain data of another file that is not skipped,
+// and skipped files are not created on disk. Pieces of high priority files are downloaded before others.
+// A completed torrent starts downloading again if a skipped file is changed to be downloaded.
+// Returns error if the metadata of a magnet link is not downloaded yet.
+func (t *Torrent) SetFilePriority(index int, priority FilePriority) error {
+	return t.torrent.SetFilePriority(index, priority)
+}
+
+// SetSequential sets whether the pieces are downloaded in order, so the beginning of the files can be used
+// before the torrent is completed, e.g. for streaming. See Config.SequentialWindow.
+func (t *Torrent) SetSequential(value bool) {
+	t.torrent.SetSequential(value)
+}
+
+// SetSuperSeeding enables super-seeding mode (BEP 16) for initial seeders.
+// After the torrent is completed, new peers are told that we have no pieces, then pieces are revealed to each peer one at a time.
+// A new piece is revealed to a peer after its previous piece is seen on another peer.
+// Peers that are already connected are not affected. Super-seeding is disabled automatically
+// when all pieces are available from connected peers. The setting is not saved in resume data.
+func (t *Torrent) SetSuperSeeding(value bool) {
+	t.torrent.SetSuperSeeding(value)
+}
+
+// SetSpeedLimitDownload limits the download speed of the torrent to rate bytes per second. 0 means unlimited.
+// The limit of the session is applied in addition to the limit of the torrent. The limit is not saved in resume data.
+func (t *Torrent) SetSpeedLimitDownload(rate int64) {
+	t.torrent.limitDownload.SetRate(rate)
+}
+
+// SetSpeedLimitUpload limits the upload speed of the torrent to rate bytes per second. 0 means unlimited.
+// The limit of the session is applied in addition to the limit of the torrent. The limit is not saved in resume data.
+func (t *Torrent) SetSpeedLimitUpload(rate int64) {
+	t.torrent.limitUpload.SetRate(rate)
+}
+
 // Verify pieces of torrent by reading all of the torrents files from disk.
 // After Verify called, the torrent is stopped, then verification starts and the torrent switches into Verifying state.
 // The torrent stays stopped after verification finishes.
 func (t *Torrent) Verify() error {
-	err := t.torrent.session.db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(torrentsBucket).Bucket([]byte(t.torrent.id))
-		return b.Delete([]byte("bitfield"))
-	})
+	err := t.torrent.session.resumer.DeleteBitfield(t.torrent.id)
 	if err != nil {
 		return err
 	}
@@ -185,6 +290,43 @@ func (t *Torrent) Verify() error {
 	return nil
 }
 
+// Check reads the files of the torrent from disk and compares the pieces with the hashes in the torrent.
+// Unlike Verify, peers and trackers are not contacted and the state of the torrent is not changed.
+// Files that do not exist are not created. The torrent must be stopped and its metadata must be known.
+// Starting the torrent interrupts the check. Returns ctx.Err() if ctx is done before the check finishes.
+func (t *Torrent) Check(ctx context.Context) (*VerifyResult, error) {
+	return t.torrent.Check(ctx)
+}
+
+// SetLocation moves the files of the torrent to the directory at path and continues using them from there.
+// The torrent is stopped while moving and started again after the files are moved.
+// Files are renamed if possible, otherwise they are copied and removed after all of them are copied.
+// Pieces that are already verified do not need to be checked again.
+// If moving fails or ctx is done before it finishes, the files are left in the old location.
+func (t *Torrent) SetLocation(ctx context.Context, path string) error {
+	return t.torrent.SetLocation(ctx, path)
+}
+
+// SetPieceDeadline makes the piece at index downloaded before the other pieces, nearest deadline first.
+// The piece is downloaded even if it is in a skipped file.
+// EventPieceDeadlineMissed is sent to the channel returned from Events if the deadline cannot be met at the current download speed.
+// The deadline is cleared when the piece is downloaded. Zero deadline clears it like ClearPieceDeadline.
+func (t *Torrent) SetPieceDeadline(index uint32, deadline time.Time) error {
+	return t.torrent.SetPieceDeadline(index, deadline)
+}
+
+// ClearPieceDeadline removes the deadline of the piece at index set with SetPieceDeadline.
+func (t *Torrent) ClearPieceDeadline(index uint32) error {
+	return t.torrent.SetPieceDeadline(index, time.Time{})
+}
+
+// DownloadPieces downloads the pieces at indices before other pieces, even if they contain data of skipped files.
+// EventPiecesDownloaded is sent to the channel returned from Events when all of them are downloaded.
+// If all pieces are already downloaded, the event is sent immediately.
+func (t *Torrent) DownloadPieces(indices []uint32) error {
+	return t.torrent.DownloadPieces(indices)
+}
+
 // Move torrent to another Session.
 // target must be the RPC server address in host:port form.
 func (t *Torrent) Move(target string) error {
@@ -0,0 +1,82 @@
+package torrent
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/fortytw2/leaktest"
+)
+
+func TestPieceProgress(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	numPieces := tor.torrent.info.NumPieces
+	tor.Start()
+	var port int
+	select {
+	case port = <-tor.torrent.NotifyListen():
+	case <-time.After(timeout):
+		t.Fatal("torrent is not listening")
+	}
+
+	// Connect as a seeder that sends only the first requested block.
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	var peerID [20]byte
+	copy(peerID[:], "-XX0000-000000000000")
+	conn, _, _, _, err := btconn.Dial(addr, nil, time.Second, time.Second, false, false, [8]byte{}, tor.torrent.infoHash, peerID, make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	bf := bitfield.New(numPieces)
+	for i := uint32(0); i < numPieces; i++ {
+		bf.Set(i)
+	}
+	writePeerMessage(t, conn, peerprotocol.Bitfield, bf.Bytes())
+	writePeerMessage(t, conn, peerprotocol.Unchoke, nil)
+	var index uint32
+	for {
+		var length uint32
+		if err = binary.Read(conn, binary.BigEndian, &length); err != nil {
+			t.Fatal(err)
+		}
+		msg := make([]byte, length)
+		if _, err = io.ReadFull(conn, msg); err != nil {
+			t.Fatal(err)
+		}
+		if length == 0 || peerprotocol.MessageID(msg[0]) != peerprotocol.Request {
+			continue
+		}
+		index = binary.BigEndian.Uint32(msg[1:5])
+		begin := binary.BigEndian.Uint32(msg[5:9])
+		blockLength := binary.BigEndian.Uint32(msg[9:13])
+		payload := make([]byte, 8+blockLength)
+		copy(payload, msg[1:9])
+		writePeerMessage(t, conn, peerprotocol.Piece, payload)
+		if begin != 0 {
+			t.Fatalf("first requested block must be at the beginning of piece, begin: %d", begin)
+		}
+		break
+	}
+
+	waitFor(t, "block is not received", func() bool {
+		done, _ := tor.PieceProgress(index)
+		return done == 1
+	})
+	if _, total := tor.PieceProgress(index); total <= 1 {
+		t.Fatalf("invalid number of blocks in piece: %d", total)
+	}
+	done, total := tor.PieceProgress(index + 1)
+	if done != 0 || total != 0 {
+		t.Fatalf("piece is not being downloaded but progress is %d/%d", done, total)
+	}
+}
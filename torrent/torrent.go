@@ -2,6 +2,7 @@ package torrent
 
 import (
 	"crypto/rand"
+	"encoding/binary"
 	"errors"
 	"net"
 	"net/http"
@@ -21,6 +22,7 @@ import (
 	"github.com/cenkalti/rain/internal/infodownloader"
 	"github.com/cenkalti/rain/internal/logger"
 	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/mover"
 	"github.com/cenkalti/rain/internal/mse"
 	"github.com/cenkalti/rain/internal/peer"
 	"github.com/cenkalti/rain/internal/pexlist"
@@ -29,6 +31,7 @@ import (
 	"github.com/cenkalti/rain/internal/piecepicker"
 	"github.com/cenkalti/rain/internal/piecewriter"
 	"github.com/cenkalti/rain/internal/resumer"
+	"github.com/cenkalti/rain/internal/speedlimit"
 	"github.com/cenkalti/rain/internal/storage"
 	"github.com/cenkalti/rain/internal/suspendchan"
 	"github.com/cenkalti/rain/internal/tracker"
@@ -54,8 +57,50 @@ type torrent struct {
 	// Peers added from magnet URLS with x.pe parameter.
 	fixedPeers []string
 
+	// Addresses of productive peers, most recent first. Saved in resume data.
+	resumePeers       []string
+	resumePeersMarked map[*peer.Peer]struct{}
+	// Number of consecutive failed connections to resume peers, keyed by address. Saved in resume data.
+	resumePeerFailures map[string]int
+
+	// Indexes of downloaded blocks of incomplete pieces, keyed by piece index. Saved in resume data.
+	// Data of these blocks are written to files when the torrent is stopped.
+	partialPieces map[uint32][]int
+
+	// Pieces that are being written with blocks received from multiple peers.
+	// Source of a corrupt piece is not known, so the peer is not banned.
+	mixedPieces map[uint32]struct{}
+
+	// Priorities of files, indexed same as info.Files. Nil means all files have normal priority.
+	filePriorities []FilePriority
+
+	// Pieces requested with DownloadPieces that are not downloaded yet, one slice for each call.
+	pieceRequests [][]uint32
+
+	// Deadlines of the pieces set with SetPieceDeadline that are not downloaded yet, keyed by piece index.
+	pieceDeadlines map[uint32]pieceDeadline
+
+	// Pieces that contain only skipped files. Nil if no piece is skipped. Calculated from filePriorities after pieces are created.
+	skippedPieces *bitfield.Bitfield
+
+	// Download pieces in order. Changed with SetSequential().
+	sequential bool
+
+	// Reveal pieces to new peers one by one after the torrent is completed (BEP 16). Changed with SetSuperSeeding().
+	superSeeding bool
+	// Peers that are connected while super-seeding. They know only the pieces revealed to them.
+	superSeedPeers map[*peer.Peer]*superSeedPeer
+	// Pieces that connected peers are seen to have while super-seeding. Nil if super-seeding is not active.
+	superSeedSwarm *bitfield.Bitfield
+
+	// Speed limits of the torrent. Bytes taken from these are also taken from the limits of the session.
+	limitDownload *speedlimit.Limiter
+	limitUpload   *speedlimit.Limiter
+
 	// Name of the torrent.
-	name string
+	// For magnet downloads, it is the display name in the link until the metadata is downloaded.
+	name  string
+	mName sync.RWMutex
 
 	// Storage implementation to save the files in torrent.
 	storage storage.Storage
@@ -69,12 +114,42 @@ type torrent struct {
 	// Bitfield for pieces we have. It is created after we got info.
 	bitfield *bitfield.Bitfield
 
+	// Stats of the files when the bitfield is saved to resume db.
+	// If the files are not changed on the next start, the saved bitfield is used without reading the pieces.
+	fileStats []verifier.FileStat
+
+	// Optional fields in the .torrent file. Empty for magnet downloads.
+	meta Metainfo
+
 	// Protects bitfield writing from torrent loop and reading from announcer loop.
 	mBitfield sync.RWMutex
 
+	// True if the bitfield has changed after it is saved to resume db. Protected by mBitfield.
+	bitfieldDirty bool
+
+	// Pieces that are assumed to be complete without checking hashes when the torrent is added with SkipHashCheck option.
+	// A piece is verified when it is requested by a peer for the first time.
+	unverifiedPieces *bitfield.Bitfield
+
+	// Requests of peers waiting for verification of an unverified piece, keyed by piece index.
+	lazyVerifyRequests map[uint32][]peer.Message
+
+	// Results of lazy piece verifications are received from this channel.
+	lazyVerifyResultC chan lazyVerifyResult
+
+	// Peers that have sent the addresses in PEX messages, keyed by address.
+	// Used for sending holepunch rendezvous messages when we cannot connect to an address.
+	holepunchRelays map[string]*peer.Peer
+	// Relays that we have sent rendezvous messages to, keyed by the address of the target.
+	// Connect messages are accepted only from these relays or for addresses received from the relay with PEX.
+	holepunchRendezvous map[string]*peer.Peer
+
 	// Unique peer ID is generated per downloader.
 	peerID [20]byte
 
+	// Random key sent in announce requests. Trackers use it to identify us if our IP address changes.
+	trackerKey uint32
+
 	files  []allocator.File
 	pieces []piece.Piece
 
@@ -99,6 +174,9 @@ type torrent struct {
 	// Keep recently seen peers to fill underpopulated PEX lists.
 	recentlySeen pexlist.RecentlySeen
 
+	// Outcomes of recent connections for debugging. Returned from RecentConnections.
+	recentConnections *connectionLog
+
 	// Unchoker implements an algorithm to select peers to unchoke based on their download speed.
 	unchoker *unchoker.Unchoker
 
@@ -116,6 +194,20 @@ type torrent struct {
 
 	pieceWriterResultC chan *piecewriter.PieceWriter
 
+	// Hash checked pieces waiting to be written to disk when Config.WriteBufferSize is set.
+	writeBuffer     []*piecewriter.PieceWriter
+	writeBufferSize int64
+	// Pieces in the buffer are written after this timer fires even if the buffer is not full.
+	// The channel of the timer is nil while the timer is not running.
+	writeBufferTimer    *time.Timer
+	writeBufferTimeoutC <-chan time.Time
+	// True while the buffered pieces are being written after the torrent is stopped.
+	writeBufferFlushing bool
+	writeBufferFlushedC chan *piecewriter.Batch
+	// Batch of buffered pieces that is being written to disk. Nil if no batch is being written.
+	writeBatch        *piecewriter.Batch
+	writeBatchResultC chan *piecewriter.Batch
+
 	// This channel is closed once all pieces are downloaded and verified.
 	completeC chan struct{}
 
@@ -138,37 +230,92 @@ type torrent struct {
 	doneC chan struct{}
 
 	// These are the channels for sending a message to run() loop.
-	statsCommandC        chan statsRequest        // Stats()
-	trackersCommandC     chan trackersRequest     // Trackers()
-	peersCommandC        chan peersRequest        // Peers()
-	webseedsCommandC     chan webseedsRequest     // Webseeds()
-	startCommandC        chan struct{}            // Start()
-	stopCommandC         chan struct{}            // Stop()
-	announceCommandC     chan struct{}            // Announce()
-	verifyCommandC       chan struct{}            // Verify()
-	notifyErrorCommandC  chan notifyErrorCommand  // NotifyError()
-	notifyListenCommandC chan notifyListenCommand // NotifyListen()
-	addPeersCommandC     chan []*net.TCPAddr      // AddPeers()
-	addTrackersCommandC  chan []tracker.Tracker   // AddTrackers()
+	statsCommandC            chan statsRequest          // Stats()
+	trackersCommandC         chan trackersRequest       // Trackers()
+	peersCommandC            chan peersRequest          // Peers()
+	webseedsCommandC         chan webseedsRequest       // Webseeds()
+	filesCommandC            chan filesRequest          // Files()
+	connectionsCommandC      chan connectionsRequest    // RecentConnections()
+	swarmStatsCommandC       chan swarmStatsRequest     // SwarmStats()
+	pieceProgressCommandC    chan pieceProgressRequest  // PieceProgress()
+	pieceMapCommandC         chan pieceMapRequest       // PieceMap()
+	sequentialCommandC       chan bool                  // SetSequential()
+	superSeedingCommandC     chan bool                  // SetSuperSeeding()
+	filePriorityCommandC     chan filePriorityRequest   // SetFilePriority()
+	downloadPiecesCommandC   chan downloadPiecesRequest // DownloadPieces()
+	pieceDeadlineCommandC    chan pieceDeadlineRequest  // SetPieceDeadline()
+	startCommandC            chan struct{}              // Start()
+	startDelayedCommandC     chan time.Duration         // startWithAnnounceDelay()
+	stopCommandC             chan struct{}              // Stop()
+	announceCommandC         chan struct{}              // Announce()
+	announceIntervalCommandC chan time.Duration         // SetAnnounceInterval()
+	pauseCommandC            chan struct{}              // Pause()
+	resumeCommandC           chan struct{}              // Resume()
+	verifyCommandC           chan struct{}              // Verify()
+	checkCommandC            chan checkRequest          // Check()
+	checkCancelCommandC      chan checkRequest          // Check()
+	locationCommandC         chan setLocationRequest    // SetLocation()
+	locationCancelCommandC   chan setLocationRequest    // SetLocation()
+	notifyErrorCommandC      chan notifyErrorCommand    // NotifyError()
+	notifyListenCommandC     chan notifyListenCommand   // NotifyListen()
+	addPeersCommandC         chan []*net.TCPAddr        // AddPeers()
+	addTrackersCommandC      chan []tracker.Tracker     // AddTrackers()
+
+	// Resolved addresses of a peer given as host name.
+	hostPeersC chan []*net.TCPAddr
+
+	// Incoming peers that have done the handshake on the port of another torrent.
+	handedOverPeerC chan *incominghandshaker.IncomingHandshaker
 
 	// Trackers send announce responses to this channel.
 	addrsFromTrackers chan []*net.TCPAddr
 
+	// Announcers signal here when a tracker returns too few peers.
+	trackerStarvedC chan struct{}
+
+	// Announcers send failed announces to this channel.
+	trackerErrorC chan announcer.TrackerError
+
+	// Lifecycle events are sent to this channel from the event loop. Closed when the torrent is closed.
+	eventsC chan Event
+	// Events waiting to be sent to eventsC.
+	events []Event
+
+	// Number of connected peers. Read by the session when the global connection limit is reached.
+	numPeers int32
+	// Number of incoming and outgoing handshakers. Its changes are added to Session.numHandshakers.
+	numHandshakers int32
+
+	// Session asks the torrent to drop a peer when the global connection limit is exceeded.
+	dropPeerC chan struct{}
+
 	// Keeps a list of peer addresses to connect.
 	addrList *addrlist.AddrList
 
 	// New raw connections created by OutgoingHandshaker are sent to here.
 	incomingConnC chan net.Conn
 
-	// Keep a set of peer IDs to block duplicate connections.
-	peerIDs map[[20]byte]struct{}
+	// Connected peers by their IDs to block duplicate connections.
+	peerIDs map[[20]byte]*peer.Peer
 
 	// Listens for incoming peer connections.
 	acceptor *acceptor.Acceptor
+	// Listens for incoming peer connections on IPv6 if Config.ListenIPv6 is enabled.
+	acceptor6 *acceptor.Acceptor
+
+	// Max number of outgoing connections. Changes over time if Config.AdaptivePeerLimit is enabled.
+	peerDialLimit int
 
 	// Special hash of info hash for encypted connection handshake.
 	sKeyHash [20]byte
 
+	// SHA-256 info hash of hybrid torrents, from the info or the magnet link. Nil if it is not known.
+	hashV2 *[32]byte
+	// Truncated v2 info hash of hybrid torrents. Peers in v2 swarm use it in handshake.
+	// Zero if the v2 info hash is not known when the torrent is created.
+	infoHashV2 [20]byte
+	sKeyHashV2 [20]byte
+
 	// Announces the status of torrent to trackers to get peer addresses periodically.
 	announcers []*announcer.PeriodicalAnnouncer
 
@@ -176,10 +323,17 @@ type torrent struct {
 	// all periodical trackers are closed.
 	stoppedEventAnnouncer *announcer.StopAnnouncer
 
+	// True if the last stopped event could not be announced to all trackers.
+	stoppedEventFailed bool
+
 	// If not nil, torrent is announced to DHT periodically.
 	dhtAnnouncer *announcer.DHTAnnouncer
 	dhtPeersC    chan []*net.TCPAddr
 
+	// If not nil, torrent is announced to the local network periodically.
+	lsdAnnouncer *announcer.DHTAnnouncer
+	lsdPeersC    chan []*net.TCPAddr
+
 	// List of peers in handshake state.
 	incomingHandshakers map[*incominghandshaker.IncomingHandshaker]struct{}
 	outgoingHandshakers map[*outgoinghandshaker.OutgoingHandshaker]struct{}
@@ -206,14 +360,39 @@ type torrent struct {
 	verifierResultC   chan *verifier.Verifier
 	checkedPieces     uint32
 
+	// Verifier of a running Check. Files are read without changing the state of the torrent.
+	checkVerifier *verifier.Verifier
+	// Files that do not exist or have a different size on disk, indexed same as info.Files.
+	checkMissingFiles []bool
+	// Result of the running Check is sent to this channel.
+	checkResponseC chan checkResponse
+
+	// A worker that moves files to another directory with SetLocation.
+	mover          *mover.Mover
+	moverProgressC chan mover.Progress
+	moverResultC   chan *mover.Mover
+	bytesMoved     int64
+	bytesToMove    int64
+	// Destination directory of the running move.
+	moveDest string
+	// Result of the running move is sent to this channel.
+	moveResponseC chan error
+	// Set if the torrent is stopped for moving files. It is started again after the move.
+	moveRestart bool
+	// Directory of the files if they are moved with SetLocation.
+	location string
+
 	// Metrics
-	downloadSpeed   metrics.Meter
-	uploadSpeed     metrics.Meter
+	downloadRate    movingRate
+	uploadRate      movingRate
 	bytesDownloaded metrics.Counter
 	bytesUploaded   metrics.Counter
 	bytesWasted     metrics.Counter
 	seededFor       metrics.Counter
 
+	// Download rates of peers, used for sizing their request queues with Config.AdaptiveRequestsOut.
+	peerDownloadRates map[*peer.Peer]*movingRate
+
 	seedDurationUpdatedAt time.Time
 	seedDurationTicker    *time.Ticker
 
@@ -223,6 +402,13 @@ type torrent struct {
 	// Peers that are sending corrupt data are banned.
 	bannedPeerIPs map[string]struct{}
 
+	// Number of corrupt pieces that peers have supplied blocks for, keyed by IP.
+	badPieceCounts map[string]int
+
+	// Remaining addresses of peers given as host names, keyed by the address being dialed.
+	// Next address is tried if the connection to the current one fails.
+	peerFallbackAddrs map[string][]*net.TCPAddr
+
 	// A signal sent to run() loop when announcers are stopped.
 	announcersStoppedC chan struct{}
 
@@ -239,6 +425,7 @@ type torrent struct {
 	webseedClient          *http.Client
 	webseedSources         []*webseedsource.WebseedSource
 	rawWebseedSources      []string
+	rawHTTPSeeds           []string
 	webseedPieceResultC    *suspendchan.Chan
 	webseedRetryC          chan *webseedsource.WebseedSource
 	webseedActiveDownloads int
@@ -246,9 +433,50 @@ type torrent struct {
 	// Set to true when manual verification is requested
 	doVerify bool
 
+	// Time of the first recoverable error since the torrent has been working properly.
+	errorGraceStartedAt time.Time
+	// Holds the recoverable error while the torrent is waiting to be restarted silently.
+	graceError  error
+	errorRetryC chan struct{}
+
+	// Started when the last peer is disconnected. Trackers are announced when it fires.
+	noPeersTimer *time.Timer
+	noPeersC     <-chan time.Time
+
+	// True while the number of connected peers is below Config.MinConnectedPeers.
+	seekingPeers bool
+
+	// Piece picking is delayed until this timer fires after the first peer is connected.
+	// The channel of the timer is nil while the timer is not running, so a stopped timer cannot fire.
+	pickerWarmupTimer *time.Timer
+	pickerWarmupC     <-chan time.Time
+	pickerWarmedUp    bool
+
+	// Started when a magnet link is started. Torrent is stopped if the info is not downloaded when it fires.
+	metadataTimer    *time.Timer
+	metadataTimeoutC <-chan time.Time
+
+	// Set by startWithAnnounceDelay on session startup. First announce to trackers is delayed by this duration.
+	startAnnounceDelay time.Duration
+	// Trackers are not announced before this time.
+	announceStartAt time.Time
+	// Overrides the interval returned from trackers if not zero. Set with SetAnnounceInterval.
+	announceInterval time.Duration
+
 	// If true, the torrent is stopped automatically when all pieces are downloaded.
 	stopAfterDownload bool
 
+	// Set when the torrent is stopped after reaching the seed ratio or seed time limit. Cleared on start.
+	seedLimitReached bool
+
+	// While paused, peers are kept connected but no pieces are requested or uploaded. Cleared on stop.
+	paused bool
+	// Set when the torrent is paused because a piece cannot be written to the disk. Cleared on resume and stop.
+	diskError error
+
+	// If true, peer addresses are not exchanged with PEX messages even if Config.PEXEnabled is true.
+	disablePEX bool
+
 	// True means that completeCmd has run before.
 	completeCmdRun bool
 
@@ -270,7 +498,9 @@ func newTorrent2(
 	stats resumer.Stats, // initial stats from previous run
 	ws []*webseedsource.WebseedSource,
 	stopAfterDownload bool,
+	disablePEX bool,
 	completeCmdRun bool,
+	meta Metainfo,
 ) (*torrent, error) {
 	if len(infoHash) != 20 {
 		return nil, errors.New("invalid infoHash (must be 20 bytes)")
@@ -290,11 +520,14 @@ func newTorrent2(
 		port:                      port,
 		info:                      info,
 		bitfield:                  bf,
+		meta:                      meta,
 		log:                       logger.New("torrent " + id),
 		peerDisconnectedC:         make(chan *peer.Peer),
 		messages:                  make(chan peer.Message),
 		pieceMessagesC:            suspendchan.New(0),
 		peers:                     make(map[*peer.Peer]struct{}),
+		resumePeersMarked:         make(map[*peer.Peer]struct{}),
+		resumePeerFailures:        make(map[string]int),
 		incomingPeers:             make(map[*peer.Peer]struct{}),
 		outgoingPeers:             make(map[*peer.Peer]struct{}),
 		pieceDownloaders:          make(map[*peer.Peer]*piecedownloader.PieceDownloader),
@@ -304,22 +537,54 @@ func newTorrent2(
 		infoDownloaders:           make(map[*peer.Peer]*infodownloader.InfoDownloader),
 		infoDownloadersSnubbed:    make(map[*peer.Peer]*infodownloader.InfoDownloader),
 		pieceWriterResultC:        make(chan *piecewriter.PieceWriter),
+		writeBufferFlushedC:       make(chan *piecewriter.Batch, 1),
+		writeBatchResultC:         make(chan *piecewriter.Batch),
+		lazyVerifyRequests:        make(map[uint32][]peer.Message),
+		lazyVerifyResultC:         make(chan lazyVerifyResult),
+		holepunchRelays:           make(map[string]*peer.Peer),
+		holepunchRendezvous:       make(map[string]*peer.Peer),
 		completeC:                 make(chan struct{}),
 		closeC:                    make(chan chan struct{}),
 		startCommandC:             make(chan struct{}),
+		startDelayedCommandC:      make(chan time.Duration),
 		stopCommandC:              make(chan struct{}),
 		announceCommandC:          make(chan struct{}),
+		announceIntervalCommandC:  make(chan time.Duration),
+		pauseCommandC:             make(chan struct{}),
+		resumeCommandC:            make(chan struct{}),
 		verifyCommandC:            make(chan struct{}),
+		checkCommandC:             make(chan checkRequest),
+		checkCancelCommandC:       make(chan checkRequest),
+		locationCommandC:          make(chan setLocationRequest),
+		locationCancelCommandC:    make(chan setLocationRequest),
 		statsCommandC:             make(chan statsRequest),
 		trackersCommandC:          make(chan trackersRequest),
 		peersCommandC:             make(chan peersRequest),
 		webseedsCommandC:          make(chan webseedsRequest),
+		filesCommandC:             make(chan filesRequest),
+		connectionsCommandC:       make(chan connectionsRequest),
+		swarmStatsCommandC:        make(chan swarmStatsRequest),
+		pieceMapCommandC:          make(chan pieceMapRequest),
+		pieceProgressCommandC:     make(chan pieceProgressRequest),
+		sequentialCommandC:        make(chan bool),
+		superSeedingCommandC:      make(chan bool),
+		limitDownload:             speedlimit.New(0, s.limitDownload),
+		limitUpload:               speedlimit.New(0, s.limitUpload),
+		filePriorityCommandC:      make(chan filePriorityRequest),
+		downloadPiecesCommandC:    make(chan downloadPiecesRequest),
+		pieceDeadlineCommandC:     make(chan pieceDeadlineRequest),
 		notifyErrorCommandC:       make(chan notifyErrorCommand),
 		notifyListenCommandC:      make(chan notifyListenCommand),
 		addPeersCommandC:          make(chan []*net.TCPAddr),
+		hostPeersC:                make(chan []*net.TCPAddr),
+		handedOverPeerC:           make(chan *incominghandshaker.IncomingHandshaker),
 		addTrackersCommandC:       make(chan []tracker.Tracker),
 		addrsFromTrackers:         make(chan []*net.TCPAddr),
-		peerIDs:                   make(map[[20]byte]struct{}),
+		trackerStarvedC:           make(chan struct{}, 1),
+		trackerErrorC:             make(chan announcer.TrackerError),
+		eventsC:                   make(chan Event),
+		dropPeerC:                 make(chan struct{}, 1),
+		peerIDs:                   make(map[[20]byte]*peer.Peer),
 		incomingConnC:             make(chan net.Conn),
 		sKeyHash:                  mse.HashSKey(ih[:]),
 		infoDownloaderResultC:     make(chan *infodownloader.InfoDownloader),
@@ -331,13 +596,20 @@ func newTorrent2(
 		allocatorResultC:          make(chan *allocator.Allocator),
 		verifierProgressC:         make(chan verifier.Progress),
 		verifierResultC:           make(chan *verifier.Verifier),
+		moverProgressC:            make(chan mover.Progress),
+		moverResultC:              make(chan *mover.Mover),
 		connectedPeerIPs:          make(map[string]struct{}),
+		pieceDeadlines:            make(map[uint32]pieceDeadline),
 		bannedPeerIPs:             make(map[string]struct{}),
+		badPieceCounts:            make(map[string]int),
+		superSeedPeers:            make(map[*peer.Peer]*superSeedPeer),
+		mixedPieces:               make(map[uint32]struct{}),
+		peerDownloadRates:         make(map[*peer.Peer]*movingRate),
+		peerFallbackAddrs:         make(map[string][]*net.TCPAddr),
 		announcersStoppedC:        make(chan struct{}),
 		dhtPeersC:                 make(chan []*net.TCPAddr, 1),
+		lsdPeersC:                 make(chan []*net.TCPAddr, 1),
 		externalIP:                externalip.FirstExternalIP(),
-		downloadSpeed:             metrics.NilMeter{},
-		uploadSpeed:               metrics.NilMeter{},
 		bytesDownloaded:           metrics.NewCounter(),
 		bytesUploaded:             metrics.NewCounter(),
 		bytesWasted:               metrics.NewCounter(),
@@ -347,13 +619,20 @@ func newTorrent2(
 		webseedSources:            ws,
 		webseedPieceResultC:       suspendchan.New(0),
 		webseedRetryC:             make(chan *webseedsource.WebseedSource),
+		errorRetryC:               make(chan struct{}),
 		doneC:                     make(chan struct{}),
 		stopAfterDownload:         stopAfterDownload,
+		disablePEX:                disablePEX,
 		completeCmdRun:            completeCmdRun,
+		peerDialLimit:             cfg.MaxPeerDial,
+	}
+	if cfg.AdaptivePeerLimit {
+		t.peerDialLimit = clampPeerDialLimit(t.peerDialLimit, cfg.AdaptivePeerLimitMin, cfg.AdaptivePeerLimitMax)
 	}
 	if len(t.webseedSources) > s.config.WebseedMaxSources {
 		t.webseedSources = t.webseedSources[:10]
 	}
+	markTrustedWebseeds(t.webseedSources, cfg.WebseedTrustedSources)
 	t.bytesDownloaded.Inc(stats.BytesDownloaded)
 	t.bytesUploaded.Inc(stats.BytesUploaded)
 	t.bytesWasted.Inc(stats.BytesWasted)
@@ -362,25 +641,35 @@ func newTorrent2(
 	if cfg.BlocklistEnabledForOutgoingConnections {
 		blocklistForOutgoingConns = s.blocklist
 	}
-	t.addrList = addrlist.New(cfg.MaxPeerAddresses, blocklistForOutgoingConns, port, &t.externalIP)
+	t.addrList = addrlist.New(cfg.MaxPeerAddresses, cfg.PeerAddressDedupDuration, blocklistForOutgoingConns, port, &t.externalIP)
+	t.recentConnections = newConnectionLog(cfg.MaxRecentConnections)
 	if t.info != nil {
 		t.piecePool = bufferpool.New(int(t.info.PieceLength))
+		if t.info.Version != metainfo.V1 {
+			t.setInfoHashV2(t.info.HashV2)
+		}
 	}
-	n := t.copyPeerIDPrefix()
-	_, err := rand.Read(t.peerID[n:])
+	var err error
+	t.peerID, err = s.torrentPeerID(t.peerIDPrefix())
 	if err != nil {
 		return nil, err
 	}
-	t.unchoker = unchoker.New(cfg.UnchokedPeers, cfg.OptimisticUnchokedPeers)
+	var key [4]byte
+	_, err = rand.Read(key[:])
+	if err != nil {
+		return nil, err
+	}
+	t.trackerKey = binary.BigEndian.Uint32(key[:])
+	t.unchoker = unchoker.New(cfg.UnchokedPeers, cfg.OptimisticUnchokedPeers, cfg.AntiLeechMinUpload, cfg.AntiLeechMinRatio, cfg.PreferReciprocatingPeers)
 	go t.run()
 	return t, nil
 }
 
-func (t *torrent) copyPeerIDPrefix() int {
-	if t.info != nil && t.info.Private {
-		return copy(t.peerID[:], t.session.config.PrivatePeerIDPrefix)
+func (t *torrent) peerIDPrefix() string {
+	if t.info != nil && t.info.Private && t.session.config.PeerIDPrefix == "" {
+		return t.session.config.PrivatePeerIDPrefix
 	}
-	return copy(t.peerID[:], publicPeerIDPrefix)
+	return t.session.peerIDPrefix()
 }
 
 func (t *torrent) getPeersForUnchoker() []unchoker.Peer {
@@ -392,21 +681,64 @@ func (t *torrent) getPeersForUnchoker() []unchoker.Peer {
 }
 
 func (t *torrent) Name() string {
+	t.mName.RLock()
+	defer t.mName.RUnlock()
 	return t.name
 }
 
+func (t *torrent) setName(name string) {
+	t.mName.Lock()
+	t.name = name
+	t.mName.Unlock()
+}
+
 func (t *torrent) InfoHash() []byte {
 	b := make([]byte, 20)
 	copy(b, t.infoHash[:])
 	return b
 }
 
+// infoHashV2Bytes returns the v2 info hash for saving in resume data. Returns nil if it is not known.
+func (t *torrent) infoHashV2Bytes() []byte {
+	if t.hashV2 == nil {
+		return nil
+	}
+	b := make([]byte, 32)
+	copy(b, t.hashV2[:])
+	return b
+}
+
+// dhtEnabled returns true if DHT can be used for the torrent.
+// Private torrents must get peers only from their trackers (BEP 27).
+// Magnet links are never private, so DHT is used until the metadata is downloaded.
+func (t *torrent) dhtEnabled() bool {
+	return t.session.dhtEnabled && (t.info == nil || !t.info.Private)
+}
+
+// webseedsEnabled returns true if pieces can be downloaded from webseed sources.
+// Private torrents must not get data from sources other than the peers from their trackers.
+func (t *torrent) webseedsEnabled() bool {
+	return t.info == nil || !t.info.Private
+}
+
 func (t *torrent) announceDHT() {
 	t.session.mPeerRequests.Lock()
 	t.session.dhtPeerRequests[t] = struct{}{}
 	t.session.mPeerRequests.Unlock()
 }
 
+// lsdEnabled returns true if the torrent can be announced to the local network.
+// Like DHT, it is not used for private torrents.
+func (t *torrent) lsdEnabled() bool {
+	return t.session.lsd != nil && (t.info == nil || !t.info.Private)
+}
+
+func (t *torrent) announceLSD() {
+	t.session.mPeerRequests.Lock()
+	t.session.lsdPeerRequests[t] = struct{}{}
+	t.session.mPeerRequests.Unlock()
+}
+
 // DisableLogging disables all log messages printed to console.
 // This function needs to be called before creating a Session.
 func DisableLogging() {
@@ -16,7 +16,7 @@ func (t *torrent) handleAllocationDone(al *allocator.Allocator) {
 	t.allocator = nil
 
 	if al.Error != nil {
-		t.stop(fmt.Errorf("file allocation error: %s", al.Error))
+		t.stopRecoverable(fmt.Errorf("file allocation error: %w", al.Error))
 		return
 	}
 
@@ -42,13 +42,21 @@ func (t *torrent) handleAllocationDone(al *allocator.Allocator) {
 	if t.piecePicker != nil {
 		panic("piece picker exists")
 	}
-	t.piecePicker = piecepicker.New(t.pieces, t.session.config.EndgameMaxDuplicateDownloads, t.webseedSources)
+	t.piecePicker = piecepicker.New(t.pieces, t.session.config.EndgameMaxDuplicateDownloads, t.session.config.EndgameThreshold, t.webseedSources)
+	t.applyFilePriorities()
+	t.piecePicker.SetSequential(t.sequential, uint32(t.session.config.SequentialWindow))
 
 	for pe := range t.peers {
 		pe.Bitfield = bitfield.New(t.info.NumPieces)
 	}
 
-	// If we already have bitfield from resume db, skip verification and start downloading.
+	// If the stats of the files are saved with the bitfield, verifier uses the bitfield only if the files are not changed.
+	if t.bitfield != nil && t.fileStats != nil && !al.HasMissing {
+		t.startVerifier()
+		return
+	}
+
+	// If we already have bitfield from resume db of an older version, skip verification and start downloading.
 	if t.bitfield != nil && !al.HasMissing {
 		for i := uint32(0); i < t.bitfield.Len(); i++ {
 			t.pieces[i].Done = t.bitfield.Test(i)
@@ -69,6 +77,7 @@ func (t *torrent) handleAllocationDone(al *allocator.Allocator) {
 	if !al.HasExisting {
 		t.mBitfield.Lock()
 		t.bitfield = bitfield.New(t.info.NumPieces)
+		t.bitfieldDirty = true
 		t.mBitfield.Unlock()
 		t.processQueuedMessages()
 		t.addFixedPeers()
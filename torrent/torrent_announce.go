@@ -2,28 +2,52 @@ package torrent
 
 import (
 	"math"
+	"time"
 
+	"github.com/cenkalti/rain/internal/externalip"
 	"github.com/cenkalti/rain/internal/tracker"
 )
 
 func (t *torrent) handleNewTrackers(trackers []tracker.Tracker) {
 	t.trackers = append(t.trackers, trackers...)
 	status := t.status()
-	if status != Stopping && status != Stopped {
+	if status != Stopping && status != Stopped && status != SeedingComplete && status != Retrying {
 		for _, tr := range trackers {
 			t.startNewAnnouncer(tr)
 		}
 	}
 }
 
+// handleAnnounceCommand announces to all trackers, DHT and LSD now, unless they are announced within their min intervals.
+func (t *torrent) handleAnnounceCommand() {
+	t.triggerAnnounces()
+}
+
+func (t *torrent) handleAnnounceIntervalCommand(d time.Duration) {
+	t.announceInterval = d
+	for _, an := range t.announcers {
+		an.SetIntervalOverride(d)
+	}
+}
+
 func (t *torrent) announcerFields() tracker.Torrent {
 	tr := tracker.Torrent{
 		InfoHash:        t.infoHash,
 		PeerID:          t.peerID,
 		Port:            t.port,
+		Key:             t.trackerKey,
 		BytesDownloaded: t.bytesDownloaded.Count(),
 		BytesUploaded:   t.bytesUploaded.Count(),
 	}
+	// Elected from the votes of peers, the router and the network interfaces.
+	if ip := t.session.ExternalIP(); externalip.IsPublic(ip) {
+		tr.IP = ip
+	} else if t.session.portMapper != nil {
+		tr.IP = t.session.portMapper.ExternalIP()
+	}
+	if t.session.config.ListenIPv6 {
+		tr.IPv6 = externalip.FirstExternalIPv6()
+	}
 	t.mBitfield.RLock()
 	if t.bitfield == nil {
 		// Some trackers don't send any peer address if don't tell we have missing bytes.
@@ -34,3 +58,33 @@ func (t *torrent) announcerFields() tracker.Torrent {
 	t.mBitfield.RUnlock()
 	return tr
 }
+
+// handleTrackerStarvation asks all trackers, DHT and LSD for more peers
+// when a tracker returns too few peers and the torrent cannot fill its dial slots.
+func (t *torrent) handleTrackerStarvation() {
+	if t.completed {
+		return
+	}
+	if s := t.status(); s != Downloading && s != DownloadingMetadata {
+		return
+	}
+	if t.addrList.Len() > 0 || len(t.outgoingPeers)+len(t.outgoingHandshakers) >= t.peerDialLimit {
+		return
+	}
+	t.log.Debugln("trackers returned too few peers, asking all sources for more peers")
+	t.setNeedMorePeers(true)
+	t.triggerAnnounces()
+}
+
+// triggerAnnounces announces to all trackers, DHT and LSD now, unless they are announced within their min intervals.
+func (t *torrent) triggerAnnounces() {
+	for _, an := range t.announcers {
+		an.Trigger()
+	}
+	if t.dhtAnnouncer != nil {
+		t.dhtAnnouncer.Trigger()
+	}
+	if t.lsdAnnouncer != nil {
+		t.lsdAnnouncer.Trigger()
+	}
+}
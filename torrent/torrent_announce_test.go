@@ -0,0 +1,116 @@
+package torrent
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/cenkalti/rain/internal/tracker"
+	"github.com/fortytw2/leaktest"
+)
+
+// startedTracker records the times of announces with "started" event.
+type startedTracker struct {
+	m       sync.Mutex
+	started []time.Time
+}
+
+func (t *startedTracker) Announce(ctx context.Context, req tracker.AnnounceRequest) (*tracker.AnnounceResponse, error) {
+	if req.Event == tracker.EventStarted {
+		t.m.Lock()
+		t.started = append(t.started, time.Now())
+		t.m.Unlock()
+	}
+	return &tracker.AnnounceResponse{Interval: time.Hour}, nil
+}
+
+func (t *startedTracker) URL() string {
+	return "http://tracker.example.com/announce"
+}
+
+func (t *startedTracker) Scrape(ctx context.Context, infoHashes [][20]byte) ([]tracker.ScrapeResult, error) {
+	return nil, tracker.ErrScrapeNotSupported
+}
+
+func (t *startedTracker) Started() []time.Time {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return append([]time.Time(nil), t.started...)
+}
+
+func TestStartupAnnounceStagger(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	s.config.StartupAnnounceStagger = 2 * time.Second
+
+	const numTorrents = 5
+	trk := &startedTracker{}
+	var torrents []*Torrent
+	for i := 0; i < numTorrents; i++ {
+		tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+		tor.torrent.trackers = []tracker.Tracker{trk}
+		torrents = append(torrents, tor)
+	}
+	resumedAt := time.Now()
+	s.resumeTorrents(torrents)
+
+	waitFor(t, "torrents did not announce", func() bool { return len(trk.Started()) >= numTorrents })
+	started := trk.Started()
+	first, last := started[0], started[0]
+	for _, at := range started {
+		if at.Before(first) {
+			first = at
+		}
+		if at.After(last) {
+			last = at
+		}
+	}
+	if last.Sub(resumedAt) > s.config.StartupAnnounceStagger+time.Second {
+		t.Fatalf("announce is delayed more than the stagger: %s", last.Sub(resumedAt))
+	}
+	if last.Sub(first) < 100*time.Millisecond {
+		t.Fatalf("first announces are not spread: %s", last.Sub(first))
+	}
+}
+
+func TestAnnounceOnNoPeers(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	s.config.TrackerMinAnnounceInterval = 100 * time.Millisecond
+	s.config.TrackerAnnounceOnNoPeersDelay = 100 * time.Millisecond
+	// Do not dial peers so the need for more peers is signalled only when the last peer disconnects.
+	s.config.MaxPeerDial = 0
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	trk := &countingTracker{}
+	tor.torrent.trackers = []tracker.Tracker{trk}
+	tor.Start()
+	var port int
+	select {
+	case port = <-tor.torrent.NotifyListen():
+	case <-time.After(timeout):
+		t.Fatal("torrent is not listening")
+	}
+
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	var peerID [20]byte
+	copy(peerID[:], "-XX0000-000000000000")
+	conn, _, _, _, err := btconn.Dial(addr, nil, time.Second, time.Second, false, false, [8]byte{}, tor.torrent.infoHash, peerID, make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "peer is not connected", func() bool { return tor.Stats().Peers.Total == 1 })
+	time.Sleep(300 * time.Millisecond)
+	if n := trk.Announces(); n != 1 {
+		t.Fatalf("must announce once while a peer is connected, announces: %d", n)
+	}
+
+	// All peers disconnect.
+	conn.Close()
+	waitFor(t, "did not announce after peers are disconnected", func() bool { return trk.Announces() >= 2 })
+}
@@ -0,0 +1,96 @@
+package torrent
+
+import (
+	"time"
+
+	"github.com/cenkalti/rain/internal/announcer"
+	"github.com/cenkalti/rain/internal/tracker"
+)
+
+// Max number of announce results waiting for Config.OnAnnounce. Excess results are dropped.
+const announceResultQueueSize = 1000
+
+// AnnounceResult is the outcome of an announce attempt. Passed to Config.OnAnnounce.
+type AnnounceResult struct {
+	// ID of the announced torrent.
+	TorrentID string
+	Time      time.Time
+	Source    AnnounceSource
+	// URL of the tracker. Empty for DHT and LSD announces.
+	Tracker string
+	Event   AnnounceEvent
+	// Time until the next announce. Zero for AnnounceEventStopped.
+	Interval time.Duration
+	// Number of peers returned from the tracker.
+	// Peers found with DHT and LSD are received after the announce, so it is always zero for them.
+	NumPeers int
+	// Set if the announce has failed.
+	Error *AnnounceError
+}
+
+// AnnounceSource is where the torrent is announced to.
+type AnnounceSource string
+
+// Sources of announces.
+const (
+	AnnounceSourceTracker AnnounceSource = "tracker"
+	AnnounceSourceDHT     AnnounceSource = "dht"
+	AnnounceSourceLSD     AnnounceSource = "lsd"
+)
+
+// AnnounceEvent is the event that is sent in an announce.
+type AnnounceEvent string
+
+// Announce events. DHT and LSD are always announced with AnnounceEventNone.
+const (
+	// AnnounceEventNone is a regular announce that is done periodically.
+	AnnounceEventNone      AnnounceEvent = "none"
+	AnnounceEventStarted   AnnounceEvent = "started"
+	AnnounceEventCompleted AnnounceEvent = "completed"
+	AnnounceEventStopped   AnnounceEvent = "stopped"
+)
+
+var announceEvents = map[tracker.Event]AnnounceEvent{
+	tracker.EventNone:      AnnounceEventNone,
+	tracker.EventStarted:   AnnounceEventStarted,
+	tracker.EventCompleted: AnnounceEventCompleted,
+	tracker.EventStopped:   AnnounceEventStopped,
+}
+
+// announceResultFunc returns the function that is called by the announcers after each announce.
+// It is called from the announcer goroutines, so it must not block.
+func (t *torrent) announceResultFunc(source AnnounceSource) func(announcer.Result) {
+	if t.session.announceResultC == nil {
+		return nil
+	}
+	return func(r announcer.Result) {
+		res := AnnounceResult{
+			TorrentID: t.id,
+			Time:      time.Now(),
+			Source:    source,
+			Tracker:   r.Tracker,
+			Event:     announceEvents[r.Event],
+			Interval:  r.Interval,
+			NumPeers:  r.NumPeers,
+		}
+		if r.Err != nil {
+			res.Error = &AnnounceError{r.Err}
+		}
+		select {
+		case t.session.announceResultC <- res:
+		default:
+			t.log.Debugln("announce result queue is full, dropping result")
+		}
+	}
+}
+
+func (s *Session) processAnnounceResults() {
+	for {
+		select {
+		case res := <-s.announceResultC:
+			s.config.OnAnnounce(res)
+		case <-s.closeC:
+			return
+		}
+	}
+}
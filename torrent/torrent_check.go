@@ -0,0 +1,223 @@
+package torrent
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cenkalti/rain/internal/allocator"
+	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/verifier"
+)
+
+var errCheckInterrupted = errors.New("check is interrupted because torrent is started")
+
+// VerifyResult is the result of checking the files of a torrent on disk with Torrent.Check.
+type VerifyResult struct {
+	// Number of pieces in the torrent.
+	NumPieces uint32
+	// Bitfield of pieces that match their hashes, most significant bit of the first byte is the first piece.
+	Bitfield []byte
+	// Results of files, indexed same as the files of the torrent.
+	Files []FileVerifyResult
+}
+
+// PieceOK returns true if the piece at index matches its hash.
+func (r *VerifyResult) PieceOK(index uint32) bool {
+	if index >= r.NumPieces {
+		return false
+	}
+	return r.Bitfield[index/8]&(0x80>>(index%8)) != 0
+}
+
+// FileVerifyResult is the result of a single file in VerifyResult.
+type FileVerifyResult struct {
+	Path string
+	// False if the file does not exist on disk or has a different size than in the torrent.
+	Exists bool
+	// True if all pieces containing data of the file match their hashes.
+	OK bool
+}
+
+type checkRequest struct {
+	Response chan checkResponse
+}
+
+type checkResponse struct {
+	Result *VerifyResult
+	Error  error
+}
+
+func (t *torrent) Check(ctx context.Context) (*VerifyResult, error) {
+	req := checkRequest{Response: make(chan checkResponse, 1)}
+	select {
+	case t.checkCommandC <- req:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-t.closeC:
+		return nil, errClosed
+	}
+	select {
+	case resp := <-req.Response:
+		return resp.Result, resp.Error
+	case <-ctx.Done():
+		select {
+		case t.checkCancelCommandC <- req:
+		case <-t.closeC:
+		}
+		return nil, ctx.Err()
+	case <-t.closeC:
+		return nil, errClosed
+	}
+}
+
+func (t *torrent) handleCheckCommand(req checkRequest) {
+	if t.info == nil {
+		req.Response <- checkResponse{Error: errors.New("torrent metadata is not downloaded yet")}
+		return
+	}
+	if s := t.status(); s != Stopped && s != SeedingComplete {
+		req.Response <- checkResponse{Error: errors.New("torrent must be stopped before checking files")}
+		return
+	}
+	if t.checkVerifier != nil {
+		req.Response <- checkResponse{Error: errors.New("files of torrent are already being checked")}
+		return
+	}
+	if t.mover != nil {
+		req.Response <- checkResponse{Error: errors.New("files of torrent are being moved")}
+		return
+	}
+	stats, err := verifier.FileStats(t.storage, t.info.Files)
+	if err != nil {
+		req.Response <- checkResponse{Error: err}
+		return
+	}
+	// Files are opened only if they have the correct size, otherwise opening would create or truncate them.
+	missing := make([]bool, len(t.info.Files))
+	files := make([]allocator.File, len(t.info.Files))
+	for i, f := range t.info.Files {
+		if f.Padding || f.Symlink != "" || f.Length == 0 {
+			files[i] = allocator.File{Storage: zeroFile{}, Name: f.Path}
+			continue
+		}
+		if stats[i].Size != f.Length {
+			missing[i] = true
+			files[i] = allocator.File{Storage: zeroFile{}, Name: f.Path}
+			continue
+		}
+		sf, _, err := t.storage.Open(f.Path, f.Length)
+		if err != nil {
+			closeCheckFiles(files)
+			req.Response <- checkResponse{Error: err}
+			return
+		}
+		files[i] = allocator.File{Storage: sf, Name: f.Path}
+	}
+	t.log.Info("checking files")
+	t.checkVerifier = verifier.New(t.session.config.VerifierConcurrency)
+	t.checkMissingFiles = missing
+	t.checkResponseC = req.Response
+	go func(ve *verifier.Verifier) {
+		defer closeCheckFiles(files)
+		ve.Run(piece.NewPieces(t.info, files), false, nil, t.session.semVerify, t.verifierProgressC, t.verifierResultC)
+	}(t.checkVerifier)
+}
+
+func closeCheckFiles(files []allocator.File) {
+	for _, f := range files {
+		if f.Storage != nil {
+			f.Storage.Close()
+		}
+	}
+}
+
+func (t *torrent) handleCheckDone(ve *verifier.Verifier) {
+	if t.checkVerifier != ve {
+		panic("invalid verifier")
+	}
+	respC := t.checkResponseC
+	missing := t.checkMissingFiles
+	t.checkVerifier = nil
+	t.checkResponseC = nil
+	t.checkMissingFiles = nil
+	t.checkedPieces = 0
+
+	if ve.Error != nil {
+		respC <- checkResponse{Error: ve.Error}
+		return
+	}
+	t.log.Info("checking files done")
+	respC <- checkResponse{Result: t.verifyResult(ve.Bitfield, missing)}
+}
+
+// verifyResult returns the results of the files from the bitfield of checked pieces.
+// Pieces that contain data of a missing file are marked as failed even if their zeroed data matches the hash.
+func (t *torrent) verifyResult(bf *bitfield.Bitfield, missing []bool) *VerifyResult {
+	files := make([]FileVerifyResult, len(t.info.Files))
+	var offset int64
+	for i, f := range t.info.Files {
+		begin := offset
+		offset += f.Length
+		files[i] = FileVerifyResult{Path: f.Path, Exists: !missing[i]}
+		if missing[i] {
+			for j := uint32(begin / int64(t.info.PieceLength)); j <= uint32((offset-1)/int64(t.info.PieceLength)); j++ {
+				bf.Clear(j)
+			}
+		}
+	}
+	offset = 0
+	for i, f := range t.info.Files {
+		begin := offset
+		offset += f.Length
+		files[i].OK = files[i].Exists
+		if f.Length == 0 || f.Padding {
+			continue
+		}
+		for j := uint32(begin / int64(t.info.PieceLength)); j <= uint32((offset-1)/int64(t.info.PieceLength)); j++ {
+			if !bf.Test(j) {
+				files[i].OK = false
+				break
+			}
+		}
+	}
+	return &VerifyResult{
+		NumPieces: t.info.NumPieces,
+		Bitfield:  bf.Bytes(),
+		Files:     files,
+	}
+}
+
+func (t *torrent) handleCheckCancelCommand(req checkRequest) {
+	if t.checkResponseC != req.Response {
+		// Check is already finished.
+		return
+	}
+	t.stopCheck(context.Canceled)
+}
+
+// stopCheck closes the running Check and sends err to the caller.
+func (t *torrent) stopCheck(err error) {
+	if t.checkVerifier == nil {
+		return
+	}
+	t.checkVerifier.Close()
+	t.checkResponseC <- checkResponse{Error: err}
+	t.checkVerifier = nil
+	t.checkResponseC = nil
+	t.checkMissingFiles = nil
+	t.checkedPieces = 0
+}
+
+// zeroFile is used in place of files that cannot be read during Check. Reads return zeros.
+type zeroFile struct{}
+
+func (zeroFile) ReadAt(p []byte, off int64) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func (zeroFile) WriteAt(p []byte, off int64) (int, error) { return 0, errors.New("file is not opened") }
+func (zeroFile) Close() error                             { return nil }
@@ -0,0 +1,68 @@
+package torrent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+)
+
+func TestCheck(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	src := filepath.Join(torrentDataDir, torrentName)
+	dst := filepath.Join(s.config.DataDir, tor.ID(), torrentName)
+	err := os.Mkdir(filepath.Join(s.config.DataDir, tor.ID()), os.ModeDir|0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = CopyDir(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := tor.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < res.NumPieces; i++ {
+		if !res.PieceOK(i) {
+			t.Fatalf("piece #%d is not ok", i)
+		}
+	}
+	for _, fr := range res.Files {
+		if !fr.Exists || !fr.OK {
+			t.Fatalf("file is not ok: %s", fr.Path)
+		}
+	}
+	if st := tor.Stats().Status; st != Stopped {
+		t.Fatalf("unexpected status: %s", st)
+	}
+
+	readme := filepath.Join(dst, "README")
+	err = os.Remove(readme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = tor.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fr := range res.Files {
+		if fr.Path == filepath.Join(torrentName, "README") {
+			if fr.Exists || fr.OK {
+				t.Fatal("missing file is reported as ok")
+			}
+		}
+	}
+	// Missing files must not be created by Check.
+	_, err = os.Stat(readme)
+	if !os.IsNotExist(err) {
+		t.Fatal("missing file is created")
+	}
+}
@@ -2,6 +2,7 @@ package torrent
 
 import (
 	"errors"
+	"sync/atomic"
 
 	"github.com/cenkalti/rain/internal/infodownloader"
 	"github.com/cenkalti/rain/internal/peer"
@@ -14,14 +15,22 @@ var errClosed = errors.New("torrent is closed")
 func (t *torrent) close() {
 	// Stop if running.
 	t.stop(errClosed)
+	t.stopCheck(errClosed)
+	t.stopMove(errClosed)
+
+	// Wait until the buffered pieces are written to save them in the bitfield.
+	if t.writeBufferFlushing {
+		t.saveFlushedWrites(<-t.writeBufferFlushedC)
+	}
 
 	// Maybe we are in "Stopping" state. Close "stopped" event announcer.
 	if t.stoppedEventAnnouncer != nil {
+		if t.session.config.TrackerWaitStopped {
+			<-t.announcersStoppedC
+			t.stoppedEventFailed = !t.stoppedEventAnnouncer.Succeeded()
+		}
 		t.stoppedEventAnnouncer.Close()
 	}
-
-	t.downloadSpeed.Stop()
-	t.uploadSpeed.Stop()
 }
 
 func (t *torrent) closePeer(pe *peer.Peer) {
@@ -33,17 +42,26 @@ func (t *torrent) closePeer(pe *peer.Peer) {
 		t.closeInfoDownloader(id)
 	}
 	delete(t.peers, pe)
+	delete(t.peerDownloadRates, pe)
 	delete(t.incomingPeers, pe)
 	delete(t.outgoingPeers, pe)
-	delete(t.peerIDs, pe.ID)
+	if t.peerIDs[pe.ID] == pe {
+		delete(t.peerIDs, pe.ID)
+	}
+	delete(t.resumePeersMarked, pe)
+	delete(t.superSeedPeers, pe)
 	delete(t.connectedPeerIPs, pe.Conn.IP())
 	if t.piecePicker != nil {
 		t.piecePicker.HandleDisconnect(pe)
 	}
 	t.unchoker.HandleDisconnect(pe)
 	t.pexDropPeer(pe.Addr())
+	t.removeHolepunchRelay(pe)
 	t.dialAddresses()
 	t.session.metrics.Peers.Dec(1)
+	atomic.AddInt32(&t.numPeers, -1)
+	t.checkNoPeers()
+	t.checkMinConnectedPeers()
 }
 
 func (t *torrent) closeWebseedDownloader(src *webseedsource.WebseedSource) {
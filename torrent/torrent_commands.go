@@ -19,6 +19,14 @@ func (t *torrent) Start() {
 	}
 }
 
+// startWithAnnounceDelay starts the torrent like Start and delays the first announce to trackers by d.
+func (t *torrent) startWithAnnounceDelay(d time.Duration) {
+	select {
+	case t.startDelayedCommandC <- d:
+	case <-t.closeC:
+	}
+}
+
 // Stop downloading and seeding.
 // Stop closes all peer connections.
 func (t *torrent) Stop() {
@@ -28,7 +36,7 @@ func (t *torrent) Stop() {
 	}
 }
 
-// Announce torrent to trackers and DHT manually.
+// Announce torrent to trackers, DHT and LSD manually.
 func (t *torrent) Announce() {
 	select {
 	case t.announceCommandC <- struct{}{}:
@@ -36,6 +44,46 @@ func (t *torrent) Announce() {
 	}
 }
 
+// SetAnnounceInterval overrides the interval returned from trackers.
+func (t *torrent) SetAnnounceInterval(d time.Duration) {
+	select {
+	case t.announceIntervalCommandC <- d:
+	case <-t.closeC:
+	}
+}
+
+// Pause downloading and uploading while keeping the peers connected.
+func (t *torrent) Pause() {
+	select {
+	case t.pauseCommandC <- struct{}{}:
+	case <-t.closeC:
+	}
+}
+
+// Resume downloading and uploading after Pause.
+func (t *torrent) Resume() {
+	select {
+	case t.resumeCommandC <- struct{}{}:
+	case <-t.closeC:
+	}
+}
+
+// SetSequential sets whether pieces are downloaded in order.
+func (t *torrent) SetSequential(value bool) {
+	select {
+	case t.sequentialCommandC <- value:
+	case <-t.closeC:
+	}
+}
+
+// SetSuperSeeding sets whether the pieces are revealed to new peers one by one.
+func (t *torrent) SetSuperSeeding(value bool) {
+	select {
+	case t.superSeedingCommandC <- value:
+	case <-t.closeC:
+	}
+}
+
 // Verify pieces by checking files.
 func (t *torrent) Verify() {
 	select {
@@ -95,6 +143,10 @@ func (t *torrent) Magnet() (string, error) {
 		Trackers: t.getTieredTrackers(),
 		Peers:    t.fixedPeers,
 	}
+	if t.hashV2 != nil {
+		h := *t.hashV2
+		m.InfoHashV2 = &h
+	}
 	return m.String(), nil
 }
 
@@ -106,19 +158,18 @@ func (t *torrent) Torrent() ([]byte, error) {
 	for i, ws := range t.webseedSources {
 		webseeds[i] = ws.URL
 	}
-	return metainfo.NewBytes(t.info.Bytes, t.getTieredTrackers(), webseeds, "")
+	return metainfo.NewBytesWithPieceLayers(t.info.Bytes, t.info.PieceLayers, t.getTieredTrackers(), webseeds, t.meta.Comment)
 }
 
 func (t *torrent) getTieredTrackers() [][]string {
 	var trackers [][]string
 	for _, tr := range t.trackers {
-		if tier, ok := tr.(*tracker.Tier); ok {
-			urls := make([]string, len(tier.Trackers))
-			for i, tt := range tier.Trackers {
-				urls[i] = tt.URL()
-			}
-			trackers = append(trackers, urls)
-		} else {
+		switch tr := tr.(type) {
+		case *tracker.AnnounceList:
+			trackers = append(trackers, tr.URLs()...)
+		case *tracker.Tier:
+			trackers = append(trackers, tr.URLs())
+		default:
 			trackers = append(trackers, []string{tr.URL()})
 		}
 	}
@@ -191,7 +242,13 @@ type Tracker struct {
 	Error        *AnnounceError
 	Warning      string
 	LastAnnounce time.Time
+	// Time of the last successful announce. Zero if the tracker has not responded yet.
+	// Seeders and Leechers are the numbers received at this time.
+	LastSuccess  time.Time
 	NextAnnounce time.Time
+	// Number of successful and failed announces since the torrent is started.
+	Successes int
+	Failures  int
 }
 
 type trackersRequest struct {
@@ -212,6 +269,147 @@ func (t *torrent) Trackers() []Tracker {
 	return trackers
 }
 
+// SwarmStats contains the estimated number of seeders and leechers in the swarm of the torrent.
+type SwarmStats struct {
+	// Estimated number of seeders and leechers in the swarm.
+	// The larger of the numbers reported by trackers and the numbers of connected peers.
+	Seeders  int
+	Leechers int
+	// Largest numbers reported in announce responses of trackers.
+	// Numbers are not summed because trackers of a torrent mostly know the same peers.
+	// Trackers on the same host are counted once with their latest response.
+	TrackerSeeders  int
+	TrackerLeechers int
+	// Number of connected peers that have all pieces or not.
+	// Peers that did not send their bitfield yet are counted as leechers.
+	ConnectedSeeders  int
+	ConnectedLeechers int
+}
+
+type swarmStatsRequest struct {
+	Response chan SwarmStats
+}
+
+func (t *torrent) SwarmStats() SwarmStats {
+	var stats SwarmStats
+	req := swarmStatsRequest{Response: make(chan SwarmStats, 1)}
+	select {
+	case t.swarmStatsCommandC <- req:
+	case <-t.closeC:
+	}
+	select {
+	case stats = <-req.Response:
+	case <-t.closeC:
+	}
+	return stats
+}
+
+type pieceProgressRequest struct {
+	Index    uint32
+	Response chan pieceProgress
+}
+
+type pieceProgress struct {
+	Done, Total int
+}
+
+func (t *torrent) PieceProgress(index uint32) (blocksDone, blocksTotal int) {
+	var p pieceProgress
+	req := pieceProgressRequest{Index: index, Response: make(chan pieceProgress, 1)}
+	select {
+	case t.pieceProgressCommandC <- req:
+	case <-t.closeC:
+	}
+	select {
+	case p = <-req.Response:
+	case <-t.closeC:
+	}
+	return p.Done, p.Total
+}
+
+// PieceMap is a snapshot of the pieces of a torrent.
+type PieceMap struct {
+	// Number of pieces in the torrent. Zero if the metadata is not downloaded yet.
+	NumPieces uint32
+	// Bitfield of downloaded and verified pieces, most significant bit of the first byte is the first piece.
+	// Nil if the pieces are not verified yet.
+	Bitfield []byte
+	// Pieces that are being downloaded at the moment.
+	Downloading []DownloadingPiece
+}
+
+// DownloadingPiece is a piece that is being downloaded from peers or webseed sources.
+type DownloadingPiece struct {
+	Index uint32
+	// Number of received blocks of the most advanced download of the piece.
+	BlocksDone  int
+	BlocksTotal int
+	// Addresses of the peers that the piece is being downloaded from.
+	// In endgame mode, a piece may be downloaded from multiple peers.
+	Peers []net.Addr
+	// URLs of the webseed sources that the piece is being downloaded from.
+	Webseeds []string
+}
+
+type pieceMapRequest struct {
+	Response chan PieceMap
+}
+
+func (t *torrent) PieceMap() PieceMap {
+	var pm PieceMap
+	req := pieceMapRequest{Response: make(chan PieceMap, 1)}
+	select {
+	case t.pieceMapCommandC <- req:
+	case <-t.closeC:
+	}
+	select {
+	case pm = <-req.Response:
+	case <-t.closeC:
+	}
+	return pm
+}
+
+type filePriorityRequest struct {
+	Index    int
+	Priority FilePriority
+	Response chan error
+}
+
+func (t *torrent) SetFilePriority(index int, priority FilePriority) error {
+	req := filePriorityRequest{Index: index, Priority: priority, Response: make(chan error, 1)}
+	select {
+	case t.filePriorityCommandC <- req:
+	case <-t.closeC:
+		return errClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.closeC:
+		return errClosed
+	}
+}
+
+type downloadPiecesRequest struct {
+	Indices  []uint32
+	Response chan error
+}
+
+func (t *torrent) DownloadPieces(indices []uint32) error {
+	req := downloadPiecesRequest{Indices: indices, Response: make(chan error, 1)}
+	select {
+	case t.downloadPiecesCommandC <- req:
+	case <-t.closeC:
+		return errClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.closeC:
+		return errClosed
+	}
+}
+
 // Peer is a remote peer that is connected and completed protocol handshake.
 type Peer struct {
 	ID                 [20]byte
@@ -230,6 +428,16 @@ type Peer struct {
 	EncryptedStream    bool
 	DownloadSpeed      int
 	UploadSpeed        int
+	// Number of block requests sent to the peer that are not received yet.
+	RequestsOut int
+	// Max number of outstanding requests we send to the peer.
+	MaxRequestsOut int
+	// Request queue length advertised by the peer in "reqq" field of extension handshake. 0 if not advertised.
+	PeerRequestQueue int
+	// Moving average of the time between sending a request and receiving the block.
+	RequestLatency time.Duration
+	// Ratio of bytes downloaded from the peer to bytes uploaded to the peer. 0 if nothing is uploaded to the peer.
+	ReciprocationRatio float64
 }
 
 // PeerSource indicates that how the peer is found.
@@ -246,6 +454,10 @@ const (
 	SourceIncoming
 	// SourceManual indicates that the peer is added manually via AddPeer method.
 	SourceManual
+	// SourceResume indicates that the peer is saved in resume data in a previous run.
+	SourceResume
+	// SourceLSD indicates that the peer is found on the local network.
+	SourceLSD
 )
 
 type peersRequest struct {
@@ -270,6 +482,7 @@ func (t *torrent) Peers() []Peer {
 // Client can download from these sources along with peers from the swarm.
 type Webseed struct {
 	URL           string
+	Trusted       bool
 	Error         error
 	DownloadSpeed int
 }
@@ -278,6 +491,24 @@ type webseedsRequest struct {
 	Response chan []Webseed
 }
 
+type filesRequest struct {
+	Response chan []File
+}
+
+func (t *torrent) Files() []File {
+	var files []File
+	req := filesRequest{Response: make(chan []File, 1)}
+	select {
+	case t.filesCommandC <- req:
+	case <-t.closeC:
+	}
+	select {
+	case files = <-req.Response:
+	case <-t.closeC:
+	}
+	return files
+}
+
 func (t *torrent) Webseeds() []Webseed {
 	var webseeds []Webseed
 	req := webseedsRequest{Response: make(chan []Webseed, 1)}
@@ -8,37 +8,64 @@ import (
 
 func (t *torrent) handleNewConnection(conn net.Conn) {
 	if len(t.incomingHandshakers)+len(t.incomingPeers) >= t.session.config.MaxPeerAccept {
+		t.session.metrics.IncomingRejected.Inc(1)
 		t.log.Debugln("peer limit reached, rejecting peer", conn.RemoteAddr().String())
+		t.recordRejectedConnection(conn.RemoteAddr(), "peer limit reached")
+		conn.Close()
+		return
+	}
+	if len(t.incomingHandshakers) >= t.session.config.MaxPendingIncomingHandshakes {
+		t.session.metrics.IncomingRejected.Inc(1)
+		t.log.Debugln("pending handshake limit reached, rejecting peer", conn.RemoteAddr().String())
+		t.recordRejectedConnection(conn.RemoteAddr(), "pending handshake limit reached")
+		conn.Close()
+		return
+	}
+	if t.connectionLimitReached() {
+		t.session.metrics.IncomingRejected.Inc(1)
+		t.log.Debugln("connection limit reached, rejecting peer", conn.RemoteAddr().String())
+		t.recordRejectedConnection(conn.RemoteAddr(), "connection limit reached")
 		conn.Close()
 		return
 	}
 	ip := conn.RemoteAddr().(*net.TCPAddr).IP
 	ipstr := ip.String()
 	if t.session.config.BlocklistEnabledForIncomingConnections && t.session.blocklist != nil && t.session.blocklist.Blocked(ip) {
-		t.log.Debugln("peer is blocked:", conn.RemoteAddr().String())
+		t.session.metrics.BlockListRejected.Inc(1)
+		t.log.Debugln("peer is blocked:", conn.RemoteAddr().String(), "total rejected:", t.session.metrics.BlockListRejected.Count())
+		t.recordRejectedConnection(conn.RemoteAddr(), "blocked IP")
 		conn.Close()
 		return
 	}
 	if _, ok := t.connectedPeerIPs[ipstr]; ok {
 		t.log.Debugln("received duplicate connection from same IP: ", ipstr)
+		t.recordRejectedConnection(conn.RemoteAddr(), "duplicate connection")
 		conn.Close()
 		return
 	}
 	if _, ok := t.bannedPeerIPs[ipstr]; ok {
 		t.log.Debugln("connection attempt from banned IP: ", ipstr)
+		t.recordRejectedConnection(conn.RemoteAddr(), "banned IP")
 		conn.Close()
 		return
 	}
+	_, _, enableEncryption, forceEncryption := t.session.config.encryptionPolicy()
+	getSKey := t.getSKey
+	if !enableEncryption {
+		// Encrypted handshakes cannot be completed without the secret keys.
+		getSKey = nil
+	}
 	h := incominghandshaker.New(conn)
 	t.incomingHandshakers[h] = struct{}{}
+	t.updateNumHandshakers()
 	t.connectedPeerIPs[ipstr] = struct{}{}
 	go h.Run(
 		t.peerID,
-		t.getSKey,
+		getSKey,
 		t.checkInfoHash,
 		t.incomingHandshakerResultC,
 		t.session.config.PeerHandshakeTimeout,
 		t.session.extensions,
-		t.session.config.ForceIncomingEncryption,
+		forceEncryption,
 	)
 }
@@ -0,0 +1,71 @@
+package torrent
+
+import (
+	"errors"
+)
+
+var errInvalidPieceIndex = errors.New("invalid piece index")
+
+// downloadPieces raises the priority of the pieces at indices so they are downloaded before others,
+// even if they are in skipped files. EventPiecesDownloaded is sent when all of them are downloaded.
+func (t *torrent) downloadPieces(indices []uint32) error {
+	if t.info == nil {
+		return errors.New("torrent metadata is not downloaded yet")
+	}
+	for _, i := range indices {
+		if i >= t.info.NumPieces {
+			return errInvalidPieceIndex
+		}
+	}
+	indices = append([]uint32(nil), indices...)
+	if t.bitfield != nil && t.piecesDone(indices) {
+		t.sendEvent(Event{Type: EventPiecesDownloaded, Pieces: indices})
+		return nil
+	}
+	t.pieceRequests = append(t.pieceRequests, indices)
+	if t.pieces == nil || t.bitfield == nil {
+		// Requested pieces are applied after allocation and verification is done.
+		return nil
+	}
+	t.updateWantedPieces()
+	return nil
+}
+
+// updateWantedPieces applies the changes in the pieces that are going to be downloaded
+// and starts downloading again if a seeding torrent has missing pieces.
+func (t *torrent) updateWantedPieces() {
+	t.applyFilePriorities()
+	if t.completed && !t.wantedPiecesDone() {
+		t.resumeDownload()
+	}
+	for pe := range t.peers {
+		t.updateInterestedState(pe)
+	}
+	t.startPieceDownloaders()
+}
+
+// piecesDone returns true if all pieces at indices are downloaded.
+func (t *torrent) piecesDone(indices []uint32) bool {
+	for _, i := range indices {
+		if !t.bitfield.Test(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPieceRequests sends EventPiecesDownloaded for each DownloadPieces call whose pieces are all downloaded.
+func (t *torrent) checkPieceRequests() {
+	if len(t.pieceRequests) == 0 {
+		return
+	}
+	remaining := t.pieceRequests[:0]
+	for _, indices := range t.pieceRequests {
+		if t.piecesDone(indices) {
+			t.sendEvent(Event{Type: EventPiecesDownloaded, Pieces: indices})
+		} else {
+			remaining = append(remaining, indices)
+		}
+	}
+	t.pieceRequests = remaining
+}
@@ -0,0 +1,74 @@
+package torrent
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+)
+
+func TestDownloadPieces(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	tor.torrent.trackers = nil
+	index := -1
+	var offset int64
+	for i, fi := range tor.torrent.info.Files {
+		if filepath.Base(fi.Path) == "zero.bin" {
+			index = i
+			break
+		}
+		offset += fi.Length
+	}
+	if err := tor.SetFilePriority(index, FilePrioritySkip); err != nil {
+		t.Fatal(err)
+	}
+	if err := tor.DownloadPieces([]uint32{tor.torrent.info.NumPieces}); err != errInvalidPieceIndex {
+		t.Fatalf("invalid piece index must be rejected: %v", err)
+	}
+	tor.Start()
+	if err := tor.AddPeer(addr); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-tor.torrent.NotifyComplete():
+	case err := <-tor.torrent.NotifyError():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		t.Fatal("download did not finish")
+	}
+	waitPieces := func(pieces []uint32) {
+		e := waitEvent(t, tor, EventPiecesDownloaded)
+		if len(e.Pieces) != len(pieces) || e.Pieces[0] != pieces[0] {
+			t.Fatalf("unexpected pieces: %v", e.Pieces)
+		}
+	}
+
+	// A piece in the middle of the skipped file.
+	pieces := []uint32{uint32(offset/int64(tor.torrent.info.PieceLength)) + 1}
+	if err := tor.DownloadPieces(pieces); err != nil {
+		t.Fatal(err)
+	}
+	// Seeder is disconnected on completion. Its IP is learned as our external IP, so connect to it over another loopback address.
+	if err := tor.AddPeer(strings.Replace(addr, "127.0.0.1", "127.0.0.2", 1)); err != nil {
+		t.Fatal(err)
+	}
+	waitPieces(pieces)
+	stats := tor.Stats()
+	if stats.Bytes.Completed >= stats.Bytes.Total/2 {
+		t.Fatalf("pieces of skipped file are downloaded: %d bytes", stats.Bytes.Completed)
+	}
+
+	// Downloaded pieces are reported immediately.
+	if err := tor.DownloadPieces(pieces); err != nil {
+		t.Fatal(err)
+	}
+	waitPieces(pieces)
+}
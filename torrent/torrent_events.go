@@ -0,0 +1,87 @@
+package torrent
+
+import (
+	"time"
+
+	"github.com/cenkalti/rain/internal/announcer"
+)
+
+// EventType is the type of a torrent lifecycle Event.
+type EventType int
+
+const (
+	// EventMetadataReceived is sent when the info dictionary of a magnet link is downloaded from peers.
+	EventMetadataReceived EventType = iota
+	// EventVerificationComplete is sent when the existing data on disk is checked.
+	EventVerificationComplete
+	// EventDownloadComplete is sent when all wanted pieces are downloaded.
+	EventDownloadComplete
+	// EventSeedLimitReached is sent when Config.SeedRatioLimit or Config.SeedTimeLimit is reached.
+	EventSeedLimitReached
+	// EventTrackerError is sent when an announce to a tracker fails.
+	EventTrackerError
+	// EventStopped is sent when the torrent is stopped.
+	EventStopped
+	// EventPiecesDownloaded is sent when all pieces requested in a Torrent.DownloadPieces call are downloaded.
+	EventPiecesDownloaded
+	// EventPieceDeadlineMissed is sent when pieces with a deadline set by Torrent.SetPieceDeadline
+	// are not expected to be downloaded in time at the current download speed, or their deadlines have passed.
+	// It is sent once for each deadline, the pieces are still downloaded before others.
+	EventPieceDeadlineMissed
+	// EventDiskError is sent when the torrent is paused because a downloaded piece cannot be written to the disk.
+	EventDiskError
+)
+
+func (e EventType) String() string {
+	m := map[EventType]string{
+		EventMetadataReceived:     "Metadata Received",
+		EventVerificationComplete: "Verification Complete",
+		EventDownloadComplete:     "Download Complete",
+		EventSeedLimitReached:     "Seed Limit Reached",
+		EventTrackerError:         "Tracker Error",
+		EventStopped:              "Stopped",
+		EventPiecesDownloaded:     "Pieces Downloaded",
+		EventPieceDeadlineMissed:  "Piece Deadline Missed",
+		EventDiskError:            "Disk Error",
+	}
+	return m[e]
+}
+
+// Event is sent to the channel returned from Torrent.Events when the state of the torrent changes.
+type Event struct {
+	Type EventType
+	Time time.Time
+	// Set for EventTrackerError and EventDiskError, and for EventStopped if the torrent is stopped because of an error.
+	Error error
+	// URL of the tracker for EventTrackerError.
+	Tracker string
+	// Indices of the pieces passed to Torrent.DownloadPieces for EventPiecesDownloaded,
+	// and the pieces that are going to miss their deadlines for EventPieceDeadlineMissed.
+	Pieces []uint32
+}
+
+// sendEvent queues the event to be sent to the channel returned from Torrent.Events.
+// Events are not dropped, the queue grows until they are received.
+// sendEvent must be called from the torrent event loop only.
+func (t *torrent) sendEvent(e Event) {
+	e.Time = time.Now()
+	t.events = append(t.events, e)
+}
+
+// nextEvent returns the channel and the event to send in the event loop.
+// Returned channel is nil if there are no events waiting, so sending is disabled in select.
+func (t *torrent) nextEvent() (chan Event, Event) {
+	if len(t.events) == 0 {
+		return nil, Event{}
+	}
+	return t.eventsC, t.events[0]
+}
+
+func (t *torrent) handleEventSent() {
+	t.events[0] = Event{}
+	t.events = t.events[1:]
+}
+
+func (t *torrent) handleTrackerError(terr announcer.TrackerError) {
+	t.sendEvent(Event{Type: EventTrackerError, Error: &AnnounceError{terr.Err}, Tracker: terr.URL})
+}
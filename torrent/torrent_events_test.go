@@ -0,0 +1,54 @@
+package torrent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+)
+
+func TestEvents(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor, err := s.AddURI(torrentMagnetLink+"&x.pe="+addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitEvent := func(typ EventType) Event {
+		for {
+			select {
+			case e, ok := <-tor.Events():
+				if !ok {
+					t.Fatalf("events channel is closed before %s event", typ)
+				}
+				if e.Type == typ {
+					return e
+				}
+			case <-time.After(timeout):
+				t.Fatalf("%s event is not received", typ)
+			}
+		}
+	}
+	waitEvent(EventMetadataReceived)
+	waitEvent(EventDownloadComplete)
+	tor.Stop()
+	if e := waitEvent(EventStopped); e.Error != nil {
+		t.Fatal(e.Error)
+	}
+	err = s.RemoveTorrent(tor.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case _, ok := <-tor.Events():
+		if ok {
+			t.Fatal("unexpected event after stop")
+		}
+	case <-time.After(timeout):
+		t.Fatal("events channel is not closed")
+	}
+}
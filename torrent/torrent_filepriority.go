@@ -0,0 +1,246 @@
+package torrent
+
+import (
+	"errors"
+
+	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/piecepicker"
+)
+
+// FilePriority determines whether and in which order the pieces of a file in torrent are downloaded.
+type FilePriority int
+
+const (
+	// FilePrioritySkip files are not downloaded.
+	FilePrioritySkip FilePriority = -1
+	// FilePriorityNormal files are downloaded. This is the default.
+	FilePriorityNormal FilePriority = 0
+	// FilePriorityHigh files are downloaded before the files with normal priority.
+	FilePriorityHigh FilePriority = 1
+)
+
+var errInvalidFileIndex = errors.New("invalid file index")
+
+// File in a torrent.
+type File struct {
+	// Path of the file relative to the data directory of the torrent.
+	Path string
+	// Size of the file in bytes.
+	Length int64
+	// Padding files are not saved to disk.
+	Padding bool
+	// Download priority of the file.
+	Priority FilePriority
+}
+
+// getFiles returns the list of files with their priorities. Returns nil if the metadata is not downloaded yet.
+func (t *torrent) getFiles() []File {
+	if t.info == nil {
+		return nil
+	}
+	files := make([]File, len(t.info.Files))
+	for i, f := range t.info.Files {
+		files[i] = File{
+			Path:     f.Path,
+			Length:   f.Length,
+			Padding:  f.Padding,
+			Priority: t.filePriority(i),
+		}
+	}
+	return files
+}
+
+// defaultFilePriorities returns the initial priorities of files in a torrent whose file list has just become known.
+// Returns nil if all files are downloaded.
+func (s *Session) defaultFilePriorities(info *metainfo.Info) []FilePriority {
+	if s.config.DefaultFilePriority == FilePriorityNormal {
+		return nil
+	}
+	priorities := make([]FilePriority, len(info.Files))
+	for i := range priorities {
+		priorities[i] = s.config.DefaultFilePriority
+	}
+	return priorities
+}
+
+// filePriority returns the priority of the file at index i. Files have normal priority if priorities are not set.
+func (t *torrent) filePriority(i int) FilePriority {
+	if i >= len(t.filePriorities) {
+		return FilePriorityNormal
+	}
+	return t.filePriorities[i]
+}
+
+// skippedFiles returns the files that are not created on disk by allocator. Returns nil if no file is skipped.
+func (t *torrent) skippedFiles() []bool {
+	var ret []bool
+	for i, p := range t.filePriorities {
+		if p != FilePrioritySkip {
+			continue
+		}
+		if ret == nil {
+			ret = make([]bool, len(t.info.Files))
+		}
+		ret[i] = true
+	}
+	return ret
+}
+
+// applyFilePriorities calculates the priorities of pieces from the priorities of files in them.
+// A piece is skipped if it contains only skipped files and has high priority if it contains a file with high priority.
+// Pieces requested with DownloadPieces are never skipped and have high priority.
+// Must be called after pieces are created.
+func (t *torrent) applyFilePriorities() {
+	wanted := make([]bool, t.info.NumPieces)
+	high := make([]bool, t.info.NumPieces)
+	var offset int64
+	for i, f := range t.info.Files {
+		begin := offset
+		offset += f.Length
+		priority := t.filePriority(i)
+		if f.Length == 0 || f.Padding || priority == FilePrioritySkip {
+			continue
+		}
+		first := uint32(begin / int64(t.info.PieceLength))
+		last := uint32((offset - 1) / int64(t.info.PieceLength))
+		for j := first; j <= last; j++ {
+			wanted[j] = true
+			if priority == FilePriorityHigh {
+				high[j] = true
+			}
+		}
+	}
+	for _, indices := range t.pieceRequests {
+		for _, i := range indices {
+			wanted[i] = true
+			high[i] = true
+		}
+	}
+	for i := range t.pieceDeadlines {
+		wanted[i] = true
+	}
+	t.skippedPieces = nil
+	for i, ok := range wanted {
+		if ok {
+			continue
+		}
+		if t.skippedPieces == nil {
+			t.skippedPieces = bitfield.New(t.info.NumPieces)
+		}
+		t.skippedPieces.Set(uint32(i))
+	}
+	if t.piecePicker != nil {
+		for i := range wanted {
+			t.piecePicker.SetSkipped(uint32(i), !wanted[i])
+			t.piecePicker.SetHigh(uint32(i), high[i])
+			t.piecePicker.SetDeadline(uint32(i), t.pieceDeadlines[uint32(i)].Time)
+		}
+	}
+}
+
+// pieceSkipped returns true if the piece at index i is not downloaded because of file priorities.
+func (t *torrent) pieceSkipped(i uint32) bool {
+	return t.skippedPieces != nil && t.skippedPieces.Test(i)
+}
+
+// wantedPiecesDone returns true if all pieces except the skipped ones are downloaded.
+func (t *torrent) wantedPiecesDone() bool {
+	if t.skippedPieces == nil {
+		return t.bitfield.All()
+	}
+	for i := uint32(0); i < t.bitfield.Len(); i++ {
+		if !t.bitfield.Test(i) && !t.skippedPieces.Test(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// setDefaultFilePriorities sets the priorities of files after the metadata of a magnet link is downloaded.
+func (t *torrent) setDefaultFilePriorities() error {
+	t.filePriorities = t.session.defaultFilePriorities(t.info)
+	return t.session.resumer.WriteFilePriorities(t.id, filePrioritiesToInts(t.filePriorities))
+}
+
+// setFilePriority changes the priority of the file at index and updates the pieces that are going to be downloaded.
+// If a seeding torrent has missing pieces after the change, it starts downloading again.
+func (t *torrent) setFilePriority(index int, priority FilePriority) error {
+	if t.info == nil {
+		return errors.New("torrent metadata is not downloaded yet")
+	}
+	if index < 0 || index >= len(t.info.Files) {
+		return errInvalidFileIndex
+	}
+	if t.filePriority(index) == priority {
+		return nil
+	}
+	if t.filePriorities == nil {
+		t.filePriorities = make([]FilePriority, len(t.info.Files))
+	}
+	t.filePriorities[index] = priority
+	err := t.session.resumer.WriteFilePriorities(t.id, filePrioritiesToInts(t.filePriorities))
+	if err != nil {
+		return err
+	}
+	if t.pieces == nil || t.bitfield == nil {
+		// Priorities are applied after allocation and verification is done.
+		return nil
+	}
+	t.applyFilePriorities()
+	if t.completed && !t.wantedPiecesDone() {
+		t.resumeDownload()
+	} else if !t.completed && t.wantedPiecesDone() {
+		t.checkCompletion()
+	}
+	for pe := range t.peers {
+		t.updateInterestedState(pe)
+	}
+	t.startPieceDownloaders()
+	return nil
+}
+
+// resumeDownload switches a completed torrent back to downloading state when there are new pieces to download.
+func (t *torrent) resumeDownload() {
+	t.completed = false
+	t.completeC = make(chan struct{})
+	t.piecePicker = piecepicker.New(t.pieces, t.session.config.EndgameMaxDuplicateDownloads, t.session.config.EndgameThreshold, t.webseedSources)
+	t.applyFilePriorities()
+	t.piecePicker.SetSequential(t.sequential, uint32(t.session.config.SequentialWindow))
+	for pe := range t.peers {
+		if pe.Bitfield == nil {
+			continue
+		}
+		for i := uint32(0); i < pe.Bitfield.Len(); i++ {
+			if pe.Bitfield.Test(i) {
+				t.piecePicker.HandleHave(pe, i)
+			}
+		}
+	}
+	if s := t.status(); s == Downloading {
+		t.setNeedMorePeers(true)
+		t.dialAddresses()
+	}
+}
+
+func filePrioritiesToInts(priorities []FilePriority) []int {
+	if priorities == nil {
+		return nil
+	}
+	ret := make([]int, len(priorities))
+	for i, p := range priorities {
+		ret[i] = int(p)
+	}
+	return ret
+}
+
+func filePrioritiesFromInts(values []int) []FilePriority {
+	if values == nil {
+		return nil
+	}
+	ret := make([]FilePriority, len(values))
+	for i, v := range values {
+		ret[i] = FilePriority(v)
+	}
+	return ret
+}
@@ -0,0 +1,128 @@
+package torrent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+)
+
+func TestDefaultFilePriorityMagnet(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	s.config.DefaultFilePriority = FilePrioritySkip
+
+	tor, err := s.AddURI(torrentMagnetLink+"&x.pe="+addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, tor, EventMetadataReceived)
+	// All files are skipped, so there is nothing to download after the metadata is received.
+	waitEvent(t, tor, EventDownloadComplete)
+	if downloaded := tor.Stats().Bytes.Downloaded; downloaded != 0 {
+		t.Fatalf("pieces of skipped files are downloaded: %d bytes", downloaded)
+	}
+
+	// Files are downloaded after the user opts in.
+	for i := range tor.Files() {
+		if err = tor.SetFilePriority(i, FilePriorityNormal); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st := tor.Stats().Status; st != Downloading {
+		t.Fatalf("torrent must be downloading, status: %s", st)
+	}
+	// Seeder is disconnected on completion. Its IP is learned as our external IP, so connect to it over another loopback address.
+	if err = tor.AddPeer(strings.Replace(addr, "127.0.0.1", "127.0.0.2", 1)); err != nil {
+		t.Fatal(err)
+	}
+	assertCompleted(t, tor)
+}
+
+func TestSetFilePriority(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	tor.torrent.trackers = nil
+	index := -1
+	for i, fi := range tor.torrent.info.Files {
+		if filepath.Base(fi.Path) == "zero.bin" {
+			index = i
+		}
+	}
+	if err := tor.SetFilePriority(index, FilePrioritySkip); err != nil {
+		t.Fatal(err)
+	}
+	if err := tor.SetFilePriority(len(tor.torrent.info.Files), FilePriorityHigh); err == nil {
+		t.Fatal("invalid file index must be rejected")
+	}
+	tor.Start()
+	if err := tor.AddPeer(addr); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-tor.torrent.NotifyComplete():
+	case err := <-tor.torrent.NotifyError():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		t.Fatal("download did not finish")
+	}
+	stats := tor.Stats()
+	if stats.Bytes.Completed >= stats.Bytes.Total/2 {
+		t.Fatalf("pieces of skipped file are downloaded: %d bytes", stats.Bytes.Completed)
+	}
+
+	// Torrent continues downloading when the file is not skipped anymore.
+	if err := tor.SetFilePriority(index, FilePriorityNormal); err != nil {
+		t.Fatal(err)
+	}
+	if st := tor.Stats().Status; st != Downloading {
+		t.Fatalf("torrent must be downloading, status: %s", st)
+	}
+	// Seeder is disconnected on completion. Its IP is learned as our external IP, so connect to it over another loopback address.
+	if err := tor.AddPeer(strings.Replace(addr, "127.0.0.1", "127.0.0.2", 1)); err != nil {
+		t.Fatal(err)
+	}
+	assertCompleted(t, tor)
+}
+
+func TestVerifySkippedFile(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	err := os.Mkdir(filepath.Join(s.config.DataDir, tor.ID()), os.ModeDir|0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = CopyDir(filepath.Join(torrentDataDir, torrentName), filepath.Join(s.config.DataDir, tor.ID(), torrentName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, fi := range tor.torrent.info.Files {
+		if filepath.Base(fi.Path) == "zero.bin" {
+			if err = tor.SetFilePriority(i, FilePrioritySkip); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err = tor.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	// Pieces of the skipped file are already on disk, so they must not be reported as missing.
+	waitStatus(t, tor, Stopped)
+	if st := tor.Stats(); st.Pieces.Have != st.Pieces.Total {
+		t.Fatalf("verified %d of %d pieces", st.Pieces.Have, st.Pieces.Total)
+	}
+}
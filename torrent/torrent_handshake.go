@@ -5,35 +5,134 @@ import (
 
 	"github.com/cenkalti/rain/internal/handshaker/incominghandshaker"
 	"github.com/cenkalti/rain/internal/handshaker/outgoinghandshaker"
+	"github.com/cenkalti/rain/internal/mse"
 	"github.com/cenkalti/rain/internal/peersource"
 )
 
+// getSKey is called from incoming handshakers. Peers may connect for any of the torrents in the session.
 func (t *torrent) getSKey(sKeyHash [20]byte) []byte {
 	if sKeyHash == t.sKeyHash {
 		return t.infoHash[:]
 	}
-	return nil
+	if t.hasInfoHashV2() && sKeyHash == t.sKeyHashV2 {
+		return t.infoHashV2[:]
+	}
+	return t.session.getSKey(sKeyHash)
 }
 
+// checkInfoHash is called from incoming handshakers. Peers may connect for any of the torrents in the session.
+// Connections for other torrents are handed over to them after the handshake.
 func (t *torrent) checkInfoHash(infoHash [20]byte) bool {
-	return infoHash == t.infoHash
+	return t.matchesInfoHash(infoHash) || t.session.findTorrentByInfoHash(infoHash) != nil
+}
+
+func (t *torrent) hasInfoHashV2() bool {
+	return t.infoHashV2 != [20]byte{}
+}
+
+// setInfoHashV2 sets the v2 info hash that is accepted in incoming handshakes.
+// Must be called before the torrent is inserted into the session because handshakers read it without locking.
+func (t *torrent) setInfoHashV2(h [32]byte) {
+	t.hashV2 = &h
+	copy(t.infoHashV2[:], h[:])
+	t.sKeyHashV2 = mse.HashSKey(t.infoHashV2[:])
+}
+
+// matchesInfoHash returns true if the info hash in handshake is for this torrent.
+// Hybrid torrents are downloaded with v1 piece hashes, so peers from v2 swarm are accepted
+// as long as they send v1 messages. We do not advertise v2 support in handshake.
+func (t *torrent) matchesInfoHash(infoHash [20]byte) bool {
+	return infoHash == t.infoHash || (t.hasInfoHashV2() && infoHash == t.infoHashV2)
 }
 
 func (t *torrent) handleIncomingHandshakeDone(ih *incominghandshaker.IncomingHandshaker) {
 	delete(t.incomingHandshakers, ih)
+	t.updateNumHandshakers()
 	if ih.Error != nil {
 		delete(t.connectedPeerIPs, ih.Conn.RemoteAddr().(*net.TCPAddr).IP.String())
+		t.recordConnection(ih.Conn.RemoteAddr(), false, ih.Cipher, connectionStage(ih.Error), ih.Error)
+		return
+	}
+	if !t.matchesInfoHash(ih.InfoHash) {
+		delete(t.connectedPeerIPs, ih.Conn.RemoteAddr().(*net.TCPAddr).IP.String())
+		target := t.session.findTorrentByInfoHash(ih.InfoHash)
+		if target == nil {
+			t.log.Debugln("peer has connected for a removed torrent:", ih.Conn.RemoteAddr().String())
+			t.recordConnection(ih.Conn.RemoteAddr(), false, ih.Cipher, StageInfoHash, errTorrentRemoved)
+			ih.Conn.Close()
+			return
+		}
+		go target.handOverIncomingPeer(ih)
+		return
+	}
+	t.startPeer(ih.Conn, peersource.Incoming, t.incomingPeers, ih.PeerID, ih.Extensions, ih.Cipher)
+}
+
+func (t *torrent) handOverIncomingPeer(ih *incominghandshaker.IncomingHandshaker) {
+	select {
+	case t.handedOverPeerC <- ih:
+	case <-t.closeC:
+		ih.Conn.Close()
+	}
+}
+
+// handleHandedOverPeer starts a peer that has done the handshake on the port of another torrent.
+func (t *torrent) handleHandedOverPeer(ih *incominghandshaker.IncomingHandshaker) {
+	addr := ih.Conn.RemoteAddr().(*net.TCPAddr)
+	ipstr := addr.IP.String()
+	reject := func(reason string) {
+		t.log.Debugln("rejecting handed over peer", addr.String()+":", reason)
+		t.recordRejectedConnection(addr, reason)
+		ih.Conn.Close()
+	}
+	if s := t.status(); s == Stopped || s == SeedingComplete || s == Stopping || s == Retrying {
+		reject("torrent is not running")
+		return
+	}
+	if t.info != nil && t.info.Private {
+		reject("torrent is private")
+		return
+	}
+	if len(t.incomingHandshakers)+len(t.incomingPeers) >= t.session.config.MaxPeerAccept {
+		reject("peer limit reached")
+		return
+	}
+	if t.connectionLimitReached() {
+		reject("connection limit reached")
+		return
+	}
+	if _, ok := t.connectedPeerIPs[ipstr]; ok {
+		reject("duplicate connection")
+		return
+	}
+	if _, ok := t.bannedPeerIPs[ipstr]; ok {
+		reject("banned IP")
 		return
 	}
+	t.connectedPeerIPs[ipstr] = struct{}{}
 	t.startPeer(ih.Conn, peersource.Incoming, t.incomingPeers, ih.PeerID, ih.Extensions, ih.Cipher)
 }
 
 func (t *torrent) handleOutgoingHandshakeDone(oh *outgoinghandshaker.OutgoingHandshaker) {
 	delete(t.outgoingHandshakers, oh)
+	t.updateNumHandshakers()
 	if oh.Error != nil {
 		delete(t.connectedPeerIPs, oh.Addr.IP.String())
+		t.recordConnection(oh.Addr, true, oh.Cipher, connectionStage(oh.Error), oh.Error)
+		if oh.Source == peersource.Resume {
+			t.resumePeerFailed(oh.Addr)
+		}
+		if oh.Source == peersource.PEX {
+			t.rendezvous(oh.Addr)
+		}
+		if next, ok := t.peerFallbackAddrs[oh.Addr.String()]; ok {
+			delete(t.peerFallbackAddrs, oh.Addr.String())
+			t.log.Debugf("cannot connect to %s, trying next address %s", oh.Addr, next[0])
+			t.handleHostPeer(next)
+		}
 		t.dialAddresses()
 		return
 	}
+	delete(t.peerFallbackAddrs, oh.Addr.String())
 	t.startPeer(oh.Conn, oh.Source, t.outgoingPeers, oh.PeerID, oh.Extensions, oh.Cipher)
 }
@@ -0,0 +1,131 @@
+package torrent
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/fortytw2/leaktest"
+)
+
+func TestMaxPendingIncomingHandshakes(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		// Connections are made from different addresses in 127.0.0.0/8 which are only routed to loopback interface on Linux.
+		t.Skip("needs multiple loopback addresses")
+	}
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	s.config.MaxPendingIncomingHandshakes = 2
+
+	tor := addTorrentFile(t, s, nil)
+	var port int
+	select {
+	case port = <-tor.torrent.NotifyListen():
+	case <-time.After(timeout):
+		t.Fatal("torrent is not listening")
+	}
+
+	// Connections stall in handshake because they never send any data.
+	// Each one comes from a different IP to avoid being rejected as duplicate.
+	var conns []net.Conn
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for i := 2; i < 8; i++ {
+		d := net.Dialer{LocalAddr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, byte(i))}}
+		conn, err := d.Dial("tcp4", "127.0.0.1:"+strconv.Itoa(port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+	}
+	var rejected, pending int
+	for _, conn := range conns {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err := conn.Read(make([]byte, 1))
+		if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+			pending++
+		} else {
+			rejected++
+		}
+	}
+	if pending != 2 || rejected != 4 {
+		t.Fatalf("unexpected number of connections: pending=%d rejected=%d", pending, rejected)
+	}
+	if n := tor.Stats().Handshakes.Incoming; n != 2 {
+		t.Fatalf("unexpected number of incoming handshakes: %d", n)
+	}
+}
+
+func TestIncomingPeerHandOver(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor1 := addTorrentFile(t, s, nil)
+
+	// Peers connect to the port of this torrent.
+	tmp, closeTmp := tempdir(t)
+	defer closeTmp()
+	path := filepath.Join(tmp, "other")
+	err := ioutil.WriteFile(path, []byte("other torrent"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := metainfo.NewInfoBytes("", []string{path}, false, 0, "", logger.New("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi, err := metainfo.NewBytes(info, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor2, err := s.AddTorrent(bytes.NewReader(mi), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var port int
+	select {
+	case port = <-tor2.torrent.NotifyListen():
+	case <-time.After(timeout):
+		t.Fatal("torrent is not listening")
+	}
+
+	dial := func() (net.Conn, error) {
+		addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+		var peerID [20]byte
+		copy(peerID[:], "-XX0000-000000000000")
+		conn, _, _, _, err := btconn.Dial(addr, nil, time.Second, time.Second, true, true, [8]byte{}, tor1.torrent.infoHash, peerID, make(chan struct{}))
+		return conn, err
+	}
+
+	conn, err := dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitFor(t, "peer is not handed over", func() bool { return tor1.Stats().Peers.Incoming == 1 })
+	if n := tor2.Stats().Peers.Total; n != 0 {
+		t.Fatalf("peer must not be added to the torrent owning the port, peers: %d", n)
+	}
+
+	err = s.RemoveTorrent(tor1.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dial()
+	if err == nil {
+		t.Fatal("connection for a removed torrent must be rejected")
+	}
+}
@@ -0,0 +1,154 @@
+package torrent
+
+import (
+	"net"
+
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/peersource"
+)
+
+// holepunchEnabled returns true if holepunch messages can be exchanged with peers of the torrent.
+// Holepunch is only used for addresses received with PEX messages so it is disabled when PEX is disabled.
+func (t *torrent) holepunchEnabled() bool {
+	return t.session.config.HolepunchEnabled && t.pexEnabled()
+}
+
+func holepunchSupported(pe *peer.Peer) bool {
+	if pe.ExtensionHandshake == nil {
+		return false
+	}
+	id, ok := pe.ExtensionHandshake.M[peerprotocol.ExtensionKeyHolepunch]
+	return ok && id != 0
+}
+
+func sendHolepunchMessage(pe *peer.Peer, msgType uint8, addr *net.TCPAddr, errCode uint32) {
+	pe.SendMessage(peerprotocol.ExtensionMessage{
+		ExtendedMessageID: pe.ExtensionHandshake.M[peerprotocol.ExtensionKeyHolepunch],
+		Payload: peerprotocol.ExtensionHolepunchMessage{
+			Type:  msgType,
+			Addr:  addr,
+			Error: errCode,
+		},
+	})
+}
+
+// addHolepunchRelays saves the peer that sent the addresses in a PEX message.
+// If we cannot connect to one of the addresses, the peer is asked to relay a rendezvous message.
+func (t *torrent) addHolepunchRelays(pe *peer.Peer, addrs []*net.TCPAddr) {
+	if !t.holepunchEnabled() || !holepunchSupported(pe) {
+		return
+	}
+	for _, addr := range addrs {
+		if len(t.holepunchRelays) >= t.session.config.MaxPeerAddresses {
+			return
+		}
+		t.holepunchRelays[addr.String()] = pe
+	}
+}
+
+func (t *torrent) removeHolepunchRelay(pe *peer.Peer) {
+	for addr, relay := range t.holepunchRelays {
+		if relay == pe {
+			delete(t.holepunchRelays, addr)
+		}
+	}
+	for addr, relay := range t.holepunchRendezvous {
+		if relay == pe {
+			delete(t.holepunchRendezvous, addr)
+		}
+	}
+}
+
+// rendezvous is called when we cannot connect to an address received from PEX.
+// The peer that sent the address is asked to make both sides connect to each other at the same time.
+func (t *torrent) rendezvous(addr *net.TCPAddr) {
+	key := addr.String()
+	relay, ok := t.holepunchRelays[key]
+	if !ok {
+		return
+	}
+	delete(t.holepunchRelays, key)
+	if !t.holepunchEnabled() {
+		return
+	}
+	if _, ok = t.peers[relay]; !ok {
+		return
+	}
+	relay.Logger().Debugln("sending holepunch rendezvous for", addr.String())
+	sendHolepunchMessage(relay, peerprotocol.ExtensionHolepunchMessageTypeRendezvous, addr, 0)
+	t.holepunchRendezvous[key] = relay
+}
+
+func (t *torrent) handleHolepunchMessage(pe *peer.Peer, msg peerprotocol.ExtensionHolepunchMessage) {
+	if !t.holepunchEnabled() || !holepunchSupported(pe) {
+		return
+	}
+	switch msg.Type {
+	case peerprotocol.ExtensionHolepunchMessageTypeRendezvous:
+		t.relayRendezvous(pe, msg.Addr)
+	case peerprotocol.ExtensionHolepunchMessageTypeConnect:
+		pe.Logger().Debugln("holepunch connect received for", msg.Addr.String())
+		t.holepunchConnect(pe, msg.Addr)
+	case peerprotocol.ExtensionHolepunchMessageTypeError:
+		pe.Logger().Debugln("holepunch rendezvous failed for", msg.Addr.String(), "error code:", msg.Error)
+	default:
+		pe.Logger().Debugln("unknown holepunch message type:", msg.Type)
+	}
+}
+
+// holepunchConnect dials the address in a connect message received from the relay.
+// Only the targets of our rendezvous messages and the addresses that the relay has sent with PEX are dialed,
+// so a peer cannot make us connect to arbitrary addresses.
+func (t *torrent) holepunchConnect(relay *peer.Peer, addr *net.TCPAddr) {
+	key := addr.String()
+	if t.holepunchRendezvous[key] != relay && t.holepunchRelays[key] != relay {
+		relay.Logger().Debugln("holepunch connect is not expected for", key)
+		return
+	}
+	delete(t.holepunchRendezvous, key)
+	delete(t.holepunchRelays, key)
+	if t.session.blocklist.Blocked(addr.IP) {
+		t.session.metrics.BlockListRejected.Inc(1)
+		t.log.Debugln("peer is blocked:", key, "total rejected:", t.session.metrics.BlockListRejected.Count())
+		return
+	}
+	if len(t.outgoingPeers)+len(t.outgoingHandshakers) >= t.dialLimit() || t.connectionLimitReached() {
+		return
+	}
+	t.dialAddress(addr, peersource.PEX, 0)
+}
+
+// relayRendezvous sends connect messages to both the peer and the target if we are connected to both of them.
+func (t *torrent) relayRendezvous(pe *peer.Peer, addr *net.TCPAddr) {
+	sendError := func(code uint32) {
+		pe.Logger().Debugln("cannot relay holepunch rendezvous to", addr.String(), "error code:", code)
+		sendHolepunchMessage(pe, peerprotocol.ExtensionHolepunchMessageTypeError, addr, code)
+	}
+	if addr.Port == 0 || addr.IP.IsUnspecified() {
+		sendError(peerprotocol.HolepunchErrorNoSuchPeer)
+		return
+	}
+	if addr.Port == t.port && addr.IP.Equal(t.externalIP) {
+		sendError(peerprotocol.HolepunchErrorNoSelf)
+		return
+	}
+	var target *peer.Peer
+	for p := range t.peers {
+		if p != pe && p.Addr().Port == addr.Port && p.Addr().IP.Equal(addr.IP) {
+			target = p
+			break
+		}
+	}
+	if target == nil {
+		sendError(peerprotocol.HolepunchErrorNotConnected)
+		return
+	}
+	if !holepunchSupported(target) {
+		sendError(peerprotocol.HolepunchErrorNoSupport)
+		return
+	}
+	pe.Logger().Debugln("relaying holepunch rendezvous to", addr.String())
+	sendHolepunchMessage(target, peerprotocol.ExtensionHolepunchMessageTypeConnect, pe.Addr(), 0)
+	sendHolepunchMessage(pe, peerprotocol.ExtensionHolepunchMessageTypeConnect, addr, 0)
+}
@@ -0,0 +1,133 @@
+package torrent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/fortytw2/leaktest"
+)
+
+func TestHolepunchRelay(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	s.config.PEXEnabled = true
+	s.config.HolepunchEnabled = true
+
+	tor := addTorrentFile(t, s, nil)
+	var port int
+	select {
+	case port = <-tor.torrent.NotifyListen():
+	case <-time.After(timeout):
+		t.Fatal("torrent is not listening")
+	}
+
+	// Connect two peers supporting holepunch extension from different IPs.
+	const holepunchID = 3
+	connect := func(ip net.IP, id string) net.Conn {
+		var peerID [20]byte
+		copy(peerID[:], id)
+		var ext [8]byte
+		ext[5] |= 0x10 // BEP 10
+		dialer := &net.Dialer{LocalAddr: &net.TCPAddr{IP: ip}}
+		addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+		conn, _, _, _, err := btconn.Dial(addr, dialer, time.Second, time.Second, false, false, ext, tor.torrent.infoHash, peerID, make(chan struct{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		_, err = peerprotocol.ExtensionMessage{
+			ExtendedMessageID: peerprotocol.ExtensionIDHandshake,
+			Payload:           peerprotocol.ExtensionHandshakeMessage{M: map[string]uint8{peerprotocol.ExtensionKeyHolepunch: holepunchID}},
+		}.WriteTo(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writePeerMessage(t, conn, peerprotocol.Extension, buf.Bytes())
+		return conn
+	}
+	readHolepunch := func(conn net.Conn) peerprotocol.ExtensionHolepunchMessage {
+		for {
+			var length uint32
+			if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+				t.Fatal(err)
+			}
+			msg := make([]byte, length)
+			if _, err := io.ReadFull(conn, msg); err != nil {
+				t.Fatal(err)
+			}
+			if length < 2 || peerprotocol.MessageID(msg[0]) != peerprotocol.Extension || msg[1] != holepunchID {
+				continue
+			}
+			var hm peerprotocol.ExtensionHolepunchMessage
+			if err := hm.UnmarshalBinary(msg[2:]); err != nil {
+				t.Fatal(err)
+			}
+			return hm
+		}
+	}
+	connA := connect(net.IPv4(127, 0, 0, 1), "-XX0000-00000000000A")
+	defer connA.Close()
+	connB := connect(net.IPv4(127, 0, 0, 2), "-XX0000-00000000000B")
+	defer connB.Close()
+	addrA := connA.LocalAddr().(*net.TCPAddr)
+	addrB := connB.LocalAddr().(*net.TCPAddr)
+
+	// Retry until the relay has received the extension handshakes of both peers.
+	for {
+		var buf bytes.Buffer
+		_, err := peerprotocol.ExtensionMessage{
+			ExtendedMessageID: peerprotocol.ExtensionIDHolepunch,
+			Payload:           peerprotocol.ExtensionHolepunchMessage{Type: peerprotocol.ExtensionHolepunchMessageTypeRendezvous, Addr: addrB},
+		}.WriteTo(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writePeerMessage(t, connA, peerprotocol.Extension, buf.Bytes())
+		hm := readHolepunch(connA)
+		if hm.Type == peerprotocol.ExtensionHolepunchMessageTypeError {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		if hm.Type != peerprotocol.ExtensionHolepunchMessageTypeConnect || hm.Addr.String() != addrB.String() {
+			t.Fatalf("unexpected message to initiating peer: %+v", hm)
+		}
+		break
+	}
+	hm := readHolepunch(connB)
+	if hm.Type != peerprotocol.ExtensionHolepunchMessageTypeConnect || hm.Addr.String() != addrA.String() {
+		t.Fatalf("unexpected message to target peer: %+v", hm)
+	}
+
+	// Connect messages for addresses that are not received from the relay must not be dialed.
+	l, err := net.Listen("tcp4", "127.0.0.3:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	var buf bytes.Buffer
+	_, err = peerprotocol.ExtensionMessage{
+		ExtendedMessageID: peerprotocol.ExtensionIDHolepunch,
+		Payload:           peerprotocol.ExtensionHolepunchMessage{Type: peerprotocol.ExtensionHolepunchMessageTypeConnect, Addr: l.Addr().(*net.TCPAddr)},
+	}.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePeerMessage(t, connA, peerprotocol.Extension, buf.Bytes())
+	if err = l.(*net.TCPListener).SetDeadline(time.Now().Add(500 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if conn, err := l.Accept(); err == nil {
+		conn.Close()
+		t.Fatal("unexpected connection to address in holepunch connect message")
+	}
+}
@@ -0,0 +1,101 @@
+package torrent
+
+import (
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/semaphore"
+)
+
+type lazyVerifyResult struct {
+	Index uint32
+	OK    bool
+	Error error
+}
+
+// lazyVerify queues the request message until the requested piece is verified.
+// The piece is read from the disk in a separate goroutine if it is not being verified already.
+// Lazy verifications share Session.semVerify with the verifiers of other torrents.
+func (t *torrent) lazyVerify(pm peer.Message) {
+	msg := pm.Message.(peerprotocol.RequestMessage)
+	reqs, verifying := t.lazyVerifyRequests[msg.Index]
+	t.lazyVerifyRequests[msg.Index] = append(reqs, pm)
+	if verifying {
+		return
+	}
+	go verifyPiece(&t.pieces[msg.Index], t.session.semVerify, t.lazyVerifyResultC, t.doneC)
+}
+
+func verifyPiece(pi *piece.Piece, sem *semaphore.Semaphore, resultC chan lazyVerifyResult, doneC chan struct{}) {
+	if !sem.WaitOrClose(doneC) {
+		return
+	}
+	defer sem.Signal()
+	res := lazyVerifyResult{Index: pi.Index}
+	buf := make([]byte, pi.Length)
+	_, res.Error = pi.Data.ReadAt(buf, 0)
+	if res.Error == nil {
+		res.OK = pi.VerifyHash(buf, pi.NewHash())
+	}
+	select {
+	case resultC <- res:
+	case <-doneC:
+	}
+}
+
+func (t *torrent) handleLazyVerifyDone(res lazyVerifyResult) {
+	reqs := t.lazyVerifyRequests[res.Index]
+	delete(t.lazyVerifyRequests, res.Index)
+	// Peers are disconnected and files are closed after stop. The piece is verified again when it is requested after start.
+	if s := t.status(); s == Stopped || s == SeedingComplete || s == Stopping || s == Retrying {
+		return
+	}
+	if t.unverifiedPieces == nil || !t.unverifiedPieces.Test(res.Index) {
+		return
+	}
+	t.unverifiedPieces.Clear(res.Index)
+	t.writeUnverifiedPieces()
+	if res.Error != nil {
+		t.log.Errorln("cannot read piece for verification:", res.Index, res.Error)
+	} else if !res.OK {
+		t.log.Warningln("piece failed hash check, will be downloaded again:", res.Index)
+	}
+	if res.Error != nil || !res.OK {
+		t.markPieceMissing(res.Index)
+	}
+	// Missing pieces are rejected. Verified pieces are served as usual.
+	for _, pm := range reqs {
+		if _, ok := t.peers[pm.Peer]; ok {
+			t.handlePeerMessage(pm)
+		}
+	}
+}
+
+func (t *torrent) markPieceMissing(index uint32) {
+	t.pieces[index].Done = false
+	t.mBitfield.Lock()
+	t.bitfield.Clear(index)
+	t.bitfieldDirty = true
+	t.mBitfield.Unlock()
+	if t.completed {
+		t.resumeDownload()
+	}
+	for pe := range t.peers {
+		t.updateInterestedState(pe)
+	}
+	t.startPieceDownloaders()
+}
+
+// writeUnverifiedPieces saves the pieces that are not verified yet, so they are not served without verification after restart.
+func (t *torrent) writeUnverifiedPieces() {
+	var value []byte
+	if t.unverifiedPieces != nil && t.unverifiedPieces.Count() > 0 {
+		value = t.unverifiedPieces.Bytes()
+	} else {
+		t.unverifiedPieces = nil
+	}
+	err := t.session.resumer.WriteUnverifiedPieces(t.id, value)
+	if err != nil {
+		t.log.Errorln("cannot write unverified pieces:", err)
+	}
+}
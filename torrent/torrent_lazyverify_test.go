@@ -0,0 +1,75 @@
+package torrent
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+)
+
+func TestSkipHashCheck(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true, SkipHashCheck: true})
+	spec, err := s.resumer.Read(tor.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.UnverifiedPieces) == 0 {
+		t.Fatal("unverified pieces are not saved")
+	}
+	src := filepath.Join(torrentDataDir, torrentName)
+	dst := filepath.Join(s.config.DataDir, tor.ID(), torrentName)
+	err = os.Mkdir(filepath.Join(s.config.DataDir, tor.ID()), os.ModeDir|0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = CopyDir(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Corrupt the first byte of every file so the first piece fails hash check.
+	err = filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || info.Size() == 0 {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = f.WriteAt([]byte{0xff}, 0)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor.torrent.trackers = nil
+	tor.Start()
+	var port int
+	select {
+	case port = <-tor.torrent.NotifyListen():
+	case err := <-tor.torrent.NotifyError():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		t.Fatal("seeder is not ready")
+	}
+	waitStatus(t, tor, Seeding)
+
+	s2, closeSession2 := newTestSession(t)
+	defer closeSession2()
+	tor2 := addTorrentFile(t, s2, nil)
+	tor2.AddPeer("127.0.0.1:" + strconv.Itoa(port))
+
+	// Corrupt pieces are detected when they are requested and the seeder starts downloading them again.
+	waitStatus(t, tor, Downloading)
+	stats := tor.Stats()
+	if stats.Pieces.Have == 0 || stats.Pieces.Have == stats.Pieces.Total {
+		t.Fatalf("unexpected number of pieces: %d/%d", stats.Pieces.Have, stats.Pieces.Total)
+	}
+}
@@ -9,10 +9,7 @@ import (
 	"github.com/cenkalti/rain/internal/peer"
 	"github.com/cenkalti/rain/internal/peerconn/peerwriter"
 	"github.com/cenkalti/rain/internal/peerprotocol"
-	"github.com/cenkalti/rain/internal/peersource"
 	"github.com/cenkalti/rain/internal/piecedownloader"
-	"github.com/cenkalti/rain/internal/piecewriter"
-	"github.com/cenkalti/rain/internal/tracker"
 )
 
 func (t *torrent) handlePieceMessage(pm peer.PieceMessage) {
@@ -33,9 +30,9 @@ func (t *torrent) handlePieceMessage(pm peer.PieceMessage) {
 		msg.Buffer.Release()
 		return
 	}
-	t.downloadSpeed.Mark(l)
 	t.bytesDownloaded.Inc(l)
 	t.session.metrics.SpeedDownload.Mark(l)
+	t.markResumePeer(pe)
 	pd, ok := t.pieceDownloaders[pe]
 	if !ok {
 		t.bytesWasted.Inc(l)
@@ -58,6 +55,9 @@ func (t *torrent) handlePieceMessage(pm peer.PieceMessage) {
 	}
 	err := pd.GotBlock(block, msg.Buffer.Data)
 	switch err {
+	case piecedownloader.ErrBlockCancelled:
+		// Block is received from another peer before.
+		t.bytesWasted.Inc(l)
 	case piecedownloader.ErrBlockDuplicate:
 		if pe.FastEnabled {
 			pe.Logger().Warningln("received duplicate block:", block.Index)
@@ -76,12 +76,20 @@ func (t *torrent) handlePieceMessage(pm peer.PieceMessage) {
 			pe.Logger().Debugln("received not requested block:", block.Index)
 		}
 	case nil:
+		pe.UpdateRequestLatency(pd.LastLatency())
 	default:
 		pe.Logger().Error(err)
 		t.closePeer(pe)
 		msg.Buffer.Release()
 		return
 	}
+	if err == nil || err == piecedownloader.ErrBlockNotRequested {
+		// Piece may be completed by another downloader with this block.
+		if pd2 := t.cancelDuplicateRequests(pd, block, msg.Buffer.Data); pd2 != nil && !pd.Done() {
+			pd = pd2
+			pe = pd2.Peer.(*peer.Peer)
+		}
+	}
 	msg.Buffer.Release()
 	if !pd.Done() {
 		pe := pd.Peer.(*peer.Peer)
@@ -99,6 +107,9 @@ func (t *torrent) handlePieceMessage(pm peer.PieceMessage) {
 		panic("piece is already writing")
 	}
 	piece.Writing = true
+	if pd.Mixed() {
+		t.mixedPieces[piece.Index] = struct{}{}
+	}
 
 	// Request next piece while writing the completed piece, being optimistic about hash check.
 	t.startPieceDownloaderFor(pe)
@@ -107,12 +118,20 @@ func (t *torrent) handlePieceMessage(pm peer.PieceMessage) {
 	t.pieceMessagesC.Suspend()
 	t.webseedPieceResultC.Suspend()
 
-	pw := piecewriter.New(piece, pe, pd.Buffer)
-	go pw.Run(t.pieceWriterResultC, t.doneC, t.session.metrics.WritesPerSecond, t.session.metrics.SpeedWrite, t.session.semWrite)
+	t.startPieceWriter(piece, pe, pd.Buffer)
 }
 
 func (t *torrent) handlePeerMessage(pm peer.Message) {
 	pe := pm.Peer
+	switch pm.Message.(type) {
+	case peerprotocol.HaveAllMessage, peerprotocol.HaveNoneMessage, peerprotocol.AllowedFastMessage, peerprotocol.RejectMessage:
+		// These messages are defined in Fast Extension (BEP 6) and must not be sent if it is not enabled.
+		if !pe.FastEnabled {
+			pe.Logger().Errorf("received %T but fast extension is not enabled", pm.Message)
+			t.closePeer(pe)
+			return
+		}
+	}
 	switch msg := pm.Message.(type) {
 	case peerprotocol.HaveMessage:
 		// Save have messages for processesing later received while we don't have info yet.
@@ -128,7 +147,10 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 		// pe.Logger().Debug("Peer ", pe.String(), " has piece #", pi.Index)
 		if t.piecePicker != nil {
 			t.piecePicker.HandleHave(pe, msg.Index)
+		} else {
+			pe.Bitfield.Set(msg.Index)
 		}
+		t.handleSuperSeedHave(pe, msg.Index)
 		t.updateInterestedState(pe)
 		t.startPieceDownloaderFor(pe)
 	case peerprotocol.BitfieldMessage:
@@ -148,13 +170,21 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 			break
 		}
 		pe.Logger().Debugln("Received bitfield:", bf.Hex())
-		if t.piecePicker != nil {
-			for i := uint32(0); i < bf.Len(); i++ {
-				if bf.Test(i) {
-					t.piecePicker.HandleHave(pe, i)
-				}
+		for i := uint32(0); i < bf.Len(); i++ {
+			if !bf.Test(i) {
+				continue
+			}
+			if t.piecePicker != nil {
+				t.piecePicker.HandleHave(pe, i)
+			} else {
+				pe.Bitfield.Set(i)
 			}
 		}
+		t.handleSuperSeedBitfield(pe)
+		if bf.All() {
+			// Update the seed flag of the peer in PEX messages.
+			t.pexAddPeer(pe)
+		}
 		t.updateInterestedState(pe)
 		t.startPieceDownloaderFor(pe)
 	case peerprotocol.HaveAllMessage:
@@ -162,11 +192,15 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 			pe.Messages = append(pe.Messages, msg)
 			break
 		}
-		if t.piecePicker != nil {
-			for _, pi := range t.pieces {
+		for _, pi := range t.pieces {
+			if t.piecePicker != nil {
 				t.piecePicker.HandleHave(pe, pi.Index)
+			} else {
+				pe.Bitfield.Set(pi.Index)
 			}
 		}
+		t.handleSuperSeedBitfield(pe)
+		t.pexAddPeer(pe)
 		t.updateInterestedState(pe)
 		t.startPieceDownloaderFor(pe)
 	case peerprotocol.HaveNoneMessage:
@@ -219,7 +253,9 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 		t.startPieceDownloaders()
 	case peerprotocol.InterestedMessage:
 		pe.PeerInterested = true
-		t.unchoker.FastUnchoke(pe)
+		if !t.paused {
+			t.unchoker.FastUnchoke(pe, t.completed)
+		}
 	case peerprotocol.NotInterestedMessage:
 		pe.PeerInterested = false
 	case peerprotocol.RequestMessage:
@@ -239,14 +275,22 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 			break
 		}
 		pi := &t.pieces[msg.Index]
-		if !pi.Done {
-			m := peerprotocol.RejectMessage{RequestMessage: msg}
-			pe.SendMessage(m)
+		if !pi.Done || t.superSeedHidden(pe, msg.Index) {
+			// Reject message is part of the fast extension. Other peers cannot be told so the request is dropped.
+			if pe.FastEnabled {
+				m := peerprotocol.RejectMessage{RequestMessage: msg}
+				pe.SendMessage(m)
+			}
+			break
+		}
+		if t.unverifiedPieces != nil && t.unverifiedPieces.Test(msg.Index) {
+			// Request is handled again after the piece is verified.
+			t.lazyVerify(pm)
 			break
 		}
 		if pe.ClientChoking {
 			if pe.FastEnabled {
-				if pe.SentAllowedFast.Has(pi) {
+				if pe.SentAllowedFast.Has(pi) && !t.paused {
 					pe.SendPiece(msg, cachedpiece.New(pi, t.session.pieceCache, t.session.config.ReadCacheBlockSize, t.peerID))
 				} else {
 					m := peerprotocol.RejectMessage{RequestMessage: msg}
@@ -282,6 +326,19 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 			break
 		}
 		pd.Rejected(block)
+		if pd.Pending() > 0 || (pe.PeerChoking && !pd.AllowedFast) {
+			// Rejected blocks are requested again with the remaining ones or after the peer unchokes us.
+			break
+		}
+		// Peer has rejected all of our requests while unchoking us, it cannot serve this piece now.
+		// Give the piece to another peer and pick a different piece for this one.
+		pe.Logger().Debugln("all requests are rejected for piece:", msg.Index)
+		t.closePieceDownloader(pd)
+		pe.StopSnubTimer()
+		if t.piecePicker != nil {
+			t.piecePicker.HandleReject(pe, msg.Index)
+		}
+		t.startPieceDownloaders()
 	case peerprotocol.CancelMessage:
 		if t.pieces == nil || t.bitfield == nil {
 			pe.Logger().Error("cancel received but we don't have info")
@@ -302,12 +359,11 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 			}})
 		}
 	case peerprotocol.PortMessage:
-		if t.session.dht != nil {
+		if t.session.dht != nil && t.dhtEnabled() {
 			t.session.dht.AddNode(fmt.Sprintf("%s:%d", pe.IP(), msg.Port))
 		}
 	case peerwriter.BlockUploaded:
 		l := int64(msg.Length)
-		t.uploadSpeed.Mark(l)
 		t.bytesUploaded.Inc(l)
 		t.session.metrics.SpeedUpload.Mark(l)
 	case peerprotocol.ExtensionHandshakeMessage:
@@ -320,35 +376,18 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 
 		if len(msg.YourIP) == 4 {
 			t.externalIP = net.IP(msg.YourIP)
+			t.session.voteExternalIP(pe.IP(), t.externalIP)
 		}
 		if _, ok := msg.M[peerprotocol.ExtensionKeyMetadata]; ok {
 			t.startInfoDownloaders()
 		}
-		if t.session.config.PEXEnabled {
-			if _, ok := msg.M[peerprotocol.ExtensionKeyPEX]; ok {
-				if t.info != nil && !t.info.Private {
-					pe.StartPEX(t.peers, &t.recentlySeen)
-				}
-			}
-		}
+		t.startPEX(pe)
 	case peerprotocol.ExtensionMetadataMessage:
 		t.handleMetadataMessage(pe, msg)
 	case peerprotocol.ExtensionPEXMessage:
-		if !t.session.config.PEXEnabled {
-			break
-		}
-		addrs, err := tracker.DecodePeersCompact([]byte(msg.Added))
-		if err != nil {
-			t.log.Error(err)
-			break
-		}
-		t.handleNewPeers(addrs, peersource.PEX)
-		addrs, err = tracker.DecodePeersCompact([]byte(msg.Dropped))
-		if err != nil {
-			t.log.Error(err)
-			break
-		}
-		t.handleNewPeers(addrs, peersource.PEX)
+		t.handlePEXMessage(pe, msg)
+	case peerprotocol.ExtensionHolepunchMessage:
+		t.handleHolepunchMessage(pe, msg)
 	default:
 		panic(fmt.Sprintf("unhandled peer message type: %T", msg))
 	}
@@ -363,7 +402,7 @@ func (t *torrent) updateInterestedState(pe *peer.Peer) {
 		for i := uint32(0); i < t.bitfield.Len(); i++ {
 			weHave := t.bitfield.Test(i)
 			peerHave := pe.Bitfield.Test(i)
-			if !weHave && peerHave {
+			if !weHave && peerHave && !t.pieceSkipped(i) {
 				interested = true
 				break
 			}
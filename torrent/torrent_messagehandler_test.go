@@ -0,0 +1,79 @@
+package torrent
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/fortytw2/leaktest"
+)
+
+func TestFastExtensionReject(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor := addTorrentFile(t, s, nil)
+	var port int
+	select {
+	case port = <-tor.torrent.NotifyListen():
+	case <-time.After(timeout):
+		t.Fatal("torrent is not listening")
+	}
+
+	// Connect as a seeder that supports fast extension and rejects all requests for the first piece.
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	var peerID [20]byte
+	copy(peerID[:], "-XX0000-000000000000")
+	var ext [8]byte
+	ext[7] |= 0x04 // BEP 6
+	conn, _, _, _, err := btconn.Dial(addr, nil, time.Second, time.Second, false, false, ext, tor.torrent.infoHash, peerID, make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err = conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		t.Fatal(err)
+	}
+	readMessage := func() []byte {
+		for {
+			var length uint32
+			if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+				t.Fatal(err)
+			}
+			msg := make([]byte, length)
+			if _, err := io.ReadFull(conn, msg); err != nil {
+				t.Fatal(err)
+			}
+			if length > 0 {
+				return msg
+			}
+		}
+	}
+	if msg := readMessage(); peerprotocol.MessageID(msg[0]) != peerprotocol.HaveNone {
+		t.Fatalf("first message must be have-none, got %d", msg[0])
+	}
+	writePeerMessage(t, conn, peerprotocol.HaveAll, nil)
+	writePeerMessage(t, conn, peerprotocol.Unchoke, nil)
+
+	rejected := int64(-1)
+	for {
+		msg := readMessage()
+		if peerprotocol.MessageID(msg[0]) != peerprotocol.Request {
+			continue
+		}
+		index := int64(binary.BigEndian.Uint32(msg[1:5]))
+		if rejected == -1 {
+			rejected = index
+		}
+		if index != rejected {
+			// Another piece is requested after all requests for the first one are rejected.
+			break
+		}
+		writePeerMessage(t, conn, peerprotocol.Reject, msg[1:13])
+	}
+}
@@ -11,6 +11,9 @@ import (
 	"github.com/cenkalti/rain/internal/peerprotocol"
 )
 
+// Metadata is sent in pieces of 16KiB except the last one. See BEP 9.
+const metadataPieceSize = 16 * 1024
+
 func (t *torrent) handleMetadataMessage(pe *peer.Peer, msg peerprotocol.ExtensionMetadataMessage) {
 	switch msg.Type {
 	case peerprotocol.ExtensionMetadataMessageTypeRequest:
@@ -26,19 +29,16 @@ func (t *torrent) handleMetadataMessage(pe *peer.Peer, msg peerprotocol.Extensio
 			t.sendMetadataReject(pe, msg.Piece, extMsgID)
 			break
 		}
-		start := 16 * 1024 * msg.Piece
-		end := start + 16*1024
 		totalSize := uint32(len(t.info.Bytes))
-		if end > totalSize {
-			end = totalSize
-		}
-		if start >= totalSize {
+		// Compare the index before multiplying to prevent overflow with large indexes.
+		if msg.Piece >= (totalSize+metadataPieceSize-1)/metadataPieceSize {
 			t.sendMetadataReject(pe, msg.Piece, extMsgID)
 			break
 		}
+		start := metadataPieceSize * msg.Piece
+		end := start + metadataPieceSize
 		if end > totalSize {
-			t.sendMetadataReject(pe, msg.Piece, extMsgID)
-			break
+			end = totalSize
 		}
 		data := t.info.Bytes[start:end]
 		dataMsg := peerprotocol.ExtensionMetadataMessage{
@@ -80,8 +80,9 @@ func (t *torrent) handleMetadataMessage(pe *peer.Peer, msg peerprotocol.Extensio
 			break
 		}
 		t.stopInfoDownloaders()
+		t.stopMetadataTimer()
 
-		info, err := t.session.parseInfo(id.Bytes)
+		info, err := t.session.parseInfo(id.Bytes, nil)
 		if err != nil {
 			t.stop(fmt.Errorf("cannot parse info bytes: %s", err))
 			break
@@ -91,12 +92,25 @@ func (t *torrent) handleMetadataMessage(pe *peer.Peer, msg peerprotocol.Extensio
 			break
 		}
 		t.info = info
+		if t.Name() != info.Name {
+			t.log.Infof("torrent name changed from %q to %q", t.Name(), info.Name)
+			t.setName(info.Name)
+		}
 		t.piecePool = bufferpool.New(int(info.PieceLength))
 		err = t.session.resumer.WriteInfo(t.id, t.info.Bytes)
 		if err != nil {
 			t.stop(fmt.Errorf("cannot write resume info: %s", err))
 			break
 		}
+		err = t.setDefaultFilePriorities()
+		if err != nil {
+			t.stop(fmt.Errorf("cannot write file priorities: %s", err))
+			break
+		}
+		for pe := range t.peers {
+			t.startPEX(pe)
+		}
+		t.sendEvent(Event{Type: EventMetadataReceived})
 		t.startAllocator()
 	case peerprotocol.ExtensionMetadataMessageTypeReject:
 		id, ok := t.infoDownloaders[pe]
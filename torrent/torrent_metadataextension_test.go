@@ -0,0 +1,35 @@
+package torrent
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+)
+
+func TestMetadataFromPeerWithoutData(t *testing.T) {
+	defer leaktest.Check(t)()
+	s1, closeSession1 := newTestSession(t)
+	defer closeSession1()
+	src := addTorrentFile(t, s1, &AddTorrentOptions{Stopped: true})
+	src.torrent.trackers = nil
+	src.Start()
+	var port int
+	select {
+	case port = <-src.torrent.NotifyListen():
+	case <-time.After(timeout):
+		t.Fatal("torrent is not listening")
+	}
+
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	tor, err := s.AddURI(torrentMagnetLink+"&x.pe=127.0.0.1:"+strconv.Itoa(port), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "metadata is not downloaded", func() bool { return tor.Name() == torrentName })
+	if have := src.Stats().Pieces.Have; have != 0 {
+		t.Fatalf("source must not have any pieces, has %d", have)
+	}
+}
@@ -0,0 +1,35 @@
+package torrent
+
+import (
+	"errors"
+	"time"
+)
+
+var errMetadataTimeout = errors.New("metadata not found")
+
+// startMetadataTimer starts a timer that stops the torrent if the info dictionary of a magnet link
+// cannot be downloaded in Config.MetadataTimeout.
+func (t *torrent) startMetadataTimer() {
+	if t.info != nil || t.metadataTimer != nil || t.session.config.MetadataTimeout <= 0 {
+		return
+	}
+	t.metadataTimer = time.NewTimer(t.session.config.MetadataTimeout)
+	t.metadataTimeoutC = t.metadataTimer.C
+}
+
+func (t *torrent) handleMetadataTimeout() {
+	t.metadataTimer = nil
+	t.metadataTimeoutC = nil
+	if t.info != nil {
+		return
+	}
+	t.stop(errMetadataTimeout)
+}
+
+func (t *torrent) stopMetadataTimer() {
+	if t.metadataTimer != nil {
+		t.metadataTimer.Stop()
+		t.metadataTimer = nil
+		t.metadataTimeoutC = nil
+	}
+}
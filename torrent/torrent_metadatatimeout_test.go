@@ -0,0 +1,24 @@
+package torrent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+)
+
+func TestMagnetMetadataTimeout(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	s.config.MetadataTimeout = 100 * time.Millisecond
+
+	tor, err := s.AddURI(torrentMagnetLink, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, tor, Stopped)
+	if err := tor.Stats().Error; err != errMetadataTimeout {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
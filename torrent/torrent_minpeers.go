@@ -0,0 +1,38 @@
+package torrent
+
+// checkMinConnectedPeers updates the peer seeking state of the torrent by comparing the number of connected peers
+// with Config.MinConnectedPeers. While seeking, known addresses are dialed and trackers and DHT are asked for more peers
+// until the minimum is met or there are no addresses left to dial.
+func (t *torrent) checkMinConnectedPeers() {
+	min := t.session.config.MinConnectedPeers
+	seeking := min > 0 && !t.completed && len(t.peers) < min
+	if seeking {
+		s := t.status()
+		seeking = s == Downloading || s == DownloadingMetadata
+	}
+	if seeking == t.seekingPeers {
+		return
+	}
+	t.seekingPeers = seeking
+	if seeking {
+		t.log.Debugf("connected peers (%d) is below the minimum (%d), seeking more peers", len(t.peers), min)
+		t.setNeedMorePeers(true)
+		t.dialAddresses()
+	} else {
+		t.setNeedMorePeers(false)
+	}
+}
+
+// dialLimit returns the max number of outgoing connections.
+// While seeking peers, a dial limit lowered by Config.AdaptivePeerLimit is raised up to Config.MinConnectedPeers,
+// without exceeding Config.MaxPeerDial.
+func (t *torrent) dialLimit() int {
+	cfg := t.session.config
+	if !t.seekingPeers || t.peerDialLimit >= cfg.MinConnectedPeers {
+		return t.peerDialLimit
+	}
+	if cfg.MinConnectedPeers > cfg.MaxPeerDial {
+		return cfg.MaxPeerDial
+	}
+	return cfg.MinConnectedPeers
+}
@@ -0,0 +1,69 @@
+package torrent
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/tracker"
+	"github.com/fortytw2/leaktest"
+)
+
+// peersTracker returns the same peers on each announce.
+type peersTracker struct {
+	addrs []*net.TCPAddr
+}
+
+func (t *peersTracker) Announce(ctx context.Context, req tracker.AnnounceRequest) (*tracker.AnnounceResponse, error) {
+	return &tracker.AnnounceResponse{Interval: time.Hour, Peers: t.addrs}, nil
+}
+
+func (t *peersTracker) URL() string {
+	return "http://tracker.example.com/announce"
+}
+
+func (t *peersTracker) Scrape(ctx context.Context, infoHashes [][20]byte) ([]tracker.ScrapeResult, error) {
+	return nil, tracker.ErrScrapeNotSupported
+}
+
+// leecher starts a torrent that has no data and waits for incoming connections.
+func leecher(t *testing.T) (addr *net.TCPAddr, c func()) {
+	s, closeSession := newTestSession(t)
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	tor.torrent.trackers = nil
+	tor.Start()
+	select {
+	case port := <-tor.torrent.NotifyListen():
+		return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}, closeSession
+	case <-time.After(timeout):
+		t.Fatal("leecher is not ready")
+	}
+	return nil, nil
+}
+
+func TestMinConnectedPeers(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr1, close1 := leecher(t)
+	defer close1()
+	addr2, close2 := leecher(t)
+	defer close2()
+	// Peers with the same IP are not connected more than once.
+	addr2.IP = net.IPv4(127, 0, 0, 2)
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	s.config.MinConnectedPeers = 2
+	// Adaptive peer limit keeps the dial target below the minimum.
+	s.config.AdaptivePeerLimit = true
+	s.config.AdaptivePeerLimitMin = 1
+	s.config.AdaptivePeerLimitMax = 1
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	tor.torrent.trackers = []tracker.Tracker{&peersTracker{addrs: []*net.TCPAddr{addr1, addr2}}}
+	tor.Start()
+
+	waitFor(t, "minimum number of peers is not connected", func() bool { return tor.Stats().Peers.Total >= 2 })
+	if tor.Stats().Peers.Seeking {
+		t.Fatal("torrent is still seeking peers after the minimum is met")
+	}
+}
@@ -0,0 +1,93 @@
+package torrent
+
+import (
+	"sort"
+
+	"github.com/cenkalti/rain/internal/piecedownloader"
+)
+
+// savePartialPieces writes the downloaded blocks of incomplete pieces to their places in files
+// and saves their indexes in resume data, so they are not downloaded again when the torrent is started.
+// Must be called before closing piece downloaders.
+func (t *torrent) savePartialPieces() {
+	partial := make(map[uint32][]int)
+	for i, blocks := range t.partialPieces {
+		if t.bitfield == nil || !t.bitfield.Test(i) {
+			partial[i] = blocks
+		}
+	}
+	for _, pd := range t.pieceDownloaders {
+		if pd.Done() || t.bitfield.Test(pd.Piece.Index) {
+			continue
+		}
+		blocks := pd.DoneBlocks()
+		if len(blocks) == 0 {
+			continue
+		}
+		if err := t.writePartialPiece(pd, blocks); err != nil {
+			t.log.Errorf("cannot write partial piece #%d: %s", pd.Piece.Index, err)
+			continue
+		}
+		partial[pd.Piece.Index] = mergeBlocks(partial[pd.Piece.Index], blocks)
+	}
+	t.partialPieces = partial
+	err := t.session.resumer.WritePartialPieces(t.id, t.partialPieces)
+	if err != nil {
+		t.log.Errorf("cannot write partial pieces to resume db: %s", err)
+	}
+}
+
+func (t *torrent) writePartialPiece(pd *piecedownloader.PieceDownloader, blocks []int) error {
+	for _, i := range blocks {
+		b, ok := pd.Piece.GetBlock(i)
+		if !ok {
+			panic("cannot get block")
+		}
+		_, err := pd.Piece.Data.WriteAt(pd.Buffer.Data[b.Begin:b.Begin+b.Length], int64(b.Begin))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resumePartialPiece reads the blocks saved in previous run into the buffer of piece downloader.
+// Piece downloader requests only the missing blocks from the peer.
+// Hash of the piece is checked as usual after all blocks are present.
+func (t *torrent) resumePartialPiece(pd *piecedownloader.PieceDownloader) {
+	blocks, ok := t.partialPieces[pd.Piece.Index]
+	if !ok || len(blocks) >= pd.Piece.NumBlocks() {
+		return
+	}
+	for _, i := range blocks {
+		b, ok := pd.Piece.GetBlock(i)
+		if !ok {
+			delete(t.partialPieces, pd.Piece.Index)
+			return
+		}
+		_, err := pd.Piece.Data.ReadAt(pd.Buffer.Data[b.Begin:b.Begin+b.Length], int64(b.Begin))
+		if err != nil {
+			t.log.Errorf("cannot read partial piece #%d: %s", pd.Piece.Index, err)
+			delete(t.partialPieces, pd.Piece.Index)
+			return
+		}
+	}
+	t.log.Debugf("resuming piece #%d with %d blocks from previous run", pd.Piece.Index, len(blocks))
+	pd.Resume(blocks)
+}
+
+// mergeBlocks returns sorted union of block indexes.
+func mergeBlocks(a, b []int) []int {
+	m := make(map[int]struct{}, len(a)+len(b))
+	ret := make([]int, 0, len(a)+len(b))
+	for _, l := range [][]int{a, b} {
+		for _, i := range l {
+			if _, ok := m[i]; !ok {
+				m[i] = struct{}{}
+				ret = append(ret, i)
+			}
+		}
+	}
+	sort.Ints(ret)
+	return ret
+}
@@ -0,0 +1,91 @@
+package torrent
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/verifier"
+	"github.com/fortytw2/leaktest"
+)
+
+func TestResumePartialPiece(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	tor.torrent.trackers = nil
+	// First block of the first piece has been downloaded in previous run.
+	tor.torrent.partialPieces = map[uint32][]int{0: {0}}
+	tor.Start()
+	waitStatus(t, tor, Downloading)
+	tor.Stop()
+	waitStatus(t, tor, Stopped)
+
+	spec, err := s.resumer.Read(tor.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.PartialPieces) != 1 || len(spec.PartialPieces[0]) != 1 {
+		t.Fatalf("partial pieces are not saved: %v", spec.PartialPieces)
+	}
+
+	// Put the data of the saved block in files.
+	src := filepath.Join(torrentDataDir, torrentName)
+	dst := filepath.Join(s.config.DataDir, tor.ID(), torrentName)
+	err = copyFiles(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Files are changed after the bitfield is saved. Update the stats so the saved bitfield is used instead of verifying the files.
+	tor.torrent.fileStats, err = verifier.FileStats(tor.torrent.storage, tor.torrent.info.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tor.Start()
+	tor.AddPeer(addr)
+	assertCompleted(t, tor)
+
+	stats := tor.Stats()
+	if stats.Bytes.Downloaded != stats.Bytes.Total-piece.BlockSize {
+		t.Fatalf("saved block is downloaded again, downloaded: %d, total: %d", stats.Bytes.Downloaded, stats.Bytes.Total)
+	}
+}
+
+// copyFiles copies the files in src directory over the files in dst directory.
+func copyFiles(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0750)
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE, 0640)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, in)
+		if err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
@@ -0,0 +1,59 @@
+package torrent
+
+import "github.com/cenkalti/rain/internal/peer"
+
+func (t *torrent) handlePause() {
+	if s := t.status(); s != Downloading && s != Seeding {
+		t.log.Debugf("cannot pause torrent in %s status", s)
+		return
+	}
+	t.log.Info("pausing torrent")
+	t.pause()
+}
+
+func (t *torrent) pause() {
+	t.paused = true
+	// Keep downloaded blocks of unfinished pieces so they are not requested again after resume.
+	t.savePartialPieces()
+	for _, pd := range t.pieceDownloaders {
+		t.closePieceDownloader(pd)
+		pd.CancelPending()
+		pd.Peer.(*peer.Peer).StopSnubTimer()
+	}
+	t.stopWebseedDownloads()
+	t.unchoker.ChokeAll()
+}
+
+func (t *torrent) handleResume() {
+	if !t.paused {
+		return
+	}
+	if t.diskError != nil {
+		t.log.Info("retrying after disk error")
+		if t.lastError == t.diskError {
+			t.lastError = nil
+		}
+		t.diskError = nil
+	}
+	t.log.Info("resuming torrent")
+	t.paused = false
+	for pe := range t.peers {
+		t.unchoker.FastUnchoke(pe, t.completed)
+	}
+	t.startPieceDownloaders()
+}
+
+// handleDiskError pauses downloading when a piece cannot be written because the disk is full or read-only.
+// Pieces that are being written at the same time fail with the same error, only the first one pauses the torrent.
+func (t *torrent) handleDiskError(err error) {
+	if t.diskError != nil {
+		return
+	}
+	t.log.Error(err)
+	if !t.paused {
+		t.pause()
+	}
+	t.diskError = err
+	t.lastError = err
+	t.sendEvent(Event{Type: EventDiskError, Error: err})
+}
@@ -0,0 +1,35 @@
+package torrent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+)
+
+func TestPauseResume(t *testing.T) {
+	defer leaktest.Check(t)()
+	s1, closeSession1 := newTestSession(t)
+	addr, cl := startSeeder(t, s1, closeSession1)
+	defer cl()
+	seed := s1.ListTorrents()[0]
+	seed.Pause()
+	waitStatus(t, seed, Paused)
+
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	tor := addTorrentFile(t, s, nil)
+	tor.AddPeer(addr)
+
+	waitFor(t, "peer is not connected", func() bool { return seed.Stats().Peers.Total == 1 })
+	time.Sleep(time.Second)
+	if have := tor.Stats().Pieces.Have; have != 0 {
+		t.Fatalf("%d pieces are downloaded from paused torrent", have)
+	}
+
+	seed.Resume()
+	assertCompleted(t, tor)
+	if st := seed.Stats().Status; st != Seeding {
+		t.Fatalf("torrent must be seeding after resume, status: %s", st)
+	}
+}
@@ -1,17 +1,22 @@
 package torrent
 
 import (
+	"bytes"
 	"context"
 	"net"
 	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/externalip"
 	"github.com/cenkalti/rain/internal/handshaker/outgoinghandshaker"
 	"github.com/cenkalti/rain/internal/mse"
+	"github.com/cenkalti/rain/internal/onion"
 	"github.com/cenkalti/rain/internal/peer"
 	"github.com/cenkalti/rain/internal/peerprotocol"
 	"github.com/cenkalti/rain/internal/peersource"
-	"github.com/cenkalti/rain/internal/resolver"
+	"github.com/cenkalti/rain/internal/speedlimit"
 )
 
 func (t *torrent) setNeedMorePeers(val bool) {
@@ -21,6 +26,43 @@ func (t *torrent) setNeedMorePeers(val bool) {
 	if t.dhtAnnouncer != nil {
 		t.dhtAnnouncer.NeedMorePeers(val)
 	}
+	if t.lsdAnnouncer != nil {
+		t.lsdAnnouncer.NeedMorePeers(val)
+	}
+}
+
+// checkNoPeers starts a timer for announcing to trackers when the last peer is disconnected.
+func (t *torrent) checkNoPeers() {
+	if !t.session.config.TrackerAnnounceOnNoPeers || len(t.peers) > 0 || t.noPeersTimer != nil {
+		return
+	}
+	if s := t.status(); s != Downloading && s != DownloadingMetadata {
+		return
+	}
+	t.noPeersTimer = time.NewTimer(t.session.config.TrackerAnnounceOnNoPeersDelay)
+	t.noPeersC = t.noPeersTimer.C
+}
+
+func (t *torrent) handleNoPeers() {
+	t.noPeersTimer = nil
+	t.noPeersC = nil
+	if len(t.peers) > 0 {
+		return
+	}
+	if s := t.status(); s != Downloading && s != DownloadingMetadata {
+		return
+	}
+	t.log.Info("no peers are connected, announcing to get new peers")
+	t.setNeedMorePeers(true)
+	t.triggerAnnounces()
+}
+
+func (t *torrent) stopNoPeersTimer() {
+	if t.noPeersTimer != nil {
+		t.noPeersTimer.Stop()
+		t.noPeersTimer = nil
+		t.noPeersC = nil
+	}
 }
 
 func (t *torrent) addPeerString(addr string) error {
@@ -34,6 +76,13 @@ func (t *torrent) addPeerString(addr string) error {
 	}
 	port := int(port64)
 	ip := net.ParseIP(hoststr)
+	if onion.IsOnion(hoststr) && t.session.torProxy == nil {
+		return errOnionWithoutTor
+	}
+	if ip == nil && t.session.torProxy != nil {
+		// Host name is resolved by Tor. Resolving it locally would leak the DNS query.
+		ip = t.session.onions.IP(hoststr)
+	}
 	if ip == nil {
 		go t.resolveAndAddPeer(hoststr, port)
 		return nil
@@ -48,43 +97,85 @@ func (t *torrent) addPeerString(addr string) error {
 
 func (t *torrent) resolveAndAddPeer(host string, port int) {
 	ctx, cancel := context.WithCancel(context.Background())
-	done := make(chan struct{})
+	defer cancel()
 	go func() {
 		select {
 		case <-t.closeC:
-		case <-done:
+			cancel()
+		case <-ctx.Done():
 		}
-		cancel()
 	}()
-	ip, err := resolver.ResolveIPv4(ctx, t.session.config.DNSResolveTimeout, host)
+	ips, err := t.session.peerHostCache.LookupIP(ctx, t.session.config.DNSResolveTimeout, host)
 	if err != nil {
+		t.log.Debugf("cannot resolve peer host %s: %s", host, err)
 		return
 	}
-	addrs := []*net.TCPAddr{{IP: ip, Port: port}}
-	t.handleNewPeers(addrs, peersource.Manual)
+	addrs := make([]*net.TCPAddr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = &net.TCPAddr{IP: ip, Port: port}
+	}
+	select {
+	case t.hostPeersC <- addrs:
+	case <-t.closeC:
+	}
+}
+
+// handleHostPeer adds the first address of a peer given as host name.
+// Remaining addresses are tried in order when the connection fails.
+func (t *torrent) handleHostPeer(addrs []*net.TCPAddr) {
+	if len(addrs) > 1 {
+		t.peerFallbackAddrs[addrs[0].String()] = addrs[1:]
+	}
+	t.handleNewPeers(addrs[:1], nil, peersource.Manual)
 }
 
-func (t *torrent) handleNewPeers(addrs []*net.TCPAddr, source peersource.Source) {
+// handleNewPeers adds the addresses to the address list and dials them.
+// flags are the PEX flags of the addresses and may be nil if the source does not provide them.
+func (t *torrent) handleNewPeers(addrs []*net.TCPAddr, flags []byte, source peersource.Source) {
 	t.log.Debugf("received %d peers from %s", len(addrs), source)
-	t.setNeedMorePeers(false)
-	if status := t.status(); status == Stopped || status == Stopping {
+	t.checkMinConnectedPeers()
+	// Keep asking for more peers while the number of connected peers is below the minimum.
+	t.setNeedMorePeers(t.seekingPeers)
+	if status := t.status(); status == Stopped || status == SeedingComplete || status == Stopping || status == Retrying {
 		return
 	}
 	if !t.completed {
-		addrs = t.filterBannedIPs(addrs)
-		t.addrList.Push(addrs, source)
+		addrs, flags = filterAddrs(addrs, flags, t.notBanned)
+		if !t.session.config.ListenIPv6 && externalip.FirstExternalIPv6() == nil {
+			// IPv6 peers cannot be reached without an IPv6 address.
+			addrs, flags = filterAddrs(addrs, flags, reachableWithoutIPv6)
+		}
+		t.addrList.PushWithFlags(addrs, flags, source)
 		t.dialAddresses()
 	}
 }
 
-func (t *torrent) filterBannedIPs(a []*net.TCPAddr) []*net.TCPAddr {
+// filterAddrs returns the addresses that keep returns true for. Flags of the removed addresses are removed if flags is not nil.
+func filterAddrs(a []*net.TCPAddr, flags []byte, keep func(*net.TCPAddr) bool) ([]*net.TCPAddr, []byte) {
 	b := a[:0]
-	for _, x := range a {
-		if _, ok := t.bannedPeerIPs[x.IP.String()]; !ok {
+	var bf []byte
+	if flags != nil {
+		bf = flags[:0]
+	}
+	for i, x := range a {
+		if keep(x) {
 			b = append(b, x)
+			if flags != nil {
+				bf = append(bf, flags[i])
+			}
 		}
 	}
-	return b
+	return b, bf
+}
+
+func (t *torrent) notBanned(addr *net.TCPAddr) bool {
+	_, ok := t.bannedPeerIPs[addr.IP.String()]
+	return !ok
+}
+
+// reachableWithoutIPv6 returns false for IPv6 addresses except the ones mapped to onion peers.
+func reachableWithoutIPv6(addr *net.TCPAddr) bool {
+	return addr.IP.To4() != nil || onion.IsMapped(addr.IP)
 }
 
 func (t *torrent) dialAddresses() {
@@ -94,32 +185,50 @@ func (t *torrent) dialAddresses() {
 	peersConnected := func() int {
 		return len(t.outgoingPeers) + len(t.outgoingHandshakers)
 	}
-	for peersConnected() < t.session.config.MaxPeerDial {
-		addr, src := t.addrList.Pop()
+	for peersConnected() < t.dialLimit() && !t.connectionLimitReached() {
+		addr, src, flags := t.addrList.Pop()
 		if addr == nil {
 			t.setNeedMorePeers(true)
 			return
 		}
-		ip := addr.IP.String()
-		if _, ok := t.connectedPeerIPs[ip]; ok {
-			continue
-		}
-		h := outgoinghandshaker.New(addr, src)
-		t.outgoingHandshakers[h] = struct{}{}
-		t.connectedPeerIPs[ip] = struct{}{}
-		go h.Run(
-			t.session.config.PeerConnectTimeout,
-			t.session.config.PeerHandshakeTimeout,
-			t.peerID,
-			t.infoHash,
-			t.outgoingHandshakerResultC,
-			t.session.extensions,
-			t.session.config.DisableOutgoingEncryption,
-			t.session.config.ForceOutgoingEncryption,
-		)
+		t.dialAddress(addr, src, flags)
 	}
 }
 
+// dialAddress starts an outgoing handshake with the peer.
+// Encrypted handshake is tried first with the peers that prefer encryption, even if outgoing encryption is disabled in config.
+func (t *torrent) dialAddress(addr *net.TCPAddr, src peersource.Source, flags byte) {
+	ip := addr.IP.String()
+	if _, ok := t.connectedPeerIPs[ip]; ok {
+		return
+	}
+	// Addresses are filtered when they are added to the list but blocklist may have been reloaded since then.
+	if t.session.config.BlocklistEnabledForOutgoingConnections && t.session.blocklist.Blocked(addr.IP) {
+		t.session.metrics.BlockListRejected.Inc(1)
+		t.log.Debugln("peer is blocked:", addr.String(), "total rejected:", t.session.metrics.BlockListRejected.Count())
+		return
+	}
+	enableEncryption, forceEncryption, _, _ := t.session.config.encryptionPolicy()
+	if flags&peerprotocol.PEXFlagPreferEncryption != 0 && t.session.config.Encryption != EncryptionDisabled {
+		enableEncryption = true
+	}
+	h := outgoinghandshaker.New(addr, src)
+	t.outgoingHandshakers[h] = struct{}{}
+	t.updateNumHandshakers()
+	t.connectedPeerIPs[ip] = struct{}{}
+	go h.Run(
+		t.session.peerDialer,
+		t.session.config.PeerConnectTimeout,
+		t.session.config.PeerHandshakeTimeout,
+		t.peerID,
+		t.infoHash,
+		t.outgoingHandshakerResultC,
+		t.session.extensions,
+		!enableEncryption,
+		forceEncryption,
+	)
+}
+
 func (t *torrent) startPeer(
 	conn net.Conn,
 	source peersource.Source,
@@ -129,32 +238,50 @@ func (t *torrent) startPeer(
 	cipher mse.CryptoMethod,
 ) {
 	addr := conn.RemoteAddr().(*net.TCPAddr)
-	t.pexAddPeer(addr)
-	_, ok := t.peerIDs[peerID]
-	if ok {
-		t.log.Debugf("peer with same id already connected. addr: %s id: %s", addr, peerID)
-		conn.Close()
-		t.pexDropPeer(addr)
-		t.dialAddresses()
-		return
+	outgoing := source != peersource.Incoming
+	if existing, ok := t.peerIDs[peerID]; ok {
+		_, existingOutgoing := t.outgoingPeers[existing]
+		if existingOutgoing == outgoing || existingOutgoing == t.preferOutgoing(peerID) {
+			t.log.Debugf("peer with same id already connected. addr: %s id: %s", addr, peerID)
+			t.recordConnection(addr, outgoing, cipher, StagePeerID, errDuplicatePeerID)
+			delete(t.connectedPeerIPs, addr.IP.String())
+			conn.Close()
+			t.dialAddresses()
+			return
+		}
+		t.log.Debugf("replacing connection of peer with same id. old addr: %s new addr: %s id: %s", existing.Addr(), addr, peerID)
+		t.closePeer(existing)
 	}
-	t.peerIDs[peerID] = struct{}{}
+	t.recordConnection(addr, outgoing, cipher, "", nil)
 
-	pe := peer.New(conn, source, peerID, extensions, cipher, t.session.config.PieceReadTimeout, t.session.config.RequestTimeout, t.session.config.MaxRequestsIn, t.session.bucketDownload, t.session.bucketUpload)
+	pe := peer.New(conn, source, peerID, extensions, cipher, t.session.config.PieceReadTimeout, t.session.config.RequestTimeout, t.session.config.MaxRequestsIn, t.limitDownload, speedlimit.New(t.session.config.SpeedLimitUploadPerPeer, t.limitUpload), t.session.isWireTraceEnabled(addr))
+	t.peerIDs[peerID] = pe
 	t.peers[pe] = struct{}{}
 	peers[pe] = struct{}{}
+	t.checkMinConnectedPeers()
 	if t.info != nil {
 		pe.Bitfield = bitfield.New(t.info.NumPieces)
 	}
+	t.pexAddPeer(pe)
 	go pe.Run(t.messages, t.pieceMessagesC.SendC(), t.peerSnubbedC, t.peerDisconnectedC)
 	t.session.metrics.Peers.Inc(1)
+	atomic.AddInt32(&t.numPeers, 1)
+	if t.session.config.MaxPeerConnectionsGlobal > 0 {
+		go t.session.checkGlobalPeerLimit()
+	}
 	t.sendFirstMessage(pe)
 	t.recentlySeen.Add(pe.Addr())
 }
 
 func (t *torrent) sendFirstMessage(p *peer.Peer) {
 	bf := t.bitfield
+	superSeeding := t.superSeedingActive()
 	switch {
+	case superSeeding:
+		// Pretend to have no pieces. Pieces are revealed one by one after the handshake.
+		if p.FastEnabled {
+			p.SendMessage(peerprotocol.HaveNoneMessage{})
+		}
 	case p.FastEnabled && bf != nil && bf.All():
 		msg := peerprotocol.HaveAllMessage{}
 		p.SendMessage(msg)
@@ -173,22 +300,30 @@ func (t *torrent) sendFirstMessage(p *peer.Peer) {
 	}
 	if p.ExtensionsEnabled {
 		extHandshakeMsg := peerprotocol.NewExtensionHandshake(metadataSize, t.getClientVersion(), p.Addr().IP, t.session.config.MaxRequestsIn)
+		if t.holepunchEnabled() {
+			extHandshakeMsg.M[peerprotocol.ExtensionKeyHolepunch] = peerprotocol.ExtensionIDHolepunch
+		}
 		msg := peerprotocol.ExtensionMessage{
 			ExtendedMessageID: peerprotocol.ExtensionIDHandshake,
 			Payload:           extHandshakeMsg,
 		}
 		p.SendMessage(msg)
 	}
-	if p.DHTEnabled {
+	if p.DHTEnabled && t.dhtEnabled() {
 		msg := peerprotocol.PortMessage{Port: t.session.config.DHTPort}
 		p.SendMessage(msg)
 	}
-	if p.FastEnabled && t.pieces != nil {
+	if superSeeding {
+		t.addSuperSeedPeer(p)
+	} else if p.FastEnabled && t.pieces != nil {
 		p.GenerateAndSendAllowedFastMessages(t.session.config.AllowedFastSet, t.info.NumPieces, t.infoHash, t.pieces)
 	}
 }
 
 func (t *torrent) getClientVersion() string {
+	if t.session.config.ClientVersion != "" {
+		return t.session.config.ClientVersion
+	}
 	if t.info != nil && t.info.Private {
 		return t.session.config.PrivateExtensionHandshakeClientVersion
 	}
@@ -225,3 +360,10 @@ func (t *torrent) handlePeerSnubbed(pe *peer.Peer) {
 		t.startInfoDownloaders()
 	}
 }
+
+// preferOutgoing returns true if the connection opened by us is kept when we have two connections with the peer with id.
+// When both sides connect to each other at the same time, each side keeps the connection opened by the side with the lower peer ID,
+// so they keep the same connection and close the other.
+func (t *torrent) preferOutgoing(id [20]byte) bool {
+	return bytes.Compare(t.peerID[:], id[:]) < 0
+}
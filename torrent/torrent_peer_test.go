@@ -0,0 +1,104 @@
+package torrent
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/fortytw2/leaktest"
+)
+
+func TestDownloadHostPeerFallback(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor := addTorrentFile(t, s, nil)
+
+	// Host name resolves to an address with no listener first, then to the seeder.
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := l.Addr().(*net.TCPAddr)
+	l.Close()
+	seederAddr, err := net.ResolveTCPAddr("tcp4", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor.torrent.hostPeersC <- []*net.TCPAddr{closedAddr, seederAddr}
+
+	assertCompleted(t, tor)
+}
+
+func TestSimultaneousConnect(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor := addTorrentFile(t, s, nil)
+	tor.torrent.trackers = nil
+	var port int
+	select {
+	case port = <-tor.torrent.NotifyListen():
+	case <-time.After(timeout):
+		t.Fatal("torrent is not listening")
+	}
+	waitPeers := func(total, outgoing int) {
+		waitFor(t, fmt.Sprintf("peers are not %d, outgoing: %d", total, outgoing), func() bool {
+			stats := tor.Stats()
+			return stats.Peers.Total == total && stats.Peers.Outgoing == outgoing
+		})
+	}
+
+	// Peer ID is higher than ours, so the connection opened by us must be kept.
+	var peerID [20]byte
+	for i := range peerID {
+		peerID[i] = 0xff
+	}
+	l, err := net.Listen("tcp4", "127.0.0.2:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// Peer connects to us.
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	conn1, _, _, _, err := btconn.Dial(addr, nil, time.Second, time.Second, false, false, [8]byte{}, tor.torrent.infoHash, peerID, make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn1.Close()
+	waitPeers(1, 0)
+
+	// We connect to the peer at the same time from the other side.
+	if err = tor.AddPeer(l.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn2, _, _, _, _, err := btconn.Accept(conn, time.Second, nil, false, func([20]byte) bool { return true }, [8]byte{}, peerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn2.Close()
+	waitPeers(1, 1)
+
+	// Incoming connection is closed.
+	if err = conn1.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.Copy(ioutil.Discard, conn1)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatal("incoming connection is not closed")
+	}
+}
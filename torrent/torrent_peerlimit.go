@@ -0,0 +1,151 @@
+package torrent
+
+import (
+	"sync/atomic"
+
+	"github.com/cenkalti/rain/internal/peer"
+)
+
+// adjustPeerDialLimit changes the max number of outgoing connections of the torrent by looking at the swarm health.
+// It is called periodically from the run loop and does nothing unless Config.AdaptivePeerLimit is enabled.
+//
+// The heuristic only applies while the torrent is downloading:
+//   - If the download speed of the torrent is over 90% of its download speed limit, the bandwidth is saturated
+//     and more connections only add overhead. The target is decreased by 10%.
+//     The limit is the stricter one of the torrent and the session download speed limits.
+//   - Otherwise, if less than a quarter of the target is made of useful peers (peers that we are actively
+//     downloading from), the target is increased by 10% to find more sources.
+//     Increasing is skipped if there are no peer addresses left to dial.
+//   - In other cases the target is not changed.
+func (t *torrent) adjustPeerDialLimit() {
+	cfg := t.session.config
+	if !cfg.AdaptivePeerLimit || t.status() != Downloading {
+		return
+	}
+	useful := len(t.pieceDownloaders) - len(t.pieceDownloadersChoked) - len(t.pieceDownloadersSnubbed)
+	speedLimit := stricterSpeedLimit(t.limitDownload.Rate(), t.session.limitDownload.Rate())
+	saturated := speedLimit > 0 && int64(t.downloadRate.Rate()) >= speedLimit*9/10
+	limit := nextPeerDialLimit(t.peerDialLimit, useful, t.addrList.Len() > 0, saturated)
+	limit = clampPeerDialLimit(limit, cfg.AdaptivePeerLimitMin, cfg.AdaptivePeerLimitMax)
+	if limit != t.peerDialLimit {
+		t.log.Debugf("changing peer dial limit from %d to %d", t.peerDialLimit, limit)
+		t.peerDialLimit = limit
+		t.dialAddresses()
+	}
+}
+
+func nextPeerDialLimit(current, useful int, hasAddrs, saturated bool) int {
+	step := current / 10
+	if step < 1 {
+		step = 1
+	}
+	switch {
+	case saturated:
+		return current - step
+	case useful < current/4 && hasAddrs:
+		return current + step
+	default:
+		return current
+	}
+}
+
+// stricterSpeedLimit returns the lower one of the limits. Zero means no limit.
+func stricterSpeedLimit(a, b int64) int64 {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+func clampPeerDialLimit(limit, min, max int) int {
+	if limit < min {
+		return min
+	}
+	if limit > max {
+		return max
+	}
+	return limit
+}
+
+// numConnections returns the number of connections of the torrent, including the ones in handshake state.
+func (t *torrent) numConnections() int {
+	return len(t.peers) + len(t.incomingHandshakers) + len(t.outgoingHandshakers)
+}
+
+// updateNumHandshakers must be called after the handshaker maps are changed.
+func (t *torrent) updateNumHandshakers() {
+	n := int32(len(t.incomingHandshakers) + len(t.outgoingHandshakers))
+	old := atomic.SwapInt32(&t.numHandshakers, n)
+	atomic.AddInt32(&t.session.numHandshakers, n-old)
+}
+
+// numConnections returns the number of connections in all torrents, including the ones in handshake state.
+func (s *Session) numConnections() int64 {
+	return s.metrics.Peers.Count() + int64(atomic.LoadInt32(&s.numHandshakers))
+}
+
+// connectionLimitReached returns true if the torrent must not dial or accept new connections.
+// See Config.MaxPeerConnectionsPerTorrent and Config.MaxPeerConnectionsGlobal.
+func (t *torrent) connectionLimitReached() bool {
+	cfg := t.session.config
+	if cfg.MaxPeerConnectionsPerTorrent > 0 && t.numConnections() >= cfg.MaxPeerConnectionsPerTorrent {
+		return true
+	}
+	if cfg.MaxPeerConnectionsGlobal > 0 && t.session.numConnections() >= int64(cfg.MaxPeerConnectionsGlobal) {
+		// Torrents with few peers are allowed to connect. Other torrents drop their peers to make room.
+		return t.numConnections() >= t.session.fairPeerShare()
+	}
+	return false
+}
+
+// dropSlowestPeer closes the connection to the peer that we download from at the lowest speed.
+func (t *torrent) dropSlowestPeer() {
+	var slowest *peer.Peer
+	for pe := range t.peers {
+		if slowest == nil || pe.DownloadSpeed() < slowest.DownloadSpeed() {
+			slowest = pe
+		}
+	}
+	if slowest == nil {
+		return
+	}
+	t.log.Debugln("global connection limit is exceeded, dropping peer:", slowest.Addr().String())
+	t.closePeer(slowest)
+}
+
+// fairPeerShare returns the number of connections each torrent can have under Config.MaxPeerConnectionsGlobal.
+func (s *Session) fairPeerShare() int {
+	n := int(atomic.LoadInt32(&s.numTorrents))
+	if n == 0 {
+		n = 1
+	}
+	share := s.config.MaxPeerConnectionsGlobal / n
+	if share < 1 {
+		share = 1
+	}
+	return share
+}
+
+// checkGlobalPeerLimit asks the torrent with the most peers to drop one if Config.MaxPeerConnectionsGlobal is exceeded.
+// It must not be called from the run loop of a torrent because it locks the torrent list.
+func (s *Session) checkGlobalPeerLimit() {
+	if s.config.MaxPeerConnectionsGlobal <= 0 || s.numConnections() <= int64(s.config.MaxPeerConnectionsGlobal) {
+		return
+	}
+	s.mTorrents.RLock()
+	var busiest *torrent
+	var max int32
+	for _, t := range s.torrents {
+		if n := atomic.LoadInt32(&t.torrent.numPeers); n > max {
+			busiest, max = t.torrent, n
+		}
+	}
+	s.mTorrents.RUnlock()
+	if busiest == nil {
+		return
+	}
+	select {
+	case busiest.dropPeerC <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,161 @@
+package torrent
+
+import (
+	"net"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/fortytw2/leaktest"
+)
+
+func TestNextPeerDialLimit(t *testing.T) {
+	if n := nextPeerDialLimit(80, 5, true, false); n != 88 {
+		t.Fatalf("limit must be increased when there are few useful peers, got %d", n)
+	}
+	if n := nextPeerDialLimit(80, 5, false, false); n != 80 {
+		t.Fatalf("limit must not change when there are no addresses to dial, got %d", n)
+	}
+	if n := nextPeerDialLimit(80, 40, true, false); n != 80 {
+		t.Fatalf("limit must not change when there are enough useful peers, got %d", n)
+	}
+	if n := nextPeerDialLimit(80, 5, true, true); n != 72 {
+		t.Fatalf("limit must be decreased when bandwidth is saturated, got %d", n)
+	}
+	if n := clampPeerDialLimit(250, 20, 200); n != 200 {
+		t.Fatalf("limit must not exceed max, got %d", n)
+	}
+}
+
+func TestStricterSpeedLimit(t *testing.T) {
+	cases := []struct {
+		torrent, session, expected int64
+	}{
+		{0, 0, 0},
+		{100, 0, 100},
+		{0, 200, 200},
+		{100, 200, 100},
+		{300, 200, 200},
+	}
+	for _, c := range cases {
+		if n := stricterSpeedLimit(c.torrent, c.session); n != c.expected {
+			t.Errorf("torrent limit: %d, session limit: %d, expected: %d, got: %d", c.torrent, c.session, c.expected, n)
+		}
+	}
+}
+
+// dialStalledConnections opens connections to the port from different addresses that never complete the handshake.
+// Returns the number of connections that are kept open and rejected by the torrent.
+func dialStalledConnections(t *testing.T, port, count int) (pending, rejected int) {
+	var conns []net.Conn
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for i := 0; i < count; i++ {
+		d := net.Dialer{LocalAddr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, byte(i+2))}}
+		conn, err := d.Dial("tcp4", "127.0.0.1:"+strconv.Itoa(port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err := conn.Read(make([]byte, 1))
+		if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+			pending++
+		} else {
+			rejected++
+		}
+	}
+	return
+}
+
+func waitListen(t *testing.T, tor *Torrent) int {
+	select {
+	case port := <-tor.torrent.NotifyListen():
+		return port
+	case <-time.After(timeout):
+		t.Fatal("torrent is not listening")
+	}
+	return 0
+}
+
+func TestConnectionLimitPerTorrent(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("needs multiple loopback addresses")
+	}
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	s.config.MaxPeerConnectionsPerTorrent = 2
+
+	tor := addTorrentFile(t, s, nil)
+	port := waitListen(t, tor)
+	pending, rejected := dialStalledConnections(t, port, 4)
+	if pending != 2 || rejected != 2 {
+		t.Fatalf("unexpected number of connections: pending=%d rejected=%d", pending, rejected)
+	}
+}
+
+func TestConnectionLimitGlobalCountsHandshakes(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("needs multiple loopback addresses")
+	}
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	s.config.MaxPeerConnectionsGlobal = 2
+
+	tor := addTorrentFile(t, s, nil)
+	port := waitListen(t, tor)
+	pending, rejected := dialStalledConnections(t, port, 4)
+	if pending != 2 || rejected != 2 {
+		t.Fatalf("unexpected number of connections: pending=%d rejected=%d", pending, rejected)
+	}
+}
+
+func TestConnectionLimitGlobalDropsFromBusiestTorrent(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("needs multiple loopback addresses")
+	}
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	s.config.MaxPeerConnectionsGlobal = 2
+
+	busy := addTorrentFile(t, s, nil)
+	busyPort := waitListen(t, busy)
+	other := addTorrentFile(t, s, nil)
+	otherPort := waitListen(t, other)
+
+	connect := func(ip byte, port int) net.Conn {
+		d := &net.Dialer{LocalAddr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, ip)}}
+		addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+		var peerID [20]byte
+		copy(peerID[:], "-XX0000-00000000000")
+		peerID[19] = ip
+		conn, _, _, _, err := btconn.Dial(addr, d, time.Second, time.Second, true, false, [8]byte{}, busy.torrent.infoHash, peerID, make(chan struct{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+
+	// Torrents get their fair share of the global limit before the limit is reached.
+	conn := connect(2, busyPort)
+	defer conn.Close()
+	conn = connect(3, busyPort)
+	defer conn.Close()
+	waitFor(t, "peers are not connected", func() bool { return busy.Stats().Peers.Total == 2 })
+
+	// Torrent with no peers is allowed to connect and the busiest torrent drops a peer to make room.
+	conn = connect(4, otherPort)
+	defer conn.Close()
+	waitFor(t, "peer is not connected", func() bool { return other.Stats().Peers.Total == 1 })
+	waitFor(t, "peer is not dropped", func() bool { return busy.Stats().Peers.Total == 1 })
+}
@@ -1,11 +1,44 @@
 package torrent
 
-import "net"
+import (
+	"net"
 
-func (t *torrent) pexAddPeer(addr *net.TCPAddr) {
-	for pe := range t.peers {
-		if pe.PEX != nil {
-			pe.PEX.Add(addr)
+	"github.com/cenkalti/rain/internal/onion"
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/peersource"
+	"github.com/cenkalti/rain/internal/pexlist"
+	"github.com/cenkalti/rain/internal/tracker"
+)
+
+// pexEnabled returns true if peer addresses can be exchanged with peers of the torrent.
+// PEX is never enabled for private torrents and is not started for magnet links until the metadata is downloaded.
+func (t *torrent) pexEnabled() bool {
+	return t.session.config.PEXEnabled && !t.disablePEX && t.info != nil && !t.info.Private
+}
+
+// startPEX starts sending PEX messages to the peer if it supports the extension.
+func (t *torrent) startPEX(pe *peer.Peer) {
+	if !t.pexEnabled() || pe.ExtensionHandshake == nil {
+		return
+	}
+	if _, ok := pe.ExtensionHandshake.M[peerprotocol.ExtensionKeyPEX]; ok {
+		pe.StartPEX(t.peers, &t.recentlySeen)
+	}
+}
+
+// pexAddPeer adds the address of the peer to the next PEX messages sent to other peers.
+// It is also called when the flags of the peer change.
+func (t *torrent) pexAddPeer(pe *peer.Peer) {
+	addr := pe.Addr()
+	if onion.IsMapped(addr.IP) {
+		// Mapped addresses of onion peers are meaningful only in this session.
+		return
+	}
+	flags := pe.PEXFlags()
+	for p := range t.peers {
+		if p.PEX != nil && p != pe {
+			p.PEX.Add(addr, flags)
 		}
 	}
 }
@@ -17,3 +50,70 @@ func (t *torrent) pexDropPeer(addr *net.TCPAddr) {
 		}
 	}
 }
+
+// handlePEXMessage adds the peers in "added" fields of the message to the address list.
+// Dropped peers are ignored because they may still be reachable from us.
+// Except the first message, at most pexlist.MaxPeers addresses are accepted from a message.
+// Peers flagged as seeds are skipped when the torrent is completed because two seeds have nothing to exchange.
+// Flags of remaining peers are kept in the address list for choosing the encryption of the outgoing connection.
+func (t *torrent) handlePEXMessage(pe *peer.Peer, msg peerprotocol.ExtensionPEXMessage) {
+	if !t.pexEnabled() {
+		return
+	}
+	pe.PEXReceived++
+	addrs, flags, err := decodePEXPeers(msg)
+	if err != nil {
+		pe.Logger().Errorln("cannot decode pex message:", err)
+		return
+	}
+	if pe.PEXReceived > 1 && len(addrs) > pexlist.MaxPeers {
+		addrs = addrs[:pexlist.MaxPeers]
+		flags = flags[:pexlist.MaxPeers]
+	}
+	if t.completed {
+		addrs, flags = filterSeeds(addrs, flags)
+	}
+	if len(addrs) > 0 {
+		t.addHolepunchRelays(pe, addrs)
+		t.handleNewPeers(addrs, flags, peersource.PEX)
+	}
+}
+
+// decodePEXPeers returns the added IPv4 and IPv6 peers in the message with their flags.
+// Flags are zero for peers that are not covered by "added.f" or "added6.f" fields.
+func decodePEXPeers(msg peerprotocol.ExtensionPEXMessage) ([]*net.TCPAddr, []byte, error) {
+	addrs, err := tracker.DecodePeersCompact([]byte(msg.Added))
+	if err != nil {
+		return nil, nil, err
+	}
+	flags := alignPEXFlags(msg.AddedFlags, len(addrs))
+	if len(msg.Added6) > 0 {
+		addrs6, err := tracker.DecodePeersCompact6([]byte(msg.Added6))
+		if err != nil {
+			return nil, nil, err
+		}
+		addrs = append(addrs, addrs6...)
+		flags = append(flags, alignPEXFlags(msg.Added6Flags, len(addrs6))...)
+	}
+	return addrs, flags, nil
+}
+
+// alignPEXFlags returns a flag for each of the n peers. Missing flags are zero, extra flags are ignored.
+func alignPEXFlags(s string, n int) []byte {
+	flags := make([]byte, n)
+	copy(flags, s)
+	return flags
+}
+
+// filterSeeds removes the peers that have peerprotocol.PEXFlagSeedOnly set.
+func filterSeeds(addrs []*net.TCPAddr, flags []byte) ([]*net.TCPAddr, []byte) {
+	b := addrs[:0]
+	bf := flags[:0]
+	for i, x := range addrs {
+		if flags[i]&peerprotocol.PEXFlagSeedOnly == 0 {
+			b = append(b, x)
+			bf = append(bf, flags[i])
+		}
+	}
+	return b, bf
+}
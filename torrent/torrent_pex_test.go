@@ -0,0 +1,42 @@
+package torrent
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/peerprotocol"
+)
+
+func TestDecodePEXPeers(t *testing.T) {
+	msg := peerprotocol.ExtensionPEXMessage{
+		Added:       string([]byte{1, 1, 1, 1, 0, 1, 2, 2, 2, 2, 0, 2}),
+		AddedFlags:  string([]byte{peerprotocol.PEXFlagPreferEncryption}),
+		Added6:      string(append(bytes.Repeat([]byte{0x20}, 16), 0, 3)),
+		Added6Flags: string([]byte{peerprotocol.PEXFlagSeedOnly, 0xff}),
+	}
+	addrs, flags, err := decodePEXPeers(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 3 {
+		t.Fatalf("expected 3 peers, got %d", len(addrs))
+	}
+	expected := []byte{peerprotocol.PEXFlagPreferEncryption, 0, peerprotocol.PEXFlagSeedOnly}
+	if !bytes.Equal(flags, expected) {
+		t.Fatalf("expected flags %v, got %v", expected, flags)
+	}
+
+	addrs, flags = filterSeeds(addrs, flags)
+	if len(addrs) != 2 || addrs[0].Port != 1 || addrs[1].Port != 2 {
+		t.Fatalf("unexpected peers after filtering seeds: %v", addrs)
+	}
+	if !bytes.Equal(flags, expected[:2]) {
+		t.Fatalf("unexpected flags after filtering seeds: %v", flags)
+	}
+
+	msg.Added = msg.Added[:5]
+	_, _, err = decodePEXPeers(msg)
+	if err == nil {
+		t.Fatal("expected error for invalid peer list")
+	}
+}
@@ -0,0 +1,33 @@
+package torrent
+
+import "time"
+
+// pickerWarmingUp returns true if pieces must not be picked yet.
+// Warm-up timer is started on the first call after the torrent is started.
+func (t *torrent) pickerWarmingUp() bool {
+	if t.pickerWarmedUp || t.session.config.PickerWarmupDelay <= 0 {
+		return false
+	}
+	if t.pickerWarmupTimer == nil {
+		t.log.Debugf("waiting %s before picking pieces", t.session.config.PickerWarmupDelay)
+		t.pickerWarmupTimer = time.NewTimer(t.session.config.PickerWarmupDelay)
+		t.pickerWarmupC = t.pickerWarmupTimer.C
+	}
+	return true
+}
+
+func (t *torrent) handlePickerWarmupDone() {
+	t.pickerWarmupTimer = nil
+	t.pickerWarmupC = nil
+	t.pickerWarmedUp = true
+	t.startPieceDownloaders()
+}
+
+func (t *torrent) stopPickerWarmup() {
+	if t.pickerWarmupTimer != nil {
+		t.pickerWarmupTimer.Stop()
+		t.pickerWarmupTimer = nil
+		t.pickerWarmupC = nil
+	}
+	t.pickerWarmedUp = false
+}
@@ -0,0 +1,36 @@
+package torrent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+)
+
+func TestPickerWarmupDelay(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	const delay = time.Second
+	s.config.PickerWarmupDelay = delay
+
+	tor := addTorrentFile(t, s, nil)
+	tor.torrent.trackers = nil
+	err := tor.AddPeer(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	waitFor(t, "peer is not connected", func() bool { return tor.Stats().Peers.Total == 1 })
+	time.Sleep(delay / 2)
+	if n := tor.Stats().Bytes.Downloaded; n != 0 {
+		t.Fatalf("downloaded %d bytes before warm-up delay", n)
+	}
+
+	assertCompleted(t, tor)
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Fatalf("download completed before warm-up delay: %s", elapsed)
+	}
+}
@@ -0,0 +1,101 @@
+package torrent
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// pieceDeadline is the time that a piece must be downloaded before, set with SetPieceDeadline.
+type pieceDeadline struct {
+	Time time.Time
+	// EventPieceDeadlineMissed is sent only once for each deadline.
+	Missed bool
+}
+
+type pieceDeadlineRequest struct {
+	Index uint32
+	// Zero value clears the deadline.
+	Deadline time.Time
+	Response chan error
+}
+
+func (t *torrent) SetPieceDeadline(index uint32, deadline time.Time) error {
+	req := pieceDeadlineRequest{Index: index, Deadline: deadline, Response: make(chan error, 1)}
+	select {
+	case t.pieceDeadlineCommandC <- req:
+	case <-t.closeC:
+		return errClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.closeC:
+		return errClosed
+	}
+}
+
+// setPieceDeadline makes the piece at index picked before all other pieces, nearest deadline first.
+// The piece is downloaded even if it is in a skipped file. Zero deadline clears the deadline of the piece.
+func (t *torrent) setPieceDeadline(index uint32, deadline time.Time) error {
+	if t.info == nil {
+		return errors.New("torrent metadata is not downloaded yet")
+	}
+	if index >= t.info.NumPieces {
+		return errInvalidPieceIndex
+	}
+	if deadline.IsZero() {
+		delete(t.pieceDeadlines, index)
+	} else if t.bitfield == nil || !t.bitfield.Test(index) {
+		t.pieceDeadlines[index] = pieceDeadline{Time: deadline}
+	}
+	if t.pieces == nil || t.bitfield == nil {
+		// Deadlines are applied after allocation and verification is done.
+		return nil
+	}
+	t.updateWantedPieces()
+	return nil
+}
+
+// checkPieceDeadlines sends EventPieceDeadlineMissed for the pieces that are not expected to be downloaded in time.
+// Pieces are assumed to be downloaded in order of their deadlines at the current download speed.
+func (t *torrent) checkPieceDeadlines(now time.Time) {
+	if len(t.pieceDeadlines) == 0 || t.pieces == nil || t.bitfield == nil {
+		return
+	}
+	indices := make([]uint32, 0, len(t.pieceDeadlines))
+	for i := range t.pieceDeadlines {
+		if t.bitfield.Test(i) {
+			// Downloaded. Deadline is not needed anymore.
+			delete(t.pieceDeadlines, i)
+			if t.piecePicker != nil {
+				t.piecePicker.SetDeadline(i, time.Time{})
+			}
+			continue
+		}
+		indices = append(indices, i)
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		return t.pieceDeadlines[indices[i]].Time.Before(t.pieceDeadlines[indices[j]].Time)
+	})
+	rate := t.downloadRate.Rate()
+	var missed []uint32
+	var incomplete int64
+	for _, i := range indices {
+		incomplete += int64(t.pieces[i].Length)
+		pd := t.pieceDeadlines[i]
+		if pd.Missed {
+			continue
+		}
+		// Unknown if nothing is being downloaded, until the deadline passes.
+		d := eta(incomplete, rate)
+		if now.After(pd.Time) || (d != nil && now.Add(*d).After(pd.Time)) {
+			pd.Missed = true
+			t.pieceDeadlines[i] = pd
+			missed = append(missed, i)
+		}
+	}
+	if len(missed) > 0 {
+		t.sendEvent(Event{Type: EventPieceDeadlineMissed, Pieces: missed})
+	}
+}
@@ -0,0 +1,41 @@
+package torrent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+)
+
+func TestPieceDeadlineMissed(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor := addTorrentFile(t, s, nil)
+	if err := tor.SetPieceDeadline(tor.torrent.info.NumPieces, time.Now()); err != errInvalidPieceIndex {
+		t.Fatalf("invalid piece index must be rejected: %v", err)
+	}
+	waitStatus(t, tor, Downloading)
+	// There are no peers, so the piece cannot be downloaded in time.
+	if err := tor.SetPieceDeadline(1, time.Now().Add(100*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tor.SetPieceDeadline(2, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		select {
+		case e := <-tor.Events():
+			if e.Type != EventPieceDeadlineMissed {
+				continue
+			}
+			if len(e.Pieces) != 1 || e.Pieces[0] != 1 {
+				t.Fatalf("unexpected pieces: %v", e.Pieces)
+			}
+			return
+		case <-time.After(timeout):
+			t.Fatal("missed deadline is not reported")
+		}
+	}
+}
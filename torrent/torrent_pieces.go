@@ -5,30 +5,47 @@ import (
 	"time"
 
 	"github.com/cenkalti/rain/internal/handshaker/outgoinghandshaker"
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/piecedownloader"
+	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
+	"github.com/cenkalti/rain/internal/verifier"
 )
 
 func (t *torrent) writeBitfield() error {
-	err := t.session.resumer.WriteBitfield(t.id, t.bitfield.Bytes())
+	stats, err := verifier.FileStats(t.storage, t.info.Files)
+	if err != nil {
+		// Empty stats do not match the files, so the pieces are verified on next start.
+		t.log.Warningln("cannot get stats of files:", err)
+		stats = []verifier.FileStat{}
+	}
+	t.fileStats = stats
+	err = t.session.resumer.WriteBitfield(t.id, t.bitfield.Bytes(), fileStatsToSpec(stats), t.resumeStats())
 	if err != nil {
 		err = fmt.Errorf("cannot write bitfield to resume db: %s", err)
 		t.log.Errorln(err)
+		return err
 	}
-	return err
+	t.mBitfield.Lock()
+	t.bitfieldDirty = false
+	t.mBitfield.Unlock()
+	return nil
 }
 
 func (t *torrent) checkCompletion() bool {
 	if t.completed {
 		return true
 	}
-	if !t.bitfield.All() {
+	if !t.wantedPiecesDone() {
 		return false
 	}
 	t.completed = true
 	close(t.completeC)
+	t.sendEvent(Event{Type: EventDownloadComplete})
 	for h := range t.outgoingHandshakers {
 		h.Close()
 	}
 	t.outgoingHandshakers = make(map[*outgoinghandshaker.OutgoingHandshaker]struct{})
+	t.updateNumHandshakers()
 	for _, src := range t.webseedSources {
 		t.closeWebseedDownloader(src)
 	}
@@ -54,3 +71,43 @@ func (t *torrent) checkCompletion() bool {
 	}
 	return true
 }
+
+// cancelDuplicateRequests gives the block to other downloaders of the same piece and cancels their requests for the block.
+// Same piece is downloaded from multiple peers in endgame mode.
+// Returns the other downloader if it has completed the piece with this block.
+func (t *torrent) cancelDuplicateRequests(pd *piecedownloader.PieceDownloader, block piece.Block, data []byte) *piecedownloader.PieceDownloader {
+	if t.piecePicker == nil {
+		return nil
+	}
+	var done *piecedownloader.PieceDownloader
+	for _, pe := range t.piecePicker.RequestedPeers(pd.Piece.Index) {
+		pd2, ok := t.pieceDownloaders[pe]
+		if !ok || pd2 == pd || pd2.Piece.Index != pd.Piece.Index {
+			continue
+		}
+		pd2.GotBlockFromOther(block, data)
+		if done == nil && pd2.Done() {
+			done = pd2
+		}
+	}
+	return done
+}
+
+func fileStatsToSpec(stats []verifier.FileStat) []boltdbresumer.FileStat {
+	ret := make([]boltdbresumer.FileStat, len(stats))
+	for i, st := range stats {
+		ret[i] = boltdbresumer.FileStat{Size: st.Size, ModTime: st.ModTime}
+	}
+	return ret
+}
+
+func fileStatsFromSpec(stats []boltdbresumer.FileStat) []verifier.FileStat {
+	if stats == nil {
+		return nil
+	}
+	ret := make([]verifier.FileStat, len(stats))
+	for i, st := range stats {
+		ret[i] = verifier.FileStat{Size: st.Size, ModTime: st.ModTime}
+	}
+	return ret
+}
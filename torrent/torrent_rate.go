@@ -0,0 +1,104 @@
+package torrent
+
+import (
+	"math"
+	"time"
+)
+
+// rateTimeConstant is the time constant of the moving average of transfer rates.
+// After the rate changes, the average reaches 63% of the change in this duration.
+const rateTimeConstant = 20 * time.Second
+
+// movingRate is the exponentially weighted moving average of a transfer rate.
+// It is updated with the value of a byte counter sampled on a ticker in the torrent loop.
+type movingRate struct {
+	// Bytes per second.
+	rate float64
+	// Value of the counter at the last sample.
+	count int64
+	// Time of the last sample. Zero if there is no sample yet.
+	time time.Time
+	// False until the rate of the first interval is known.
+	initialized bool
+}
+
+// update adds a sample of the counter at now.
+func (r *movingRate) update(count int64, now time.Time) {
+	if r.time.IsZero() {
+		r.count = count
+		r.time = now
+		return
+	}
+	dt := now.Sub(r.time).Seconds()
+	if dt <= 0 {
+		return
+	}
+	instant := float64(count-r.count) / dt
+	r.count = count
+	r.time = now
+	if !r.initialized {
+		// Starting the average from zero would make the rate ramp up slowly after start.
+		r.rate = instant
+		r.initialized = true
+		return
+	}
+	alpha := 1 - math.Exp(-dt/rateTimeConstant.Seconds())
+	r.rate += alpha * (instant - r.rate)
+}
+
+// Rate returns the average in bytes per second.
+func (r *movingRate) Rate() int {
+	return int(r.rate)
+}
+
+func (r *movingRate) reset() {
+	*r = movingRate{}
+}
+
+// updateRates samples the transfer counters of the torrent. Called every second from the torrent loop.
+func (t *torrent) updateRates(now time.Time) {
+	if s := t.status(); s == Stopped || s == SeedingComplete {
+		return
+	}
+	t.downloadRate.update(t.bytesDownloaded.Count(), now)
+	t.uploadRate.update(t.bytesUploaded.Count(), now)
+	for pe := range t.peers {
+		r, ok := t.peerDownloadRates[pe]
+		if !ok {
+			r = new(movingRate)
+			t.peerDownloadRates[pe] = r
+		}
+		r.update(pe.BytesDownloaded(), now)
+	}
+}
+
+// eta returns the time remaining to download incomplete bytes with the average download rate.
+// Returns nil if rate is zero. Long durations are rounded so the value does not change on every call.
+func eta(incomplete int64, rate int) *time.Duration {
+	if rate <= 0 {
+		return nil
+	}
+	eta := time.Duration(float64(incomplete) / float64(rate) * float64(time.Second))
+	switch {
+	case eta > 8*time.Hour:
+		eta = eta.Round(time.Hour)
+	case eta > 4*time.Hour:
+		eta = eta.Round(30 * time.Minute)
+	case eta > 2*time.Hour:
+		eta = eta.Round(15 * time.Minute)
+	case eta > time.Hour:
+		eta = eta.Round(5 * time.Minute)
+	case eta > 30*time.Minute:
+		eta = eta.Round(1 * time.Minute)
+	case eta > 15*time.Minute:
+		eta = eta.Round(30 * time.Second)
+	case eta > 5*time.Minute:
+		eta = eta.Round(15 * time.Second)
+	case eta > time.Minute:
+		eta = eta.Round(5 * time.Second)
+	default:
+		// Round up, so the torrent is not shown complete until it is.
+		eta = (eta + time.Second - 1).Truncate(time.Second)
+	}
+	return &eta
+}
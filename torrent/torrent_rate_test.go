@@ -0,0 +1,49 @@
+package torrent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMovingRate(t *testing.T) {
+	var r movingRate
+	now := time.Now()
+	var count int64
+	r.update(count, now)
+	if r.Rate() != 0 {
+		t.Fatalf("rate must be zero before the second sample, got %d", r.Rate())
+	}
+	for i := 0; i < 10; i++ {
+		now = now.Add(time.Second)
+		count += 1000
+		r.update(count, now)
+	}
+	if r.Rate() != 1000 {
+		t.Fatalf("unexpected rate: %d", r.Rate())
+	}
+	// A single spike must not change the average much.
+	now = now.Add(time.Second)
+	count += 100000
+	r.update(count, now)
+	if r.Rate() < 1000 || r.Rate() > 10000 {
+		t.Fatalf("unexpected rate after spike: %d", r.Rate())
+	}
+	// Rate decays to zero when nothing is transferred.
+	for i := 0; i < 300; i++ {
+		now = now.Add(time.Second)
+		r.update(count, now)
+	}
+	if r.Rate() != 0 {
+		t.Fatalf("unexpected rate after stall: %d", r.Rate())
+	}
+	if eta(1000, r.Rate()) != nil {
+		t.Fatal("eta must be unknown when rate is zero")
+	}
+	if d := eta(1500, 1000); d == nil || *d != 2*time.Second {
+		t.Fatalf("unexpected eta: %v", d)
+	}
+	r.reset()
+	if r.Rate() != 0 {
+		t.Fatal("rate is not reset")
+	}
+}
@@ -0,0 +1,136 @@
+package torrent
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/cenkalti/rain/internal/mse"
+)
+
+var (
+	errDuplicatePeerID = errors.New("peer with same id is already connected")
+	errTorrentRemoved  = errors.New("peer has connected for a removed torrent")
+)
+
+// ConnectionAttempt is the outcome of a connection with a peer. Returned from Torrent.RecentConnections.
+type ConnectionAttempt struct {
+	// Address of the peer.
+	Addr net.Addr
+	// Time when the connection is established or has failed.
+	Time time.Time
+	// True if we have connected to the peer, false if the peer has connected to us.
+	Outgoing bool
+	// True if the encryption handshake is done.
+	// For outgoing connections, false means that plaintext fallback is used if encryption is enabled.
+	EncryptedHandshake bool
+	// True if the peer protocol messages are encrypted after the handshake.
+	EncryptedStream bool
+	// Stage of the connection that has failed. Not set if Error is nil.
+	Stage ConnectionStage
+	// Error of the failed connection. nil if the peer is connected.
+	Error error
+}
+
+// ConnectionStage is a step of establishing a connection with a peer.
+type ConnectionStage string
+
+const (
+	// StageConnect is opening the TCP connection. Incoming connections rejected by the limits fail at this stage.
+	StageConnect ConnectionStage = "connect"
+	// StageEncryption is the MSE key exchange.
+	StageEncryption ConnectionStage = "encryption"
+	// StageHandshake is the BitTorrent protocol handshake.
+	StageHandshake ConnectionStage = "handshake"
+	// StageInfoHash indicates that the peer has sent a different info hash in handshake.
+	StageInfoHash ConnectionStage = "info hash"
+	// StagePeerID indicates that the connection is dropped because it is our own connection
+	// or another peer with the same ID is already connected.
+	StagePeerID ConnectionStage = "peer id"
+)
+
+func connectionStage(err error) ConnectionStage {
+	var serr *btconn.StageError
+	if !errors.As(err, &serr) {
+		return StageHandshake
+	}
+	switch serr.Stage {
+	case btconn.StageDial:
+		return StageConnect
+	case btconn.StageEncryption:
+		return StageEncryption
+	case btconn.StageInfoHash:
+		return StageInfoHash
+	case btconn.StagePeerID:
+		return StagePeerID
+	default:
+		return StageHandshake
+	}
+}
+
+// connectionLog is a ring buffer that keeps the recent connection attempts.
+type connectionLog struct {
+	attempts []ConnectionAttempt
+	// Index of the next attempt to overwrite when the buffer is full.
+	next int
+}
+
+func newConnectionLog(size int) *connectionLog {
+	return &connectionLog{attempts: make([]ConnectionAttempt, 0, size)}
+}
+
+func (l *connectionLog) Add(a ConnectionAttempt) {
+	if cap(l.attempts) == 0 {
+		return
+	}
+	if len(l.attempts) < cap(l.attempts) {
+		l.attempts = append(l.attempts, a)
+		return
+	}
+	l.attempts[l.next] = a
+	l.next = (l.next + 1) % len(l.attempts)
+}
+
+// List returns the attempts from oldest to newest.
+func (l *connectionLog) List() []ConnectionAttempt {
+	ret := make([]ConnectionAttempt, 0, len(l.attempts))
+	ret = append(ret, l.attempts[l.next:]...)
+	return append(ret, l.attempts[:l.next]...)
+}
+
+// recordConnection adds the outcome of a connection to the list returned from RecentConnections.
+func (t *torrent) recordConnection(addr net.Addr, outgoing bool, cipher mse.CryptoMethod, stage ConnectionStage, err error) {
+	t.recentConnections.Add(ConnectionAttempt{
+		Addr:               addr,
+		Time:               time.Now(),
+		Outgoing:           outgoing,
+		EncryptedHandshake: cipher != 0,
+		EncryptedStream:    cipher == mse.RC4,
+		Stage:              stage,
+		Error:              err,
+	})
+}
+
+// recordRejectedConnection records an incoming connection that is closed before the handshake.
+func (t *torrent) recordRejectedConnection(addr net.Addr, reason string) {
+	t.recordConnection(addr, false, 0, StageConnect, errors.New("rejected: "+reason))
+}
+
+type connectionsRequest struct {
+	Response chan []ConnectionAttempt
+}
+
+func (t *torrent) RecentConnections() []ConnectionAttempt {
+	var attempts []ConnectionAttempt
+	req := connectionsRequest{Response: make(chan []ConnectionAttempt, 1)}
+	select {
+	case t.connectionsCommandC <- req:
+	case <-t.closeC:
+	}
+	select {
+	case attempts = <-req.Response:
+	case <-t.closeC:
+	}
+	return attempts
+}
@@ -0,0 +1,52 @@
+package torrent
+
+import (
+	"net"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+)
+
+func TestRecentConnections(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	// Nothing listens on this port, so the connection is refused.
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := l.Addr().String()
+	l.Close()
+
+	tor := addTorrentFile(t, s, nil)
+	if err = tor.AddPeer(closedAddr); err != nil {
+		t.Fatal(err)
+	}
+	if err = tor.AddPeer(addr); err != nil {
+		t.Fatal(err)
+	}
+	assertCompleted(t, tor)
+
+	var connected, refused bool
+	for _, ca := range tor.RecentConnections() {
+		if !ca.Outgoing {
+			continue
+		}
+		switch ca.Addr.String() {
+		case addr:
+			connected = ca.Error == nil
+		case closedAddr:
+			refused = ca.Error != nil && ca.Stage == StageConnect
+		}
+	}
+	if !connected {
+		t.Fatal("successful connection is not recorded")
+	}
+	if !refused {
+		t.Fatal("refused connection is not recorded at connect stage")
+	}
+}
@@ -0,0 +1,122 @@
+package torrent
+
+import (
+	"net"
+
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peersource"
+)
+
+// Peers in these networks are not saved in resume data because their addresses are not reachable from other networks
+// or they are likely to change between runs.
+var nonPublicNetworks = parseCIDRs(
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"fc00::/7",
+)
+
+func parseCIDRs(a ...string) []*net.IPNet {
+	ret := make([]*net.IPNet, len(a))
+	for i, s := range a {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			panic(err)
+		}
+		ret[i] = n
+	}
+	return ret
+}
+
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range nonPublicNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// markResumePeer is called when a piece block is received from the peer.
+// The address of the peer is moved to the front of the resume peers list.
+func (t *torrent) markResumePeer(pe *peer.Peer) {
+	if t.session.config.MaxResumePeers <= 0 {
+		return
+	}
+	if _, ok := t.resumePeersMarked[pe]; ok {
+		return
+	}
+	t.resumePeersMarked[pe] = struct{}{}
+	// Incoming peers connect from random ports, we cannot dial them.
+	if pe.Source == peersource.Incoming {
+		return
+	}
+	addr := pe.Addr()
+	if !isPublicIP(addr.IP) {
+		return
+	}
+	s := addr.String()
+	delete(t.resumePeerFailures, s)
+	peers := make([]string, 0, len(t.resumePeers)+1)
+	peers = append(peers, s)
+	for _, p := range t.resumePeers {
+		if p != s {
+			peers = append(peers, p)
+		}
+	}
+	if len(peers) > t.session.config.MaxResumePeers {
+		peers = peers[:t.session.config.MaxResumePeers]
+	}
+	t.resumePeers = peers
+}
+
+// resumePeerFailed is called when the connection to a peer in resume data fails.
+// The address is removed from the resume peers list after Config.MaxResumePeerFailures consecutive failures,
+// so a peer that is offline for a short time is not forgotten.
+func (t *torrent) resumePeerFailed(addr *net.TCPAddr) {
+	s := addr.String()
+	t.resumePeerFailures[s]++
+	if t.resumePeerFailures[s] < t.session.config.MaxResumePeerFailures {
+		return
+	}
+	delete(t.resumePeerFailures, s)
+	for i, p := range t.resumePeers {
+		if p == s {
+			t.resumePeers = append(t.resumePeers[:i:i], t.resumePeers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (t *torrent) addResumePeers() {
+	if t.session.config.MaxResumePeers <= 0 || len(t.resumePeers) == 0 {
+		return
+	}
+	addrs := make([]*net.TCPAddr, 0, len(t.resumePeers))
+	for _, s := range t.resumePeers {
+		addr, err := net.ResolveTCPAddr("tcp", s)
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	t.handleNewPeers(addrs, nil, peersource.Resume)
+}
+
+func (t *torrent) writeResumePeers() {
+	// Failure counts of addresses that are dropped from the list are not saved.
+	failures := make(map[string]int)
+	for _, p := range t.resumePeers {
+		if n, ok := t.resumePeerFailures[p]; ok {
+			failures[p] = n
+		}
+	}
+	err := t.session.resumer.WritePeers(t.id, t.resumePeers, failures)
+	if err != nil {
+		t.log.Errorf("cannot write peers to resume db: %s", err)
+	}
+}
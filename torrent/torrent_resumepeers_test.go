@@ -0,0 +1,33 @@
+package torrent
+
+import (
+	"net"
+	"testing"
+)
+
+func TestResumePeerFailures(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.MaxResumePeerFailures = 2
+	tor := &torrent{
+		session:            &Session{config: cfg},
+		resumePeers:        []string{"1.1.1.1:1", "2.2.2.2:2"},
+		resumePeerFailures: map[string]int{"2.2.2.2:2": 1},
+	}
+	addr1 := &net.TCPAddr{IP: net.IPv4(1, 1, 1, 1), Port: 1}
+	addr2 := &net.TCPAddr{IP: net.IPv4(2, 2, 2, 2), Port: 2}
+
+	// A single failure does not remove the peer.
+	tor.resumePeerFailed(addr1)
+	if len(tor.resumePeers) != 2 || tor.resumePeerFailures["1.1.1.1:1"] != 1 {
+		t.Fatalf("peer is removed after first failure: %v %v", tor.resumePeers, tor.resumePeerFailures)
+	}
+
+	// Failure count loaded from resume data is continued.
+	tor.resumePeerFailed(addr2)
+	if len(tor.resumePeers) != 1 || tor.resumePeers[0] != "1.1.1.1:1" {
+		t.Fatalf("peer is not removed after consecutive failures: %v", tor.resumePeers)
+	}
+	if _, ok := tor.resumePeerFailures["2.2.2.2:2"]; ok {
+		t.Fatal("failure count of removed peer is kept")
+	}
+}
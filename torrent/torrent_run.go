@@ -17,19 +17,40 @@ func (t *torrent) run() {
 	defer t.unchokeTicker.Stop()
 
 	for {
+		eventsC, event := t.nextEvent()
 		select {
 		case <-t.closeC:
 			t.close()
+			close(t.eventsC)
 			close(t.doneC)
 			return
+		case eventsC <- event:
+			t.handleEventSent()
 		case <-t.startCommandC:
 			t.start()
+		case d := <-t.startDelayedCommandC:
+			t.startAnnounceDelay = d
+			t.start()
 		case <-t.stopCommandC:
 			t.stop(nil)
 		case <-t.announceCommandC:
-			t.setNeedMorePeers(true)
+			t.handleAnnounceCommand()
+		case d := <-t.announceIntervalCommandC:
+			t.handleAnnounceIntervalCommand(d)
+		case <-t.pauseCommandC:
+			t.handlePause()
+		case <-t.resumeCommandC:
+			t.handleResume()
 		case <-t.verifyCommandC:
 			t.handleVerifyCommand()
+		case req := <-t.checkCommandC:
+			t.handleCheckCommand(req)
+		case req := <-t.checkCancelCommandC:
+			t.handleCheckCancelCommand(req)
+		case req := <-t.locationCommandC:
+			t.handleSetLocationCommand(req)
+		case req := <-t.locationCancelCommandC:
+			t.handleSetLocationCancelCommand(req)
 		case <-t.announcersStoppedC:
 			t.handleStopped()
 		case cmd := <-t.notifyErrorCommandC:
@@ -44,6 +65,26 @@ func (t *torrent) run() {
 			req.Response <- t.getPeers()
 		case req := <-t.webseedsCommandC:
 			req.Response <- t.getWebseeds()
+		case req := <-t.filesCommandC:
+			req.Response <- t.getFiles()
+		case req := <-t.connectionsCommandC:
+			req.Response <- t.recentConnections.List()
+		case req := <-t.swarmStatsCommandC:
+			req.Response <- t.getSwarmStats()
+		case req := <-t.pieceProgressCommandC:
+			req.Response <- t.getPieceProgress(req.Index)
+		case req := <-t.pieceMapCommandC:
+			req.Response <- t.getPieceMap()
+		case value := <-t.sequentialCommandC:
+			t.setSequential(value)
+		case value := <-t.superSeedingCommandC:
+			t.setSuperSeeding(value)
+		case req := <-t.filePriorityCommandC:
+			req.Response <- t.setFilePriority(req.Index, req.Priority)
+		case req := <-t.downloadPiecesCommandC:
+			req.Response <- t.downloadPieces(req.Indices)
+		case req := <-t.pieceDeadlineCommandC:
+			req.Response <- t.setPieceDeadline(req.Index, req.Deadline)
 		case p := <-t.allocatorProgressC:
 			t.bytesAllocated = p.AllocatedSize
 		case al := <-t.allocatorResultC:
@@ -51,31 +92,77 @@ func (t *torrent) run() {
 		case p := <-t.verifierProgressC:
 			t.checkedPieces = p.Checked
 		case ve := <-t.verifierResultC:
-			t.handleVerificationDone(ve)
+			if ve == t.checkVerifier {
+				t.handleCheckDone(ve)
+			} else {
+				t.handleVerificationDone(ve)
+			}
+		case p := <-t.moverProgressC:
+			t.bytesMoved = p.MovedSize
+		case mo := <-t.moverResultC:
+			t.handleMoveDone(mo)
 		case data := <-t.ramNotifyC:
 			t.startSinglePieceDownloader(data.(*peer.Peer))
 		case addrs := <-t.addrsFromTrackers:
-			t.handleNewPeers(addrs, peersource.Tracker)
+			t.handleNewPeers(addrs, nil, peersource.Tracker)
 		case addrs := <-t.addPeersCommandC:
-			t.handleNewPeers(addrs, peersource.Manual)
+			t.handleNewPeers(addrs, nil, peersource.Manual)
+		case addrs := <-t.hostPeersC:
+			t.handleHostPeer(addrs)
+		case ih := <-t.handedOverPeerC:
+			t.handleHandedOverPeer(ih)
 		case addrs := <-t.dhtPeersC:
-			t.handleNewPeers(addrs, peersource.DHT)
+			if t.dhtEnabled() {
+				t.handleNewPeers(addrs, nil, peersource.DHT)
+			}
+		case addrs := <-t.lsdPeersC:
+			if t.lsdEnabled() {
+				t.handleNewPeers(addrs, nil, peersource.LSD)
+			}
 		case trackers := <-t.addTrackersCommandC:
 			t.handleNewTrackers(trackers)
 		case conn := <-t.incomingConnC:
 			t.handleNewConnection(conn)
 		case res := <-t.webseedPieceResultC.ReceiveC():
 			t.handleWebseedPieceResult(res.(*urldownloader.PieceResult))
+		case <-t.errorRetryC:
+			t.handleErrorRetry()
+		case <-t.noPeersC:
+			t.handleNoPeers()
+		case <-t.trackerStarvedC:
+			t.handleTrackerStarvation()
+		case terr := <-t.trackerErrorC:
+			t.handleTrackerError(terr)
+		case <-t.pickerWarmupC:
+			t.handlePickerWarmupDone()
+		case <-t.metadataTimeoutC:
+			t.handleMetadataTimeout()
+		case <-t.dropPeerC:
+			t.dropSlowestPeer()
 		case src := <-t.webseedRetryC:
-			t.startPieceDownloaderForWebseed(src)
+			t.handleWebseedRetry(src)
+		case res := <-t.lazyVerifyResultC:
+			t.handleLazyVerifyDone(res)
 		case pw := <-t.pieceWriterResultC:
 			t.handlePieceWriteDone(pw)
+		case b := <-t.writeBatchResultC:
+			t.handleWriteBatchDone(b)
+		case <-t.writeBufferTimeoutC:
+			t.handleWriteBufferTimeout()
+		case b := <-t.writeBufferFlushedC:
+			t.handleWriteBufferFlushed(b)
 		case now := <-t.seedDurationTicker.C:
 			t.updateSeedDuration(now)
+			t.updateRates(now)
+			t.checkPieceDeadlines(now)
+			t.checkSeedLimits()
 		case pe := <-t.peerSnubbedC:
 			t.handlePeerSnubbed(pe)
 		case <-t.unchokeTicker.C:
-			t.unchoker.TickUnchoke(t.getPeersForUnchoker(), t.completed)
+			if !t.paused {
+				t.unchoker.TickUnchoke(t.getPeersForUnchoker(), t.completed)
+			}
+			t.adjustPeerDialLimit()
 		case ih := <-t.incomingHandshakerResultC:
 			t.handleIncomingHandshakeDone(ih)
 		case oh := <-t.outgoingHandshakerResultC:
@@ -0,0 +1,8 @@
+package torrent
+
+func (t *torrent) setSequential(value bool) {
+	t.sequential = value
+	if t.piecePicker != nil {
+		t.piecePicker.SetSequential(value, uint32(t.session.config.SequentialWindow))
+	}
+}
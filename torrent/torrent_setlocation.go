@@ -0,0 +1,174 @@
+package torrent
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+
+	"github.com/cenkalti/rain/internal/mover"
+	"github.com/cenkalti/rain/internal/storage/filestorage"
+)
+
+type setLocationRequest struct {
+	Path     string
+	Response chan error
+}
+
+func (t *torrent) SetLocation(ctx context.Context, path string) error {
+	req := setLocationRequest{Path: path, Response: make(chan error, 1)}
+	select {
+	case t.locationCommandC <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.closeC:
+		return errClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-ctx.Done():
+		select {
+		case t.locationCancelCommandC <- req:
+		case <-t.closeC:
+		}
+		return ctx.Err()
+	case <-t.closeC:
+		return errClosed
+	}
+}
+
+func (t *torrent) handleSetLocationCommand(req setLocationRequest) {
+	if t.info == nil {
+		req.Response <- errors.New("torrent metadata is not downloaded yet")
+		return
+	}
+	if t.mover != nil {
+		req.Response <- errors.New("files of torrent are already being moved")
+		return
+	}
+	if t.checkVerifier != nil {
+		req.Response <- errors.New("files of torrent are being checked")
+		return
+	}
+	if t.writeBufferFlushing {
+		req.Response <- errors.New("downloaded pieces are being written to files")
+		return
+	}
+	fs, ok := t.storage.(*filestorage.FileStorage)
+	if !ok {
+		req.Response <- errors.New("storage of torrent does not support moving files")
+		return
+	}
+	dest, err := filepath.Abs(req.Path)
+	if err != nil {
+		req.Response <- err
+		return
+	}
+	src := fs.RootDir()
+	if dest == src {
+		req.Response <- nil
+		return
+	}
+	var total int64
+	files := make([]mover.File, 0, len(t.info.Files))
+	for _, f := range t.info.Files {
+		// Symlinks are created again by the allocator in the new location.
+		if f.Padding || f.Symlink != "" {
+			continue
+		}
+		files = append(files, mover.File{Path: f.Path, Length: f.Length})
+		total += f.Length
+	}
+	// Files must be closed before moving them.
+	if s := t.status(); s != Stopped && s != Stopping && s != SeedingComplete {
+		t.stop(nil)
+		t.moveRestart = true
+	}
+	t.log.Infof("moving files from %q to %q", src, dest)
+	t.mover = mover.New()
+	t.moveDest = dest
+	t.moveResponseC = req.Response
+	t.bytesMoved = 0
+	t.bytesToMove = total
+	go t.mover.Run(src, dest, files, t.moverProgressC, t.moverResultC)
+}
+
+func (t *torrent) handleMoveDone(mo *mover.Mover) {
+	if t.mover != mo {
+		panic("invalid mover")
+	}
+	respC := t.moveResponseC
+	dest := t.moveDest
+	t.mover = nil
+	t.moveResponseC = nil
+	t.moveDest = ""
+	t.bytesMoved = 0
+	t.bytesToMove = 0
+
+	err := mo.Error
+	if err == nil {
+		err = t.setStorage(dest)
+	}
+	if err != nil {
+		// Files are left in the old location.
+		t.log.Errorln("cannot move files:", err)
+	} else {
+		t.log.Info("moving files done")
+	}
+	respC <- err
+	t.restartAfterMove()
+}
+
+// setStorage saves the new location of files and switches the storage of the torrent to it.
+func (t *torrent) setStorage(dest string) error {
+	sto, err := t.session.newFileStorage(dest)
+	if err != nil {
+		return err
+	}
+	err = t.session.resumer.WriteLocation(t.id, dest)
+	if err != nil {
+		return err
+	}
+	t.storage = sto
+	t.location = dest
+	return nil
+}
+
+// restartAfterMove starts the torrent again if it is stopped for moving files.
+// If the torrent is still announcing the stopped event, it is started after the announce is done.
+func (t *torrent) restartAfterMove() {
+	if !t.moveRestart || t.mover != nil || t.status() == Stopping {
+		return
+	}
+	t.moveRestart = false
+	t.start()
+}
+
+func (t *torrent) handleSetLocationCancelCommand(req setLocationRequest) {
+	if t.moveResponseC != req.Response {
+		// Move is already finished.
+		return
+	}
+	t.stopMove(context.Canceled)
+}
+
+// stopMove cancels the running move and sends err to the caller. Files that are moved so far are put back.
+func (t *torrent) stopMove(err error) {
+	mo := t.mover
+	if mo == nil {
+		return
+	}
+	mo.Close()
+	if mo.Error == nil {
+		// Files are moved before the result is received.
+		t.handleMoveDone(mo)
+		return
+	}
+	t.moveResponseC <- err
+	t.mover = nil
+	t.moveResponseC = nil
+	t.moveDest = ""
+	t.bytesMoved = 0
+	t.bytesToMove = 0
+	t.restartAfterMove()
+}
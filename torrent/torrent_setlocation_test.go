@@ -0,0 +1,112 @@
+package torrent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
+	"github.com/fortytw2/leaktest"
+	"go.etcd.io/bbolt"
+)
+
+func TestSetLocation(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	src := filepath.Join(s.config.DataDir, tor.ID(), torrentName)
+	err := os.Mkdir(filepath.Join(s.config.DataDir, tor.ID()), os.ModeDir|0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = CopyDir(filepath.Join(torrentDataDir, torrentName), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor.torrent.trackers = nil
+	err = tor.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, tor, Seeding)
+
+	where, clean := tempdir(t)
+	defer clean()
+	err = tor.SetLocation(context.Background(), where)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(where, torrentName, "README")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(src, "README")); !os.IsNotExist(err) {
+		t.Fatalf("file is not removed from old location: %v", err)
+	}
+	waitStatus(t, tor, Seeding)
+	stats := tor.Stats()
+	if stats.Pieces.Have != stats.Pieces.Total {
+		t.Fatalf("pieces are lost after moving, have %d of %d", stats.Pieces.Have, stats.Pieces.Total)
+	}
+	if stats.Move.Active {
+		t.Fatal("move is still active")
+	}
+	spec, err := s.resumer.Read(tor.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Location != where {
+		t.Fatalf("location is not saved: %q", spec.Location)
+	}
+
+	// Compacted database must keep the location. A magnet without info is compacted too.
+	_, err = s.AddURI(torrentMagnetLink, &AddTorrentOptions{Stopped: true, DisablePEX: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(where, "compact.db")
+	err = s.CompactDatabase(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := bbolt.Open(output, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	res, err := boltdbresumer.New(db, torrentsBucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err = res.Read(tor.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Location != where {
+		t.Fatalf("location is lost after compaction: %q", spec.Location)
+	}
+	if len(spec.Bitfield) == 0 {
+		t.Fatal("bitfield is lost after compaction")
+	}
+	ids, err := res.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 {
+		t.Fatalf("compacted database has %d torrents", len(ids))
+	}
+	for _, id := range ids {
+		if id == tor.ID() {
+			continue
+		}
+		spec, err = res.Read(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(spec.Info) != 0 || !spec.DisablePEX {
+			t.Fatal("magnet is not compacted correctly")
+		}
+	}
+}
@@ -2,11 +2,13 @@ package torrent
 
 import (
 	"net"
+	"time"
 
 	"github.com/cenkalti/rain/internal/acceptor"
 	"github.com/cenkalti/rain/internal/allocator"
 	"github.com/cenkalti/rain/internal/announcer"
 	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/piece"
 	"github.com/cenkalti/rain/internal/piecedownloader"
 	"github.com/cenkalti/rain/internal/piecepicker"
 	"github.com/cenkalti/rain/internal/tracker"
@@ -19,6 +21,16 @@ import (
 func (t *torrent) start() {
 	// Do not start if already started.
 	if t.errC != nil {
+		// Do not wait for the retry interval if the torrent is started by the user.
+		if t.status() == Retrying {
+			t.handleErrorRetry()
+		}
+		return
+	}
+
+	// Files cannot be opened while they are being moved. Start after the move is done.
+	if t.mover != nil {
+		t.moveRestart = true
 		return
 	}
 
@@ -28,17 +40,30 @@ func (t *torrent) start() {
 		t.stoppedEventAnnouncer = nil
 	}
 
+	// Files are opened by the allocator and may be modified while they are being checked.
+	t.stopCheck(errCheckInterrupted)
+
 	t.log.Info("starting torrent")
+	if t.seedLimitReached {
+		t.seedLimitReached = false
+		err := t.session.resumer.WriteSeedLimitReached(t.id, false)
+		if err != nil {
+			t.log.Errorln("cannot write seed limit status:", err)
+		}
+	}
 	t.errC = make(chan error, 1)
 	t.portC = make(chan int, 1)
 	t.lastError = nil
-	t.downloadSpeed = metrics.NewMeter()
-	t.uploadSpeed = metrics.NewMeter()
+	t.downloadRate.reset()
+	t.uploadRate.reset()
+	t.announceStartAt = time.Now().Add(t.startAnnounceDelay)
+	t.startAnnounceDelay = 0
 
 	if t.info != nil {
 		if t.pieces != nil {
 			if t.bitfield != nil {
 				t.addFixedPeers()
+				t.addResumePeers()
 				t.startAcceptor()
 				t.startAnnouncers()
 				t.startPieceDownloaders()
@@ -50,10 +75,13 @@ func (t *torrent) start() {
 		}
 	} else {
 		t.addFixedPeers()
+		t.addResumePeers()
 		t.startAcceptor()
 		t.startAnnouncers()
 		t.startInfoDownloaders()
+		t.startMetadataTimer()
 	}
+	t.checkMinConnectedPeers()
 }
 
 func (t *torrent) startVerifier() {
@@ -63,8 +91,17 @@ func (t *torrent) startVerifier() {
 	if len(t.pieces) == 0 {
 		panic("zero length pieces")
 	}
-	t.verifier = verifier.New()
-	go t.verifier.Run(t.pieces, t.verifierProgressC, t.verifierResultC)
+	var resume *verifier.Resume
+	if t.bitfield != nil && t.fileStats != nil {
+		resume = &verifier.Resume{
+			Bitfield:  t.bitfield,
+			FileStats: t.fileStats,
+			Storage:   t.storage,
+			Files:     t.info.Files,
+		}
+	}
+	t.verifier = verifier.New(t.session.config.VerifierConcurrency)
+	go t.verifier.Run(t.pieces, t.session.config.DisableVerification, resume, t.session.semVerify, t.verifierProgressC, t.verifierResultC)
 }
 
 func (t *torrent) startAllocator() {
@@ -72,7 +109,7 @@ func (t *torrent) startAllocator() {
 		panic("allocator exists")
 	}
 	t.allocator = allocator.New()
-	go t.allocator.Run(t.info, t.storage, t.allocatorProgressC, t.allocatorResultC)
+	go t.allocator.Run(t.info, t.storage, t.skippedFiles(), t.allocatorProgressC, t.allocatorResultC)
 }
 
 func (t *torrent) addFixedPeers() {
@@ -87,9 +124,13 @@ func (t *torrent) startAnnouncers() {
 			t.startNewAnnouncer(tr)
 		}
 	}
-	if t.dhtAnnouncer == nil && t.session.config.DHTEnabled && (t.info == nil || !t.info.Private) {
+	if t.dhtAnnouncer == nil && t.dhtEnabled() {
 		t.dhtAnnouncer = announcer.NewDHTAnnouncer()
-		go t.dhtAnnouncer.Run(t.announceDHT, t.session.config.DHTAnnounceInterval, t.session.config.DHTMinAnnounceInterval, t.log)
+		go t.dhtAnnouncer.Run(t.announceDHT, t.session.config.DHTAnnounceInterval, t.session.config.DHTMinAnnounceInterval, t.announceResultFunc(AnnounceSourceDHT), t.log)
+	}
+	if t.lsdAnnouncer == nil && t.lsdEnabled() {
+		t.lsdAnnouncer = announcer.NewDHTAnnouncer()
+		go t.lsdAnnouncer.Run(t.announceLSD, t.session.config.LSDAnnounceInterval, t.session.config.LSDMinAnnounceInterval, t.announceResultFunc(AnnounceSourceLSD), t.log)
 	}
 }
 
@@ -98,11 +139,18 @@ func (t *torrent) startNewAnnouncer(tr tracker.Tracker) {
 		tr,
 		t.session.config.TrackerNumWant,
 		t.session.config.TrackerMinAnnounceInterval,
+		time.Until(t.announceStartAt),
+		t.session.config.TrackerAnnounceJitter,
+		t.session.config.TrackerStarvationRatio,
 		t.announcerFields,
 		t.completeC,
 		t.addrsFromTrackers,
+		t.trackerStarvedC,
+		t.trackerErrorC,
+		t.announceResultFunc(AnnounceSourceTracker),
 		t.log,
 	)
+	an.SetIntervalOverride(t.announceInterval)
 	t.announcers = append(t.announcers, an)
 	go an.Run()
 }
@@ -118,11 +166,30 @@ func (t *torrent) startAcceptor() {
 		t.log.Info("Listening peers on tcp://" + listener.Addr().String())
 		t.port = listener.Addr().(*net.TCPAddr).Port
 		t.portC <- t.port
+		if t.session.portMapper != nil {
+			t.session.portMapper.Add(t.port)
+		}
 		t.acceptor = acceptor.New(listener, t.incomingConnC, t.log)
 		go t.acceptor.Run()
+		if t.session.config.ListenIPv6 {
+			t.startAcceptor6()
+		}
 	}
 }
 
+// startAcceptor6 listens the same port with the IPv4 listener on IPv6.
+// Both acceptors send incoming connections to the same channel.
+func (t *torrent) startAcceptor6() {
+	listener, err := net.ListenTCP("tcp6", &net.TCPAddr{Port: t.port})
+	if err != nil {
+		t.log.Warningf("cannot listen port %d on IPv6: %s", t.port, err)
+		return
+	}
+	t.log.Info("Listening peers on tcp://" + listener.Addr().String())
+	t.acceptor6 = acceptor.New(listener, t.incomingConnC, t.log)
+	go t.acceptor6.Run()
+}
+
 func (t *torrent) startInfoDownloaders() {
 	if t.info != nil {
 		return
@@ -159,6 +226,9 @@ func (t *torrent) startPieceDownloaders() {
 }
 
 func (t *torrent) startPieceDownloaderForWebseed(src *webseedsource.WebseedSource) (started bool) {
+	if !t.webseedsEnabled() {
+		return false
+	}
 	if t.webseedActiveDownloads >= t.session.config.WebseedMaxDownloads {
 		return false
 	}
@@ -176,7 +246,12 @@ func (t *torrent) startPieceDownloaderForWebseed(src *webseedsource.WebseedSourc
 
 func (t *torrent) startWebseedDownloader(sp *piecepicker.WebseedDownloadSpec) {
 	t.log.Debugf("downloading pieces %d-%d from webseed %s", sp.Begin, sp.End, sp.Source.URL)
-	ud := urldownloader.New(sp.Source.URL, sp.Begin, sp.End)
+	var ud *urldownloader.URLDownloader
+	if sp.Source.HTTPSeed {
+		ud = urldownloader.NewHTTPSeed(sp.Source.URL, t.infoHash, sp.Begin, sp.End)
+	} else {
+		ud = urldownloader.New(sp.Source.URL, sp.Begin, sp.End)
+	}
 	for _, src := range t.webseedSources {
 		if src != sp.Source {
 			continue
@@ -190,13 +265,16 @@ func (t *torrent) startWebseedDownloader(sp *piecepicker.WebseedDownloadSpec) {
 		src.DownloadSpeed = metrics.NewMeter()
 		break
 	}
-	go ud.Run(t.webseedClient, t.pieces, len(t.info.Files) > 1, t.webseedPieceResultC.SendC(), t.piecePool, t.session.config.WebseedResponseBodyReadTimeout)
+	go ud.Run(t.webseedClient, t.pieces, len(t.info.Files) > 1, t.webseedPieceResultC.SendC(), t.piecePool, t.session.config.WebseedResponseBodyReadTimeout, t.limitDownload)
 }
 
 func (t *torrent) startPieceDownloaderFor(pe *peer.Peer) {
 	if t.status() != Downloading {
 		return
 	}
+	if t.pickerWarmingUp() {
+		return
+	}
 	if t.session.ram == nil {
 		t.startSinglePieceDownloader(pe)
 		return
@@ -225,6 +303,7 @@ func (t *torrent) startSinglePieceDownloader(pe *peer.Peer) {
 	if _, ok := t.pieceDownloaders[pe]; ok {
 		panic("peer already has a piece downloader")
 	}
+	t.resumePartialPiece(pd)
 	t.log.Debugf("requesting piece #%d from peer %s", pi.Index, pe.IP())
 	t.pieceDownloaders[pe] = pd
 	pe.Downloading = true
@@ -234,12 +313,37 @@ func (t *torrent) startSinglePieceDownloader(pe *peer.Peer) {
 }
 
 func (t *torrent) maxAllowedRequests(pe *peer.Peer) int {
+	var rreq int
+	if pe.ExtensionHandshake != nil {
+		rreq = pe.ExtensionHandshake.RequestQueue
+	}
 	ret := t.session.config.DefaultRequestsOut
-	if pe.ExtensionHandshake != nil && pe.ExtensionHandshake.RequestQueue > 0 {
-		ret = pe.ExtensionHandshake.RequestQueue
+	if r, ok := t.peerDownloadRates[pe]; ok && r.initialized && t.session.config.AdaptiveRequestsOut {
+		ret = requestsForQueueTime(r.Rate(), t.session.config.AdaptiveRequestsQueueTime, t.session.config.AdaptiveRequestsOutMin, t.session.config.AdaptiveRequestsOutMax)
+		// Peer may drop requests exceeding the queue length it has advertised.
+		if rreq > 0 && ret > rreq {
+			ret = rreq
+		}
+	} else if rreq > 0 {
+		ret = rreq
 	}
 	if ret > t.session.config.MaxRequestsOut {
 		ret = t.session.config.MaxRequestsOut
 	}
 	return ret
 }
+
+// requestsForQueueTime returns the number of blocks that are downloaded in queueTime
+// at the given download rate in bytes per second, clamped between min and max.
+// Latency of the requests is not used because it includes the time requests wait in the queue of the peer,
+// which grows with the number of requests.
+func requestsForQueueTime(rate int, queueTime time.Duration, min, max int) int {
+	n := int(float64(rate) * queueTime.Seconds() / piece.BlockSize)
+	if n < min {
+		n = min
+	}
+	if n > max {
+		n = max
+	}
+	return n
+}
@@ -0,0 +1,86 @@
+package torrent
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/fortytw2/leaktest"
+)
+
+func TestRequestsForQueueTime(t *testing.T) {
+	if n := requestsForQueueTime(10*piece.BlockSize, 2*time.Second, 4, 250); n != 20 {
+		t.Fatalf("queue must hold the blocks downloaded in queue time, got %d", n)
+	}
+	if n := requestsForQueueTime(piece.BlockSize, 100*time.Millisecond, 4, 250); n != 4 {
+		t.Fatalf("queue must not be less than min, got %d", n)
+	}
+	if n := requestsForQueueTime(100<<20, time.Second, 4, 250); n != 250 {
+		t.Fatalf("queue must not exceed max, got %d", n)
+	}
+}
+
+func TestAdaptiveRequestsGrow(t *testing.T) {
+	defer leaktest.Check(t)()
+	s1, closeSession1 := newTestSession(t)
+	s1.SetSpeedLimitUpload(2 << 20)
+	addr, cl := startSeeder(t, s1, closeSession1)
+	defer cl()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	s.config.AdaptiveRequestsOut = true
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	tor.torrent.trackers = nil
+	tor.Start()
+	if err := tor.AddPeer(addr); err != nil {
+		t.Fatal(err)
+	}
+
+	// Queue must grow beyond the default after the rate of the fast peer is measured.
+	deadline := time.After(timeout)
+	for {
+		for _, p := range tor.Peers() {
+			if p.MaxRequestsOut > s.config.DefaultRequestsOut {
+				return
+			}
+		}
+		select {
+		case <-tor.NotifyComplete():
+			t.Fatal("download is completed before the request queue grows")
+		case <-deadline:
+			t.Fatal("request queue did not grow")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func TestListenIPv6(t *testing.T) {
+	defer leaktest.Check(t)()
+	l, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 is not available:", err)
+	}
+	l.Close()
+
+	s1, closeSession1 := newTestSession(t)
+	s1.config.ListenIPv6 = true
+	addr, cl := startSeeder(t, s1, closeSession1)
+	defer cl()
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	s.config.ListenIPv6 = true
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	tor.torrent.trackers = nil
+	tor.Start()
+	if err = tor.AddPeer(net.JoinHostPort("::1", port)); err != nil {
+		t.Fatal(err)
+	}
+	assertCompleted(t, tor)
+}
@@ -1,8 +1,11 @@
 package torrent
 
 import (
+	"sort"
 	"time"
 
+	"github.com/cenkalti/rain/internal/announcer"
+	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/mse"
 	"github.com/cenkalti/rain/internal/peersource"
 	"github.com/cenkalti/rain/internal/stringutil"
@@ -31,6 +34,11 @@ type Stats struct {
 		Available uint32
 		// Number of total pieces in torrent.
 		Total uint32
+		// Number of complete copies of the torrent in connected swarm, including our own pieces.
+		// Integer part is the availability of the rarest piece.
+		// Fractional part is the ratio of pieces that have more copies than the rarest piece.
+		// A value less than 1 means that no complete copy exists and the download may never finish.
+		Availability float64
 	}
 	Bytes struct {
 		// Bytes that are downloaded and passed hash check.
@@ -56,6 +64,22 @@ type Stats struct {
 		Incoming int
 		// Number of peers that we have connected to.
 		Outgoing int
+		// Number of connected peers that have all pieces.
+		Seeds int
+		// Number of connected peers that have missing pieces.
+		Leechers int
+		// Number of peers connected over IPv4 and IPv6.
+		IPv4 int
+		IPv6 int
+		// Max number of outgoing connections.
+		// Changes over time if Config.AdaptivePeerLimit is enabled.
+		DialTarget int
+		// True while the number of connected peers is below Config.MinConnectedPeers and more peers are being searched.
+		Seeking bool
+		// Number of peers that we are choking.
+		Choked int
+		// Number of peers that we are uploading to.
+		Unchoked int
 	}
 	Handshakes struct {
 		// Number of peers that are not handshaked yet.
@@ -74,6 +98,23 @@ type Stats struct {
 		DHT int
 		// Peers found via peer exchange.
 		PEX int
+		// Peers found on the local network.
+		LSD int
+		// Peers loaded from resume data.
+		Resume int
+		// Number of productive peer addresses that are saved in resume data.
+		Saved int
+	}
+	// Size of the swarm reported in announce responses of trackers.
+	Swarm struct {
+		// Largest numbers of seeders and leechers reported by trackers.
+		// Trackers with the same URL are counted once with their latest response.
+		Seeders  int
+		Leechers int
+		// Number of distinct trackers that have responded.
+		Trackers int
+		// Time of the latest response. Zero if no tracker has responded yet.
+		LastUpdate time.Time
 	}
 	Downloads struct {
 		// Number of active piece downloads.
@@ -101,15 +142,29 @@ type Stats struct {
 	PieceLength uint32
 	// Duration while the torrent is in Seeding status.
 	SeededFor time.Duration
-	// Speed is calculated as 1-minute moving average.
+	// Ratio of uploaded bytes to downloaded bytes, including the bytes from previous runs.
+	// Zero if nothing is downloaded.
+	SeedRatio float64
+	// Speed is the exponentially weighted moving average of the bytes transferred in every second.
+	// Changes in speed are reflected gradually, in about 20 seconds.
 	Speed struct {
 		// Downloaded bytes per second.
 		Download int
 		// Uploaded bytes per second.
 		Upload int
 	}
-	// Time remaining to complete download. nil value means infinity.
+	// Time remaining to complete download, calculated from Speed.Download.
+	// nil value means unknown, when nothing is being downloaded.
 	ETA *time.Duration
+	// Progress of moving files with Torrent.SetLocation.
+	Move struct {
+		// True while files are being moved.
+		Active bool
+		// Number of bytes moved or copied so far.
+		Moved int64
+		// Number of total bytes to be moved.
+		Total int64
+	}
 }
 
 func (t *torrent) stats() Stats {
@@ -120,16 +175,48 @@ func (t *torrent) stats() Stats {
 	s.Port = t.port
 	s.Status = t.status()
 	s.Error = t.lastError
+	if t.graceError != nil {
+		s.Error = t.graceError
+	}
 	s.Addresses.Total = t.addrList.Len()
 	s.Addresses.Tracker = t.addrList.LenSource(peersource.Tracker)
 	s.Addresses.DHT = t.addrList.LenSource(peersource.DHT)
 	s.Addresses.PEX = t.addrList.LenSource(peersource.PEX)
+	s.Addresses.LSD = t.addrList.LenSource(peersource.LSD)
+	s.Addresses.Resume = t.addrList.LenSource(peersource.Resume)
+	s.Addresses.Saved = len(t.resumePeers)
+	swarm := t.trackerSwarm()
+	s.Swarm.Seeders = swarm.seeders
+	s.Swarm.Leechers = swarm.leechers
+	s.Swarm.Trackers = swarm.trackers
+	s.Swarm.LastUpdate = swarm.lastUpdate
 	s.Handshakes.Incoming = len(t.incomingHandshakers)
 	s.Handshakes.Outgoing = len(t.outgoingHandshakers)
 	s.Handshakes.Total = len(t.incomingHandshakers) + len(t.outgoingHandshakers)
 	s.Peers.Total = len(t.peers)
 	s.Peers.Incoming = len(t.incomingPeers)
 	s.Peers.Outgoing = len(t.outgoingPeers)
+	for pe := range t.peers {
+		if pe.Bitfield != nil && pe.Bitfield.All() {
+			s.Peers.Seeds++
+		} else {
+			s.Peers.Leechers++
+		}
+		if pe.Conn.Addr().IP.To4() != nil {
+			s.Peers.IPv4++
+		} else {
+			s.Peers.IPv6++
+		}
+	}
+	s.Peers.DialTarget = t.dialLimit()
+	s.Peers.Seeking = t.seekingPeers
+	for pe := range t.peers {
+		if pe.ClientChoking {
+			s.Peers.Choked++
+		} else {
+			s.Peers.Unchoked++
+		}
+	}
 	s.MetadataDownloads.Total = len(t.infoDownloaders)
 	s.MetadataDownloads.Snubbed = len(t.infoDownloadersSnubbed)
 	s.MetadataDownloads.Running = len(t.infoDownloaders) - len(t.infoDownloadersSnubbed)
@@ -138,14 +225,19 @@ func (t *torrent) stats() Stats {
 	s.Downloads.Choked = len(t.pieceDownloadersChoked)
 	s.Downloads.Running = len(t.pieceDownloaders) - len(t.pieceDownloadersChoked) - len(t.pieceDownloadersSnubbed)
 	s.Pieces.Available = t.avaliablePieceCount()
+	s.Pieces.Availability = t.availability()
 	s.Bytes.Downloaded = t.bytesDownloaded.Count()
 	s.Bytes.Uploaded = t.bytesUploaded.Count()
 	s.Bytes.Wasted = t.bytesWasted.Count()
 	s.SeededFor = time.Duration(t.seededFor.Count())
+	s.SeedRatio = t.seedRatio()
 	s.Bytes.Allocated = t.bytesAllocated
 	s.Pieces.Checked = t.checkedPieces
-	s.Speed.Download = int(t.downloadSpeed.Rate1())
-	s.Speed.Upload = int(t.uploadSpeed.Rate1())
+	s.Move.Active = t.mover != nil
+	s.Move.Moved = t.bytesMoved
+	s.Move.Total = t.bytesToMove
+	s.Speed.Download = t.downloadRate.Rate()
+	s.Speed.Upload = t.uploadRate.Rate()
 
 	if t.info != nil {
 		s.Bytes.Total = t.info.Length
@@ -165,29 +257,7 @@ func (t *torrent) stats() Stats {
 		s.Pieces.Missing = s.Pieces.Total - s.Pieces.Have
 	}
 	if s.Status == Downloading {
-		bps := int64(s.Speed.Download)
-		if bps != 0 {
-			eta := time.Duration(s.Bytes.Incomplete/bps) * time.Second
-			switch {
-			case eta > 8*time.Hour:
-				eta = eta.Round(time.Hour)
-			case eta > 4*time.Hour:
-				eta = eta.Round(30 * time.Minute)
-			case eta > 2*time.Hour:
-				eta = eta.Round(15 * time.Minute)
-			case eta > time.Hour:
-				eta = eta.Round(5 * time.Minute)
-			case eta > 30*time.Minute:
-				eta = eta.Round(1 * time.Minute)
-			case eta > 15*time.Minute:
-				eta = eta.Round(30 * time.Second)
-			case eta > 5*time.Minute:
-				eta = eta.Round(15 * time.Second)
-			case eta > time.Minute:
-				eta = eta.Round(5 * time.Second)
-			}
-			s.ETA = &eta
-		}
+		s.ETA = eta(s.Bytes.Incomplete, s.Speed.Download)
 	}
 	return s
 }
@@ -199,6 +269,50 @@ func (t *torrent) avaliablePieceCount() uint32 {
 	return t.piecePicker.Available()
 }
 
+func (t *torrent) availability() float64 {
+	if t.info == nil {
+		return 0
+	}
+	bitfields := make([]*bitfield.Bitfield, 0, len(t.peers)+1)
+	if t.bitfield != nil {
+		bitfields = append(bitfields, t.bitfield)
+	}
+	for pe := range t.peers {
+		if pe.Bitfield != nil {
+			bitfields = append(bitfields, pe.Bitfield)
+		}
+	}
+	return distributedCopies(t.info.NumPieces, bitfields)
+}
+
+// distributedCopies returns the number of complete copies that can be assembled from given bitfields.
+func distributedCopies(numPieces uint32, bitfields []*bitfield.Bitfield) float64 {
+	if numPieces == 0 {
+		return 0
+	}
+	counts := make([]int, numPieces)
+	for _, bf := range bitfields {
+		for i := range counts {
+			if bf.Test(uint32(i)) {
+				counts[i]++
+			}
+		}
+	}
+	min := counts[0]
+	for _, n := range counts {
+		if n < min {
+			min = n
+		}
+	}
+	var aboveMin int
+	for _, n := range counts {
+		if n > min {
+			aboveMin++
+		}
+	}
+	return float64(min) + float64(aboveMin)/float64(numPieces)
+}
+
 func (t *torrent) bytesComplete() int64 {
 	if t.bitfield == nil || len(t.pieces) == 0 {
 		return 0
@@ -222,7 +336,10 @@ func (t *torrent) getTrackers() []Tracker {
 			Leechers:     st.Leechers,
 			Warning:      st.Warning,
 			LastAnnounce: st.LastAnnounce,
+			LastSuccess:  st.LastSuccess,
 			NextAnnounce: st.NextAnnounce,
+			Successes:    st.Successes,
+			Failures:     st.Failures,
 		}
 		if st.Error != nil {
 			trackers[i].Error = &AnnounceError{st.Error}
@@ -231,6 +348,67 @@ func (t *torrent) getTrackers() []Tracker {
 	return trackers
 }
 
+type trackerSwarm struct {
+	seeders    int
+	leechers   int
+	trackers   int
+	lastUpdate time.Time
+}
+
+// trackerSwarm returns the size of the swarm from the latest announce responses of trackers.
+func (t *torrent) trackerSwarm() trackerSwarm {
+	// Keep the latest response for each tracker URL.
+	latest := make(map[string]announcer.Stats)
+	for _, an := range t.announcers {
+		st := an.Stats()
+		if st.LastSuccess.IsZero() {
+			continue
+		}
+		u := an.Tracker.URL()
+		if prev, ok := latest[u]; ok && prev.LastSuccess.After(st.LastSuccess) {
+			continue
+		}
+		latest[u] = st
+	}
+	var s trackerSwarm
+	s.trackers = len(latest)
+	for _, st := range latest {
+		if st.Seeders > s.seeders {
+			s.seeders = st.Seeders
+		}
+		if st.Leechers > s.leechers {
+			s.leechers = st.Leechers
+		}
+		if st.LastSuccess.After(s.lastUpdate) {
+			s.lastUpdate = st.LastSuccess
+		}
+	}
+	return s
+}
+
+func (t *torrent) getSwarmStats() SwarmStats {
+	var s SwarmStats
+	swarm := t.trackerSwarm()
+	s.TrackerSeeders = swarm.seeders
+	s.TrackerLeechers = swarm.leechers
+	for pe := range t.peers {
+		if pe.Bitfield != nil && pe.Bitfield.All() {
+			s.ConnectedSeeders++
+		} else {
+			s.ConnectedLeechers++
+		}
+	}
+	s.Seeders = s.TrackerSeeders
+	if s.ConnectedSeeders > s.Seeders {
+		s.Seeders = s.ConnectedSeeders
+	}
+	s.Leechers = s.TrackerLeechers
+	if s.ConnectedLeechers > s.Leechers {
+		s.Leechers = s.ConnectedLeechers
+	}
+	return s
+}
+
 func (t *torrent) getPeers() []Peer {
 	peers := make([]Peer, 0, len(t.peers))
 	for pe := range t.peers {
@@ -246,6 +424,10 @@ func (t *torrent) getPeers() []Peer {
 			source = SourceIncoming
 		case peersource.Manual:
 			source = SourceManual
+		case peersource.Resume:
+			source = SourceResume
+		case peersource.LSD:
+			source = SourceLSD
 		default:
 			panic("unhandled peer source")
 		}
@@ -266,6 +448,15 @@ func (t *torrent) getPeers() []Peer {
 			Source:             source,
 			DownloadSpeed:      pe.DownloadSpeed(),
 			UploadSpeed:        pe.UploadSpeed(),
+			MaxRequestsOut:     t.maxAllowedRequests(pe),
+			RequestLatency:     pe.RequestLatency,
+			ReciprocationRatio: pe.ReciprocationRatio(),
+		}
+		if pd, ok := t.pieceDownloaders[pe]; ok {
+			p.RequestsOut = pd.Pending()
+		}
+		if pe.ExtensionHandshake != nil {
+			p.PeerRequestQueue = pe.ExtensionHandshake.RequestQueue
 		}
 		peers = append(peers, p)
 	}
@@ -277,6 +468,7 @@ func (t *torrent) getWebseeds() []Webseed {
 	for _, src := range t.webseedSources {
 		ws := Webseed{
 			URL:           src.URL,
+			Trusted:       src.Trusted,
 			Error:         src.LastError,
 			DownloadSpeed: int(src.DownloadSpeed.Rate1()),
 		}
@@ -285,6 +477,38 @@ func (t *torrent) getWebseeds() []Webseed {
 	return webseeds
 }
 
+// seedRatio returns the ratio of uploaded bytes to downloaded bytes.
+func (t *torrent) seedRatio() float64 {
+	downloaded := t.bytesDownloaded.Count()
+	if downloaded == 0 {
+		return 0
+	}
+	return float64(t.bytesUploaded.Count()) / float64(downloaded)
+}
+
+// checkSeedLimits stops the torrent if Config.SeedRatioLimit or Config.SeedTimeLimit is reached while seeding.
+func (t *torrent) checkSeedLimits() {
+	if t.status() != Seeding {
+		return
+	}
+	cfg := t.session.config
+	switch {
+	case cfg.SeedRatioLimit > 0 && t.seedRatio() >= cfg.SeedRatioLimit:
+		t.log.Infof("seed ratio limit (%.2f) is reached", cfg.SeedRatioLimit)
+	case cfg.SeedTimeLimit > 0 && time.Duration(t.seededFor.Count()) >= cfg.SeedTimeLimit:
+		t.log.Infof("seed time limit (%s) is reached", cfg.SeedTimeLimit)
+	default:
+		return
+	}
+	t.stop(nil)
+	t.seedLimitReached = true
+	t.sendEvent(Event{Type: EventSeedLimitReached})
+	err := t.session.resumer.WriteSeedLimitReached(t.id, true)
+	if err != nil {
+		t.log.Errorln("cannot write seed limit status:", err)
+	}
+}
+
 func (t *torrent) updateSeedDuration(now time.Time) {
 	if t.status() != Seeding {
 		t.seedDurationUpdatedAt = time.Time{}
@@ -297,3 +521,62 @@ func (t *torrent) updateSeedDuration(now time.Time) {
 	t.seededFor.Inc(int64(now.Sub(t.seedDurationUpdatedAt)))
 	t.seedDurationUpdatedAt = now
 }
+
+// getPieceProgress returns the number of received blocks of a piece that is being downloaded from peers.
+// If the piece is downloaded from multiple peers in endgame mode, the most advanced download is returned.
+func (t *torrent) getPieceProgress(index uint32) pieceProgress {
+	var p pieceProgress
+	for _, pd := range t.pieceDownloaders {
+		if pd.Piece.Index != index {
+			continue
+		}
+		done, total := pd.Progress()
+		if done >= p.Done {
+			p = pieceProgress{Done: done, Total: total}
+		}
+	}
+	return p
+}
+
+func (t *torrent) getPieceMap() PieceMap {
+	var pm PieceMap
+	if t.info != nil {
+		pm.NumPieces = t.info.NumPieces
+	}
+	if t.bitfield != nil {
+		pm.Bitfield = t.bitfield.Copy().Bytes()
+	}
+	downloading := make(map[uint32]*DownloadingPiece)
+	get := func(index uint32) *DownloadingPiece {
+		dp, ok := downloading[index]
+		if !ok {
+			dp = &DownloadingPiece{Index: index, BlocksTotal: t.pieces[index].NumBlocks()}
+			downloading[index] = dp
+		}
+		return dp
+	}
+	for pe, pd := range t.pieceDownloaders {
+		dp := get(pd.Piece.Index)
+		dp.Peers = append(dp.Peers, pe.Addr())
+		if done, _ := pd.Progress(); done > dp.BlocksDone {
+			dp.BlocksDone = done
+		}
+	}
+	for _, src := range t.webseedSources {
+		if !src.Downloading() {
+			continue
+		}
+		index := src.Downloader.ReadCurrent()
+		if index >= uint32(len(t.pieces)) {
+			continue
+		}
+		dp := get(index)
+		dp.Webseeds = append(dp.Webseeds, src.URL)
+	}
+	pm.Downloading = make([]DownloadingPiece, 0, len(downloading))
+	for _, dp := range downloading {
+		pm.Downloading = append(pm.Downloading, *dp)
+	}
+	sort.Slice(pm.Downloading, func(i, j int) bool { return pm.Downloading[i].Index < pm.Downloading[j].Index })
+	return pm
+}
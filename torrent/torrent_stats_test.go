@@ -0,0 +1,101 @@
+package torrent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/tracker"
+	"github.com/fortytw2/leaktest"
+)
+
+func TestSeedTimeLimit(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	s.config.SeedTimeLimit = time.Millisecond
+	_, cl := startSeeder(t, s, closeSession)
+	defer cl()
+
+	tor := s.ListTorrents()[0]
+	waitStatus(t, tor, SeedingComplete)
+	if err := tor.Stats().Error; err != nil {
+		t.Fatal(err)
+	}
+	spec, err := s.resumer.Read(tor.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !spec.SeedLimitReached {
+		t.Fatal("seed limit status is not saved")
+	}
+}
+
+func TestDistributedCopies(t *testing.T) {
+	newBitfield := func(pieces ...uint32) *bitfield.Bitfield {
+		bf := bitfield.New(4)
+		for _, i := range pieces {
+			bf.Set(i)
+		}
+		return bf
+	}
+	if n := distributedCopies(4, nil); n != 0 {
+		t.Fatalf("availability must be 0 without any pieces, got %f", n)
+	}
+	if n := distributedCopies(4, []*bitfield.Bitfield{newBitfield(0, 1), newBitfield(1, 2)}); n != 0.75 {
+		t.Fatalf("availability must be less than 1 when a piece is missing in swarm, got %f", n)
+	}
+	if n := distributedCopies(4, []*bitfield.Bitfield{newBitfield(0, 1, 2, 3), newBitfield(0, 1, 2, 3), newBitfield(0)}); n != 2.25 {
+		t.Fatalf("unexpected availability: %f", n)
+	}
+}
+
+func TestSwarmStats(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	// Keep the connection to seeder open by not downloading anything.
+	s.config.PickerWarmupDelay = time.Hour
+
+	// Announce results are sent after the stats of the announcer are updated.
+	s.announceResultC = make(chan AnnounceResult, 10)
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	trk1 := &countingTracker{seeders: 0, leechers: 7}
+	trk2 := &countingTracker{seeders: 3, leechers: 2, url: "http://tracker2.example.com/announce"}
+	trk3 := &countingTracker{seeders: 1, leechers: 1, url: "udp://tracker2.example.com:1337/announce"}
+	tor.torrent.trackers = []tracker.Tracker{trk1, trk2, trk3}
+	tor.Start()
+	announced := make(map[string]bool)
+	for len(announced) < len(tor.torrent.trackers) {
+		select {
+		case res := <-s.announceResultC:
+			if res.Error != nil {
+				t.Fatal(res.Error)
+			}
+			announced[res.Tracker] = true
+		case <-time.After(timeout):
+			t.Fatal("torrent is not announced to all trackers")
+		}
+	}
+	err := tor.AddPeer(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "seeder is not connected", func() bool { return tor.SwarmStats().ConnectedSeeders != 0 })
+	expected := SwarmStats{
+		Seeders:          3,
+		Leechers:         7,
+		TrackerSeeders:   3,
+		TrackerLeechers:  7,
+		ConnectedSeeders: 1,
+	}
+	if st := tor.SwarmStats(); st != expected {
+		t.Fatalf("invalid swarm stats: %+v", st)
+	}
+	st := tor.Stats().Swarm
+	if st.Seeders != 3 || st.Leechers != 7 || st.Trackers != 3 || st.LastUpdate.IsZero() {
+		t.Fatalf("invalid swarm in stats: %+v", st)
+	}
+}
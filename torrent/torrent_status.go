@@ -20,6 +20,17 @@ const (
 	Seeding
 	// Stopping the torrent. This is the status after Stop() is called. All peers are disconnected and files are closed. A stop event sent to all trackers. After trackers responded the torrent switches into Stopped state.
 	Stopping
+	// Paused indicates that the torrent keeps the peers connected but does not download or upload pieces.
+	Paused
+	// SeedingComplete indicates that the torrent is stopped after reaching Config.SeedRatioLimit or Config.SeedTimeLimit.
+	// It behaves like Stopped and remains in the session until it is started again.
+	SeedingComplete
+	// DiskError indicates that downloading is paused because downloaded pieces cannot be written to the disk.
+	// The error is returned in Stats.Error. Downloading is retried when Resume is called.
+	DiskError
+	// Retrying indicates that the torrent is stopped because of an error and it is going to be started again after Config.ErrorRetryInterval.
+	// The error is returned in Stats.Error. Calling Start restarts the torrent immediately and calling Stop cancels the retry.
+	Retrying
 )
 
 func (s Status) String() string {
@@ -31,16 +42,28 @@ func (s Status) String() string {
 		Downloading:         "Downloading",
 		Seeding:             "Seeding",
 		Stopping:            "Stopping",
+		Paused:              "Paused",
+		SeedingComplete:     "Seeding Complete",
+		DiskError:           "Disk Error",
+		Retrying:            "Retrying (error)",
 	}
 	return m[s]
 }
 
 func (t *torrent) status() Status {
 	switch {
+	case t.errC == nil && t.seedLimitReached:
+		return SeedingComplete
 	case t.errC == nil:
 		return Stopped
-	case t.stoppedEventAnnouncer != nil:
+	case t.stoppedEventAnnouncer != nil || t.writeBufferFlushing:
 		return Stopping
+	case t.graceError != nil:
+		return Retrying
+	case t.diskError != nil:
+		return DiskError
+	case t.paused:
+		return Paused
 	case t.allocator != nil:
 		return Allocating
 	case t.verifier != nil:
@@ -1,47 +1,99 @@
 package torrent
 
 import (
+	"net"
+	"time"
+
 	"github.com/cenkalti/rain/internal/announcer"
 	"github.com/cenkalti/rain/internal/handshaker/incominghandshaker"
 	"github.com/cenkalti/rain/internal/handshaker/outgoinghandshaker"
 	"github.com/cenkalti/rain/internal/tracker"
-	"github.com/rcrowley/go-metrics"
 )
 
 func (t *torrent) handleStopped() {
+	t.stoppedEventFailed = !t.stoppedEventAnnouncer.Succeeded()
 	t.stoppedEventAnnouncer = nil
+	if t.writeBufferFlushing {
+		// Stop is finished after the buffered pieces are written.
+		return
+	}
+	t.finishStop()
+}
+
+// finishStop is called after the stopped event is announced and the buffered pieces are written.
+func (t *torrent) finishStop() {
+	if t.graceError != nil {
+		// Keep errC open so the error is not reported while the torrent is waiting to be restarted.
+		go t.notifyErrorRetry()
+		return
+	}
+	t.closeErrC()
+}
+
+// closeErrC reports the stop error to the waiters of NotifyStop and completes the stopping of the torrent.
+func (t *torrent) closeErrC() {
 	t.errC <- t.lastError
 	t.errC = nil
 	t.portC = nil
+	t.sendEvent(Event{Type: EventStopped, Error: t.lastError})
 	if t.doVerify {
 		t.bitfield = nil
 		t.start()
 	} else {
 		t.log.Info("torrent has stopped")
+		t.restartAfterMove()
 	}
 }
 
 func (t *torrent) stop(err error) {
+	// Do not start again after moving files if the torrent is stopped by the user.
+	t.moveRestart = false
+
 	s := t.status()
-	if s == Stopping || s == Stopped {
+	if s == Retrying || (s == Stopping && t.graceError != nil) {
+		// Cancel the restart and report the stop to the user.
+		t.log.Info("cancelling retry")
+		t.graceError = nil
+		t.errorGraceStartedAt = time.Time{}
+		t.lastError = err
+		if s == Retrying {
+			t.closeErrC()
+		}
+		return
+	}
+	if s == Stopping || s == Stopped || s == SeedingComplete {
 		return
 	}
 
 	t.log.Info("stopping torrent")
 	t.lastError = err
+	t.graceError = nil
+	t.paused = false
+	t.diskError = nil
 	if err != nil && err != errClosed {
 		t.log.Error(err)
 	}
 
 	t.stopAcceptor()
+	if t.pieces != nil {
+		// Must be done before closing peers because that closes piece downloaders too.
+		t.savePartialPieces()
+	}
 	t.stopPeers()
+	t.stopNoPeersTimer()
+	t.seekingPeers = false
+	t.stopPickerWarmup()
 	t.stopPiecedownloaders()
 	t.stopInfoDownloaders()
+	t.stopMetadataTimer()
 	t.stopWebseedDownloads()
 
-	if t.bitfield != nil {
+	// Buffered pieces must be written before saving the bitfield and closing the files.
+	// If there are any, the bitfield is saved after they are written.
+	if !t.stopWriteBuffer() && t.bitfield != nil {
 		_ = t.writeBitfield()
 	}
+	t.writeResumePeers()
 
 	// Closing data is necessary to cancel ongoing IO operations on files.
 	t.closeData()
@@ -61,7 +113,8 @@ func (t *torrent) stop(err error) {
 
 	// Then start another announcer to announce Stopped event to the trackers.
 	// The torrent enters "Stopping" state.
-	// This announcer times out in 5 seconds. After it's done the torrent is in "Stopped" status.
+	// Each tracker times out after TrackerStopTimeout and the announcer gives up after StoppedEventTimeout.
+	// After it's done the torrent is in "Stopped" status.
 	trackers := make([]tracker.Tracker, 0, len(announcers))
 	for _, an := range announcers {
 		if an.HasAnnounced {
@@ -71,11 +124,63 @@ func (t *torrent) stop(err error) {
 	if t.stoppedEventAnnouncer != nil {
 		panic("stopped event announcer exists")
 	}
-	t.stoppedEventAnnouncer = announcer.NewStopAnnouncer(trackers, t.announcerFields(), t.session.config.TrackerStopTimeout, t.announcersStoppedC, t.log)
+	t.stoppedEventAnnouncer = announcer.NewStopAnnouncer(trackers, t.announcerFields(), t.session.config.TrackerStopTimeout, t.session.config.StoppedEventTimeout, t.session.config.TrackerStopRetries, t.announcersStoppedC, t.announceResultFunc(AnnounceSourceTracker), t.log)
 
 	go t.stoppedEventAnnouncer.Run()
 
 	t.addrList.Reset()
+	t.peerFallbackAddrs = make(map[string][]*net.TCPAddr)
+}
+
+// stopRecoverable stops the torrent and restarts it later if the grace period for errors has not expired yet.
+// The torrent is in Retrying status while waiting for the restart.
+// Otherwise, or if the error is permanent, the torrent is stopped with the error.
+func (t *torrent) stopRecoverable(err error) {
+	s := t.status()
+	if s == Stopping || s == Stopped || s == SeedingComplete || s == Retrying {
+		return
+	}
+	if isPermanentError(err) {
+		t.errorGraceStartedAt = time.Time{}
+		t.stop(err)
+		return
+	}
+	gracePeriod := t.session.config.ErrorGracePeriod
+	now := time.Now()
+	if t.errorGraceStartedAt.IsZero() {
+		t.errorGraceStartedAt = now
+	}
+	if gracePeriod <= 0 || now.Sub(t.errorGraceStartedAt) >= gracePeriod {
+		t.errorGraceStartedAt = time.Time{}
+		t.stop(err)
+		return
+	}
+	t.log.Warningln("retrying after recoverable error:", err)
+	t.stop(nil)
+	t.graceError = err
+}
+
+func (t *torrent) notifyErrorRetry() {
+	select {
+	case <-time.After(t.session.config.ErrorRetryInterval):
+		select {
+		case t.errorRetryC <- struct{}{}:
+		case <-t.closeC:
+		}
+	case <-t.closeC:
+	}
+}
+
+func (t *torrent) handleErrorRetry() {
+	if t.graceError == nil {
+		// Torrent is stopped or started by the user while waiting.
+		return
+	}
+	t.graceError = nil
+	errC := t.errC
+	t.errC = nil
+	t.start()
+	t.errC = errC
 }
 
 func (t *torrent) stopAllocator() {
@@ -101,10 +206,8 @@ func (t *torrent) stopWebseedDownloads() {
 }
 
 func (t *torrent) resetSpeeds() {
-	t.downloadSpeed.Stop()
-	t.downloadSpeed = metrics.NilMeter{}
-	t.uploadSpeed.Stop()
-	t.uploadSpeed = metrics.NilMeter{}
+	t.downloadRate.reset()
+	t.uploadRate.reset()
 }
 
 func (t *torrent) stopOutgoingHandshakers() {
@@ -113,6 +216,7 @@ func (t *torrent) stopOutgoingHandshakers() {
 		oh.Close()
 	}
 	t.outgoingHandshakers = make(map[*outgoinghandshaker.OutgoingHandshaker]struct{})
+	t.updateNumHandshakers()
 }
 
 func (t *torrent) stopIncomingHandshakers() {
@@ -121,6 +225,7 @@ func (t *torrent) stopIncomingHandshakers() {
 		ih.Close()
 	}
 	t.incomingHandshakers = make(map[*incominghandshaker.IncomingHandshaker]struct{})
+	t.updateNumHandshakers()
 }
 
 func (t *torrent) closeData() {
@@ -148,14 +253,25 @@ func (t *torrent) stopPeriodicalAnnouncers() {
 		t.dhtAnnouncer.Close()
 		t.dhtAnnouncer = nil
 	}
+	if t.lsdAnnouncer != nil {
+		t.lsdAnnouncer.Close()
+		t.lsdAnnouncer = nil
+	}
 }
 
 func (t *torrent) stopAcceptor() {
 	t.log.Debugln("stopping acceptor")
 	if t.acceptor != nil {
 		t.acceptor.Close()
+		if t.session.portMapper != nil {
+			t.session.portMapper.Remove(t.port)
+		}
 	}
 	t.acceptor = nil
+	if t.acceptor6 != nil {
+		t.acceptor6.Close()
+	}
+	t.acceptor6 = nil
 }
 
 func (t *torrent) stopPeers() {
@@ -0,0 +1,91 @@
+package torrent
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/storage"
+	"github.com/fortytw2/leaktest"
+)
+
+// failingStorage fails writes with errno if it is not zero.
+type failingStorage struct {
+	storage.Storage
+	errno int64
+}
+
+func (s *failingStorage) Open(name string, size int64) (storage.File, bool, error) {
+	f, exists, err := s.Storage.Open(name, size)
+	if err != nil {
+		return nil, exists, err
+	}
+	return &failingFile{File: f, storage: s}, exists, nil
+}
+
+type failingFile struct {
+	storage.File
+	storage *failingStorage
+}
+
+func (f *failingFile) WriteAt(p []byte, off int64) (int, error) {
+	if errno := syscall.Errno(atomic.LoadInt64(&f.storage.errno)); errno != 0 {
+		return 0, &os.PathError{Op: "write", Path: "file", Err: errno}
+	}
+	return f.File.WriteAt(p, off)
+}
+
+func TestErrorRetry(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	s.config.ErrorRetryInterval = time.Hour
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	sto := &failingStorage{Storage: tor.torrent.storage, errno: int64(syscall.EIO)}
+	tor.torrent.storage = sto
+	tor.Start()
+	tor.AddPeer(addr)
+
+	waitStatus(t, tor, Retrying)
+	if err := tor.Stats().Error; !errors.Is(err, syscall.EIO) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Start must not wait for the retry interval.
+	atomic.StoreInt64(&sto.errno, 0)
+	tor.Start()
+	tor.AddPeer(addr)
+	assertCompleted(t, tor)
+	if err := tor.Stats().Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestErrorPermanent(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	sto := &failingStorage{Storage: tor.torrent.storage, errno: int64(syscall.ENAMETOOLONG)}
+	tor.torrent.storage = sto
+	tor.Start()
+	tor.AddPeer(addr)
+
+	select {
+	case err := <-tor.NotifyStop():
+		if !errors.Is(err, syscall.ENAMETOOLONG) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(timeout):
+		t.Fatal("torrent is not stopped")
+	}
+}
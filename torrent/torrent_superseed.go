@@ -0,0 +1,164 @@
+package torrent
+
+import (
+	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+)
+
+// superSeedPeer is the state of a peer that is connected while super-seeding.
+type superSeedPeer struct {
+	// Pieces that are revealed to the peer with have messages.
+	revealed *bitfield.Bitfield
+	// Last revealed piece. Next piece is revealed after this piece is seen on another peer.
+	offered  uint32
+	hasOffer bool
+}
+
+func (t *torrent) setSuperSeeding(value bool) {
+	if t.superSeeding == value {
+		return
+	}
+	t.superSeeding = value
+	if !value {
+		t.stopSuperSeeding()
+	}
+}
+
+// superSeedingActive returns true if the pieces must be hidden from new peers.
+func (t *torrent) superSeedingActive() bool {
+	return t.superSeeding && t.completed && t.bitfield != nil
+}
+
+// addSuperSeedPeer reveals the first piece to a new peer.
+// The peer must not be sent our bitfield before.
+func (t *torrent) addSuperSeedPeer(pe *peer.Peer) {
+	if t.superSeedSwarm == nil {
+		t.superSeedSwarm = bitfield.New(t.info.NumPieces)
+		t.updateSuperSeedSwarm()
+	}
+	sp := &superSeedPeer{revealed: bitfield.New(t.info.NumPieces)}
+	t.superSeedPeers[pe] = sp
+	t.offerSuperSeedPiece(pe, sp)
+	t.checkSuperSeedSwarm()
+}
+
+// offerSuperSeedPiece reveals a piece that the peer does not have.
+// Pieces that are rare in the swarm and not offered to other peers are preferred.
+func (t *torrent) offerSuperSeedPiece(pe *peer.Peer, sp *superSeedPeer) {
+	counts := make([]int, t.info.NumPieces)
+	for p := range t.peers {
+		if p.Bitfield == nil {
+			continue
+		}
+		for i := uint32(0); i < p.Bitfield.Len(); i++ {
+			if p.Bitfield.Test(i) {
+				counts[i]++
+			}
+		}
+	}
+	for _, sp2 := range t.superSeedPeers {
+		if sp2.hasOffer {
+			counts[sp2.offered]++
+		}
+	}
+	sp.hasOffer = false
+	for i := uint32(0); i < t.info.NumPieces; i++ {
+		if !t.bitfield.Test(i) || sp.revealed.Test(i) || (pe.Bitfield != nil && pe.Bitfield.Test(i)) {
+			continue
+		}
+		if !sp.hasOffer || counts[i] < counts[sp.offered] {
+			sp.offered = i
+			sp.hasOffer = true
+		}
+	}
+	if !sp.hasOffer {
+		return
+	}
+	sp.revealed.Set(sp.offered)
+	pe.SendMessage(peerprotocol.HaveMessage{Index: sp.offered})
+}
+
+// handleSuperSeedHave must be called after the piece is set in the bitfield of the peer with a have message.
+// Other peers that are offered the piece are revealed a new piece because their piece has been spread.
+// The peer that is offered the piece waits until the piece is seen on another peer.
+func (t *torrent) handleSuperSeedHave(pe *peer.Peer, i uint32) {
+	if !t.superSeeding || t.superSeedSwarm == nil {
+		return
+	}
+	for pe2, sp := range t.superSeedPeers {
+		if pe2 != pe && sp.hasOffer && sp.offered == i {
+			t.offerSuperSeedPiece(pe2, sp)
+		}
+	}
+	t.superSeedSwarm.Set(i)
+	t.checkSuperSeedSwarm()
+}
+
+// handleSuperSeedBitfield must be called after the bitfield of the peer is set with a bitfield or have all message.
+// If the peer already has the piece offered to it, it is revealed another piece.
+func (t *torrent) handleSuperSeedBitfield(pe *peer.Peer) {
+	if !t.superSeeding || t.superSeedSwarm == nil {
+		return
+	}
+	if sp, ok := t.superSeedPeers[pe]; ok && sp.hasOffer && pe.Bitfield.Test(sp.offered) {
+		t.offerSuperSeedPiece(pe, sp)
+	}
+	for i := uint32(0); i < pe.Bitfield.Len(); i++ {
+		if pe.Bitfield.Test(i) {
+			t.superSeedSwarm.Set(i)
+		}
+	}
+	t.checkSuperSeedSwarm()
+}
+
+// checkSuperSeedSwarm disables super-seeding when all pieces are available from connected peers.
+func (t *torrent) checkSuperSeedSwarm() {
+	if !t.superSeedSwarm.All() {
+		return
+	}
+	// Peers may have been disconnected. Check connected peers again.
+	t.updateSuperSeedSwarm()
+	if t.superSeedSwarm.All() {
+		t.log.Info("all pieces are available from peers, disabling super-seeding")
+		t.superSeeding = false
+		t.stopSuperSeeding()
+	}
+}
+
+// updateSuperSeedSwarm sets the pieces of connected peers in the swarm bitfield.
+// Pieces that we don't have are also set because they cannot be spread by us.
+func (t *torrent) updateSuperSeedSwarm() {
+	for i := uint32(0); i < t.info.NumPieces; i++ {
+		if !t.bitfield.Test(i) {
+			t.superSeedSwarm.Set(i)
+			continue
+		}
+		t.superSeedSwarm.Clear(i)
+		for pe := range t.peers {
+			if pe.Bitfield != nil && pe.Bitfield.Test(i) {
+				t.superSeedSwarm.Set(i)
+				break
+			}
+		}
+	}
+}
+
+// stopSuperSeeding reveals all pieces to the peers that are connected while super-seeding.
+func (t *torrent) stopSuperSeeding() {
+	for pe, sp := range t.superSeedPeers {
+		for i := uint32(0); i < t.info.NumPieces; i++ {
+			if t.bitfield.Test(i) && !sp.revealed.Test(i) && !pe.Bitfield.Test(i) {
+				pe.SendMessage(peerprotocol.HaveMessage{Index: i})
+			}
+		}
+	}
+	t.superSeedPeers = make(map[*peer.Peer]*superSeedPeer)
+	t.superSeedSwarm = nil
+}
+
+// superSeedHidden returns true if the piece is not revealed to the peer yet.
+func (t *torrent) superSeedHidden(pe *peer.Peer, i uint32) bool {
+	sp, ok := t.superSeedPeers[pe]
+	return ok && !sp.revealed.Test(i)
+}
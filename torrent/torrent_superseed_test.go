@@ -0,0 +1,104 @@
+package torrent
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/fortytw2/leaktest"
+)
+
+// readHaveMessage skips other messages and returns the index in the next have message.
+func readHaveMessage(t *testing.T, conn net.Conn) uint32 {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		var length uint32
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			t.Fatal(err)
+		}
+		msg := make([]byte, length)
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			t.Fatal(err)
+		}
+		if length == 0 {
+			continue
+		}
+		switch peerprotocol.MessageID(msg[0]) {
+		case peerprotocol.Bitfield, peerprotocol.HaveAll:
+			t.Fatal("pieces are not hidden")
+		case peerprotocol.Have:
+			return binary.BigEndian.Uint32(msg[1:5])
+		}
+	}
+}
+
+func TestSuperSeeding(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	addr, cl := startSeeder(t, s, closeSession)
+	defer cl()
+	tor := s.ListTorrents()[0]
+	select {
+	case <-tor.torrent.NotifyComplete():
+	case <-time.After(timeout):
+		t.Fatal("seeder is not completed")
+	}
+	tor.SetSuperSeeding(true)
+	numPieces := tor.torrent.info.NumPieces
+
+	dial := func(ip string) net.Conn {
+		var peerID [20]byte
+		copy(peerID[:], "-XX0000-"+ip)
+		dialer := &net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(ip)}}
+		taddr, err := net.ResolveTCPAddr("tcp4", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, _, _, _, err := btconn.Dial(taddr, dialer, time.Second, time.Second, false, false, [8]byte{}, tor.torrent.infoHash, peerID, make(chan struct{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	have := func(conn net.Conn, i uint32) {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, i)
+		writePeerMessage(t, conn, peerprotocol.Have, b)
+	}
+
+	// Each peer is revealed a different piece.
+	connA := dial("127.0.0.2")
+	defer connA.Close()
+	pieceA := readHaveMessage(t, connA)
+	connB := dial("127.0.0.3")
+	defer connB.Close()
+	pieceB := readHaveMessage(t, connB)
+	if pieceA == pieceB {
+		t.Fatalf("same piece is revealed to both peers: %d", pieceA)
+	}
+
+	// Piece of A is spread to B, so A is revealed a new piece.
+	have(connB, pieceA)
+	if next := readHaveMessage(t, connA); next == pieceA {
+		t.Fatalf("same piece is revealed again: %d", next)
+	}
+
+	// Super-seeding is disabled when A has all pieces and B is told about all of them.
+	for i := uint32(0); i < numPieces; i++ {
+		have(connA, i)
+	}
+	revealed := map[uint32]struct{}{pieceB: {}}
+	for len(revealed) < int(numPieces)-1 {
+		i := readHaveMessage(t, connB)
+		if i == pieceA {
+			t.Fatalf("piece of peer is revealed: %d", i)
+		}
+		revealed[i] = struct{}{}
+	}
+}
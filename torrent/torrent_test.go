@@ -1,6 +1,9 @@
 package torrent
 
 import (
+	"context"
+	"encoding/binary"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -8,10 +11,14 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/tracker"
 	"github.com/cenkalti/rain/internal/webseedsource"
 	"github.com/fortytw2/leaktest"
 )
@@ -35,6 +42,7 @@ func newTestSession(t *testing.T) (*Session, func()) {
 	cfg.Database = filepath.Join(tmp, "session.db")
 	cfg.DataDir = tmp
 	cfg.DHTEnabled = false
+	cfg.LSDEnabled = false
 	cfg.PEXEnabled = false
 	cfg.RPCEnabled = false
 	s, err := NewSession(cfg)
@@ -56,20 +64,15 @@ func CopyDir(src, dst string) error {
 }
 
 func seeder(t *testing.T) (addr string, c func()) {
-	f, err := os.Open(torrentFile)
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer f.Close()
 	s, closeSession := newTestSession(t)
-	opt := &AddTorrentOptions{Stopped: true}
-	tor, err := s.AddTorrent(f, opt)
-	if err != nil {
-		t.Fatal(err)
-	}
+	return startSeeder(t, s, closeSession)
+}
+
+func startSeeder(t *testing.T, s *Session, closeSession func()) (addr string, c func()) {
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
 	src := filepath.Join(torrentDataDir, torrentName)
 	dst := filepath.Join(s.config.DataDir, tor.ID(), torrentName)
-	err = os.Mkdir(filepath.Join(s.config.DataDir, tor.ID()), os.ModeDir|0750)
+	err := os.Mkdir(filepath.Join(s.config.DataDir, tor.ID()), os.ModeDir|0750)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -82,7 +85,7 @@ func seeder(t *testing.T) (addr string, c func()) {
 	var port int
 	select {
 	case port = <-tor.torrent.NotifyListen():
-	case err = <-tor.torrent.NotifyError():
+	case err := <-tor.torrent.NotifyError():
 		t.Fatal(err)
 	case <-time.After(timeout):
 		t.Fatal("seeder is not ready")
@@ -92,6 +95,20 @@ func seeder(t *testing.T) (addr string, c func()) {
 	}
 }
 
+// addTorrentFile adds the sample torrent to the session.
+func addTorrentFile(t *testing.T, s *Session, opt *AddTorrentOptions) *Torrent {
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tor, err := s.AddTorrent(f, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tor
+}
+
 func tempdir(t *testing.T) (string, func()) {
 	where, err := ioutil.TempDir("", "rain-")
 	if err != nil {
@@ -116,7 +133,27 @@ func TestDownloadMagnet(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if tor.Name() != torrentInfoHashString {
+		t.Fatalf("provisional name must be the info hash, got %q", tor.Name())
+	}
 	assertCompleted(t, tor)
+	if tor.Name() != torrentName {
+		t.Fatalf("name must be changed after metadata is downloaded, got %q", tor.Name())
+	}
+	pm := tor.PieceMap()
+	if pm.NumPieces == 0 {
+		t.Fatal("number of pieces must be known after metadata is downloaded")
+	}
+	bf, err := bitfield.NewBytes(pm.Bitfield, pm.NumPieces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bf.All() {
+		t.Fatalf("all pieces must be set in bitfield, got %s", bf.Hex())
+	}
+	if len(pm.Downloading) != 0 {
+		t.Fatalf("no piece must be downloading, got %d", len(pm.Downloading))
+	}
 }
 
 func webseed(t *testing.T) (port int, c func()) {
@@ -149,17 +186,7 @@ func TestDownloadWebseed(t *testing.T) {
 	s, closeSession := newTestSession(t)
 	defer closeSession()
 
-	f, err := os.Open(torrentFile)
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer f.Close()
-
-	opt := &AddTorrentOptions{Stopped: true}
-	tor, err := s.AddTorrent(f, opt)
-	if err != nil {
-		t.Fatal(err)
-	}
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
 	tor.torrent.webseedSources = webseedsource.NewList([]string{
 		"http://127.0.0.1:" + strconv.Itoa(port1),
 		"http://127.0.0.1:" + strconv.Itoa(port2),
@@ -171,6 +198,78 @@ func TestDownloadWebseed(t *testing.T) {
 	assertCompleted(t, tor)
 }
 
+// waitEvent receives the events of the torrent until an event of type typ is received.
+func waitEvent(t *testing.T, tor *Torrent, typ EventType) Event {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case e := <-tor.Events():
+			if e.Type == typ {
+				return e
+			}
+		case <-deadline:
+			t.Fatalf("%s event is not received", typ)
+		}
+	}
+}
+
+func waitStatus(t *testing.T, tor *Torrent, status Status) {
+	waitFor(t, "torrent status is not "+status.String(), func() bool { return tor.Stats().Status == status })
+}
+
+// waitFor polls cond until it returns true. The test fails with msg if cond is not true in timeout.
+func waitFor(t *testing.T, msg string, cond func() bool) {
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type countingTracker struct {
+	m         sync.Mutex
+	announces int
+	seeders   int32
+	leechers  int32
+	url       string
+}
+
+func (t *countingTracker) Announce(ctx context.Context, req tracker.AnnounceRequest) (*tracker.AnnounceResponse, error) {
+	t.m.Lock()
+	t.announces++
+	t.m.Unlock()
+	return &tracker.AnnounceResponse{Interval: time.Hour, Seeders: t.seeders, Leechers: t.leechers}, nil
+}
+
+func (t *countingTracker) URL() string {
+	if t.url != "" {
+		return t.url
+	}
+	return "http://tracker.example.com/announce"
+}
+
+func (t *countingTracker) Scrape(ctx context.Context, infoHashes [][20]byte) ([]tracker.ScrapeResult, error) {
+	return nil, tracker.ErrScrapeNotSupported
+}
+
+func (t *countingTracker) Announces() int {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.announces
+}
+
+func writePeerMessage(t *testing.T, conn net.Conn, id peerprotocol.MessageID, payload []byte) {
+	b := make([]byte, 5+len(payload))
+	binary.BigEndian.PutUint32(b, uint32(1+len(payload)))
+	b[4] = byte(id)
+	copy(b[5:], payload)
+	if _, err := conn.Write(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func assertCompleted(t *testing.T, tor *Torrent) {
 	t2 := tor.torrent
 	select {
@@ -188,3 +287,66 @@ func assertCompleted(t *testing.T, tor *Torrent) {
 		t.Fatal(err)
 	}
 }
+
+// socksForwarder is a SOCKS5 server that connects requests for host to target address.
+// Host may be a host name or an IPv4 address.
+func socksForwarder(t *testing.T, host, target string) (addr string, c func()) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		buf := make([]byte, 3)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		if _, err := conn.Write([]byte{5, 0}); err != nil {
+			return
+		}
+		hdr := make([]byte, 5)
+		if _, err := io.ReadFull(conn, hdr); err != nil {
+			return
+		}
+		var requested string
+		switch hdr[3] {
+		case 1:
+			b := make([]byte, 5)
+			if _, err := io.ReadFull(conn, b); err != nil {
+				return
+			}
+			requested = net.IP(append(hdr[4:], b[:3]...)).String()
+		case 3:
+			b := make([]byte, int(hdr[4])+2)
+			if _, err := io.ReadFull(conn, b); err != nil {
+				return
+			}
+			requested = string(b[:len(b)-2])
+		}
+		if requested != host {
+			_, _ = conn.Write([]byte{5, 4, 0, 1, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		tconn, err := net.Dial("tcp4", target)
+		if err != nil {
+			_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		defer tconn.Close()
+		if _, err = conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0}); err != nil {
+			return
+		}
+		go func() { _, _ = io.Copy(tconn, conn) }()
+		_, _ = io.Copy(conn, tconn)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return l.Addr().String(), func() { l.Close() }
+}
@@ -10,7 +10,7 @@ import (
 func (t *torrent) handleVerifyCommand() {
 	t.log.Info("verifying")
 	t.doVerify = true
-	if t.status() == Stopped {
+	if s := t.status(); s == Stopped || s == SeedingComplete || s == Retrying {
 		t.bitfield = nil
 		t.start()
 	} else {
@@ -25,7 +25,7 @@ func (t *torrent) handleVerificationDone(ve *verifier.Verifier) {
 	t.verifier = nil
 
 	if ve.Error != nil {
-		t.stop(fmt.Errorf("file verification error: %s", ve.Error))
+		t.stopRecoverable(fmt.Errorf("file verification error: %w", ve.Error))
 		return
 	}
 
@@ -33,6 +33,11 @@ func (t *torrent) handleVerificationDone(ve *verifier.Verifier) {
 	t.mBitfield.Lock()
 	t.bitfield = ve.Bitfield
 	t.mBitfield.Unlock()
+	// Bitfield from resume data may contain pieces that are added with SkipHashCheck and not verified yet.
+	if !ve.Resumed && t.unverifiedPieces != nil {
+		t.unverifiedPieces = nil
+		t.writeUnverifiedPieces()
+	}
 
 	// Save the bitfield to resume db.
 	err := t.writeBitfield()
@@ -51,8 +56,12 @@ func (t *torrent) handleVerificationDone(ve *verifier.Verifier) {
 		}
 	}
 
+	t.sendEvent(Event{Type: EventVerificationComplete})
+	t.checkPieceRequests()
+
 	// We may detect missing pieces after verification. Then, status must be set from Seeding to Downloading.
-	if !t.bitfield.All() {
+	// Channel is replaced only if it is closed before, otherwise waiters of the channel are not notified on completion.
+	if t.completed && !t.wantedPiecesDone() {
 		t.completed = false
 		t.completeC = make(chan struct{})
 	}
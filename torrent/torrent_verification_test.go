@@ -0,0 +1,52 @@
+package torrent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/semaphore"
+	"github.com/fortytw2/leaktest"
+)
+
+func TestMaxConcurrentVerifications(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	s.semVerify = semaphore.New(1)
+
+	var torrents []*Torrent
+	for i := 0; i < 3; i++ {
+		tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+		tor.torrent.trackers = nil
+		// Existing files are verified when the torrent is started.
+		src := filepath.Join(torrentDataDir, torrentName)
+		dst := filepath.Join(s.config.DataDir, tor.ID(), torrentName)
+		err := os.Mkdir(filepath.Join(s.config.DataDir, tor.ID()), os.ModeDir|0750)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = CopyDir(src, dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		torrents = append(torrents, tor)
+	}
+	// Take the only slot so all torrents must wait in queue.
+	s.semVerify.Wait()
+	for _, tor := range torrents {
+		tor.Start()
+	}
+	waitFor(t, "torrents are not waiting for verification", func() bool { return s.semVerify.Waiting() == len(torrents) })
+	for _, tor := range torrents {
+		stats := tor.Stats()
+		if stats.Status != Verifying || stats.Pieces.Checked != 0 {
+			t.Fatalf("torrent must be waiting for verification, status: %s, checked: %d", stats.Status, stats.Pieces.Checked)
+		}
+	}
+
+	s.semVerify.Signal()
+	for _, tor := range torrents {
+		waitStatus(t, tor, Seeding)
+	}
+}
@@ -1,9 +1,11 @@
 package torrent
 
 import (
+	"errors"
+	"net/url"
+	"strings"
 	"time"
 
-	"github.com/cenkalti/rain/internal/piecewriter"
 	"github.com/cenkalti/rain/internal/urldownloader"
 	"github.com/cenkalti/rain/internal/webseedsource"
 )
@@ -25,12 +27,12 @@ func (t *torrent) handleWebseedPieceResult(msg *urldownloader.PieceResult) {
 	t.log.Debugf("piece #%d downloaded from %s", msg.Index, msg.Downloader.URL)
 
 	t.bytesDownloaded.Inc(int64(len(msg.Buffer.Data)))
-	t.downloadSpeed.Mark(int64(len(msg.Buffer.Data)))
 	for _, src := range t.webseedSources {
 		if src.URL != msg.Downloader.URL {
 			continue
 		}
 		src.DownloadSpeed.Mark(int64(len(msg.Buffer.Data)))
+		src.Failures = 0
 		break
 	}
 
@@ -43,8 +45,7 @@ func (t *torrent) handleWebseedPieceResult(msg *urldownloader.PieceResult) {
 	t.pieceMessagesC.Suspend()
 	t.webseedPieceResultC.Suspend()
 
-	pw := piecewriter.New(piece, msg.Downloader, msg.Buffer)
-	go pw.Run(t.pieceWriterResultC, t.doneC, t.session.metrics.WritesPerSecond, t.session.metrics.SpeedWrite, t.session.semWrite)
+	t.startPieceWriter(piece, msg.Downloader, msg.Buffer)
 
 	if msg.Done {
 		for _, src := range t.webseedSources {
@@ -68,16 +69,52 @@ func (t *torrent) disableSource(srcurl string, err error, retry bool) {
 		src.DisabledAt = time.Now()
 		src.LastError = err
 		t.closeWebseedDownloader(src)
-		if retry {
-			go t.notifyWebseedRetry(src)
+		if !retry {
+			break
+		}
+		src.Failures++
+		if max := t.session.config.WebseedMaxRetries; max > 0 && src.Failures > max {
+			t.log.Debugf("webseed source %s failed %d times, not retrying", src.URL, src.Failures)
+			break
 		}
+		delay := webseedRetryDelay(err, src.Failures, t.session.config.WebseedRetryInterval, t.session.config.WebseedMaxRetryInterval)
+		go t.notifyWebseedRetry(src, delay)
 		break
 	}
 }
 
-func (t *torrent) notifyWebseedRetry(src *webseedsource.WebseedSource) {
+// webseedRetryDelay returns the time to wait before using a source again after an error.
+// Delay requested by the server with Retry-After header is respected.
+// Otherwise, the delay grows exponentially with the number of consecutive failures.
+func webseedRetryDelay(err error, failures int, interval, max time.Duration) time.Duration {
+	var serr *urldownloader.StatusError
+	if errors.As(err, &serr) && serr.RetryAfter > 0 {
+		return serr.RetryAfter
+	}
+	if max < interval {
+		max = interval
+	}
+	delay := interval
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// handleWebseedRetry enables the source again after the retry delay has passed.
+func (t *torrent) handleWebseedRetry(src *webseedsource.WebseedSource) {
+	src.Disabled = false
+	if !src.Downloading() {
+		t.startPieceDownloaderForWebseed(src)
+	}
+}
+
+func (t *torrent) notifyWebseedRetry(src *webseedsource.WebseedSource, delay time.Duration) {
 	select {
-	case <-time.After(time.Minute):
+	case <-time.After(delay):
 		select {
 		case t.webseedRetryC <- src:
 		case <-t.closeC:
@@ -85,3 +122,71 @@ func (t *torrent) notifyWebseedRetry(src *webseedsource.WebseedSource) {
 	case <-t.closeC:
 	}
 }
+
+func markTrustedWebseeds(sources []*webseedsource.WebseedSource, trusted []string) {
+	for _, src := range sources {
+		for _, prefix := range trusted {
+			if matchWebseedPrefix(src.URL, prefix) {
+				src.Trusted = true
+				break
+			}
+		}
+	}
+}
+
+// matchWebseedPrefix returns true if rawURL has the same scheme, host and port with prefix and its path is under the path of prefix.
+// Paths are compared at "/" boundaries, so "https://example.com/a" does not match "https://example.com/ab".
+func matchWebseedPrefix(rawURL, prefix string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	p, err := url.Parse(prefix)
+	if err != nil {
+		return false
+	}
+	if u.Scheme != p.Scheme || !strings.EqualFold(u.Host, p.Host) || p.Host == "" || u.User != nil {
+		return false
+	}
+	dir := strings.TrimSuffix(p.Path, "/")
+	return u.Path == dir || strings.HasPrefix(u.Path, dir+"/")
+}
+
+// verifyPieceFrom returns true if the data downloaded from the given sources must be hash checked before writing.
+func (t *torrent) verifyPieceFrom(sources ...interface{}) bool {
+	if t.session.config.DisableVerification {
+		return false
+	}
+	if !t.session.config.WebseedSkipVerifyTrusted || !t.session.config.WebseedVerifyTLS {
+		return true
+	}
+	return !allTrustedWebseeds(t.webseedSources, sources)
+}
+
+// allTrustedWebseeds returns true if all of the sources are trusted WebSeed sources served over HTTPS.
+// Peers and any other kind of source make the result false, so pieces with mixed sources are always verified.
+func allTrustedWebseeds(webseeds []*webseedsource.WebseedSource, sources []interface{}) bool {
+	if len(sources) == 0 {
+		return false
+	}
+	for _, s := range sources {
+		ud, ok := s.(*urldownloader.URLDownloader)
+		if !ok {
+			return false
+		}
+		if !strings.HasPrefix(strings.ToLower(ud.URL), "https://") {
+			return false
+		}
+		var trusted bool
+		for _, src := range webseeds {
+			if src.URL == ud.URL {
+				trusted = src.Trusted
+				break
+			}
+		}
+		if !trusted {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,190 @@
+package torrent
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/urldownloader"
+	"github.com/cenkalti/rain/internal/webseedsource"
+	"github.com/fortytw2/leaktest"
+)
+
+func TestWebseedRetryDelay(t *testing.T) {
+	cases := []struct {
+		err      error
+		failures int
+		expected time.Duration
+	}{
+		{errors.New("error"), 1, time.Minute},
+		{errors.New("error"), 2, 2 * time.Minute},
+		{errors.New("error"), 4, 8 * time.Minute},
+		{errors.New("error"), 100, 30 * time.Minute},
+		{&urldownloader.StatusError{Code: 503, RetryAfter: 5 * time.Second}, 100, 5 * time.Second},
+	}
+	for _, c := range cases {
+		d := webseedRetryDelay(c.err, c.failures, time.Minute, 30*time.Minute)
+		if d != c.expected {
+			t.Errorf("failures: %d, expected %s, got %s", c.failures, c.expected, d)
+		}
+	}
+}
+
+func TestPrivateTorrentWebseed(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	var requests [2]int32
+	torrents := make([]*Torrent, 2)
+	for i, private := range []bool{false, true} {
+		i := i
+		l, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs := http.FileServer(http.Dir("./testdata"))
+		srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests[i], 1)
+			fs.ServeHTTP(w, r)
+		})}
+		go srv.Serve(l)
+		defer srv.Close()
+
+		info, err := metainfo.NewInfoBytes("", []string{filepath.Join(torrentDataDir, torrentName)}, private, 0, "", logger.New("test"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := metainfo.NewBytes(info, nil, nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tor, err := s.AddTorrent(bytes.NewReader(b), &AddTorrentOptions{Stopped: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tor.torrent.trackers = nil
+		tor.torrent.webseedSources = webseedsource.NewList([]string{"http://" + l.Addr().String()})
+		tor.torrent.webseedClient = http.DefaultClient
+		tor.Start()
+		torrents[i] = tor
+	}
+	// Public torrent is started first and downloads from its webseed.
+	assertCompleted(t, torrents[0])
+	if st := torrents[1].Stats(); st.Bytes.Completed != 0 {
+		t.Fatalf("private torrent downloaded %d bytes", st.Bytes.Completed)
+	}
+	if n := atomic.LoadInt32(&requests[1]); n != 0 {
+		t.Fatalf("webseed of private torrent received %d requests", n)
+	}
+}
+
+func TestDownloadWebseedRetryAfter(t *testing.T) {
+	defer leaktest.Check(t)()
+	const retryAfter = 2 * time.Second
+	var mu sync.Mutex
+	var requestTimes []time.Time
+	fs := http.FileServer(http.Dir("./testdata"))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requestTimes = append(requestTimes, time.Now())
+		first := len(requestTimes) == 1
+		mu.Unlock()
+		if first {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter/time.Second)))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fs.ServeHTTP(w, r)
+	})
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: handler}
+	servingDone := make(chan struct{})
+	go func() {
+		srv.Serve(l)
+		close(servingDone)
+	}()
+	defer func() {
+		srv.Close()
+		<-servingDone
+	}()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	tor.torrent.webseedSources = webseedsource.NewList([]string{
+		"http://127.0.0.1:" + strconv.Itoa(l.Addr().(*net.TCPAddr).Port),
+	})
+	tor.torrent.webseedClient = http.DefaultClient
+	tor.Start()
+
+	assertCompleted(t, tor)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requestTimes) < 2 {
+		t.Fatalf("expected at least 2 requests, got %d", len(requestTimes))
+	}
+	if d := requestTimes[1].Sub(requestTimes[0]); d < retryAfter {
+		t.Fatalf("source retried after %s, before Retry-After delay %s", d, retryAfter)
+	}
+}
+
+func TestAllTrustedWebseeds(t *testing.T) {
+	webseeds := webseedsource.NewList([]string{"https://trusted.example.com/a", "https://other.example.com/a", "http://trusted.example.com/a"})
+	markTrustedWebseeds(webseeds, []string{"https://trusted.example.com/", "http://trusted.example.com/"})
+	trusted := urldownloader.New("https://trusted.example.com/a", 0, 1)
+	untrusted := urldownloader.New("https://other.example.com/a", 0, 1)
+	plain := urldownloader.New("http://trusted.example.com/a", 0, 1)
+	if !allTrustedWebseeds(webseeds, []interface{}{trusted}) {
+		t.Fatal("pieces from trusted webseed must skip verification")
+	}
+	if allTrustedWebseeds(webseeds, []interface{}{untrusted}) {
+		t.Fatal("pieces from untrusted webseed must be verified")
+	}
+	if allTrustedWebseeds(webseeds, []interface{}{plain}) {
+		t.Fatal("pieces from trusted webseed without TLS must be verified")
+	}
+	if allTrustedWebseeds(webseeds, []interface{}{trusted, &peer.Peer{}}) {
+		t.Fatal("pieces with mixed sources must be verified")
+	}
+}
+
+func TestMatchWebseedPrefix(t *testing.T) {
+	cases := []struct {
+		url     string
+		prefix  string
+		matches bool
+	}{
+		{"https://trusted.example.com/a", "https://trusted.example.com/", true},
+		{"https://trusted.example.com/a", "https://trusted.example.com", true},
+		{"https://TRUSTED.example.com/a", "https://trusted.example.com/", true},
+		{"https://trusted.example.com/files/a", "https://trusted.example.com/files", true},
+		{"https://trusted.example.com/files/a", "https://trusted.example.com/files/", true},
+		{"https://trusted.example.com/files", "https://trusted.example.com/files/", true},
+		{"https://trusted.example.com/filesystem/a", "https://trusted.example.com/files", false},
+		{"https://trusted.example.com.evil.com/a", "https://trusted.example.com", false},
+		{"https://trusted.example.com:8443/a", "https://trusted.example.com/", false},
+		{"https://trusted.example.com@evil.com/a", "https://trusted.example.com/", false},
+		{"http://trusted.example.com/a", "https://trusted.example.com/", false},
+		{"https://evil.com/trusted.example.com/a", "https://trusted.example.com/", false},
+	}
+	for _, c := range cases {
+		if matchWebseedPrefix(c.url, c.prefix) != c.matches {
+			t.Errorf("url: %q, prefix: %q, expected match: %v", c.url, c.prefix, c.matches)
+		}
+	}
+}
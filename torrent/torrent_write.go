@@ -3,28 +3,63 @@ package torrent
 import (
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/cenkalti/rain/internal/bufferpool"
 	"github.com/cenkalti/rain/internal/peer"
 	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/piece"
 	"github.com/cenkalti/rain/internal/piecewriter"
 	"github.com/cenkalti/rain/internal/urldownloader"
 )
 
-func (t *torrent) handlePieceWriteDone(pw *piecewriter.PieceWriter) {
-	pw.Piece.Writing = false
+// startPieceWriter checks the hash of the downloaded piece and writes it to disk.
+// If Config.WriteBufferSize is set, the piece is written later with the other pieces in the write buffer.
+func (t *torrent) startPieceWriter(pi *piece.Piece, source interface{}, buf bufferpool.Buffer) {
+	pw := piecewriter.New(pi, source, buf, t.verifyPieceFrom(source))
+	pw.Defer = t.session.config.WriteBufferSize > 0
+	go pw.Run(t.pieceWriterResultC, t.doneC, t.session.metrics.WritesPerSecond, t.session.metrics.SpeedWrite, t.session.semWrite)
+}
 
+func (t *torrent) handlePieceWriteDone(pw *piecewriter.PieceWriter) {
 	t.pieceMessagesC.Resume()
 	t.webseedPieceResultC.Resume()
 
+	if pw.Defer && pw.HashOK {
+		t.bufferPieceWrite(pw)
+		return
+	}
+	t.handlePieceWritten(pw)
+}
+
+// handlePieceWritten is called after the piece is written to disk or it has failed the hash check.
+func (t *torrent) handlePieceWritten(pw *piecewriter.PieceWriter) {
+	pw.Piece.Writing = false
 	pw.Buffer.Release()
 
+	_, resumed := t.partialPieces[pw.Piece.Index]
+	delete(t.partialPieces, pw.Piece.Index)
+	_, mixed := t.mixedPieces[pw.Piece.Index]
+	delete(t.mixedPieces, pw.Piece.Index)
+
 	if !pw.HashOK {
 		t.bytesWasted.Inc(int64(len(pw.Buffer.Data)))
+		if resumed {
+			// Saved blocks from previous run may be corrupt. Do not blame the source.
+			t.log.Debugf("piece #%d is corrupt, discarding blocks from previous run", pw.Piece.Index)
+			t.startPieceDownloaders()
+			return
+		}
+		if mixed {
+			// Blocks are received from multiple peers. Do not blame a single peer.
+			t.log.Debugf("piece #%d is corrupt, blocks are received from multiple peers", pw.Piece.Index)
+			t.startPieceDownloaders()
+			return
+		}
 		switch src := pw.Source.(type) {
 		case *peer.Peer:
 			t.log.Debugln("received corrupt piece from peer", src.String())
-			t.closePeer(src)
-			t.bannedPeerIPs[src.IP()] = struct{}{}
+			t.handleBadPiece(src)
 		case *urldownloader.URLDownloader:
 			t.log.Debugln("received corrupt piece from webseed", src.URL)
 			t.disableSource(src.URL, errors.New("corrupt piece"), false)
@@ -35,17 +70,22 @@ func (t *torrent) handlePieceWriteDone(pw *piecewriter.PieceWriter) {
 		return
 	}
 	if pw.Error != nil {
-		t.stop(pw.Error)
+		// Retrying does not help until the user fixes the permissions or frees space.
+		// The piece is not marked as done. It is downloaded again after resume.
+		if isReadOnlyError(pw.Error) {
+			t.handleDiskError(fmt.Errorf("%w: %s", ErrStorageReadOnly, pw.Error))
+			return
+		}
+		if isDiskFullError(pw.Error) {
+			t.handleDiskError(fmt.Errorf("%w: %s", ErrDiskFull, pw.Error))
+			return
+		}
+		t.stopRecoverable(pw.Error)
 		return
 	}
+	t.errorGraceStartedAt = time.Time{}
 
-	pw.Piece.Done = true
-	if t.bitfield.Test(pw.Piece.Index) {
-		panic(fmt.Sprintf("already have the piece #%d", pw.Piece.Index))
-	}
-	t.mBitfield.Lock()
-	t.bitfield.Set(pw.Piece.Index)
-	t.mBitfield.Unlock()
+	t.markPieceDone(pw)
 
 	if t.piecePicker != nil {
 		_, ok := pw.Source.(*urldownloader.URLDownloader)
@@ -77,6 +117,7 @@ func (t *torrent) handlePieceWriteDone(pw *piecewriter.PieceWriter) {
 		pe.SendMessage(msg)
 	}
 
+	t.checkPieceRequests()
 	completed := t.checkCompletion()
 	if completed {
 		t.log.Info("download completed")
@@ -88,3 +129,32 @@ func (t *torrent) handlePieceWriteDone(pw *piecewriter.PieceWriter) {
 		}
 	}
 }
+
+// markPieceDone sets the bit of the written piece in the bitfield.
+func (t *torrent) markPieceDone(pw *piecewriter.PieceWriter) {
+	pw.Piece.Done = true
+	if t.bitfield.Test(pw.Piece.Index) {
+		panic(fmt.Sprintf("already have the piece #%d", pw.Piece.Index))
+	}
+	t.mBitfield.Lock()
+	t.bitfield.Set(pw.Piece.Index)
+	t.bitfieldDirty = true
+	t.mBitfield.Unlock()
+}
+
+// handleBadPiece is called when all blocks of a piece that failed the hash check are received from the peer.
+// The peer is banned if it exceeds the limit of corrupt pieces.
+func (t *torrent) handleBadPiece(pe *peer.Peer) {
+	ip := pe.IP()
+	t.badPieceCounts[ip]++
+	if t.badPieceCounts[ip] <= t.session.config.MaxBadPiecesBeforeBan {
+		return
+	}
+	t.log.Debugf("banning peer %s after %d corrupt pieces", ip, t.badPieceCounts[ip])
+	t.bannedPeerIPs[ip] = struct{}{}
+	for pe2 := range t.peers {
+		if pe2.IP() == ip {
+			t.closePeer(pe2)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package torrent
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/storage"
+	"github.com/fortytw2/leaktest"
+)
+
+// readOnlyStorage fails writes with EROFS while readOnly is set.
+type readOnlyStorage struct {
+	storage.Storage
+	readOnly int32
+}
+
+func (s *readOnlyStorage) Open(name string, size int64) (storage.File, bool, error) {
+	f, exists, err := s.Storage.Open(name, size)
+	if err != nil {
+		return nil, exists, err
+	}
+	return &readOnlyFile{File: f, storage: s}, exists, nil
+}
+
+type readOnlyFile struct {
+	storage.File
+	storage *readOnlyStorage
+}
+
+func (f *readOnlyFile) WriteAt(p []byte, off int64) (int, error) {
+	if atomic.LoadInt32(&f.storage.readOnly) == 1 {
+		return 0, &os.PathError{Op: "write", Path: "file", Err: syscall.EROFS}
+	}
+	return f.File.WriteAt(p, off)
+}
+
+func TestStorageReadOnly(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	sto := &readOnlyStorage{Storage: tor.torrent.storage, readOnly: 1}
+	tor.torrent.storage = sto
+	tor.Start()
+	tor.AddPeer(addr)
+
+	e := waitEvent(t, tor, EventDiskError)
+	if !errors.Is(e.Error, ErrStorageReadOnly) {
+		t.Fatalf("unexpected error: %v", e.Error)
+	}
+	if st := tor.Stats().Status; st != DiskError {
+		t.Fatalf("torrent must be paused, status: %s", st)
+	}
+
+	// Permissions are fixed.
+	atomic.StoreInt32(&sto.readOnly, 0)
+	tor.Resume()
+	assertCompleted(t, tor)
+	if err := tor.Stats().Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// diskFullStorage fails writes with ENOSPC after writing space bytes.
+// The write that exceeds the space is done partially.
+type diskFullStorage struct {
+	storage.Storage
+	space int64
+}
+
+func (s *diskFullStorage) Open(name string, size int64) (storage.File, bool, error) {
+	f, exists, err := s.Storage.Open(name, size)
+	if err != nil {
+		return nil, exists, err
+	}
+	return &diskFullFile{File: f, storage: s}, exists, nil
+}
+
+type diskFullFile struct {
+	storage.File
+	storage *diskFullStorage
+}
+
+func (f *diskFullFile) WriteAt(p []byte, off int64) (int, error) {
+	space := atomic.AddInt64(&f.storage.space, -int64(len(p))) + int64(len(p))
+	if space >= int64(len(p)) {
+		return f.File.WriteAt(p, off)
+	}
+	if space < 0 {
+		space = 0
+	}
+	atomic.AddInt64(&f.storage.space, int64(len(p))-space)
+	n, err := f.File.WriteAt(p[:space], off)
+	if err != nil {
+		return n, err
+	}
+	return n, &os.PathError{Op: "write", Path: "file", Err: syscall.ENOSPC}
+}
+
+func TestDiskFull(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	// Disk gets full in the middle of the second piece.
+	sto := &diskFullStorage{Storage: tor.torrent.storage, space: int64(tor.torrent.info.PieceLength) * 3 / 2}
+	tor.torrent.storage = sto
+	tor.Start()
+	tor.AddPeer(addr)
+
+	waitStatus(t, tor, DiskError)
+	if err := tor.Stats().Error; !errors.Is(err, ErrDiskFull) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitEvent(t, tor, EventDiskError)
+	if have := tor.Stats().Pieces.Have; have >= tor.torrent.info.NumPieces {
+		t.Fatalf("all pieces are marked as downloaded, have: %d", have)
+	}
+
+	// Space is freed. Pieces that are failed to write must be downloaded again, otherwise files do not match.
+	atomic.StoreInt64(&sto.space, math.MaxInt64/2)
+	tor.Resume()
+	assertCompleted(t, tor)
+	if err := tor.Stats().Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBanPeerSendingCorruptData(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	s.config.MaxBadPiecesBeforeBan = 0
+
+	tor := addTorrentFile(t, s, &AddTorrentOptions{Stopped: true})
+	numPieces := tor.torrent.info.NumPieces
+	tor.Start()
+	var port int
+	select {
+	case port = <-tor.torrent.NotifyListen():
+	case <-time.After(timeout):
+		t.Fatal("torrent is not listening")
+	}
+
+	// Connect as a seeder that sends zeroed blocks for every request.
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	var peerID [20]byte
+	copy(peerID[:], "-XX0000-000000000000")
+	conn, _, _, _, err := btconn.Dial(addr, nil, time.Second, time.Second, false, false, [8]byte{}, tor.torrent.infoHash, peerID, make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	bf := bitfield.New(numPieces)
+	for i := uint32(0); i < numPieces; i++ {
+		bf.Set(i)
+	}
+	writePeerMessage(t, conn, peerprotocol.Bitfield, bf.Bytes())
+	writePeerMessage(t, conn, peerprotocol.Unchoke, nil)
+	closedC := make(chan struct{})
+	go func() {
+		defer close(closedC)
+		for {
+			var length uint32
+			if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+				return
+			}
+			msg := make([]byte, length)
+			if _, err := io.ReadFull(conn, msg); err != nil {
+				return
+			}
+			if length == 0 || peerprotocol.MessageID(msg[0]) != peerprotocol.Request {
+				continue
+			}
+			blockLength := binary.BigEndian.Uint32(msg[9:13])
+			b := make([]byte, 13+blockLength)
+			binary.BigEndian.PutUint32(b, 9+blockLength)
+			b[4] = byte(peerprotocol.Piece)
+			copy(b[5:13], msg[1:9])
+			if _, err := conn.Write(b); err != nil {
+				return
+			}
+		}
+	}()
+	select {
+	case <-closedC:
+	case <-time.After(timeout):
+		t.Fatal("peer sending corrupt data is not disconnected")
+	}
+
+	conn2, _, _, _, err := btconn.Dial(addr, nil, time.Second, time.Second, false, false, [8]byte{}, tor.torrent.infoHash, peerID, make(chan struct{}))
+	if err == nil {
+		conn2.Close()
+		t.Fatal("banned peer must not be able to connect again")
+	}
+}
@@ -0,0 +1,164 @@
+package torrent
+
+import (
+	"time"
+
+	"github.com/cenkalti/rain/internal/piecewriter"
+)
+
+// bufferPieceWrite keeps the hash checked piece in memory until it is written to disk with the other pieces in the buffer.
+// Buffer is written when its size reaches Config.WriteBufferSize or after Config.WriteBufferFlushInterval.
+func (t *torrent) bufferPieceWrite(pw *piecewriter.PieceWriter) {
+	if t.pieces == nil || &t.pieces[pw.Piece.Index] != pw.Piece {
+		// Torrent is stopped while the hash of the piece was being checked.
+		pw.Piece.Writing = false
+		pw.Buffer.Release()
+		delete(t.partialPieces, pw.Piece.Index)
+		delete(t.mixedPieces, pw.Piece.Index)
+		return
+	}
+	t.writeBuffer = append(t.writeBuffer, pw)
+	t.writeBufferSize += int64(len(pw.Buffer.Data))
+	if t.writeBufferSize >= t.session.config.WriteBufferSize || t.allPiecesWriting() {
+		t.flushWriteBuffer()
+		return
+	}
+	t.startWriteBufferTimer()
+}
+
+// allPiecesWriting returns true if there are no more pieces to download, so there is no point waiting for the buffer to fill.
+func (t *torrent) allPiecesWriting() bool {
+	n := t.bitfield.Count() + uint32(len(t.writeBuffer))
+	if t.writeBatch != nil {
+		n += uint32(len(t.writeBatch.Writers))
+	}
+	return n == t.bitfield.Len()
+}
+
+// flushWriteBuffer starts writing the buffered pieces to disk.
+// Only one batch is written at a time. Pieces buffered meanwhile are written after the running batch is done.
+func (t *torrent) flushWriteBuffer() {
+	t.stopWriteBufferTimer()
+	if t.writeBatch != nil || len(t.writeBuffer) == 0 {
+		return
+	}
+	t.writeBatch = t.newWriteBatch()
+	go t.writeBatch.Run(t.writeBatchResultC, t.doneC, t.session.metrics.WritesPerSecond, t.session.metrics.SpeedWrite, t.session.semWrite)
+}
+
+func (t *torrent) newWriteBatch() *piecewriter.Batch {
+	b := piecewriter.NewBatch(t.writeBuffer)
+	t.writeBuffer = nil
+	t.writeBufferSize = 0
+	return b
+}
+
+func (t *torrent) handleWriteBatchDone(b *piecewriter.Batch) {
+	if t.writeBatch != b {
+		// Batch is already handled while stopping the torrent.
+		return
+	}
+	t.writeBatch = nil
+	for i, pw := range b.Writers {
+		if t.pieces == nil {
+			// Torrent is stopped by one of the previous pieces, i.e. it has completed.
+			t.finishBufferedWrites(b.Writers[i:])
+			return
+		}
+		t.handlePieceWritten(pw)
+	}
+	if t.pieces == nil {
+		return
+	}
+	if t.writeBufferSize >= t.session.config.WriteBufferSize || (len(t.writeBuffer) > 0 && t.allPiecesWriting()) {
+		t.flushWriteBuffer()
+	} else if len(t.writeBuffer) > 0 {
+		t.startWriteBufferTimer()
+	}
+}
+
+// finishBufferedWrites marks the written pieces as done without notifying peers. Used while the torrent is stopping.
+func (t *torrent) finishBufferedWrites(writers []*piecewriter.PieceWriter) {
+	for _, pw := range writers {
+		pw.Piece.Writing = false
+		pw.Buffer.Release()
+		delete(t.partialPieces, pw.Piece.Index)
+		delete(t.mixedPieces, pw.Piece.Index)
+		if pw.Error != nil {
+			t.log.Errorf("cannot write piece #%d: %s", pw.Piece.Index, pw.Error)
+			continue
+		}
+		t.markPieceDone(pw)
+	}
+}
+
+// stopWriteBuffer starts writing the buffered pieces to disk in a goroutine while the torrent is stopping,
+// so the run loop is not blocked by disk IO. Files are closed by the goroutine after the pieces are written.
+// The torrent stays in Stopping status until handleWriteBufferFlushed is called.
+// Returns false if there are no pieces to write.
+func (t *torrent) stopWriteBuffer() bool {
+	t.stopWriteBufferTimer()
+	if t.writeBatch == nil && len(t.writeBuffer) == 0 {
+		return false
+	}
+	running := t.writeBatch
+	t.writeBatch = nil
+	b := t.newWriteBatch()
+	files := t.files
+	// Prevent closing the files before the pieces are written.
+	t.files = nil
+	t.writeBufferFlushing = true
+	go func() {
+		if running != nil {
+			<-running.Done()
+			b.Writers = append(running.Writers, b.Writers...)
+		}
+		b.Write(t.session.metrics.WritesPerSecond, t.session.metrics.SpeedWrite, t.session.semWrite)
+		for _, f := range files {
+			if err := f.Storage.Close(); err != nil {
+				t.log.Error(err)
+			}
+		}
+		t.writeBufferFlushedC <- b
+	}()
+	return true
+}
+
+// handleWriteBufferFlushed is called after the buffered pieces are written while the torrent is stopping.
+func (t *torrent) handleWriteBufferFlushed(b *piecewriter.Batch) {
+	t.saveFlushedWrites(b)
+	if t.stoppedEventAnnouncer == nil {
+		// Stopped event is already announced to the trackers.
+		t.finishStop()
+	}
+}
+
+func (t *torrent) saveFlushedWrites(b *piecewriter.Batch) {
+	t.writeBufferFlushing = false
+	t.finishBufferedWrites(b.Writers)
+	if t.bitfield != nil {
+		_ = t.writeBitfield()
+	}
+}
+
+func (t *torrent) startWriteBufferTimer() {
+	if t.writeBufferTimer != nil {
+		return
+	}
+	t.writeBufferTimer = time.NewTimer(t.session.config.WriteBufferFlushInterval)
+	t.writeBufferTimeoutC = t.writeBufferTimer.C
+}
+
+func (t *torrent) handleWriteBufferTimeout() {
+	t.writeBufferTimer = nil
+	t.writeBufferTimeoutC = nil
+	t.flushWriteBuffer()
+}
+
+func (t *torrent) stopWriteBufferTimer() {
+	if t.writeBufferTimer != nil {
+		t.writeBufferTimer.Stop()
+		t.writeBufferTimer = nil
+		t.writeBufferTimeoutC = nil
+	}
+}
@@ -0,0 +1,52 @@
+package torrent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+)
+
+func TestWriteBufferStop(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t)
+	defer cl()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	// Keep pieces in memory until the torrent is stopped.
+	s.config.WriteBufferSize = 1 << 30
+	s.config.WriteBufferFlushInterval = time.Hour
+	// Slow down the download, so it can be stopped in the middle.
+	s.SetSpeedLimitDownload(2 << 20)
+
+	tor := addTorrentFile(t, s, nil)
+	if err := tor.AddPeer(addr); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "pieces are not downloaded", func() bool { return tor.Stats().Bytes.Downloaded >= 4<<20 })
+	if have := tor.Stats().Pieces.Have; have != 0 {
+		t.Fatalf("pieces must not be written before the buffer is flushed, have %d", have)
+	}
+	err := tor.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, tor, Stopped)
+	have := tor.Stats().Pieces.Have
+	if have == 0 {
+		t.Fatal("buffered pieces are not written on stop")
+	}
+
+	res, err := tor.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor.torrent.mBitfield.RLock()
+	defer tor.torrent.mBitfield.RUnlock()
+	for i := uint32(0); i < res.NumPieces; i++ {
+		if tor.torrent.bitfield.Test(i) && !res.PieceOK(i) {
+			t.Fatalf("piece #%d is marked as downloaded but its data is not written", i)
+		}
+	}
+}